@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/logger"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/redis"
+)
+
+const bundleUsage = `usage: re9ai-whatsapp-adapter bundle <command>
+
+commands:
+  export [-o file]                   write the templates, automations and policies as a JSON bundle
+  import [-dry-run] [-prune] <file>  apply a bundle exported by another environment ("-" reads stdin);
+                                     -dry-run only lists the differences, -prune deletes automations
+                                     missing from the bundle`
+
+// runBundleCommand runs a bundle subcommand against the configured database and
+// returns the process exit code
+func runBundleCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, bundleUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("bundle "+args[0], flag.ContinueOnError)
+	output := flags.String("o", "-", "file to write the bundle to")
+	dryRun := flags.Bool("dry-run", false, "only list the differences")
+	prune := flags.Bool("prune", false, "delete automations missing from the bundle")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if args[0] != "export" && (args[0] != "import" || flags.NArg() != 1) {
+		fmt.Fprintln(os.Stderr, bundleUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	log := logger.New(cfg.LogLevel)
+	log.SetOutput(os.Stderr)
+
+	db, err := database.NewPostgresConnection(cfg.DatabaseURL, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	redisClient, err := redis.NewRedisClient(cfg.RedisURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to Redis: %v\n", err)
+		return 1
+	}
+	defer redisClient.Close()
+
+	// Running replicas reload automations when the import publishes the change
+	cacheBus, err := services.NewCacheBus(cfg, db, redisClient, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize cache bus: %v\n", err)
+		return 1
+	}
+	automationService := services.NewAutomationService(db, cacheBus, nil, nil, nil, cfg, log)
+	bundleService := services.NewBundleService(db, automationService, cfg, log)
+
+	switch args[0] {
+	case "export":
+		bundle, err := bundleService.Export(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export bundle: %v\n", err)
+			return 1
+		}
+
+		out := os.Stdout
+		if *output != "-" {
+			if out, err = os.Create(*output); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to create %s: %v\n", *output, err)
+				return 1
+			}
+			defer out.Close()
+		}
+		if err := printJSON(out, bundle); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write bundle: %v\n", err)
+			return 1
+		}
+		return 0
+
+	default:
+		in := os.Stdin
+		if path := flags.Arg(0); path != "-" {
+			if in, err = os.Open(path); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to open %s: %v\n", path, err)
+				return 1
+			}
+			defer in.Close()
+		}
+
+		var bundle models.ConfigBundle
+		if err := json.NewDecoder(in).Decode(&bundle); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read bundle: %v\n", err)
+			return 1
+		}
+
+		actor := "cli"
+		if current, err := user.Current(); err == nil {
+			actor = "cli:" + current.Username
+		}
+		result, err := bundleService.Import(ctx, &bundle, *dryRun, *prune, actor, "", "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to import bundle: %v\n", err)
+			return 1
+		}
+		if err := printJSON(os.Stdout, result); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print result: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// printJSON writes v as indented JSON
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
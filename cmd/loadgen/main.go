@@ -0,0 +1,325 @@
+// Command loadgen replays synthetic Twilio WhatsApp webhooks against a running adapter
+// at a fixed rate and reports handler latency, database write throughput and the lag of
+// the asynchronous forwarding to the orchestrator.
+//
+// Usage:
+//
+//	go run ./cmd/loadgen -target http://localhost:8080 -rate 200 -duration 1m
+//
+// Throughput and lag are read from the adapter's /metrics endpoint before and after the
+// run. Point the adapter at a stub orchestrator so forwarding does not reach production.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// options are the command line flags
+type options struct {
+	target      string
+	rate        int
+	duration    time.Duration
+	concurrency int
+	senders     int
+	to          string
+	mediaRatio  float64
+	mediaURL    string
+	authToken   string
+	timeout     time.Duration
+}
+
+// result is the outcome of one webhook request
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.target, "target", "http://localhost:8080", "base URL of the adapter")
+	flag.IntVar(&opts.rate, "rate", 50, "webhooks per second")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to send")
+	flag.IntVar(&opts.concurrency, "concurrency", 64, "maximum requests in flight")
+	flag.IntVar(&opts.senders, "senders", 1000, "number of distinct synthetic senders")
+	flag.StringVar(&opts.to, "to", "whatsapp:+14155238886", "adapter number the webhooks are addressed to")
+	flag.Float64Var(&opts.mediaRatio, "media-ratio", 0, "fraction of webhooks carrying an image (0-1)")
+	flag.StringVar(&opts.mediaURL, "media-url", "https://demo.twilio.com/owl.png", "image URL used for media webhooks")
+	flag.StringVar(&opts.authToken, "auth-token", "", "Twilio auth token to sign webhooks with (X-Twilio-Signature)")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "per-request timeout")
+	flag.Parse()
+
+	if opts.rate <= 0 || opts.concurrency <= 0 || opts.senders <= 0 || opts.duration <= 0 {
+		fmt.Fprintln(os.Stderr, "rate, concurrency, senders and duration must be positive")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &http.Client{Timeout: opts.timeout}
+	webhookURL := strings.TrimRight(opts.target, "/") + "/webhooks/whatsapp/messages"
+	metricsURL := strings.TrimRight(opts.target, "/") + "/metrics"
+
+	before, err := scrapeMetrics(client, metricsURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to read metrics before the run: %v\n", err)
+	}
+
+	fmt.Printf("Sending %d webhooks/s to %s for %s\n", opts.rate, webhookURL, opts.duration)
+	results, elapsed := run(ctx, client, webhookURL, opts)
+
+	// Give the asynchronous processing a moment to catch up before reading lag
+	time.Sleep(2 * time.Second)
+	after, err := scrapeMetrics(client, metricsURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to read metrics after the run: %v\n", err)
+	}
+
+	report(results, elapsed, opts, before, after)
+}
+
+// run sends webhooks at the configured rate until the duration ends or ctx is canceled
+func run(ctx context.Context, client *http.Client, webhookURL string, opts options) ([]result, time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+
+	jobs := make(chan url.Values, opts.concurrency)
+	resultsCh := make(chan result, opts.concurrency)
+
+	var workers sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for form := range jobs {
+				resultsCh <- send(client, webhookURL, form, opts.authToken)
+			}
+		}()
+	}
+
+	var results []result
+	collected := make(chan struct{})
+	go func() {
+		for r := range resultsCh {
+			results = append(results, r)
+		}
+		close(collected)
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(opts.rate))
+	defer ticker.Stop()
+
+	seq := 0
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			seq++
+			select {
+			case jobs <- syntheticWebhook(seq, opts):
+			case <-ctx.Done():
+				break loop
+			}
+		}
+	}
+	elapsed := time.Since(start)
+
+	close(jobs)
+	workers.Wait()
+	close(resultsCh)
+	<-collected
+
+	return results, elapsed
+}
+
+// syntheticWebhook builds the form Twilio posts for an inbound WhatsApp message
+func syntheticWebhook(seq int, opts options) url.Values {
+	sender := fmt.Sprintf("+1555%07d", rand.Intn(opts.senders))
+	sid := fmt.Sprintf("SM%016x%016x", rand.Uint64(), rand.Uint64())
+
+	form := url.Values{}
+	form.Set("MessageSid", sid)
+	form.Set("SmsMessageSid", sid)
+	form.Set("AccountSid", "AC00000000000000000000000000000000")
+	form.Set("From", "whatsapp:"+sender)
+	form.Set("To", opts.to)
+	form.Set("WaId", strings.TrimPrefix(sender, "+"))
+	form.Set("ProfileName", "Load Test "+strconv.Itoa(seq%opts.senders))
+	form.Set("Body", fmt.Sprintf("load test message %d", seq))
+	form.Set("NumMedia", "0")
+	form.Set("ApiVersion", "2010-04-01")
+
+	if opts.mediaRatio > 0 && rand.Float64() < opts.mediaRatio {
+		form.Set("NumMedia", "1")
+		form.Set("MediaUrl0", opts.mediaURL)
+		form.Set("MediaContentType0", "image/png")
+	}
+
+	return form
+}
+
+// send posts one webhook and measures its latency
+func send(client *http.Client, webhookURL string, form url.Values, authToken string) result {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result{err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if authToken != "" {
+		req.Header.Set("X-Twilio-Signature", twilioSignature(authToken, webhookURL, form))
+	} else {
+		req.Header.Set("X-Twilio-Signature", "loadgen")
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return result{latency: latency, err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return result{status: resp.StatusCode, latency: latency}
+}
+
+// twilioSignature computes X-Twilio-Signature: the base64 HMAC-SHA1 of the URL followed
+// by every form parameter name and value, sorted by name
+func twilioSignature(authToken, webhookURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var payload strings.Builder
+	payload.WriteString(webhookURL)
+	for _, key := range keys {
+		payload.WriteString(key)
+		payload.WriteString(form.Get(key))
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(payload.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// scrapeMetrics reads the adapter's metrics, summing series of the same name
+func scrapeMetrics(client *http.Client, metricsURL string) (map[string]float64, error) {
+	resp, err := client.Get(metricsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		space := strings.LastIndexByte(line, ' ')
+		if space < 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(line[space+1:], 64)
+		if err != nil {
+			continue
+		}
+
+		name := line[:space]
+		// Only inbound writes are caused by the load
+		if strings.HasPrefix(name, "messages_stored_total{") && !strings.Contains(name, `direction="inbound"`) {
+			continue
+		}
+		if brace := strings.IndexByte(name, '{'); brace >= 0 {
+			name = name[:brace]
+		}
+		values[name] += value
+	}
+
+	return values, scanner.Err()
+}
+
+// report prints latency percentiles, status codes and the server-side metrics deltas
+func report(results []result, elapsed time.Duration, opts options, before, after map[string]float64) {
+	statuses := make(map[string]int)
+	latencies := make([]time.Duration, 0, len(results))
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			statuses["error"]++
+		default:
+			statuses[strconv.Itoa(r.status)]++
+			latencies = append(latencies, r.latency)
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Println()
+	fmt.Printf("Requests:        %d in %s (%.1f/s, target %d/s)\n",
+		len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds(), opts.rate)
+
+	codes := make([]string, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Printf("  %-14s %d\n", code+":", statuses[code])
+	}
+
+	if len(latencies) > 0 {
+		fmt.Printf("Handler latency: p50 %s  p90 %s  p99 %s  max %s\n",
+			percentile(latencies, 0.50), percentile(latencies, 0.90),
+			percentile(latencies, 0.99), latencies[len(latencies)-1])
+	}
+
+	if before == nil || after == nil {
+		return
+	}
+
+	stored := after["messages_stored_total"] - before["messages_stored_total"]
+	fmt.Printf("DB writes:       %.0f inbound messages stored (%.1f/s)\n", stored, stored/elapsed.Seconds())
+
+	forwards := after["inbound_forwards_total"] - before["inbound_forwards_total"]
+	if forwards > 0 {
+		lag := (after["inbound_forward_lag_seconds_total"] - before["inbound_forward_lag_seconds_total"]) / forwards
+		fmt.Printf("Queue lag:       %.0f forwarded, average %s from receipt to forwarding\n",
+			forwards, time.Duration(lag*float64(time.Second)).Round(time.Millisecond))
+	}
+	fmt.Printf("Backlog:         %.0f async tasks still in flight\n", after["inbound_async_tasks_in_flight"])
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	index := int(float64(len(sorted)-1) * p)
+	return sorted[index].Round(time.Microsecond)
+}
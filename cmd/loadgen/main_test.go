@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"p50", latencies, 0.50, 50 * time.Millisecond},
+		{"p99", latencies, 0.99, 99 * time.Millisecond},
+		{"max", latencies, 1, 100 * time.Millisecond},
+		{"single sample", []time.Duration{7 * time.Millisecond}, 0.99, 7 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwilioSignature(t *testing.T) {
+	opts := options{senders: 10, to: "whatsapp:+15550000000"}
+	form := syntheticWebhook(1, opts)
+
+	payload := "https://adapter.example.com/webhook/whatsapp" +
+		"AccountSid" + form.Get("AccountSid") +
+		"ApiVersion" + form.Get("ApiVersion") +
+		"Body" + form.Get("Body") +
+		"From" + form.Get("From") +
+		"MessageSid" + form.Get("MessageSid") +
+		"NumMedia" + form.Get("NumMedia") +
+		"ProfileName" + form.Get("ProfileName") +
+		"SmsMessageSid" + form.Get("SmsMessageSid") +
+		"To" + form.Get("To") +
+		"WaId" + form.Get("WaId")
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write([]byte(payload))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if got := twilioSignature("secret", "https://adapter.example.com/webhook/whatsapp", form); got != want {
+		t.Errorf("twilioSignature() = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkSyntheticWebhook(b *testing.B) {
+	opts := options{senders: 1000, to: "whatsapp:+15550000000", mediaRatio: 0.1, mediaURL: "https://example.com/image.png"}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		form := syntheticWebhook(i, opts)
+		twilioSignature("secret", "https://adapter.example.com/webhook/whatsapp", form)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+const configUsage = `usage: re9ai-whatsapp-adapter config <command>
+
+commands:
+  validate       check the effective configuration and exit non-zero if it is invalid
+  print [-json]  show every setting with its effective value and source, secrets masked`
+
+// runConfigCommand runs a config subcommand and returns the process exit code
+func runConfigCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "validate":
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "configuration is invalid: %v\n", err)
+			return 1
+		}
+		fmt.Println("configuration is valid")
+		return 0
+
+	case "print":
+		if len(args) > 1 && args[1] == "-json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(map[string]interface{}{
+				"files":    cfg.Files(),
+				"settings": cfg.Settings(),
+			}); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to print configuration: %v\n", err)
+				return 1
+			}
+			return 0
+		}
+
+		if files := cfg.Files(); len(files) > 0 {
+			fmt.Printf("# files: %s\n", strings.Join(files, ", "))
+		}
+		for _, setting := range cfg.Settings() {
+			fmt.Printf("%-8s %s=%s\n", setting.Source, setting.Key, setting.Value)
+		}
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, configUsage)
+	return 2
+}
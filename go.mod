@@ -18,6 +18,7 @@ require (
 	github.com/go-playground/validator/v10 v10.16.0
 	github.com/twilio/twilio-go v1.15.2
 	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -51,5 +52,4 @@ require (
 	golang.org/x/sys v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
\ No newline at end of file
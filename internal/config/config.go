@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds all configuration for the WhatsApp adapter service
@@ -13,18 +15,100 @@ type Config struct {
 	Environment string
 	LogLevel    string
 
+	// Proxies (IPs or CIDRs) whose forwarding headers are trusted to carry the client IP,
+	// e.g. the load balancer subnets; empty trusts none and uses the peer address
+	TrustedProxies  []string
+	RemoteIPHeaders []string
+
 	// Database configuration
 	DatabaseURL string
+
+	// Query limits; 0 disables the timeout or slow query logging
+	DBQueryTimeout       time.Duration // per MessageService call
+	DBSlowQueryThreshold time.Duration
 	RedisURL    string
 
+	// Cross-replica cache invalidation transport: "redis" or "postgres" (LISTEN/NOTIFY)
+	CacheBusTransport string
+
+	// Values cached in Redis: keys start with the namespace (re9:<environment> when empty;
+	// other Redis keys share it, see RedisNamespace), values are sealed with AES-256-GCM when a key is set, and each class has its own TTL
+	CacheNamespace          string
+	CacheEncryptionKey      string // base64 AES-256 key; empty stores values in the clear
+	CacheEncryptionKeyID    string
+	CacheEncryptionPrevKeys map[string]string // key id -> base64 key, to read values sealed before a rotation
+	CacheTTLs               map[string]string // class=duration, overriding the defaults
+
+	// Boot-time compatibility checks of the schema, Redis and the Twilio sender:
+	// "enforce" refuses to start on failure, "degrade" serves and reports it, "off" skips them
+	StartupChecks   string
+	RedisMinVersion string
+
 	// Twilio configuration
 	TwilioAccountSID       string
 	TwilioAuthToken        string
 	TwilioWhatsAppFrom     string // e.g., "whatsapp:+14155238886"
+
+	// SMS fallback when WhatsApp delivery permanently fails
+	SMSFallbackEnabled    bool
+	SMSFromNumber         string   // e.g., "+14155550100"
+	SMSFallbackCategories []string // message categories that may fall back; "*" for all
+	SMSFallbackErrorCodes []string // Twilio error codes that trigger fallback
+
+	// Outbound transport: "messaging" (Programmable Messaging) or "conversations"
+	TwilioTransport               string
+	TwilioConversationsServiceSID string
+
+	// Twilio API call log for debugging provider issues
+	TwilioCallLogSize       int
+	TwilioCallLogSampleRate float64 // share of successful calls recorded; failures always are
 	
 	// WhatsApp webhook configuration
-	WhatsAppWebhookSecret  string
-	WhatsAppVerifyToken    string
+	WhatsAppWebhookSecret          string
+	WhatsAppWebhookSecondarySecret string // also accepted while WHATSAPP_WEBHOOK_SECRET is rotated
+	WhatsAppVerifyToken            string
+
+	// Meta Messenger / Instagram Direct channels
+	MetaMessengerEnabled bool
+	MetaInstagramEnabled bool
+	MetaPageID           string
+	MetaPageAccessToken  string
+	MetaAppSecret        string
+	MetaVerifyToken      string
+	MetaGraphAPIURL      string
+
+	// Telegram bot channel
+	TelegramEnabled        bool
+	TelegramBotToken       string
+	TelegramMode           string // "webhook" or "polling"
+	TelegramWebhookSecret  string
+	TelegramAPIURL         string
+	TelegramLocationButton string // label of the button of location requests
+
+	// Email-to-conversation bridge
+	EmailEnabled      bool
+	EmailFromAddress  string
+	EmailFromName     string
+	EmailSubject      string
+	EmailWebhookToken string
+	EmailSNSTopicARNs []string // SNS topics SES notifications are accepted from; empty accepts any
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
+
+	// Runtime provider configurations stored encrypted in Postgres
+	ProviderConfigKey             string // base64 AES-256 key; empty disables stored providers
+	ProviderConfigKeyID           string
+	ProviderConfigPrevKeys        map[string]string // key id -> base64 key, to read credentials sealed before a rotation
+	ProviderConfigRefreshInterval time.Duration
+
+	// Keyword automations are cached and reloaded at least this often
+	AutomationsRefreshInterval time.Duration
+
+	// Internal diagnostics server (pprof); disabled when the port is empty
+	DiagnosticsPort  string
+	DiagnosticsToken string
 
 	// AWS configuration for media handling
 	AWSRegion           string
@@ -32,38 +116,418 @@ type Config struct {
 	AWSSecretAccessKey  string
 	S3BucketName        string
 
+	// Server-side encryption and tagging of objects we write to S3
+	S3SSE                 string // "", "AES256" (SSE-S3) or "aws:kms" (SSE-KMS)
+	S3SSEKMSKeyID         string // KMS key for SSE-KMS; the account default when empty
+	S3BucketKeyEnabled    bool   // use an S3 bucket key to reduce KMS requests
+	S3ObjectTagging       bool   // tag objects with their tenant and conversation
+	S3RequireEncryption   bool   // refuse to start unless buckets enforce default encryption
+
+	// Inbound media policy
+	MediaAllowedTypes     []string // MIME types; "image/*" style wildcards allowed
+	MediaMaxImageBytes    int
+	MediaMaxVideoBytes    int
+	MediaMaxAudioBytes    int
+	MediaMaxDocumentBytes int
+
+	// Twilio MessageType values answered with an explanation instead of being processed
+	UnsupportedInboundTypes []string
+
+	// Inbound text normalization before storage and forwarding
+	InboundNormalizationEnabled bool
+	InboundUnicodeForm          string // "nfc", "nfkc" or "none"
+	InboundMaxRepeat            int    // longest run of one character kept; 0 keeps every run
+	InboundStripSkinTones       bool   // reduce emoji with a skin tone to the default emoji
+
+	// Translation of inbound text to the working language and of replies back
+	TranslationEnabled         bool
+	TranslationProvider        string // "libretranslate"
+	TranslationURL             string
+	TranslationAPIKey          string
+	TranslationWorkingLanguage string // language the bot and orchestrator work in, e.g. "pt"
+	TranslationTimeout         time.Duration
+
+	// Sentiment scoring of inbound text and escalation of negative streaks
+	SentimentEnabled           bool
+	SentimentProvider          string // "lexicon" or "http"
+	SentimentURL               string
+	SentimentAPIToken          string // sent as a bearer token
+	SentimentTimeout           time.Duration
+	SentimentNegativeThreshold float64 // scores at or below are negative
+	SentimentEscalationStreak  int     // consecutive negative messages that escalate; 0 disables
+	SentimentEscalationTag     string
+
+	// Satisfaction surveys after sessions close
+	CSATEnabled        bool
+	CSATTemplateSID    string
+	CSATDelay          time.Duration // after the session closes
+	CSATResponseWindow time.Duration // ratings arriving later are not recorded
+	CSATPollInterval   time.Duration
+
+	// Media URLs in orchestrator payloads
+	OrchestratorMediaURLs   string        // "presigned" or "media_id"
+	OrchestratorMediaURLTTL time.Duration // lifetime of presigned URLs
+
+	// Media inspection
+	MediaInfoProbeBytes int // leading bytes of audio and video read to find their duration
+
+	// Content-addressed inbound media
+	MediaDedupEnabled bool // store inbound media once per SHA-256 and reuse its analysis
+
+	// Direct uploads to the media bucket
+	MediaUploadURLTTL time.Duration // lifetime of presigned upload URLs
+
+	// Content template asking users to resend media that expired before it was fetched
+	MediaResendTemplateSID string
+
+	// Messages users delete for everyone: "keep" the content or "purge" it
+	RevokedMessagePolicy string
+
+	// Content template asking WhatsApp users to share their location
+	LocationRequestTemplateSID string
+
+	// Payment requests: order template, default currency and status webhook token
+	PaymentTemplateSID  string
+	PaymentCurrency     string
+	PaymentWebhookToken string
+
+	// Appointment confirmations: template, time zone of its date and time, and the
+	// calendar service told about answers; the calendar is not called when the URL is empty
+	AppointmentTemplateSID string
+	AppointmentTimezone    string
+	CalendarWebhookURL     string
+	CalendarWebhookToken   string // sent as a bearer token
+
+	// OCR for images of documents
+	OCREnabled          bool
+	OCRTesseractPath    string
+	OCRLanguages        string
+	OCRDocumentMinChars int
+
+	// Voice notes
+	VoiceNoteMaxDuration time.Duration
+	TranscriptionTimeout time.Duration // voice notes are forwarded without a transcript after this; 0 waits forever
+	FFprobePath          string
+
 	// External service URLs
 	ChatOrchestratorURL string
 	AIProcessingURL     string
 
-	// Rate limiting
+	// Webhook responses
+	WebhookEarlyAck      bool          // answer Twilio webhooks once stored, process them from the outbox
+	WebhookLatencyBudget time.Duration // responses slower than this are counted as over budget
+
+	// Webhook requests
+	WebhookBaseURL           string // public base URL webhooks are configured with; empty rebuilds it from Host
+	WebhookMaxBodyBytes      int    // larger webhook bodies are rejected with 413
+	EmailWebhookMaxBodyBytes int    // the same for inbound email webhooks, which carry attachments
+
+	// Raw webhook requests kept in webhook_events for audit
+	WebhookEventsEnabled   bool
+	WebhookEventsRetention time.Duration
+
+	// Transactional outbox for work that must follow a stored message (orchestrator forwards)
+	OutboxPollInterval time.Duration // also the first retry delay, doubled after each failure
+	OutboxMaxAttempts  int
+	OutboxRetention    time.Duration // how long processed entries are kept
+
+	// Monthly whatsapp_messages partitions
+	MessagePartitionsAhead       int // future months kept ready
+	MessageRetentionMonths       int // 0 keeps every partition
+	PartitionMaintenanceInterval time.Duration
+
+	// Cold storage for idle conversations
+	ArchiveAfterMonths  int // 0 disables archiving; archived history stays readable
+	ArchiveBucket       string
+	ArchivePrefix       string
+	ArchiveStorageClass string
+	ArchiveInterval     time.Duration
+
+	// Shadow traffic: copies of accepted webhooks and orchestrator forwards sent to a
+	// second deployment; their responses are ignored
+	ShadowWebhookURL      string  // base URL of the shadow adapter; empty disables webhook mirroring
+	ShadowOrchestratorURL string  // base URL of the shadow orchestrator; empty disables forward mirroring
+	ShadowSampleRate      float64 // share of traffic mirrored, 0 to 1
+	ShadowSampleBy        string  // "sender" mirrors whole conversations, "request" samples each request
+	ShadowTimeout         time.Duration
+	ShadowMaxConcurrency  int // mirrored requests in flight; more are dropped
+
+	// Synthetic canary conversations
+	CanaryInterval   time.Duration // time between canary messages; 0 disables the canary
+	CanaryFromNumber string        // monitoring number the canary sends from
+	CanaryToNumber   string        // number the canary sends to; defaults to TwilioWhatsAppFrom
+	CanaryTimeout    time.Duration // time a canary message has to be stored and forwarded
+
+	// Historical conversation imports
+	ImportMaxMessages int      // records accepted per import
+	ImportBuckets     []string // S3 buckets imports may read from; empty disables S3 imports
+
+	// Outgoing request signing (HMAC, see pkg/signing)
+	SigningKeyID              string
+	OrchestratorSigningSecret string
+	AIProcessingSigningSecret string
+
+	// Inbound message classification for lead scoring; disabled when the URL is empty
+	ClassifierURL            string
+	ClassifierSigningSecret  string
+	ClassifierTimeout        time.Duration
+	ClassifierMinConfidence  float64
+	ClassifierMaxConcurrency int
+
+	// Conversation export to the CRM on session close; disabled when the URL is empty
+	CRMExportURL           string
+	CRMExportAuthToken     string // sent as a bearer token
+	CRMExportSigningSecret string
+	CRMExportTimeout       time.Duration
+	CRMExportMaxAttempts   int
+	CRMExportRetryInterval time.Duration // base delay, doubled after every failed attempt
+
+	// AI processing callbacks
+	AICallbackToken   string // shared token the AI service presents on callbacks
+	AICallbackBaseURL string // public base URL of this adapter, e.g. "https://wa.re9.ai"
+
+	// Event notifications
+	EventsChannel   string
+	EventWebhookURL string
+
+	// Failure spike alerting; alerts are published as anomaly.detected events and sent
+	// to every configured destination
+	AnomalyDetectionEnabled      bool
+	AnomalyCheckInterval         time.Duration
+	AnomalyWindow                time.Duration
+	AnomalyFailureRate           float64       // share of outbound messages failing
+	AnomalyOrchestratorErrorRate float64       // share of orchestrator forward attempts failing
+	AnomalyMinVolume             int           // windows with fewer messages or attempts never alert
+	AnomalyCooldown              time.Duration // one alert per rule per cooldown across replicas
+	AlertWebhookURL              string
+	AlertSNSTopicARN             string
+	AlertPagerDutyRoutingKey     string
+
+	// Delivery SLOs; targets are the share of messages that must meet the threshold
+	SLOInboundForwardTarget      float64
+	SLOInboundForwardThreshold   time.Duration // receipt to orchestrator forward
+	SLOOutboundDeliveryTarget    float64
+	SLOOutboundDeliveryThreshold time.Duration // send to delivery receipt
+	SLOWindow                    time.Duration // compliance and error budget period
+	SLOEvaluationInterval        time.Duration
+
+	// Metric labels; numbers and tenants past their limits are reported as "other"
+	MetricsTenants    map[string]string // our number -> tenant name
+	MetricsMaxTenants int
+	MetricsMaxNumbers int
+
+	// Rate limiting per client IP; 0 requests per minute disables it
 	RateLimitPerMinute int
 	RateLimitBurst     int
 
+	// Detection of abusive API callers: thresholds per caller within the window, after
+	// which the caller is locked out (0 disables a check)
+	SecurityWindow           time.Duration
+	SecurityLockout          time.Duration
+	SecurityAuthFailureLimit int // 401 responses per IP
+	SecurityDeniedLimit      int // 403 responses per key or IP
+	SecurityNotFoundLimit    int // 404 responses per key or IP, e.g. ID enumeration
+	SecurityKeyIPLimit       int // distinct IPs using one API key
+
+	// Response compression; level 1 (fastest) to 9 (smallest), 0 disables it
+	CompressionLevel   int
+	CompressionMinSize int      // bytes
+	CompressionTypes   []string // content types compressed, without parameters
+
+	// Shared transport of downstream HTTP clients; a DNS cache TTL of 0 disables caching
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+	HTTPDNSCacheTTL         time.Duration
+
+	// Egress proxy of the AI and media download clients, for partners that allowlist
+	// fixed IPs. Only EGRESS_PROXY_HOSTS go through it, or every destination when empty.
+	EgressProxyURL       string
+	EgressProxyHosts     []string // exact host names, or ".domain" / "*.domain" for subdomains
+	EgressProxyHealthURL string   // requested through the proxy by readiness checks; empty skips them
+
 	// Security
 	JWTSecret string
+
+	// Tenant API keys on /api/v1. When not required, requests without a key are let
+	// through with APIKeylessRole, but a key that is presented must be valid.
+	APIKeysRequired     bool
+	APIKeylessRole      string        // role of requests without a key: readonly, sender or operator
+	APIKeyRotationGrace time.Duration // how long a rotated key keeps working by default
+
+	// Duplicate outbound suppression (0 disables)
+	DuplicateSendWindow time.Duration
+
+	// Caps on proactive messages per recipient and calendar day or week (0 disables);
+	// each send counts the weight of its category, 1 unless listed
+	NotificationDailyCap         int
+	NotificationWeeklyCap        int
+	NotificationCategoryWeights  map[string]string // category=weight
+	NotificationExemptCategories []string          // categories never capped, e.g. transactional
+
+	// Acknowledgment of the first message of a new session, suppressed when the 90th
+	// percentile of recent reply latencies is within the threshold
+	FirstResponseAckEnabled    bool
+	FirstResponseAckThreshold  time.Duration
+	FirstResponseAckMinSamples int               // latencies needed before suppressing
+	FirstResponseAckTenants    map[string]string // tenant=threshold or tenant=off, overriding the above
+
+	// Async sends
+	SendWorkers           int           // parallel provider calls for async sends
+	SendQueueSize         int           // async sends waiting for a worker before new ones are rejected
+	SendBatchMax          int           // recipients per batch send
+	SendQueueDrainTimeout time.Duration // time queued sends are still performed for on shutdown
+
+	// Campaigns
+	CampaignRatePerMinute int           // messages per minute per campaign, unless it overrides it
+	CampaignMaxRecipients int           // audience size limit
+	CampaignPollInterval  time.Duration // how often running campaigns send their next recipients
+	CampaignReplyWindow   time.Duration // replies this long after a message count as engagement
+
+	// Campaign frequency caps; campaign messages per recipient and tenant in a rolling window
+	CampaignFrequencyCap        int               // 0 disables the cap
+	CampaignFrequencyWindow     time.Duration     // how far back sends are counted
+	CampaignFrequencyCapTenants map[string]string // tenant=cap, overriding the above; 0 exempts the tenant
+
+	// Campaign quiet hours in each recipient's local time, as HH:MM; empty sends at any hour
+	CampaignQuietHoursStart string
+	CampaignQuietHoursEnd   string
+	CampaignDefaultTimezone string // for recipients whose time zone cannot be inferred
+
+	// Template variables
+	TemplateVariableMaxLength  int               // characters per variable value
+	TemplateVariableMaxLengths map[string]string // variable name -> max length, overriding the default
+	TemplateURLAllowedHosts    []string          // hosts (and their subdomains) variables may link to
+
+	// Link tracking
+	LinkTrackingEnabled bool
+	LinkShortenerDomain string // e.g., "https://go.re9.ai"
+
+	// Chat sessions
+	SessionIdleTimeout    time.Duration
+	SessionSweepInterval  time.Duration
+	SessionSummaryEnabled bool
+
+	// Conversation state machine
+	ConversationStateEnabled bool
+
+	// Longest a conversation's automated replies can be muted for
+	SessionMuteMaxDuration time.Duration
+
+	// Orchestrator chat context
+	ChatContextHistorySize int
+	DefaultLocale          string
+
+	// Directory of <locale>.json files overriding the built-in message catalog
+	I18nOverridesDir string
+
+	// Conversation snapshots
+	SnapshotMaxMessages int
+	SnapshotMaxChars    int
+
+	// Files and values the configuration was resolved from
+	layers *layers
 }
 
-// Load reads configuration from environment variables
-func Load() *Config {
+// load builds the configuration from the layers of the Load call in progress
+func load() *Config {
 	return &Config{
 		// Server configuration
 		Port:        getEnv("PORT", "8080"),
 		Environment: getEnv("ENVIRONMENT", "development"),
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
 
+		// Client IP resolution
+		TrustedProxies:  getEnvAsSlice("TRUSTED_PROXIES", nil),
+		RemoteIPHeaders: getEnvAsSlice("REMOTE_IP_HEADERS", []string{"X-Forwarded-For", "X-Real-IP"}),
+
 		// Database configuration
 		DatabaseURL: getEnv("DATABASE_URL", ""),
+
+		// Query limits
+		DBQueryTimeout:       getEnvAsDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		DBSlowQueryThreshold: getEnvAsDuration("DB_SLOW_QUERY_THRESHOLD", 250*time.Millisecond),
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 
+		// Cache invalidation
+		CacheBusTransport: getEnv("CACHE_BUS_TRANSPORT", "redis"),
+
+		// Redis cache values
+		CacheNamespace:          getEnv("CACHE_NAMESPACE", ""),
+		CacheEncryptionKey:      getEnv("CACHE_ENCRYPTION_KEY", ""),
+		CacheEncryptionKeyID:    getEnv("CACHE_ENCRYPTION_KEY_ID", "c1"),
+		CacheEncryptionPrevKeys: getEnvAsMap("CACHE_ENCRYPTION_PREVIOUS_KEYS"),
+		CacheTTLs:               getEnvAsMap("CACHE_TTLS"),
+
+		// Startup checks
+		StartupChecks:   getEnv("STARTUP_CHECKS", "enforce"),
+		RedisMinVersion: getEnv("REDIS_MIN_VERSION", "6.0"),
+
 		// Twilio configuration
 		TwilioAccountSID:       getEnv("TWILIO_ACCOUNT_SID", ""),
 		TwilioAuthToken:        getEnv("TWILIO_AUTH_TOKEN", ""),
 		TwilioWhatsAppFrom:     getEnv("TWILIO_WHATSAPP_FROM", "whatsapp:+14155238886"),
 
+		// SMS fallback
+		SMSFallbackEnabled:    getEnvAsBool("SMS_FALLBACK_ENABLED", false),
+		SMSFromNumber:         getEnv("SMS_FROM_NUMBER", ""),
+		SMSFallbackCategories: getEnvAsSlice("SMS_FALLBACK_CATEGORIES", []string{"transactional", "otp"}),
+		SMSFallbackErrorCodes: getEnvAsSlice("SMS_FALLBACK_ERROR_CODES", []string{"63003"}),
+
+		// Outbound transport
+		TwilioTransport:               getEnv("TWILIO_TRANSPORT", "messaging"),
+		TwilioConversationsServiceSID: getEnv("TWILIO_CONVERSATIONS_SERVICE_SID", ""),
+
+		// Twilio API call log
+		TwilioCallLogSize:       getEnvAsInt("TWILIO_CALL_LOG_SIZE", 200),
+		TwilioCallLogSampleRate: getEnvAsFloat("TWILIO_CALL_LOG_SAMPLE_RATE", 0.1),
+
 		// WhatsApp webhook configuration
-		WhatsAppWebhookSecret:  getEnv("WHATSAPP_WEBHOOK_SECRET", ""),
-		WhatsAppVerifyToken:    getEnv("WHATSAPP_VERIFY_TOKEN", ""),
+		WhatsAppWebhookSecret:          getEnv("WHATSAPP_WEBHOOK_SECRET", ""),
+		WhatsAppWebhookSecondarySecret: getEnv("WHATSAPP_WEBHOOK_SECRET_SECONDARY", ""),
+		WhatsAppVerifyToken:            getEnv("WHATSAPP_VERIFY_TOKEN", ""),
+
+		// Meta channels
+		MetaMessengerEnabled: getEnvAsBool("META_MESSENGER_ENABLED", false),
+		MetaInstagramEnabled: getEnvAsBool("META_INSTAGRAM_ENABLED", false),
+		MetaPageID:           getEnv("META_PAGE_ID", ""),
+		MetaPageAccessToken:  getEnv("META_PAGE_ACCESS_TOKEN", ""),
+		MetaAppSecret:        getEnv("META_APP_SECRET", ""),
+		MetaVerifyToken:      getEnv("META_VERIFY_TOKEN", ""),
+		MetaGraphAPIURL:      getEnv("META_GRAPH_API_URL", "https://graph.facebook.com/v19.0"),
+
+		// Telegram
+		TelegramEnabled:        getEnvAsBool("TELEGRAM_ENABLED", false),
+		TelegramBotToken:       getEnv("TELEGRAM_BOT_TOKEN", ""),
+		TelegramMode:           getEnv("TELEGRAM_MODE", "webhook"),
+		TelegramWebhookSecret:  getEnv("TELEGRAM_WEBHOOK_SECRET", ""),
+		TelegramAPIURL:         getEnv("TELEGRAM_API_URL", "https://api.telegram.org"),
+		TelegramLocationButton: getEnv("TELEGRAM_LOCATION_BUTTON", "📍 Enviar localização"),
+
+		// Email bridge
+		EmailEnabled:      getEnvAsBool("EMAIL_ENABLED", false),
+		EmailFromAddress:  getEnv("EMAIL_FROM_ADDRESS", ""),
+		EmailFromName:     getEnv("EMAIL_FROM_NAME", "re9.ai"),
+		EmailSubject:      getEnv("EMAIL_SUBJECT", "Sua conversa com a re9.ai"),
+		EmailWebhookToken: getEnv("EMAIL_WEBHOOK_TOKEN", ""),
+		EmailSNSTopicARNs: getEnvAsSlice("EMAIL_SNS_TOPIC_ARNS", nil),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+
+		// Stored provider configurations
+		ProviderConfigKey:             getEnv("PROVIDER_CONFIG_KEY", ""),
+		ProviderConfigKeyID:           getEnv("PROVIDER_CONFIG_KEY_ID", "k1"),
+		ProviderConfigPrevKeys:        getEnvAsMap("PROVIDER_CONFIG_PREVIOUS_KEYS"),
+		ProviderConfigRefreshInterval: getEnvAsDuration("PROVIDER_CONFIG_REFRESH_INTERVAL", time.Minute),
+
+		// Automations
+		AutomationsRefreshInterval: getEnvAsDuration("AUTOMATIONS_REFRESH_INTERVAL", time.Minute),
+
+		// Diagnostics server
+		DiagnosticsPort:  getEnv("DIAGNOSTICS_PORT", ""),
+		DiagnosticsToken: getEnv("DIAGNOSTICS_TOKEN", ""),
 
 		// AWS configuration
 		AWSRegion:           getEnv("AWS_REGION", "us-east-1"),
@@ -71,39 +535,409 @@ func Load() *Config {
 		AWSSecretAccessKey:  getEnv("AWS_SECRET_ACCESS_KEY", ""),
 		S3BucketName:        getEnv("S3_BUCKET_NAME", ""),
 
+		// Server-side encryption and tagging of objects we write to S3
+		S3SSE:               getEnv("S3_SSE", ""),
+		S3SSEKMSKeyID:       getEnv("S3_SSE_KMS_KEY_ID", ""),
+		S3BucketKeyEnabled:  getEnvAsBool("S3_BUCKET_KEY_ENABLED", true),
+		S3ObjectTagging:     getEnvAsBool("S3_OBJECT_TAGGING", true),
+		S3RequireEncryption: getEnvAsBool("S3_REQUIRE_ENCRYPTION", getEnv("ENVIRONMENT", "development") == "production"),
+
+		// Inbound media policy
+		MediaAllowedTypes: getEnvAsSlice("MEDIA_ALLOWED_TYPES", []string{
+			"image/jpeg", "image/png", "image/webp",
+			"video/mp4", "video/3gpp",
+			"audio/ogg", "audio/mpeg", "audio/amr", "audio/mp4", "audio/aac",
+			"application/pdf",
+		}),
+		MediaMaxImageBytes:    getEnvAsInt("MEDIA_MAX_IMAGE_BYTES", 5*1024*1024),
+		MediaMaxVideoBytes:    getEnvAsInt("MEDIA_MAX_VIDEO_BYTES", 16*1024*1024),
+		MediaMaxAudioBytes:    getEnvAsInt("MEDIA_MAX_AUDIO_BYTES", 16*1024*1024),
+		MediaMaxDocumentBytes: getEnvAsInt("MEDIA_MAX_DOCUMENT_BYTES", 100*1024*1024),
+
+		UnsupportedInboundTypes: getEnvAsSlice("UNSUPPORTED_INBOUND_TYPES", []string{"unsupported", "unknown", "poll", "live_location"}),
+
+		// Inbound text normalization
+		InboundNormalizationEnabled: getEnvAsBool("INBOUND_NORMALIZATION_ENABLED", false),
+		InboundUnicodeForm:          getEnv("INBOUND_UNICODE_FORM", "nfc"),
+		InboundMaxRepeat:            getEnvAsInt("INBOUND_MAX_REPEAT", 4),
+		InboundStripSkinTones:       getEnvAsBool("INBOUND_STRIP_SKIN_TONES", false),
+
+		// Translation
+		TranslationEnabled:         getEnvAsBool("TRANSLATION_ENABLED", false),
+		TranslationProvider:        getEnv("TRANSLATION_PROVIDER", "libretranslate"),
+		TranslationURL:             getEnv("TRANSLATION_URL", ""),
+		TranslationAPIKey:          getEnv("TRANSLATION_API_KEY", ""),
+		TranslationWorkingLanguage: getEnv("TRANSLATION_WORKING_LANGUAGE", "pt"),
+		TranslationTimeout:         getEnvAsDuration("TRANSLATION_TIMEOUT", 5*time.Second),
+
+		// Sentiment
+		SentimentEnabled:           getEnvAsBool("SENTIMENT_ENABLED", false),
+		SentimentProvider:          getEnv("SENTIMENT_PROVIDER", "lexicon"),
+		SentimentURL:               getEnv("SENTIMENT_URL", ""),
+		SentimentAPIToken:          getEnv("SENTIMENT_API_TOKEN", ""),
+		SentimentTimeout:           getEnvAsDuration("SENTIMENT_TIMEOUT", 2*time.Second),
+		SentimentNegativeThreshold: getEnvAsFloat("SENTIMENT_NEGATIVE_THRESHOLD", -0.3),
+		SentimentEscalationStreak:  getEnvAsInt("SENTIMENT_ESCALATION_STREAK", 3),
+		SentimentEscalationTag:     getEnv("SENTIMENT_ESCALATION_TAG", "negative_sentiment"),
+
+		// Satisfaction surveys
+		CSATEnabled:        getEnvAsBool("CSAT_ENABLED", false),
+		CSATTemplateSID:    getEnv("CSAT_TEMPLATE_SID", ""),
+		CSATDelay:          getEnvAsDuration("CSAT_DELAY", 10*time.Minute),
+		CSATResponseWindow: getEnvAsDuration("CSAT_RESPONSE_WINDOW", 24*time.Hour),
+		CSATPollInterval:   getEnvAsDuration("CSAT_POLL_INTERVAL", time.Minute),
+
+		// Media URLs in orchestrator payloads
+		OrchestratorMediaURLs:   getEnv("ORCHESTRATOR_MEDIA_URLS", "presigned"),
+		OrchestratorMediaURLTTL: getEnvAsDuration("ORCHESTRATOR_MEDIA_URL_TTL", time.Hour),
+
+		// Media inspection
+		MediaInfoProbeBytes: getEnvAsInt("MEDIA_INFO_PROBE_BYTES", 4*1024*1024),
+
+		// Content-addressed inbound media
+		MediaDedupEnabled: getEnvAsBool("MEDIA_DEDUP_ENABLED", true),
+
+		// Direct uploads to the media bucket
+		MediaUploadURLTTL: getEnvAsDuration("MEDIA_UPLOAD_URL_TTL", 15*time.Minute),
+
+		// Content template asking users to resend media that expired before it was fetched
+		MediaResendTemplateSID: getEnv("MEDIA_RESEND_TEMPLATE_SID", ""),
+
+		// Messages users delete for everyone: "keep" the content or "purge" it
+		RevokedMessagePolicy: getEnv("REVOKED_MESSAGE_POLICY", "keep"),
+
+		// Content template asking WhatsApp users to share their location
+		LocationRequestTemplateSID: getEnv("LOCATION_REQUEST_TEMPLATE_SID", ""),
+
+		// Payment requests: order template, default currency and status webhook token
+		PaymentTemplateSID:  getEnv("PAYMENT_TEMPLATE_SID", ""),
+		PaymentCurrency:     getEnv("PAYMENT_CURRENCY", "BRL"),
+		PaymentWebhookToken: getEnv("PAYMENT_WEBHOOK_TOKEN", ""),
+
+		// Appointment confirmations
+		AppointmentTemplateSID: getEnv("APPOINTMENT_TEMPLATE_SID", ""),
+		AppointmentTimezone:    getEnv("APPOINTMENT_TIMEZONE", "America/Sao_Paulo"),
+		CalendarWebhookURL:     getEnv("CALENDAR_WEBHOOK_URL", ""),
+		CalendarWebhookToken:   getEnv("CALENDAR_WEBHOOK_TOKEN", ""),
+
+		// OCR for images of documents
+		OCREnabled:          getEnvAsBool("OCR_ENABLED", false),
+		OCRTesseractPath:    getEnv("OCR_TESSERACT_PATH", "tesseract"),
+		OCRLanguages:        getEnv("OCR_LANGUAGES", "por+eng"),
+		OCRDocumentMinChars: getEnvAsInt("OCR_DOCUMENT_MIN_CHARS", 200),
+
+		// Voice notes
+		VoiceNoteMaxDuration: getEnvAsDuration("VOICE_NOTE_MAX_DURATION", 5*time.Minute),
+		TranscriptionTimeout: getEnvAsDuration("TRANSCRIPTION_TIMEOUT", 2*time.Minute),
+		FFprobePath:          getEnv("FFPROBE_PATH", "ffprobe"),
+
 		// External service URLs
 		ChatOrchestratorURL: getEnv("CHAT_ORCHESTRATOR_URL", "http://localhost:8081"),
 		AIProcessingURL:     getEnv("AI_PROCESSING_URL", "http://localhost:8082"),
 
+		// Webhook responses
+		WebhookEarlyAck:      getEnvAsBool("WEBHOOK_EARLY_ACK", false),
+		WebhookLatencyBudget: getEnvAsDuration("WEBHOOK_LATENCY_BUDGET", 2*time.Second),
+
+		// Webhook requests
+		WebhookBaseURL:           getEnv("WEBHOOK_BASE_URL", ""),
+		WebhookMaxBodyBytes:      getEnvAsInt("WEBHOOK_MAX_BODY_BYTES", 1024*1024),
+		EmailWebhookMaxBodyBytes: getEnvAsInt("EMAIL_WEBHOOK_MAX_BODY_BYTES", 30*1024*1024),
+
+		// Webhook audit
+		WebhookEventsEnabled:   getEnvAsBool("WEBHOOK_EVENTS_ENABLED", false),
+		WebhookEventsRetention: getEnvAsDuration("WEBHOOK_EVENTS_RETENTION", 30*24*time.Hour),
+
+		// Outbox
+		OutboxPollInterval: getEnvAsDuration("OUTBOX_POLL_INTERVAL", 5*time.Second),
+		OutboxMaxAttempts:  getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxRetention:    getEnvAsDuration("OUTBOX_RETENTION", 72*time.Hour),
+
+		// Message partitions
+		MessagePartitionsAhead:       getEnvAsInt("MESSAGE_PARTITIONS_AHEAD", 3),
+		MessageRetentionMonths:       getEnvAsInt("MESSAGE_RETENTION_MONTHS", 0),
+		PartitionMaintenanceInterval: getEnvAsDuration("PARTITION_MAINTENANCE_INTERVAL", 12*time.Hour),
+
+		// Conversation archive
+		ArchiveAfterMonths:  getEnvAsInt("ARCHIVE_AFTER_MONTHS", 0),
+		ArchiveBucket:       getEnv("ARCHIVE_BUCKET", getEnv("S3_BUCKET_NAME", "")),
+		ArchivePrefix:       getEnv("ARCHIVE_PREFIX", "archive/conversations"),
+		ArchiveStorageClass: getEnv("ARCHIVE_STORAGE_CLASS", "STANDARD_IA"),
+		ArchiveInterval:     getEnvAsDuration("ARCHIVE_INTERVAL", 6*time.Hour),
+
+		// Shadow traffic
+		ShadowWebhookURL:      getEnv("SHADOW_WEBHOOK_URL", ""),
+		ShadowOrchestratorURL: getEnv("SHADOW_ORCHESTRATOR_URL", ""),
+		ShadowSampleRate:      getEnvAsFloat("SHADOW_SAMPLE_RATE", 0.1),
+		ShadowSampleBy:        getEnv("SHADOW_SAMPLE_BY", "sender"),
+		ShadowTimeout:         getEnvAsDuration("SHADOW_TIMEOUT", 5*time.Second),
+		ShadowMaxConcurrency:  getEnvAsInt("SHADOW_MAX_CONCURRENCY", 20),
+
+		// Canary monitor
+		CanaryInterval:   getEnvAsDuration("CANARY_INTERVAL", 0),
+		CanaryFromNumber: getEnv("CANARY_FROM_NUMBER", ""),
+		CanaryToNumber:   getEnv("CANARY_TO_NUMBER", ""),
+		CanaryTimeout:    getEnvAsDuration("CANARY_TIMEOUT", 2*time.Minute),
+
+		// History imports
+		ImportMaxMessages: getEnvAsInt("IMPORT_MAX_MESSAGES", 50000),
+		ImportBuckets:     getEnvAsSlice("IMPORT_BUCKETS", nil),
+
+		// Outgoing request signing
+		SigningKeyID:              getEnv("SIGNING_KEY_ID", "whatsapp-adapter"),
+		OrchestratorSigningSecret: getEnv("ORCHESTRATOR_SIGNING_SECRET", ""),
+		AIProcessingSigningSecret: getEnv("AI_PROCESSING_SIGNING_SECRET", ""),
+
+		// Inbound message classification
+		ClassifierURL:            getEnv("CLASSIFIER_URL", ""),
+		ClassifierSigningSecret:  getEnv("CLASSIFIER_SIGNING_SECRET", ""),
+		ClassifierTimeout:        getEnvAsDuration("CLASSIFIER_TIMEOUT", 5*time.Second),
+		ClassifierMinConfidence:  getEnvAsFloat("CLASSIFIER_MIN_CONFIDENCE", 0.5),
+		ClassifierMaxConcurrency: getEnvAsInt("CLASSIFIER_MAX_CONCURRENCY", 8),
+
+		// CRM export
+		CRMExportURL:           getEnv("CRM_EXPORT_URL", ""),
+		CRMExportAuthToken:     getEnv("CRM_EXPORT_AUTH_TOKEN", ""),
+		CRMExportSigningSecret: getEnv("CRM_EXPORT_SIGNING_SECRET", ""),
+		CRMExportTimeout:       getEnvAsDuration("CRM_EXPORT_TIMEOUT", 10*time.Second),
+		CRMExportMaxAttempts:   getEnvAsInt("CRM_EXPORT_MAX_ATTEMPTS", 8),
+		CRMExportRetryInterval: getEnvAsDuration("CRM_EXPORT_RETRY_INTERVAL", time.Minute),
+
+		// AI processing callbacks
+		AICallbackToken:   getEnv("AI_CALLBACK_TOKEN", ""),
+		AICallbackBaseURL: getEnv("AI_CALLBACK_BASE_URL", ""),
+
+		// Event notifications
+		EventsChannel:   getEnv("EVENTS_CHANNEL", "whatsapp:events"),
+		EventWebhookURL: getEnv("EVENT_WEBHOOK_URL", ""),
+
+		// Failure spike alerting
+		AnomalyDetectionEnabled:      getEnvAsBool("ANOMALY_DETECTION_ENABLED", false),
+		AnomalyCheckInterval:         getEnvAsDuration("ANOMALY_CHECK_INTERVAL", time.Minute),
+		AnomalyWindow:                getEnvAsDuration("ANOMALY_WINDOW", 5*time.Minute),
+		AnomalyFailureRate:           getEnvAsFloat("ANOMALY_FAILURE_RATE", 0.2),
+		AnomalyOrchestratorErrorRate: getEnvAsFloat("ANOMALY_ORCHESTRATOR_ERROR_RATE", 0.2),
+		AnomalyMinVolume:             getEnvAsInt("ANOMALY_MIN_VOLUME", 20),
+		AnomalyCooldown:              getEnvAsDuration("ANOMALY_COOLDOWN", 30*time.Minute),
+		AlertWebhookURL:              getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertSNSTopicARN:             getEnv("ALERT_SNS_TOPIC_ARN", ""),
+		AlertPagerDutyRoutingKey:     getEnv("ALERT_PAGERDUTY_ROUTING_KEY", ""),
+
+		// Delivery SLOs
+		SLOInboundForwardTarget:      getEnvAsFloat("SLO_INBOUND_FORWARD_TARGET", 0.99),
+		SLOInboundForwardThreshold:   getEnvAsDuration("SLO_INBOUND_FORWARD_THRESHOLD", 5*time.Second),
+		SLOOutboundDeliveryTarget:    getEnvAsFloat("SLO_OUTBOUND_DELIVERY_TARGET", 0.98),
+		SLOOutboundDeliveryThreshold: getEnvAsDuration("SLO_OUTBOUND_DELIVERY_THRESHOLD", time.Minute),
+		SLOWindow:                    getEnvAsDuration("SLO_WINDOW", 24*time.Hour),
+		SLOEvaluationInterval:        getEnvAsDuration("SLO_EVALUATION_INTERVAL", time.Minute),
+
+		// Metric labels
+		MetricsTenants:    getEnvAsMap("METRICS_TENANTS"),
+		MetricsMaxTenants: getEnvAsInt("METRICS_MAX_TENANTS", 20),
+		MetricsMaxNumbers: getEnvAsInt("METRICS_MAX_NUMBERS", 50),
+
 		// Rate limiting
-		RateLimitPerMinute: getEnvAsInt("RATE_LIMIT_PER_MINUTE", 60),
+		RateLimitPerMinute: getEnvAsInt("RATE_LIMIT_PER_MINUTE", 0),
 		RateLimitBurst:     getEnvAsInt("RATE_LIMIT_BURST", 10),
 
+		// Abusive caller detection
+		SecurityWindow:           getEnvAsDuration("SECURITY_WINDOW", 10*time.Minute),
+		SecurityLockout:          getEnvAsDuration("SECURITY_LOCKOUT", 15*time.Minute),
+		SecurityAuthFailureLimit: getEnvAsInt("SECURITY_AUTH_FAILURE_LIMIT", 10),
+		SecurityDeniedLimit:      getEnvAsInt("SECURITY_DENIED_LIMIT", 30),
+		SecurityNotFoundLimit:    getEnvAsInt("SECURITY_NOT_FOUND_LIMIT", 200),
+		SecurityKeyIPLimit:       getEnvAsInt("SECURITY_KEY_IP_LIMIT", 20),
+
+		// Response compression
+		CompressionLevel:   getEnvAsInt("COMPRESSION_LEVEL", 5),
+		CompressionMinSize: getEnvAsInt("COMPRESSION_MIN_SIZE", 1024),
+		CompressionTypes:   getEnvAsSlice("COMPRESSION_TYPES", []string{"application/json", "application/x-ndjson", "text/csv", "text/plain"}),
+
+		// Downstream HTTP clients
+		HTTPMaxIdleConns:        getEnvAsInt("HTTP_MAX_IDLE_CONNS", 200),
+		HTTPMaxIdleConnsPerHost: getEnvAsInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 32),
+		HTTPIdleConnTimeout:     getEnvAsDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		HTTPDNSCacheTTL:         getEnvAsDuration("HTTP_DNS_CACHE_TTL", 30*time.Second),
+
+		// Egress proxy
+		EgressProxyURL:       getEnv("EGRESS_PROXY_URL", ""),
+		EgressProxyHosts:     getEnvAsSlice("EGRESS_PROXY_HOSTS", nil),
+		EgressProxyHealthURL: getEnv("EGRESS_PROXY_HEALTH_URL", ""),
+
 		// Security
 		JWTSecret: getEnv("JWT_SECRET", ""),
+
+		// Tenant API keys
+		APIKeysRequired:     getEnvAsBool("API_KEYS_REQUIRED", false),
+		APIKeylessRole:      getEnv("API_KEYLESS_ROLE", "readonly"),
+		APIKeyRotationGrace: getEnvAsDuration("API_KEY_ROTATION_GRACE", 24*time.Hour),
+
+		// Duplicate outbound suppression
+		DuplicateSendWindow: getEnvAsDuration("DUPLICATE_SEND_WINDOW", 30*time.Second),
+
+		// Proactive message caps
+		NotificationDailyCap:         getEnvAsInt("NOTIFICATION_DAILY_CAP", 0),
+		NotificationWeeklyCap:        getEnvAsInt("NOTIFICATION_WEEKLY_CAP", 0),
+		NotificationCategoryWeights:  getEnvAsMap("NOTIFICATION_CATEGORY_WEIGHTS"),
+		NotificationExemptCategories: getEnvAsSlice("NOTIFICATION_EXEMPT_CATEGORIES", []string{"transactional", "otp"}),
+
+		// First-message acknowledgments
+		FirstResponseAckEnabled:    getEnvAsBool("FIRST_RESPONSE_ACK_ENABLED", false),
+		FirstResponseAckThreshold:  getEnvAsDuration("FIRST_RESPONSE_ACK_THRESHOLD", 10*time.Second),
+		FirstResponseAckMinSamples: getEnvAsInt("FIRST_RESPONSE_ACK_MIN_SAMPLES", 20),
+		FirstResponseAckTenants:    getEnvAsMap("FIRST_RESPONSE_ACK_TENANTS"),
+
+		// Async sends
+		SendWorkers:           getEnvAsInt("SEND_WORKERS", 8),
+		SendQueueSize:         getEnvAsInt("SEND_QUEUE_SIZE", 1000),
+		SendBatchMax:          getEnvAsInt("SEND_BATCH_MAX", 100),
+		SendQueueDrainTimeout: getEnvAsDuration("SEND_QUEUE_DRAIN_TIMEOUT", 20*time.Second),
+
+		// Campaigns
+		CampaignRatePerMinute: getEnvAsInt("CAMPAIGN_RATE_PER_MINUTE", 60),
+		CampaignMaxRecipients: getEnvAsInt("CAMPAIGN_MAX_RECIPIENTS", 10000),
+		CampaignPollInterval:  getEnvAsDuration("CAMPAIGN_POLL_INTERVAL", 5*time.Second),
+		CampaignReplyWindow:   getEnvAsDuration("CAMPAIGN_REPLY_WINDOW", 24*time.Hour),
+
+		// Campaign frequency caps
+		CampaignFrequencyCap:        getEnvAsInt("CAMPAIGN_FREQUENCY_CAP", 0),
+		CampaignFrequencyWindow:     getEnvAsDuration("CAMPAIGN_FREQUENCY_WINDOW", 7*24*time.Hour),
+		CampaignFrequencyCapTenants: getEnvAsMap("CAMPAIGN_FREQUENCY_CAP_TENANTS"),
+
+		// Campaign quiet hours
+		CampaignQuietHoursStart: getEnv("CAMPAIGN_QUIET_HOURS_START", ""),
+		CampaignQuietHoursEnd:   getEnv("CAMPAIGN_QUIET_HOURS_END", ""),
+		CampaignDefaultTimezone: getEnv("CAMPAIGN_DEFAULT_TIMEZONE", "America/Sao_Paulo"),
+
+		// Template variables
+		TemplateVariableMaxLength:  getEnvAsInt("TEMPLATE_VARIABLE_MAX_LENGTH", 1024),
+		TemplateVariableMaxLengths: getEnvAsMap("TEMPLATE_VARIABLE_MAX_LENGTHS"),
+		TemplateURLAllowedHosts:    getEnvAsSlice("TEMPLATE_URL_ALLOWED_HOSTS", nil),
+
+		// Link tracking
+		LinkTrackingEnabled: getEnvAsBool("LINK_TRACKING_ENABLED", false),
+		LinkShortenerDomain: getEnv("LINK_SHORTENER_DOMAIN", "http://localhost:8080"),
+
+		// Chat sessions
+		SessionIdleTimeout:    getEnvAsDuration("SESSION_IDLE_TIMEOUT", 30*time.Minute),
+		SessionSweepInterval:  getEnvAsDuration("SESSION_SWEEP_INTERVAL", time.Minute),
+		SessionSummaryEnabled: getEnvAsBool("SESSION_SUMMARY_ENABLED", false),
+
+		// Conversation state machine
+		ConversationStateEnabled: getEnvAsBool("CONVERSATION_STATE_ENABLED", false),
+
+		// Longest a conversation's automated replies can be muted for
+		SessionMuteMaxDuration: getEnvAsDuration("SESSION_MUTE_MAX_DURATION", 7*24*time.Hour),
+
+		// Orchestrator chat context
+		ChatContextHistorySize: getEnvAsInt("CHAT_CONTEXT_HISTORY_SIZE", 10),
+		DefaultLocale:          getEnv("DEFAULT_LOCALE", "pt-BR"),
+
+		// Directory of <locale>.json files overriding the built-in message catalog
+		I18nOverridesDir: getEnv("I18N_OVERRIDES_DIR", ""),
+
+		// Conversation snapshots
+		SnapshotMaxMessages: getEnvAsInt("SNAPSHOT_MAX_MESSAGES", 20),
+		SnapshotMaxChars:    getEnvAsInt("SNAPSHOT_MAX_CHARS", 8000),
 	}
 }
 
-// getEnv gets an environment variable with a fallback value
+// getEnv gets a configuration value with a fallback value
 func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
+	if value, exists := lookup(key); exists {
 		return value
 	}
-	return fallback
+	return defaulted(key, fallback)
 }
 
-// getEnvAsInt gets an environment variable as integer with a fallback value
+// getEnvAsInt gets a configuration value as integer with a fallback value
 func getEnvAsInt(key string, fallback int) int {
-	if value, exists := os.LookupEnv(key); exists {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
+	if value, exists := lookup(key); exists {
+		intVal, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid(key, value, "an integer", fallback)
+		}
+		return intVal
+	}
+	return defaulted(key, fallback)
+}
+
+// getEnvAsFloat gets a configuration value as float with a fallback value
+func getEnvAsFloat(key string, fallback float64) float64 {
+	if value, exists := lookup(key); exists {
+		floatVal, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid(key, value, "a number", fallback)
+		}
+		return floatVal
+	}
+	return defaulted(key, fallback)
+}
+
+// getEnvAsBool gets a configuration value as boolean with a fallback value
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, exists := lookup(key); exists {
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid(key, value, "a boolean", fallback)
+		}
+		return boolVal
+	}
+	return defaulted(key, fallback)
+}
+
+// getEnvAsDuration gets a configuration value as duration (e.g., "30m") with a fallback value
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := lookup(key); exists {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return invalid(key, value, "a duration", fallback)
+		}
+		return duration
+	}
+	return defaulted(key, fallback)
+}
+
+// getEnvAsSlice gets a comma-separated configuration value as a string slice with a fallback value
+func getEnvAsSlice(key string, fallback []string) []string {
+	if value, exists := lookup(key); exists {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return defaulted(key, fallback)
+}
+
+// getEnvAsMap gets an environment variable of comma-separated key=value pairs
+func getEnvAsMap(key string) map[string]string {
+	items := make(map[string]string)
+	for _, pair := range getEnvAsSlice(key, nil) {
+		if k, v, ok := strings.Cut(pair, "="); ok && strings.TrimSpace(k) != "" {
+			items[strings.TrimSpace(k)] = strings.TrimSpace(v)
 		}
 	}
-	return fallback
+	return items
+}
+
+// RedisNamespace returns the prefix of every Redis key and channel the adapter uses:
+// CACHE_NAMESPACE, or re9:<environment> when it is empty
+func (c *Config) RedisNamespace() string {
+	if namespace := strings.TrimSuffix(c.CacheNamespace, ":"); namespace != "" {
+		return namespace
+	}
+	return "re9:" + c.Environment
 }
 
 // Validate checks if all required configuration values are set
 func (c *Config) Validate() error {
+	if err := c.validateLayers(); err != nil {
+		return err
+	}
+
 	required := map[string]string{
 		"TWILIO_ACCOUNT_SID":      c.TwilioAccountSID,
 		"TWILIO_AUTH_TOKEN":       c.TwilioAuthToken,
@@ -113,10 +947,224 @@ func (c *Config) Validate() error {
 		"JWT_SECRET":              c.JWTSecret,
 	}
 
+	if c.MetaMessengerEnabled || c.MetaInstagramEnabled {
+		required["META_PAGE_ACCESS_TOKEN"] = c.MetaPageAccessToken
+		required["META_APP_SECRET"] = c.MetaAppSecret
+		required["META_VERIFY_TOKEN"] = c.MetaVerifyToken
+	}
+
+	if c.EmailEnabled {
+		required["EMAIL_FROM_ADDRESS"] = c.EmailFromAddress
+		required["EMAIL_WEBHOOK_TOKEN"] = c.EmailWebhookToken
+		required["SMTP_HOST"] = c.SMTPHost
+	}
+
+	if c.TelegramEnabled {
+		required["TELEGRAM_BOT_TOKEN"] = c.TelegramBotToken
+	}
+
+	if c.SMSFallbackEnabled {
+		required["SMS_FROM_NUMBER"] = c.SMSFromNumber
+	}
+
+	if c.DiagnosticsPort != "" {
+		required["DIAGNOSTICS_TOKEN"] = c.DiagnosticsToken
+	}
+
+	if c.TranslationEnabled {
+		required["TRANSLATION_URL"] = c.TranslationURL
+		required["TRANSLATION_WORKING_LANGUAGE"] = c.TranslationWorkingLanguage
+	}
+
+	if c.CSATEnabled {
+		required["CSAT_TEMPLATE_SID"] = c.CSATTemplateSID
+	}
+
 	for key, value := range required {
 		if value == "" {
-			return fmt.Errorf("required environment variable %s is not set", key)
+			return fmt.Errorf("required setting %s is not set", key)
+		}
+	}
+
+	if c.WhatsAppWebhookSecondarySecret != "" && c.WhatsAppWebhookSecondarySecret == c.WhatsAppWebhookSecret {
+		return fmt.Errorf("WHATSAPP_WEBHOOK_SECRET_SECONDARY must differ from WHATSAPP_WEBHOOK_SECRET")
+	}
+
+	if c.WebhookBaseURL != "" {
+		baseURL, err := url.Parse(c.WebhookBaseURL)
+		if err != nil || baseURL.Host == "" || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.RawQuery != "" {
+			return fmt.Errorf("WEBHOOK_BASE_URL must be an absolute http or https URL without a query, got %q", c.WebhookBaseURL)
+		}
+	}
+	if c.WebhookMaxBodyBytes <= 0 || c.EmailWebhookMaxBodyBytes <= 0 {
+		return fmt.Errorf("WEBHOOK_MAX_BODY_BYTES and EMAIL_WEBHOOK_MAX_BODY_BYTES must be positive")
+	}
+
+	if c.EgressProxyURL != "" {
+		proxyURL, err := url.Parse(c.EgressProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return fmt.Errorf("EGRESS_PROXY_URL must be an absolute URL, got %q", c.EgressProxyURL)
 		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("EGRESS_PROXY_URL scheme must be http, https or socks5, got %q", proxyURL.Scheme)
+		}
+	} else if len(c.EgressProxyHosts) > 0 || c.EgressProxyHealthURL != "" {
+		return fmt.Errorf("EGRESS_PROXY_HOSTS and EGRESS_PROXY_HEALTH_URL require EGRESS_PROXY_URL")
+	}
+
+	switch c.RevokedMessagePolicy {
+	case "keep", "purge":
+	default:
+		return fmt.Errorf("REVOKED_MESSAGE_POLICY must be keep or purge, got %q", c.RevokedMessagePolicy)
+	}
+
+	if _, err := time.LoadLocation(c.AppointmentTimezone); err != nil {
+		return fmt.Errorf("APPOINTMENT_TIMEZONE must be an IANA time zone, got %q", c.AppointmentTimezone)
+	}
+
+	for category, weight := range c.NotificationCategoryWeights {
+		if n, err := strconv.Atoi(weight); err != nil || n < 0 {
+			return fmt.Errorf("NOTIFICATION_CATEGORY_WEIGHTS weight of %q must be a non-negative integer, got %q", category, weight)
+		}
+	}
+
+	for class, value := range c.CacheTTLs {
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("CACHE_TTLS TTL of %q must be a positive duration, got %q", class, value)
+		}
+	}
+
+	switch c.InboundUnicodeForm {
+	case "nfc", "nfkc", "none":
+	default:
+		return fmt.Errorf("INBOUND_UNICODE_FORM must be nfc, nfkc or none, got %q", c.InboundUnicodeForm)
+	}
+	if c.InboundMaxRepeat < 0 {
+		return fmt.Errorf("INBOUND_MAX_REPEAT must not be negative, got %d", c.InboundMaxRepeat)
+	}
+
+	if c.TranslationEnabled && c.TranslationProvider != "libretranslate" {
+		return fmt.Errorf("TRANSLATION_PROVIDER must be libretranslate, got %q", c.TranslationProvider)
+	}
+
+	if c.SentimentEnabled {
+		switch c.SentimentProvider {
+		case "lexicon":
+		case "http":
+			if c.SentimentURL == "" {
+				return fmt.Errorf("SENTIMENT_URL is required with SENTIMENT_PROVIDER=http")
+			}
+		default:
+			return fmt.Errorf("SENTIMENT_PROVIDER must be lexicon or http, got %q", c.SentimentProvider)
+		}
+		if c.SentimentNegativeThreshold < -1 || c.SentimentNegativeThreshold > 1 {
+			return fmt.Errorf("SENTIMENT_NEGATIVE_THRESHOLD must be between -1 and 1, got %g", c.SentimentNegativeThreshold)
+		}
+		if c.SentimentEscalationStreak > 0 && c.SentimentEscalationTag == "" {
+			return fmt.Errorf("SENTIMENT_ESCALATION_TAG must be set to escalate negative conversations")
+		}
+	}
+
+	if c.CSATEnabled && (c.CSATDelay < 0 || c.CSATResponseWindow <= 0 || c.CSATPollInterval <= 0) {
+		return fmt.Errorf("CSAT_DELAY must not be negative, and CSAT_RESPONSE_WINDOW and CSAT_POLL_INTERVAL must be positive")
+	}
+
+	if c.CampaignRatePerMinute <= 0 || c.CampaignMaxRecipients <= 0 || c.CampaignPollInterval <= 0 {
+		return fmt.Errorf("CAMPAIGN_RATE_PER_MINUTE, CAMPAIGN_MAX_RECIPIENTS and CAMPAIGN_POLL_INTERVAL must be positive")
+	}
+
+	if c.CampaignFrequencyCap < 0 || c.CampaignFrequencyWindow <= 0 {
+		return fmt.Errorf("CAMPAIGN_FREQUENCY_CAP must not be negative and CAMPAIGN_FREQUENCY_WINDOW must be positive")
+	}
+	for tenant, value := range c.CampaignFrequencyCapTenants {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("CAMPAIGN_FREQUENCY_CAP_TENANTS cap of %q must be a non-negative integer, got %q", tenant, value)
+		}
+	}
+
+	if (c.CampaignQuietHoursStart == "") != (c.CampaignQuietHoursEnd == "") {
+		return fmt.Errorf("CAMPAIGN_QUIET_HOURS_START and CAMPAIGN_QUIET_HOURS_END must be set together")
+	}
+	for name, value := range map[string]string{
+		"CAMPAIGN_QUIET_HOURS_START": c.CampaignQuietHoursStart,
+		"CAMPAIGN_QUIET_HOURS_END":   c.CampaignQuietHoursEnd,
+	} {
+		if _, err := time.Parse("15:04", value); value != "" && err != nil {
+			return fmt.Errorf("%s must be a time of day as HH:MM, got %q", name, value)
+		}
+	}
+	if c.CampaignQuietHoursStart != "" && c.CampaignQuietHoursStart == c.CampaignQuietHoursEnd {
+		return fmt.Errorf("CAMPAIGN_QUIET_HOURS_START and CAMPAIGN_QUIET_HOURS_END must differ")
+	}
+	if _, err := time.LoadLocation(c.CampaignDefaultTimezone); err != nil {
+		return fmt.Errorf("CAMPAIGN_DEFAULT_TIMEZONE must be an IANA time zone, got %q", c.CampaignDefaultTimezone)
+	}
+
+	if c.TemplateVariableMaxLength <= 0 {
+		return fmt.Errorf("TEMPLATE_VARIABLE_MAX_LENGTH must be positive, got %d", c.TemplateVariableMaxLength)
+	}
+	for name, value := range c.TemplateVariableMaxLengths {
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("TEMPLATE_VARIABLE_MAX_LENGTHS length of %q must be a positive integer, got %q", name, value)
+		}
+	}
+
+	if c.SecurityWindow <= 0 || c.SecurityLockout <= 0 {
+		return fmt.Errorf("SECURITY_WINDOW and SECURITY_LOCKOUT must be positive, got %s and %s", c.SecurityWindow, c.SecurityLockout)
+	}
+
+	if c.TranscriptionTimeout < 0 {
+		return fmt.Errorf("TRANSCRIPTION_TIMEOUT must not be negative, got %s", c.TranscriptionTimeout)
+	}
+
+	switch c.APIKeylessRole {
+	case "readonly", "sender", "operator":
+	default:
+		return fmt.Errorf("API_KEYLESS_ROLE must be readonly, sender or operator, got %q", c.APIKeylessRole)
+	}
+
+	if c.APIKeyRotationGrace < 0 {
+		return fmt.Errorf("API_KEY_ROTATION_GRACE must not be negative, got %s", c.APIKeyRotationGrace)
+	}
+
+	if c.CompressionLevel < 0 || c.CompressionLevel > 9 {
+		return fmt.Errorf("COMPRESSION_LEVEL must be between 0 and 9, got %d", c.CompressionLevel)
+	}
+
+	for tenant, value := range c.FirstResponseAckTenants {
+		if strings.EqualFold(value, "off") {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("FIRST_RESPONSE_ACK_TENANTS threshold of %q must be a positive duration or off, got %q", tenant, value)
+		}
+	}
+
+	switch c.StartupChecks {
+	case "enforce", "degrade", "off":
+	default:
+		return fmt.Errorf("STARTUP_CHECKS must be enforce, degrade or off, got %q", c.StartupChecks)
+	}
+
+	if c.ShadowSampleRate < 0 || c.ShadowSampleRate > 1 {
+		return fmt.Errorf("SHADOW_SAMPLE_RATE must be between 0 and 1, got %v", c.ShadowSampleRate)
+	}
+	switch c.ShadowSampleBy {
+	case "sender", "request":
+	default:
+		return fmt.Errorf("SHADOW_SAMPLE_BY must be sender or request, got %q", c.ShadowSampleBy)
+	}
+
+	if c.CanaryInterval > 0 && c.CanaryTimeout >= c.CanaryInterval {
+		return fmt.Errorf("CANARY_TIMEOUT must be shorter than CANARY_INTERVAL, got %s and %s", c.CanaryTimeout, c.CanaryInterval)
+	}
+
+	switch c.S3SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return fmt.Errorf("S3_SSE must be AES256 or aws:kms, got %q", c.S3SSE)
 	}
 
 	return nil
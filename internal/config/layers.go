@@ -0,0 +1,297 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration layers, lowest precedence first
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceOverlay = "overlay"
+	SourceEnv     = "env"
+)
+
+// defaultConfigFile is read when CONFIG_FILE is not set; it may be missing
+const defaultConfigFile = "config.yaml"
+
+// Setting is the effective value of one configuration key and the layer it came from
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// layers holds the configuration files below the environment and records how each
+// key was resolved while Load runs
+type layers struct {
+	filePath    string
+	overlayPath string
+	file        map[string]string
+	overlay     map[string]string
+
+	settings map[string]*Setting
+	problems []string
+}
+
+// loading is the layer set of the Load call in progress; the getEnv helpers resolve
+// keys through it
+var loading *layers
+
+// Load reads the configuration: built-in defaults, then config.yaml (CONFIG_FILE), then
+// the overlay of the environment (config.<ENVIRONMENT>.yaml next to it), then
+// environment variables. File keys are the environment variable names in lower case;
+// nested keys are joined with underscores, so twilio.auth_token sets TWILIO_AUTH_TOKEN.
+func Load() (*Config, error) {
+	l, err := readLayers()
+	if err != nil {
+		return nil, err
+	}
+
+	loading = l
+	defer func() { loading = nil }()
+
+	cfg := load()
+	cfg.layers = l
+	return cfg, nil
+}
+
+// Settings returns every configuration key with its effective value and source, sorted
+// by key. Secrets are masked.
+func (c *Config) Settings() []Setting {
+	if c.layers == nil {
+		return nil
+	}
+
+	settings := make([]Setting, 0, len(c.layers.settings))
+	for _, setting := range c.layers.settings {
+		masked := *setting
+		masked.Value = maskSetting(setting.Key, setting.Value)
+		settings = append(settings, masked)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// Files returns the configuration files that were read, base file first
+func (c *Config) Files() []string {
+	var files []string
+	if c.layers != nil {
+		for _, path := range []string{c.layers.filePath, c.layers.overlayPath} {
+			if path != "" {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// validateLayers reports values that could not be parsed and file keys that no setting uses
+func (c *Config) validateLayers() error {
+	if c.layers == nil {
+		return nil
+	}
+
+	problems := append([]string(nil), c.layers.problems...)
+	for _, file := range []struct {
+		path   string
+		values map[string]string
+	}{{c.layers.filePath, c.layers.file}, {c.layers.overlayPath, c.layers.overlay}} {
+		for key := range file.values {
+			if _, ok := c.layers.settings[key]; !ok {
+				problems = append(problems, fmt.Sprintf("unknown key %s in %s", strings.ToLower(key), file.path))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// readLayers reads the base configuration file and the overlay of the environment
+func readLayers() (*layers, error) {
+	l := &layers{settings: make(map[string]*Setting)}
+
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	file, err := readConfigFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return l, nil
+		}
+		return nil, err
+	}
+	l.file, l.filePath = file, path
+
+	environment, ok := os.LookupEnv("ENVIRONMENT")
+	if !ok {
+		environment = file["ENVIRONMENT"]
+	}
+	if environment == "" {
+		environment = "development"
+	}
+
+	ext := filepath.Ext(path)
+	overlayPath := strings.TrimSuffix(path, ext) + "." + environment + ext
+	overlay, err := readConfigFile(overlayPath)
+	switch {
+	case err == nil:
+		l.overlay, l.overlayPath = overlay, overlayPath
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// readConfigFile reads a YAML configuration file into environment variable names and values
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flattenConfig("", document, values)
+	return values, nil
+}
+
+// flattenConfig turns nested YAML keys into environment variable names. Lists become
+// comma-separated values and null leaves the key unset.
+func flattenConfig(prefix string, document map[string]interface{}, values map[string]string) {
+	for key, value := range document {
+		name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			flattenConfig(name, v, values)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+}
+
+// lookup resolves a key through the layers, environment first, and records where it came from
+func lookup(key string) (string, bool) {
+	value, ok := os.LookupEnv(key)
+	source := SourceEnv
+	if !ok && loading != nil {
+		if value, ok = loading.overlay[key]; ok {
+			source = SourceOverlay
+		} else if value, ok = loading.file[key]; ok {
+			source = SourceFile
+		}
+	}
+
+	if ok && loading != nil {
+		loading.settings[key] = &Setting{Key: key, Value: value, Source: source}
+	}
+	return value, ok
+}
+
+// defaulted records that a key uses its built-in default and returns it
+func defaulted[T any](key string, fallback T) T {
+	if loading != nil {
+		value := fmt.Sprint(fallback)
+		if items, ok := any(fallback).([]string); ok {
+			value = strings.Join(items, ",")
+		}
+		loading.settings[key] = &Setting{Key: key, Value: value, Source: SourceDefault}
+	}
+	return fallback
+}
+
+// invalid records a value that could not be parsed; the default is used instead
+func invalid[T any](key, value, kind string, fallback T) T {
+	if loading != nil {
+		loading.problems = append(loading.problems, fmt.Sprintf("%s=%q is not %s", key, value, kind))
+	}
+	return defaulted(key, fallback)
+}
+
+// secretMarkers identify keys whose values are masked when printed
+var secretMarkers = []string{"SECRET", "TOKEN", "PASSWORD", "PRIVATE", "CREDENTIAL", "_KEY"}
+
+// maskSetting hides secret values, and the userinfo and query string of URLs, which
+// often carry credentials (webhook tokens, connection passwords)
+func maskSetting(key, value string) string {
+	if value == "" {
+		return value
+	}
+	for _, marker := range secretMarkers {
+		if strings.Contains(key, marker) && !strings.HasSuffix(key, "_KEY_ID") {
+			return "********"
+		}
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+
+	// List settings are joined with commas, so each item may be a URL
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = maskURL(item)
+	}
+	return strings.Join(items, ",")
+}
+
+// maskURL masks the userinfo and query parameter values of a URL, keeping parameter
+// names so the setting stays recognizable. Values that do not parse are masked whole.
+func maskURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "********"
+	}
+
+	masked := ""
+	if u.User != nil {
+		// Set by hand: url.User would escape the mask
+		masked = "********@"
+		u.User = nil
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			name, _, _ := strings.Cut(param, "=")
+			params[i] = name + "=********"
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if masked != "" && u.Host != "" {
+		return u.Scheme + "://" + masked + strings.TrimPrefix(u.String(), u.Scheme+"://")
+	}
+	return u.String()
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+func TestMaskSetting(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+	}{
+		{"plain value", "PORT", "8080", "8080"},
+		{"empty secret", "WHATSAPP_APP_SECRET", "", ""},
+		{"secret key", "WHATSAPP_APP_SECRET", "s3cr3t", "********"},
+		{"key id is not a secret", "ENCRYPTION_KEY_ID", "k1", "k1"},
+		{"url without credentials", "AI_SERVICE_URL", "https://ai.internal/api", "https://ai.internal/api"},
+		{"connection password", "DATABASE_URL", "postgres://app:pw@db:5432/re9", "postgres://********@db:5432/re9"},
+		{"token as username", "CRM_WEBHOOK_URL", "https://tok123@hooks.example.com/in", "https://********@hooks.example.com/in"},
+		{"token in query", "ALERT_WEBHOOK_URL", "https://hooks.example.com/in?token=abc&channel=ops", "https://hooks.example.com/in?token=********&channel=********"},
+		{"token in fragment", "ALERT_WEBHOOK_URL", "https://hooks.example.com/in#abc", "https://hooks.example.com/in"},
+		{"url list", "EVENT_WEBHOOK_URLS", "https://a.example.com/?key=1,https://b.example.com/", "https://a.example.com/?key=********,https://b.example.com/"},
+		{"unparsable url", "ALERT_WEBHOOK_URL", "https://exa mple.com/%zz?token=abc", "********"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskSetting(tt.key, tt.value); got != tt.want {
+				t.Errorf("maskSetting(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
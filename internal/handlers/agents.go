@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AgentHandler handles agent presence and conversation assignment endpoints used by
+// the agent console
+type AgentHandler struct {
+	agentService *services.AgentService
+	logger       *logrus.Logger
+}
+
+// NewAgentHandler creates a new agent handler
+func NewAgentHandler(agentService *services.AgentService, logger *logrus.Logger) *AgentHandler {
+	return &AgentHandler{
+		agentService: agentService,
+		logger:       logger,
+	}
+}
+
+// ListAgents returns the active agents with their presence and workload
+func (h *AgentHandler) ListAgents(c *gin.Context) {
+	agents, err := h.agentService.List(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list agents")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list agents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"agents": agents})
+}
+
+// CreateAgent adds an agent
+func (h *AgentHandler) CreateAgent(c *gin.Context) {
+	var request models.AgentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent request"})
+		return
+	}
+	if request.MaxConcurrent != nil && *request.MaxConcurrent < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_concurrent must not be negative"})
+		return
+	}
+
+	agent, err := h.agentService.Create(c.Request.Context(), &request)
+	if err != nil {
+		if errors.Is(err, services.ErrAgentExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to create agent")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, agent)
+}
+
+// SetPresence records an agent's presence; the console calls it periodically as a heartbeat
+func (h *AgentHandler) SetPresence(c *gin.Context) {
+	agentID, err := uuid.Parse(c.Param("agentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+
+	var request models.AgentPresenceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid presence request"})
+		return
+	}
+
+	agent, err := h.agentService.SetPresence(c.Request.Context(), agentID, request.Presence)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPresence):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrAgentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		default:
+			h.logger.WithError(err).Error("Failed to update agent presence")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update presence"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, agent)
+}
+
+// ListAgentSessions returns the active conversations assigned to an agent
+func (h *AgentHandler) ListAgentSessions(c *gin.Context) {
+	agentID, err := uuid.Parse(c.Param("agentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+
+	sessions, err := h.agentService.ListSessions(c.Request.Context(), agentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list agent sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list agent sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// ClaimSession assigns a conversation to the requesting agent
+func (h *AgentHandler) ClaimSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var request models.ClaimSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid claim request"})
+		return
+	}
+
+	session, err := h.agentService.Claim(c.Request.Context(), sessionID, request.AgentID)
+	if err != nil {
+		h.assignmentError(c, err, "Failed to claim session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// ReleaseSession hands a conversation back to the bot or to the next available agent
+func (h *AgentHandler) ReleaseSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var request models.ReleaseSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid release request"})
+		return
+	}
+
+	session, err := h.agentService.Release(c.Request.Context(), sessionID, request.AgentID, request.ResumeBot)
+	if err != nil {
+		h.assignmentError(c, err, "Failed to release session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// AssignSession assigns an unassigned conversation to the next available agent
+func (h *AgentHandler) AssignSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.agentService.AutoAssign(c.Request.Context(), sessionID)
+	if err != nil {
+		h.assignmentError(c, err, "Failed to assign session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// assignmentError maps assignment errors to responses
+func (h *AgentHandler) assignmentError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	case errors.Is(err, services.ErrAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+	case errors.Is(err, services.ErrSessionAssigned), errors.Is(err, services.ErrSessionNotAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoAgentAvailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// Voice notes whose transcript is overdue are looked for this often, this many at a time
+const (
+	transcriptionSweepInterval = 15 * time.Second
+	transcriptionSweepBatch    = 100
+)
+
+// errTranscriptionTimeout is recorded on the analysis stage of voice notes forwarded
+// without a transcript
+var errTranscriptionTimeout = errors.New("transcription timed out")
+
+// HandleTranscription ingests a voice-note transcript posted by the AI processing service
+func (h *WhatsAppHandler) HandleTranscription(c *gin.Context) {
+	var result models.TranscriptionResult
+
+	if err := c.ShouldBindJSON(&result); err != nil {
+		h.logger.WithError(err).Error("Failed to parse transcription result")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transcription data"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"message_id":     result.MessageID,
+		"transcript_len": len(result.Transcript),
+		"duration_secs":  result.DurationSeconds,
+	}).Info("Received voice note transcription")
+
+	message, err := h.messageService.GetMessage(c.Request.Context(), result.MessageID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve transcribed message")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if message.Type != models.MessageTypeAudio {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Message is not a voice note"})
+		return
+	}
+
+	// The reported duration is authoritative when ffprobe could not measure the note
+	if result.DurationSeconds > 0 {
+		mediaType := ""
+		if message.MediaType != nil {
+			mediaType = *message.MediaType
+		}
+
+		duration := time.Duration(result.DurationSeconds * float64(time.Second))
+		if violation := h.mediaService.CheckVoiceNoteDuration(mediaType, duration); violation != nil {
+			// The empty transcript settles the note, so a repeated callback is not rejected twice
+			err = h.messageService.UpdateTranscript(c.Request.Context(), message.ID, "")
+			if errors.Is(err, services.ErrTranscriptStored) {
+				c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+				return
+			}
+			if err != nil {
+				h.logger.WithError(err).Error("Failed to settle rejected voice note")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store transcript"})
+				return
+			}
+			go h.rejectMedia(context.Background(), message, violation)
+			c.JSON(http.StatusOK, gin.H{"status": "rejected", "reason": violation.Reason})
+			return
+		}
+	}
+
+	err = h.forwardTranscript(c.Request.Context(), message, result.Transcript)
+	if errors.Is(err, services.ErrTranscriptStored) {
+		// A retried callback, or one that arrived after the note was forwarded without it
+		c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to store transcript")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store transcript"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "accepted"})
+}
+
+// forwardTranscript stores a voice note's transcript and forwards it to the orchestrator.
+// It returns services.ErrTranscriptStored without forwarding when the note was settled
+// already.
+func (h *WhatsAppHandler) forwardTranscript(ctx context.Context, message *models.WhatsAppMessage, transcript string) error {
+	if err := h.messageService.UpdateTranscript(ctx, message.ID, transcript); err != nil {
+		return err
+	}
+	message.Transcript = &transcript
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobCompleted, nil)
+
+	// Forward the transcript as if the user had typed it
+	forwarded := *message
+	if strings.TrimSpace(transcript) != "" {
+		forwarded.Type = models.MessageTypeText
+		forwarded.Content = transcript
+	}
+	h.enqueueForward(ctx, &forwarded)
+	return nil
+}
+
+// StartTranscriptionTimeouts forwards voice notes whose transcript has not arrived within
+// timeout without one, until ctx is done, so a lost callback does not leave the user
+// unanswered. Replicas can run it together: only the one that settles a note forwards it.
+func (h *WhatsAppHandler) StartTranscriptionTimeouts(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(transcriptionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.forwardOverdueVoiceNotes(ctx, timeout)
+		}
+	}
+}
+
+// forwardOverdueVoiceNotes forwards the voice notes sent for transcription more than
+// timeout ago that still have no transcript
+func (h *WhatsAppHandler) forwardOverdueVoiceNotes(ctx context.Context, timeout time.Duration) {
+	messages, err := h.messageService.AwaitingTranscript(ctx, timeout, transcriptionSweepBatch)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to look up overdue transcriptions")
+		return
+	}
+
+	for _, message := range messages {
+		// The empty transcript makes a late callback a duplicate
+		err := h.messageService.UpdateTranscript(ctx, message.ID, "")
+		if errors.Is(err, services.ErrTranscriptStored) {
+			continue
+		}
+		if err != nil {
+			h.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to settle overdue voice note")
+			continue
+		}
+
+		h.logger.WithField("message_id", message.ID).Warn("Transcription timed out; forwarding the voice note without a transcript")
+		h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobFailed, errTranscriptionTimeout)
+		h.enqueueForward(ctx, message)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AIResultHandler handles analysis result callbacks from the AI processing service
+type AIResultHandler struct {
+	aiResultService *services.AIResultService
+	messageService  *services.MessageService
+	logger          *logrus.Logger
+}
+
+// NewAIResultHandler creates a new AI result handler
+func NewAIResultHandler(aiResultService *services.AIResultService, messageService *services.MessageService, logger *logrus.Logger) *AIResultHandler {
+	return &AIResultHandler{
+		aiResultService: aiResultService,
+		messageService:  messageService,
+		logger:          logger,
+	}
+}
+
+// HandleResult stores an analysis result posted by the AI processing service
+func (h *AIResultHandler) HandleResult(c *gin.Context) {
+	var callback models.AIResultCallback
+
+	if err := c.ShouldBindJSON(&callback); err != nil {
+		h.logger.WithError(err).Error("Failed to parse AI result callback")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid AI result data"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"message_id":    callback.MessageID,
+		"analysis_type": callback.AnalysisType,
+		"status":        callback.Status,
+	}).Info("Received AI result callback")
+
+	message, err := h.messageService.GetMessage(c.Request.Context(), callback.MessageID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve message for AI result")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	result, err := h.aiResultService.StoreResult(c.Request.Context(), message, &callback)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store AI result"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetMessageResults returns all analysis results stored for a message
+func (h *AIResultHandler) GetMessageResults(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	results, err := h.aiResultService.GetResultsByMessage(c.Request.Context(), messageID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve AI results")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve AI results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message_id": messageID,
+		"results":    results,
+	})
+}
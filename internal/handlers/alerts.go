@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AlertHandler handles Twilio debugger alerts and their admin listing
+type AlertHandler struct {
+	alertService *services.AlertService
+	logger       *logrus.Logger
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(alertService *services.AlertService, logger *logrus.Logger) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+		logger:       logger,
+	}
+}
+
+// HandleTwilioAlert records an alert posted by Twilio's debugger webhook
+func (h *AlertHandler) HandleTwilioAlert(c *gin.Context) {
+	var webhook models.TwilioAlertWebhook
+
+	if err := c.ShouldBind(&webhook); err != nil || webhook.Sid == "" {
+		h.logger.WithError(err).Error("Failed to parse Twilio alert webhook")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert data"})
+		return
+	}
+
+	if _, err := h.alertService.RecordAlert(c.Request.Context(), &webhook); err != nil {
+		h.logger.WithError(err).WithField("alert_sid", webhook.Sid).Error("Failed to record Twilio alert")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record alert"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListAlerts returns stored Twilio alerts, newest first, filtered by level
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	alerts, total, err := h.alertService.ListAlerts(c.Request.Context(), c.Query("level"), page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list Twilio alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list alerts"})
+		return
+	}
+
+	respondPage(c, page, alerts, len(alerts), total)
+}
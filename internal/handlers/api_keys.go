@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/middleware"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// maxRotationGraceHours bounds grace_hours; longer overlaps should be a new key instead
+const maxRotationGraceHours = 30 * 24
+
+// APIKeyHandler handles the admin API for tenant API keys
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	config        *config.Config
+	logger        *logrus.Logger
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService, cfg *config.Config, logger *logrus.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// ListAPIKeys returns the keys of the tenant in ?tenant=, or of every tenant, without
+// their secrets
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.List(c.Request.Context(), c.Query("tenant"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey issues a key for a tenant; the response is the only place its secret appears
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var request models.CreateAPIKeyRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key request"})
+		return
+	}
+
+	// The admin role is reserved for admin tokens; keys only reach the API
+	if request.Role == "" {
+		request.Role = middleware.RoleOperator
+	}
+	if !middleware.ValidRole(request.Role) || request.Role == middleware.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be readonly, sender or operator"})
+		return
+	}
+
+	issued, err := h.apiKeyService.Create(c.Request.Context(), &request, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"api_key_id": issued.ID,
+		"tenant":     issued.Tenant,
+		"role":       issued.Role,
+		"admin":      c.GetString("admin_subject"),
+	}).Info("API key created")
+
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RotateAPIKey replaces a key with a new one; the old key stays valid for the grace period
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	var request models.RotateAPIKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rotation request"})
+			return
+		}
+	}
+
+	grace, ok := rotationGrace(&request, h.config.APIKeyRotationGrace)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("grace_hours must be between 0 and %d", maxRotationGraceHours)})
+		return
+	}
+
+	issued, err := h.apiKeyService.Rotate(c.Request.Context(), id, grace, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"api_key_id": issued.ID,
+		"rotated":    id,
+		"tenant":     issued.Tenant,
+		"grace":      grace,
+		"admin":      c.GetString("admin_subject"),
+	}).Info("API key rotated")
+
+	c.JSON(http.StatusCreated, issued)
+}
+
+// RevokeAPIKey disables a key immediately
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	key, err := h.apiKeyService.Revoke(c.Request.Context(), id, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"api_key_id": id,
+		"tenant":     key.Tenant,
+		"admin":      c.GetString("admin_subject"),
+	}).Info("API key revoked")
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps API key errors to HTTP responses
+func (h *APIKeyHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+	case errors.Is(err, services.ErrAPIKeyNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": "API key is revoked or expired"})
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("API key operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "API key operation failed"})
+	}
+}
+
+// rotationGrace returns the grace period a rotation asks for, or fallback when it names
+// none; ok is false for periods outside 0 to maxRotationGraceHours
+func rotationGrace(request *models.RotateAPIKeyRequest, fallback time.Duration) (time.Duration, bool) {
+	if request.GraceHours == nil {
+		return fallback, true
+	}
+	hours := *request.GraceHours
+	if !(hours >= 0 && hours <= maxRotationGraceHours) {
+		return 0, false
+	}
+	return time.Duration(hours * float64(time.Hour)), true
+}
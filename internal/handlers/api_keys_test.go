@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestRotationGrace(t *testing.T) {
+	hours := func(h float64) *float64 { return &h }
+
+	tests := []struct {
+		name   string
+		hours  *float64
+		want   time.Duration
+		wantOK bool
+	}{
+		{"default", nil, 24 * time.Hour, true},
+		{"retire at once", hours(0), 0, true},
+		{"fractional", hours(1.5), 90 * time.Minute, true},
+		{"maximum", hours(maxRotationGraceHours), maxRotationGraceHours * time.Hour, true},
+		{"negative", hours(-1), 0, false},
+		{"over the maximum", hours(maxRotationGraceHours + 1), 0, false},
+		{"overflowing", hours(1e300), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rotationGrace(&models.RotateAPIKeyRequest{GraceHours: tt.hours}, 24*time.Hour)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("rotationGrace() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRotateAPIKeyRejectsGrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewAPIKeyHandler(nil, &config.Config{APIKeyRotationGrace: 24 * time.Hour}, logrus.New())
+
+	for _, body := range []string{`{"grace_hours":-1}`, `{"grace_hours":721}`, `{"grace_hours":1e300}`} {
+		t.Run(body, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api-keys/:id/rotate", handler.RotateAPIKey)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api-keys/6f1c2b7e-2a4d-4f7e-9a51-0c8d7b3e1f20/rotate", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
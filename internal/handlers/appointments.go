@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AppointmentHandler serves the appointments proposed in conversations
+type AppointmentHandler struct {
+	appointmentService *services.AppointmentService
+	logger             *logrus.Logger
+}
+
+// NewAppointmentHandler creates a new appointment handler
+func NewAppointmentHandler(appointmentService *services.AppointmentService, logger *logrus.Logger) *AppointmentHandler {
+	return &AppointmentHandler{
+		appointmentService: appointmentService,
+		logger:             logger,
+	}
+}
+
+// GetAppointment returns an appointment and the user's answer
+func (h *AppointmentHandler) GetAppointment(c *gin.Context) {
+	appointmentID, err := uuid.Parse(c.Param("appointmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		return
+	}
+
+	appointment, err := h.appointmentService.Get(c.Request.Context(), appointmentID)
+	if err != nil {
+		if errors.Is(err, services.ErrAppointmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve appointment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appointment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// ListSessionAppointments returns the appointments proposed in a session
+func (h *AppointmentHandler) ListSessionAppointments(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	appointments, err := h.appointmentService.ListForSession(c.Request.Context(), sessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list session appointments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list appointments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"appointments": appointments})
+}
+
+// scheduleAppointment proposes the appointment of a schedule_appointment next action to
+// the sender of message, sending the confirmation template on the message's channel
+func (h *WhatsAppHandler) scheduleAppointment(ctx context.Context, message *models.WhatsAppMessage, response *services.ChatResponse) {
+	if h.appointments == nil {
+		return
+	}
+	logger := h.logger.WithField("message_id", message.ID)
+
+	intent, err := services.ParseIntent(response.Context)
+	if err != nil {
+		logger.WithError(err).Warn("Ignoring orchestrator appointment")
+		return
+	}
+
+	provider, err := h.channels.For(message.Channel)
+	if err != nil {
+		logger.WithError(err).Warn("No provider to send appointment confirmation")
+		return
+	}
+
+	appointment, send, err := h.appointments.Create(ctx, message, intent)
+	if errors.Is(err, services.ErrAppointmentExists) {
+		// An earlier delivery of the same response proposed it already
+		return
+	}
+	if err != nil {
+		logger.WithError(err).Warn("Failed to create appointment")
+		return
+	}
+
+	sent, err := h.deliver(ctx, provider, send, nil, "")
+	if err != nil {
+		if err := h.appointments.Failed(context.Background(), appointment); err != nil {
+			logger.WithError(err).Error("Failed to mark appointment failed")
+		}
+		return
+	}
+
+	if err := h.appointments.Sent(ctx, appointment, sent.ID); err != nil {
+		logger.WithError(err).WithField("appointment_id", appointment.ID).Warn("Failed to link appointment to its message")
+	}
+}
+
+// answerAppointment records the confirm or decline button a user tapped on an
+// appointment confirmation
+func (h *WhatsAppHandler) answerAppointment(ctx context.Context, message *models.WhatsAppMessage) {
+	if h.appointments == nil {
+		return
+	}
+	if _, err := h.appointments.HandleReply(ctx, message); err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to record appointment answer")
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AuditHandler exposes the audit log to administrators
+type AuditHandler struct {
+	auditService *services.AuditService
+	logger       *logrus.Logger
+}
+
+// NewAuditHandler creates a new audit log handler
+func NewAuditHandler(auditService *services.AuditService, logger *logrus.Logger) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// ListAuditLog returns audit entries, newest first, filtered by target_type, target_id
+// and an action prefix
+func (h *AuditHandler) ListAuditLog(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.List(c.Request.Context(), c.Query("target_type"), c.Query("target_id"), c.Query("action"), page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list audit log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit log"})
+		return
+	}
+
+	respondPage(c, page, entries, len(entries), total)
+}
+
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// AutomationHandler handles the admin API for inbound message automations
+type AutomationHandler struct {
+	automationService *services.AutomationService
+	logger            *logrus.Logger
+}
+
+// NewAutomationHandler creates a new automation handler
+func NewAutomationHandler(automationService *services.AutomationService, logger *logrus.Logger) *AutomationHandler {
+	return &AutomationHandler{
+		automationService: automationService,
+		logger:            logger,
+	}
+}
+
+// ListAutomations returns every automation in evaluation order
+func (h *AutomationHandler) ListAutomations(c *gin.Context) {
+	automations, err := h.automationService.List(c.Request.Context())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"automations": automations})
+}
+
+// CreateAutomation stores a new automation
+func (h *AutomationHandler) CreateAutomation(c *gin.Context) {
+	var request models.AutomationRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid automation"})
+		return
+	}
+
+	automation, err := h.automationService.Create(c.Request.Context(), &request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"automation": automation.Name,
+		"admin":      c.GetString("admin_subject"),
+		"client_ip":  c.ClientIP(),
+	}).Info("Automation created")
+
+	c.JSON(http.StatusCreated, automation)
+}
+
+// UpdateAutomation changes a automation
+func (h *AutomationHandler) UpdateAutomation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid automation ID"})
+		return
+	}
+
+	var request models.AutomationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid automation"})
+		return
+	}
+
+	automation, err := h.automationService.Update(c.Request.Context(), id, &request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"automation": automation.Name,
+		"admin":      c.GetString("admin_subject"),
+		"client_ip":  c.ClientIP(),
+	}).Info("Automation updated")
+
+	c.JSON(http.StatusOK, automation)
+}
+
+// DeleteAutomation removes a automation
+func (h *AutomationHandler) DeleteAutomation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid automation ID"})
+		return
+	}
+
+	if err := h.automationService.Delete(c.Request.Context(), id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"automation_id": id,
+		"admin":         c.GetString("admin_subject"),
+		"client_ip":     c.ClientIP(),
+	}).Info("Automation deleted")
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps automation errors to HTTP responses
+func (h *AutomationHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAutomationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Automation not found"})
+	case errors.Is(err, services.ErrInvalidAutomation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Automation operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Automation operation failed"})
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// BundleHandler handles the admin API for moving configuration between environments
+type BundleHandler struct {
+	bundleService *services.BundleService
+	logger        *logrus.Logger
+}
+
+// NewBundleHandler creates a new bundle handler
+func NewBundleHandler(bundleService *services.BundleService, logger *logrus.Logger) *BundleHandler {
+	return &BundleHandler{
+		bundleService: bundleService,
+		logger:        logger,
+	}
+}
+
+// ExportBundle returns the templates, automations and policies of this environment
+func (h *BundleHandler) ExportBundle(c *gin.Context) {
+	bundle, err := h.bundleService.Export(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export configuration bundle")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportBundle applies a bundle exported by another environment. ?dry_run=true only
+// lists the differences; ?prune=true also deletes automations missing from the bundle.
+func (h *BundleHandler) ImportBundle(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run"})
+		return
+	}
+	prune, err := strconv.ParseBool(c.DefaultQuery("prune", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prune"})
+		return
+	}
+
+	var bundle models.ConfigBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"environment": bundle.Environment,
+		"dry_run":     dryRun,
+		"prune":       prune,
+		"admin":       c.GetString("admin_subject"),
+		"client_ip":   c.ClientIP(),
+	}).Info("Importing configuration bundle via admin API")
+
+	result, err := h.bundleService.Import(c.Request.Context(), &bundle, dryRun, prune, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBundle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to import configuration bundle")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import bundle"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// frequencyCapDefaultPeriod is the period of the frequency cap report when no `from`
+// is given
+const frequencyCapDefaultPeriod = 7 * 24 * time.Hour
+
+// CampaignHandler manages broadcast campaigns, whose messages go out through the send
+// pipeline of the WhatsApp handler
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+	frequencyCaps   *services.FrequencyCapService
+	pipeline        *WhatsAppHandler
+	logger          *logrus.Logger
+}
+
+// NewCampaignHandler creates a new campaign handler
+func NewCampaignHandler(campaignService *services.CampaignService, frequencyCaps *services.FrequencyCapService, pipeline *WhatsAppHandler, logger *logrus.Logger) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		frequencyCaps:   frequencyCaps,
+		pipeline:        pipeline,
+		logger:          logger,
+	}
+}
+
+// CreateCampaign creates a draft campaign and snapshots its audience. The message is
+// checked against the channel like a batch send; recipients are checked as they are sent.
+// The campaign belongs to the tenant of the API key, whose frequency cap applies to it.
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var request models.CreateCampaignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	w := h.pipeline
+	var provider services.MessagingProvider
+	var err error
+	if request.Provider != "" {
+		provider, err = w.channels.Named(request.Channel, request.Provider)
+	} else {
+		provider, err = w.channels.For(request.Channel)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel not configured"})
+		return
+	}
+	probe := &models.SendMessageRequest{
+		Content:   request.Content,
+		Type:      request.Type,
+		MediaURL:  request.MediaURL,
+		Variables: request.Variables,
+		Template:  request.Template,
+	}
+	if len(request.Variants) == 0 {
+		if message := unsupportedSendType(provider, probe); message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": message})
+			return
+		}
+	}
+	// Without a template of its own, the campaign's variables fill the variants' templates
+	for i := 0; probe.Template == nil && i < len(request.Variants); i++ {
+		probe.Template = request.Variants[i].Template
+	}
+	if err := w.sanitizeVariables(probe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Variables = probe.Variables
+
+	// Each variant is checked as the message it turns the campaign's into
+	for i := range request.Variants {
+		variant := &request.Variants[i]
+		probe := &models.SendMessageRequest{
+			Content:   request.Content,
+			Type:      request.Type,
+			MediaURL:  request.MediaURL,
+			Variables: request.Variables,
+			Template:  request.Template,
+		}
+		variant.Apply(probe)
+		if message := unsupportedSendType(provider, probe); message != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variant %q: %s", variant.Name, message)})
+			return
+		}
+
+		own := &models.SendMessageRequest{Template: probe.Template, Variables: variant.Variables}
+		if err := w.sanitizeVariables(own); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("variant %q: %s", variant.Name, err.Error())})
+			return
+		}
+		variant.Variables = own.Variables
+	}
+
+	campaign, err := h.campaignService.Create(c.Request.Context(), &request, c.GetString("api_key_tenant"), c.GetString("admin_subject"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// ListCampaigns returns campaigns, newest first, optionally by `status`
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	campaigns, total, err := h.campaignService.List(c.Request.Context(), c.Query("status"), page.limit, page.offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	respondPage(c, page, campaigns, len(campaigns), total)
+}
+
+// GetCampaign returns a campaign
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.campaignService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// ScheduleCampaign schedules a draft campaign, at once without a `scheduled_at`
+func (h *CampaignHandler) ScheduleCampaign(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	var request models.ScheduleCampaignRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+			return
+		}
+	}
+
+	campaign, err := h.campaignService.Schedule(c.Request.Context(), id, &request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// PauseCampaign stops a scheduled or running campaign
+func (h *CampaignHandler) PauseCampaign(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.campaignService.Pause(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// ResumeCampaign continues a paused campaign
+func (h *CampaignHandler) ResumeCampaign(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.campaignService.Resume(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// GetReport returns the delivery and engagement funnel of a campaign
+func (h *CampaignHandler) GetReport(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.campaignService.Report(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ListRecipients returns the recipients of a campaign, optionally by `status`, with
+// the time each one deferred past its quiet hours is scheduled for
+func (h *CampaignHandler) ListRecipients(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	recipients, total, err := h.campaignService.Recipients(c.Request.Context(), id, c.Query("status"), page.limit, page.offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	respondPage(c, page, recipients, len(recipients), total)
+}
+
+// GetFrequencyCapReport sums the campaign messages counted against and refused by the
+// frequency cap between the RFC 3339 `from` and `to`, by default the last 7 days,
+// optionally of one `tenant`
+func (h *CampaignHandler) GetFrequencyCapReport(c *gin.Context) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-frequencyCapDefaultPeriod)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	var tenant *string
+	if value, ok := c.GetQuery("tenant"); ok {
+		tenant = &value
+	}
+
+	report, err := h.frequencyCaps.Report(c.Request.Context(), from, to, tenant)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// respondError maps campaign errors to HTTP responses
+func (h *CampaignHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCampaignNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Campaign not found"})
+	case errors.Is(err, services.ErrInvalidCampaign):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCampaignTransition):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Campaign operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Campaign operation failed"})
+	}
+}
+
+// parseCampaignID reads the campaign ID path parameter, answering 400 when it is invalid
+func parseCampaignID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("campaignId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid campaign ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// SendCampaignMessage sends one campaign message with the checks of an API send.
+// Suppressed recipients, duplicates and recipients over their notification caps are
+// skipped with services.ErrCampaignRecipientSkipped.
+func (h *WhatsAppHandler) SendCampaignMessage(ctx context.Context, request *models.SendMessageRequest) (*models.SendMessageResponse, error) {
+	var provider services.MessagingProvider
+	var err error
+	if request.Provider != "" {
+		provider, err = h.channels.Named(request.Channel, request.Provider)
+	} else {
+		provider, err = h.channels.For(request.Channel)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	suppression, err := h.suppressionService.Lookup(ctx, request.Channel, request.To)
+	if err != nil {
+		return nil, err
+	}
+	if suppression != nil {
+		return nil, fmt.Errorf("%w: recipient is suppressed", services.ErrCampaignRecipientSkipped)
+	}
+
+	dedupKey, err := h.dedupService.Claim(ctx, request)
+	if err != nil {
+		var duplicate *services.DuplicateSendError
+		if errors.As(err, &duplicate) {
+			return nil, fmt.Errorf("%w: duplicate of message %s", services.ErrCampaignRecipientSkipped, duplicate.PriorMessageID)
+		}
+	}
+
+	capClaim, err := h.notificationCaps.Claim(ctx, request)
+	if err != nil {
+		h.dedupService.Release(context.Background(), dedupKey)
+		var capped *services.NotificationCapError
+		if errors.As(err, &capped) {
+			return nil, fmt.Errorf("%w: %s", services.ErrCampaignRecipientSkipped, capped.Error())
+		}
+		return nil, err
+	}
+
+	// Links are shortened per recipient so clicks are attributed to their message
+	content, trackedLinks, err := h.linkService.ShortenLinks(ctx, request.Content)
+	if err != nil {
+		h.dedupService.Release(context.Background(), dedupKey)
+		h.notificationCaps.Release(context.Background(), capClaim)
+		return nil, err
+	}
+	request.Content = content
+
+	response, err := h.deliver(ctx, provider, request, trackedLinks, dedupKey)
+	if err != nil {
+		h.notificationCaps.Release(context.Background(), capClaim)
+	}
+	return response, err
+}
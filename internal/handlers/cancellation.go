@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// CancellationHandler handles cancellation of pending outbound messages
+type CancellationHandler struct {
+	cancellationService *services.CancellationService
+	logger              *logrus.Logger
+}
+
+// NewCancellationHandler creates a new cancellation handler
+func NewCancellationHandler(cancellationService *services.CancellationService, logger *logrus.Logger) *CancellationHandler {
+	return &CancellationHandler{
+		cancellationService: cancellationService,
+		logger:              logger,
+	}
+}
+
+// CancelMessage cancels an outbound message that has not been sent yet
+func (h *CancellationHandler) CancelMessage(c *gin.Context) {
+	messageID := c.Param("messageId")
+	if _, err := uuid.Parse(messageID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	message, err := h.cancellationService.Cancel(c.Request.Context(), messageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMessageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		case errors.Is(err, services.ErrMessageNotCancelable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Message can no longer be canceled"})
+		default:
+			h.logger.WithError(err).WithField("message_id", messageID).Error("Failed to cancel message")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel message"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// ChaosHandler handles the admin API for fault injection
+type ChaosHandler struct {
+	faultInjector *services.FaultInjector
+	logger        *logrus.Logger
+}
+
+// NewChaosHandler creates a new fault injection handler
+func NewChaosHandler(faultInjector *services.FaultInjector, logger *logrus.Logger) *ChaosHandler {
+	return &ChaosHandler{
+		faultInjector: faultInjector,
+		logger:        logger,
+	}
+}
+
+// GetFaults returns the current fault configuration
+func (h *ChaosHandler) GetFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, h.faultInjector.Config())
+}
+
+// UpdateFaults replaces the fault configuration
+func (h *ChaosHandler) UpdateFaults(c *gin.Context) {
+	var request models.FaultConfig
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fault config"})
+		return
+	}
+
+	if err := h.faultInjector.Configure(request); err != nil {
+		switch {
+		case errors.Is(err, services.ErrFaultInjectionUnavailable):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Fault injection is not available in production"})
+		case errors.Is(err, services.ErrInvalidFaultConfig):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to configure faults"})
+		}
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"enabled":   request.Enabled,
+		"admin":     c.GetString("admin_subject"),
+		"client_ip": c.ClientIP(),
+	}).Warn("Fault injection updated")
+
+	c.JSON(http.StatusOK, h.faultInjector.Config())
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// CRMExportHandler exposes the CRM export delivery log to admins
+type CRMExportHandler struct {
+	crmExportService *services.CRMExportService
+	logger           *logrus.Logger
+}
+
+// NewCRMExportHandler creates a new CRM export handler
+func NewCRMExportHandler(crmExportService *services.CRMExportService, logger *logrus.Logger) *CRMExportHandler {
+	return &CRMExportHandler{
+		crmExportService: crmExportService,
+		logger:           logger,
+	}
+}
+
+// ListExports returns CRM exports, newest first, filtered by status
+func (h *CRMExportHandler) ListExports(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	exports, total, err := h.crmExportService.List(c.Request.Context(), c.Query("status"), page.limit, page.offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	respondPage(c, page, exports, len(exports), total)
+}
+
+// GetExport returns one CRM export with its delivery attempts
+func (h *CRMExportHandler) GetExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	export, err := h.crmExportService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// RetryExport redelivers a failed CRM export
+func (h *CRMExportHandler) RetryExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	if !h.crmExportService.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "CRM export is disabled"})
+		return
+	}
+
+	export, err := h.crmExportService.Retry(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"export_id": id,
+		"status":    export.Status,
+		"admin":     c.GetString("admin_subject"),
+		"client_ip": c.ClientIP(),
+	}).Info("CRM export retried")
+
+	c.JSON(http.StatusOK, export)
+}
+
+// respondError maps CRM export errors to HTTP responses
+func (h *CRMExportHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCRMExportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "CRM export not found"})
+	case errors.Is(err, services.ErrCRMExportNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("CRM export operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "CRM export operation failed"})
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// csatDefaultPeriod is the period reported when no `from` is given
+const csatDefaultPeriod = 30 * 24 * time.Hour
+
+// CSATHandler exposes customer satisfaction analytics
+type CSATHandler struct {
+	csatService *services.CSATService
+	logger      *logrus.Logger
+}
+
+// NewCSATHandler creates a new CSAT handler
+func NewCSATHandler(csatService *services.CSATService, logger *logrus.Logger) *CSATHandler {
+	return &CSATHandler{
+		csatService: csatService,
+		logger:      logger,
+	}
+}
+
+// GetStats aggregates the surveys sent between the RFC 3339 `from` and `to`, by default
+// the last 30 days, optionally on one `channel`
+func (h *CSATHandler) GetStats(c *gin.Context) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+			return
+		}
+		to = parsed
+	}
+	from := to.Add(-csatDefaultPeriod)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	stats, err := h.csatService.Stats(c.Request.Context(), from, to, models.Channel(c.Query("channel")))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compute CSAT")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute CSAT"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// DeadLetterHandler exposes dead-lettered inbound payloads to admins
+type DeadLetterHandler struct {
+	deadLetterService *services.DeadLetterService
+	logger            *logrus.Logger
+}
+
+// NewDeadLetterHandler creates a new dead letter handler
+func NewDeadLetterHandler(deadLetterService *services.DeadLetterService, logger *logrus.Logger) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		deadLetterService: deadLetterService,
+		logger:            logger,
+	}
+}
+
+// ListDeadLetters returns dead letters, newest first, filtered by source
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	deadLetters, total, err := h.deadLetterService.List(c.Request.Context(), c.Query("source"), page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead letters"})
+		return
+	}
+
+	respondPage(c, page, deadLetters, len(deadLetters), total)
+}
+
+// GetDeadLetter returns one dead letter with its payload and stack trace
+func (h *DeadLetterHandler) GetDeadLetter(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dead letter ID"})
+		return
+	}
+
+	deadLetter, err := h.deadLetterService.Get(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve dead letter")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve dead letter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
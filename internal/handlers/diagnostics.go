@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DiagnosticsHandler exposes runtime state and profiles for diagnosing stalls
+type DiagnosticsHandler struct {
+	startedAt time.Time
+	logger    *logrus.Logger
+}
+
+// NewDiagnosticsHandler creates a new diagnostics handler
+func NewDiagnosticsHandler(logger *logrus.Logger) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		startedAt: time.Now(),
+		logger:    logger,
+	}
+}
+
+// Runtime returns goroutine, memory and GC statistics
+func (h *DiagnosticsHandler) Runtime(c *gin.Context) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	var lastGC *time.Time
+	if stats.LastGC > 0 {
+		at := time.Unix(0, int64(stats.LastGC)).UTC()
+		lastGC = &at
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+		"go_version":     runtime.Version(),
+		"goroutines":     runtime.NumGoroutine(),
+		"num_cpu":        runtime.NumCPU(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"memory": gin.H{
+			"heap_alloc_bytes": stats.HeapAlloc,
+			"heap_inuse_bytes": stats.HeapInuse,
+			"heap_objects":     stats.HeapObjects,
+			"stack_inuse":      stats.StackInuse,
+			"sys_bytes":        stats.Sys,
+		},
+		"gc": gin.H{
+			"cycles":          stats.NumGC,
+			"pause_total_ms":  float64(stats.PauseTotalNs) / 1e6,
+			"last_pause_ms":   float64(stats.PauseNs[(stats.NumGC+255)%256]) / 1e6,
+			"last_gc":         lastGC,
+			"next_gc_bytes":   stats.NextGC,
+			"gc_cpu_fraction": stats.GCCPUFraction,
+		},
+	})
+}
+
+// GoroutineDump writes the stack of every goroutine as text. debug=2 (the default)
+// prints each goroutine with its state and wait time; debug=1 groups identical stacks.
+func (h *DiagnosticsHandler) GoroutineDump(c *gin.Context) {
+	debug, err := strconv.Atoi(c.DefaultQuery("debug", "2"))
+	if err != nil || debug < 1 || debug > 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid debug level"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"goroutines": runtime.NumGoroutine(),
+		"admin":      c.GetString("admin_subject"),
+	}).Info("Goroutine dump requested")
+
+	c.Header("Content-Type", "text/plain; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := runtimepprof.Lookup("goroutine").WriteTo(c.Writer, debug); err != nil {
+		h.logger.WithError(err).Error("Failed to write goroutine dump")
+	}
+}
+
+// RegisterProfiling adds the net/http/pprof endpoints under /debug/pprof
+func RegisterProfiling(router gin.IRouter) {
+	group := router.Group("/debug/pprof")
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+	group.GET("/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+}
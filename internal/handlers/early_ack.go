@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/middleware"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// webhooksQueuedTotal counts early-acknowledged webhooks by route and outcome
+var webhooksQueuedTotal = metrics.NewCounter("webhooks_queued_total", "Early-acknowledged Twilio webhooks by route and outcome", "route", "outcome")
+
+// AcknowledgeEarly wraps the handler of a Twilio webhook route: the raw request is stored
+// in the outbox and answered right away, and parsing and processing happen when the entry
+// is delivered. A webhook that cannot be stored is passed to inline instead.
+func (h *WhatsAppHandler) AcknowledgeEarly(route string, inline gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := middleware.RawBody(c)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to read webhook body")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+			return
+		}
+
+		entry, err := services.NewOutboxEntry(models.OutboxKindTwilioWebhook, nil, &models.QueuedWebhook{
+			Route:            route,
+			ContentType:      c.ContentType(),
+			Body:             string(body),
+			IdempotencyToken: c.GetHeader("I-Twilio-Idempotency-Token"),
+			ReceivedAt:       time.Now(),
+			WebhookEventID:   middleware.WebhookEventID(c.Request.Context()),
+		})
+		if err == nil {
+			err = h.outbox.Enqueue(c.Request.Context(), entry)
+		}
+		if err != nil {
+			webhooksQueuedTotal.Inc(route, "inline")
+			h.logger.WithError(err).WithField("route", route).Warn("Failed to queue webhook, processing it inline")
+			inline(c)
+			return
+		}
+
+		webhooksQueuedTotal.Inc(route, "queued")
+		c.Status(http.StatusOK)
+	}
+}
+
+// DeliverWebhook processes a webhook acknowledged by AcknowledgeEarly; it is the outbox
+// handler for twilio_webhook entries
+func (h *WhatsAppHandler) DeliverWebhook(ctx context.Context, entry *models.OutboxEntry) error {
+	var webhook models.QueuedWebhook
+	if err := json.Unmarshal(entry.Payload, &webhook); err != nil {
+		return fmt.Errorf("failed to decode queued webhook: %w", err)
+	}
+	ctx = middleware.WithWebhookEventID(ctx, webhook.WebhookEventID)
+
+	bind := func(obj interface{}) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", strings.NewReader(webhook.Body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", webhook.ContentType)
+		if err := binding.Default(http.MethodPost, webhook.ContentType).Bind(req, obj); err != nil {
+			return fmt.Errorf("failed to parse queued %s webhook: %w", webhook.Route, err)
+		}
+		return nil
+	}
+
+	switch webhook.Route {
+	case models.WebhookRouteMessage:
+		var webhookData models.TwilioWebhookRequest
+		if err := bind(&webhookData); err != nil {
+			return err
+		}
+		return h.ingestWebhook(ctx, &webhookData)
+
+	case models.WebhookRouteStatus:
+		var webhookData models.TwilioWebhookRequest
+		if err := bind(&webhookData); err != nil {
+			return err
+		}
+		return h.updateStatus(ctx, &webhookData, webhook.IdempotencyToken)
+
+	case models.WebhookRouteConversation:
+		var event models.ConversationsWebhookRequest
+		if err := bind(&event); err != nil {
+			return err
+		}
+		if event.EventType == models.ConversationEventMessageRemoved {
+			return h.revokeMessage(ctx, event.MessageSid)
+		}
+		webhookData := h.whatsappService.ConversationEventToWebhook(&event)
+		switch {
+		case webhookData == nil:
+			return nil
+		case event.EventType == models.ConversationEventDeliveryUpdated:
+			return h.updateStatus(ctx, webhookData, webhook.IdempotencyToken)
+		default:
+			return h.ingestWebhook(ctx, webhookData)
+		}
+	}
+
+	return fmt.Errorf("unknown webhook route %q", webhook.Route)
+}
+
+// ingestWebhook converts a Twilio message webhook and runs it through the pipeline. A
+// webhook that panics while being converted is dead-lettered and acknowledged.
+func (h *WhatsAppHandler) ingestWebhook(ctx context.Context, webhookData *models.TwilioWebhookRequest) error {
+	var message *models.WhatsAppMessage
+	var err error
+	if !h.isolate("twilio", models.IngestStageParse, nil, webhookData, func() {
+		message, err = h.whatsappService.ProcessIncomingMessage(webhookData)
+	}) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to process incoming message: %w", err)
+	}
+
+	return h.ingest(ctx, message, webhookData.ProfileName, webhookData.WaId)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// EmailHandler turns inbound emails into conversation messages on the shared pipeline
+type EmailHandler struct {
+	pipeline     *WhatsAppHandler
+	emailService *services.EmailService
+	logger       *logrus.Logger
+}
+
+// NewEmailHandler creates a new email handler
+func NewEmailHandler(pipeline *WhatsAppHandler, emailService *services.EmailService, logger *logrus.Logger) *EmailHandler {
+	return &EmailHandler{
+		pipeline:     pipeline,
+		emailService: emailService,
+		logger:       logger,
+	}
+}
+
+// HandleSendGrid processes a SendGrid Inbound Parse post, in either parsed or raw mode
+func (h *EmailHandler) HandleSendGrid(c *gin.Context) {
+	var email *models.InboundEmail
+
+	if raw := c.PostForm("email"); raw != "" {
+		parsed, err := h.emailService.ParseRawEmail([]byte(raw))
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to parse raw inbound email")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+			return
+		}
+		email = parsed
+	} else {
+		email = h.emailService.ParseParsedFields(
+			c.PostForm("from"),
+			c.PostForm("to"),
+			c.PostForm("subject"),
+			c.PostForm("text"),
+			c.PostForm("headers"),
+		)
+	}
+
+	// SendGrid posts the outcome of its SPF and DKIM checks in both modes
+	if err := h.emailService.CheckSendGridVerdicts(c.PostForm("SPF"), c.PostForm("dkim"), email); err != nil {
+		h.dropEmail(c, email, err)
+		return
+	}
+
+	h.ingestEmail(c, email)
+}
+
+// HandleSES processes SNS deliveries of SES receipt notifications
+func (h *EmailHandler) HandleSES(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+
+	// SNS posts JSON with a text/plain content type
+	var notification models.SNSNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		h.logger.WithError(err).Error("Failed to parse SNS notification")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+
+	if err := h.emailService.VerifySNSMessage(c.Request.Context(), &notification); err != nil {
+		if errors.Is(err, services.ErrInvalidSNSSignature) {
+			h.logger.WithError(err).WithField("topic_arn", notification.TopicArn).Warn("Rejected SNS post")
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid signature"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to verify SNS signature")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Signature verification unavailable"})
+		return
+	}
+
+	switch notification.Type {
+	case "SubscriptionConfirmation":
+		if err := h.emailService.ConfirmSNSSubscription(c.Request.Context(), notification.SubscribeURL); err != nil {
+			h.logger.WithError(err).Error("Failed to confirm SNS subscription")
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Subscription confirmation failed"})
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	case "Notification":
+	default:
+		c.Status(http.StatusOK)
+		return
+	}
+
+	var receipt models.SESReceiptNotification
+	if err := json.Unmarshal([]byte(notification.Message), &receipt); err != nil {
+		h.logger.WithError(err).Error("Failed to parse SES notification")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+
+	if receipt.Content == "" {
+		// Receipt rules that store the email in S3 do not include it in the notification
+		h.logger.WithField("ses_message_id", receipt.Mail.MessageID).Warn("SES notification without email content, configure the SNS action")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	email, err := h.emailService.ParseRawEmail([]byte(receipt.Content))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to parse SES email")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		return
+	}
+
+	if err := h.emailService.CheckSESVerdicts(&receipt); err != nil {
+		h.dropEmail(c, email, err)
+		return
+	}
+
+	h.ingestEmail(c, email)
+}
+
+// dropEmail answers 200 for an email that is not ingested, so the sender does not retry it
+func (h *EmailHandler) dropEmail(c *gin.Context, email *models.InboundEmail, err error) {
+	h.logger.WithError(err).WithFields(logrus.Fields{
+		"from":       email.From,
+		"message_id": email.MessageID,
+	}).Warn("Dropping unauthenticated inbound email")
+	c.Status(http.StatusOK)
+}
+
+// ingestEmail converts an email into a message and runs it through the pipeline
+func (h *EmailHandler) ingestEmail(c *gin.Context, email *models.InboundEmail) {
+	message, err := h.emailService.ToMessage(email)
+	if err != nil {
+		h.logger.WithError(err).Warn("Dropping inbound email")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"from":       message.From,
+		"subject":    email.Subject,
+	}).Info("Received inbound email")
+
+	if err := h.pipeline.ingest(c.Request.Context(), message, email.FromName, ""); err != nil {
+		// The sender retries; an email without a Message-ID is stored again under a new one
+		h.logger.WithError(err).Error("Failed to ingest inbound email")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process email"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
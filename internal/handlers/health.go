@@ -9,13 +9,20 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	db     *pgxpool.Pool
-	redis  *redis.Client
-	logger *logrus.Logger
+	db      *pgxpool.Pool
+	redis   *redis.Client
+	startup *services.StartupChecker
+	logger  *logrus.Logger
+
+	// Requested through the egress proxy by readiness checks; empty skips the check
+	egressProxyHealthURL string
 }
 
 // NewHealthHandler creates a new health handler
@@ -27,6 +34,16 @@ func NewHealthHandler(db *pgxpool.Pool, redisClient *redis.Client, logger *logru
 	}
 }
 
+// UseStartupChecks reports the outcome of the startup compatibility checks in readiness
+func (h *HealthHandler) UseStartupChecks(startup *services.StartupChecker) {
+	h.startup = startup
+}
+
+// UseEgressProxyCheck requests healthURL through the egress proxy in readiness checks
+func (h *HealthHandler) UseEgressProxyCheck(healthURL string) {
+	h.egressProxyHealthURL = healthURL
+}
+
 // Health performs a basic health check
 func (h *HealthHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -88,6 +105,32 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 		}
 	}
 
+	// An unreachable egress proxy only affects the AI and media download clients, so
+	// the service stays ready but degraded
+	if h.egressProxyHealthURL != "" {
+		if err := services.CheckEgressProxy(ctx, h.egressProxyHealthURL); err != nil {
+			h.logger.WithError(err).Error("Egress proxy health check failed")
+			checks["egress_proxy"] = map[string]interface{}{
+				"status": "unhealthy",
+				"error":  err.Error(),
+			}
+			if statusCode == http.StatusOK {
+				status = "degraded"
+			}
+		} else {
+			checks["egress_proxy"] = map[string]interface{}{
+				"status": "healthy",
+			}
+		}
+	}
+	// Failed startup checks leave the service serving in a degraded state
+	if h.startup != nil && len(h.startup.Results()) > 0 {
+		checks["startup"] = h.startup.Results()
+		if h.startup.Degraded() && statusCode == http.StatusOK {
+			status = "degraded"
+		}
+	}
+
 	c.JSON(statusCode, gin.H{
 		"status":    status,
 		"timestamp": time.Now().UTC(),
@@ -100,7 +143,8 @@ func (h *HealthHandler) Ready(c *gin.Context) {
 // PrometheusHandler returns a handler for Prometheus metrics
 func PrometheusHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: Implement Prometheus metrics
-		c.String(http.StatusOK, "# Prometheus metrics endpoint\n# TODO: Implement metrics collection\n")
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		metrics.Default.WriteText(c.Writer)
 	}
 }
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// ImportHandler imports historical conversations for admins
+type ImportHandler struct {
+	importService *services.ImportService
+	logger        *logrus.Logger
+}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler(importService *services.ImportService, logger *logrus.Logger) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+		logger:        logger,
+	}
+}
+
+// Import stores the historical messages of the request body. JSONL and CSV bodies are
+// imported directly; a JSON body points at an object in S3. ?dry_run=true only
+// validates the records.
+func (h *ImportHandler) Import(c *gin.Context) {
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dry_run"})
+		return
+	}
+
+	logger := h.logger.WithFields(logrus.Fields{
+		"content_type": c.ContentType(),
+		"dry_run":      dryRun,
+		"admin":        c.GetString("admin_subject"),
+		"client_ip":    c.ClientIP(),
+	})
+
+	ctx := c.Request.Context()
+	var result *models.ImportResult
+	switch c.ContentType() {
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		logger.Info("Importing historical messages via admin API")
+		result, err = h.importService.Import(ctx, c.Request.Body, models.ImportFormatJSONL, dryRun, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+
+	case "text/csv":
+		logger.Info("Importing historical messages via admin API")
+		result, err = h.importService.Import(ctx, c.Request.Body, models.ImportFormatCSV, dryRun, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+
+	case "application/json":
+		var request models.ImportS3Request
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import request"})
+			return
+		}
+		logger.WithField("s3_uri", request.S3URI).Info("Importing historical messages from S3 via admin API")
+		result, err = h.importService.ImportFromS3(ctx, &request, dryRun, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/x-ndjson, text/csv or application/json"})
+		return
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidImport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImportTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImportSourceNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to import historical messages")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import messages"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
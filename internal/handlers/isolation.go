@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// isolate runs one stage of the inbound pipeline and recovers from a panic in it. The
+// payload is dead-lettered with the stack trace and false is returned, so the webhook
+// is still acknowledged instead of being retried into the same bug.
+func (h *WhatsAppHandler) isolate(source, stage string, messageID *uuid.UUID, payload interface{}, run func()) (ok bool) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		ok = false
+		stack := debug.Stack()
+
+		h.logger.WithFields(logrus.Fields{
+			"source":     source,
+			"stage":      stage,
+			"message_id": messageID,
+			"panic":      recovered,
+		}).Error("Inbound pipeline stage panicked")
+
+		if h.deadLetters != nil {
+			h.deadLetters.Record(source, stage, messageID, payload, recovered, stack)
+		}
+	}()
+
+	run()
+	return true
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// LinkHandler handles short link redirects and click analytics
+type LinkHandler struct {
+	linkService *services.LinkService
+	logger      *logrus.Logger
+}
+
+// NewLinkHandler creates a new link handler
+func NewLinkHandler(linkService *services.LinkService, logger *logrus.Logger) *LinkHandler {
+	return &LinkHandler{
+		linkService: linkService,
+		logger:      logger,
+	}
+}
+
+// Redirect records a click on a short link and redirects to the original URL
+func (h *LinkHandler) Redirect(c *gin.Context) {
+	code := c.Param("code")
+
+	click := &models.LinkClick{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Referer:   c.Request.Referer(),
+	}
+
+	originalURL, err := h.linkService.RecordClick(c.Request.Context(), code, click)
+	if err != nil {
+		if errors.Is(err, services.ErrLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+			return
+		}
+		h.logger.WithError(err).WithField("code", code).Error("Failed to resolve short link")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve link"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, originalURL)
+}
+
+// GetMessageClicks returns click analytics for the links sent in a message
+func (h *LinkHandler) GetMessageClicks(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	stats, err := h.linkService.GetMessageClickStats(c.Request.Context(), messageID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve message click stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve click stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// MediaHandler serves message media and the registry of media stored in our bucket
+type MediaHandler struct {
+	messageService *services.MessageService
+	mediaService   *services.MediaService
+	mediaJobs      *services.MediaJobService
+	logger         *logrus.Logger
+}
+
+// NewMediaHandler creates a new media handler
+func NewMediaHandler(messageService *services.MessageService, mediaService *services.MediaService, mediaJobs *services.MediaJobService, logger *logrus.Logger) *MediaHandler {
+	return &MediaHandler{
+		messageService: messageService,
+		mediaService:   mediaService,
+		mediaJobs:      mediaJobs,
+		logger:         logger,
+	}
+}
+
+// GetMessageMedia streams the media of a message, so services that only receive a
+// media ID never need our Twilio credentials
+func (h *MediaHandler) GetMessageMedia(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	message, err := h.messageService.GetMessage(c.Request.Context(), messageID.String())
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve message")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve message"})
+		return
+	}
+	if message.MediaURL == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message has no media"})
+		return
+	}
+
+	body, contentType, err := h.mediaService.OpenMedia(c.Request.Context(), *message.MediaURL)
+	if err != nil {
+		if errors.Is(err, services.ErrMediaUnavailable) {
+			c.JSON(http.StatusGone, gin.H{"error": "Media is no longer available"})
+			return
+		}
+		if errors.Is(err, services.ErrMediaHostNotAllowed) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Media is hosted elsewhere and is not fetched"})
+			return
+		}
+		h.logger.WithError(err).WithField("message_id", messageID).Warn("Failed to fetch message media")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch media"})
+		return
+	}
+	defer body.Close()
+
+	if message.MediaType != nil && *message.MediaType != "" {
+		contentType = *message.MediaType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "private, no-store")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		h.logger.WithError(err).WithField("message_id", messageID).Warn("Failed to stream message media")
+	}
+}
+
+// ListMedia lists stored media, newest first, optionally by `message_id` or `session_id`
+func (h *MediaHandler) ListMedia(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	var filter models.MediaFilter
+	if value := c.Query("message_id"); value != "" {
+		messageID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+			return
+		}
+		filter.MessageID = &messageID
+	}
+	if value := c.Query("session_id"); value != "" {
+		sessionID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+			return
+		}
+		filter.SessionID = &sessionID
+	}
+
+	objects, total, err := h.mediaService.ListMedia(c.Request.Context(), filter, page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list media")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list media"})
+		return
+	}
+
+	respondPage(c, page, objects, len(objects), total)
+}
+
+// GetMedia returns a stored media object
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	mediaID, err := uuid.Parse(c.Param("mediaId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media ID"})
+		return
+	}
+
+	object, err := h.mediaService.GetStoredMedia(c.Request.Context(), mediaID)
+	if err != nil {
+		h.respondError(c, err, "Failed to retrieve media")
+		return
+	}
+
+	c.JSON(http.StatusOK, object)
+}
+
+// GetMediaInfo inspects a stored media object: size, type, ETag, and the dimensions and
+// duration read from its content
+func (h *MediaHandler) GetMediaInfo(c *gin.Context) {
+	mediaID, err := uuid.Parse(c.Param("mediaId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media ID"})
+		return
+	}
+
+	object, err := h.mediaService.GetStoredMedia(c.Request.Context(), mediaID)
+	if err != nil {
+		h.respondError(c, err, "Failed to retrieve media")
+		return
+	}
+
+	info, err := h.mediaService.GetMediaInfo(c.Request.Context(), object.URL)
+	if err != nil {
+		h.logger.WithError(err).WithField("media_id", mediaID).Warn("Failed to inspect media")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to inspect media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// GetMessageMediaInfo inspects the media of a message hosted by Twilio or in our bucket
+func (h *MediaHandler) GetMessageMediaInfo(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	message, err := h.messageService.GetMessage(c.Request.Context(), messageID.String())
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve message")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve message"})
+		return
+	}
+	if message.MediaURL == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message has no media"})
+		return
+	}
+
+	info, err := h.mediaService.GetMediaInfo(c.Request.Context(), *message.MediaURL)
+	if err != nil {
+		if errors.Is(err, services.ErrMediaUnavailable) {
+			c.JSON(http.StatusGone, gin.H{"error": "Media is no longer available"})
+			return
+		}
+		if errors.Is(err, services.ErrMediaHostNotAllowed) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Media is hosted elsewhere and is not fetched"})
+			return
+		}
+		h.logger.WithError(err).WithField("message_id", messageID).Warn("Failed to inspect message media")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to inspect media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// PresignUpload returns a presigned URL for uploading a file directly to the media
+// bucket, keeping large bodies off the adapter
+func (h *MediaHandler) PresignUpload(c *gin.Context) {
+	var request models.MediaPresignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	upload, err := h.mediaService.PresignUpload(c.Request.Context(), &request)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMediaUploadRejected):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrMediaStorageDisabled):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Media storage is not configured"})
+		default:
+			h.logger.WithError(err).Error("Failed to presign media upload")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to presign media upload"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// GetMediaStatus reports the pipeline state of a message's attachments, so the
+// orchestrator can wait for processing before asking the user to resend
+func (h *MediaHandler) GetMediaStatus(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	message, err := h.messageService.GetMessage(c.Request.Context(), messageID.String())
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve message")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve message"})
+		return
+	}
+
+	status, err := h.mediaJobs.Status(c.Request.Context(), message)
+	if err != nil {
+		h.logger.WithError(err).WithField("message_id", messageID).Error("Failed to retrieve media status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve media status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// DeleteMedia deletes a stored media object from the bucket and the registry, and
+// removes it from the messages that carried it
+func (h *MediaHandler) DeleteMedia(c *gin.Context) {
+	mediaID, err := uuid.Parse(c.Param("mediaId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media ID"})
+		return
+	}
+
+	messageIDs, err := h.mediaService.DeleteStoredMedia(c.Request.Context(), mediaID)
+	if err != nil {
+		h.respondError(c, err, "Failed to delete media")
+		return
+	}
+
+	for _, messageID := range messageIDs {
+		h.messageService.InvalidateCache(c.Request.Context(), messageID)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps media errors to HTTP responses
+func (h *MediaHandler) respondError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrMediaNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+	h.logger.WithError(err).Error(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// MetadataHandler lets the orchestrator attach structured data to messages and sessions
+type MetadataHandler struct {
+	messageService *services.MessageService
+	sessionService *services.SessionService
+	logger         *logrus.Logger
+}
+
+// NewMetadataHandler creates a new metadata handler
+func NewMetadataHandler(messageService *services.MessageService, sessionService *services.SessionService, logger *logrus.Logger) *MetadataHandler {
+	return &MetadataHandler{
+		messageService: messageService,
+		sessionService: sessionService,
+		logger:         logger,
+	}
+}
+
+// UpdateMessageMetadata merges a patch into a message's metadata
+func (h *MetadataHandler) UpdateMessageMetadata(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	var patch models.MetadataPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata patch"})
+		return
+	}
+
+	metadata, err := h.messageService.UpdateMetadata(c.Request.Context(), messageID, patch)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message_id": messageID, "metadata": metadata})
+}
+
+// UpdateSessionMetadata merges a patch into a session's metadata
+func (h *MetadataHandler) UpdateSessionMetadata(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var patch models.MetadataPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata patch"})
+		return
+	}
+
+	metadata, err := h.sessionService.UpdateMetadata(c.Request.Context(), sessionID, patch)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID, "metadata": metadata})
+}
+
+// respondError maps metadata errors to HTTP responses
+func (h *MetadataHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMetadata):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	default:
+		h.logger.WithError(err).Error("Failed to update metadata")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update metadata"})
+	}
+}
+
+// parseMetadataFilter reads the optional metadata query parameter, a JSON object that
+// results' metadata must contain
+func parseMetadataFilter(c *gin.Context) (models.Metadata, error) {
+	raw := c.Query("metadata")
+	if raw == "" {
+		return models.Metadata{}, nil
+	}
+
+	var filter models.Metadata
+	if err := json.Unmarshal([]byte(raw), &filter); err != nil || filter == nil {
+		return nil, errors.New("metadata filter must be a JSON object")
+	}
+	return filter, nil
+}
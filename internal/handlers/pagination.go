@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// cursorPrefix marks an offset cursor; cursors are opaque to clients so that pages can
+// later move to keyset positions without breaking them
+const cursorPrefix = "offset:"
+
+// pageRequest is the position and shape of a requested list page
+type pageRequest struct {
+	limit  int
+	offset int
+	fields []string // item fields to keep; all when empty
+}
+
+// parsePage reads ?limit=, ?cursor= (or the older ?offset=) and ?fields= of a list
+// request. It answers 400 and returns false when one is invalid.
+func parsePage(c *gin.Context) (pageRequest, bool) {
+	var page pageRequest
+	var err error
+
+	page.limit, err = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || page.limit <= 0 || page.limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return page, false
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		page.offset, err = decodeCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return page, false
+		}
+	} else {
+		page.offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil || page.offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return page, false
+		}
+	}
+
+	for _, field := range strings.Split(c.Query("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			page.fields = append(page.fields, field)
+		}
+	}
+
+	return page, true
+}
+
+// respondPage writes count items of a list page in the models.Page envelope, keeping
+// only the requested fields of each item. total is the estimated number of matching
+// items, negative when unknown.
+func respondPage(c *gin.Context, page pageRequest, items interface{}, count int, total int64) {
+	envelope := models.Page{Data: items}
+
+	if count >= page.limit {
+		cursor := encodeCursor(page.offset + count)
+		envelope.NextCursor = &cursor
+		// The planner may guess fewer rows than we have already seen
+		if total >= 0 {
+			if seen := int64(page.offset + count); total <= seen {
+				total = seen + 1
+			}
+			envelope.TotalEstimate = &total
+		}
+	} else if count > 0 || page.offset == 0 {
+		exact := int64(page.offset + count)
+		envelope.TotalEstimate = &exact
+	}
+
+	if len(page.fields) > 0 {
+		data, err := selectFields(items, page.fields)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+			return
+		}
+		envelope.Data = data
+	}
+
+	jsonWithETag(c, envelope)
+}
+
+// selectFields keeps only the named top-level JSON fields of each item in items
+func selectFields(items interface{}, fields []string) ([]map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &objects); err != nil {
+		return nil, err
+	}
+
+	sparse := make([]map[string]json.RawMessage, len(objects))
+	for i, object := range objects {
+		sparse[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := object[field]; ok {
+				sparse[i][field] = value
+			}
+		}
+	}
+	return sparse, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	value, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, strconv.ErrSyntax
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, strconv.ErrSyntax
+	}
+	return offset, nil
+}
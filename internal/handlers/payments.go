@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// PaymentHandler sends payment requests through the send pipeline of the WhatsApp
+// handler and receives their status from the payment provider
+type PaymentHandler struct {
+	pipeline       *WhatsAppHandler
+	paymentService *services.PaymentService
+	logger         *logrus.Logger
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(pipeline *WhatsAppHandler, paymentService *services.PaymentService, logger *logrus.Logger) *PaymentHandler {
+	return &PaymentHandler{
+		pipeline:       pipeline,
+		paymentService: paymentService,
+		logger:         logger,
+	}
+}
+
+// SendPayment sends an order/payment template and stores the payment request as pending
+func (h *PaymentHandler) SendPayment(c *gin.Context) {
+	var request models.PaymentSendRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	w := h.pipeline
+
+	var provider services.MessagingProvider
+	var err error
+	if request.Provider != "" {
+		provider, err = w.channels.Named(request.Channel, request.Provider)
+	} else {
+		provider, err = w.channels.For(request.Channel)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel not configured"})
+		return
+	}
+
+	suppression, err := w.suppressionService.Lookup(ctx, request.Channel, request.To)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check suppression list")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check suppression list"})
+		return
+	}
+	if suppression != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Recipient is suppressed"})
+		return
+	}
+
+	payment, send, err := h.paymentService.Create(ctx, &request)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPayment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPaymentTemplateMissing):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment template is not configured"})
+		case errors.Is(err, services.ErrPaymentExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "Payment reference already exists"})
+		default:
+			h.logger.WithError(err).Error("Failed to create payment request")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment request"})
+		}
+		return
+	}
+
+	response, err := w.deliver(ctx, provider, send, nil, "")
+	if err != nil {
+		if err := h.paymentService.Failed(context.Background(), payment); err != nil {
+			h.logger.WithError(err).Error("Failed to mark payment request failed")
+		}
+		if errors.Is(err, services.ErrInvalidTemplateVariable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send payment request"})
+		return
+	}
+
+	if err := h.paymentService.Sent(ctx, payment, response.ID); err != nil {
+		h.logger.WithError(err).WithField("reference_id", payment.ReferenceID).Warn("Failed to link payment request to its message")
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// GetPayment returns a payment request and its latest status
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	payment, err := h.paymentService.Get(c.Request.Context(), c.Param("referenceId"))
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve payment request")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payment request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// ListSessionPayments returns the payment requests sent in a session
+func (h *PaymentHandler) ListSessionPayments(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	payments, err := h.paymentService.ListForSession(c.Request.Context(), sessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list session payments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": payments})
+}
+
+// HandleStatus applies a payment status callback from the payment provider
+func (h *PaymentHandler) HandleStatus(c *gin.Context) {
+	var update models.PaymentStatusUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	payment, err := h.paymentService.UpdateStatus(c.Request.Context(), &update)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPayment):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrPaymentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Payment request not found"})
+		case errors.Is(err, services.ErrPaymentMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to apply payment status")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply payment status"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
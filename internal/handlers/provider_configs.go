@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// ProviderConfigHandler handles the admin API for stored provider configurations
+type ProviderConfigHandler struct {
+	providerConfigService *services.ProviderConfigService
+	logger                *logrus.Logger
+}
+
+// NewProviderConfigHandler creates a new provider config handler
+func NewProviderConfigHandler(providerConfigService *services.ProviderConfigService, logger *logrus.Logger) *ProviderConfigHandler {
+	return &ProviderConfigHandler{
+		providerConfigService: providerConfigService,
+		logger:                logger,
+	}
+}
+
+// ListProviders returns every stored provider configuration without credentials
+func (h *ProviderConfigHandler) ListProviders(c *gin.Context) {
+	configs, err := h.providerConfigService.List(c.Request.Context())
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": configs})
+}
+
+// CreateProvider stores a new provider configuration
+func (h *ProviderConfigHandler) CreateProvider(c *gin.Context) {
+	var request models.ProviderConfigRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider config"})
+		return
+	}
+
+	providerConfig, err := h.providerConfigService.Create(c.Request.Context(), &request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"provider_config": providerConfig.Name,
+		"admin":           c.GetString("admin_subject"),
+	}).Info("Provider config created")
+
+	c.JSON(http.StatusCreated, providerConfig)
+}
+
+// UpdateProvider changes a stored provider configuration
+func (h *ProviderConfigHandler) UpdateProvider(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider config ID"})
+		return
+	}
+
+	var request models.ProviderConfigRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider config"})
+		return
+	}
+
+	providerConfig, err := h.providerConfigService.Update(c.Request.Context(), id, &request)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"provider_config": providerConfig.Name,
+		"admin":           c.GetString("admin_subject"),
+	}).Info("Provider config updated")
+
+	c.JSON(http.StatusOK, providerConfig)
+}
+
+// DeleteProvider removes a stored provider configuration
+func (h *ProviderConfigHandler) DeleteProvider(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid provider config ID"})
+		return
+	}
+
+	if err := h.providerConfigService.Delete(c.Request.Context(), id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"provider_config_id": id,
+		"admin":              c.GetString("admin_subject"),
+	}).Info("Provider config deleted")
+
+	c.Status(http.StatusNoContent)
+}
+
+// respondError maps provider config errors to HTTP responses
+func (h *ProviderConfigHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProviderConfigsDisabled):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Provider configs are disabled"})
+	case errors.Is(err, services.ErrProviderConfigNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Provider config not found"})
+	case errors.Is(err, services.ErrInvalidProviderConfig):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Provider config operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Provider config operation failed"})
+	}
+}
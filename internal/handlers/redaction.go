@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// RedactionHandler handles admin redaction of message content
+type RedactionHandler struct {
+	redactionService *services.RedactionService
+	logger           *logrus.Logger
+}
+
+// NewRedactionHandler creates a new redaction handler
+func NewRedactionHandler(redactionService *services.RedactionService, logger *logrus.Logger) *RedactionHandler {
+	return &RedactionHandler{
+		redactionService: redactionService,
+		logger:           logger,
+	}
+}
+
+// RedactMessage replaces a message's content and media with tombstones
+func (h *RedactionHandler) RedactMessage(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	// The reason is optional, so an empty body is accepted
+	var request models.RedactMessageRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid redaction request"})
+			return
+		}
+	}
+
+	message, err := h.redactionService.RedactMessage(c.Request.Context(), messageID, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP(), request.Reason)
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).WithField("message_id", messageID).Error("Failed to redact message")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redact message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
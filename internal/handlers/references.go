@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// ReferenceHandler exposes the listings conversations are about
+type ReferenceHandler struct {
+	referenceService *services.ReferenceService
+	sessionService   *services.SessionService
+	logger           *logrus.Logger
+}
+
+// NewReferenceHandler creates a new reference handler
+func NewReferenceHandler(referenceService *services.ReferenceService, sessionService *services.SessionService, logger *logrus.Logger) *ReferenceHandler {
+	return &ReferenceHandler{
+		referenceService: referenceService,
+		sessionService:   sessionService,
+		logger:           logger,
+	}
+}
+
+// ListListingConversations returns the conversations that reference a listing
+func (h *ReferenceHandler) ListListingConversations(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	listingID := c.Param("listingId")
+	conversations, total, err := h.referenceService.ListConversations(c.Request.Context(), models.ReferenceKindListing, listingID, page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list listing conversations")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list conversations"})
+		return
+	}
+
+	respondPage(c, page, conversations, len(conversations), total)
+}
+
+// ListSessionReferences returns the listings a session references
+func (h *ReferenceHandler) ListSessionReferences(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	references, err := h.referenceService.ListForSession(c.Request.Context(), sessionID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list session references")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list references"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"references": references})
+}
+
+// CreateSessionReference links a session to a listing
+func (h *ReferenceHandler) CreateSessionReference(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var req models.ReferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.sessionService.GetSession(ctx, sessionID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	if err := h.referenceService.Link(ctx, sessionID, req.MessageID, models.ReferenceKindListing, models.ReferenceSourceAPI, req.ListingID); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	references, err := h.referenceService.ListForSession(ctx, sessionID)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"references": references})
+}
+
+func (h *ReferenceHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	case errors.Is(err, services.ErrInvalidReference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to link session reference")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link reference"})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SecurityHandler exposes detected abusive API callers to administrators
+type SecurityHandler struct {
+	securityService *services.SecurityService
+	auditService    *services.AuditService
+	logger          *logrus.Logger
+}
+
+// NewSecurityHandler creates a new security handler
+func NewSecurityHandler(securityService *services.SecurityService, auditService *services.AuditService, logger *logrus.Logger) *SecurityHandler {
+	return &SecurityHandler{
+		securityService: securityService,
+		auditService:    auditService,
+		logger:          logger,
+	}
+}
+
+// ListSecurityEvents returns lockouts and unlocks of API callers, newest first, optionally
+// for one caller (target_type ip or api_key, and target_id)
+func (h *SecurityHandler) ListSecurityEvents(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.List(c.Request.Context(), c.Query("target_type"), c.Query("target_id"), "security.", page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list security events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list security events"})
+		return
+	}
+
+	respondPage(c, page, entries, len(entries), total)
+}
+
+// UnlockCaller lifts the lockout of an IP or API key prefix
+func (h *SecurityHandler) UnlockCaller(c *gin.Context) {
+	callerType, callerID := c.Param("type"), c.Param("id")
+
+	err := h.securityService.Unlock(c.Request.Context(), callerType, callerID, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSecurityCaller) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to unlock API caller")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock caller"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"caller_type": callerType,
+		"caller":      callerID,
+		"admin":       c.GetString("admin_subject"),
+	}).Info("API caller unlocked")
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SegmentHandler manages the audience definitions campaigns can be sent to
+type SegmentHandler struct {
+	segmentService *services.SegmentService
+	logger         *logrus.Logger
+}
+
+// NewSegmentHandler creates a new segment handler
+func NewSegmentHandler(segmentService *services.SegmentService, logger *logrus.Logger) *SegmentHandler {
+	return &SegmentHandler{
+		segmentService: segmentService,
+		logger:         logger,
+	}
+}
+
+// CreateSegment stores a segment
+func (h *SegmentHandler) CreateSegment(c *gin.Context) {
+	var request models.CreateSegmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	segment, err := h.segmentService.Create(c.Request.Context(), &request, c.GetString("admin_subject"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, segment)
+}
+
+// ListSegments returns segments, newest first
+func (h *SegmentHandler) ListSegments(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	segments, total, err := h.segmentService.List(c.Request.Context(), page.limit, page.offset)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	respondPage(c, page, segments, len(segments), total)
+}
+
+// GetSegment returns a segment
+func (h *SegmentHandler) GetSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.segmentService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, segment)
+}
+
+// DeleteSegment removes a segment no campaign is waiting to launch with
+func (h *SegmentHandler) DeleteSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.segmentService.Delete(c.Request.Context(), id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewSegment counts the members of a saved segment on the `channel` query
+// parameter's channel, WhatsApp by default
+func (h *SegmentHandler) PreviewSegment(c *gin.Context) {
+	id, ok := parseSegmentID(c)
+	if !ok {
+		return
+	}
+
+	segment, err := h.segmentService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	preview, err := h.segmentService.Preview(c.Request.Context(), segment.Filters, models.Channel(c.Query("channel")))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// PreviewFilters counts the members of filters before they are saved as a segment
+func (h *SegmentHandler) PreviewFilters(c *gin.Context) {
+	var request models.PreviewSegmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	preview, err := h.segmentService.Preview(c.Request.Context(), request.Filters, request.Channel)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// respondError maps segment errors to HTTP responses
+func (h *SegmentHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSegmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+	case errors.Is(err, services.ErrInvalidSegment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSegmentInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Segment operation failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Segment operation failed"})
+	}
+}
+
+// parseSegmentID reads the segment ID path parameter, answering 400 when it is invalid
+func parseSegmentID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("segmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SendBatchHandler sends the same message to several recipients through the send
+// pipeline of the WhatsApp handler
+type SendBatchHandler struct {
+	pipeline          *WhatsAppHandler
+	validationService *services.SendValidationService
+	maxRecipients     int
+	logger            *logrus.Logger
+}
+
+// NewSendBatchHandler creates a new batch send handler
+func NewSendBatchHandler(pipeline *WhatsAppHandler, validationService *services.SendValidationService, maxRecipients int, logger *logrus.Logger) *SendBatchHandler {
+	return &SendBatchHandler{
+		pipeline:          pipeline,
+		validationService: validationService,
+		maxRecipients:     maxRecipients,
+		logger:            logger,
+	}
+}
+
+// SendBatch validates every recipient of a batch and then queues one async send per
+// recipient. A batch is all or nothing: if any recipient fails a pre-send check, or
+// the sends cannot all be claimed or queued, nothing is sent.
+func (h *SendBatchHandler) SendBatch(c *gin.Context) {
+	var batch models.SendBatchRequest
+
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if len(batch.Recipients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one recipient is required"})
+		return
+	}
+	if len(batch.Recipients) > h.maxRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d recipients are allowed", h.maxRecipients)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	w := h.pipeline
+
+	// Every recipient shares the channel, provider and content
+	var provider services.MessagingProvider
+	var err error
+	if batch.Provider != "" {
+		provider, err = w.channels.Named(batch.Channel, batch.Provider)
+	} else {
+		provider, err = w.channels.For(batch.Channel)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel not configured"})
+		return
+	}
+	if message := unsupportedSendType(provider, batch.SendRequest("")); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	// Validate every recipient before anything is claimed or sent
+	result := &models.SendBatchResult{Accepted: true}
+	requests := make([]*models.SendMessageRequest, len(batch.Recipients))
+	seen := make(map[string]bool, len(batch.Recipients))
+	for i, to := range batch.Recipients {
+		requests[i] = batch.SendRequest(to)
+		recipient := models.SendBatchRecipient{To: to, Status: models.SendStatusQueued}
+
+		verdict := h.validationService.Validate(ctx, requests[i])
+		for _, check := range verdict.Checks {
+			if !check.Passed && !check.Skipped {
+				recipient.Errors = append(recipient.Errors, fmt.Sprintf("%s: %s", check.Name, check.Reason))
+			}
+		}
+		if seen[verdict.Recipient] {
+			recipient.Errors = append(recipient.Errors, "recipient appears more than once in the batch")
+		}
+		seen[verdict.Recipient] = true
+
+		if len(recipient.Errors) > 0 {
+			recipient.Status = models.SendStatusInvalid
+			result.Accepted = false
+		}
+		result.Recipients = append(result.Recipients, recipient)
+	}
+
+	if !result.Accepted {
+		markRejected(result)
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	// Claim the duplicate window for every recipient; release them all if one is taken
+	dedupKeys := make([]string, 0, len(requests))
+	capClaims := make([]*services.NotificationClaim, 0, len(requests))
+	release := func() {
+		for _, key := range dedupKeys {
+			w.dedupService.Release(context.Background(), key)
+		}
+		for _, claim := range capClaims {
+			w.notificationCaps.Release(context.Background(), claim)
+		}
+	}
+	for i, request := range requests {
+		key, err := w.dedupService.Claim(ctx, request)
+		if err != nil {
+			var duplicate *services.DuplicateSendError
+			if errors.As(err, &duplicate) {
+				release()
+				result.Accepted = false
+				result.Recipients[i].Status = models.SendStatusInvalid
+				result.Recipients[i].Errors = []string{fmt.Sprintf("%s: duplicate of message %s", models.ValidationCheckDuplicate, duplicate.PriorMessageID)}
+				markRejected(result)
+				c.JSON(http.StatusConflict, result)
+				return
+			}
+		}
+		dedupKeys = append(dedupKeys, key)
+	}
+
+	// Count proactive sends against each recipient's caps; release them all if one is capped
+	for i, request := range requests {
+		claim, err := w.notificationCaps.Claim(ctx, request)
+		if err != nil {
+			release()
+			var capped *services.NotificationCapError
+			if !errors.As(err, &capped) {
+				h.logger.WithError(err).Error("Failed to check notification caps")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check notification caps"})
+				return
+			}
+			result.Accepted = false
+			result.Recipients[i].Status = models.SendStatusInvalid
+			result.Recipients[i].Errors = []string{fmt.Sprintf("%s: %s", models.ValidationCheckNotificationCap, capped.Error())}
+			markRejected(result)
+			c.JSON(http.StatusTooManyRequests, result)
+			return
+		}
+		capClaims = append(capClaims, claim)
+	}
+
+	// Links are shortened per recipient so clicks are attributed to their message
+	tasks := make([]services.SendTask, len(requests))
+	for i, request := range requests {
+		content, trackedLinks, err := w.linkService.ShortenLinks(ctx, request.Content)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to shorten outbound links")
+			release()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare message"})
+			return
+		}
+		request.Content = content
+
+		request, dedupKey, capClaim := request, dedupKeys[i], capClaims[i]
+		tasks[i] = services.SendTask{
+			ID: uuid.New(),
+			Job: func(ctx context.Context) (*models.SendMessageResponse, error) {
+				response, err := w.deliver(ctx, provider, request, trackedLinks, dedupKey)
+				if err != nil {
+					w.notificationCaps.Release(context.Background(), capClaim)
+				}
+				return response, err
+			},
+			Release: func() {
+				w.dedupService.Release(context.Background(), dedupKey)
+				w.notificationCaps.Release(context.Background(), capClaim)
+			},
+		}
+	}
+
+	sends, err := w.sendQueue.SubmitAll(ctx, tasks...)
+	if err != nil {
+		release()
+		if errors.Is(err, services.ErrSendQueueFull) {
+			h.logger.WithField("recipients", len(tasks)).Warn("Batch send rejected: send queue is full")
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Send queue is full"})
+			return
+		}
+		if errors.Is(err, services.ErrSendQueueStopped) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to queue batch")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue messages"})
+		return
+	}
+
+	for i, send := range sends {
+		result.Recipients[i].SendID = &send.ID
+		result.Recipients[i].Status = send.Status
+	}
+
+	h.logger.WithField("recipients", len(sends)).Info("Batch send queued")
+	c.JSON(http.StatusAccepted, result)
+}
+
+// markRejected marks the valid recipients of a rejected batch as not sent
+func markRejected(result *models.SendBatchResult) {
+	for i := range result.Recipients {
+		if result.Recipients[i].Status != models.SendStatusInvalid {
+			result.Recipients[i].Status = models.SendStatusRejected
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SendHandler exposes the state of async sends
+type SendHandler struct {
+	sendQueue *services.SendQueue
+	logger    *logrus.Logger
+}
+
+// NewSendHandler creates a new send handler
+func NewSendHandler(sendQueue *services.SendQueue, logger *logrus.Logger) *SendHandler {
+	return &SendHandler{
+		sendQueue: sendQueue,
+		logger:    logger,
+	}
+}
+
+// GetSend returns the state of an async send by its tracking ID
+func (h *SendHandler) GetSend(c *gin.Context) {
+	sendID, err := uuid.Parse(c.Param("sendId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid send ID"})
+		return
+	}
+
+	send, err := h.sendQueue.Get(c.Request.Context(), sendID)
+	if err != nil {
+		if errors.Is(err, services.ErrSendNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Send not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve send")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve send"})
+		return
+	}
+
+	c.JSON(http.StatusOK, send)
+}
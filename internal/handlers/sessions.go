@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SessionHandler handles chat session API endpoints
+type SessionHandler struct {
+	sessionService *services.SessionService
+	logger         *logrus.Logger
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessionService *services.SessionService, logger *logrus.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+		logger:         logger,
+	}
+}
+
+// ListSessions returns sessions, newest first, filtered by metadata, status and mute state
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	filter, err := parseMetadataFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var muted *bool
+	if value := c.Query("muted"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid muted filter"})
+			return
+		}
+		muted = &parsed
+	}
+
+	sessions, total, err := h.sessionService.ListSessions(c.Request.Context(), filter, c.Query("status"), muted, page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	respondPage(c, page, sessions, len(sessions), total)
+}
+
+// GetSession retrieves a chat session, including its summary once available
+func (h *SessionHandler) GetSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionService.GetSession(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve session"})
+		return
+	}
+
+	jsonWithETag(c, session)
+}
+
+// CloseSession explicitly closes a chat session
+func (h *SessionHandler) CloseSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	h.logger.WithField("session_id", sessionID).Info("Closing session via API")
+
+	session, err := h.sessionService.CloseSession(c.Request.Context(), sessionID, models.SessionCloseReasonExplicit)
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to close session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// MuteSession suppresses automated replies in a session for a number of hours
+func (h *SessionHandler) MuteSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	var request models.MuteSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mute request"})
+		return
+	}
+
+	duration := time.Duration(request.Hours * float64(time.Hour))
+	session, err := h.sessionService.Mute(c.Request.Context(), sessionID, duration, request.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMute):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSessionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		default:
+			h.logger.WithError(err).Error("Failed to mute session")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute session"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// UnmuteSession lifts a session's mute
+func (h *SessionHandler) UnmuteSession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	session, err := h.sessionService.Unmute(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to unmute session")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmute session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
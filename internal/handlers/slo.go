@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SLOHandler exposes delivery SLO compliance
+type SLOHandler struct {
+	sloService *services.SLOService
+	logger     *logrus.Logger
+}
+
+// NewSLOHandler creates a new SLO handler
+func NewSLOHandler(sloService *services.SLOService, logger *logrus.Logger) *SLOHandler {
+	return &SLOHandler{
+		sloService: sloService,
+		logger:     logger,
+	}
+}
+
+// GetReport returns the compliance and error budget burn of every SLO
+func (h *SLOHandler) GetReport(c *gin.Context) {
+	report, err := h.sloService.Report(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compute SLO report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute SLO report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
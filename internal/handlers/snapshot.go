@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// charsPerToken approximates the characters per LLM token when a budget is given in tokens
+const charsPerToken = 4
+
+// SnapshotHandler serves conversation snapshots for the orchestrator's context window
+type SnapshotHandler struct {
+	identityService *services.IdentityService
+	sessionService  *services.SessionService
+	logger          *logrus.Logger
+}
+
+// NewSnapshotHandler creates a new snapshot handler
+func NewSnapshotHandler(identityService *services.IdentityService, sessionService *services.SessionService, logger *logrus.Logger) *SnapshotHandler {
+	return &SnapshotHandler{
+		identityService: identityService,
+		sessionService:  sessionService,
+		logger:          logger,
+	}
+}
+
+// GetSnapshot returns the recent conversation with the user behind a phone number,
+// trimmed to `limit` messages and a `max_chars` or `max_tokens` budget
+func (h *SnapshotHandler) GetSnapshot(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	maxChars, err := strconv.Atoi(c.DefaultQuery("max_chars", "0"))
+	if err != nil || maxChars < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_chars"})
+		return
+	}
+	if value := c.Query("max_tokens"); value != "" {
+		maxTokens, err := strconv.Atoi(value)
+		if err != nil || maxTokens <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_tokens"})
+			return
+		}
+		if maxChars == 0 || maxTokens*charsPerToken < maxChars {
+			maxChars = maxTokens * charsPerToken
+		}
+	}
+
+	userID, err := h.identityService.LookupUserID(c.Request.Context(), c.Param("phone"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve user identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	snapshot, err := h.sessionService.ConversationSnapshot(c.Request.Context(), userID, limit, maxChars)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to build conversation snapshot")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build snapshot"})
+		return
+	}
+
+	jsonWithETag(c, snapshot)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// StatusHistoryHandler exposes the status callbacks recorded for messages
+type StatusHistoryHandler struct {
+	messageService *services.MessageService
+	logger         *logrus.Logger
+}
+
+// NewStatusHistoryHandler creates a new status history handler
+func NewStatusHistoryHandler(messageService *services.MessageService, logger *logrus.Logger) *StatusHistoryHandler {
+	return &StatusHistoryHandler{
+		messageService: messageService,
+		logger:         logger,
+	}
+}
+
+// GetStatusHistory returns every status callback received for a message in arrival order
+func (h *StatusHistoryHandler) GetStatusHistory(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	history, err := h.messageService.GetStatusHistory(c.Request.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve message status history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
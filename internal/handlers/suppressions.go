@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// SuppressionHandler handles the admin API for the recipient suppression list
+type SuppressionHandler struct {
+	suppressionService *services.SuppressionService
+	logger             *logrus.Logger
+}
+
+// NewSuppressionHandler creates a new suppression handler
+func NewSuppressionHandler(suppressionService *services.SuppressionService, logger *logrus.Logger) *SuppressionHandler {
+	return &SuppressionHandler{
+		suppressionService: suppressionService,
+		logger:             logger,
+	}
+}
+
+// ListSuppressions returns suppressed recipients, newest first
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	suppressions, total, err := h.suppressionService.List(c.Request.Context(), page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list suppressions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list suppressions"})
+		return
+	}
+
+	respondPage(c, page, suppressions, len(suppressions), total)
+}
+
+// CreateSuppression adds a recipient to the suppression list
+func (h *SuppressionHandler) CreateSuppression(c *gin.Context) {
+	var request models.SuppressRecipientRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid suppression request"})
+		return
+	}
+
+	suppression, err := h.suppressionService.Suppress(c.Request.Context(), &request)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to suppress recipient")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suppress recipient"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suppression)
+}
+
+// DeleteSuppression removes a recipient from the suppression list
+func (h *SuppressionHandler) DeleteSuppression(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid suppression ID"})
+		return
+	}
+
+	if err := h.suppressionService.Unsuppress(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrSuppressionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Suppression not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to remove suppression")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove suppression"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"suppression_id": id,
+		"admin":          c.GetString("admin_subject"),
+		"client_ip":      c.ClientIP(),
+	}).Info("Suppression removed")
+
+	c.Status(http.StatusNoContent)
+}
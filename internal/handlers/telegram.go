@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// TelegramHandler feeds Telegram bot updates into the same inbound pipeline as WhatsApp
+type TelegramHandler struct {
+	pipeline        *WhatsAppHandler
+	telegramService *services.TelegramService
+	liveLocations   *services.LiveLocationService
+	logger          *logrus.Logger
+}
+
+// NewTelegramHandler creates a new Telegram handler
+func NewTelegramHandler(pipeline *WhatsAppHandler, telegramService *services.TelegramService, liveLocations *services.LiveLocationService, logger *logrus.Logger) *TelegramHandler {
+	return &TelegramHandler{
+		pipeline:        pipeline,
+		telegramService: telegramService,
+		liveLocations:   liveLocations,
+		logger:          logger,
+	}
+}
+
+// HandleWebhook processes an update pushed by Telegram
+func (h *TelegramHandler) HandleWebhook(c *gin.Context) {
+	var update models.TelegramUpdate
+
+	if err := c.ShouldBindJSON(&update); err != nil {
+		h.logger.WithError(err).Error("Failed to parse Telegram update")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+
+	h.HandleUpdate(c.Request.Context(), &update)
+
+	// Telegram redelivers until it gets a 2xx
+	c.Status(http.StatusOK)
+}
+
+// HandleUpdate ingests a single update; also used by the long-polling loop
+func (h *TelegramHandler) HandleUpdate(ctx context.Context, update *models.TelegramUpdate) {
+	// Live locations move by editing the message that shared them
+	if location := h.telegramService.ProcessLocationEdit(update); location != nil {
+		if _, err := h.liveLocations.Update(ctx, location); err != nil {
+			h.logger.WithError(err).WithField("update_id", update.UpdateID).Error("Failed to record live location update")
+		}
+		return
+	}
+
+	message, profileName := h.telegramService.ProcessUpdate(ctx, update)
+	if message == nil {
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"update_id":  update.UpdateID,
+		"message_id": message.ID,
+		"type":       message.Type,
+	}).Info("Received Telegram message")
+
+	if err := h.pipeline.ingest(ctx, message, profileName, ""); err != nil {
+		h.logger.WithError(err).WithField("update_id", update.UpdateID).Error("Failed to ingest Telegram message")
+	}
+}
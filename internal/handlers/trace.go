@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// TraceHandler exposes the processing timeline of messages for debugging
+type TraceHandler struct {
+	traceService *services.TraceService
+	logger       *logrus.Logger
+}
+
+// NewTraceHandler creates a new trace handler
+func NewTraceHandler(traceService *services.TraceService, logger *logrus.Logger) *TraceHandler {
+	return &TraceHandler{
+		traceService: traceService,
+		logger:       logger,
+	}
+}
+
+// GetTrace returns every recorded processing step of a message, oldest first
+func (h *TraceHandler) GetTrace(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	trace, err := h.traceService.Trace(c.Request.Context(), messageID)
+	if err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to build message trace")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build trace"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
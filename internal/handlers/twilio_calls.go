@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// TwilioCallHandler handles the admin API for recent Twilio API calls
+type TwilioCallHandler struct {
+	twilioCalls *services.TwilioCallLog
+	logger      *logrus.Logger
+}
+
+// NewTwilioCallHandler creates a new Twilio call log handler
+func NewTwilioCallHandler(twilioCalls *services.TwilioCallLog, logger *logrus.Logger) *TwilioCallHandler {
+	return &TwilioCallHandler{
+		twilioCalls: twilioCalls,
+		logger:      logger,
+	}
+}
+
+// ListCalls returns recently recorded Twilio API calls, newest first
+func (h *TwilioCallHandler) ListCalls(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	failedOnly, err := strconv.ParseBool(c.DefaultQuery("failed", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid failed filter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"calls": h.twilioCalls.Recent(limit, failedOnly),
+		"limit": limit,
+	})
+}
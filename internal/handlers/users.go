@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// UserHandler handles user history and identity administration endpoints
+type UserHandler struct {
+	identityService *services.IdentityService
+	archiveService  *services.ArchiveService
+	logger          *logrus.Logger
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(identityService *services.IdentityService, archiveService *services.ArchiveService, logger *logrus.Logger) *UserHandler {
+	return &UserHandler{
+		identityService: identityService,
+		archiveService:  archiveService,
+		logger:          logger,
+	}
+}
+
+// GetUserMessages returns the message history of the user behind a phone number,
+// including messages sent under any of the user's other identifiers, optionally filtered
+// by metadata. Pages past the messages still in the database are read from archived
+// conversations.
+func (h *UserHandler) GetUserMessages(c *gin.Context) {
+	phoneNumber := c.Param("phone")
+
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	filter, err := parseMetadataFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.identityService.LookupUserID(c.Request.Context(), phoneNumber)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve user identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	history, err := h.archiveService.GetUserHistory(c.Request.Context(), userID, filter, page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to retrieve user history")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
+		return
+	}
+
+	respondPage(c, page, history.Messages, len(history.Messages), -1)
+}
+
+// SetLocale sets the locale the adapter's own messages to a user are sent in
+func (h *UserHandler) SetLocale(c *gin.Context) {
+	var request models.SetLocaleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, err := h.identityService.LookupUserID(c.Request.Context(), c.Param("phone"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve user identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	user, err := h.identityService.SetLocale(c.Request.Context(), userID, request.Locale)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidLocale):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			h.logger.WithError(err).Error("Failed to set user locale")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set locale"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// SetTimezone sets the time zone campaign quiet hours are applied in for a user
+func (h *UserHandler) SetTimezone(c *gin.Context) {
+	var request models.SetTimezoneRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, err := h.identityService.LookupUserID(c.Request.Context(), c.Param("phone"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve user identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	user, err := h.identityService.SetTimezone(c.Request.Context(), userID, request.Timezone)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTimezone):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		default:
+			h.logger.WithError(err).Error("Failed to set user time zone")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set time zone"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// SetConsent replaces the notification categories a user opted in to
+func (h *UserHandler) SetConsent(c *gin.Context) {
+	var request models.SetConsentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	userID, err := h.identityService.LookupUserID(c.Request.Context(), c.Param("phone"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to resolve user identity")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve user"})
+		return
+	}
+
+	user, err := h.identityService.SetConsent(c.Request.Context(), userID, request.Categories)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to set user consent")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set consent"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// MergeUsers merges a duplicate user into a canonical user
+func (h *UserHandler) MergeUsers(c *gin.Context) {
+	var request models.MergeUsersRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge request"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"source_user_id": request.SourceUserID,
+		"target_user_id": request.TargetUserID,
+		"admin":          c.GetString("admin_subject"),
+		"client_ip":      c.ClientIP(),
+	}).Info("Merging users via admin API")
+
+	if request.SourceUserID == uuid.Nil || request.TargetUserID == uuid.Nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result, err := h.identityService.MergeUsers(c.Request.Context(), request.SourceUserID, request.TargetUserID, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMerge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found or already merged"})
+		default:
+			h.logger.WithError(err).Error("Failed to merge users")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge users"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MergePhones merges the conversations of two numbers of the same person
+func (h *UserHandler) MergePhones(c *gin.Context) {
+	var request models.MergePhonesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge request"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"source_phone": request.SourcePhone,
+		"target_phone": request.TargetPhone,
+		"admin":        c.GetString("admin_subject"),
+		"client_ip":    c.ClientIP(),
+	}).Info("Merging phone numbers via admin API")
+
+	result, err := h.identityService.MergePhones(c.Request.Context(), &request, c.GetString("admin_subject"), c.GetString("auth_role"), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMerge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to merge phone numbers")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge phone numbers"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// LinkIdentity maps an identifier such as an email address to an existing user
+func (h *UserHandler) LinkIdentity(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var request models.LinkIdentityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity request"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"kind":      request.Kind,
+		"admin":     c.GetString("admin_subject"),
+		"client_ip": c.ClientIP(),
+	}).Info("Linking identity via admin API")
+
+	identity, err := h.identityService.LinkIdentity(c.Request.Context(), userID, request.Kind, request.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found or already merged"})
+		case errors.Is(err, services.ErrIdentityConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "Identity belongs to another user; merge the users instead"})
+		default:
+			h.logger.WithError(err).Error("Failed to link identity")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link identity"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, identity)
+}
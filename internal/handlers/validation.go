@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// ValidationHandler handles dry-run validation of outbound messages
+type ValidationHandler struct {
+	validationService *services.SendValidationService
+	logger            *logrus.Logger
+}
+
+// NewValidationHandler creates a new validation handler
+func NewValidationHandler(validationService *services.SendValidationService, logger *logrus.Logger) *ValidationHandler {
+	return &ValidationHandler{
+		validationService: validationService,
+		logger:            logger,
+	}
+}
+
+// ValidateMessage runs every pre-send check on a send request without sending it. The
+// verdict is returned with 200 whether or not the message would be accepted.
+func (h *ValidationHandler) ValidateMessage(c *gin.Context) {
+	var request models.SendMessageRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.validationService.Validate(c.Request.Context(), &request))
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// WebhookEventHandler exposes recorded raw webhook requests to admins
+type WebhookEventHandler struct {
+	webhookEventService *services.WebhookEventService
+	logger              *logrus.Logger
+}
+
+// NewWebhookEventHandler creates a new webhook event handler
+func NewWebhookEventHandler(webhookEventService *services.WebhookEventService, logger *logrus.Logger) *WebhookEventHandler {
+	return &WebhookEventHandler{
+		webhookEventService: webhookEventService,
+		logger:              logger,
+	}
+}
+
+// ListEvents returns recorded webhooks, newest first, filtered by source
+func (h *WebhookEventHandler) ListEvents(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := h.webhookEventService.ListEvents(c.Request.Context(), c.Query("source"), page.limit, page.offset)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list webhook events")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook events"})
+		return
+	}
+
+	respondPage(c, page, events, len(events), total)
+}
+
+// GetEvent returns one recorded webhook
+func (h *WebhookEventHandler) GetEvent(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook event ID"})
+		return
+	}
+
+	event, err := h.webhookEventService.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrWebhookEventNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event not found"})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to retrieve webhook event")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve webhook event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// GetMessageRaw returns the recorded webhook request a message was parsed from
+func (h *WebhookEventHandler) GetMessageRaw(c *gin.Context) {
+	messageID, err := uuid.Parse(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID"})
+		return
+	}
+
+	event, err := h.webhookEventService.GetMessageEvent(c.Request.Context(), messageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMessageNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		case errors.Is(err, services.ErrWebhookEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No raw webhook recorded for this message"})
+		default:
+			h.logger.WithError(err).Error("Failed to retrieve message webhook event")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve raw webhook"})
+		}
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"message_id":       messageID,
+		"webhook_event_id": event.ID,
+		"admin":            c.GetString("admin_subject"),
+		"client_ip":        c.ClientIP(),
+	}).Info("Raw webhook of message retrieved")
+
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+// WebhookSecretHandler reports on webhook secret rotations
+type WebhookSecretHandler struct {
+	webhookSecretService *services.WebhookSecretService
+	logger               *logrus.Logger
+}
+
+// NewWebhookSecretHandler creates a new webhook secret handler
+func NewWebhookSecretHandler(webhookSecretService *services.WebhookSecretService, logger *logrus.Logger) *WebhookSecretHandler {
+	return &WebhookSecretHandler{
+		webhookSecretService: webhookSecretService,
+		logger:               logger,
+	}
+}
+
+// GetWebhookSecrets returns when Twilio webhooks signed with the primary and secondary
+// secrets were last seen, and whether the secondary one can be retired
+func (h *WebhookSecretHandler) GetWebhookSecrets(c *gin.Context) {
+	report, err := h.webhookSecretService.Report(c.Request.Context(), "twilio")
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to report webhook secret usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report webhook secret usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -1,42 +1,170 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/middleware"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Metrics of the asynchronous inbound processing
+var (
+	inboundTasksInFlight   = metrics.NewGauge("inbound_async_tasks_in_flight", "Inbound media processing and forwarding tasks running")
+	inboundForwardsTotal   = metrics.NewCounter("inbound_forwards_total", "Inbound messages forwarded to the orchestrator", "tenant", "number", "type", "provider")
+	inboundForwardLagTotal = metrics.NewCounter("inbound_forward_lag_seconds_total", "Total time between receiving inbound messages and forwarding them")
+	inboundForwardDupTotal = metrics.NewCounter("inbound_forward_duplicates_total", "Forwards the orchestrator reported as already processed", "outcome")
 )
 
 // WhatsAppHandler handles WhatsApp webhook endpoints and API operations
 type WhatsAppHandler struct {
-	whatsappService *services.WhatsAppService
-	messageService  *services.MessageService
-	mediaService    *services.MediaService
-	aiService       *services.AIService
-	logger          *logrus.Logger
+	whatsappService    *services.WhatsAppService
+	channels           *services.ChannelProviders
+	messageService     *services.MessageService
+	mediaService       *services.MediaService
+	aiService          *services.AIService
+	linkService        *services.LinkService
+	sessionService     *services.SessionService
+	autoReply          *services.AutoReplyService
+	dedupService       *services.OutboundDedupService
+	notificationCaps   *services.NotificationCapService
+	suppressionService *services.SuppressionService
+	fallbackService    *services.FallbackService
+	classifierService  *services.ClassifierService
+	automationService  *services.AutomationService
+	outbox             *services.OutboxService
+	referenceService   *services.ReferenceService
+	sendQueue          *services.SendQueue
+	aiResultService    *services.AIResultService
+	mediaJobs          *services.MediaJobService
+	revocationService  *services.RevocationService
+	deadLetters        *services.DeadLetterService
+	appointments       *services.AppointmentService
+	firstResponse      *services.FirstResponseService
+	templateSanitizer  *services.TemplateSanitizer
+	normalizer         *services.InboundNormalizer
+	translation        *services.TranslationService
+	sentiment          *services.SentimentService
+	csat               *services.CSATService
+	logger             *logrus.Logger
+
+	// Template asking the user to resend media that expired before we fetched it
+	mediaResendTemplate string
 }
 
 // NewWhatsAppHandler creates a new WhatsApp handler
 func NewWhatsAppHandler(
 	whatsappService *services.WhatsAppService,
+	channels *services.ChannelProviders,
 	messageService *services.MessageService,
 	mediaService *services.MediaService,
 	aiService *services.AIService,
+	linkService *services.LinkService,
+	sessionService *services.SessionService,
+	autoReply *services.AutoReplyService,
+	dedupService *services.OutboundDedupService,
+	notificationCaps *services.NotificationCapService,
+	suppressionService *services.SuppressionService,
+	fallbackService *services.FallbackService,
+	classifierService *services.ClassifierService,
+	automationService *services.AutomationService,
+	outbox *services.OutboxService,
+	referenceService *services.ReferenceService,
+	sendQueue *services.SendQueue,
+	aiResultService *services.AIResultService,
+	mediaJobs *services.MediaJobService,
+	revocationService *services.RevocationService,
 	logger *logrus.Logger,
 ) *WhatsAppHandler {
 	return &WhatsAppHandler{
-		whatsappService: whatsappService,
-		messageService:  messageService,
-		mediaService:    mediaService,
-		aiService:       aiService,
-		logger:          logger,
+		whatsappService:    whatsappService,
+		channels:           channels,
+		messageService:     messageService,
+		mediaService:       mediaService,
+		aiService:          aiService,
+		linkService:        linkService,
+		sessionService:     sessionService,
+		autoReply:          autoReply,
+		dedupService:       dedupService,
+		notificationCaps:   notificationCaps,
+		suppressionService: suppressionService,
+		fallbackService:    fallbackService,
+		classifierService:  classifierService,
+		automationService:  automationService,
+		outbox:             outbox,
+		referenceService:   referenceService,
+		sendQueue:          sendQueue,
+		aiResultService:    aiResultService,
+		mediaJobs:          mediaJobs,
+		revocationService:  revocationService,
+		logger:             logger,
 	}
 }
 
+// UseMediaResendTemplate asks users to resend media that is no longer available with
+// the given content template; empty disables the request
+func (h *WhatsAppHandler) UseMediaResendTemplate(templateSID string) {
+	h.mediaResendTemplate = templateSID
+}
+
+// UseTemplateSanitizer cleans the variables of outbound templates and rejects sends
+// whose variables are too long or link to hosts that are not allowed
+func (h *WhatsAppHandler) UseTemplateSanitizer(sanitizer *services.TemplateSanitizer) {
+	h.templateSanitizer = sanitizer
+}
+
+// UseInboundNormalizer normalizes the text of inbound messages before they are routed
+// and stored
+func (h *WhatsAppHandler) UseInboundNormalizer(normalizer *services.InboundNormalizer) {
+	h.normalizer = normalizer
+}
+
+// UseTranslation translates inbound text to the working language and text replies to
+// the language of their recipient
+func (h *WhatsAppHandler) UseTranslation(translation *services.TranslationService) {
+	h.translation = translation
+}
+
+// UseSentiment scores inbound text and escalates conversations with a streak of
+// negative messages
+func (h *WhatsAppHandler) UseSentiment(sentiment *services.SentimentService) {
+	h.sentiment = sentiment
+}
+
+// UseCSAT records the ratings users give in reply to satisfaction surveys
+func (h *WhatsAppHandler) UseCSAT(csat *services.CSATService) {
+	h.csat = csat
+}
+
+// UseDeadLetters keeps inbound payloads whose processing panicked
+func (h *WhatsAppHandler) UseDeadLetters(deadLetters *services.DeadLetterService) {
+	h.deadLetters = deadLetters
+}
+
+// UseAppointments proposes the appointments the orchestrator schedules and records the
+// user's answers
+func (h *WhatsAppHandler) UseAppointments(appointments *services.AppointmentService) {
+	h.appointments = appointments
+}
+
+// UseFirstResponseAck acknowledges the first message of new sessions and measures how
+// quickly replies follow forwarded messages
+func (h *WhatsAppHandler) UseFirstResponseAck(firstResponse *services.FirstResponseService) {
+	h.firstResponse = firstResponse
+}
+
 // VerifyWebhook handles WhatsApp webhook verification
 func (h *WhatsAppHandler) VerifyWebhook(c *gin.Context) {
 	// Twilio sends a GET request with verification parameters
@@ -81,30 +209,218 @@ func (h *WhatsAppHandler) HandleMessage(c *gin.Context) {
 		"num_media":   webhookData.NumMedia,
 	}).Info("Received WhatsApp message webhook")
 
-	// Process the incoming message
-	message, err := h.whatsappService.ProcessIncomingMessage(&webhookData)
-	if err != nil {
+	h.ingestMessage(c, &webhookData)
+}
+
+// ingestMessage stores an inbound message and routes it for processing
+func (h *WhatsAppHandler) ingestMessage(c *gin.Context, webhookData *models.TwilioWebhookRequest) {
+	if err := h.ingestWebhook(c.Request.Context(), webhookData); err != nil {
 		h.logger.WithError(err).Error("Failed to process incoming message")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process message"})
 		return
 	}
 
+	// Return success to Twilio
+	c.Status(http.StatusOK)
+}
+
+// ingest runs an inbound message from any channel through the shared pipeline: session
+// resolution, media policy, storage, local replies and forwarding to the orchestrator.
+// Each stage is isolated: a panic is dead-lettered, and the pipeline stops only when
+// the message cannot be routed or stored. A failure to store the message is returned,
+// so the webhook or outbox entry that carried it is retried.
+func (h *WhatsAppHandler) ingest(ctx context.Context, message *models.WhatsAppMessage, profileName, waID string) error {
+	stage := func(name string, run func()) bool {
+		return h.isolate(string(message.Channel), name, &message.ID, message, run)
+	}
+
+	// Point the message at its recorded raw webhook, for debugging field mapping
+	message.WebhookEventID = middleware.WebhookEventID(ctx)
+
+	// Attach the message to the sender's active chat session
+	var session *models.ChatSession
+	stage(models.IngestStageSession, func() {
+		var user *models.User
+		var err error
+		if _, ok := models.IdentityKindForChannel(message.Channel); ok {
+			user, session, err = h.sessionService.ResolveChannelSession(ctx, message.Channel, message.From, profileName)
+		} else {
+			user, session, err = h.sessionService.ResolveSession(ctx, message.Channel, message.From, profileName, waID)
+		}
+		if err != nil {
+			session = nil
+			h.logger.WithError(err).Warn("Failed to resolve chat session for inbound message")
+		} else {
+			message.UserID = &user.ID
+			message.SessionID = &session.ID
+		}
+	})
+
+	// Muted conversations are stored but get no automated replies or orchestrator forward
+	muted := session != nil && session.Muted
+
+	var violation *services.MediaPolicyViolation
+	unsupported := false
+	var reply *i18n.Message
+	handled := false
+	var automations *services.AutomationMatch
+	var forward *models.OutboxEntry
+	routed := stage(models.IngestStageRoute, func() {
+		// Everything after this reads the normalized text; the original is stored with it
+		if h.normalizer != nil {
+			h.normalizer.Normalize(message)
+		}
+		if h.translation != nil {
+			h.translation.TranslateInbound(ctx, message)
+		}
+		if h.sentiment != nil {
+			h.sentiment.Score(ctx, message)
+		}
+
+		// Enforce the inbound attachment policy before any media is downloaded
+		violation = h.mediaService.CheckInboundPolicy(ctx, message)
+		if violation != nil {
+			reason := violation.Error()
+			message.Flagged = true
+			message.FlagReason = &reason
+
+			h.logger.WithFields(logrus.Fields{
+				"message_id": message.ID,
+				"reason":     reason,
+			}).Warn("Inbound attachment rejected by media policy")
+		}
+
+		// Types we cannot handle are tagged and explained rather than forwarded as text
+		if violation == nil && message.UnsupportedType != "" {
+			unsupported = true
+			reason := "unsupported_type: " + message.UnsupportedType
+			message.Flagged = true
+			message.FlagReason = &reason
+
+			h.logger.WithFields(logrus.Fields{
+				"message_id": message.ID,
+				"type":       message.UnsupportedType,
+			}).Info("Inbound message of unsupported type")
+		}
+
+		// Decide how the message is handled before storing it, so that its orchestrator
+		// forward is written in the same transaction as the message
+		if violation == nil && !unsupported && !muted {
+			// Answer locally when the conversation state already tells us what to say
+			reply, handled = h.sessionService.EvaluateInbound(ctx, session, message)
+
+			// A rating answers a survey and is thanked rather than forwarded
+			if !handled && h.csat != nil && h.csat.HandleReply(ctx, session, message) {
+				reply, handled = &i18n.Message{Key: i18n.KeyCSATThanks}, true
+			}
+		}
+
+		// Voice notes are forwarded once their transcript arrives, and automations may
+		// answer a message without the orchestrator
+		if violation == nil && !unsupported && !handled && !muted && message.Type != models.MessageTypeAudio {
+			automations = h.automationService.Match(message)
+			if !automations.Handled {
+				var err error
+				forward, err = services.NewOutboxEntry(models.OutboxKindOrchestratorForward, &message.ID, message)
+				if err != nil {
+					h.logger.WithError(err).Error("Failed to prepare orchestrator forward")
+				}
+			}
+		}
+	})
+	if !routed {
+		return nil
+	}
+
 	// Store message in database
-	if err := h.messageService.StoreMessage(c.Request.Context(), message); err != nil {
-		h.logger.WithError(err).Error("Failed to store message in database")
-		// Don't return error to Twilio, message was processed successfully
+	var outbox []*models.OutboxEntry
+	if forward != nil {
+		outbox = append(outbox, forward)
+	}
+	var created bool
+	var err error
+	if !stage(models.IngestStageStore, func() {
+		created, err = h.messageService.StoreMessage(ctx, message, outbox...)
+	}) {
+		return nil
 	}
+	switch {
+	case err != nil:
+		// Nothing was stored or forwarded; the retry runs the whole pipeline again
+		return fmt.Errorf("failed to store message: %w", err)
+	case !created:
+		// A webhook retry of a message that was already handled
+		return nil
+	default:
+		stage(models.IngestStagePostStore, func() {
+			// Forward message to chat orchestrator for AI processing
+			h.outbox.Dispatch(outbox...)
+
+			// Let the sender of a new conversation know we got it while the reply is prepared
+			if forward != nil && h.firstResponse != nil {
+				h.firstResponse.ExpectReply(ctx, message)
+				if session != nil && session.New {
+					h.firstResponse.AcknowledgeAsync(message)
+				}
+			}
+
+			// Media we downloaded into our bucket (e.g., from Telegram) is tracked by message
+			if err := h.mediaService.AttachToMessage(ctx, message.MediaURL, message.ID); err != nil {
+				h.logger.WithError(err).Warn("Failed to attach stored media to inbound message")
+			}
 
-	// Process media if present
-	if message.MediaURL != nil {
-		go h.processMediaAsync(message)
+			// Lead scoring labels are added in the background and never delay replies
+			h.classifierService.ClassifyAsync(message)
+
+			if h.sentiment != nil {
+				h.sentiment.Escalate(ctx, session, message)
+			}
+
+			if muted {
+				h.sessionService.HoldMuted(ctx, session, message)
+			}
+
+			// Confirmation buttons answer an appointment; the reply is still forwarded
+			if message.ButtonPayload != "" {
+				h.answerAppointment(ctx, message)
+			}
+		})
 	}
 
-	// Forward message to chat orchestrator for AI processing
-	go h.forwardToOrchestrator(message)
+	if automations != nil {
+		stage(models.IngestStageAutomations, func() {
+			h.automationService.Apply(ctx, automations, message, session)
+		})
+	}
 
-	// Return success to Twilio
-	c.Status(http.StatusOK)
+	stage(models.IngestStageReply, func() {
+		// Rejected attachments get an explanation instead of further processing
+		if violation != nil {
+			if !muted {
+				h.autoReply.ReplyAsync(message, violation.Reply())
+			}
+			return
+		}
+		if unsupported {
+			if !muted {
+				h.autoReply.ReplyUnsupportedAsync(message)
+			}
+			return
+		}
+
+		if handled {
+			if reply != nil {
+				h.autoReply.ReplyAsync(message, *reply)
+			}
+			return
+		}
+
+		// Process media if present
+		if message.MediaURL != nil {
+			go h.processMediaAsync(message)
+		}
+	})
+	return nil
 }
 
 // HandleStatus processes message status updates from Twilio
@@ -123,21 +439,98 @@ func (h *WhatsAppHandler) HandleStatus(c *gin.Context) {
 		"error_code":  webhookData.ErrorCode,
 	}).Info("Received WhatsApp status update webhook")
 
+	h.applyStatusUpdate(c, &webhookData)
+}
+
+// HandleConversationEvent processes Twilio Conversations webhooks: inbound messages
+// and delivery receipts when the conversations transport is enabled
+func (h *WhatsAppHandler) HandleConversationEvent(c *gin.Context) {
+	var event models.ConversationsWebhookRequest
+
+	if err := c.ShouldBind(&event); err != nil {
+		h.logger.WithError(err).Error("Failed to parse conversations webhook data")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook data"})
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"event_type":       event.EventType,
+		"conversation_sid": event.ConversationSid,
+		"message_sid":      event.MessageSid,
+	}).Info("Received Twilio Conversations webhook")
+
+	// The user deleted the message for everyone
+	if event.EventType == models.ConversationEventMessageRemoved {
+		if err := h.revokeMessage(c.Request.Context(), event.MessageSid); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke message"})
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	webhookData := h.whatsappService.ConversationEventToWebhook(&event)
+	if webhookData == nil {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	if event.EventType == models.ConversationEventDeliveryUpdated {
+		h.applyStatusUpdate(c, webhookData)
+		return
+	}
+
+	h.ingestMessage(c, webhookData)
+}
+
+// revokeMessage marks an inbound message the user deleted for everyone. Deletions of
+// messages we never stored are ignored.
+func (h *WhatsAppHandler) revokeMessage(ctx context.Context, providerSID string) error {
+	if _, err := h.revocationService.Revoke(ctx, providerSID); err != nil {
+		if errors.Is(err, services.ErrMessageNotFound) {
+			h.logger.WithField("message_sid", providerSID).Debug("Deleted message not found")
+			return nil
+		}
+		h.logger.WithError(err).WithField("message_sid", providerSID).Error("Failed to revoke message")
+		return err
+	}
+	return nil
+}
+
+// applyStatusUpdate records a delivery status change for an outbound message
+func (h *WhatsAppHandler) applyStatusUpdate(c *gin.Context, webhookData *models.TwilioWebhookRequest) {
+	// Twilio sends the same token on every retry of a callback
+	if err := h.updateStatus(c.Request.Context(), webhookData, c.GetHeader("I-Twilio-Idempotency-Token")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process status update"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// updateStatus stores a status callback and starts an SMS fallback for permanent failures
+func (h *WhatsAppHandler) updateStatus(ctx context.Context, webhookData *models.TwilioWebhookRequest, idempotencyToken string) error {
 	// Process the status update
-	statusUpdate, err := h.whatsappService.ProcessStatusUpdate(&webhookData)
+	statusUpdate, err := h.whatsappService.ProcessStatusUpdate(webhookData)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to process status update")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process status update"})
-		return
+		return err
+	}
+
+	if idempotencyToken != "" {
+		statusUpdate.PayloadRef = &idempotencyToken
 	}
 
 	// Update message status in database
-	if err := h.messageService.UpdateMessageStatus(c.Request.Context(), statusUpdate); err != nil {
+	if err := h.messageService.UpdateMessageStatus(ctx, statusUpdate); err != nil {
 		h.logger.WithError(err).Error("Failed to update message status in database")
 		// Don't return error to Twilio
 	}
 
-	c.Status(http.StatusOK)
+	// Permanent WhatsApp failures may be retried over SMS
+	h.fallbackService.HandleStatusUpdateAsync(statusUpdate)
+
+	return nil
 }
 
 // SendMessage handles API requests to send WhatsApp messages
@@ -156,69 +549,336 @@ func (h *WhatsAppHandler) SendMessage(c *gin.Context) {
 		"content": request.Content,
 	}).Info("Sending WhatsApp message via API")
 
-	var response *models.SendMessageResponse
+	// Route to the named provider, or the default provider for the requested channel
+	var provider services.MessagingProvider
 	var err error
+	if request.Provider != "" {
+		provider, err = h.channels.Named(request.Channel, request.Provider)
+	} else {
+		provider, err = h.channels.For(request.Channel)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel not configured"})
+		return
+	}
 
-	// Send message based on type
+	// Never message recipients on the suppression list
+	suppression, err := h.suppressionService.Lookup(c.Request.Context(), request.Channel, request.To)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check suppression list")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check suppression list"})
+		return
+	}
+	if suppression != nil {
+		h.logger.WithField("to", request.To).Warn("Outbound message to suppressed recipient blocked")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Recipient is suppressed"})
+		return
+	}
+
+	if message := unsupportedSendType(provider, &request); message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	// Variables are cleaned before the duplicate check so both see what is sent
+	if err := h.sanitizeVariables(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Direct uploads are only sent once they have been verified
+	if request.MediaURL != nil && *request.MediaURL != "" {
+		if err := h.mediaService.VerifyUpload(c.Request.Context(), *request.MediaURL); err != nil {
+			if errors.Is(err, services.ErrMediaUploadPending) || errors.Is(err, services.ErrMediaUploadRejected) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			h.logger.WithError(err).Error("Failed to verify uploaded media")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify uploaded media"})
+			return
+		}
+	}
+
+	// Suppress accidental double-sends of identical content
+	dedupKey, err := h.dedupService.Claim(c.Request.Context(), &request)
+	if err != nil {
+		var duplicate *services.DuplicateSendError
+		if errors.As(err, &duplicate) {
+			h.logger.WithField("to", request.To).Warn("Duplicate outbound message suppressed")
+			c.JSON(http.StatusConflict, gin.H{
+				"error":        "Duplicate message",
+				"duplicate_of": duplicate.PriorMessageID,
+			})
+			return
+		}
+	}
+
+	// Cap proactive messages per recipient to prevent notification fatigue
+	capClaim, err := h.notificationCaps.Claim(c.Request.Context(), &request)
+	if err != nil {
+		h.dedupService.Release(context.Background(), dedupKey)
+		var capped *services.NotificationCapError
+		if errors.As(err, &capped) {
+			h.logger.WithFields(logrus.Fields{
+				"to":     request.To,
+				"period": capped.Period,
+			}).Warn("Proactive message suppressed by notification cap")
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":  "Notification cap reached",
+				"period": capped.Period,
+				"cap":    capped.Cap,
+			})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to check notification caps")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check notification caps"})
+		return
+	}
+
+	// Replace URLs with tracked short links before the content leaves the adapter
+	content, trackedLinks, err := h.linkService.ShortenLinks(c.Request.Context(), request.Content)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to shorten outbound links")
+		h.dedupService.Release(context.Background(), dedupKey)
+		h.notificationCaps.Release(context.Background(), capClaim)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare message"})
+		return
+	}
+	request.Content = content
+
+	// Async sends are answered at once and performed by the send queue's workers
+	if request.Async {
+		release := func() {
+			h.dedupService.Release(context.Background(), dedupKey)
+			h.notificationCaps.Release(context.Background(), capClaim)
+		}
+		send, err := h.sendQueue.Submit(c.Request.Context(), services.SendTask{
+			ID: uuid.New(),
+			Job: func(ctx context.Context) (*models.SendMessageResponse, error) {
+				response, err := h.deliver(ctx, provider, &request, trackedLinks, dedupKey)
+				if err != nil {
+					h.notificationCaps.Release(context.Background(), capClaim)
+				}
+				return response, err
+			},
+			Release: release,
+		})
+		if err != nil {
+			release()
+			if errors.Is(err, services.ErrSendQueueFull) {
+				h.logger.WithField("to", request.To).Warn("Async send rejected: send queue is full")
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Send queue is full"})
+				return
+			}
+			if errors.Is(err, services.ErrSendQueueStopped) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Server is shutting down"})
+				return
+			}
+			h.logger.WithError(err).Error("Failed to queue message")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue message"})
+			return
+		}
+		c.JSON(http.StatusAccepted, send)
+		return
+	}
+
+	response, err := h.deliver(c.Request.Context(), provider, &request, trackedLinks, dedupKey)
+	if err != nil {
+		h.notificationCaps.Release(context.Background(), capClaim)
+	}
+	if errors.Is(err, services.ErrLocationRequestTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Location request template SID required in template"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// sanitizeVariables replaces the template variables of a request with their sanitized
+// values
+func (h *WhatsAppHandler) sanitizeVariables(request *models.SendMessageRequest) error {
+	if h.templateSanitizer == nil || request.Template == nil {
+		return nil
+	}
+
+	variables, err := h.templateSanitizer.Sanitize(request.Variables)
+	if err != nil {
+		return err
+	}
+	request.Variables = variables
+	return nil
+}
+
+// translatableSendType reports whether the content of a send of type is free text
+// shown to the user; templates and interactive messages are sent as approved
+func translatableSendType(messageType models.MessageType) bool {
+	switch messageType {
+	case models.MessageTypeText, "", models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
+		return true
+	}
+	return false
+}
+
+// unsupportedSendType describes why a send request's type cannot be sent through
+// provider, or returns an empty string when it can
+func unsupportedSendType(provider services.MessagingProvider, request *models.SendMessageRequest) string {
 	switch request.Type {
 	case models.MessageTypeText, "":
-		response, err = h.whatsappService.SendTextMessage(c.Request.Context(), request.To, request.Content)
-	
+		return ""
 	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
 		if request.MediaURL == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Media URL required for media messages"})
-			return
+			return "Media URL required for media messages"
+		}
+		return ""
+	case models.MessageTypeFlow:
+		if request.Template == nil {
+			return "Flow content SID required in template"
 		}
+		if _, ok := provider.(services.FlowSender); !ok {
+			return "Channel does not support WhatsApp Flows"
+		}
+		return ""
+	case models.MessageTypeLocationRequest:
+		if _, ok := provider.(services.LocationRequester); !ok {
+			return "Channel does not support location requests"
+		}
+		return ""
+	default:
+		if request.Template == nil {
+			return "Unsupported message type"
+		}
+		return ""
+	}
+}
+
+// deliver sends a validated request through provider and stores the outbound message.
+// The dedup claim is confirmed on success and released on failure.
+func (h *WhatsAppHandler) deliver(ctx context.Context, provider services.MessagingProvider, request *models.SendMessageRequest, trackedLinks []*models.TrackedLink, dedupKey string) (*models.SendMessageResponse, error) {
+	if err := h.sanitizeVariables(request); err != nil {
+		h.dedupService.Release(context.Background(), dedupKey)
+		return nil, err
+	}
+
+	// Text and captions go out in the recipient's language; the text as written is stored
+	content := request.Content
+	var original, language *string
+	if h.translation != nil && !request.SkipTranslation && translatableSendType(request.Type) {
+		if translated, lang := h.translation.TranslateOutbound(ctx, request.Channel, request.To, content); lang != "" {
+			original, language = &request.Content, &lang
+			content = translated
+		}
+	}
+
+	var response *models.SendMessageResponse
+	var err error
+
+	// Send message based on type
+	switch request.Type {
+	case models.MessageTypeText, "":
+		response, err = provider.SendTextMessage(ctx, request.To, content)
+
+	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
 		mediaType := ""
 		if request.MediaType != nil {
 			mediaType = *request.MediaType
 		}
-		response, err = h.whatsappService.SendMediaMessage(c.Request.Context(), request.To, request.Content, *request.MediaURL, mediaType)
-	
-	default:
+		response, err = provider.SendMediaMessage(ctx, request.To, content, *request.MediaURL, mediaType)
+
+	case models.MessageTypeFlow:
+		response, err = provider.(services.FlowSender).SendFlowMessage(ctx, request.To, *request.Template, request.Variables)
+
+	case models.MessageTypeLocationRequest:
+		templateSID := ""
 		if request.Template != nil {
-			response, err = h.whatsappService.SendTemplateMessage(c.Request.Context(), request.To, *request.Template, request.Variables)
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported message type"})
-			return
+			templateSID = *request.Template
 		}
+		response, err = provider.(services.LocationRequester).SendLocationRequest(ctx, request.To, content, templateSID)
+
+	default:
+		response, err = provider.SendTemplateMessage(ctx, request.To, *request.Template, request.Variables)
 	}
 
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to send WhatsApp message")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
-		return
+		h.dedupService.Release(context.Background(), dedupKey)
+		return nil, err
 	}
 
+	h.dedupService.Confirm(ctx, dedupKey, response.ID)
+
 	// Store outbound message in database
 	outboundMessage := &models.WhatsAppMessage{
 		ID:        response.ID,
 		TwilioSID: response.TwilioSID,
-		From:      h.whatsappService.GetFromNumber(),
+		From:      provider.GetFromNumber(),
 		To:        request.To,
 		Direction: models.MessageDirectionOutbound,
 		Type:      request.Type,
 		Status:    response.Status,
-		Content:   request.Content,
+		Content:   content,
 		MediaURL:  request.MediaURL,
 		MediaType: request.MediaType,
 		Timestamp: response.CreatedAt,
 		CreatedAt: response.CreatedAt,
 		UpdatedAt: response.CreatedAt,
+		Category:  request.Category,
+		Channel:   request.Channel,
+
+		OriginalContent: original,
+		Language:        language,
 	}
 
-	if err := h.messageService.StoreMessage(c.Request.Context(), outboundMessage); err != nil {
+	// Outbound replies belong to the recipient's active session, if any
+	if session, err := h.sessionService.TouchActiveSession(ctx, request.Channel, request.To); err != nil {
+		h.logger.WithError(err).Warn("Failed to resolve chat session for outbound message")
+	} else if session != nil {
+		outboundMessage.UserID = &session.UserID
+		outboundMessage.SessionID = &session.ID
+	}
+
+	if _, err := h.messageService.StoreMessage(ctx, outboundMessage); err != nil {
 		h.logger.WithError(err).Error("Failed to store outbound message")
-		// Don't fail the request, message was sent successfully
+		// Don't fail the send, the message was sent successfully
 	}
 
-	c.JSON(http.StatusOK, response)
+	if h.firstResponse != nil {
+		h.firstResponse.ObserveReply(ctx, outboundMessage)
+	}
+
+	if err := h.linkService.AttachToMessage(ctx, trackedLinks, response.ID); err != nil {
+		h.logger.WithError(err).Warn("Failed to attach tracked links to outbound message")
+	}
+
+	if err := h.mediaService.AttachToMessage(ctx, request.MediaURL, response.ID); err != nil {
+		h.logger.WithError(err).Warn("Failed to attach uploaded media to outbound message")
+	}
+
+	return response, nil
 }
 
-// GetMessage retrieves a message by ID
+// GetMessage retrieves a message by ID. ?include=media,status_history,ai_results,session
+// adds those related records to the response.
 func (h *WhatsAppHandler) GetMessage(c *gin.Context) {
 	messageID := c.Param("messageId")
-	
+
+	include := map[string]bool{}
+	if raw := c.Query("include"); raw != "" {
+		for _, section := range strings.Split(raw, ",") {
+			section = strings.TrimSpace(section)
+			switch section {
+			case models.MessageIncludeMedia, models.MessageIncludeStatusHistory, models.MessageIncludeAIResults, models.MessageIncludeSession:
+				include[section] = true
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown include %q; expected media, status_history, ai_results or session", section)})
+				return
+			}
+		}
+	}
+
 	h.logger.WithField("message_id", messageID).Info("Retrieving message")
 
 	message, err := h.messageService.GetMessage(c.Request.Context(), messageID)
@@ -228,7 +888,55 @@ func (h *WhatsAppHandler) GetMessage(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, message)
+	if len(include) == 0 {
+		jsonWithETag(c, message)
+		return
+	}
+
+	detail, err := h.expandMessage(c.Request.Context(), message, include)
+	if err != nil {
+		h.logger.WithError(err).WithField("message_id", messageID).Error("Failed to retrieve message details")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve message details"})
+		return
+	}
+
+	jsonWithETag(c, detail)
+}
+
+// expandMessage loads the related records of a message named in include
+func (h *WhatsAppHandler) expandMessage(ctx context.Context, message *models.WhatsAppMessage, include map[string]bool) (*models.MessageDetail, error) {
+	detail := &models.MessageDetail{WhatsAppMessage: message}
+	var err error
+
+	if include[models.MessageIncludeMedia] {
+		detail.Media, _, err = h.mediaService.ListMedia(ctx, models.MediaFilter{MessageID: &message.ID}, 100, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if include[models.MessageIncludeStatusHistory] {
+		detail.StatusHistory, err = h.messageService.GetStatusHistory(ctx, message.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if include[models.MessageIncludeAIResults] {
+		detail.AIResults, err = h.aiResultService.GetResultsByMessage(ctx, message.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if include[models.MessageIncludeSession] && message.SessionID != nil {
+		detail.Session, err = h.sessionService.GetSession(ctx, *message.SessionID)
+		if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			return nil, err
+		}
+	}
+
+	return detail, nil
 }
 
 // UploadMedia handles media file uploads
@@ -270,25 +978,251 @@ func (h *WhatsAppHandler) processMediaAsync(message *models.WhatsAppMessage) {
 		return
 	}
 
+	inboundTasksInFlight.Add(1)
+	defer inboundTasksInFlight.Add(-1)
+
 	h.logger.WithFields(logrus.Fields{
 		"message_id": message.ID,
 		"media_url":  *message.MediaURL,
 		"media_type": *message.MediaType,
 	}).Info("Processing media asynchronously")
 
+	ctx := context.Background()
+	h.mediaJobs.Start(ctx, message.ID)
+
 	// Download and process media
-	err := h.mediaService.ProcessMedia(context.Background(), message)
+	result, err := h.mediaService.ProcessMedia(ctx, message)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to process media")
+		if errors.Is(err, services.ErrMediaUnavailable) {
+			h.mediaUnavailable(ctx, message, err)
+		} else {
+			h.mediaJobs.Record(ctx, message.ID, models.MediaStageDownloaded, models.MediaJobFailed, err)
+		}
+		if message.Type == models.MessageTypeAudio {
+			h.enqueueForward(ctx, message)
+		}
+		return
+	}
+
+	// Scanning and transcoding are not implemented yet
+	downloaded := models.MediaJobSkipped
+	if result.Downloaded {
+		downloaded = models.MediaJobCompleted
+	}
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageDownloaded, downloaded, nil)
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageScanned, models.MediaJobSkipped, nil)
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageTranscoded, models.MediaJobSkipped, nil)
+
+	if result.Violation != nil {
+		h.rejectMedia(ctx, message, result.Violation)
+		return
+	}
+
+	if result.ExtractedText != "" {
+		message.ExtractedText = &result.ExtractedText
+		if err := h.messageService.UpdateExtractedText(ctx, message.ID, result.ExtractedText); err != nil {
+			h.logger.WithError(err).Warn("Failed to persist extracted text")
+		}
+	}
+
+	if result.SHA256 != "" {
+		message.MediaSHA256 = &result.SHA256
+		if err := h.messageService.SetMediaHash(ctx, message.ID, result.SHA256); err != nil {
+			h.logger.WithError(err).Warn("Failed to persist media hash")
+		}
+		if result.Duplicate && h.reuseAnalysis(ctx, message, result) {
+			return
+		}
+	}
+
+	// Route the media to the matching AI analysis endpoint. The stage is marked running
+	// first, as the result callback may arrive before the request returns.
+	switch message.Type {
+	case models.MessageTypeImage, models.MessageTypeDocument, models.MessageTypeAudio:
+		h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobRunning, nil)
+	default:
+		h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobSkipped, nil)
+	}
+
+	switch message.Type {
+	case models.MessageTypeImage:
+		if imageAnalysis(h.mediaService.OCREnabled(), result) == models.AIAnalysisDocument {
+			err = h.aiService.ProcessDocumentAI(ctx, message, *message.MediaURL)
+		} else {
+			err = h.aiService.ProcessImageAI(ctx, message, *message.MediaURL)
+		}
+	case models.MessageTypeDocument:
+		err = h.aiService.ProcessDocumentAI(ctx, message, *message.MediaURL)
+	case models.MessageTypeAudio:
+		if err = h.aiService.ProcessAudioAI(ctx, message, *message.MediaURL); err != nil {
+			// Without a transcript the orchestrator still needs to know a voice note arrived
+			h.enqueueForward(ctx, message)
+		}
+	}
+
+	if err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to send media for AI analysis")
+		h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobFailed, err)
+	}
+}
+
+// imageAnalysis picks the AI analysis for an inbound image. Only OCR can tell a
+// photographed document from a photo, so without it every image is a photo.
+func imageAnalysis(ocrEnabled bool, result *services.MediaProcessingResult) models.AIAnalysisType {
+	if !ocrEnabled || result == nil || !result.DocumentLike {
+		return models.AIAnalysisImage
+	}
+	return models.AIAnalysisDocument
+}
+
+// mediaUnavailable marks an attachment whose media expired or was deleted before we
+// fetched it, and asks the user to send it again when a resend template is configured
+func (h *WhatsAppHandler) mediaUnavailable(ctx context.Context, message *models.WhatsAppMessage, err error) {
+	h.logger.WithError(err).WithField("message_id", message.ID).Warn("Inbound media is no longer available")
+
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageDownloaded, models.MediaJobUnavailable, err)
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageScanned, models.MediaJobSkipped, nil)
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageTranscoded, models.MediaJobSkipped, nil)
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobSkipped, nil)
+
+	if h.mediaResendTemplate == "" {
+		return
+	}
+	if muted, _ := h.sessionService.IsMuted(ctx, message); muted {
+		return
+	}
+	if _, err := h.autoReply.ReplyTemplate(ctx, message, h.mediaResendTemplate, nil); err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to request media resend")
+	}
+}
+
+// reuseAnalysis applies the analysis of earlier media with the same content instead of
+// sending it to the AI service again. It reports whether the analysis was reused.
+func (h *WhatsAppHandler) reuseAnalysis(ctx context.Context, message *models.WhatsAppMessage, result *services.MediaProcessingResult) bool {
+	var reused bool
+	var err error
+
+	switch message.Type {
+	case models.MessageTypeImage:
+		analysisType := imageAnalysis(h.mediaService.OCREnabled(), result)
+		reused, err = h.aiResultService.ReuseResult(ctx, message, analysisType)
+	case models.MessageTypeDocument:
+		reused, err = h.aiResultService.ReuseResult(ctx, message, models.AIAnalysisDocument)
+	case models.MessageTypeAudio:
+		var transcript *string
+		transcript, err = h.messageService.TranscriptByMediaHash(ctx, *message.MediaSHA256, message.ID)
+		if err == nil && transcript != nil {
+			err = h.forwardTranscript(ctx, message, *transcript)
+			reused = err == nil
+		}
+	}
+
+	if err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to reuse analysis of duplicate media")
+		return false
+	}
+	if reused {
+		h.logger.WithField("message_id", message.ID).Info("Reused analysis of duplicate media")
+	}
+	return reused
+}
+
+// DeliverForward processes an orchestrator forward from the outbox
+func (h *WhatsAppHandler) DeliverForward(ctx context.Context, entry *models.OutboxEntry) error {
+	var message models.WhatsAppMessage
+	if err := json.Unmarshal(entry.Payload, &message); err != nil {
+		return fmt.Errorf("failed to decode forwarded message: %w", err)
+	}
+
+	return h.forwardToOrchestrator(ctx, &message)
+}
+
+// enqueueForward forwards a message that is already stored to the chat orchestrator
+// through the outbox, so the forward is retried until it succeeds
+func (h *WhatsAppHandler) enqueueForward(ctx context.Context, message *models.WhatsAppMessage) {
+	entry, err := services.NewOutboxEntry(models.OutboxKindOrchestratorForward, &message.ID, message)
+	if err == nil {
+		err = h.outbox.Enqueue(ctx, entry)
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to enqueue orchestrator forward")
 	}
 }
 
 // forwardToOrchestrator forwards the message to the chat orchestrator
-func (h *WhatsAppHandler) forwardToOrchestrator(message *models.WhatsAppMessage) {
+func (h *WhatsAppHandler) forwardToOrchestrator(ctx context.Context, message *models.WhatsAppMessage) error {
+	inboundTasksInFlight.Add(1)
+	defer inboundTasksInFlight.Add(-1)
+
+	inboundForwardsTotal.Inc(services.MessageMetricLabels(message)...)
+	inboundForwardLagTotal.Add(time.Since(message.CreatedAt).Seconds())
+
+	// Media and transcripts can complete after the conversation was muted
+	if muted, err := h.sessionService.IsMuted(ctx, message); err != nil {
+		h.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to check conversation mute; forwarding")
+	} else if muted {
+		h.logger.WithField("message_id", message.ID).Info("Conversation muted; message not forwarded to orchestrator")
+		return nil
+	}
+
 	h.logger.WithField("message_id", message.ID).Info("Forwarding message to chat orchestrator")
 
-	err := h.aiService.ForwardToOrchestrator(context.Background(), message)
+	chatContext := h.sessionService.BuildChatContext(ctx, message)
+
+	response, err := h.aiService.ForwardToOrchestrator(ctx, message, chatContext)
+	if errors.Is(err, services.ErrDuplicateForward) {
+		inboundForwardDupTotal.Inc("conflict")
+		return nil
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to forward message to orchestrator")
+		return err
+	}
+
+	// A replayed response is applied again: the earlier delivery may have failed before
+	// applying it, and every step below is idempotent
+	if response.Replayed {
+		inboundForwardDupTotal.Inc("replayed")
 	}
-}
\ No newline at end of file
+
+	if message.SessionID != nil && response.NextAction != "" {
+		if err := h.sessionService.ApplyNextAction(ctx, *message.SessionID, response.NextAction); err != nil {
+			h.logger.WithError(err).Warn("Failed to apply orchestrator next action")
+		}
+	}
+
+	if services.IsScheduleAction(response.NextAction) {
+		h.scheduleAppointment(ctx, message, response)
+	}
+
+	if message.SessionID != nil {
+		if err := h.referenceService.LinkFromResponse(ctx, *message.SessionID, &message.ID, response.Context, response.NextAction); err != nil {
+			h.logger.WithError(err).Warn("Failed to link orchestrator listing references")
+		}
+	}
+
+	return nil
+}
+
+// rejectMedia flags a message whose media breached policy and explains why to the user
+func (h *WhatsAppHandler) rejectMedia(ctx context.Context, message *models.WhatsAppMessage, violation *services.MediaPolicyViolation) {
+	h.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"reason":     violation.Error(),
+	}).Warn("Inbound media rejected by media policy")
+
+	h.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobFailed, violation)
+
+	if err := h.messageService.FlagMessage(ctx, message.ID, violation.Error()); err != nil {
+		h.logger.WithError(err).Warn("Failed to flag rejected message")
+	}
+
+	// No automated replies while the conversation is muted
+	if muted, _ := h.sessionService.IsMuted(ctx, message); muted {
+		return
+	}
+	if _, err := h.autoReply.ReplyMessage(ctx, message, violation.Reply()); err != nil {
+		h.logger.WithError(err).Warn("Failed to send media rejection reply")
+	}
+}
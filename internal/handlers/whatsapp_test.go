@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
+)
+
+func TestImageAnalysis(t *testing.T) {
+	tests := []struct {
+		name       string
+		ocrEnabled bool
+		result     *services.MediaProcessingResult
+		want       models.AIAnalysisType
+	}{
+		{"ocr disabled", false, &services.MediaProcessingResult{}, models.AIAnalysisImage},
+		{"ocr disabled ignores stale flag", false, &services.MediaProcessingResult{DocumentLike: true}, models.AIAnalysisImage},
+		{"photo", true, &services.MediaProcessingResult{ExtractedText: "hi"}, models.AIAnalysisImage},
+		{"photographed document", true, &services.MediaProcessingResult{DocumentLike: true}, models.AIAnalysisDocument},
+		{"no result", true, nil, models.AIAnalysisImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageAnalysis(tt.ocrEnabled, tt.result); got != tt.want {
+				t.Errorf("imageAnalysis() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package i18n holds the catalog of messages the adapter sends on its own, such as
+// rejection notices and conversation-state prompts, in every supported locale.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed locales/*.json
+var embedded embed.FS
+
+// Message keys
+const (
+	KeyMediaTooLarge        = "media.too_large"
+	KeyMediaTooLong         = "media.too_long"
+	KeyMediaTypeNotAllowed  = "media.type_not_allowed"
+	KeyAwaitingDocument     = "state.awaiting_document"
+	KeyAwaitingConfirmation = "state.awaiting_confirmation"
+	KeyUnsupportedDefault   = "unsupported.default"
+	KeyAckReceived          = "ack.received"
+	KeyCSATThanks           = "csat.thanks"
+)
+
+// KeyUnsupportedPrefix prefixes the explanation for one unsupported inbound type, e.g.
+// "unsupported.poll"
+const KeyUnsupportedPrefix = "unsupported."
+
+// localePattern matches a language with an optional region, e.g. "pt", "pt-BR" or "es_419"
+var localePattern = regexp.MustCompile(`^([A-Za-z]{2,3})(?:[-_]([A-Za-z]{2}|[0-9]{3}))?$`)
+
+// Message is a catalog key and the variables substituted into its {name} placeholders
+type Message struct {
+	Key  string
+	Vars map[string]string
+}
+
+// Catalog maps locales to their message texts
+type Catalog struct {
+	messages      map[string]map[string]string
+	defaultLocale string
+}
+
+// Load reads the embedded catalogs and then every <locale>.json in overrideDir, whose
+// keys replace or extend the embedded ones. The default locale must be in the catalog.
+func Load(defaultLocale, overrideDir string) (*Catalog, error) {
+	c := &Catalog{messages: map[string]map[string]string{}}
+
+	files, err := embedded.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedded locales: %w", err)
+	}
+	for _, file := range files {
+		data, err := embedded.ReadFile("locales/" + file.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded locale %s: %w", file.Name(), err)
+		}
+		if err := c.merge(file.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if overrideDir != "" {
+		paths, err := filepath.Glob(filepath.Join(overrideDir, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list locale overrides: %w", err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read locale override %s: %w", path, err)
+			}
+			if err := c.merge(filepath.Base(path), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	locale, err := Normalize(defaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := c.messages[locale]; !ok {
+		return nil, fmt.Errorf("default locale %q has no message catalog", defaultLocale)
+	}
+	c.defaultLocale = locale
+
+	return c, nil
+}
+
+// merge adds the messages of a <locale>.json file to the catalog
+func (c *Catalog) merge(name string, data []byte) error {
+	locale, err := Normalize(strings.TrimSuffix(name, ".json"))
+	if err != nil {
+		return fmt.Errorf("invalid locale file %s: %w", name, err)
+	}
+
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("failed to parse locale file %s: %w", name, err)
+	}
+
+	if c.messages[locale] == nil {
+		c.messages[locale] = map[string]string{}
+	}
+	for key, text := range messages {
+		c.messages[locale][key] = text
+	}
+	return nil
+}
+
+// Text renders a message in the best available locale: the locale itself, another
+// region of its language, then the default locale. Unknown keys render as the key.
+func (c *Catalog) Text(locale string, message Message) string {
+	text, ok := c.messages[c.Resolve(locale)][message.Key]
+	if !ok {
+		text, ok = c.messages[c.defaultLocale][message.Key]
+	}
+	if !ok {
+		return message.Key
+	}
+
+	for name, value := range message.Vars {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
+
+// Has reports whether the default locale defines a message key
+func (c *Catalog) Has(key string) bool {
+	_, ok := c.messages[c.defaultLocale][key]
+	return ok
+}
+
+// Resolve returns the catalog locale used for a requested locale
+func (c *Catalog) Resolve(locale string) string {
+	normalized, err := Normalize(locale)
+	if err != nil {
+		return c.defaultLocale
+	}
+	if _, ok := c.messages[normalized]; ok {
+		return normalized
+	}
+
+	language := strings.SplitN(normalized, "-", 2)[0]
+	if _, ok := c.messages[language]; ok {
+		return language
+	}
+	if strings.HasPrefix(c.defaultLocale, language+"-") {
+		return c.defaultLocale
+	}
+	var regional []string
+	for candidate := range c.messages {
+		if strings.HasPrefix(candidate, language+"-") {
+			regional = append(regional, candidate)
+		}
+	}
+	if len(regional) > 0 {
+		sort.Strings(regional)
+		return regional[0]
+	}
+
+	return c.defaultLocale
+}
+
+// Locales returns the locales in the catalog
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Normalize formats a locale as a lowercase language and uppercase region, e.g.
+// "pt_br" becomes "pt-BR"
+func Normalize(locale string) (string, error) {
+	match := localePattern.FindStringSubmatch(strings.TrimSpace(locale))
+	if match == nil {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	if match[2] == "" {
+		return strings.ToLower(match[1]), nil
+	}
+	return strings.ToLower(match[1]) + "-" + strings.ToUpper(match[2]), nil
+}
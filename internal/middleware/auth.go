@@ -1,16 +1,35 @@
 package middleware
 
 import (
+	"context"
 	"crypto/hmac"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
 )
 
-// WhatsAppSignatureVerification verifies Twilio webhook signatures
-func WhatsAppSignatureVerification(secret string) gin.HandlerFunc {
+// WebhookSecretObserver is told which configured secret verified a webhook, so the usage
+// of a secret being rotated out can be tracked
+type WebhookSecretObserver interface {
+	ObserveWebhookSecret(source, slot string)
+}
+
+// WhatsAppSignatureVerification verifies Twilio webhook signatures. The signed URL is
+// baseURL followed by the request path, or is rebuilt from the request when baseURL is
+// empty. While the auth token is rotated, signatures made with the secondary secret are
+// accepted too; observer, when set, is told which of the two matched.
+func WhatsAppSignatureVerification(secret, secondary, baseURL string, observer WebhookSecretObserver) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if secret == "" {
 			// Skip verification if no secret is configured (development mode)
@@ -26,32 +45,272 @@ func WhatsAppSignatureVerification(secret string) gin.HandlerFunc {
 			return
 		}
 
-		// Get the raw body for signature verification
-		// Note: In a production implementation, you might need to read the body
-		// and then restore it for subsequent handlers
-		
-		// For now, we'll just verify the signature exists
-		// TODO: Implement full signature verification
-		
+		body, err := RawBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+
+		webhookURL := requestURL(c, baseURL)
+		slot := ""
+		switch {
+		case verifySignature(signature, secret, webhookURL, c.ContentType(), body):
+			slot = models.WebhookSecretPrimary
+		case secondary != "" && verifySignature(signature, secondary, webhookURL, c.ContentType(), body):
+			slot = models.WebhookSecretSecondary
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			c.Abort()
+			return
+		}
+
+		if observer != nil {
+			observer.ObserveWebhookSecret("twilio", slot)
+		}
+		c.Next()
+	}
+}
+
+// MetaSignatureVerification verifies the X-Hub-Signature-256 header Meta sends with
+// Messenger and Instagram webhooks: an HMAC-SHA256 of the raw body keyed by the app secret
+func MetaSignatureVerification(appSecret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if appSecret == "" {
+			// Skip verification if no secret is configured (development mode)
+			c.Next()
+			return
+		}
+
+		signature := strings.TrimPrefix(c.GetHeader("X-Hub-Signature-256"), "sha256=")
+		if signature == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing signature"})
+			c.Abort()
+			return
+		}
+
+		body, err := RawBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte(appSecret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TelegramSecretToken checks the X-Telegram-Bot-Api-Secret-Token header Telegram sends
+// when the webhook was registered with a secret_token
+func TelegramSecretToken(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			// Skip verification if no secret is configured (development mode)
+			c.Next()
+			return
+		}
+
+		provided := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid secret token"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
-// RateLimit implements basic rate limiting using Redis
-func RateLimit(redisClient interface{}) gin.HandlerFunc {
+// BasicAuthToken checks a shared token sent as the HTTP basic auth password, for webhook
+// senders (SendGrid, SNS) that only support credentials embedded in the URL
+func BasicAuthToken(token string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: Implement rate limiting logic using Redis
-		// For now, just pass through
+		if token == "" {
+			// Skip authentication if no token is configured (development mode)
+			c.Next()
+			return
+		}
+
+		_, password, ok := c.Request.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Basic realm="webhooks"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
-// verifySignature verifies the Twilio webhook signature
-func verifySignature(signature, secret, body, url string) bool {
-	// Create HMAC SHA256 hash
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(url + body))
-	expectedSignature := hex.EncodeToString(h.Sum(nil))
-	
-	return signature == expectedSignature
+// ServiceToken authenticates calls from internal services using a shared bearer token,
+// and marks them as "service_caller" so Guard does not count them. It fails closed:
+// without a token every request is rejected.
+func ServiceToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+			c.Abort()
+			return
+		}
+
+		c.Set("service_caller", true)
+		c.Next()
+	}
+}
+
+// TokenAuth requires an HS256 JWT signed with secret whose "role" claim names a known
+// role, and sets "admin_subject" and "auth_role" for RequireScope and the audit log.
+// Unlike webhook verification it fails closed: without a secret the admin API is disabled.
+func TokenAuth(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authenticateToken(c, secret) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticateToken verifies the bearer JWT of a request and stores its subject and role,
+// or writes the error response and returns false
+func authenticateToken(c *gin.Context, secret string) bool {
+	if secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Admin API is not configured"})
+		return false
+	}
+
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
+		return false
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return false
+	}
+
+	role, _ := claims["role"].(string)
+	if !ValidRole(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unknown role"})
+		return false
+	}
+
+	if subject, err := claims.GetSubject(); err == nil {
+		c.Set("admin_subject", subject)
+	}
+	c.Set("auth_role", role)
+	return true
+}
+
+// APIKeyAuthenticator resolves an API key to the tenant, key ID and role it belongs to.
+// ok is false for unknown, revoked and expired keys; err is for failed lookups.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, clientIP string) (tenant, keyID, role string, ok bool, err error)
+}
+
+// APIKeyAuth checks the tenant API key in the X-API-Key header and sets "api_key_tenant",
+// "api_key_id" and the key's "auth_role" on the context. When keys are not required, requests without one pass,
+// so clients can adopt keys before they are enforced, and hold keylessRole; a key that is sent must be valid.
+// Paths starting with an exempt prefix carry their own credentials and are skipped.
+// Lookups that fail for any other reason than a bad key fail closed.
+func APIKeyAuth(authenticator APIKeyAuthenticator, required bool, keylessRole string, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, prefix := range exempt {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		key := strings.TrimSpace(c.GetHeader("X-API-Key"))
+		if key == "" {
+			if required {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+				c.Abort()
+				return
+			}
+			c.Set("auth_role", keylessRole)
+			c.Next()
+			return
+		}
+
+		tenant, keyID, role, ok, err := authenticator.AuthenticateAPIKey(c.Request.Context(), key, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "API key check unavailable"})
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key_tenant", tenant)
+		c.Set("api_key_id", keyID)
+		c.Set("auth_role", role)
+		c.Next()
+	}
+}
+
+// verifySignature checks X-Twilio-Signature: the base64 HMAC-SHA1, keyed by the auth
+// token, of the URL followed by every form parameter name and value, sorted by name
+func verifySignature(signature, secret, webhookURL, contentType string, body []byte) bool {
+	var payload strings.Builder
+	payload.WriteString(webhookURL)
+	if contentType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return false
+		}
+		keys := make([]string, 0, len(form))
+		for key := range form {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, value := range form[key] {
+				payload.WriteString(key)
+				payload.WriteString(value)
+			}
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(payload.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// requestURL returns the URL the webhook was sent to: the configured public base URL
+// followed by the request path, or else the URL as seen through a TLS-terminating proxy
+func requestURL(c *gin.Context, baseURL string) string {
+	if baseURL != "" {
+		return strings.TrimRight(baseURL, "/") + c.Request.URL.RequestURI()
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
 }
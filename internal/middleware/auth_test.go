@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testWebhookURL    = "https://adapter.example.com/webhook/whatsapp"
+	testWebhookSecret = "12345"
+)
+
+// sign computes a Twilio signature independently of verifySignature
+func sign(secret, payload string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func testWebhookForm() url.Values {
+	form := url.Values{}
+	form.Set("MessageSid", "SM0000000000000000000000000000000a")
+	form.Set("From", "whatsapp:+15550000001")
+	form.Set("To", "whatsapp:+15550000002")
+	form.Set("Body", "hello there")
+	form.Set("NumMedia", "0")
+	return form
+}
+
+func TestVerifySignature(t *testing.T) {
+	form := testWebhookForm()
+	body := []byte(form.Encode())
+	formSignature := sign(testWebhookSecret, testWebhookURL+
+		"Bodyhello there"+
+		"Fromwhatsapp:+15550000001"+
+		"MessageSidSM0000000000000000000000000000000a"+
+		"NumMedia0"+
+		"Towhatsapp:+15550000002")
+
+	tampered := testWebhookForm()
+	tampered.Set("Body", "goodbye")
+
+	tests := []struct {
+		name        string
+		signature   string
+		secret      string
+		webhookURL  string
+		contentType string
+		body        []byte
+		want        bool
+	}{
+		{"form signed over sorted params", formSignature, testWebhookSecret, testWebhookURL, "application/x-www-form-urlencoded", body, true},
+		{"wrong secret", formSignature, "54321", testWebhookURL, "application/x-www-form-urlencoded", body, false},
+		{"different url", formSignature, testWebhookSecret, testWebhookURL + "?x=1", "application/x-www-form-urlencoded", body, false},
+		{"tampered param", formSignature, testWebhookSecret, testWebhookURL, "application/x-www-form-urlencoded", []byte(tampered.Encode()), false},
+		{"json signed over url only", sign(testWebhookSecret, testWebhookURL), testWebhookSecret, testWebhookURL, "application/json", []byte(`{"a":1}`), true},
+		{"empty signature", "", testWebhookSecret, testWebhookURL, "application/x-www-form-urlencoded", body, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifySignature(tt.signature, tt.secret, tt.webhookURL, tt.contentType, tt.body)
+			if got != tt.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkVerifySignature(b *testing.B) {
+	form := testWebhookForm()
+	form.Set("NumMedia", "1")
+	form.Set("MediaUrl0", "https://api.twilio.com/2010-04-01/Accounts/AC0/Messages/MM0/Media/ME0")
+	form.Set("MediaContentType0", "image/jpeg")
+	form.Set("ProfileName", "Benchmark")
+	form.Set("WaId", "15550000001")
+	body := []byte(form.Encode())
+
+	mac := hmac.New(sha1.New, []byte(testWebhookSecret))
+	payload := testWebhookURL
+	for _, key := range []string{"Body", "From", "MediaContentType0", "MediaUrl0", "MessageSid", "NumMedia", "ProfileName", "To", "WaId"} {
+		payload += key + form.Get(key)
+	}
+	mac.Write([]byte(payload))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if !verifySignature(signature, testWebhookSecret, testWebhookURL, "application/x-www-form-urlencoded", body) {
+		b.Fatal("signature does not verify")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		verifySignature(signature, testWebhookSecret, testWebhookURL, "application/x-www-form-urlencoded", body)
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseURL   string
+		target    string
+		host      string
+		forwarded string
+		want      string
+	}{
+		{"configured base", "https://wa.re9.ai", "/webhooks/whatsapp/messages?x=1", "10.0.0.7:8080", "", "https://wa.re9.ai/webhooks/whatsapp/messages?x=1"},
+		{"configured base with slash", "https://wa.re9.ai/", "/webhooks/whatsapp/status", "evil.example.com", "http", "https://wa.re9.ai/webhooks/whatsapp/status"},
+		{"rebuilt behind tls proxy", "", "/webhooks/whatsapp/messages", "wa.re9.ai", "https", "https://wa.re9.ai/webhooks/whatsapp/messages"},
+		{"rebuilt plain", "", "/webhooks/whatsapp/messages", "localhost:8080", "", "http://localhost:8080/webhooks/whatsapp/messages"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, tt.target, nil)
+			c.Request.Host = tt.host
+			if tt.forwarded != "" {
+				c.Request.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+
+			if got := requestURL(c, tt.baseURL); got != tt.want {
+				t.Errorf("requestURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "Bearer other", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"no token configured", "", "", http.StatusUnauthorized},
+		{"no token configured with header", "", "Bearer ", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/ai/transcriptions", ServiceToken(tt.token), func(c *gin.Context) {
+				if !c.GetBool("service_caller") {
+					t.Error("service_caller not set")
+				}
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/ai/transcriptions", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Faults decides which faults to inject into a request
+type Faults interface {
+	InjectDelay() time.Duration
+	InjectError() bool
+	InjectWebhookDrop() bool
+}
+
+// FaultInjection delays requests, fails API requests with 503 and acknowledges webhooks
+// without processing them, as configured on the fault injector. Health, metrics and
+// admin endpoints are never affected so faults can always be turned off again.
+func FaultInjection(faults Faults) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path == "/health" || path == "/ready" || path == "/metrics" || strings.HasPrefix(path, "/api/v1/admin") {
+			c.Next()
+			return
+		}
+
+		if delay := faults.InjectDelay(); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-c.Request.Context().Done():
+				c.AbortWithStatus(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		if strings.HasPrefix(path, "/webhooks/") {
+			if faults.InjectWebhookDrop() {
+				c.AbortWithStatus(http.StatusOK)
+				return
+			}
+		} else if faults.InjectError() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Injected fault"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Compress compresses responses with gzip or deflate, whichever the client prefers, when
+// their content type is one of types and their body reaches minSize bytes. Paths starting
+// with an exempt prefix, such as provider webhooks, are never compressed. Smaller bodies
+// are held until the handler finishes, larger ones are streamed through the compressor.
+func Compress(level, minSize int, types []string, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, prefix := range exempt {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			level:          level,
+			minSize:        minSize,
+			types:          types,
+			status:         http.StatusOK,
+		}
+		c.Writer = writer
+		defer func() {
+			writer.finish()
+			c.Writer = writer.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether to compress it
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	level    int
+	minSize  int
+	types    []string
+
+	status     int
+	buffer     bytes.Buffer
+	decided    bool
+	compressor io.WriteCloser // nil when the response is passed through
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	// The header is written once the body decides on compression
+}
+
+func (w *compressWriter) Status() int {
+	if !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || w.buffer.Len() > 0
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buffer.Write(data)
+		if w.buffer.Len() < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide writes the header, compressed when the response qualifies, and the buffered body
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+
+	if w.compressible() {
+		var err error
+		switch w.encoding {
+		case "gzip":
+			w.compressor, err = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		case "deflate":
+			w.compressor, err = flate.NewWriter(w.ResponseWriter, w.level)
+		}
+		if err != nil {
+			w.compressor = nil
+		} else {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			// The compressed body is no longer byte-identical to the tagged one
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	data := w.buffer.Bytes()
+	w.buffer = bytes.Buffer{}
+	if w.compressor != nil {
+		_, err := w.compressor.Write(data)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(data)
+	return err
+}
+
+// compressible reports whether the response may be compressed: it has a body of a listed
+// type that is not already encoded
+func (w *compressWriter) compressible() bool {
+	if w.buffer.Len() < w.minSize || w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(w.buffer.Bytes())
+	}
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, allowed := range w.types {
+		if strings.EqualFold(contentType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes what the handler left buffered and closes the compressor
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if w.buffer.Len() == 0 {
+			// Nothing was written; leave the header to gin so a bodiless status still goes out
+			w.decided = true
+			w.ResponseWriter.WriteHeader(w.status)
+			return
+		}
+		_ = w.decide()
+	}
+	if w.compressor != nil {
+		_ = w.compressor.Close()
+	}
+}
+
+// acceptedEncoding returns the encoding we support that an Accept-Encoding header ranks
+// highest, gzip on ties, or "" when the client accepts neither
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != "gzip" && name != "deflate" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
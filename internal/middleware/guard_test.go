@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordingGuard records observed calls and locks out the IPs in locked
+type recordingGuard struct {
+	locked   map[string]bool
+	observed []int
+}
+
+func (g *recordingGuard) Locked(ctx context.Context, clientIP, apiKey string) (time.Duration, bool) {
+	if g.locked[clientIP] {
+		return 90 * time.Second, true
+	}
+	return 0, false
+}
+
+func (g *recordingGuard) ObserveCall(clientIP, apiKey, route string, status int) {
+	g.observed = append(g.observed, status)
+}
+
+func TestGuard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		locked        bool
+		serviceCaller bool
+		status        int
+		wantStatus    int
+		wantObserved  []int
+	}{
+		{"observed once answered", false, false, http.StatusNotFound, http.StatusNotFound, []int{http.StatusNotFound}},
+		{"locked out", true, false, http.StatusOK, http.StatusTooManyRequests, nil},
+		{"service callers not counted", false, true, http.StatusUnauthorized, http.StatusUnauthorized, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &recordingGuard{locked: map[string]bool{"192.0.2.1": tt.locked}}
+			router := gin.New()
+			router.GET("/api/v1/messages/:id", Guard(guard), func(c *gin.Context) {
+				if tt.serviceCaller {
+					c.Set("service_caller", true)
+				}
+				c.Status(tt.status)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/messages/1", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			// Observed before the request returns, not by a stray goroutine
+			if len(guard.observed) != len(tt.wantObserved) || (len(tt.wantObserved) > 0 && guard.observed[0] != tt.wantObserved[0]) {
+				t.Errorf("observed %v, want %v", guard.observed, tt.wantObserved)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Webhook latency metrics
+var (
+	webhookResponsesTotal       = metrics.NewCounter("webhook_responses_total", "Webhook responses by route and whether they met the latency budget", "route", "budget")
+	webhookResponseSecondsTotal = metrics.NewCounter("webhook_response_seconds_total", "Time spent answering webhooks by route", "route")
+)
+
+// LatencyBudget measures webhook responses against budget, the time a provider waits
+// before it treats a webhook as failed and retries it
+func LatencyBudget(budget time.Duration, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		elapsed := time.Since(start)
+
+		route := c.FullPath()
+		webhookResponseSecondsTotal.Add(elapsed.Seconds(), route)
+		if elapsed <= budget {
+			webhookResponsesTotal.Inc(route, "within")
+			return
+		}
+
+		webhookResponsesTotal.Inc(route, "exceeded")
+		logger.WithFields(logrus.Fields{
+			"route":   route,
+			"latency": elapsed,
+			"budget":  budget,
+		}).Warn("Webhook response exceeded latency budget")
+	}
+}
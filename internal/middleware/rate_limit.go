@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript takes ARGV[4] tokens (0 only checks) from the bucket in KEYS[1],
+// refilled at ARGV[1] tokens per second up to ARGV[2] tokens, at time ARGV[3]
+// (milliseconds). It returns whether the bucket held a token and the tokens left, in
+// thousandths.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - cost
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate * 1000) + 1000)
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+// RateLimit allows each client IP perMinute requests per minute with bursts of up to
+// burst requests (a token bucket kept in Redis, so the limit holds across replicas),
+// with the client IP as gin resolves it behind trusted proxies. It goes before
+// authentication: requests presenting an API key are left to RateLimitAPIKeys once the
+// key has authenticated, and are only turned away here while their IP's bucket is empty.
+// A presented key that does not authenticate is charged to the IP afterwards, so
+// guessing keys is limited like any other unauthenticated traffic. A perMinute of 0
+// disables it, paths starting with an exempt prefix are not counted, and requests are
+// let through when Redis is unavailable. Counted responses carry X-RateLimit-* headers.
+// Buckets are kept under namespace, the adapter's Redis namespace.
+func RateLimit(redisClient *redis.Client, namespace string, perMinute, burst int, exempt ...string) gin.HandlerFunc {
+	limiter := newTokenBucket(redisClient, namespace, perMinute, burst)
+
+	return func(c *gin.Context) {
+		if perMinute <= 0 {
+			c.Next()
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		key := rateLimitIPKey(c)
+		if strings.TrimSpace(c.GetHeader("X-API-Key")) == "" {
+			if limiter.take(c, key, 1) {
+				c.Next()
+			}
+			return
+		}
+
+		if !limiter.allow(c, key) {
+			return
+		}
+		c.Next()
+		if c.GetString("api_key_id") == "" {
+			limiter.charge(c, key)
+		}
+	}
+}
+
+// RateLimitAPIKeys gives each authenticated API key its own bucket of the same size as
+// RateLimit's, so tenants behind one NAT or proxy do not share an allowance. It goes
+// after APIKeyAuth; requests without an authenticated key were counted by RateLimit.
+func RateLimitAPIKeys(redisClient *redis.Client, namespace string, perMinute, burst int) gin.HandlerFunc {
+	limiter := newTokenBucket(redisClient, namespace, perMinute, burst)
+
+	return func(c *gin.Context) {
+		key, ok := rateLimitAPIKey(c)
+		if perMinute <= 0 || !ok {
+			c.Next()
+			return
+		}
+		if limiter.take(c, key, 1) {
+			c.Next()
+		}
+	}
+}
+
+// rateLimitIPKey returns the bucket of a client IP
+func rateLimitIPKey(c *gin.Context) string {
+	return "ratelimit:" + c.ClientIP()
+}
+
+// rateLimitAPIKey returns the bucket of the API key a request authenticated with, which
+// is keyed by the key's ID so a random X-API-Key header cannot pick a fresh bucket
+func rateLimitAPIKey(c *gin.Context) (string, bool) {
+	keyID := c.GetString("api_key_id")
+	if keyID == "" {
+		return "", false
+	}
+	return "ratelimit:key:" + keyID, true
+}
+
+// tokenBucket runs tokenBucketScript with one rate and burst
+type tokenBucket struct {
+	redis     *redis.Client
+	namespace string
+	perMinute int
+	burst     int
+	rate      float64
+}
+
+func newTokenBucket(redisClient *redis.Client, namespace string, perMinute, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		redis:     redisClient,
+		namespace: namespace,
+		perMinute: perMinute,
+		burst:     burst,
+		rate:      float64(perMinute) / 60,
+	}
+}
+
+// run applies cost tokens to a bucket; ok is false when Redis is unavailable
+func (b *tokenBucket) run(c *gin.Context, key string, cost int) (allowed bool, tokens float64, ok bool) {
+	result, err := tokenBucketScript.Run(c.Request.Context(), b.redis,
+		[]string{b.namespace + ":" + key}, b.rate, b.burst, time.Now().UnixMilli(), cost).Int64Slice()
+	if err != nil || len(result) != 2 {
+		return true, 0, false
+	}
+	return result[0] == 1, float64(result[1]) / 1000, true
+}
+
+// take takes cost tokens from a bucket, and answers 429 and returns false when it is empty
+func (b *tokenBucket) take(c *gin.Context, key string, cost int) bool {
+	allowed, tokens, ok := b.run(c, key, cost)
+	if !ok {
+		return true
+	}
+
+	// Let clients pace themselves: the bucket size, the whole requests left in it and
+	// the seconds until it is full again
+	c.Header("X-RateLimit-Limit", strconv.Itoa(b.burst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(int(math.Floor(tokens))))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(b.burst)-tokens)/b.rate))))
+
+	if !allowed {
+		b.reject(c, tokens)
+		return false
+	}
+	return true
+}
+
+// allow answers 429 and returns false when a bucket is empty, without taking a token
+func (b *tokenBucket) allow(c *gin.Context, key string) bool {
+	allowed, tokens, ok := b.run(c, key, 0)
+	if ok && !allowed {
+		b.reject(c, tokens)
+		return false
+	}
+	return true
+}
+
+// charge takes a token for a request that has already been answered
+func (b *tokenBucket) charge(c *gin.Context, key string) {
+	b.run(c, key, 1)
+}
+
+func (b *tokenBucket) reject(c *gin.Context, tokens float64) {
+	retryAfter := int(math.Ceil((1 - tokens) / b.rate))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":               "Rate limit exceeded",
+		"retry_after_seconds": retryAfter,
+		"limit_per_minute":    b.perMinute,
+		"burst":               b.burst,
+		"detail": fmt.Sprintf("Up to %d requests may be sent at once; the allowance then refills at %d requests per minute. "+
+			"Pace requests using the X-RateLimit-Remaining and X-RateLimit-Reset headers.", b.burst, b.perMinute),
+	})
+	c.Abort()
+}
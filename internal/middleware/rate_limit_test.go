@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		keyID   string
+		wantKey string
+		wantOK  bool
+	}{
+		{"authenticated key", "rk_live_abc", "0b6d6c1e-2f4c-4a53-9d3c-0f6c1b5f7e21", "ratelimit:key:0b6d6c1e-2f4c-4a53-9d3c-0f6c1b5f7e21", true},
+		{"unauthenticated header", "rk_live_random", "", "", false},
+		{"keyless", "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/messages", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("X-API-Key", tt.header)
+			}
+			if tt.keyID != "" {
+				c.Set("api_key_id", tt.keyID)
+			}
+
+			key, ok := rateLimitAPIKey(c)
+			if key != tt.wantKey || ok != tt.wantOK {
+				t.Errorf("rateLimitAPIKey() = %q, %v, want %q, %v", key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
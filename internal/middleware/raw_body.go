@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// rawBodyKey is the gin context key of the captured request body
+const rawBodyKey = "raw_body"
+
+// webhookEventKey is the request context key of the recorded webhook's ID
+type webhookEventKey struct{}
+
+// WebhookRecorder keeps raw webhook requests for audit
+type WebhookRecorder interface {
+	RecordWebhook(id uuid.UUID, source string, r *http.Request, body []byte, status int)
+}
+
+// CaptureRawBody reads the request body once and keeps it on the context, so signature
+// verification and binding both see the bytes that were sent. Bodies over maxBytes are
+// rejected with 413. When recorder is not nil the request and the response status are
+// recorded after the handler ran, under an ID chosen up front and put on the request
+// context (see WebhookEventID).
+func CaptureRawBody(source string, maxBytes int, recorder WebhookRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes))
+		}
+
+		body, err := RawBody(c)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+
+		if recorder == nil {
+			c.Next()
+			return
+		}
+
+		id := uuid.New()
+		c.Request = c.Request.WithContext(WithWebhookEventID(c.Request.Context(), &id))
+
+		c.Next()
+
+		recorder.RecordWebhook(id, source, c.Request, body, c.Writer.Status())
+	}
+}
+
+// WithWebhookEventID returns ctx carrying the ID of the recorded webhook being processed
+func WithWebhookEventID(ctx context.Context, id *uuid.UUID) context.Context {
+	if id == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, webhookEventKey{}, *id)
+}
+
+// WebhookEventID returns the ID the webhook of ctx is recorded under, or nil when it is
+// not recorded
+func WebhookEventID(ctx context.Context) *uuid.UUID {
+	if id, ok := ctx.Value(webhookEventKey{}).(uuid.UUID); ok {
+		return &id
+	}
+	return nil
+}
+
+// RawBody returns the request body, reading it on first use. The request body is reset
+// every time, so it can still be bound afterwards.
+func RawBody(c *gin.Context) ([]byte, error) {
+	if cached, ok := c.Get(rawBodyKey); ok {
+		body := cached.([]byte)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return body, nil
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return nil, err
+		}
+	}
+	c.Set(rawBodyKey, body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
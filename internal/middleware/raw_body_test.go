@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCaptureRawBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"under the limit", strings.Repeat("a", 15), http.StatusOK},
+		{"at the limit", strings.Repeat("a", 16), http.StatusOK},
+		{"over the limit", strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+		{"empty", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var captured string
+			router := gin.New()
+			router.POST("/webhook", CaptureRawBody("twilio", 16, nil), func(c *gin.Context) {
+				body, err := RawBody(c)
+				if err != nil {
+					t.Errorf("RawBody() error = %v", err)
+				}
+				captured = string(body)
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && captured != tt.body {
+				t.Errorf("handler saw %q, want %q", captured, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoleHasScope(t *testing.T) {
+	tests := []struct {
+		role                       string
+		read, send, operate, admin bool
+	}{
+		{RoleReadOnly, true, false, false, false},
+		{RoleSender, true, true, false, false},
+		{RoleOperator, true, true, true, false},
+		{RoleAdmin, true, true, true, true},
+		{"superuser", false, false, false, false},
+		{"", false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			want := map[string]bool{ScopeRead: tt.read, ScopeSend: tt.send, ScopeOperate: tt.operate, ScopeAdmin: tt.admin}
+			for scope, granted := range want {
+				if got := RoleHasScope(tt.role, scope); got != granted {
+					t.Errorf("RoleHasScope(%q, %q) = %v, want %v", tt.role, scope, got, granted)
+				}
+			}
+			if got, wantValid := ValidRole(tt.role), tt.read; got != wantValid {
+				t.Errorf("ValidRole(%q) = %v, want %v", tt.role, got, wantValid)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		role       string
+		setRole    bool
+		scope      string
+		wantStatus int
+	}{
+		{"granted", RoleOperator, true, ScopeOperate, http.StatusOK},
+		{"admin scope for admin", RoleAdmin, true, ScopeAdmin, http.StatusOK},
+		{"admin scope for operator", RoleOperator, true, ScopeAdmin, http.StatusForbidden},
+		{"send scope for readonly", RoleReadOnly, true, ScopeSend, http.StatusForbidden},
+		{"unknown role", "superuser", true, ScopeRead, http.StatusForbidden},
+		{"unauthenticated", "", false, ScopeRead, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/resource", func(c *gin.Context) {
+				if tt.setRole {
+					c.Set("auth_role", tt.role)
+				}
+				c.Next()
+			}, RequireScope(tt.scope), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/resource", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookMirror sends copies of webhook requests to a shadow deployment
+type WebhookMirror interface {
+	MirrorWebhook(source string, r *http.Request, body []byte)
+}
+
+// MirrorWebhooks hands every webhook request this deployment accepted to mirror once
+// it has been answered, so mirroring adds no latency and rejected requests are not
+// passed on. A nil mirror does nothing.
+func MirrorWebhooks(source string, mirror WebhookMirror) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mirror == nil {
+			c.Next()
+			return
+		}
+
+		body, err := RawBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() < http.StatusBadRequest {
+			mirror.MirrorWebhook(source, c.Request, body)
+		}
+	}
+}
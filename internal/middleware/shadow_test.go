@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordingMirror keeps the bodies of the webhooks it is handed
+type recordingMirror struct {
+	bodies []string
+}
+
+func (m *recordingMirror) MirrorWebhook(source string, r *http.Request, body []byte) {
+	m.bodies = append(m.bodies, string(body))
+}
+
+func TestMirrorWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		status     int
+		wantMirror bool
+	}{
+		{"accepted", http.StatusOK, true},
+		{"accepted without content", http.StatusNoContent, true},
+		{"rejected signature", http.StatusForbidden, false},
+		{"failed", http.StatusInternalServerError, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mirror := &recordingMirror{}
+			var bound string
+
+			router := gin.New()
+			router.POST("/webhook", MirrorWebhooks("twilio", mirror), func(c *gin.Context) {
+				bound = c.PostForm("Body")
+				c.Status(tt.status)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("Body=hi"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if bound != "hi" {
+				t.Errorf("handler read Body = %q, want the body still readable", bound)
+			}
+			if got := len(mirror.bodies) == 1 && mirror.bodies[0] == "Body=hi"; got != tt.wantMirror {
+				t.Errorf("mirrored %v, want mirrored = %v", mirror.bodies, tt.wantMirror)
+			}
+		})
+	}
+}
+
+func TestMirrorWebhooksNil(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/webhook", MirrorWebhooks("twilio", nil), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("Body=hi")))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AgentPresence is an agent's availability for new conversations
+type AgentPresence string
+
+const (
+	AgentPresenceOnline  AgentPresence = "online"  // takes auto-assigned conversations
+	AgentPresenceAway    AgentPresence = "away"    // keeps conversations, gets no new ones
+	AgentPresenceOffline AgentPresence = "offline" // keeps conversations, gets no new ones
+)
+
+// Valid reports whether p is a known presence
+func (p AgentPresence) Valid() bool {
+	switch p {
+	case AgentPresenceOnline, AgentPresenceAway, AgentPresenceOffline:
+		return true
+	}
+	return false
+}
+
+// How a conversation was assigned to an agent
+const (
+	AssignmentMethodClaim = "claim"
+	AssignmentMethodAuto  = "auto"
+)
+
+// Agent is a human who handles conversations handed off from the orchestrator
+type Agent struct {
+	ID             uuid.UUID     `json:"id" db:"id"`
+	Name           string        `json:"name" db:"name"`
+	Email          string        `json:"email" db:"email"`
+	Presence       AgentPresence `json:"presence" db:"presence"`
+	MaxConcurrent  int           `json:"max_concurrent" db:"max_concurrent"` // 0 means no limit
+	IsActive       bool          `json:"is_active" db:"is_active"`
+	LastAssignedAt *time.Time    `json:"last_assigned_at,omitempty" db:"last_assigned_at"`
+	LastSeenAt     *time.Time    `json:"last_seen_at,omitempty" db:"last_seen_at"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+
+	// Active conversations assigned to the agent
+	ActiveConversations int `json:"active_conversations" db:"-"`
+}
+
+// AgentRequest creates an agent
+type AgentRequest struct {
+	Name          string `json:"name" binding:"required"`
+	Email         string `json:"email" binding:"required,email"`
+	MaxConcurrent *int   `json:"max_concurrent,omitempty"`
+}
+
+// AgentPresenceRequest updates an agent's presence; the console also sends it as a heartbeat
+type AgentPresenceRequest struct {
+	Presence AgentPresence `json:"presence" binding:"required"`
+}
+
+// ClaimSessionRequest assigns a conversation to an agent
+type ClaimSessionRequest struct {
+	AgentID uuid.UUID `json:"agent_id" binding:"required"`
+}
+
+// ReleaseSessionRequest gives a conversation back. With ResumeBot the orchestrator
+// takes over again; otherwise the conversation waits for the next available agent.
+type ReleaseSessionRequest struct {
+	AgentID   uuid.UUID `json:"agent_id" binding:"required"`
+	ResumeBot bool      `json:"resume_bot,omitempty"`
+}
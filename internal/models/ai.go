@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AIAnalysisType identifies which AI pipeline produced a result
+type AIAnalysisType string
+
+const (
+	AIAnalysisDocument AIAnalysisType = "document"
+	AIAnalysisImage    AIAnalysisType = "image"
+	AIAnalysisAudio    AIAnalysisType = "audio"
+)
+
+// AI result statuses
+const (
+	AIResultStatusCompleted = "completed"
+	AIResultStatusFailed    = "failed"
+)
+
+// AIResult represents a stored analysis result for a message
+type AIResult struct {
+	ID           uuid.UUID              `json:"id" db:"id"`
+	MessageID    uuid.UUID              `json:"message_id" db:"message_id"`
+	AnalysisType AIAnalysisType         `json:"analysis_type" db:"analysis_type"`
+	Status       string                 `json:"status" db:"status"`
+	Result       map[string]interface{} `json:"result,omitempty" db:"result"`
+	Error        *string                `json:"error,omitempty" db:"error"`
+	Model        *string                `json:"model,omitempty" db:"model"`
+	ReceivedAt   time.Time              `json:"received_at" db:"received_at"`
+	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+// AIResultCallback represents an analysis result posted back by the AI processing service
+type AIResultCallback struct {
+	MessageID    string                 `json:"message_id" binding:"required"`
+	AnalysisType AIAnalysisType         `json:"analysis_type" binding:"required,oneof=document image audio"`
+	Status       string                 `json:"status" binding:"omitempty,oneof=completed failed"`
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Model        string                 `json:"model,omitempty"`
+}
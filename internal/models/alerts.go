@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Twilio alert levels
+const (
+	AlertLevelError   = "error"
+	AlertLevelWarning = "warning"
+)
+
+// TwilioAlertWebhook is the form Twilio's debugger webhook posts for each alert
+type TwilioAlertWebhook struct {
+	Sid              string `form:"Sid"`
+	AccountSid       string `form:"AccountSid"`
+	ParentAccountSid string `form:"ParentAccountSid"`
+	Timestamp        string `form:"Timestamp"`
+	Level            string `form:"Level"`
+	PayloadType      string `form:"PayloadType"`
+	Payload          string `form:"Payload"`
+}
+
+// TwilioAlertPayload is the JSON document carried in an alert's Payload field
+type TwilioAlertPayload struct {
+	ResourceSID string `json:"resource_sid"`
+	ServiceSID  string `json:"service_sid"`
+	ErrorCode   string `json:"error_code"`
+	MoreInfo    struct {
+		Msg string `json:"Msg"`
+	} `json:"more_info"`
+}
+
+// TwilioAlert is a stored Twilio alert, linked to our message and number when the
+// alert concerns a message we sent or received
+type TwilioAlert struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	AlertSID    string          `json:"alert_sid" db:"alert_sid"`
+	AccountSID  string          `json:"account_sid" db:"account_sid"`
+	Level       string          `json:"level" db:"level"`
+	ErrorCode   *string         `json:"error_code,omitempty" db:"error_code"`
+	Description *string         `json:"description,omitempty" db:"description"`
+	ResourceSID *string         `json:"resource_sid,omitempty" db:"resource_sid"`
+	MessageID   *uuid.UUID      `json:"message_id,omitempty" db:"message_id"`
+	PhoneNumber *string         `json:"phone_number,omitempty" db:"phone_number"`
+	Payload     json.RawMessage `json:"payload,omitempty" db:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at" db:"occurred_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
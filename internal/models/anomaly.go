@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Failure spike rules checked by the anomaly detector
+const (
+	AnomalyRuleOutboundFailures   = "outbound_failure_rate"
+	AnomalyRuleOrchestratorErrors = "orchestrator_error_rate"
+)
+
+// AnomalyAlert describes a failure rate that crossed its threshold
+type AnomalyAlert struct {
+	ID              uuid.UUID      `json:"id"`
+	Rule            string         `json:"rule"`
+	Summary         string         `json:"summary"`
+	Rate            float64        `json:"rate"`
+	Threshold       float64        `json:"threshold"`
+	Failures        int            `json:"failures"`
+	Total           int            `json:"total"`
+	Window          string         `json:"window"`
+	TopErrorCodes   []AnomalyCount `json:"top_error_codes,omitempty"`
+	AffectedSenders []AnomalyCount `json:"affected_senders,omitempty"`
+	DetectedAt      time.Time      `json:"detected_at"`
+}
+
+// AnomalyCount is how often a value (error code, sender number) occurred in the window
+type AnomalyCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
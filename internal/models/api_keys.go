@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key states, derived from a key's revocation and expiry
+const (
+	APIKeyStatusActive  = "active"
+	APIKeyStatusExpired = "expired" // past expires_at, e.g. the end of a rotation window
+	APIKeyStatusRevoked = "revoked"
+)
+
+// APIKey is a tenant's key for the /api/v1 API. Only a hash of the key is stored; the key
+// itself is returned once, when it is created or rotated.
+type APIKey struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Tenant      string     `json:"tenant" db:"tenant"`
+	Name        string     `json:"name" db:"name"`
+	Prefix      string     `json:"prefix" db:"prefix"` // the start of the key, to tell keys apart
+	Role        string     `json:"role" db:"role"`
+	Status      string     `json:"status" db:"-"`
+	CreatedBy   string     `json:"created_by,omitempty" db:"created_by"`
+	RotatedFrom *uuid.UUID `json:"rotated_from,omitempty" db:"rotated_from"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP  *string    `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// IssuedAPIKey is a newly created or rotated key together with its secret value
+type IssuedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKeyRequest creates a key for a tenant
+type CreateAPIKeyRequest struct {
+	Tenant    string     `json:"tenant" binding:"required"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"` // readonly, sender or operator; operator when omitted
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// RotateAPIKeyRequest replaces a key; the old key keeps working for GraceHours, or the
+// configured rotation grace period when omitted
+type RotateAPIKeyRequest struct {
+	GraceHours *float64 `json:"grace_hours" binding:"omitempty,gte=0"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReferenceKindAppointment marks a reference to an appointment proposed in a conversation
+const ReferenceKindAppointment = "appointment"
+
+// ReferenceSourceAppointment marks references stored when an appointment confirmation is sent
+const ReferenceSourceAppointment = "appointment"
+
+// AppointmentStatus is the state of an appointment
+type AppointmentStatus string
+
+const (
+	AppointmentStatusPending   AppointmentStatus = "pending" // confirmation sent, awaiting the user's answer
+	AppointmentStatusConfirmed AppointmentStatus = "confirmed"
+	AppointmentStatusDeclined  AppointmentStatus = "declined"
+	AppointmentStatusFailed    AppointmentStatus = "failed" // the confirmation could not be sent
+)
+
+// Button payloads of the confirmation template's quick replies. A payload may carry the
+// appointment ID after a colon, e.g. "appointment_confirm:<id>".
+const (
+	AppointmentPayloadConfirm = "appointment_confirm"
+	AppointmentPayloadDecline = "appointment_decline"
+)
+
+// AppointmentIntent is the scheduling intent the orchestrator sends in the "appointment"
+// context key of a response with the schedule_appointment next action
+type AppointmentIntent struct {
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Location  string     `json:"location,omitempty"`
+	ListingID string     `json:"listing_id,omitempty"`
+}
+
+// Appointment is an appointment proposed to a user and their answer
+type Appointment struct {
+	ID                 uuid.UUID         `json:"id" db:"id"`
+	SessionID          *uuid.UUID        `json:"session_id,omitempty" db:"session_id"`
+	MessageID          *uuid.UUID        `json:"message_id,omitempty" db:"message_id"` // the confirmation message
+	To                 string            `json:"to" db:"to_number"`
+	Channel            Channel           `json:"channel" db:"channel"`
+	Title              string            `json:"title" db:"title"`
+	Location           string            `json:"location,omitempty" db:"location"`
+	ListingID          *string           `json:"listing_id,omitempty" db:"listing_id"`
+	StartsAt           time.Time         `json:"starts_at" db:"starts_at"`
+	EndsAt             *time.Time        `json:"ends_at,omitempty" db:"ends_at"`
+	Status             AppointmentStatus `json:"status" db:"status"`
+	RespondedAt        *time.Time        `json:"responded_at,omitempty" db:"responded_at"`
+	CalendarNotifiedAt *time.Time        `json:"calendar_notified_at,omitempty" db:"calendar_notified_at"`
+	CreatedAt          time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at" db:"updated_at"`
+}
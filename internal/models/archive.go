@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversationArchive records a conversation whose messages moved to cold storage
+type ConversationArchive struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	SessionID      uuid.UUID `json:"session_id" db:"session_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	ObjectKey      string    `json:"object_key" db:"object_key"`
+	MessageCount   int       `json:"message_count" db:"message_count"`
+	FirstMessageAt time.Time `json:"first_message_at" db:"first_message_at"`
+	LastMessageAt  time.Time `json:"last_message_at" db:"last_message_at"`
+	ArchivedAt     time.Time `json:"archived_at" db:"archived_at"`
+}
+
+// ArchivedConversation is the document stored in cold storage for one conversation;
+// messages are in chronological order
+type ArchivedConversation struct {
+	SessionID  uuid.UUID          `json:"session_id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	ArchivedAt time.Time          `json:"archived_at"`
+	Messages   []*WhatsAppMessage `json:"messages"`
+}
+
+// MessageHistoryPage is one page of a user's message history, newest first
+type MessageHistoryPage struct {
+	Messages []*WhatsAppMessage `json:"messages"`
+	Archived bool               `json:"archived"` // some messages were read from cold storage
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	AuditActionMessageRedacted  = "message.redacted"
+	AuditActionUsersMerged      = "users.merged"
+	AuditActionPhonesMerged     = "phones.merged"
+	AuditActionMessagesImported = "messages.imported"
+	AuditActionBundleImported   = "bundle.imported"
+	AuditActionAPIKeyCreated    = "api_key.created"
+	AuditActionAPIKeyRotated    = "api_key.rotated"
+	AuditActionAPIKeyRevoked    = "api_key.revoked"
+	AuditActionSecurityLockout  = "security.lockout"
+	AuditActionSecurityUnlocked = "security.unlocked"
+)
+
+// AuditEntry records an administrative action for later review
+type AuditEntry struct {
+	ID         uuid.UUID              `json:"id" db:"id"`
+	Actor      string                 `json:"actor" db:"actor"`
+	ActorRole  string                 `json:"actor_role,omitempty" db:"actor_role"`
+	ClientIP   string                 `json:"client_ip,omitempty" db:"client_ip"`
+	Action     string                 `json:"action" db:"action"`
+	TargetType string                 `json:"target_type" db:"target_type"`
+	TargetID   string                 `json:"target_id" db:"target_id"`
+	Details    map[string]interface{} `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time              `json:"created_at" db:"created_at"`
+}
+
+// RedactMessageRequest represents a request to redact a message's content
+type RedactMessageRequest struct {
+	Reason string `json:"reason"`
+}
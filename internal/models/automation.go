@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How an automation matches inbound text
+const (
+	AutomationMatchKeyword = "keyword" // whole words, case-insensitive
+	AutomationMatchRegex   = "regex"
+)
+
+// What an automation does when it matches
+const (
+	AutomationActionSendTemplate    = "send_template"
+	AutomationActionTagConversation = "tag_conversation"
+	AutomationActionOpenHandoff     = "open_handoff"
+)
+
+// Automation is a keyword or regex rule evaluated on inbound messages before the
+// orchestrator is called. Tenant is the business number or address that received the
+// message; rules without a tenant apply to every number.
+type Automation struct {
+	ID                uuid.UUID         `json:"id" db:"id"`
+	Name              string            `json:"name" db:"name"`
+	Tenant            string            `json:"tenant,omitempty" db:"tenant"`
+	Channel           Channel           `json:"channel,omitempty" db:"channel"`
+	MatchType         string            `json:"match_type" db:"match_type"`
+	Pattern           string            `json:"pattern" db:"pattern"`
+	Action            string            `json:"action" db:"action"`
+	TemplateSID       string            `json:"template_sid,omitempty" db:"template_sid"`
+	TemplateVariables map[string]string `json:"template_variables,omitempty" db:"template_variables"`
+	Tags              []string          `json:"tags,omitempty" db:"tags"`
+	Priority          int               `json:"priority" db:"priority"`
+	IsActive          bool              `json:"is_active" db:"is_active"`
+	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// AutomationRequest creates or updates an automation. On update, nil fields are left
+// unchanged.
+type AutomationRequest struct {
+	Name              *string           `json:"name"`
+	Tenant            *string           `json:"tenant"`
+	Channel           *Channel          `json:"channel"`
+	MatchType         *string           `json:"match_type"`
+	Pattern           *string           `json:"pattern"`
+	Action            *string           `json:"action"`
+	TemplateSID       *string           `json:"template_sid"`
+	TemplateVariables map[string]string `json:"template_variables"`
+	Tags              []string          `json:"tags"`
+	Priority          *int              `json:"priority"`
+	IsActive          *bool             `json:"is_active"`
+}
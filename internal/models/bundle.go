@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// BundleVersion is the configuration bundle format this build writes and reads
+const BundleVersion = 1
+
+// What importing a bundle does to one item
+const (
+	BundleChangeCreate = "create"
+	BundleChangeUpdate = "update"
+	BundleChangeDelete = "delete"
+)
+
+// Kinds of bundle items
+const (
+	BundleKindAutomation = "automation"
+	BundleKindTemplate   = "template"
+	BundleKindPolicy     = "policy"
+)
+
+// ConfigBundle carries the message templates, automations and policies of one
+// environment to another. Automations are matched by name, templates and policies by
+// their configuration key.
+type ConfigBundle struct {
+	Version     int                 `json:"version"`
+	Environment string              `json:"environment"`
+	ExportedAt  time.Time           `json:"exported_at"`
+	Templates   map[string]string   `json:"templates"`
+	Automations []*BundleAutomation `json:"automations"`
+	Policies    map[string]string   `json:"policies"`
+}
+
+// BundleAutomation is an automation without the fields that differ per environment
+type BundleAutomation struct {
+	Name              string            `json:"name"`
+	Tenant            string            `json:"tenant,omitempty"`
+	Channel           Channel           `json:"channel,omitempty"`
+	MatchType         string            `json:"match_type"`
+	Pattern           string            `json:"pattern"`
+	Action            string            `json:"action"`
+	TemplateSID       string            `json:"template_sid,omitempty"`
+	TemplateVariables map[string]string `json:"template_variables,omitempty"`
+	Tags              []string          `json:"tags,omitempty"`
+	Priority          int               `json:"priority"`
+	IsActive          bool              `json:"is_active"`
+}
+
+// BundleChange is one difference between a bundle and the environment it is imported
+// into. Template and policy changes are never applied: they come from the target's
+// configuration, which has to be changed there.
+type BundleChange struct {
+	Kind    string   `json:"kind"`
+	Key     string   `json:"key"`
+	Change  string   `json:"change"`
+	Fields  []string `json:"fields,omitempty"` // changed automation fields
+	Current string   `json:"current,omitempty"`
+	Bundle  string   `json:"bundle,omitempty"`
+	Applied bool     `json:"applied"`
+}
+
+// BundleImportResult lists the changes an import made, or would make on a dry run
+type BundleImportResult struct {
+	DryRun  bool           `json:"dry_run"`
+	Changes []BundleChange `json:"changes"`
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CampaignStatus is the lifecycle state of a campaign
+type CampaignStatus string
+
+const (
+	CampaignStatusDraft     CampaignStatus = "draft"     // created, not yet scheduled
+	CampaignStatusScheduled CampaignStatus = "scheduled" // waiting for scheduled_at
+	CampaignStatusRunning   CampaignStatus = "running"
+	CampaignStatusPaused    CampaignStatus = "paused"
+	CampaignStatusCompleted CampaignStatus = "completed" // every recipient was sent, skipped or failed
+)
+
+// CampaignRecipientStatus is the state of one recipient of a campaign
+type CampaignRecipientStatus string
+
+const (
+	CampaignRecipientPending CampaignRecipientStatus = "pending"
+	CampaignRecipientSending CampaignRecipientStatus = "sending"
+	CampaignRecipientSent    CampaignRecipientStatus = "sent"
+	CampaignRecipientSkipped CampaignRecipientStatus = "skipped" // suppressed, capped or a duplicate
+	CampaignRecipientCapped  CampaignRecipientStatus = "capped"  // over the campaign frequency cap
+	CampaignRecipientFailed  CampaignRecipientStatus = "failed"
+)
+
+// Campaign is a broadcast of one message to an audience, sent at a throttled rate once
+// it is scheduled. A list of recipients is snapshotted when the campaign is created; a
+// segment's members are snapshotted when the campaign launches.
+type Campaign struct {
+	ID            uuid.UUID         `json:"id" db:"id"`
+	Name          string            `json:"name" db:"name"`
+	Tenant        string            `json:"tenant,omitempty" db:"tenant"` // of the API key that created it
+	Status        CampaignStatus    `json:"status" db:"status"`
+	Channel       Channel           `json:"channel" db:"channel"`
+	Provider      string            `json:"provider,omitempty" db:"provider"`
+	Type          MessageType       `json:"type" db:"message_type"`
+	Content       string            `json:"content,omitempty" db:"content"`
+	MediaURL      *string           `json:"media_url,omitempty" db:"media_url"`
+	MediaType     *string           `json:"media_type,omitempty" db:"media_type"`
+	Template      *string           `json:"template,omitempty" db:"template"`
+	Variables     map[string]string `json:"variables,omitempty" db:"variables"`
+	Category      *string           `json:"category,omitempty" db:"category"`
+	Variants      []CampaignVariant `json:"variants,omitempty" db:"variants"`
+	RatePerMinute *int              `json:"rate_per_minute,omitempty" db:"rate_per_minute"` // overrides CAMPAIGN_RATE_PER_MINUTE
+	SegmentID     *uuid.UUID        `json:"segment_id,omitempty" db:"segment_id"`
+	AudienceSize  int               `json:"audience_size" db:"audience_size"` // 0 until a segment campaign launches
+	ScheduledAt   *time.Time        `json:"scheduled_at,omitempty" db:"scheduled_at"`
+	StartedAt     *time.Time        `json:"started_at,omitempty" db:"started_at"`
+	PausedAt      *time.Time        `json:"paused_at,omitempty" db:"paused_at"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedBy     string            `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt     time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// SendRequest is the send of the campaign's message to one recipient, in the given
+// variant when the campaign tests several
+func (c *Campaign) SendRequest(to string, variant *CampaignVariant) *SendMessageRequest {
+	request := &SendMessageRequest{
+		To:        to,
+		Content:   c.Content,
+		Type:      c.Type,
+		MediaURL:  c.MediaURL,
+		MediaType: c.MediaType,
+		Variables: c.Variables,
+		Template:  c.Template,
+		Channel:   c.Channel,
+		Provider:  c.Provider,
+		Category:  c.Category,
+	}
+	if variant != nil {
+		variant.Apply(request)
+	}
+	return request
+}
+
+// CampaignVariant is one version of a campaign's message in an A/B test. Recipients are
+// split between the variants in proportion to their weights.
+type CampaignVariant struct {
+	Name      string            `json:"name"`
+	Weight    int               `json:"weight"`
+	Content   string            `json:"content,omitempty"`
+	Template  *string           `json:"template,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// Apply replaces the content and template of a send with the variant's, and merges the
+// variant's variables over the send's
+func (v *CampaignVariant) Apply(request *SendMessageRequest) {
+	if v.Content != "" {
+		request.Content = v.Content
+	}
+	if v.Template != nil {
+		request.Template = v.Template
+	}
+	if len(v.Variables) > 0 {
+		variables := make(map[string]string, len(request.Variables)+len(v.Variables))
+		for name, value := range request.Variables {
+			variables[name] = value
+		}
+		for name, value := range v.Variables {
+			variables[name] = value
+		}
+		request.Variables = variables
+	}
+}
+
+// CreateCampaignRequest creates a draft campaign of one message to either a list of
+// recipients or the members of a segment
+type CreateCampaignRequest struct {
+	Name          string            `json:"name" binding:"required"`
+	Recipients    []string          `json:"recipients,omitempty"`
+	SegmentID     *uuid.UUID        `json:"segment_id,omitempty"`
+	Content       string            `json:"content"`
+	Type          MessageType       `json:"type"`
+	MediaURL      *string           `json:"media_url,omitempty"`
+	MediaType     *string           `json:"media_type,omitempty"`
+	Variables     map[string]string `json:"variables,omitempty"`
+	Template      *string           `json:"template,omitempty"`
+	Channel       Channel           `json:"channel,omitempty"`
+	Provider      string            `json:"provider,omitempty"`
+	Category      *string           `json:"category,omitempty"`
+	Variants      []CampaignVariant `json:"variants,omitempty"` // at least two, to A/B test them
+	RatePerMinute *int              `json:"rate_per_minute,omitempty"`
+}
+
+// ScheduleCampaignRequest schedules a draft campaign; without scheduled_at it starts at
+// once. A rate_per_minute replaces the campaign's throttle.
+type ScheduleCampaignRequest struct {
+	ScheduledAt   *time.Time `json:"scheduled_at,omitempty"`
+	RatePerMinute *int       `json:"rate_per_minute,omitempty"`
+}
+
+// CampaignRecipient is one recipient of a campaign's audience snapshot
+type CampaignRecipient struct {
+	CampaignID  uuid.UUID               `json:"campaign_id" db:"campaign_id"`
+	To          string                  `json:"to" db:"recipient"`
+	Status      CampaignRecipientStatus `json:"status" db:"status"`
+	Variant     *string                 `json:"variant,omitempty" db:"variant"`
+	Timezone    *string                 `json:"timezone,omitempty" db:"timezone"`
+	ScheduledAt *time.Time              `json:"scheduled_at,omitempty" db:"send_after"` // deferred past the recipient's quiet hours
+	MessageID   *uuid.UUID              `json:"message_id,omitempty" db:"message_id"`
+	Error       *string                 `json:"error,omitempty" db:"error"`
+	SentAt      *time.Time              `json:"sent_at,omitempty" db:"sent_at"`
+}
+
+// CampaignReport is the delivery and engagement funnel of a campaign. Each stage
+// counts recipients: read ones were also delivered, and replies and clicks count the
+// recipients who replied within CAMPAIGN_REPLY_WINDOW or clicked a tracked link.
+type CampaignReport struct {
+	CampaignID uuid.UUID      `json:"campaign_id"`
+	Status     CampaignStatus `json:"status"`
+	Audience   int64          `json:"audience"`
+	Pending    int64          `json:"pending"`  // includes recipients being sent
+	Deferred   int64          `json:"deferred"` // pending until their quiet hours end
+	Skipped    int64          `json:"skipped"`
+	Capped     int64          `json:"capped"`
+	Failed     int64          `json:"failed"` // not sent, or rejected by the provider later
+	Sent       int64          `json:"sent"`
+	Delivered  int64          `json:"delivered"`
+	Read       int64          `json:"read"`
+	Replied    int64          `json:"replied"`
+	Clicked    int64          `json:"clicked"`
+
+	DeliveryRate float64 `json:"delivery_rate"` // delivered of sent
+	ReadRate     float64 `json:"read_rate"`     // read of delivered
+	ReplyRate    float64 `json:"reply_rate"`    // replied of delivered
+	ClickRate    float64 `json:"click_rate"`    // clicked of delivered
+
+	// Campaigns testing variants report each of them, and the winner once its engagement
+	// rate is ahead of every other variant's with 95% confidence
+	Variants []*CampaignVariantReport `json:"variants,omitempty"`
+	Winner   string                   `json:"winner,omitempty"`
+}
+
+// CampaignVariantReport is the funnel of one variant of a campaign. Engaged recipients
+// replied or clicked; the confidence interval is the 95% Wilson score interval of the
+// engagement rate.
+type CampaignVariantReport struct {
+	Name      string `json:"name"`
+	Weight    int    `json:"weight"`
+	Sent      int64  `json:"sent"`
+	Delivered int64  `json:"delivered"`
+	Read      int64  `json:"read"`
+	Replied   int64  `json:"replied"`
+	Clicked   int64  `json:"clicked"`
+	Engaged   int64  `json:"engaged"`
+
+	EngagementRate float64 `json:"engagement_rate"` // engaged of delivered
+	ConfidenceLow  float64 `json:"confidence_low"`
+	ConfidenceHigh float64 `json:"confidence_high"`
+}
+
+// FrequencyCapRollup counts the campaign messages of a tenant counted against the
+// frequency cap on one day (UTC), and those refused by it
+type FrequencyCapRollup struct {
+	Day     time.Time `json:"day" db:"day"`
+	Tenant  string    `json:"tenant" db:"tenant"`
+	Counted int64     `json:"counted" db:"counted"`
+	Capped  int64     `json:"capped" db:"capped"`
+}
+
+// FrequencyCapReport summarizes the frequency cap over a period
+type FrequencyCapReport struct {
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Tenant     string                `json:"tenant,omitempty"`
+	Counted    int64                 `json:"counted"`
+	Capped     int64                 `json:"capped"`
+	CappedRate float64               `json:"capped_rate"` // capped of counted and capped
+	Days       []*FrequencyCapRollup `json:"days"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// FaultConfig controls the faults injected for resilience testing. Rates are
+// probabilities between 0 and 1 applied to each request or provider call.
+type FaultConfig struct {
+	Enabled           bool    `json:"enabled"`
+	LatencyMs         int     `json:"latency_ms"`
+	LatencyRate       float64 `json:"latency_rate"`
+	ErrorRate         float64 `json:"error_rate"`
+	WebhookDropRate   float64 `json:"webhook_drop_rate"`
+	ProviderErrorRate float64 `json:"provider_error_rate"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatContextSchemaVersion is the version of the ChatContext wire format sent to the
+// chat orchestrator. Adding optional fields keeps the version; renaming, removing or
+// changing the meaning of a field requires a bump so the orchestrator can branch on it.
+const ChatContextSchemaVersion = 1
+
+// ChatContext is the structured context sent to the chat orchestrator with each message
+type ChatContext struct {
+	SchemaVersion  int                  `json:"schema_version"`
+	Platform       string               `json:"platform"` // message channel: whatsapp, sms, messenger, instagram, telegram or email
+	TwilioSID      string               `json:"twilio_sid,omitempty"`
+	Direction      MessageDirection     `json:"direction"`
+	Locale         string               `json:"locale,omitempty"`
+	Session        *ChatContextSession  `json:"session,omitempty"`
+	User           *ChatContextUser     `json:"user,omitempty"`
+	Window         *ChatContextWindow   `json:"window,omitempty"`
+	RecentMessages []ChatContextMessage `json:"recent_messages"`
+}
+
+// ChatContextSession describes the chat session a message belongs to
+type ChatContextSession struct {
+	ID             uuid.UUID `json:"id"`
+	Status         string    `json:"status"`
+	State          string    `json:"state,omitempty"`
+	Tags           []string  `json:"tags,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	LastActivityAt time.Time `json:"last_activity_at"`
+}
+
+// ChatContextUser describes the WhatsApp user's profile
+type ChatContextUser struct {
+	ID          uuid.UUID `json:"id"`
+	PhoneNumber string    `json:"phone_number"`
+	WhatsAppID  string    `json:"whatsapp_id,omitempty"`
+	ProfileName string    `json:"profile_name,omitempty"`
+}
+
+// ChatContextWindow describes the WhatsApp customer service window, inside which
+// free-form replies are allowed; outside it only approved templates can be sent
+type ChatContextWindow struct {
+	Open      bool       `json:"open"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ChatContextMessage is a previous message included for conversational context
+type ChatContextMessage struct {
+	ID          uuid.UUID        `json:"id"`
+	Direction   MessageDirection `json:"direction"`
+	MessageType MessageType      `json:"message_type"`
+	Content     string           `json:"content"`
+	MediaType   *string          `json:"media_type,omitempty"`
+	Labels      []string         `json:"labels,omitempty"`
+	Sentiment   *float64         `json:"sentiment,omitempty"`
+	Timestamp   time.Time        `json:"timestamp"`
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The orchestrator parses these payloads; a change to any expected string below is a
+// wire format change and needs a ChatContextSchemaVersion bump unless it only adds an
+// optional field.
+
+var (
+	testSessionID = uuid.MustParse("7d8f6a2e-3c4b-4f5a-9e1d-2b3c4d5e6f70")
+	testUserID    = uuid.MustParse("0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d")
+	testMessageID = uuid.MustParse("f0e1d2c3-b4a5-4968-8776-655443322110")
+	testStartedAt = time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	testActiveAt  = time.Date(2026, 3, 1, 9, 45, 12, 0, time.UTC)
+	testExpiresAt = time.Date(2026, 3, 2, 9, 45, 12, 0, time.UTC)
+)
+
+func TestChatContextEncoding(t *testing.T) {
+	mediaType := "audio/ogg"
+	sentiment := -0.25
+
+	tests := []struct {
+		name    string
+		context ChatContext
+		want    string
+	}{
+		{
+			name: "minimal",
+			context: ChatContext{
+				SchemaVersion:  ChatContextSchemaVersion,
+				Platform:       "whatsapp",
+				Direction:      MessageDirectionInbound,
+				RecentMessages: []ChatContextMessage{},
+			},
+			want: `{"schema_version":1,"platform":"whatsapp","direction":"inbound","recent_messages":[]}`,
+		},
+		{
+			name: "session without optional fields",
+			context: ChatContext{
+				SchemaVersion: ChatContextSchemaVersion,
+				Platform:      "sms",
+				TwilioSID:     "SM123",
+				Direction:     MessageDirectionInbound,
+				Locale:        "pt-BR",
+				Session: &ChatContextSession{
+					ID:             testSessionID,
+					Status:         "active",
+					StartedAt:      testStartedAt,
+					LastActivityAt: testActiveAt,
+				},
+				Window:         &ChatContextWindow{Open: false},
+				RecentMessages: []ChatContextMessage{},
+			},
+			want: `{"schema_version":1,"platform":"sms","twilio_sid":"SM123","direction":"inbound","locale":"pt-BR",` +
+				`"session":{"id":"7d8f6a2e-3c4b-4f5a-9e1d-2b3c4d5e6f70","status":"active","started_at":"2026-03-01T09:30:00Z","last_activity_at":"2026-03-01T09:45:12Z"},` +
+				`"window":{"open":false},"recent_messages":[]}`,
+		},
+		{
+			name: "full",
+			context: ChatContext{
+				SchemaVersion: ChatContextSchemaVersion,
+				Platform:      "whatsapp",
+				TwilioSID:     "SM456",
+				Direction:     MessageDirectionInbound,
+				Locale:        "en",
+				Session: &ChatContextSession{
+					ID:             testSessionID,
+					Status:         "active",
+					State:          "awaiting_document",
+					Tags:           []string{"vip"},
+					StartedAt:      testStartedAt,
+					LastActivityAt: testActiveAt,
+				},
+				User: &ChatContextUser{
+					ID:          testUserID,
+					PhoneNumber: "+5511999990000",
+					WhatsAppID:  "5511999990000",
+					ProfileName: "Ana",
+				},
+				Window: &ChatContextWindow{Open: true, ExpiresAt: &testExpiresAt},
+				RecentMessages: []ChatContextMessage{{
+					ID:          testMessageID,
+					Direction:   MessageDirectionInbound,
+					MessageType: MessageTypeAudio,
+					Content:     "hello",
+					MediaType:   &mediaType,
+					Labels:      []string{"greeting"},
+					Sentiment:   &sentiment,
+					Timestamp:   testActiveAt,
+				}},
+			},
+			want: `{"schema_version":1,"platform":"whatsapp","twilio_sid":"SM456","direction":"inbound","locale":"en",` +
+				`"session":{"id":"7d8f6a2e-3c4b-4f5a-9e1d-2b3c4d5e6f70","status":"active","state":"awaiting_document","tags":["vip"],"started_at":"2026-03-01T09:30:00Z","last_activity_at":"2026-03-01T09:45:12Z"},` +
+				`"user":{"id":"0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d","phone_number":"+5511999990000","whatsapp_id":"5511999990000","profile_name":"Ana"},` +
+				`"window":{"open":true,"expires_at":"2026-03-02T09:45:12Z"},` +
+				`"recent_messages":[{"id":"f0e1d2c3-b4a5-4968-8776-655443322110","direction":"inbound","message_type":"audio","content":"hello","media_type":"audio/ogg","labels":["greeting"],"sentiment":-0.25,"timestamp":"2026-03-01T09:45:12Z"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.context)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatContextDecoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    ChatContext
+	}{
+		{
+			name:    "version 1 minimal",
+			payload: `{"schema_version":1,"platform":"whatsapp","direction":"inbound","recent_messages":[]}`,
+			want: ChatContext{
+				SchemaVersion:  1,
+				Platform:       "whatsapp",
+				Direction:      MessageDirectionInbound,
+				RecentMessages: []ChatContextMessage{},
+			},
+		},
+		{
+			name: "unknown fields from a newer minor revision are ignored",
+			payload: `{"schema_version":1,"platform":"telegram","direction":"inbound","recent_messages":[],` +
+				`"window":{"open":true,"expires_at":"2026-03-02T09:45:12Z","template_required":false},"experiment":"b"}`,
+			want: ChatContext{
+				SchemaVersion:  1,
+				Platform:       "telegram",
+				Direction:      MessageDirectionInbound,
+				Window:         &ChatContextWindow{Open: true, ExpiresAt: &testExpiresAt},
+				RecentMessages: []ChatContextMessage{},
+			},
+		},
+		{
+			name: "missing recent messages",
+			payload: `{"schema_version":1,"platform":"email","direction":"inbound",` +
+				`"user":{"id":"0a1b2c3d-4e5f-4a6b-8c7d-8e9f0a1b2c3d","phone_number":"ana@example.com"}}`,
+			want: ChatContext{
+				SchemaVersion: 1,
+				Platform:      "email",
+				Direction:     MessageDirectionInbound,
+				User:          &ChatContextUser{ID: testUserID, PhoneNumber: "ana@example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ChatContext
+			if err := json.Unmarshal([]byte(tt.payload), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatContextRoundTrip(t *testing.T) {
+	mediaType := "image/jpeg"
+	want := ChatContext{
+		SchemaVersion: ChatContextSchemaVersion,
+		Platform:      "instagram",
+		Direction:     MessageDirectionInbound,
+		Session:       &ChatContextSession{ID: testSessionID, Status: "active", StartedAt: testStartedAt, LastActivityAt: testActiveAt},
+		Window:        &ChatContextWindow{Open: true, ExpiresAt: &testExpiresAt},
+		RecentMessages: []ChatContextMessage{
+			{ID: testMessageID, Direction: MessageDirectionOutbound, MessageType: MessageTypeText, Content: "Hi!", Timestamp: testStartedAt},
+			{ID: testMessageID, Direction: MessageDirectionInbound, MessageType: MessageTypeImage, MediaType: &mediaType, Timestamp: testActiveAt},
+		},
+	}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var got ChatContext
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
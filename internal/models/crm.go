@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CRM export statuses
+const (
+	CRMExportStatusPending   = "pending"
+	CRMExportStatusDelivered = "delivered"
+	CRMExportStatusFailed    = "failed"
+)
+
+// CRMConversationRecord is the conversation summary pushed to the CRM when a session closes
+type CRMConversationRecord struct {
+	SessionID     uuid.UUID        `json:"session_id"`
+	UserID        uuid.UUID        `json:"user_id"`
+	PhoneNumber   string           `json:"phone_number,omitempty"`
+	ProfileName   string           `json:"profile_name,omitempty"`
+	Channels      []Channel        `json:"channels"`
+	StartedAt     time.Time        `json:"started_at"`
+	EndedAt       *time.Time       `json:"ended_at,omitempty"`
+	CloseReason   *string          `json:"close_reason,omitempty"`
+	Summary       *string          `json:"summary,omitempty"`
+	State         string           `json:"state"`
+	Tags          []string         `json:"tags"`
+	Labels        []string         `json:"labels"`
+	MessageCounts CRMMessageCounts `json:"message_counts"`
+	Consent       CRMConsent       `json:"consent"`
+}
+
+// CRMMessageCounts counts the messages of an exported conversation
+type CRMMessageCounts struct {
+	Total    int `json:"total"`
+	Inbound  int `json:"inbound"`
+	Outbound int `json:"outbound"`
+	Media    int `json:"media"`
+}
+
+// CRMConsent reports whether the contact may still be messaged on the channels they used
+type CRMConsent struct {
+	Contactable      bool      `json:"contactable"`
+	OptedOutChannels []Channel `json:"opted_out_channels"`
+}
+
+// CRMExport is the delivery log entry of one conversation export
+type CRMExport struct {
+	ID             uuid.UUID           `json:"id" db:"id"`
+	SessionID      uuid.UUID           `json:"session_id" db:"session_id"`
+	Status         string              `json:"status" db:"status"`
+	Attempts       int                 `json:"attempts" db:"attempts"`
+	LastStatusCode *int                `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string             `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  *time.Time          `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	DeliveredAt    *time.Time          `json:"delivered_at,omitempty" db:"delivered_at"`
+	Payload        json.RawMessage     `json:"payload" db:"payload"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at" db:"updated_at"`
+	History        []*CRMExportAttempt `json:"history,omitempty" db:"-"`
+}
+
+// CRMExportAttempt records one delivery attempt of an export
+type CRMExportAttempt struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	ExportID   uuid.UUID `json:"export_id" db:"export_id"`
+	Attempt    int       `json:"attempt" db:"attempt"`
+	StatusCode *int      `json:"status_code,omitempty" db:"status_code"`
+	Error      *string   `json:"error,omitempty" db:"error"`
+	DurationMS int64     `json:"duration_ms" db:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
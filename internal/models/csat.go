@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CSATStatus is the state of a satisfaction survey
+type CSATStatus string
+
+const (
+	CSATStatusScheduled CSATStatus = "scheduled" // waiting for CSAT_DELAY after the session closed
+	CSATStatusSending   CSATStatus = "sending"   // claimed by a replica that is sending it
+	CSATStatusSent      CSATStatus = "sent"      // awaiting the user's rating
+	CSATStatusAnswered  CSATStatus = "answered"
+	CSATStatusSkipped   CSATStatus = "skipped" // the user started a new conversation before it was sent
+	CSATStatusFailed    CSATStatus = "failed"  // the survey could not be sent
+)
+
+// CSATPayloadPrefix prefixes the quick reply payloads of the rating template, e.g.
+// "csat:5"
+const CSATPayloadPrefix = "csat"
+
+// CSATResponse is the satisfaction survey of a closed session and the user's rating
+type CSATResponse struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	SessionID       uuid.UUID  `json:"session_id" db:"session_id"`
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	To              string     `json:"to" db:"to_number"`
+	Channel         Channel    `json:"channel" db:"channel"`
+	Status          CSATStatus `json:"status" db:"status"`
+	SendAfter       time.Time  `json:"send_after" db:"send_after"`
+	SurveyMessageID *uuid.UUID `json:"survey_message_id,omitempty" db:"survey_message_id"`
+	SentAt          *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+	Rating          *int       `json:"rating,omitempty" db:"rating"` // 1 to 5
+	ReplyMessageID  *uuid.UUID `json:"reply_message_id,omitempty" db:"reply_message_id"`
+	RespondedAt     *time.Time `json:"responded_at,omitempty" db:"responded_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CSATStats aggregates the surveys sent in a period. Score is the share of ratings of 4
+// or 5, the usual CSAT definition; Average and Score are absent without responses.
+type CSATStats struct {
+	From         time.Time     `json:"from"`
+	To           time.Time     `json:"to"`
+	Channel      Channel       `json:"channel,omitempty"`
+	Sent         int64         `json:"sent"`
+	Responses    int64         `json:"responses"`
+	ResponseRate float64       `json:"response_rate"`
+	Average      *float64      `json:"average,omitempty"`
+	Score        *float64      `json:"score,omitempty"`
+	Distribution map[int]int64 `json:"distribution"` // responses per rating
+}
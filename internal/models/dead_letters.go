@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stages of the inbound pipeline that are isolated from each other's panics
+const (
+	IngestStageParse       = "parse"
+	IngestStageSession     = "session"
+	IngestStageRoute       = "route"
+	IngestStageStore       = "store"
+	IngestStagePostStore   = "post_store"
+	IngestStageAutomations = "automations"
+	IngestStageReply       = "reply"
+)
+
+// DeadLetter is an inbound payload whose processing panicked, kept with the stack trace
+// for analysis
+type DeadLetter struct {
+	ID        uuid.UUID       `json:"id" db:"id"`
+	Source    string          `json:"source" db:"source"`
+	Stage     string          `json:"stage" db:"stage"`
+	MessageID *uuid.UUID      `json:"message_id,omitempty" db:"message_id"`
+	Payload   json.RawMessage `json:"payload" db:"payload"`
+	Error     string          `json:"error" db:"error"`
+	Stack     string          `json:"stack" db:"stack"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+// InboundEmail is an email received through an inbound parse webhook
+type InboundEmail struct {
+	MessageID string
+	From      string
+	FromName  string
+	To        string
+	Subject   string
+	Text      string
+}
+
+// SNSNotification is the envelope Amazon SNS posts to HTTP subscribers. Signature is
+// the base64 RSA signature of the other fields, made with the certificate at
+// SigningCertURL.
+type SNSNotification struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	Token            string `json:"Token"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// SESReceiptNotification is the SES "Received" notification carried in an SNS message
+type SESReceiptNotification struct {
+	NotificationType string `json:"notificationType"`
+	Mail             struct {
+		MessageID   string   `json:"messageId"`
+		Source      string   `json:"source"`
+		Destination []string `json:"destination"`
+	} `json:"mail"`
+	Receipt struct {
+		SPFVerdict  SESVerdict `json:"spfVerdict"`
+		DKIMVerdict SESVerdict `json:"dkimVerdict"`
+	} `json:"receipt"`
+	Content string `json:"content"`
+}
+
+// SESVerdict is the outcome of a check SES ran on a received email, e.g. PASS or FAIL
+type SESVerdict struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types published by the adapter
+const (
+	EventAIResultReceived   = "ai.result.received"
+	EventMessageCanceled    = "message.canceled"
+	EventMessageRevoked     = "message.revoked"
+	EventMessageMuted       = "message.muted" // inbound message held back from the orchestrator
+	EventSessionMuted       = "session.muted"
+	EventSessionUnmuted     = "session.unmuted"
+	EventSessionAssigned    = "session.assigned"
+	EventSessionReleased    = "session.released"
+	EventSessionEscalated   = "session.escalated" // negative sentiment streak; an agent should look
+	EventPaymentUpdated     = "payment.updated"
+	EventTwilioAlert        = "twilio.alert"
+	EventMessageLabeled     = "message.labeled"
+	EventAutomationFired    = "automation.fired"
+	EventAnomalyDetected    = "anomaly.detected"
+	EventLocationUpdated    = "location.updated"    // a live location moved
+	EventAppointmentUpdated = "appointment.updated" // the user confirmed or declined an appointment
+	EventCSATAnswered       = "csat.answered"       // the user rated a closed conversation
+	EventCampaignUpdated    = "campaign.updated"    // a campaign changed status
+)
+
+// Event represents a notification published to downstream consumers
+type Event struct {
+	ID         uuid.UUID              `json:"id"`
+	Type       string                 `json:"type"`
+	MessageID  *uuid.UUID             `json:"message_id,omitempty"`
+	SessionID  *uuid.UUID             `json:"session_id,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
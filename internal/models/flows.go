@@ -0,0 +1,11 @@
+package models
+
+// FlowResponse holds the answers a user submitted through a WhatsApp Flow
+type FlowResponse struct {
+	// FlowToken is the token the Flow was sent with, identifying the form instance
+	FlowToken string `json:"flow_token,omitempty"`
+	// Name is the Flow's name as reported by WhatsApp, usually "flow"
+	Name string `json:"name,omitempty"`
+	// Answers maps the Flow's field names to the submitted values
+	Answers map[string]interface{} `json:"answers"`
+}
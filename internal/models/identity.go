@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity kinds
+const (
+	IdentityKindPhone         = "phone"
+	IdentityKindWaID          = "wa_id"
+	IdentityKindMessengerPSID = "messenger_psid"
+	IdentityKindInstagramID   = "instagram_id"
+	IdentityKindTelegramChat  = "telegram_chat_id"
+	IdentityKindEmail         = "email"
+)
+
+// IdentityKindForChannel returns the identity kind of a channel-scoped sender ID;
+// channels addressed by phone number have none
+func IdentityKindForChannel(channel Channel) (string, bool) {
+	switch channel {
+	case ChannelMessenger:
+		return IdentityKindMessengerPSID, true
+	case ChannelInstagram:
+		return IdentityKindInstagramID, true
+	case ChannelTelegram:
+		return IdentityKindTelegramChat, true
+	case ChannelEmail:
+		return IdentityKindEmail, true
+	default:
+		return "", false
+	}
+}
+
+// UserIdentity maps a channel identifier to a canonical user
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Kind      string    `json:"kind" db:"kind"`
+	Value     string    `json:"value" db:"value"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// LinkIdentityRequest represents an admin request to map an identifier to an existing user
+type LinkIdentityRequest struct {
+	Kind  string `json:"kind" binding:"required"`
+	Value string `json:"value" binding:"required"`
+}
+
+// MergeUsersRequest represents an admin request to merge a duplicate user into another
+type MergeUsersRequest struct {
+	SourceUserID uuid.UUID `json:"source_user_id" binding:"required"`
+	TargetUserID uuid.UUID `json:"target_user_id" binding:"required"`
+}
+
+// MergeUsersResult summarizes what a merge moved
+type MergeUsersResult struct {
+	SourceUserID    uuid.UUID `json:"source_user_id"`
+	TargetUserID    uuid.UUID `json:"target_user_id"`
+	IdentitiesMoved int64     `json:"identities_moved"`
+	MessagesMoved   int64     `json:"messages_moved"`
+	SessionsMoved   int64     `json:"sessions_moved"`
+	SessionsClosed  int64     `json:"sessions_closed"`
+	ArchivesMoved   int64     `json:"archives_moved"`
+}
+
+// MergePhonesRequest represents an admin request to merge the conversations of two numbers
+// of the same person
+type MergePhonesRequest struct {
+	SourcePhone string `json:"source_phone" binding:"required"`
+	TargetPhone string `json:"target_phone" binding:"required"`
+	Reason      string `json:"reason"`
+}
+
+// MergePhonesResult summarizes a phone merge and the user merge it made
+type MergePhonesResult struct {
+	SourcePhone string `json:"source_phone"`
+	TargetPhone string `json:"target_phone"`
+	MergeUsersResult
+}
+
+// SetLocaleRequest sets the locale of a user's system-generated messages; an empty
+// locale reverts to DEFAULT_LOCALE
+type SetLocaleRequest struct {
+	Locale string `json:"locale"`
+}
+
+// SetTimezoneRequest sets the IANA time zone of a user, e.g. "America/Manaus"; an
+// empty time zone reverts to inferring it from the phone number
+type SetTimezoneRequest struct {
+	Timezone string `json:"timezone"`
+}
+
+// SetConsentRequest replaces the notification categories a user opted in to, e.g.
+// "marketing"; an empty list withdraws every consent
+type SetConsentRequest struct {
+	Categories []string `json:"categories"`
+}
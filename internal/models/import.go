@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Import payload formats
+const (
+	ImportFormatJSONL = "jsonl"
+	ImportFormatCSV   = "csv"
+)
+
+// ImportRecord is one historical message of an import. JSONL lines use these JSON
+// names; CSV files use them as header columns.
+type ImportRecord struct {
+	ExternalID string    `json:"external_id"` // message ID at the previous provider; keeps re-imports idempotent
+	Channel    Channel   `json:"channel"`     // whatsapp (default) or sms
+	Direction  string    `json:"direction"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Type       string    `json:"type"` // text when empty
+	Content    string    `json:"content"`
+	MediaURL   string    `json:"media_url"`
+	MediaType  string    `json:"media_type"`
+	Status     string    `json:"status"` // delivered when empty
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// ImportS3Request points an import at a JSONL or CSV object in S3
+type ImportS3Request struct {
+	S3URI  string `json:"s3_uri" binding:"required"` // s3://bucket/key
+	Format string `json:"format"`                    // taken from the key's extension when empty
+}
+
+// ImportError describes a record that was not imported
+type ImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	DryRun     bool          `json:"dry_run"`
+	Records    int           `json:"records"`
+	Imported   int           `json:"imported"`
+	Duplicates int           `json:"duplicates"`
+	Invalid    int           `json:"invalid"`
+	Users      int           `json:"users"`
+	Errors     []ImportError `json:"errors"` // the first ones only
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrackedLink represents a shortened URL embedded in an outbound message
+type TrackedLink struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Code        string     `json:"code" db:"code"`
+	MessageID   *uuid.UUID `json:"message_id,omitempty" db:"message_id"`
+	OriginalURL string     `json:"original_url" db:"original_url"`
+	ShortURL    string     `json:"short_url" db:"-"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// LinkClick represents a single click on a tracked link
+type LinkClick struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	LinkID    uuid.UUID `json:"link_id" db:"link_id"`
+	ClickedAt time.Time `json:"clicked_at" db:"clicked_at"`
+	IPAddress string    `json:"ip_address" db:"ip_address"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	Referer   string    `json:"referer" db:"referer"`
+}
+
+// LinkStats summarizes click activity for a single tracked link
+type LinkStats struct {
+	Code         string     `json:"code"`
+	OriginalURL  string     `json:"original_url"`
+	ShortURL     string     `json:"short_url"`
+	Clicks       int        `json:"clicks"`
+	UniqueClicks int        `json:"unique_clicks"`
+	FirstClickAt *time.Time `json:"first_click_at,omitempty"`
+	LastClickAt  *time.Time `json:"last_click_at,omitempty"`
+}
+
+// MessageClickStats aggregates click analytics for all links in a message
+type MessageClickStats struct {
+	MessageID   uuid.UUID   `json:"message_id"`
+	TotalClicks int         `json:"total_clicks"`
+	Links       []LinkStats `json:"links"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// LiveLocationUpdate is a new position of a location the user shares live. Sequence
+// orders the updates of one share; older updates than the stored one are ignored.
+type LiveLocationUpdate struct {
+	Channel    Channel `json:"channel"`
+	ProviderID string  `json:"provider_id"` // provider message ID of the live location message
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	LivePeriod int     `json:"live_period,omitempty"` // seconds the location is shared for
+	Sequence   int64   `json:"sequence"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origins of stored media objects
+const (
+	MediaSourceUpload   = "upload"   // uploaded through the API or downloaded from a channel
+	MediaSourceRehosted = "rehosted" // copy of Twilio media shared with the orchestrator
+	MediaSourceInbound  = "inbound"  // inbound media stored once per content hash
+	MediaSourceDirect   = "direct"   // uploaded by the client to a presigned URL
+)
+
+// Media scan statuses
+const (
+	MediaScanNotScanned = "not_scanned"
+	MediaScanInfected   = "infected"
+)
+
+// States of direct uploads
+const (
+	MediaUploadPending  = "pending"  // URL issued, object not verified yet
+	MediaUploadVerified = "verified" // object checked and usable in sends
+	MediaUploadRejected = "rejected" // object failed verification and was deleted
+)
+
+// MediaObject is a media file stored in our bucket
+type MediaObject struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	Bucket       string     `json:"bucket" db:"bucket"`
+	Key          string     `json:"key" db:"object_key"`
+	URL          string     `json:"url" db:"url"`
+	MessageID    *uuid.UUID `json:"message_id,omitempty" db:"message_id"`
+	SessionID    *uuid.UUID `json:"session_id,omitempty" db:"-"` // of the message
+	Source       string     `json:"source" db:"source"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	SizeBytes    int64      `json:"size_bytes" db:"size_bytes"`
+	ScanStatus   string     `json:"scan_status" db:"scan_status"`
+	ThumbnailURL *string    `json:"thumbnail_url,omitempty" db:"thumbnail_url"`
+	SHA256       *string    `json:"sha256,omitempty" db:"sha256"`               // set on content-addressed objects
+	UploadStatus *string    `json:"upload_status,omitempty" db:"upload_status"` // set on direct uploads
+	ETag         *string    `json:"etag,omitempty" db:"etag"`                   // as last seen in the bucket
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+
+	// Set once the object has been inspected
+	Info *MediaInfo `json:"info,omitempty" db:"-"`
+}
+
+// MediaInfo describes a media file: what the storage reports about it and, for images,
+// video and audio, what was read from its content. Unknown values are left out.
+type MediaInfo struct {
+	URL             string    `json:"url"`
+	ContentType     string    `json:"content_type,omitempty"`
+	SizeBytes       int64     `json:"size_bytes"`
+	ETag            string    `json:"etag,omitempty"`
+	Width           *int      `json:"width,omitempty"`
+	Height          *int      `json:"height,omitempty"`
+	DurationSeconds *float64  `json:"duration_seconds,omitempty"`
+	InspectedAt     time.Time `json:"inspected_at"`
+}
+
+// MediaFilter selects stored media by the message or conversation it belongs to
+type MediaFilter struct {
+	MessageID *uuid.UUID
+	SessionID *uuid.UUID
+}
+
+// MediaPresignRequest asks for a URL to upload a file directly to our bucket
+type MediaPresignRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	SizeBytes   int64  `json:"size_bytes" binding:"required,min=1"`
+}
+
+// MediaPresignResponse is a presigned upload URL and the media it will create. The
+// upload must be a PUT carrying Headers; MediaURL is then used in send requests.
+type MediaPresignResponse struct {
+	MediaID   uuid.UUID         `json:"media_id"`
+	MediaURL  string            `json:"media_url"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
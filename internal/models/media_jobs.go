@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stages of the inbound media pipeline, in order
+const (
+	MediaStageDownloaded = "downloaded"
+	MediaStageScanned    = "scanned"
+	MediaStageTranscoded = "transcoded"
+	MediaStageAnalyzed   = "analyzed"
+)
+
+// MediaStages lists the pipeline stages in order
+var MediaStages = []string{MediaStageDownloaded, MediaStageScanned, MediaStageTranscoded, MediaStageAnalyzed}
+
+// Media pipeline stage and attachment states
+const (
+	MediaJobPending     = "pending"
+	MediaJobRunning     = "running"
+	MediaJobCompleted   = "completed"
+	MediaJobFailed      = "failed"
+	MediaJobSkipped     = "skipped"     // the stage does not apply or is not implemented
+	MediaJobUnavailable = "unavailable" // the media expired or was deleted before it was downloaded
+	MediaJobProcessing  = "processing"  // attachment state while stages are pending or running
+	MediaJobUnknown     = "unknown"     // attachment received before tracking existed
+)
+
+// MediaProcessingJob is one pipeline stage of a message attachment
+type MediaProcessingJob struct {
+	Stage       string     `json:"stage" db:"stage"`
+	Status      string     `json:"status" db:"status"`
+	Error       *string    `json:"error,omitempty" db:"error"`
+	StartedAt   *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// MediaAttachmentStatus is the pipeline state of one attachment of a message
+type MediaAttachmentStatus struct {
+	Index     int                   `json:"index"`
+	MediaType string                `json:"media_type,omitempty"`
+	Status    string                `json:"status"`
+	Stages    []*MediaProcessingJob `json:"stages"`
+}
+
+// MediaStatus reports the processing state of a message's attachments
+type MediaStatus struct {
+	MessageID   uuid.UUID                `json:"message_id"`
+	Attachments []*MediaAttachmentStatus `json:"attachments"`
+}
@@ -0,0 +1,69 @@
+package models
+
+// Meta webhook object types
+const (
+	MetaObjectPage      = "page"
+	MetaObjectInstagram = "instagram"
+)
+
+// MetaWebhookRequest represents a Messenger or Instagram Direct webhook delivery
+type MetaWebhookRequest struct {
+	Object string      `json:"object"`
+	Entry  []MetaEntry `json:"entry"`
+}
+
+// MetaEntry groups the messaging events of one page or Instagram account
+type MetaEntry struct {
+	ID        string               `json:"id"`
+	Time      int64                `json:"time"`
+	Messaging []MetaMessagingEvent `json:"messaging"`
+}
+
+// MetaMessagingEvent is a single message, delivery or read event
+type MetaMessagingEvent struct {
+	Sender    MetaParticipant `json:"sender"`
+	Recipient MetaParticipant `json:"recipient"`
+	Timestamp int64           `json:"timestamp"`
+	Message   *MetaMessage    `json:"message,omitempty"`
+	Delivery  *MetaDelivery   `json:"delivery,omitempty"`
+}
+
+// MetaParticipant identifies a page-scoped user or the page itself
+type MetaParticipant struct {
+	ID string `json:"id"`
+}
+
+// MetaMessage is the message body of a messaging event
+type MetaMessage struct {
+	MID         string           `json:"mid"`
+	Text        string           `json:"text"`
+	IsEcho      bool             `json:"is_echo"`
+	IsDeleted   bool             `json:"is_deleted"` // the user unsent the message
+	Attachments []MetaAttachment `json:"attachments"`
+}
+
+// MetaAttachment is a media attachment; Type is image, video, audio or file
+type MetaAttachment struct {
+	Type    string `json:"type"`
+	Payload struct {
+		URL string `json:"url"`
+	} `json:"payload"`
+}
+
+// MetaDelivery lists outbound messages confirmed delivered
+type MetaDelivery struct {
+	MIDs      []string `json:"mids"`
+	Watermark int64    `json:"watermark"`
+}
+
+// MetaChannelForObject maps a webhook object type to its channel
+func MetaChannelForObject(object string) (Channel, bool) {
+	switch object {
+	case MetaObjectPage:
+		return ChannelMessenger, true
+	case MetaObjectInstagram:
+		return ChannelInstagram, true
+	default:
+		return "", false
+	}
+}
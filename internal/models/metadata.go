@@ -0,0 +1,168 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Metadata is free-form structured data the orchestrator attaches to messages and
+// sessions, such as property IDs or lead scores. It is stored as JSONB.
+type Metadata map[string]interface{}
+
+// MetadataPatch updates metadata: keys are set to the given values, and keys given a
+// null value are removed
+type MetadataPatch map[string]interface{}
+
+// String returns a string value, or false when the key is missing or not a string
+func (m Metadata) String(key string) (string, bool) {
+	value, ok := m[key].(string)
+	return value, ok
+}
+
+// Float returns a numeric value; numeric strings are accepted too
+func (m Metadata) Float(key string) (float64, bool) {
+	switch value := m[key].(type) {
+	case float64:
+		return value, true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Int returns an integral numeric value; numeric strings are accepted too
+func (m Metadata) Int(key string) (int64, bool) {
+	f, ok := m.Float(key)
+	if !ok || f != float64(int64(f)) {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// Bool returns a boolean value
+func (m Metadata) Bool(key string) (bool, bool) {
+	value, ok := m[key].(bool)
+	return value, ok
+}
+
+// Time returns an RFC 3339 timestamp value
+func (m Metadata) Time(key string) (time.Time, bool) {
+	value, ok := m.String(key)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
+
+// Object returns a nested object value
+func (m Metadata) Object(key string) (Metadata, bool) {
+	value, ok := m[key].(map[string]interface{})
+	return Metadata(value), ok
+}
+
+// Contains reports whether m contains filter as JSONB @> does when filtering stored rows:
+// every key of filter is present with a contained value. Objects are matched
+// recursively, an array contains another when each of the other's elements is
+// contained in one of its elements, and numbers are compared by value.
+func (m Metadata) Contains(filter Metadata) bool {
+	return jsonContains(map[string]interface{}(m), map[string]interface{}(filter))
+}
+
+// jsonContains is JSONB containment of decoded JSON values
+func jsonContains(have, want interface{}) bool {
+	if wantObject, ok := jsonObject(want); ok {
+		haveObject, ok := jsonObject(have)
+		if !ok {
+			return false
+		}
+		for key, wantValue := range wantObject {
+			haveValue, ok := haveObject[key]
+			if !ok || !jsonContains(haveValue, wantValue) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if wantArray, ok := jsonArray(want); ok {
+		haveArray, ok := jsonArray(have)
+		if !ok {
+			return false
+		}
+		for _, wantElement := range wantArray {
+			found := false
+			for _, haveElement := range haveArray {
+				if jsonContains(haveElement, wantElement) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	if wantNumber, ok := jsonNumber(want); ok {
+		haveNumber, ok := jsonNumber(have)
+		return ok && haveNumber == wantNumber
+	}
+
+	return reflect.DeepEqual(have, want)
+}
+
+// jsonObject returns a decoded JSON object
+func jsonObject(value interface{}) (map[string]interface{}, bool) {
+	switch object := value.(type) {
+	case map[string]interface{}:
+		return object, true
+	case Metadata:
+		return object, true
+	}
+	return nil, false
+}
+
+// jsonArray returns the elements of a decoded JSON array, or of any slice
+func jsonArray(value interface{}) ([]interface{}, bool) {
+	if array, ok := value.([]interface{}); ok {
+		return array, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		return nil, false
+	}
+	array := make([]interface{}, v.Len())
+	for i := range array {
+		array[i] = v.Index(i).Interface()
+	}
+	return array, true
+}
+
+// jsonNumber returns a decoded JSON number as a float64
+func jsonNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMetadataContains(t *testing.T) {
+	// Expected results are those of Postgres' jsonb @> operator on the same documents
+	tests := []struct {
+		name   string
+		have   string
+		filter string
+		want   bool
+	}{
+		{"empty filter", `{"a": 1}`, `{}`, true},
+		{"equal scalar", `{"listing_id": "L1", "score": 3}`, `{"listing_id": "L1"}`, true},
+		{"different scalar", `{"listing_id": "L1"}`, `{"listing_id": "L2"}`, false},
+		{"missing key", `{"listing_id": "L1"}`, `{"score": 3}`, false},
+		{"numbers by value", `{"score": 3.0}`, `{"score": 3}`, true},
+		{"null", `{"agent": null}`, `{"agent": null}`, true},
+		{"nested object", `{"lead": {"stage": "hot", "score": 9}}`, `{"lead": {"stage": "hot"}}`, true},
+		{"nested object mismatch", `{"lead": {"stage": "cold"}}`, `{"lead": {"stage": "hot"}}`, false},
+		{"array subset", `{"tags": ["vip", "rent", "sp"]}`, `{"tags": ["sp", "vip"]}`, true},
+		{"array element missing", `{"tags": ["vip"]}`, `{"tags": ["vip", "rent"]}`, false},
+		{"empty array", `{"tags": ["vip"]}`, `{"tags": []}`, true},
+		{"repeated elements", `{"tags": ["vip"]}`, `{"tags": ["vip", "vip"]}`, true},
+		{"objects in arrays", `{"items": [{"id": 1, "qty": 2}, {"id": 2}]}`, `{"items": [{"id": 1}]}`, true},
+		{"nested arrays", `{"grid": [1, 2, [1, 3]]}`, `{"grid": [[1, 3]]}`, true},
+		{"nested array flattened", `{"grid": [1, 2, [1, 3]]}`, `{"grid": [[1, 2]]}`, false},
+		{"scalar against array", `{"tags": ["vip"]}`, `{"tags": "vip"}`, false},
+		{"array against scalar", `{"tags": "vip"}`, `{"tags": ["vip"]}`, false},
+		{"object against array", `{"lead": [{"stage": "hot"}]}`, `{"lead": {"stage": "hot"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var have, filter Metadata
+			if err := json.Unmarshal([]byte(tt.have), &have); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.filter), &filter); err != nil {
+				t.Fatal(err)
+			}
+			if got := have.Contains(filter); got != tt.want {
+				t.Errorf("%s.Contains(%s) = %v, want %v", tt.have, tt.filter, got, tt.want)
+			}
+		})
+	}
+
+	if !(Metadata{"tags": []string{"vip", "rent"}}).Contains(Metadata{"tags": []interface{}{"rent"}}) {
+		t.Error("Contains() did not match an array built in Go")
+	}
+}
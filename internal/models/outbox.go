@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox entry kinds
+const (
+	OutboxKindOrchestratorForward = "orchestrator_forward"
+	OutboxKindTwilioWebhook       = "twilio_webhook"
+	OutboxKindOrchestratorRevoke  = "orchestrator_revoke"
+	OutboxKindCalendarWebhook     = "calendar_webhook"
+)
+
+// Twilio webhook routes that can be acknowledged early
+const (
+	WebhookRouteMessage      = "message"
+	WebhookRouteStatus       = "status"
+	WebhookRouteConversation = "conversation"
+)
+
+// Outbox entry statuses
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusDone    = "done"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxEntry is a unit of work written in the same transaction as the change that
+// requires it and processed after the transaction commits
+type OutboxEntry struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Kind          string          `json:"kind" db:"kind"`
+	MessageID     *uuid.UUID      `json:"message_id,omitempty" db:"message_id"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Status        string          `json:"status" db:"status"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	ProcessedAt   *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// QueuedWebhook is a Twilio webhook acknowledged before processing; it is replayed
+// from the outbox
+type QueuedWebhook struct {
+	Route            string    `json:"route"`
+	ContentType      string    `json:"content_type"`
+	Body             string    `json:"body"`
+	IdempotencyToken string    `json:"idempotency_token,omitempty"`
+	ReceivedAt       time.Time `json:"received_at"`
+
+	// Recorded webhook request, carried over to the messages parsed from it
+	WebhookEventID *uuid.UUID `json:"webhook_event_id,omitempty"`
+}
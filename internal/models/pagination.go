@@ -0,0 +1,10 @@
+package models
+
+// Page is the envelope of list responses. NextCursor is passed back as ?cursor= for the
+// next page and is null on the last one. TotalEstimate is the database's estimate of the
+// matching rows: approximate, exact on the last page, and null when it cannot be told.
+type Page struct {
+	Data          interface{} `json:"data"`
+	NextCursor    *string     `json:"next_cursor"`
+	TotalEstimate *int64      `json:"total_estimate"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReferenceKindPayment marks a reference to a payment request sent in a conversation
+const ReferenceKindPayment = "payment"
+
+// ReferenceSourcePayment marks references stored when a payment request is sent
+const ReferenceSourcePayment = "payment"
+
+// PaymentStatus is the state of a payment request
+type PaymentStatus string
+
+const (
+	PaymentStatusPending  PaymentStatus = "pending"
+	PaymentStatusPaid     PaymentStatus = "paid"
+	PaymentStatusFailed   PaymentStatus = "failed"
+	PaymentStatusCanceled PaymentStatus = "canceled"
+	PaymentStatusExpired  PaymentStatus = "expired"
+)
+
+// Valid reports whether s is a known payment status
+func (s PaymentStatus) Valid() bool {
+	switch s {
+	case PaymentStatusPending, PaymentStatusPaid, PaymentStatusFailed, PaymentStatusCanceled, PaymentStatusExpired:
+		return true
+	}
+	return false
+}
+
+// PaymentItem is a line item of a payment request; amounts are in minor units
+// (centavos for BRL)
+type PaymentItem struct {
+	Name       string `json:"name" binding:"required"`
+	Quantity   int    `json:"quantity"`
+	UnitAmount int64  `json:"unit_amount"`
+}
+
+// PaymentSendRequest asks for an order/payment template to be sent to a recipient
+type PaymentSendRequest struct {
+	To          string        `json:"to" binding:"required"`
+	ReferenceID string        `json:"reference_id" binding:"required"`
+	Currency    string        `json:"currency,omitempty"`
+	Items       []PaymentItem `json:"items" binding:"required,dive"`
+	// Description is stored as the message content, e.g. "Reservation fee"
+	Description string `json:"description,omitempty"`
+	// Template overrides PAYMENT_TEMPLATE_SID
+	Template  *string           `json:"template,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Channel   Channel           `json:"channel,omitempty"`
+	Provider  string            `json:"provider,omitempty"`
+}
+
+// PaymentRequest is a payment request sent in a conversation and its latest status
+type PaymentRequest struct {
+	ID            uuid.UUID     `json:"id" db:"id"`
+	ReferenceID   string        `json:"reference_id" db:"reference_id"`
+	MessageID     *uuid.UUID    `json:"message_id,omitempty" db:"message_id"`
+	SessionID     *uuid.UUID    `json:"session_id,omitempty" db:"session_id"`
+	To            string        `json:"to" db:"to_number"`
+	Channel       Channel       `json:"channel" db:"channel"`
+	Currency      string        `json:"currency" db:"currency"`
+	Items         []PaymentItem `json:"items" db:"items"`
+	TotalAmount   int64         `json:"total_amount" db:"total_amount"`
+	Status        PaymentStatus `json:"status" db:"status"`
+	TransactionID *string       `json:"transaction_id,omitempty" db:"transaction_id"`
+	PaidAt        *time.Time    `json:"paid_at,omitempty" db:"paid_at"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// PaymentStatusUpdate is a payment status callback from the payment provider. Updates to
+// paid must report the amount and currency charged, which must match the request.
+type PaymentStatusUpdate struct {
+	ReferenceID   string        `json:"reference_id" binding:"required"`
+	Status        PaymentStatus `json:"status" binding:"required"`
+	TransactionID string        `json:"transaction_id,omitempty"`
+	Amount        *int64        `json:"amount,omitempty"` // minor units
+	Currency      string        `json:"currency,omitempty"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Provider kinds that can be configured at runtime
+const (
+	ProviderKindTwilioMessaging     = "twilio_messaging"
+	ProviderKindTwilioConversations = "twilio_conversations"
+	ProviderKindTwilioSMS           = "twilio_sms"
+	ProviderKindMeta                = "meta"
+	ProviderKindTelegram            = "telegram"
+	ProviderKindSMTPEmail           = "smtp_email"
+)
+
+// ProviderConfig is a stored outbound provider for a channel. Credentials are encrypted
+// at rest and never returned by the API; only their keys are listed.
+type ProviderConfig struct {
+	ID             uuid.UUID         `json:"id" db:"id"`
+	Name           string            `json:"name" db:"name"`
+	Channel        Channel           `json:"channel" db:"channel"`
+	Kind           string            `json:"kind" db:"kind"`
+	FromAddress    string            `json:"from_address" db:"from_address"`
+	Settings       map[string]string `json:"settings" db:"settings"`
+	Credentials    map[string]string `json:"-" db:"-"`
+	CredentialKeys []string          `json:"credential_keys" db:"-"`
+	IsDefault      bool              `json:"is_default" db:"is_default"`
+	IsActive       bool              `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ProviderConfigRequest creates or updates a provider configuration. On update, nil
+// fields are left unchanged and credentials are replaced only when provided.
+type ProviderConfigRequest struct {
+	Name        *string           `json:"name"`
+	Channel     *Channel          `json:"channel"`
+	Kind        *string           `json:"kind"`
+	FromAddress *string           `json:"from_address"`
+	Settings    map[string]string `json:"settings"`
+	Credentials map[string]string `json:"credentials"`
+	IsDefault   *bool             `json:"is_default"`
+	IsActive    *bool             `json:"is_active"`
+}
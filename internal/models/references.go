@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReferenceKindListing marks a reference to a real-estate listing
+const ReferenceKindListing = "listing"
+
+// Where a conversation reference came from
+const (
+	ReferenceSourceContext    = "context"     // orchestrator response context
+	ReferenceSourceNextAction = "next_action" // orchestrator "listing:<id>" next action
+	ReferenceSourceAPI        = "api"
+)
+
+// ConversationReference links a session, and optionally the message that prompted it,
+// to an external object such as a listing
+type ConversationReference struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	SessionID uuid.UUID  `json:"session_id" db:"session_id"`
+	MessageID *uuid.UUID `json:"message_id,omitempty" db:"message_id"`
+	Kind      string     `json:"kind" db:"kind"`
+	RefID     string     `json:"ref_id" db:"ref_id"`
+	Source    string     `json:"source" db:"source"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ReferenceRequest links a session to a listing through the API
+type ReferenceRequest struct {
+	ListingID string     `json:"listing_id" binding:"required"`
+	MessageID *uuid.UUID `json:"message_id,omitempty"`
+}
+
+// ReferencedConversation summarizes a conversation about a referenced object
+type ReferencedConversation struct {
+	SessionID         uuid.UUID  `json:"session_id"`
+	UserID            uuid.UUID  `json:"user_id"`
+	Status            string     `json:"status"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+	LastActivityAt    time.Time  `json:"last_activity_at"`
+	FirstReferencedAt time.Time  `json:"first_referenced_at"`
+	LastReferencedAt  time.Time  `json:"last_referenced_at"`
+	References        int        `json:"references"`
+}
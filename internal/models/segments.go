@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SegmentFilters selects users for an audience. Every filter that is set must match;
+// an empty filter set selects every reachable user. Merged, inactive and suppressed
+// users are never selected.
+type SegmentFilters struct {
+	Tags              []string `json:"tags,omitempty"`                // a session of the user is tagged with any of them
+	MatchAllTags      bool     `json:"match_all_tags,omitempty"`      // the user's sessions carry every tag instead
+	ActiveWithinHours *float64 `json:"active_within_hours,omitempty"` // last activity at most this long ago
+	InactiveForHours  *float64 `json:"inactive_for_hours,omitempty"`  // no activity for at least this long
+	ConsentCategory   string   `json:"consent_category,omitempty"`    // the user opted in to this category
+	Regions           []string `json:"regions,omitempty"`             // phone number prefixes, e.g. "+55" or "+5511"
+}
+
+// Segment is a named audience definition. Its members are resolved when it is
+// previewed, and snapshotted into a campaign's recipients when the campaign launches.
+type Segment struct {
+	ID        uuid.UUID      `json:"id" db:"id"`
+	Name      string         `json:"name" db:"name"`
+	Filters   SegmentFilters `json:"filters" db:"filters"`
+	CreatedBy string         `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// CreateSegmentRequest defines a segment
+type CreateSegmentRequest struct {
+	Name    string         `json:"name" binding:"required"`
+	Filters SegmentFilters `json:"filters"`
+}
+
+// PreviewSegmentRequest previews filters that are not saved as a segment yet
+type PreviewSegmentRequest struct {
+	Filters SegmentFilters `json:"filters"`
+	Channel Channel        `json:"channel,omitempty"`
+}
+
+// SegmentPreview is the audience a segment resolves to now on a channel
+type SegmentPreview struct {
+	Channel Channel  `json:"channel"`
+	Count   int64    `json:"count"`
+	Sample  []string `json:"sample"` // a few of the recipients, for a sanity check
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Async send statuses
+const (
+	SendStatusQueued  = "queued"
+	SendStatusSending = "sending"
+	SendStatusSent    = "sent"
+	SendStatusFailed  = "failed"
+)
+
+// AsyncSend tracks a message accepted for asynchronous sending
+type AsyncSend struct {
+	ID        uuid.UUID  `json:"id"`
+	Status    string     `json:"status"`
+	MessageID *uuid.UUID `json:"message_id,omitempty"` // stored outbound message, once sent
+	TwilioSID string     `json:"twilio_sid,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// SendBatchRequest sends the same content or template to several recipients
+type SendBatchRequest struct {
+	Recipients []string          `json:"recipients" binding:"required"`
+	Content    string            `json:"content"`
+	Type       MessageType       `json:"type"`
+	MediaURL   *string           `json:"media_url,omitempty"`
+	MediaType  *string           `json:"media_type,omitempty"`
+	Variables  map[string]string `json:"variables,omitempty"`
+	Template   *string           `json:"template,omitempty"`
+
+	AllowDuplicate bool    `json:"allow_duplicate,omitempty"`
+	Channel        Channel `json:"channel,omitempty"`
+	Provider       string  `json:"provider,omitempty"`
+	Category       *string `json:"category,omitempty"`
+}
+
+// SendRequest is the async send request of the batch for one recipient
+func (b *SendBatchRequest) SendRequest(to string) *SendMessageRequest {
+	return &SendMessageRequest{
+		To:             to,
+		Content:        b.Content,
+		Type:           b.Type,
+		MediaURL:       b.MediaURL,
+		MediaType:      b.MediaType,
+		Variables:      b.Variables,
+		Template:       b.Template,
+		AllowDuplicate: b.AllowDuplicate,
+		Channel:        b.Channel,
+		Provider:       b.Provider,
+		Category:       b.Category,
+		Async:          true,
+	}
+}
+
+// SendBatchRecipient is the outcome of a batch for one recipient. Errors lists the
+// failed pre-send checks; SendID tracks the queued send when the batch is accepted.
+type SendBatchRecipient struct {
+	To     string     `json:"to"`
+	SendID *uuid.UUID `json:"send_id,omitempty"`
+	Status string     `json:"status"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+// SendBatchResult is the outcome of a batch: either every recipient is queued or none is
+type SendBatchResult struct {
+	Accepted   bool                 `json:"accepted"`
+	Recipients []SendBatchRecipient `json:"recipients"`
+}
+
+// Batch recipient statuses besides the async send statuses
+const (
+	SendStatusInvalid  = "invalid"
+	SendStatusRejected = "rejected" // valid, but not sent because the batch was rejected
+)
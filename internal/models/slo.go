@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Delivery SLOs
+const (
+	SLOInboundForward   = "inbound_forward"
+	SLOOutboundDelivery = "outbound_delivery"
+)
+
+// SLOReport is the compliance of every SLO over its windows
+type SLOReport struct {
+	SLOs        []*SLOStatus `json:"slos"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// SLOStatus is the compliance of one SLO
+type SLOStatus struct {
+	Name                 string       `json:"name"`
+	Description          string       `json:"description"`
+	Target               float64      `json:"target"`
+	Threshold            string       `json:"threshold"`
+	Windows              []*SLOWindow `json:"windows"`
+	ErrorBudgetRemaining float64      `json:"error_budget_remaining"` // share of the budget left over the SLO window
+}
+
+// SLOWindow is an SLO's compliance over one trailing window. A burn rate of 1 spends
+// the error budget exactly over the SLO window.
+type SLOWindow struct {
+	Window     string  `json:"window"`
+	Total      int     `json:"total"`
+	Good       int     `json:"good"`
+	Compliance float64 `json:"compliance"`
+	BurnRate   float64 `json:"burn_rate"`
+}
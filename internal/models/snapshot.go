@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Snapshot message roles, as used by chat LLM APIs
+const (
+	SnapshotRoleUser      = "user"
+	SnapshotRoleAssistant = "assistant"
+)
+
+// ConversationSnapshot is the recent conversation with a user, oldest first, trimmed to
+// a message count and character budget
+type ConversationSnapshot struct {
+	UserID    uuid.UUID         `json:"user_id"`
+	Messages  []SnapshotMessage `json:"messages"`
+	Chars     int               `json:"chars"`     // content characters in messages
+	MaxChars  int               `json:"max_chars"` // the budget the snapshot was trimmed to
+	Truncated bool              `json:"truncated"` // older messages were left out or the oldest one was cut
+}
+
+// SnapshotMessage is one conversation turn ready for an LLM prompt
+type SnapshotMessage struct {
+	ID        uuid.UUID   `json:"id"`
+	Role      string      `json:"role"`
+	Content   string      `json:"content"`
+	Type      MessageType `json:"type"`
+	Channel   Channel     `json:"channel,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
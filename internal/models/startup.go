@@ -0,0 +1,15 @@
+package models
+
+// Outcomes of a startup check
+const (
+	StartupCheckOK      = "ok"
+	StartupCheckFailed  = "failed"
+	StartupCheckSkipped = "skipped"
+)
+
+// StartupCheck is the outcome of a compatibility check run at boot
+type StartupCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageStatusEvent is one status callback received for a message. Callbacks are kept
+// even when no stored message matches their SID yet, as happens when they arrive before
+// the send completes.
+type MessageStatusEvent struct {
+	ID             uuid.UUID     `json:"id" db:"id"`
+	MessageID      *uuid.UUID    `json:"message_id,omitempty" db:"message_id"`
+	TwilioSID      string        `json:"twilio_sid" db:"twilio_sid"`
+	Status         MessageStatus `json:"status" db:"status"`
+	ProviderStatus string        `json:"provider_status,omitempty" db:"provider_status"`
+	ErrorCode      *string       `json:"error_code,omitempty" db:"error_code"`
+	ErrorMessage   *string       `json:"error_message,omitempty" db:"error_message"`
+	PayloadRef     *string       `json:"payload_ref,omitempty" db:"payload_ref"`
+	ReceivedAt     time.Time     `json:"received_at" db:"received_at"`
+}
+
+// MessageStatusHistory lists the status callbacks of a message in the order received
+type MessageStatusHistory struct {
+	MessageID uuid.UUID             `json:"message_id"`
+	TwilioSID string                `json:"twilio_sid"`
+	Status    MessageStatus         `json:"status"`
+	Events    []*MessageStatusEvent `json:"events"`
+}
@@ -0,0 +1,71 @@
+package models
+
+// TelegramUpdate represents an update from the Telegram Bot API (webhook or getUpdates)
+type TelegramUpdate struct {
+	UpdateID      int64            `json:"update_id"`
+	Message       *TelegramMessage `json:"message,omitempty"`
+	EditedMessage *TelegramMessage `json:"edited_message,omitempty"` // carries live location updates
+}
+
+// TelegramMessage is an incoming Telegram message; only the fields we map are decoded
+type TelegramMessage struct {
+	MessageID int64             `json:"message_id"`
+	From      *TelegramUser     `json:"from,omitempty"`
+	Chat      TelegramChat      `json:"chat"`
+	Date      int64             `json:"date"`
+	EditDate  int64             `json:"edit_date,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Caption   string            `json:"caption,omitempty"`
+	Photo     []TelegramFile    `json:"photo,omitempty"`
+	Document  *TelegramFile     `json:"document,omitempty"`
+	Audio     *TelegramFile     `json:"audio,omitempty"`
+	Voice     *TelegramFile     `json:"voice,omitempty"`
+	Video     *TelegramFile     `json:"video,omitempty"`
+	VideoNote *TelegramFile     `json:"video_note,omitempty"`
+	Location  *TelegramLocation `json:"location,omitempty"`
+	Contact   *TelegramContact  `json:"contact,omitempty"`
+	Poll      *TelegramPoll     `json:"poll,omitempty"`
+}
+
+// TelegramUser is the sender of a message
+type TelegramUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// TelegramChat is the chat a message belongs to; replies are addressed to its ID
+type TelegramChat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// TelegramFile covers photo sizes, documents, audio, voice and video attachments
+type TelegramFile struct {
+	FileID   string `json:"file_id"`
+	FileSize int64  `json:"file_size,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+}
+
+// TelegramLocation is a shared location; LivePeriod is set when it is shared live
+type TelegramLocation struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	LivePeriod int     `json:"live_period,omitempty"`
+}
+
+// TelegramPoll is a poll sent into the chat
+type TelegramPoll struct {
+	ID       string `json:"id"`
+	Question string `json:"question"`
+}
+
+// TelegramContact is a shared contact card
+type TelegramContact struct {
+	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name,omitempty"`
+}
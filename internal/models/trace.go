@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Processing stages of a message trace
+const (
+	TraceStageWebhookReceived = "webhook_received"
+	TraceStageStored          = "stored"
+	TraceStageMedia           = "media"        // one pipeline stage of an attachment; Detail names it
+	TraceStageAIResult        = "ai_result"    // Detail names the analysis type
+	TraceStageOrchestrator    = "orchestrator" // outbox work for the orchestrator; Detail names the kind
+	TraceStageDeadLetter      = "dead_letter"  // Detail names the ingest stage that panicked
+	TraceStageReplyStored     = "reply_stored"
+	TraceStageStatus          = "status" // a status callback for the message or its reply
+)
+
+// TraceEvent is one step in the processing of a message
+type TraceEvent struct {
+	Stage      string     `json:"stage"`
+	Status     string     `json:"status,omitempty"`
+	Detail     string     `json:"detail,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+	At         time.Time  `json:"at"`
+	DurationMS *int64     `json:"duration_ms,omitempty"` // for steps with a known start
+	MessageID  *uuid.UUID `json:"message_id,omitempty"`  // set on events of the reply
+}
+
+// MessageTrace is the processing timeline of one message, oldest event first
+type MessageTrace struct {
+	MessageID      uuid.UUID        `json:"message_id"`
+	Direction      MessageDirection `json:"direction"`
+	Status         MessageStatus    `json:"status"`
+	ReplyMessageID *uuid.UUID       `json:"reply_message_id,omitempty"`
+	Events         []*TraceEvent    `json:"events"`
+}
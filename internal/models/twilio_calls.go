@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// TwilioAPICall describes one request to the Twilio REST API. Authorization headers
+// are never recorded and request parameters are listed by name only.
+type TwilioAPICall struct {
+	ID              uint64    `json:"id"`
+	Method          string    `json:"method"`
+	URL             string    `json:"url"`
+	Params          []string  `json:"params,omitempty"`
+	StatusCode      int       `json:"status_code,omitempty"`
+	TwilioRequestID string    `json:"twilio_request_id,omitempty"`
+	ErrorBody       string    `json:"error_body,omitempty"`
+	Error           string    `json:"error,omitempty"`
+	DurationMs      float64   `json:"duration_ms"`
+	StartedAt       time.Time `json:"started_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pre-send checks reported by message validation
+const (
+	ValidationCheckChannel         = "channel"
+	ValidationCheckRecipient       = "recipient_format"
+	ValidationCheckSuppression     = "suppression"
+	ValidationCheckDuplicate       = "duplicate"
+	ValidationCheckNotificationCap = "notification_cap"
+	ValidationCheckWindow          = "window"
+	ValidationCheckTemplate        = "template"
+	ValidationCheckPolicy          = "policy"
+	ValidationCheckMedia           = "media"
+)
+
+// ValidationCheck is the outcome of one pre-send check. Skipped checks do not apply
+// to the request (e.g., the window check for email) and do not fail it.
+type ValidationCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// SendValidationResult is the verdict for a send request that was checked but not sent
+type SendValidationResult struct {
+	Valid     bool               `json:"valid"`
+	Channel   Channel            `json:"channel"`
+	Provider  string             `json:"provider,omitempty"`
+	Recipient string             `json:"recipient"`
+	Window    *ChatContextWindow `json:"window,omitempty"`
+	Checks    []ValidationCheck  `json:"checks"`
+}
+
+// RecipientSuppression blocks all outbound messages to a recipient on a channel
+type RecipientSuppression struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Channel   Channel   `json:"channel" db:"channel"`
+	Recipient string    `json:"recipient" db:"recipient"`
+	Reason    *string   `json:"reason,omitempty" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SuppressRecipientRequest represents an admin request to add a recipient to the suppression list
+type SuppressRecipientRequest struct {
+	Channel   Channel `json:"channel"`
+	Recipient string  `json:"recipient" binding:"required"`
+	Reason    *string `json:"reason,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEvent is a raw webhook request kept for forensic audit
+type WebhookEvent struct {
+	ID         uuid.UUID         `json:"id" db:"id"`
+	Source     string            `json:"source" db:"source"`
+	Method     string            `json:"method" db:"method"`
+	Path       string            `json:"path" db:"path"`
+	Headers    map[string]string `json:"headers" db:"headers"`
+	Body       []byte            `json:"body" db:"body"` // base64 in JSON
+	Truncated  bool              `json:"truncated" db:"truncated"`
+	StatusCode int               `json:"status_code" db:"status_code"`
+	ReceivedAt time.Time         `json:"received_at" db:"received_at"`
+}
+
+// Webhook secret slots
+const (
+	WebhookSecretPrimary   = "primary"   // WHATSAPP_WEBHOOK_SECRET
+	WebhookSecretSecondary = "secondary" // WHATSAPP_WEBHOOK_SECRET_SECONDARY
+)
+
+// WebhookSecretUsage is when webhooks signed with a configured secret were seen. The
+// secret is identified by a fingerprint, the start of its SHA-256, never by its value.
+type WebhookSecretUsage struct {
+	Slot        string     `json:"slot"`
+	Fingerprint string     `json:"fingerprint"`
+	FirstSeenAt *time.Time `json:"first_seen_at,omitempty" db:"first_seen_at"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty" db:"last_seen_at"`
+}
+
+// WebhookSecretReport is the state of a webhook secret rotation. Retirable is set once
+// the secondary secret has not been seen since webhooks started arriving signed with
+// the primary one.
+type WebhookSecretReport struct {
+	Source    string                `json:"source"`
+	Secrets   []*WebhookSecretUsage `json:"secrets"`
+	Retirable bool                  `json:"retirable"`
+}
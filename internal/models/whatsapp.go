@@ -23,6 +23,7 @@ const (
 	MessageStatusDelivered MessageStatus = "delivered"
 	MessageStatusRead      MessageStatus = "read"
 	MessageStatusFailed    MessageStatus = "failed"
+	MessageStatusCanceled  MessageStatus = "canceled"
 )
 
 // MessageType represents the type of message content
@@ -36,6 +37,24 @@ const (
 	MessageTypeVideo    MessageType = "video"
 	MessageTypeLocation MessageType = "location"
 	MessageTypeContact  MessageType = "contact"
+	MessageTypeFlow     MessageType = "flow" // WhatsApp Flow form, or the user's submission of one
+	MessageTypePayment  MessageType = "payment" // order/payment request template
+
+	MessageTypeLiveLocation    MessageType = "live_location"    // location shared live; updates replace its coordinates
+	MessageTypeLocationRequest MessageType = "location_request" // interactive prompt asking the user to share their location
+	MessageTypeAppointment     MessageType = "appointment"      // appointment confirmation template
+)
+
+// Channel identifies the messaging network a message travels over
+type Channel string
+
+const (
+	ChannelWhatsApp  Channel = "whatsapp"
+	ChannelSMS       Channel = "sms"
+	ChannelMessenger Channel = "messenger"
+	ChannelInstagram Channel = "instagram"
+	ChannelTelegram  Channel = "telegram"
+	ChannelEmail     Channel = "email"
 )
 
 // WhatsAppMessage represents a WhatsApp message in our system
@@ -59,6 +78,92 @@ type WhatsAppMessage struct {
 	SessionID   *uuid.UUID `json:"session_id,omitempty" db:"session_id"`
 	ErrorCode   *string    `json:"error_code,omitempty" db:"error_code"`
 	ErrorMsg    *string    `json:"error_message,omitempty" db:"error_message"`
+
+	// Policy enforcement
+	Flagged    bool    `json:"flagged" db:"flagged"`
+	FlagReason *string `json:"flag_reason,omitempty" db:"flag_reason"`
+
+	// Media processing results
+	ExtractedText *string `json:"extracted_text,omitempty" db:"extracted_text"`
+	Transcript    *string `json:"transcript,omitempty" db:"transcript"`
+	MediaSHA256   *string `json:"media_sha256,omitempty" db:"media_sha256"` // hash of the downloaded media
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" db:"revoked_at"` // the user deleted the message for everyone
+
+	// Answers of a submitted WhatsApp Flow
+	FlowResponse *FlowResponse `json:"flow_response,omitempty" db:"flow_response"`
+
+	// Channel the message was sent or received on; TwilioSID holds the
+	// provider's message ID for non-Twilio channels
+	Channel Channel `json:"channel" db:"channel"`
+
+	// SMS fallback linkage
+	Category          *string    `json:"category,omitempty" db:"category"`
+	FallbackMessageID *uuid.UUID `json:"fallback_message_id,omitempty" db:"fallback_message_id"`
+	FallbackOf        *uuid.UUID `json:"fallback_of,omitempty" db:"fallback_of"`
+
+	// Set when content and media were replaced with tombstones
+	RedactedAt *time.Time `json:"redacted_at,omitempty" db:"redacted_at"`
+
+	// Text of an inbound message as received, when the normalization stage changed it
+	RawContent *string `json:"raw_content,omitempty" db:"raw_content"`
+
+	// Translated messages: the text as written, and the language of the user's side of the
+	// conversation. Content holds the translation: in the working language for inbound
+	// messages, in the user's language for outbound ones.
+	OriginalContent *string `json:"original_content,omitempty" db:"original_content"`
+	Language        *string `json:"language,omitempty" db:"language"`
+
+	// Sentiment of inbound text from -1 (negative) to 1 (positive), when scored
+	Sentiment *float64 `json:"sentiment,omitempty" db:"sentiment"`
+
+	// Classifier labels of inbound text, e.g. "wants_valuation"
+	Labels []string `json:"labels,omitempty" db:"labels"`
+
+	// Structured data attached by the orchestrator
+	Metadata Metadata `json:"metadata" db:"metadata"`
+
+	// Recorded webhook request the message was parsed from, when webhooks are recorded
+	WebhookEventID *uuid.UUID `json:"webhook_event_id,omitempty" db:"webhook_event_id"`
+
+	// Set on historical messages brought in through the import API
+	ImportedAt *time.Time `json:"imported_at,omitempty" db:"imported_at"`
+
+	// Set on messages served from a conversation archive
+	Archived bool `json:"archived,omitempty" db:"-"`
+
+	// Provider type of an inbound message the pipeline cannot handle, e.g. "poll";
+	// set while parsing and recorded in the flag reason as "unsupported_type: poll"
+	UnsupportedType string `json:"unsupported_type,omitempty" db:"-"`
+
+	// ID of the template quick reply button the user tapped; set while parsing
+	ButtonPayload string `json:"button_payload,omitempty" db:"-"`
+}
+
+// Sections GET /messages/:messageId can include with the message, as ?include=a,b
+const (
+	MessageIncludeMedia         = "media"
+	MessageIncludeStatusHistory = "status_history"
+	MessageIncludeAIResults     = "ai_results"
+	MessageIncludeSession       = "session"
+)
+
+// MessageDetail is a message with the related records requested with ?include. Sections
+// that were not requested, or have nothing to show, are left out.
+type MessageDetail struct {
+	*WhatsAppMessage
+	Media         []*MediaObject        `json:"media,omitempty"`
+	StatusHistory *MessageStatusHistory `json:"status_history,omitempty"`
+	AIResults     []*AIResult           `json:"ai_results,omitempty"`
+	Session       *ChatSession          `json:"session,omitempty"`
+}
+
+// TranscriptionResult represents a speech-to-text result posted back by the AI processing service
+type TranscriptionResult struct {
+	MessageID       string  `json:"message_id" binding:"required"`
+	Transcript      string  `json:"transcript"`
+	Language        string  `json:"language,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Confidence      float64 `json:"confidence,omitempty"`
 }
 
 // TwilioWebhookRequest represents incoming webhook payload from Twilio
@@ -80,11 +185,49 @@ type TwilioWebhookRequest struct {
 	ErrorCode           string `form:"ErrorCode" json:"ErrorCode"`
 	ErrorMessage        string `form:"ErrorMessage" json:"ErrorMessage"`
 
+	// Interactive replies; InteractiveData carries a Flow submission as JSON
+	MessageType     string `form:"MessageType" json:"MessageType"`
+	InteractiveData string `form:"InteractiveData" json:"InteractiveData"`
+
+	// Quick reply buttons of templates; ButtonPayload is the ID of the tapped button
+	ButtonText    string `form:"ButtonText" json:"ButtonText"`
+	ButtonPayload string `form:"ButtonPayload" json:"ButtonPayload"`
+
+	// Shared locations
+	Latitude  string `form:"Latitude" json:"Latitude"`
+	Longitude string `form:"Longitude" json:"Longitude"`
+	Address   string `form:"Address" json:"Address"`
+	Label     string `form:"Label" json:"Label"`
+
 	// Profile information
 	ProfileName string `form:"ProfileName" json:"ProfileName"`
 	WaId        string `form:"WaId" json:"WaId"`
 }
 
+// Twilio Conversations webhook event types
+const (
+	ConversationEventMessageAdded    = "onMessageAdded"
+	ConversationEventDeliveryUpdated = "onDeliveryUpdated"
+	ConversationEventMessageRemoved  = "onMessageRemoved"
+)
+
+// ConversationsWebhookRequest represents a Twilio Conversations post-event webhook
+type ConversationsWebhookRequest struct {
+	EventType       string `form:"EventType" json:"EventType"`
+	ConversationSid string `form:"ConversationSid" json:"ConversationSid"`
+	ChatServiceSid  string `form:"ChatServiceSid" json:"ChatServiceSid"`
+	MessageSid      string `form:"MessageSid" json:"MessageSid"`
+	ParticipantSid  string `form:"ParticipantSid" json:"ParticipantSid"`
+	Author          string `form:"Author" json:"Author"`
+	Body            string `form:"Body" json:"Body"`
+	Media           string `form:"Media" json:"Media"` // JSON array of attachments
+	DateCreated     string `form:"DateCreated" json:"DateCreated"`
+
+	// Delivery receipt fields
+	Status    string `form:"Status" json:"Status"`
+	ErrorCode string `form:"ErrorCode" json:"ErrorCode"`
+}
+
 // SendMessageRequest represents a request to send a WhatsApp message
 type SendMessageRequest struct {
 	To        string            `json:"to" validate:"required"`
@@ -94,6 +237,25 @@ type SendMessageRequest struct {
 	MediaType *string           `json:"media_type,omitempty"`
 	Variables map[string]string `json:"variables,omitempty"`
 	Template  *string           `json:"template,omitempty"`
+
+	// SkipTranslation sends the content as given, e.g. when an agent already wrote it in
+	// the user's language
+	SkipTranslation bool `json:"skip_translation,omitempty"`
+
+	// AllowDuplicate bypasses duplicate suppression for intentional resends
+	AllowDuplicate bool `json:"allow_duplicate,omitempty"`
+
+	// Channel selects the outbound network; defaults to WhatsApp
+	Channel Channel `json:"channel,omitempty"`
+
+	// Provider selects a stored provider configuration by name instead of the channel default
+	Provider string `json:"provider,omitempty"`
+
+	// Category (e.g., "transactional", "marketing") selects the SMS fallback policy
+	Category *string `json:"category,omitempty"`
+
+	// Async queues the send and answers 202 with a tracking ID instead of waiting for the provider
+	Async bool `json:"async,omitempty"`
 }
 
 // SendMessageResponse represents the response from sending a message
@@ -111,27 +273,89 @@ type MessageStatusUpdate struct {
 	ErrorCode    *string       `json:"error_code,omitempty"`
 	ErrorMessage *string       `json:"error_message,omitempty"`
 	Timestamp    time.Time     `json:"timestamp"`
+
+	// Status as the provider reported it, before mapping
+	ProviderStatus string `json:"provider_status,omitempty"`
+
+	// Identifies the raw callback, e.g. Twilio's I-Twilio-Idempotency-Token header
+	PayloadRef *string `json:"payload_ref,omitempty"`
 }
 
 // User represents a WhatsApp user in our system
 type User struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	PhoneNumber string    `json:"phone_number" db:"phone_number"`
-	WhatsAppID  string    `json:"whatsapp_id" db:"whatsapp_id"`
-	ProfileName string    `json:"profile_name" db:"profile_name"`
-	IsActive    bool      `json:"is_active" db:"is_active"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                uuid.UUID `json:"id" db:"id"`
+	PhoneNumber       string    `json:"phone_number" db:"phone_number"`
+	WhatsAppID        string    `json:"whatsapp_id" db:"whatsapp_id"`
+	ProfileName       string    `json:"profile_name" db:"profile_name"`
+	IsActive          bool      `json:"is_active" db:"is_active"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	Locale            string    `json:"locale,omitempty" db:"locale"`               // e.g. "pt-BR"; empty uses DEFAULT_LOCALE
+	ConsentCategories []string  `json:"consent_categories" db:"consent_categories"` // notification categories the user opted in to
+	Timezone          string    `json:"timezone,omitempty" db:"timezone"`           // IANA zone; empty infers it from the phone number
 }
 
+// Session statuses
+const (
+	SessionStatusActive = "active"
+	SessionStatusClosed = "closed"
+)
+
+// Session close reasons
+const (
+	SessionCloseReasonTimeout  = "timeout"
+	SessionCloseReasonExplicit = "explicit"
+	SessionCloseReasonMerged   = "merged"
+
+	// A second active session of the same user, closed when one active session per
+	// user became a constraint
+	SessionCloseReasonDuplicate = "duplicate"
+)
+
+// ConversationState is the adapter-side state of a conversation flow
+type ConversationState string
+
+const (
+	ConversationStateIdle                 ConversationState = "idle"
+	ConversationStateAwaitingDocument     ConversationState = "awaiting_document"
+	ConversationStateAwaitingConfirmation ConversationState = "awaiting_confirmation"
+	ConversationStateHandoff              ConversationState = "handoff" // a human agent took over
+)
+
 // ChatSession represents a chat conversation session
 type ChatSession struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Status    string    `json:"status" db:"status"`
-	Context   string    `json:"context" db:"context"`
-	StartedAt time.Time `json:"started_at" db:"started_at"`
-	EndedAt   *time.Time `json:"ended_at,omitempty" db:"ended_at"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID             uuid.UUID         `json:"id" db:"id"`
+	UserID         uuid.UUID         `json:"user_id" db:"user_id"`
+	Status         string            `json:"status" db:"status"`
+	Context        string            `json:"context" db:"context"`
+	StartedAt      time.Time         `json:"started_at" db:"started_at"`
+	EndedAt        *time.Time        `json:"ended_at,omitempty" db:"ended_at"`
+	LastActivityAt time.Time         `json:"last_activity_at" db:"last_activity_at"`
+	CloseReason    *string           `json:"close_reason,omitempty" db:"close_reason"`
+	Summary        *string           `json:"summary,omitempty" db:"summary"`
+	SummarizedAt   *time.Time        `json:"summarized_at,omitempty" db:"summarized_at"`
+	State          ConversationState `json:"state" db:"state"`
+	StateUpdatedAt *time.Time        `json:"state_updated_at,omitempty" db:"state_updated_at"`
+	Tags           []string          `json:"tags" db:"tags"`
+	Metadata       Metadata          `json:"metadata" db:"metadata"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+
+	// Automated replies are suppressed until MutedUntil; Muted reports whether that is now
+	Muted      bool       `json:"muted" db:"-"`
+	MutedUntil *time.Time `json:"muted_until,omitempty" db:"muted_until"`
+	MuteReason *string    `json:"mute_reason,omitempty" db:"mute_reason"`
+
+	// Agent handling the conversation after a handoff
+	AssignedAgentID *uuid.UUID `json:"assigned_agent_id,omitempty" db:"assigned_agent_id"`
+	AssignedAt      *time.Time `json:"assigned_at,omitempty" db:"assigned_at"`
+
+	// New reports whether the session was started by the request that resolved it
+	New bool `json:"-" db:"-"`
+}
+
+// MuteSessionRequest mutes automated replies in a conversation for a number of hours
+type MuteSessionRequest struct {
+	Hours  float64 `json:"hours" binding:"required,gt=0"`
+	Reason string  `json:"reason,omitempty"`
 }
\ No newline at end of file
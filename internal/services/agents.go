@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Agent errors
+var (
+	ErrAgentNotFound      = errors.New("agent not found")
+	ErrAgentExists        = errors.New("an agent with this email already exists")
+	ErrInvalidPresence    = errors.New("invalid presence")
+	ErrSessionAssigned    = errors.New("session is assigned to another agent")
+	ErrSessionNotAssigned = errors.New("session is not assigned to this agent")
+	ErrNoAgentAvailable   = errors.New("no agent available")
+)
+
+var sessionAssignmentsTotal = metrics.NewCounter("session_assignments_total", "Conversations assigned to agents, by method", "method")
+
+// agentColumns lists the agents columns in the order scanAgent expects; the last column
+// counts the agent's active conversations
+const agentColumns = `
+	a.id, a.name, a.email, a.presence, a.max_concurrent, a.is_active, a.last_assigned_at,
+	a.last_seen_at, a.created_at, a.updated_at,
+	(SELECT COUNT(*) FROM chat_sessions s WHERE s.assigned_agent_id = a.id AND s.status = 'active')`
+
+// AgentService keeps the agents who take over handed-off conversations and which
+// conversation each of them handles. The adapter is the source of truth for
+// assignments; the agent console claims and releases conversations through it.
+type AgentService struct {
+	db           *pgxpool.Pool
+	eventService *EventService
+	sessions     *SessionStore
+	logger       *logrus.Logger
+}
+
+// NewAgentService creates a new agent service instance
+func NewAgentService(db *pgxpool.Pool, eventService *EventService, sessions *SessionStore, logger *logrus.Logger) *AgentService {
+	return &AgentService{
+		db:           db,
+		eventService: eventService,
+		sessions:     sessions,
+		logger:       logger,
+	}
+}
+
+// Create stores a new agent, offline until the console reports presence
+func (s *AgentService) Create(ctx context.Context, request *models.AgentRequest) (*models.Agent, error) {
+	maxConcurrent := 0
+	if request.MaxConcurrent != nil {
+		maxConcurrent = *request.MaxConcurrent
+	}
+
+	agent, err := scanAgent(s.db.QueryRow(ctx, `
+		WITH a AS (
+			INSERT INTO agents (id, name, email, presence, max_concurrent, is_active, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, true, NOW(), NOW())
+			RETURNING *
+		)
+		SELECT`+agentColumns+` FROM a`,
+		uuid.New(), strings.TrimSpace(request.Name), strings.ToLower(strings.TrimSpace(request.Email)),
+		models.AgentPresenceOffline, maxConcurrent,
+	))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrAgentExists
+		}
+		return nil, fmt.Errorf("failed to create agent: %w", err)
+	}
+	return agent, nil
+}
+
+// List returns every active agent by name
+func (s *AgentService) List(ctx context.Context) ([]*models.Agent, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+agentColumns+`
+		FROM agents a
+		WHERE a.is_active
+		ORDER BY a.name, a.id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := []*models.Agent{}
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan agent: %w", err)
+		}
+		agents = append(agents, agent)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading agents: %w", err)
+	}
+
+	return agents, nil
+}
+
+// SetPresence records an agent's presence and when it was last seen. An agent coming
+// online is given the conversations waiting for an agent, up to its capacity.
+func (s *AgentService) SetPresence(ctx context.Context, agentID uuid.UUID, presence models.AgentPresence) (*models.Agent, error) {
+	if !presence.Valid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPresence, presence)
+	}
+
+	agent, err := scanAgent(s.db.QueryRow(ctx, `
+		WITH a AS (
+			UPDATE agents SET presence = $2, last_seen_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND is_active
+			RETURNING *
+		)
+		SELECT`+agentColumns+` FROM a`, agentID, presence))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAgentNotFound
+		}
+		return nil, fmt.Errorf("failed to update agent presence: %w", err)
+	}
+
+	if presence == models.AgentPresenceOnline {
+		if assigned := s.AssignWaiting(ctx); assigned > 0 {
+			s.logger.WithField("assigned", assigned).Info("Waiting conversations assigned after agent came online")
+		}
+	}
+
+	return agent, nil
+}
+
+// Claim assigns a conversation to an agent and hands it off from the orchestrator.
+// Claiming a conversation the agent already holds is a no-op; one held by another
+// agent returns ErrSessionAssigned.
+func (s *AgentService) Claim(ctx context.Context, sessionID, agentID uuid.UUID) (*models.ChatSession, error) {
+	var active bool
+	err := s.db.QueryRow(ctx, `SELECT is_active FROM agents WHERE id = $1`, agentID).Scan(&active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		return nil, ErrAgentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agent: %w", err)
+	}
+
+	session, err := scanSession(s.db.QueryRow(ctx, `
+		UPDATE chat_sessions
+		SET assigned_agent_id = $2,
+			assigned_at = CASE WHEN assigned_agent_id = $2 THEN assigned_at ELSE NOW() END,
+			state = $3, state_updated_at = CASE WHEN state = $3 THEN state_updated_at ELSE NOW() END,
+			updated_at = NOW()
+		WHERE id = $1 AND (assigned_agent_id IS NULL OR assigned_agent_id = $2)
+		RETURNING`+sessionColumns,
+		sessionID, agentID, models.ConversationStateHandoff,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, s.assignmentConflict(ctx, sessionID)
+		}
+		return nil, fmt.Errorf("failed to claim session: %w", err)
+	}
+
+	s.assigned(ctx, session, models.AssignmentMethodClaim)
+	return session, nil
+}
+
+// Release takes a conversation away from the agent holding it. With resumeBot the
+// orchestrator answers again; otherwise the conversation stays handed off and goes to
+// the next available agent.
+func (s *AgentService) Release(ctx context.Context, sessionID, agentID uuid.UUID, resumeBot bool) (*models.ChatSession, error) {
+	state := models.ConversationStateHandoff
+	if resumeBot {
+		state = models.ConversationStateIdle
+	}
+
+	session, err := scanSession(s.db.QueryRow(ctx, `
+		UPDATE chat_sessions
+		SET assigned_agent_id = NULL, assigned_at = NULL,
+			state = $3, state_updated_at = CASE WHEN state = $3 THEN state_updated_at ELSE NOW() END,
+			updated_at = NOW()
+		WHERE id = $1 AND assigned_agent_id = $2
+		RETURNING`+sessionColumns,
+		sessionID, agentID, state,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if err := s.assignmentConflict(ctx, sessionID); errors.Is(err, ErrSessionNotFound) {
+				return nil, err
+			}
+			return nil, ErrSessionNotAssigned
+		}
+		return nil, fmt.Errorf("failed to release session: %w", err)
+	}
+	s.sessions.Forget(ctx, session.ID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"agent_id":   agentID,
+		"resume_bot": resumeBot,
+	}).Info("Conversation released by agent")
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventSessionReleased,
+		SessionID: &session.ID,
+		Data: map[string]interface{}{
+			"agent_id":   agentID,
+			"resume_bot": resumeBot,
+		},
+	})
+
+	if !resumeBot && session.Status == models.SessionStatusActive {
+		if assigned, err := s.AutoAssign(ctx, session.ID); err == nil {
+			session = assigned
+		} else if !errors.Is(err, ErrNoAgentAvailable) {
+			s.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to reassign released conversation")
+		}
+	}
+
+	return session, nil
+}
+
+// AutoAssign gives an unassigned active conversation to the online agent with spare
+// capacity who was assigned a conversation longest ago (round robin). It returns
+// ErrNoAgentAvailable when every online agent is at capacity.
+func (s *AgentService) AutoAssign(ctx context.Context, sessionID uuid.UUID) (*models.ChatSession, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin assignment: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	session, err := scanSession(tx.QueryRow(ctx, `SELECT`+sessionColumns+`
+		FROM chat_sessions WHERE id = $1 FOR UPDATE`, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if session.AssignedAgentID != nil || session.Status != models.SessionStatusActive {
+		return session, nil
+	}
+
+	// Agents being assigned by another replica are skipped rather than waited for
+	var agentID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT a.id FROM agents a
+		WHERE a.is_active AND a.presence = $1
+			AND (a.max_concurrent <= 0 OR (
+				SELECT COUNT(*) FROM chat_sessions s
+				WHERE s.assigned_agent_id = a.id AND s.status = 'active') < a.max_concurrent)
+		ORDER BY a.last_assigned_at NULLS FIRST, a.id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, models.AgentPresenceOnline).Scan(&agentID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoAgentAvailable
+		}
+		return nil, fmt.Errorf("failed to pick agent: %w", err)
+	}
+
+	session, err = scanSession(tx.QueryRow(ctx, `
+		UPDATE chat_sessions
+		SET assigned_agent_id = $2, assigned_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING`+sessionColumns, sessionID, agentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign session: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE agents SET last_assigned_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, agentID); err != nil {
+		return nil, fmt.Errorf("failed to update agent: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit assignment: %w", err)
+	}
+
+	s.assigned(ctx, session, models.AssignmentMethodAuto)
+	return session, nil
+}
+
+// AssignWaiting auto-assigns handed-off conversations that have no agent, oldest
+// first, until none are left or no agent is available. It returns how many were
+// assigned.
+func (s *AgentService) AssignWaiting(ctx context.Context) int {
+	rows, err := s.db.Query(ctx, `
+		SELECT id FROM chat_sessions
+		WHERE status = 'active' AND state = $1 AND assigned_agent_id IS NULL
+		ORDER BY started_at
+		LIMIT 100`, models.ConversationStateHandoff)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to query conversations waiting for an agent")
+		return 0
+	}
+	var waiting []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			waiting = append(waiting, id)
+		}
+	}
+	rows.Close()
+
+	assigned := 0
+	for _, id := range waiting {
+		if _, err := s.AutoAssign(ctx, id); err != nil {
+			if !errors.Is(err, ErrNoAgentAvailable) {
+				s.logger.WithError(err).WithField("session_id", id).Warn("Failed to assign waiting conversation")
+			}
+			break
+		}
+		assigned++
+	}
+	return assigned
+}
+
+// ListSessions returns the active conversations assigned to an agent, oldest assignment first
+func (s *AgentService) ListSessions(ctx context.Context, agentID uuid.UUID) ([]*models.ChatSession, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+sessionColumns+`
+		FROM chat_sessions
+		WHERE assigned_agent_id = $1 AND status = 'active'
+		ORDER BY assigned_at, id`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.ChatSession{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading agent sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Helper functions
+
+// assignmentConflict explains why a session could not be claimed or released
+func (s *AgentService) assignmentConflict(ctx context.Context, sessionID uuid.UUID) error {
+	var exists bool
+	if err := s.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM chat_sessions WHERE id = $1)`, sessionID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if !exists {
+		return ErrSessionNotFound
+	}
+	return ErrSessionAssigned
+}
+
+// assigned records and publishes a new assignment
+func (s *AgentService) assigned(ctx context.Context, session *models.ChatSession, method string) {
+	sessionAssignmentsTotal.Inc(method)
+	s.sessions.Forget(ctx, session.ID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"agent_id":   session.AssignedAgentID,
+		"method":     method,
+	}).Info("Conversation assigned to agent")
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventSessionAssigned,
+		SessionID: &session.ID,
+		Data: map[string]interface{}{
+			"agent_id": session.AssignedAgentID,
+			"method":   method,
+		},
+	})
+}
+
+// scanAgent scans an agents row selected with agentColumns
+func scanAgent(row pgx.Row) (*models.Agent, error) {
+	var agent models.Agent
+	err := row.Scan(
+		&agent.ID,
+		&agent.Name,
+		&agent.Email,
+		&agent.Presence,
+		&agent.MaxConcurrent,
+		&agent.IsActive,
+		&agent.LastAssignedAt,
+		&agent.LastSeenAt,
+		&agent.CreatedAt,
+		&agent.UpdatedAt,
+		&agent.ActiveConversations,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
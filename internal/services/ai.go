@@ -4,16 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/signing"
 )
 
+// ErrDuplicateForward is returned when the orchestrator already processed the message
+var ErrDuplicateForward = errors.New("orchestrator already processed this message")
+
 // AIService handles communication with AI processing services
 type AIService struct {
 	config            *config.Config
@@ -21,6 +27,16 @@ type AIService struct {
 	httpClient        *http.Client
 	orchestratorURL   string
 	aiProcessingURL   string
+
+	// Request signers; nil when signing is not configured for a service
+	orchestratorSigner *signing.Signer
+	aiProcessingSigner *signing.Signer
+
+	// Replaces credentialed media URLs in orchestrator payloads; nil passes them through
+	mediaService *MediaService
+
+	// Mirrors forwards to a shadow orchestrator; nil when shadow traffic is off
+	shadow *ShadowService
 }
 
 // NewAIService creates a new AI service instance
@@ -28,24 +44,39 @@ func NewAIService(cfg *config.Config, logger *logrus.Logger) *AIService {
 	return &AIService{
 		config:          cfg,
 		logger:          logger,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient:      newEgressHTTPClient(30 * time.Second),
 		orchestratorURL: cfg.ChatOrchestratorURL,
 		aiProcessingURL: cfg.AIProcessingURL,
+
+		orchestratorSigner: signing.NewSigner(cfg.SigningKeyID, cfg.OrchestratorSigningSecret),
+		aiProcessingSigner: signing.NewSigner(cfg.SigningKeyID, cfg.AIProcessingSigningSecret),
 	}
 }
 
+// UseMediaService applies the orchestrator media URL policy of mediaService to forwards
+func (a *AIService) UseMediaService(mediaService *MediaService) {
+	a.mediaService = mediaService
+}
+
+// UseShadow mirrors a sample of orchestrator forwards to the shadow orchestrator
+func (a *AIService) UseShadow(shadow *ShadowService) {
+	a.shadow = shadow
+}
+
 // ChatRequest represents a request to the chat orchestrator
 type ChatRequest struct {
 	MessageID   string                 `json:"message_id"`
-	UserPhone   string                 `json:"user_phone"`
+	UserPhone   string                 `json:"user_phone"` // channel-scoped sender ID on Meta and Telegram
+	Channel     models.Channel         `json:"channel"`
 	Content     string                 `json:"content"`
 	MessageType models.MessageType     `json:"message_type"`
 	MediaURL    *string               `json:"media_url,omitempty"`
 	MediaType   *string               `json:"media_type,omitempty"`
+	MediaID     *string               `json:"media_id,omitempty"` // fetch with GET /api/v1/messages/:messageId/media
+	FlowResponse *models.FlowResponse `json:"flow_response,omitempty"` // structured answers of a Flow submission
+	Sentiment   *float64              `json:"sentiment,omitempty"`     // -1 (negative) to 1 (positive), when scored
 	Timestamp   time.Time             `json:"timestamp"`
-	Context     map[string]interface{} `json:"context,omitempty"`
+	Context     *models.ChatContext    `json:"context,omitempty"`
 }
 
 // ChatResponse represents a response from the chat orchestrator
@@ -59,10 +90,37 @@ type ChatResponse struct {
 	Context       map[string]interface{} `json:"context,omitempty"`
 	NextAction    string                `json:"next_action,omitempty"`
 	ProcessedAt   time.Time             `json:"processed_at"`
+
+	// Replayed is set when the orchestrator answered with the stored response of an
+	// earlier delivery of the same message
+	Replayed bool `json:"-"`
+}
+
+// TranscriptEntry represents a single message in a conversation transcript
+type TranscriptEntry struct {
+	Direction   models.MessageDirection `json:"direction"`
+	MessageType models.MessageType      `json:"message_type"`
+	Content     string                  `json:"content"`
+	MediaType   *string                 `json:"media_type,omitempty"`
+	Timestamp   time.Time               `json:"timestamp"`
+}
+
+// SummaryRequest represents a transcript summarization request to the AI processing service
+type SummaryRequest struct {
+	SessionID  string            `json:"session_id"`
+	UserID     string            `json:"user_id"`
+	StartedAt  time.Time         `json:"started_at"`
+	EndedAt    *time.Time        `json:"ended_at,omitempty"`
+	Transcript []TranscriptEntry `json:"transcript"`
+}
+
+// SummaryResponse represents the summarization result from the AI processing service
+type SummaryResponse struct {
+	Summary string `json:"summary"`
 }
 
 // ForwardToOrchestrator forwards a message to the chat orchestrator for AI processing
-func (a *AIService) ForwardToOrchestrator(ctx context.Context, message *models.WhatsAppMessage) error {
+func (a *AIService) ForwardToOrchestrator(ctx context.Context, message *models.WhatsAppMessage, chatContext *models.ChatContext) (*ChatResponse, error) {
 	a.logger.WithFields(logrus.Fields{
 		"message_id": message.ID,
 		"from":       message.From,
@@ -73,23 +131,35 @@ func (a *AIService) ForwardToOrchestrator(ctx context.Context, message *models.W
 	request := ChatRequest{
 		MessageID:   message.ID.String(),
 		UserPhone:   message.From,
+		Channel:     message.Channel,
 		Content:     message.Content,
 		MessageType: message.Type,
 		MediaURL:    message.MediaURL,
 		MediaType:   message.MediaType,
+		FlowResponse: message.FlowResponse,
+		Sentiment:   message.Sentiment,
 		Timestamp:   message.Timestamp,
-		Context: map[string]interface{}{
-			"platform":    "whatsapp",
-			"twilio_sid":  message.TwilioSID,
-			"direction":   message.Direction,
-		},
+		Context:     chatContext,
+	}
+
+	// Never hand the orchestrator a URL that only works with our credentials
+	if a.mediaService != nil {
+		var err error
+		request.MediaURL, request.MediaID, err = a.mediaService.OrchestratorMedia(ctx, message)
+		if err != nil {
+			a.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to re-host media for orchestrator; forwarding media ID only")
+		}
 	}
 
 	// Marshal request to JSON
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		a.logger.WithError(err).Error("Failed to marshal chat request")
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if a.shadow != nil {
+		a.shadow.MirrorOrchestrator(message, jsonData)
 	}
 
 	// Send request to orchestrator
@@ -97,49 +167,105 @@ func (a *AIService) ForwardToOrchestrator(ctx context.Context, message *models.W
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		a.logger.WithError(err).Error("Failed to create HTTP request")
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
 
+	// Every delivery of a message carries the same key, so the orchestrator can tell
+	// retries from new messages
+	req.Header.Set("Idempotency-Key", message.ID.String())
+
+	if err := a.orchestratorSigner.Sign(req, jsonData); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
 	// Make the request
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		a.logger.WithError(err).Error("Failed to send request to orchestrator")
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// A conflict means an earlier delivery was processed; retrying would only repeat it
+	if resp.StatusCode == http.StatusConflict {
+		a.logger.WithField("message_id", message.ID).Info("Orchestrator already processed message")
+		return nil, ErrDuplicateForward
+	}
+
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
 		a.logger.WithFields(logrus.Fields{
 			"status_code": resp.StatusCode,
 			"status":      resp.Status,
 		}).Error("Orchestrator returned error status")
-		return fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
 	}
 
 	// Parse response
 	var chatResponse ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResponse); err != nil {
 		a.logger.WithError(err).Error("Failed to decode orchestrator response")
-		return fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	chatResponse.Replayed = resp.Header.Get("Idempotent-Replayed") == "true"
 
 	a.logger.WithFields(logrus.Fields{
 		"response_id":   chatResponse.ResponseID,
 		"should_reply":  chatResponse.ShouldReply,
 		"next_action":   chatResponse.NextAction,
 		"content_len":   len(chatResponse.Content),
+		"replayed":      chatResponse.Replayed,
 	}).Info("Received response from chat orchestrator")
 
 	// TODO: Handle the response - this might involve:
 	// 1. Sending an automated reply if should_reply is true
-	// 2. Triggering additional actions based on next_action
-	// 3. Updating user context/session state
-	// 4. Logging conversation analytics
+	// 2. Logging conversation analytics
+
+	return &chatResponse, nil
+}
+
+// RevocationNotice tells the chat orchestrator that a user deleted a message for everyone
+type RevocationNotice struct {
+	MessageID string         `json:"message_id"`
+	UserPhone string         `json:"user_phone"`
+	Channel   models.Channel `json:"channel"`
+	RevokedAt time.Time      `json:"revoked_at"`
+}
+
+// NotifyRevoked tells the chat orchestrator to disregard a message the user deleted
+func (a *AIService) NotifyRevoked(ctx context.Context, notice *RevocationNotice) error {
+	jsonData, err := json.Marshal(notice)
+	if err != nil {
+		return fmt.Errorf("failed to marshal revocation notice: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/messages/revoked", a.orchestratorURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+
+	if err := a.orchestratorSigner.Sign(req, jsonData); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send revocation notice: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("orchestrator returned status %d", resp.StatusCode)
+	}
 
+	a.logger.WithField("message_id", notice.MessageID).Info("Revocation sent to chat orchestrator")
 	return nil
 }
 
@@ -160,6 +286,12 @@ func (a *AIService) ProcessDocumentAI(ctx context.Context, message *models.Whats
 		},
 	}
 
+	// Text already extracted by OCR saves the AI service a second pass
+	if message.ExtractedText != nil {
+		request["extracted_text"] = *message.ExtractedText
+	}
+	a.addCallbackURL(request, "/api/v1/ai/results")
+
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return fmt.Errorf("failed to marshal document AI request: %w", err)
@@ -173,6 +305,10 @@ func (a *AIService) ProcessDocumentAI(ctx context.Context, message *models.Whats
 
 	req.Header.Set("Content-Type", "application/json")
 
+	if err := a.aiProcessingSigner.Sign(req, jsonData); err != nil {
+		return fmt.Errorf("failed to sign document AI request: %w", err)
+	}
+
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send document AI request: %w", err)
@@ -203,6 +339,7 @@ func (a *AIService) ProcessImageAI(ctx context.Context, message *models.WhatsApp
 			"timestamp": message.Timestamp,
 		},
 	}
+	a.addCallbackURL(request, "/api/v1/ai/results")
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
@@ -217,6 +354,10 @@ func (a *AIService) ProcessImageAI(ctx context.Context, message *models.WhatsApp
 
 	req.Header.Set("Content-Type", "application/json")
 
+	if err := a.aiProcessingSigner.Sign(req, jsonData); err != nil {
+		return fmt.Errorf("failed to sign image AI request: %w", err)
+	}
+
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send image AI request: %w", err)
@@ -247,6 +388,7 @@ func (a *AIService) ProcessAudioAI(ctx context.Context, message *models.WhatsApp
 			"timestamp": message.Timestamp,
 		},
 	}
+	a.addCallbackURL(request, "/api/v1/ai/transcriptions")
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
@@ -261,6 +403,10 @@ func (a *AIService) ProcessAudioAI(ctx context.Context, message *models.WhatsApp
 
 	req.Header.Set("Content-Type", "application/json")
 
+	if err := a.aiProcessingSigner.Sign(req, jsonData); err != nil {
+		return fmt.Errorf("failed to sign audio AI request: %w", err)
+	}
+
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send audio AI request: %w", err)
@@ -275,6 +421,67 @@ func (a *AIService) ProcessAudioAI(ctx context.Context, message *models.WhatsApp
 	return nil
 }
 
+// SummarizeTranscript sends a closed session's transcript for summarization and returns the summary
+func (a *AIService) SummarizeTranscript(ctx context.Context, session *models.ChatSession, messages []*models.WhatsAppMessage) (string, error) {
+	a.logger.WithFields(logrus.Fields{
+		"session_id":    session.ID,
+		"message_count": len(messages),
+	}).Info("Sending session transcript for summarization")
+
+	request := SummaryRequest{
+		SessionID:  session.ID.String(),
+		UserID:     session.UserID.String(),
+		StartedAt:  session.StartedAt,
+		EndedAt:    session.EndedAt,
+		Transcript: make([]TranscriptEntry, 0, len(messages)),
+	}
+
+	for _, message := range messages {
+		request.Transcript = append(request.Transcript, TranscriptEntry{
+			Direction:   message.Direction,
+			MessageType: message.Type,
+			Content:     message.Content,
+			MediaType:   message.MediaType,
+			Timestamp:   message.Timestamp,
+		})
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal summary request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v1/conversations/summarize", a.aiProcessingURL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create summary request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := a.aiProcessingSigner.Sign(req, jsonData); err != nil {
+		return "", fmt.Errorf("failed to sign summary request: %w", err)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send summary request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summary service returned status %d", resp.StatusCode)
+	}
+
+	var summaryResponse SummaryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&summaryResponse); err != nil {
+		return "", fmt.Errorf("failed to decode summary response: %w", err)
+	}
+
+	a.logger.WithField("session_id", session.ID).Info("Session transcript summarized successfully")
+	return summaryResponse.Summary, nil
+}
+
 // GetConversationContext retrieves conversation context for a user
 func (a *AIService) GetConversationContext(ctx context.Context, userPhone string) (map[string]interface{}, error) {
 	a.logger.WithField("user_phone", userPhone).Info("Retrieving conversation context")
@@ -285,6 +492,10 @@ func (a *AIService) GetConversationContext(ctx context.Context, userPhone string
 		return nil, fmt.Errorf("failed to create context request: %w", err)
 	}
 
+	if err := a.orchestratorSigner.Sign(req, nil); err != nil {
+		return nil, fmt.Errorf("failed to sign context request: %w", err)
+	}
+
 	resp, err := a.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation context: %w", err)
@@ -312,3 +523,11 @@ func (a *AIService) GetConversationContext(ctx context.Context, userPhone string
 
 	return context, nil
 }
+
+// addCallbackURL tells the AI service where to post results when a public base URL is configured
+func (a *AIService) addCallbackURL(request map[string]interface{}, path string) {
+	if a.config.AICallbackBaseURL == "" {
+		return
+	}
+	request["callback_url"] = strings.TrimRight(a.config.AICallbackBaseURL, "/") + path
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var aiResultsReusedTotal = metrics.NewCounter("ai_results_reused_total", "AI results copied from earlier messages with identical media", "analysis_type")
+
+// aiResultColumns lists the ai_results columns in the order scanAIResult expects
+const aiResultColumns = `
+	id, message_id, analysis_type, status, result, error, model, received_at, updated_at`
+
+// AIResultService persists analysis results posted back by the AI processing service
+type AIResultService struct {
+	db           *pgxpool.Pool
+	eventService *EventService
+	mediaJobs    *MediaJobService
+	logger       *logrus.Logger
+}
+
+// NewAIResultService creates a new AI result service instance
+func NewAIResultService(db *pgxpool.Pool, eventService *EventService, logger *logrus.Logger) *AIResultService {
+	return &AIResultService{
+		db:           db,
+		eventService: eventService,
+		logger:       logger,
+	}
+}
+
+// UseMediaJobs marks the analysis stage of an attachment done when its result arrives
+func (s *AIResultService) UseMediaJobs(mediaJobs *MediaJobService) {
+	s.mediaJobs = mediaJobs
+}
+
+// ReuseResult copies the completed analysis of an earlier, unredacted message with the
+// same media content to message. It reports whether a result was found; messages without
+// a media hash never match.
+func (s *AIResultService) ReuseResult(ctx context.Context, message *models.WhatsAppMessage, analysisType models.AIAnalysisType) (bool, error) {
+	if message.MediaSHA256 == nil {
+		return false, nil
+	}
+
+	var result map[string]interface{}
+	var model string
+	err := s.db.QueryRow(ctx, `
+		SELECT r.result, COALESCE(r.model, '')
+		FROM ai_results r
+		JOIN whatsapp_messages m ON m.id = r.message_id
+		WHERE m.media_sha256 = $1 AND m.id <> $2 AND m.redacted_at IS NULL
+		  AND r.analysis_type = $3 AND r.status = $4 AND r.result IS NOT NULL
+		ORDER BY r.updated_at DESC
+		LIMIT 1`,
+		*message.MediaSHA256, message.ID, analysisType, models.AIResultStatusCompleted,
+	).Scan(&result, &model)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find reusable AI result: %w", err)
+	}
+
+	if _, err := s.StoreResult(ctx, message, &models.AIResultCallback{
+		MessageID:    message.ID.String(),
+		AnalysisType: analysisType,
+		Status:       models.AIResultStatusCompleted,
+		Result:       result,
+		Model:        model,
+	}); err != nil {
+		return false, err
+	}
+
+	aiResultsReusedTotal.Inc(string(analysisType))
+	return true, nil
+}
+
+// StoreResult saves an analysis result for a message and publishes an event. A repeated
+// callback for the same message and analysis type replaces the earlier result.
+func (s *AIResultService) StoreResult(ctx context.Context, message *models.WhatsAppMessage, callback *models.AIResultCallback) (*models.AIResult, error) {
+	status := callback.Status
+	if status == "" {
+		status = models.AIResultStatusCompleted
+	}
+
+	query := `
+		INSERT INTO ai_results (id, message_id, analysis_type, status, result, error, model, received_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NOW(), NOW())
+		ON CONFLICT (message_id, analysis_type) DO UPDATE SET
+			status = EXCLUDED.status,
+			result = EXCLUDED.result,
+			error = EXCLUDED.error,
+			model = EXCLUDED.model,
+			updated_at = NOW()
+		RETURNING` + aiResultColumns
+
+	result, err := scanAIResult(s.db.QueryRow(ctx, query,
+		uuid.New(),
+		message.ID,
+		callback.AnalysisType,
+		status,
+		callback.Result,
+		callback.Error,
+		callback.Model,
+	))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to store AI result")
+		return nil, fmt.Errorf("failed to store AI result: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id":    message.ID,
+		"analysis_type": result.AnalysisType,
+		"status":        result.Status,
+	}).Info("AI result stored")
+
+	if s.mediaJobs != nil {
+		if result.Status == models.AIResultStatusCompleted {
+			s.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobCompleted, nil)
+		} else {
+			s.mediaJobs.Record(ctx, message.ID, models.MediaStageAnalyzed, models.MediaJobFailed, errors.New(callback.Error))
+		}
+	}
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventAIResultReceived,
+		MessageID: &message.ID,
+		SessionID: message.SessionID,
+		Data: map[string]interface{}{
+			"result_id":     result.ID,
+			"analysis_type": result.AnalysisType,
+			"status":        result.Status,
+		},
+	})
+
+	return result, nil
+}
+
+// GetResultsByMessage retrieves all analysis results for a message
+func (s *AIResultService) GetResultsByMessage(ctx context.Context, messageID uuid.UUID) ([]*models.AIResult, error) {
+	query := `SELECT` + aiResultColumns + ` FROM ai_results WHERE message_id = $1 ORDER BY received_at`
+
+	rows, err := s.db.Query(ctx, query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query AI results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.AIResult{}
+	for rows.Next() {
+		result, err := scanAIResult(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan AI result: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading AI results: %w", err)
+	}
+
+	return results, nil
+}
+
+// scanAIResult scans an ai_results row selected with aiResultColumns
+func scanAIResult(row pgx.Row) (*models.AIResult, error) {
+	var result models.AIResult
+	err := row.Scan(
+		&result.ID,
+		&result.MessageID,
+		&result.AnalysisType,
+		&result.Status,
+		&result.Result,
+		&result.Error,
+		&result.Model,
+		&result.ReceivedAt,
+		&result.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
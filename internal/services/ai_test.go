@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestChatRequestEncoding(t *testing.T) {
+	timestamp := time.Date(2026, 3, 1, 9, 45, 12, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		request ChatRequest
+		want    string
+	}{
+		{
+			name: "without context",
+			request: ChatRequest{
+				MessageID:   "f0e1d2c3-b4a5-4968-8776-655443322110",
+				UserPhone:   "+5511999990000",
+				Channel:     models.ChannelWhatsApp,
+				Content:     "hi",
+				MessageType: models.MessageTypeText,
+				Timestamp:   timestamp,
+			},
+			want: `{"message_id":"f0e1d2c3-b4a5-4968-8776-655443322110","user_phone":"+5511999990000","channel":"whatsapp",` +
+				`"content":"hi","message_type":"text","timestamp":"2026-03-01T09:45:12Z"}`,
+		},
+		{
+			name: "with context",
+			request: ChatRequest{
+				MessageID:   "f0e1d2c3-b4a5-4968-8776-655443322110",
+				UserPhone:   "+5511999990000",
+				Channel:     models.ChannelSMS,
+				Content:     "hi",
+				MessageType: models.MessageTypeText,
+				Timestamp:   timestamp,
+				Context: &models.ChatContext{
+					SchemaVersion:  models.ChatContextSchemaVersion,
+					Platform:       "sms",
+					Direction:      models.MessageDirectionInbound,
+					RecentMessages: []models.ChatContextMessage{},
+				},
+			},
+			want: `{"message_id":"f0e1d2c3-b4a5-4968-8776-655443322110","user_phone":"+5511999990000","channel":"sms",` +
+				`"content":"hi","message_type":"text","timestamp":"2026-03-01T09:45:12Z",` +
+				`"context":{"schema_version":1,"platform":"sms","direction":"inbound","recent_messages":[]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.request)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatResponseDecoding(t *testing.T) {
+	payload := `{"response_id":"r1","content":"Sure","message_type":"text","should_reply":true,` +
+		`"context":{"listing_id":"L-9"},"next_action":"schedule_appointment","processed_at":"2026-03-01T09:45:13Z","replayed":true}`
+
+	var got ChatResponse
+	if err := json.Unmarshal([]byte(payload), &got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got.ResponseID != "r1" || !got.ShouldReply || got.NextAction != ScheduleAppointmentAction || got.Context["listing_id"] != "L-9" {
+		t.Errorf("Unmarshal() = %+v", got)
+	}
+	// Replays are only signaled by the Idempotent-Replayed header
+	if got.Replayed {
+		t.Error("Replayed was decoded from the body")
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// twilioAlertsTotal counts Twilio alerts by level and error code
+var twilioAlertsTotal = metrics.NewCounter("twilio_alerts_total", "Twilio debugger alerts received", "level", "error_code")
+
+// twilioAlertColumns lists the twilio_alerts columns in the order scanTwilioAlert expects
+const twilioAlertColumns = `
+	id, alert_sid, account_sid, level, error_code, description, resource_sid,
+	message_id, phone_number, payload, occurred_at, created_at`
+
+// AlertService records Twilio account alerts so provider problems surface immediately
+type AlertService struct {
+	db           *pgxpool.Pool
+	eventService *EventService
+	logger       *logrus.Logger
+}
+
+// NewAlertService creates a new Twilio alert service
+func NewAlertService(db *pgxpool.Pool, eventService *EventService, logger *logrus.Logger) *AlertService {
+	return &AlertService{
+		db:           db,
+		eventService: eventService,
+		logger:       logger,
+	}
+}
+
+// RecordAlert stores a Twilio debugger alert, linking it to the message and number it
+// concerns, then counts, logs and publishes it. Redelivered alerts return nil.
+func (s *AlertService) RecordAlert(ctx context.Context, webhook *models.TwilioAlertWebhook) (*models.TwilioAlert, error) {
+	alert := &models.TwilioAlert{
+		ID:         uuid.New(),
+		AlertSID:   webhook.Sid,
+		AccountSID: webhook.AccountSid,
+		Level:      strings.ToLower(webhook.Level),
+		OccurredAt: time.Now(),
+	}
+	if occurredAt, err := time.Parse(time.RFC3339, webhook.Timestamp); err == nil {
+		alert.OccurredAt = occurredAt
+	}
+
+	if webhook.Payload != "" && json.Valid([]byte(webhook.Payload)) {
+		alert.Payload = json.RawMessage(webhook.Payload)
+
+		var payload models.TwilioAlertPayload
+		if err := json.Unmarshal(alert.Payload, &payload); err == nil {
+			alert.ErrorCode = optionalString(payload.ErrorCode)
+			alert.Description = optionalString(payload.MoreInfo.Msg)
+			alert.ResourceSID = optionalString(payload.ResourceSID)
+		}
+	}
+
+	if alert.ResourceSID != nil {
+		if err := s.linkMessage(ctx, alert); err != nil {
+			s.logger.WithError(err).WithField("alert_sid", alert.AlertSID).Warn("Failed to link Twilio alert to a message")
+		}
+	}
+
+	query := `
+		INSERT INTO twilio_alerts (id, alert_sid, account_sid, level, error_code, description,
+			resource_sid, message_id, phone_number, payload, occurred_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		ON CONFLICT (alert_sid) DO NOTHING
+		RETURNING` + twilioAlertColumns
+
+	stored, err := scanTwilioAlert(s.db.QueryRow(ctx, query,
+		alert.ID,
+		alert.AlertSID,
+		alert.AccountSID,
+		alert.Level,
+		alert.ErrorCode,
+		alert.Description,
+		alert.ResourceSID,
+		alert.MessageID,
+		alert.PhoneNumber,
+		alert.Payload,
+		alert.OccurredAt,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to store Twilio alert: %w", err)
+	}
+
+	errorCode := ""
+	if stored.ErrorCode != nil {
+		errorCode = *stored.ErrorCode
+	}
+	twilioAlertsTotal.Inc(stored.Level, errorCode)
+
+	entry := s.logger.WithFields(logrus.Fields{
+		"alert_sid":    stored.AlertSID,
+		"error_code":   errorCode,
+		"resource_sid": stored.ResourceSID,
+		"message_id":   stored.MessageID,
+		"phone_number": stored.PhoneNumber,
+		"description":  stored.Description,
+	})
+	if stored.Level == models.AlertLevelError {
+		entry.Error("Twilio alert received")
+	} else {
+		entry.Warn("Twilio alert received")
+	}
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventTwilioAlert,
+		MessageID: stored.MessageID,
+		Data: map[string]interface{}{
+			"alert_id":     stored.ID,
+			"level":        stored.Level,
+			"error_code":   errorCode,
+			"phone_number": stored.PhoneNumber,
+		},
+	})
+
+	return stored, nil
+}
+
+// ListAlerts returns stored alerts, newest first, optionally of one level
+func (s *AlertService) ListAlerts(ctx context.Context, level string, limit, offset int) ([]*models.TwilioAlert, int64, error) {
+	query := `SELECT` + twilioAlertColumns + ` FROM twilio_alerts
+		WHERE $1 = '' OR level = $1
+		ORDER BY occurred_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, strings.ToLower(level), limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query Twilio alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []*models.TwilioAlert{}
+	for rows.Next() {
+		alert, err := scanTwilioAlert(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan Twilio alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading Twilio alerts: %w", err)
+	}
+
+	return alerts, estimateTotal(ctx, s.db, s.logger, query, strings.ToLower(level), limit, offset), nil
+}
+
+// Helper methods
+
+// linkMessage maps an alert's resource SID to our message and the number of ours it used
+func (s *AlertService) linkMessage(ctx context.Context, alert *models.TwilioAlert) error {
+	var messageID uuid.UUID
+	var direction models.MessageDirection
+	var from, to string
+
+	err := s.db.QueryRow(ctx, `
+		SELECT id, direction, from_number, to_number
+		FROM whatsapp_messages WHERE twilio_sid = $1`, *alert.ResourceSID,
+	).Scan(&messageID, &direction, &from, &to)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	number := to
+	if direction == models.MessageDirectionOutbound {
+		number = from
+	}
+
+	alert.MessageID = &messageID
+	alert.PhoneNumber = &number
+	return nil
+}
+
+// scanTwilioAlert scans a twilio_alerts row selected with twilioAlertColumns
+func scanTwilioAlert(row pgx.Row) (*models.TwilioAlert, error) {
+	var alert models.TwilioAlert
+	err := row.Scan(
+		&alert.ID,
+		&alert.AlertSID,
+		&alert.AccountSID,
+		&alert.Level,
+		&alert.ErrorCode,
+		&alert.Description,
+		&alert.ResourceSID,
+		&alert.MessageID,
+		&alert.PhoneNumber,
+		&alert.Payload,
+		&alert.OccurredAt,
+		&alert.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// optionalString returns nil for empty strings
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
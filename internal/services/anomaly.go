@@ -0,0 +1,397 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// anomalyAlertsTotal counts raised failure spike alerts by rule
+var anomalyAlertsTotal = metrics.NewCounter("anomaly_alerts_total", "Failure spike alerts raised by rule", "rule")
+
+// anomalyTopN bounds the error codes and sender numbers listed in an alert
+const anomalyTopN = 5
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// forwardSample is a reading of the orchestrator forward counters
+type forwardSample struct {
+	at       time.Time
+	attempts float64
+	errors   float64
+}
+
+// AnomalyService watches failure rates and raises alerts when they cross their
+// thresholds: the share of outbound messages failing, from the status history shared by
+// all replicas, and the share of orchestrator forward attempts failing on this replica.
+// Each rule alerts at most once per cooldown across replicas.
+type AnomalyService struct {
+	db           *pgxpool.Pool
+	redis        *redis.Client
+	eventService *EventService
+	httpClient   *http.Client
+	awsConfig    aws.Config
+	config       *appConfig.Config
+	logger       *logrus.Logger
+
+	forwardSamples []forwardSample
+}
+
+// NewAnomalyService creates a new anomaly service
+func NewAnomalyService(db *pgxpool.Pool, redisClient *redis.Client, eventService *EventService, cfg *appConfig.Config, logger *logrus.Logger) (*AnomalyService, error) {
+	s := &AnomalyService{
+		db:           db,
+		redis:        redisClient,
+		eventService: eventService,
+		httpClient:   newHTTPClient(10 * time.Second),
+		config:       cfg,
+		logger:       logger,
+	}
+
+	if cfg.AlertSNSTopicARN != "" {
+		awsConfig, err := config.LoadDefaultConfig(context.TODO(),
+			config.WithRegion(snsTopicRegion(cfg.AlertSNSTopicARN, cfg.AWSRegion)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		s.awsConfig = awsConfig
+	}
+
+	return s, nil
+}
+
+// Enabled reports whether failure rates are watched
+func (s *AnomalyService) Enabled() bool {
+	return s.config.AnomalyDetectionEnabled
+}
+
+// Start checks the failure rates every check interval until ctx is done
+func (s *AnomalyService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.AnomalyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Check(ctx); err != nil {
+				s.logger.WithError(err).Error("Anomaly check failed")
+			}
+		}
+	}
+}
+
+// Check evaluates every rule over the window and raises an alert for each breach
+func (s *AnomalyService) Check(ctx context.Context) error {
+	now := time.Now()
+
+	if alert := s.checkOrchestratorErrors(now); alert != nil {
+		s.raise(ctx, alert)
+	}
+
+	alert, err := s.checkOutboundFailures(ctx, now)
+	if err != nil {
+		return err
+	}
+	if alert != nil {
+		s.raise(ctx, alert)
+	}
+
+	return nil
+}
+
+// Helper methods
+
+// checkOutboundFailures compares outbound messages whose status callbacks in the window
+// report a failure with all messages that had a callback in the window
+func (s *AnomalyService) checkOutboundFailures(ctx context.Context, now time.Time) (*models.AnomalyAlert, error) {
+	since := now.Add(-s.config.AnomalyWindow)
+
+	var failures, total int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(DISTINCT twilio_sid) FILTER (WHERE status = 'failed'), COUNT(DISTINCT twilio_sid)
+		FROM message_status_events
+		WHERE received_at >= $1`, since,
+	).Scan(&failures, &total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count outbound failures: %w", err)
+	}
+
+	if total < s.config.AnomalyMinVolume {
+		return nil, nil
+	}
+	rate := float64(failures) / float64(total)
+	if rate < s.config.AnomalyFailureRate {
+		return nil, nil
+	}
+
+	alert := s.newAlert(models.AnomalyRuleOutboundFailures, rate, s.config.AnomalyFailureRate, failures, total, now)
+	alert.Summary = fmt.Sprintf("%.0f%% of outbound messages failed in the last %s (%d of %d)",
+		rate*100, s.config.AnomalyWindow, failures, total)
+
+	alert.TopErrorCodes, err = s.topCounts(ctx, `
+		SELECT COALESCE(error_code, 'unknown'), COUNT(DISTINCT twilio_sid)
+		FROM message_status_events
+		WHERE received_at >= $1 AND status = 'failed'
+		GROUP BY 1 ORDER BY 2 DESC LIMIT $2`, since, anomalyTopN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failure callbacks arrive soon after sending, so only recent partitions are read
+	alert.AffectedSenders, err = s.topCounts(ctx, `
+		SELECT m.from_number, COUNT(DISTINCT e.twilio_sid)
+		FROM message_status_events e
+		JOIN whatsapp_messages m ON m.twilio_sid = e.twilio_sid AND m.timestamp >= $3
+		WHERE e.received_at >= $1 AND e.status = 'failed'
+		GROUP BY 1 ORDER BY 2 DESC LIMIT $2`, since, anomalyTopN, since.Add(-24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// checkOrchestratorErrors compares failed orchestrator forward attempts with all attempts
+// since the oldest counter reading still inside the window
+func (s *AnomalyService) checkOrchestratorErrors(now time.Time) *models.AnomalyAlert {
+	kind := models.OutboxKindOrchestratorForward
+	retries := outboxProcessedTotal.Value(kind, "retry")
+	failed := outboxProcessedTotal.Value(kind, "failed")
+	sample := forwardSample{
+		at:       now,
+		attempts: outboxProcessedTotal.Value(kind, "done") + retries + failed,
+		errors:   retries + failed,
+	}
+
+	// Keep the newest reading older than the window as the baseline
+	cutoff := now.Add(-s.config.AnomalyWindow)
+	for len(s.forwardSamples) > 1 && !s.forwardSamples[1].at.After(cutoff) {
+		s.forwardSamples = s.forwardSamples[1:]
+	}
+	s.forwardSamples = append(s.forwardSamples, sample)
+	if len(s.forwardSamples) < 2 {
+		return nil
+	}
+
+	baseline := s.forwardSamples[0]
+	attempts := int(sample.attempts - baseline.attempts)
+	failures := int(sample.errors - baseline.errors)
+	if attempts < s.config.AnomalyMinVolume {
+		return nil
+	}
+	rate := float64(failures) / float64(attempts)
+	if rate < s.config.AnomalyOrchestratorErrorRate {
+		return nil
+	}
+
+	alert := s.newAlert(models.AnomalyRuleOrchestratorErrors, rate, s.config.AnomalyOrchestratorErrorRate, failures, attempts, now)
+	alert.Summary = fmt.Sprintf("%.0f%% of orchestrator forward attempts failed in the last %s (%d of %d)",
+		rate*100, now.Sub(baseline.at).Round(time.Second), failures, attempts)
+	return alert
+}
+
+// newAlert builds an alert for a rule
+func (s *AnomalyService) newAlert(rule string, rate, threshold float64, failures, total int, now time.Time) *models.AnomalyAlert {
+	return &models.AnomalyAlert{
+		ID:         uuid.New(),
+		Rule:       rule,
+		Rate:       rate,
+		Threshold:  threshold,
+		Failures:   failures,
+		Total:      total,
+		Window:     s.config.AnomalyWindow.String(),
+		DetectedAt: now,
+	}
+}
+
+// topCounts runs a query returning (value, count) rows
+func (s *AnomalyService) topCounts(ctx context.Context, query string, args ...interface{}) ([]models.AnomalyCount, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomaly context: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []models.AnomalyCount
+	for rows.Next() {
+		var count models.AnomalyCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly context: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading anomaly context: %w", err)
+	}
+
+	return counts, nil
+}
+
+// raise publishes an alert and sends it to every configured destination, unless the rule
+// already alerted within the cooldown
+func (s *AnomalyService) raise(ctx context.Context, alert *models.AnomalyAlert) {
+	key := redisKey(s.config, fmt.Sprintf("anomaly_alert:%s", alert.Rule))
+	first, err := s.redis.SetNX(ctx, key, alert.ID.String(), s.config.AnomalyCooldown).Result()
+	if err != nil {
+		// Better to alert twice than not at all
+		s.logger.WithError(err).Warn("Failed to check anomaly alert cooldown")
+	} else if !first {
+		return
+	}
+
+	anomalyAlertsTotal.Inc(alert.Rule)
+	s.logger.WithFields(logrus.Fields{
+		"rule":      alert.Rule,
+		"rate":      alert.Rate,
+		"threshold": alert.Threshold,
+		"failures":  alert.Failures,
+		"total":     alert.Total,
+	}).Warn(alert.Summary)
+
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to marshal anomaly alert")
+		return
+	}
+
+	var data map[string]interface{}
+	json.Unmarshal(payload, &data)
+	s.eventService.Publish(ctx, &models.Event{
+		ID:         alert.ID,
+		Type:       models.EventAnomalyDetected,
+		Data:       data,
+		OccurredAt: alert.DetectedAt,
+	})
+
+	if s.config.AlertWebhookURL != "" {
+		if err := s.postJSON(ctx, s.config.AlertWebhookURL, payload); err != nil {
+			s.logger.WithError(err).Warn("Failed to send anomaly alert webhook")
+		}
+	}
+
+	if s.config.AlertPagerDutyRoutingKey != "" {
+		if err := s.sendPagerDuty(ctx, alert); err != nil {
+			s.logger.WithError(err).Warn("Failed to send anomaly alert to PagerDuty")
+		}
+	}
+
+	if s.config.AlertSNSTopicARN != "" {
+		if err := s.publishSNS(ctx, alert, payload); err != nil {
+			s.logger.WithError(err).Warn("Failed to publish anomaly alert to SNS")
+		}
+	}
+}
+
+// sendPagerDuty triggers a PagerDuty incident, deduplicated per rule
+func (s *AnomalyService) sendPagerDuty(ctx context.Context, alert *models.AnomalyAlert) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"routing_key":  s.config.AlertPagerDutyRoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    "re9ai-whatsapp-adapter:" + alert.Rule,
+		"payload": map[string]interface{}{
+			"summary":        alert.Summary,
+			"source":         "re9ai-whatsapp-adapter",
+			"severity":       "error",
+			"timestamp":      alert.DetectedAt.Format(time.RFC3339),
+			"custom_details": alert,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %w", err)
+	}
+
+	return s.postJSON(ctx, pagerDutyEventsURL, payload)
+}
+
+// publishSNS publishes an alert to the SNS topic through the SNS query API
+func (s *AnomalyService) publishSNS(ctx context.Context, alert *models.AnomalyAlert, payload []byte) error {
+	region := snsTopicRegion(s.config.AlertSNSTopicARN, s.config.AWSRegion)
+	subject := alert.Summary
+	if len(subject) > 100 {
+		subject = subject[:100]
+	}
+
+	body := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {s.config.AlertSNSTopicARN},
+		"Subject":  {subject},
+		"Message":  {string(payload)},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://sns.%s.amazonaws.com/", region), strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SNS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	credentials, err := s.awsConfig.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256([]byte(body))
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "sns", region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign SNS request: %w", err)
+	}
+
+	return s.do(req)
+}
+
+// postJSON posts a JSON payload
+func (s *AnomalyService) postJSON(ctx context.Context, target string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+
+	return s.do(req)
+}
+
+// do sends an alert request and checks for a 2xx response
+func (s *AnomalyService) do(req *http.Request) error {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert destination returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// snsTopicRegion returns the region of an SNS topic ARN (arn:aws:sns:<region>:...)
+func snsTopicRegion(topicARN, fallback string) string {
+	parts := strings.Split(topicARN, ":")
+	if len(parts) >= 6 && parts[3] != "" {
+		return parts[3]
+	}
+	return fallback
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// API key errors
+var (
+	ErrAPIKeyNotFound  = errors.New("api key not found")
+	ErrAPIKeyNotActive = errors.New("api key is revoked or expired")
+	ErrInvalidAPIKey   = errors.New("invalid api key request")
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to recognize
+	apiKeyPrefix = "re9_"
+	// apiKeyCacheTTL bounds how long a replica trusts a key it looked up, in case it
+	// misses a revocation
+	apiKeyCacheTTL = 30 * time.Second
+	// apiKeyUsageInterval is how often last-used is written for a key used from one IP
+	apiKeyUsageInterval = time.Minute
+)
+
+// apiKeyColumns lists the api_keys columns in the order scanAPIKey expects
+const apiKeyColumns = `
+	id, tenant, name, prefix, role, created_by, rotated_from, expires_at, revoked_at,
+	last_used_at, last_used_ip, created_at`
+
+var apiKeyAuthTotal = metrics.NewCounter("api_key_authentications_total", "API key checks on the API, by outcome (accepted, rejected)", "outcome")
+
+// cachedAPIKey is a key as a replica last loaded it, with the usage it last recorded
+type cachedAPIKey struct {
+	key        *models.APIKey
+	loadedAt   time.Time
+	recordedAt time.Time
+	recordedIP string
+}
+
+// APIKeyService issues, rotates and revokes tenant API keys and authenticates requests
+// that present them. Keys are stored as SHA-256 hashes; a rotated key stays valid until
+// its grace period ends, so clients can switch over without downtime.
+type APIKeyService struct {
+	db       *pgxpool.Pool
+	cacheBus *CacheBus
+	config   *config.Config
+	logger   *logrus.Logger
+
+	mu    sync.Mutex
+	cache map[string]*cachedAPIKey // key hash -> key
+}
+
+// NewAPIKeyService creates a new API key service and drops cached keys when another
+// replica rotates or revokes them
+func NewAPIKeyService(db *pgxpool.Pool, cacheBus *CacheBus, cfg *config.Config, logger *logrus.Logger) *APIKeyService {
+	service := &APIKeyService{
+		db:       db,
+		cacheBus: cacheBus,
+		config:   cfg,
+		logger:   logger,
+		cache:    make(map[string]*cachedAPIKey),
+	}
+	cacheBus.Subscribe(CacheTopicAPIKeys, service.forget)
+	return service
+}
+
+// List returns a tenant's keys, newest first, or every tenant's when tenant is empty
+func (s *APIKeyService) List(ctx context.Context, tenant string) ([]*models.APIKey, error) {
+	query := `SELECT` + apiKeyColumns + ` FROM api_keys
+		WHERE $1 = '' OR tenant = $1
+		ORDER BY tenant, created_at DESC`
+
+	rows, err := s.db.Query(ctx, query, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Create issues a new key for a tenant. The returned secret is not stored and cannot
+// be retrieved again.
+func (s *APIKeyService) Create(ctx context.Context, request *models.CreateAPIKeyRequest, actor, actorRole, clientIP string) (*models.IssuedAPIKey, error) {
+	tenant := strings.TrimSpace(request.Tenant)
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: tenant is required", ErrInvalidAPIKey)
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin api key creation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	issued, err := s.insert(ctx, tx, tenant, strings.TrimSpace(request.Name), request.Role, actor, nil, request.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  actorRole,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionAPIKeyCreated,
+		TargetType: "api_key",
+		TargetID:   issued.ID.String(),
+		Details:    map[string]interface{}{"tenant": tenant, "prefix": issued.Prefix, "role": issued.Role},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit api key: %w", err)
+	}
+
+	return issued, nil
+}
+
+// Rotate issues a replacement for an active key. The old key keeps working for grace,
+// so both are accepted while clients switch; a zero grace retires it immediately.
+func (s *APIKeyService) Rotate(ctx context.Context, id uuid.UUID, grace time.Duration, actor, actorRole, clientIP string) (*models.IssuedAPIKey, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin api key rotation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	old, err := scanAPIKey(tx.QueryRow(ctx, `SELECT`+apiKeyColumns+` FROM api_keys WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve api key: %w", err)
+	}
+	if apiKeyStatus(old, time.Now()) != models.APIKeyStatusActive {
+		return nil, ErrAPIKeyNotActive
+	}
+
+	// The old key never outlives its own expiry
+	retireAt := time.Now().Add(grace)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(retireAt) {
+		retireAt = *old.ExpiresAt
+	}
+	var oldHash string
+	err = tx.QueryRow(ctx, `UPDATE api_keys SET expires_at = $2 WHERE id = $1 RETURNING key_hash`, id, retireAt).Scan(&oldHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire api key: %w", err)
+	}
+
+	issued, err := s.insert(ctx, tx, old.Tenant, old.Name, old.Role, actor, &old.ID, old.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  actorRole,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionAPIKeyRotated,
+		TargetType: "api_key",
+		TargetID:   old.ID.String(),
+		Details: map[string]interface{}{
+			"tenant":         old.Tenant,
+			"replaced_by":    issued.ID,
+			"old_expires_at": retireAt,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit api key rotation: %w", err)
+	}
+
+	s.changed(ctx, oldHash)
+	return issued, nil
+}
+
+// Revoke disables a key at once. Revoking a revoked key is a no-op.
+func (s *APIKeyService) Revoke(ctx context.Context, id uuid.UUID, actor, actorRole, clientIP string) (*models.APIKey, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin api key revocation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hash string
+	var wasRevoked bool
+	err = tx.QueryRow(ctx, `SELECT key_hash, revoked_at IS NOT NULL FROM api_keys WHERE id = $1 FOR UPDATE`, id).Scan(&hash, &wasRevoked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve api key: %w", err)
+	}
+
+	key, err := scanAPIKey(tx.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = $1
+		RETURNING`+apiKeyColumns, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	if !wasRevoked {
+		err = recordAudit(ctx, tx, &models.AuditEntry{
+			Actor:      actor,
+			ActorRole:  actorRole,
+			ClientIP:   clientIP,
+			Action:     models.AuditActionAPIKeyRevoked,
+			TargetType: "api_key",
+			TargetID:   key.ID.String(),
+			Details:    map[string]interface{}{"tenant": key.Tenant, "prefix": key.Prefix},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit api key revocation: %w", err)
+	}
+
+	s.changed(ctx, hash)
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the tenant, ID and role of an active key; ok is false for keys
+// that are unknown, revoked or expired. It also notes when and from which IP the key was
+// last used.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, secret, clientIP string) (string, string, string, bool, error) {
+	hash := hashAPIKey(secret)
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.cache[hash]
+	if ok && now.Sub(entry.loadedAt) > apiKeyCacheTTL {
+		delete(s.cache, hash)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		if !strings.HasPrefix(secret, apiKeyPrefix) {
+			apiKeyAuthTotal.Inc("rejected")
+			return "", "", "", false, nil
+		}
+		key, err := scanAPIKey(s.db.QueryRow(ctx, `SELECT`+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apiKeyAuthTotal.Inc("rejected")
+				return "", "", "", false, nil
+			}
+			return "", "", "", false, fmt.Errorf("failed to look up api key: %w", err)
+		}
+
+		entry = &cachedAPIKey{key: key, loadedAt: now}
+		s.mu.Lock()
+		s.cache[hash] = entry
+		s.mu.Unlock()
+	}
+
+	if apiKeyStatus(entry.key, now) != models.APIKeyStatusActive {
+		apiKeyAuthTotal.Inc("rejected")
+		return "", "", "", false, nil
+	}
+	apiKeyAuthTotal.Inc("accepted")
+
+	s.mu.Lock()
+	record := now.Sub(entry.recordedAt) >= apiKeyUsageInterval || entry.recordedIP != clientIP
+	if record {
+		entry.recordedAt, entry.recordedIP = now, clientIP
+	}
+	s.mu.Unlock()
+	if record {
+		go s.recordUsage(entry.key.ID, clientIP, now)
+	}
+
+	return entry.key.Tenant, entry.key.ID.String(), entry.key.Role, true, nil
+}
+
+// Helper methods
+
+// insert generates a key and stores its hash
+func (s *APIKeyService) insert(ctx context.Context, tx pgx.Tx, tenant, name, keyRole, actor string, rotatedFrom *uuid.UUID, expiresAt *time.Time) (*models.IssuedAPIKey, error) {
+	secret, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := scanAPIKey(tx.QueryRow(ctx, `
+		INSERT INTO api_keys (id, tenant, name, prefix, role, key_hash, created_by, rotated_from, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING`+apiKeyColumns,
+		uuid.New(), tenant, name, prefix, keyRole, hashAPIKey(secret), actor, rotatedFrom, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store api key: %w", err)
+	}
+
+	return &models.IssuedAPIKey{APIKey: key, Key: secret}, nil
+}
+
+// changed drops a key from the local cache and tells other replicas to do the same
+func (s *APIKeyService) changed(ctx context.Context, hash string) {
+	s.forget(hash)
+	s.cacheBus.Publish(ctx, CacheTopicAPIKeys, hash)
+}
+
+// forget drops a cached key, or every cached key when hash is empty
+func (s *APIKeyService) forget(hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hash == "" {
+		s.cache = make(map[string]*cachedAPIKey)
+		return
+	}
+	delete(s.cache, hash)
+}
+
+// recordUsage stores when and from where a key was last used
+func (s *APIKeyService) recordUsage(id uuid.UUID, clientIP string, usedAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2, last_used_ip = $3
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)`, id, usedAt, clientIP)
+	if err != nil {
+		s.logger.WithError(err).WithField("api_key_id", id).Warn("Failed to record api key usage")
+	}
+}
+
+// scanAPIKey scans an api_keys row selected with apiKeyColumns
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var key models.APIKey
+	err := row.Scan(
+		&key.ID,
+		&key.Tenant,
+		&key.Name,
+		&key.Prefix,
+		&key.Role,
+		&key.CreatedBy,
+		&key.RotatedFrom,
+		&key.ExpiresAt,
+		&key.RevokedAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.Status = apiKeyStatus(&key, time.Now())
+	return &key, nil
+}
+
+// apiKeyStatus derives whether a key is active at now
+func apiKeyStatus(key *models.APIKey, now time.Time) string {
+	switch {
+	case key.RevokedAt != nil:
+		return models.APIKeyStatusRevoked
+	case key.ExpiresAt != nil && !key.ExpiresAt.After(now):
+		return models.APIKeyStatusExpired
+	default:
+		return models.APIKeyStatusActive
+	}
+}
+
+// generateAPIKey returns a new key, re9_<prefix>_<secret>, and its displayable prefix
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of a key, as stored in api_keys.key_hash
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	secret, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() error = %v", err)
+	}
+	if !regexp.MustCompile(`^re9_[0-9a-f]{8}$`).MatchString(prefix) {
+		t.Errorf("prefix = %q, want re9_ and 8 hex characters", prefix)
+	}
+	if !strings.HasPrefix(secret, prefix+"_") || len(secret) != len(prefix)+1+43 {
+		t.Errorf("key = %q, want %s_ and a 32-byte secret", secret, prefix)
+	}
+
+	other, _, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() error = %v", err)
+	}
+	if other == secret {
+		t.Error("generateAPIKey() returned the same key twice")
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{"re9_test", "d91042ece12b89514bf3a075a1f2e84b842f1d00ab9ecc88113162e8a2bc1b2d"},
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			if got := hashAPIKey(tt.secret); got != tt.want {
+				t.Errorf("hashAPIKey(%q) = %q, want %q", tt.secret, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	const clientIP = "203.0.113.7"
+
+	cached := func(secret string, key *models.APIKey) *APIKeyService {
+		// Usage is already recorded for clientIP, so lookups never reach the database
+		return &APIKeyService{cache: map[string]*cachedAPIKey{
+			hashAPIKey(secret): {key: key, loadedAt: now, recordedAt: now, recordedIP: clientIP},
+		}}
+	}
+	key := func(expiresAt, revokedAt *time.Time) *models.APIKey {
+		return &models.APIKey{ID: uuid.New(), Tenant: "acme", Role: "sender", ExpiresAt: expiresAt, RevokedAt: revokedAt}
+	}
+
+	tests := []struct {
+		name    string
+		service *APIKeyService
+		secret  string
+		wantOK  bool
+	}{
+		{"active", cached("re9_abc_secret", key(nil, nil)), "re9_abc_secret", true},
+		{"in grace period", cached("re9_abc_secret", key(&future, nil)), "re9_abc_secret", true},
+		{"expired", cached("re9_abc_secret", key(&past, nil)), "re9_abc_secret", false},
+		{"revoked", cached("re9_abc_secret", key(nil, &past)), "re9_abc_secret", false},
+		{"other secret without prefix", cached("re9_abc_secret", key(nil, nil)), "abc_secret", false},
+		{"secrets are case-sensitive", cached("re9_abc_secret", key(nil, nil)), "RE9_abc_secret", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, id, role, ok, err := tt.service.AuthenticateAPIKey(context.Background(), tt.secret, clientIP)
+			if err != nil {
+				t.Fatalf("AuthenticateAPIKey() error = %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("AuthenticateAPIKey() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && (tenant != "acme" || role != "sender" || id == "") {
+				t.Errorf("AuthenticateAPIKey() = %q, %q, %q", tenant, id, role)
+			}
+		})
+	}
+}
+
+func TestAPIKeyStatus(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		key  models.APIKey
+		want string
+	}{
+		{"active", models.APIKey{}, models.APIKeyStatusActive},
+		{"expires later", models.APIKey{ExpiresAt: &future}, models.APIKeyStatusActive},
+		{"expires now", models.APIKey{ExpiresAt: &now}, models.APIKeyStatusExpired},
+		{"expired", models.APIKey{ExpiresAt: &past}, models.APIKeyStatusExpired},
+		{"revoked before expiry", models.APIKey{ExpiresAt: &future, RevokedAt: &past}, models.APIKeyStatusRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiKeyStatus(&tt.key, now); got != tt.want {
+				t.Errorf("apiKeyStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
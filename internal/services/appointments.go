@@ -0,0 +1,447 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var (
+	// ErrInvalidAppointment is returned for scheduling intents that cannot be proposed
+	ErrInvalidAppointment = errors.New("invalid appointment")
+
+	// ErrAppointmentTemplateMissing is returned when no confirmation template is configured
+	ErrAppointmentTemplateMissing = errors.New("no appointment template configured")
+
+	// ErrAppointmentNotFound is returned for unknown appointments
+	ErrAppointmentNotFound = errors.New("appointment not found")
+
+	// ErrAppointmentExists is returned when a message already scheduled an appointment
+	ErrAppointmentExists = errors.New("appointment already scheduled for message")
+)
+
+// ScheduleAppointmentAction is the orchestrator next action that proposes the
+// appointment in the "appointment" context key to the user
+const ScheduleAppointmentAction = "schedule_appointment"
+
+// calendarWebhookTimeout bounds one call to the calendar service
+const calendarWebhookTimeout = 10 * time.Second
+
+var appointmentsTotal = metrics.NewCounter("appointments_total", "Appointments by status they entered", "status")
+
+// appointmentColumns lists the appointments columns in the order scanAppointment expects
+const appointmentColumns = `
+	id, session_id, message_id, to_number, channel, title, location, listing_id, starts_at,
+	ends_at, status, responded_at, calendar_notified_at, created_at, updated_at`
+
+// AppointmentService proposes appointments the orchestrator schedules as confirmation
+// templates, records the user's confirm or decline button reply and tells the calendar
+// service about it
+type AppointmentService struct {
+	db               *pgxpool.Pool
+	referenceService *ReferenceService
+	eventService     *EventService
+	outbox           *OutboxService
+	httpClient       *http.Client
+	config           *appConfig.Config
+	logger           *logrus.Logger
+}
+
+// NewAppointmentService creates a new appointment service instance
+func NewAppointmentService(
+	db *pgxpool.Pool,
+	referenceService *ReferenceService,
+	eventService *EventService,
+	outbox *OutboxService,
+	cfg *appConfig.Config,
+	logger *logrus.Logger,
+) *AppointmentService {
+	return &AppointmentService{
+		db:               db,
+		referenceService: referenceService,
+		eventService:     eventService,
+		outbox:           outbox,
+		httpClient:       newHTTPClient(calendarWebhookTimeout),
+		config:           cfg,
+		logger:           logger,
+	}
+}
+
+// IsScheduleAction reports whether an orchestrator next action proposes an appointment
+func IsScheduleAction(nextAction string) bool {
+	return strings.EqualFold(strings.TrimSpace(nextAction), ScheduleAppointmentAction)
+}
+
+// ParseIntent reads the scheduling intent from the "appointment" key of an orchestrator
+// response context
+func ParseIntent(responseContext map[string]interface{}) (*models.AppointmentIntent, error) {
+	value, ok := responseContext["appointment"]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("%w: response context has no appointment", ErrInvalidAppointment)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAppointment, err)
+	}
+	var intent models.AppointmentIntent
+	if err := json.Unmarshal(data, &intent); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAppointment, err)
+	}
+	return &intent, nil
+}
+
+// Create validates a scheduling intent for the sender of an inbound message, stores the
+// appointment as pending and returns the template send that asks the user to confirm
+// it. The template receives the appointment_id, date, time, title and location
+// variables, with date and time in APPOINTMENT_TIMEZONE. Each inbound message schedules
+// at most one appointment; later attempts return ErrAppointmentExists.
+func (s *AppointmentService) Create(ctx context.Context, message *models.WhatsAppMessage, intent *models.AppointmentIntent) (*models.Appointment, *models.SendMessageRequest, error) {
+	template := s.config.AppointmentTemplateSID
+	if template == "" {
+		return nil, nil, ErrAppointmentTemplateMissing
+	}
+
+	if intent.StartsAt.IsZero() {
+		return nil, nil, fmt.Errorf("%w: starts_at is required", ErrInvalidAppointment)
+	}
+	if !intent.StartsAt.After(time.Now()) {
+		return nil, nil, fmt.Errorf("%w: starts_at is in the past", ErrInvalidAppointment)
+	}
+	if intent.EndsAt != nil && !intent.EndsAt.After(intent.StartsAt) {
+		return nil, nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidAppointment)
+	}
+	if len(intent.ListingID) > maxRefIDLength {
+		return nil, nil, fmt.Errorf("%w: listing ID must be at most %d characters", ErrInvalidAppointment, maxRefIDLength)
+	}
+
+	location, err := time.LoadLocation(s.config.AppointmentTimezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load appointment time zone: %w", err)
+	}
+
+	appointment := &models.Appointment{
+		ID:       uuid.New(),
+		To:       message.From,
+		Channel:  message.Channel,
+		Title:    strings.TrimSpace(intent.Title),
+		Location: strings.TrimSpace(intent.Location),
+		StartsAt: intent.StartsAt,
+		EndsAt:   intent.EndsAt,
+		Status:   models.AppointmentStatusPending,
+	}
+	if appointment.Channel == "" {
+		appointment.Channel = models.ChannelWhatsApp
+	}
+	if listingID := strings.TrimSpace(intent.ListingID); listingID != "" {
+		appointment.ListingID = &listingID
+	}
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO appointments (
+			id, to_number, channel, title, location, listing_id, starts_at, ends_at, status,
+			source_message_id, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW(), NOW())
+		ON CONFLICT (source_message_id) WHERE source_message_id IS NOT NULL DO NOTHING
+		RETURNING created_at, updated_at`,
+		appointment.ID, appointment.To, appointment.Channel, appointment.Title, appointment.Location,
+		appointment.ListingID, appointment.StartsAt, appointment.EndsAt, appointment.Status, message.ID,
+	).Scan(&appointment.CreatedAt, &appointment.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrAppointmentExists
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store appointment: %w", err)
+	}
+	appointmentsTotal.Inc(string(models.AppointmentStatusPending))
+
+	startsAt := appointment.StartsAt.In(location)
+	variables := map[string]string{
+		"appointment_id": appointment.ID.String(),
+		"date":           startsAt.Format("02/01/2006"),
+		"time":           startsAt.Format("15:04"),
+		"title":          appointment.Title,
+		"location":       appointment.Location,
+	}
+
+	content := fmt.Sprintf("Appointment on %s at %s", variables["date"], variables["time"])
+	if appointment.Title != "" {
+		content = appointment.Title + ": " + content
+	}
+
+	send := &models.SendMessageRequest{
+		To:        appointment.To,
+		Content:   content,
+		Type:      models.MessageTypeAppointment,
+		Template:  &template,
+		Variables: variables,
+		Channel:   appointment.Channel,
+	}
+	return appointment, send, nil
+}
+
+// Sent links an appointment to the confirmation message that delivered it and, when the
+// message belongs to a session, stores the appointment and its listing as references of
+// that conversation
+func (s *AppointmentService) Sent(ctx context.Context, appointment *models.Appointment, messageID uuid.UUID) error {
+	err := s.db.QueryRow(ctx, `
+		UPDATE appointments
+		SET message_id = $2,
+			session_id = (SELECT session_id FROM whatsapp_messages WHERE id = $2),
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING session_id`,
+		appointment.ID, messageID,
+	).Scan(&appointment.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to link appointment to message: %w", err)
+	}
+	appointment.MessageID = &messageID
+
+	if appointment.SessionID == nil {
+		return nil
+	}
+	if err := s.referenceService.Link(ctx, *appointment.SessionID, &messageID, models.ReferenceKindAppointment, models.ReferenceSourceAppointment, appointment.ID.String()); err != nil {
+		return err
+	}
+	if appointment.ListingID != nil {
+		return s.referenceService.Link(ctx, *appointment.SessionID, &messageID, models.ReferenceKindListing, models.ReferenceSourceAppointment, *appointment.ListingID)
+	}
+	return nil
+}
+
+// Failed marks an appointment whose confirmation could not be sent
+func (s *AppointmentService) Failed(ctx context.Context, appointment *models.Appointment) error {
+	if _, err := s.db.Exec(ctx, `
+		UPDATE appointments SET status = $2, updated_at = NOW()
+		WHERE id = $1`, appointment.ID, models.AppointmentStatusFailed); err != nil {
+		return fmt.Errorf("failed to mark appointment failed: %w", err)
+	}
+	appointment.Status = models.AppointmentStatusFailed
+	appointmentsTotal.Inc(string(models.AppointmentStatusFailed))
+	return nil
+}
+
+// HandleReply applies the confirm or decline button a user tapped on a confirmation
+// template. The payload names the appointment after a colon; without one, the user's
+// latest pending appointment on the channel is answered. The answer is published as an
+// appointment.updated event, and the calendar service is called through the outbox in
+// the same transaction. It returns nil when the message answers no pending appointment.
+func (s *AppointmentService) HandleReply(ctx context.Context, message *models.WhatsAppMessage) (*models.Appointment, error) {
+	status, appointmentID, ok := parseAppointmentPayload(message.ButtonPayload)
+	if !ok {
+		return nil, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin appointment reply: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	appointment, err := scanAppointment(tx.QueryRow(ctx, `
+		UPDATE appointments
+		SET status = $4, responded_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM appointments
+			WHERE channel = $1 AND to_number = $2 AND status = 'pending'
+				AND ($3::uuid IS NULL OR id = $3)
+			ORDER BY created_at DESC
+			LIMIT 1
+		)
+		RETURNING`+appointmentColumns,
+		message.Channel, message.From, appointmentID, status,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record appointment reply: %w", err)
+	}
+
+	var entry *models.OutboxEntry
+	if s.config.CalendarWebhookURL != "" {
+		entry, err = NewOutboxEntry(models.OutboxKindCalendarWebhook, &message.ID, appointment)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertOutboxEntries(ctx, tx, []*models.OutboxEntry{entry}); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit appointment reply: %w", err)
+	}
+	if entry != nil {
+		s.outbox.Dispatch(entry)
+	}
+	appointmentsTotal.Inc(string(appointment.Status))
+
+	s.logger.WithFields(logrus.Fields{
+		"appointment_id": appointment.ID,
+		"status":         appointment.Status,
+	}).Info("Appointment answered")
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventAppointmentUpdated,
+		MessageID: &message.ID,
+		SessionID: appointment.SessionID,
+		Data: map[string]interface{}{
+			"appointment_id": appointment.ID,
+			"status":         appointment.Status,
+			"starts_at":      appointment.StartsAt,
+			"listing_id":     appointment.ListingID,
+		},
+	})
+
+	return appointment, nil
+}
+
+// DeliverCalendarWebhook sends an answered appointment to CALENDAR_WEBHOOK_URL from the
+// outbox; failures are retried by the outbox
+func (s *AppointmentService) DeliverCalendarWebhook(ctx context.Context, entry *models.OutboxEntry) error {
+	var appointment models.Appointment
+	if err := json.Unmarshal(entry.Payload, &appointment); err != nil {
+		return fmt.Errorf("failed to decode calendar notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.CalendarWebhookURL, bytes.NewReader(entry.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create calendar request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+	// The appointment ID lets the calendar service deduplicate retries
+	req.Header.Set("X-Appointment-ID", appointment.ID.String())
+	if s.config.CalendarWebhookToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.CalendarWebhookToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call calendar service: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("calendar service returned status %d", resp.StatusCode)
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		UPDATE appointments SET calendar_notified_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, appointment.ID); err != nil {
+		s.logger.WithError(err).WithField("appointment_id", appointment.ID).Warn("Failed to record calendar notification")
+	}
+	return nil
+}
+
+// Get returns an appointment by ID
+func (s *AppointmentService) Get(ctx context.Context, id uuid.UUID) (*models.Appointment, error) {
+	appointment, err := scanAppointment(s.db.QueryRow(ctx, `SELECT`+appointmentColumns+`
+		FROM appointments
+		WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAppointmentNotFound
+		}
+		return nil, fmt.Errorf("failed to load appointment: %w", err)
+	}
+	return appointment, nil
+}
+
+// ListForSession returns the appointments proposed in a session, oldest first
+func (s *AppointmentService) ListForSession(ctx context.Context, sessionID uuid.UUID) ([]*models.Appointment, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+appointmentColumns+`
+		FROM appointments
+		WHERE session_id = $1
+		ORDER BY created_at, id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query appointments: %w", err)
+	}
+	defer rows.Close()
+
+	appointments := []*models.Appointment{}
+	for rows.Next() {
+		appointment, err := scanAppointment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan appointment: %w", err)
+		}
+		appointments = append(appointments, appointment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading appointments: %w", err)
+	}
+
+	return appointments, nil
+}
+
+// Helper functions
+
+// parseAppointmentPayload reads the answer and optional appointment ID of a confirmation
+// button payload, e.g. "appointment_confirm:<id>"
+func parseAppointmentPayload(payload string) (models.AppointmentStatus, *uuid.UUID, bool) {
+	action, idText, _ := strings.Cut(strings.TrimSpace(payload), ":")
+
+	var status models.AppointmentStatus
+	switch strings.ToLower(action) {
+	case models.AppointmentPayloadConfirm:
+		status = models.AppointmentStatusConfirmed
+	case models.AppointmentPayloadDecline:
+		status = models.AppointmentStatusDeclined
+	default:
+		return "", nil, false
+	}
+
+	if idText == "" {
+		return status, nil, true
+	}
+	id, err := uuid.Parse(idText)
+	if err != nil {
+		return "", nil, false
+	}
+	return status, &id, true
+}
+
+// scanAppointment scans an appointments row selected with appointmentColumns
+func scanAppointment(row pgx.Row) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := row.Scan(
+		&appointment.ID,
+		&appointment.SessionID,
+		&appointment.MessageID,
+		&appointment.To,
+		&appointment.Channel,
+		&appointment.Title,
+		&appointment.Location,
+		&appointment.ListingID,
+		&appointment.StartsAt,
+		&appointment.EndsAt,
+		&appointment.Status,
+		&appointment.RespondedAt,
+		&appointment.CalendarNotifiedAt,
+		&appointment.CreatedAt,
+		&appointment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Archive metrics
+var (
+	conversationsArchivedTotal = metrics.NewCounter("conversations_archived_total", "Conversations moved to cold storage by outcome", "outcome")
+	archiveReadsTotal          = metrics.NewCounter("conversation_archive_reads_total", "Archived conversations read back for history by outcome", "outcome")
+)
+
+// archiveBatchSize bounds how many conversations one pass archives
+const archiveBatchSize = 100
+
+// conversationArchiveColumns lists the conversation_archives columns in the order
+// scanConversationArchive expects
+const conversationArchiveColumns = `
+	id, session_id, user_id, object_key, message_count, first_message_at, last_message_at,
+	archived_at`
+
+// ArchiveService moves the messages of closed conversations idle for longer than the
+// configured number of months to object storage, and reads them back for history
+// requests that page past the messages still in the database.
+type ArchiveService struct {
+	db             *pgxpool.Pool
+	s3Client       *s3.Client
+	messageService *MessageService
+	config         *appConfig.Config
+	logger         *logrus.Logger
+}
+
+// NewArchiveService creates a new archive service
+func NewArchiveService(db *pgxpool.Pool, messageService *MessageService, cfg *appConfig.Config, logger *logrus.Logger) (*ArchiveService, error) {
+	awsConfig, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithRegion(cfg.AWSRegion),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &ArchiveService{
+		db:             db,
+		s3Client:       s3.NewFromConfig(awsConfig),
+		messageService: messageService,
+		config:         cfg,
+		logger:         logger,
+	}, nil
+}
+
+// Enabled reports whether conversations are being archived
+func (s *ArchiveService) Enabled() bool {
+	return s.config.ArchiveAfterMonths > 0 && s.config.ArchiveBucket != ""
+}
+
+// Start archives due conversations every archive interval until ctx is done
+func (s *ArchiveService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.ArchiveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.ArchiveDue(ctx); err != nil {
+				s.logger.WithError(err).Error("Conversation archive pass failed")
+			}
+		}
+	}
+}
+
+// ArchiveDue archives closed conversations idle for longer than the configured number of
+// months and returns how many were archived. It first indexes older archives and deletes
+// archives past message retention.
+func (s *ArchiveService) ArchiveDue(ctx context.Context) (int, error) {
+	if err := s.indexArchives(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to index conversation archives")
+	}
+	if expired, err := s.expireArchives(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to expire conversation archives")
+	} else if expired > 0 {
+		s.logger.WithField("archives", expired).Info("Deleted conversation archives past retention")
+	}
+
+	cutoff := time.Now().AddDate(0, -s.config.ArchiveAfterMonths, 0)
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id FROM chat_sessions s
+		WHERE status = 'closed' AND last_activity_at < $1
+			AND NOT EXISTS (SELECT 1 FROM conversation_archives a WHERE a.session_id = s.id)
+		ORDER BY last_activity_at
+		LIMIT $2`, cutoff, archiveBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query conversations to archive: %w", err)
+	}
+
+	var sessionIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan conversation to archive: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading conversations to archive: %w", err)
+	}
+
+	archived := 0
+	for _, id := range sessionIDs {
+		ok, err := s.archiveSession(ctx, id)
+		if err != nil {
+			conversationsArchivedTotal.Inc("failed")
+			s.logger.WithError(err).WithField("session_id", id).Error("Failed to archive conversation")
+			continue
+		}
+		if ok {
+			conversationsArchivedTotal.Inc("archived")
+			archived++
+		}
+	}
+
+	if archived > 0 {
+		s.logger.WithField("conversations", archived).Info("Archived idle conversations")
+	}
+	return archived, nil
+}
+
+// GetUserHistory returns a page of a user's messages whose metadata contains filter,
+// newest first. Once the messages still in the database run out, the page continues with
+// archived conversations, newest first, whose messages are marked as archived.
+func (s *ArchiveService) GetUserHistory(ctx context.Context, userID uuid.UUID, filter models.Metadata, limit, offset int) (*models.MessageHistoryPage, error) {
+	if filter == nil {
+		filter = models.Metadata{}
+	}
+
+	messages, err := s.messageService.GetMessagesByUserID(ctx, userID, filter, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.MessageHistoryPage{Messages: messages}
+	if len(messages) == limit {
+		return page, nil
+	}
+
+	var live int
+	if err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM whatsapp_messages WHERE user_id = $1 AND metadata @> $2`,
+		userID, filter,
+	).Scan(&live); err != nil {
+		return nil, fmt.Errorf("failed to count user messages: %w", err)
+	}
+	skip := offset - live
+	if skip < 0 {
+		skip = 0
+	}
+
+	// Unindexed archives are read to find out
+	rows, err := s.db.Query(ctx, `SELECT`+conversationArchiveColumns+`
+		FROM conversation_archives
+		WHERE user_id = $1
+			AND ($2::jsonb = '{}' OR message_metadata IS NULL OR message_metadata @> jsonb_build_array($2::jsonb))
+		ORDER BY last_message_at DESC`, userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversation archives: %w", err)
+	}
+
+	var archives []*models.ConversationArchive
+	for rows.Next() {
+		archive, err := scanConversationArchive(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan conversation archive: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading conversation archives: %w", err)
+	}
+
+	for _, archive := range archives {
+		if len(page.Messages) >= limit {
+			break
+		}
+		// Without a filter, whole archives are skipped by their message count
+		if len(filter) == 0 && skip >= archive.MessageCount {
+			skip -= archive.MessageCount
+			continue
+		}
+
+		conversation, err := s.load(ctx, archive)
+		if err != nil {
+			archiveReadsTotal.Inc("failed")
+			return nil, err
+		}
+		archiveReadsTotal.Inc("ok")
+
+		for i := len(conversation.Messages) - 1; i >= 0 && len(page.Messages) < limit; i-- {
+			message := conversation.Messages[i]
+			if !message.Metadata.Contains(filter) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			message.Archived = true
+			page.Messages = append(page.Messages, message)
+			page.Archived = true
+		}
+	}
+
+	return page, nil
+}
+
+// Helper methods
+
+// archiveSession uploads a conversation's messages and removes them from the database in
+// one transaction; it reports false when another worker got to the session first
+func (s *ArchiveService) archiveSession(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin archive: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	var lastActivity time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, last_activity_at FROM chat_sessions s
+		WHERE id = $1 AND status = 'closed'
+			AND NOT EXISTS (SELECT 1 FROM conversation_archives a WHERE a.session_id = s.id)
+		FOR UPDATE SKIP LOCKED`, sessionID).Scan(&userID, &lastActivity)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to lock session: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT`+messageColumns+`
+		FROM whatsapp_messages
+		WHERE session_id = $1
+		ORDER BY timestamp ASC`, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to query session messages: %w", err)
+	}
+
+	conversation := &models.ArchivedConversation{
+		SessionID:  sessionID,
+		UserID:     userID,
+		ArchivedAt: time.Now().UTC(),
+		Messages:   []*models.WhatsAppMessage{},
+	}
+	for rows.Next() {
+		var message models.WhatsAppMessage
+		if err := scanMessageInto(rows, &message); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan session message: %w", err)
+		}
+		conversation.Messages = append(conversation.Messages, &message)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error reading session messages: %w", err)
+	}
+
+	first, last := lastActivity, lastActivity
+	if n := len(conversation.Messages); n > 0 {
+		first, last = conversation.Messages[0].Timestamp, conversation.Messages[n-1].Timestamp
+	}
+
+	messageIDs, messageMetadata, err := archiveIndex(conversation)
+	if err != nil {
+		return false, err
+	}
+
+	// The key is stable, so a retry after a failed commit overwrites the earlier upload
+	key := fmt.Sprintf("%s/%s/%s.json.gz", strings.Trim(s.config.ArchivePrefix, "/"), lastActivity.UTC().Format("2006/01"), sessionID)
+	if err := s.upload(ctx, key, conversation); err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversation_archives (
+			id, session_id, user_id, object_key, message_count, first_message_at, last_message_at, archived_at,
+			message_ids, message_metadata
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		uuid.New(), sessionID, userID, key, len(conversation.Messages), first, last, conversation.ArchivedAt,
+		messageIDs, messageMetadata,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record conversation archive: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM whatsapp_messages WHERE session_id = $1 AND timestamp >= $2`,
+		sessionID, first,
+	); err != nil {
+		return false, fmt.Errorf("failed to remove archived messages: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit archive: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": sessionID,
+		"messages":   len(conversation.Messages),
+		"object_key": key,
+	}).Info("Conversation archived")
+
+	return true, nil
+}
+
+// indexArchives records the message IDs and metadata of a batch of archives written
+// before they were recorded, reading each one back from the bucket
+func (s *ArchiveService) indexArchives(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `SELECT`+conversationArchiveColumns+`
+		FROM conversation_archives
+		WHERE message_ids IS NULL OR message_metadata IS NULL
+		LIMIT $1`, archiveBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to query unindexed archives: %w", err)
+	}
+
+	var archives []*models.ConversationArchive
+	for rows.Next() {
+		archive, err := scanConversationArchive(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan conversation archive: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading unindexed archives: %w", err)
+	}
+
+	for _, archive := range archives {
+		conversation, err := s.load(ctx, archive)
+		if err != nil {
+			s.logger.WithError(err).WithField("archive_id", archive.ID).Warn("Failed to read conversation archive for indexing")
+			continue
+		}
+		messageIDs, messageMetadata, err := archiveIndex(conversation)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(ctx, `
+			UPDATE conversation_archives SET message_ids = $2, message_metadata = $3 WHERE id = $1`,
+			archive.ID, messageIDs, messageMetadata); err != nil {
+			return fmt.Errorf("failed to index conversation archive: %w", err)
+		}
+	}
+	return nil
+}
+
+// expireArchives deletes archives whose newest message is past MESSAGE_RETENTION_MONTHS,
+// the object first so a failed deletion leaves the row to retry with
+func (s *ArchiveService) expireArchives(ctx context.Context) (int, error) {
+	if s.config.MessageRetentionMonths <= 0 {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -s.config.MessageRetentionMonths, 0)
+
+	rows, err := s.db.Query(ctx, `SELECT`+conversationArchiveColumns+`
+		FROM conversation_archives
+		WHERE last_message_at < $1
+		ORDER BY last_message_at
+		LIMIT $2`, cutoff, archiveBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired archives: %w", err)
+	}
+
+	var archives []*models.ConversationArchive
+	for rows.Next() {
+		archive, err := scanConversationArchive(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan conversation archive: %w", err)
+		}
+		archives = append(archives, archive)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading expired archives: %w", err)
+	}
+
+	expired := 0
+	for _, archive := range archives {
+		if _, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.config.ArchiveBucket),
+			Key:    aws.String(archive.ObjectKey),
+		}); err != nil {
+			return expired, fmt.Errorf("failed to delete conversation archive %s: %w", archive.ObjectKey, err)
+		}
+		if _, err := s.db.Exec(ctx, `DELETE FROM conversation_archives WHERE id = $1`, archive.ID); err != nil {
+			return expired, fmt.Errorf("failed to delete conversation archive record: %w", err)
+		}
+		conversationsArchivedTotal.Inc("expired")
+		expired++
+	}
+	return expired, nil
+}
+
+// lockArchiveOf returns the archive holding a message, locked for an update of its
+// object until tx ends, or ErrMessageNotFound
+func (s *ArchiveService) lockArchiveOf(ctx context.Context, tx pgx.Tx, messageID uuid.UUID) (*models.ConversationArchive, error) {
+	archive, err := scanConversationArchive(tx.QueryRow(ctx, `SELECT`+conversationArchiveColumns+`
+		FROM conversation_archives
+		WHERE message_ids @> ARRAY[$1::uuid]
+		FOR UPDATE`, messageID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to find conversation archive: %w", err)
+	}
+	return archive, nil
+}
+
+// upload writes a conversation to the archive bucket as gzipped JSON
+func (s *ArchiveService) upload(ctx context.Context, key string, conversation *models.ArchivedConversation) error {
+	var body bytes.Buffer
+	writer := gzip.NewWriter(&body)
+	if err := json.NewEncoder(writer).Encode(conversation); err != nil {
+		return fmt.Errorf("failed to encode conversation archive: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress conversation archive: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:          aws.String(s.config.ArchiveBucket),
+		Key:             aws.String(key),
+		Body:            bytes.NewReader(body.Bytes()),
+		ContentType:     aws.String("application/json"),
+		ContentEncoding: aws.String("gzip"),
+		StorageClass:    types.StorageClass(s.config.ArchiveStorageClass),
+	}
+	applyObjectSettings(s.config, input, url.Values{"conversation": {conversation.SessionID.String()}})
+	if _, err := s.s3Client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload conversation archive: %w", err)
+	}
+	return nil
+}
+
+// CheckEncryption verifies that the archive bucket encrypts objects by default
+func (s *ArchiveService) CheckEncryption(ctx context.Context) error {
+	if s.config.ArchiveBucket == "" {
+		return nil
+	}
+	return checkBucketEncryption(ctx, s.s3Client, s.config, s.config.ArchiveBucket)
+}
+
+// load reads an archived conversation back from the archive bucket
+func (s *ArchiveService) load(ctx context.Context, archive *models.ConversationArchive) (*models.ArchivedConversation, error) {
+	object, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.ArchiveBucket),
+		Key:    aws.String(archive.ObjectKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch conversation archive %s: %w", archive.ObjectKey, err)
+	}
+	defer object.Body.Close()
+
+	reader, err := gzip.NewReader(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress conversation archive %s: %w", archive.ObjectKey, err)
+	}
+	defer reader.Close()
+
+	var conversation models.ArchivedConversation
+	if err := json.NewDecoder(reader).Decode(&conversation); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation archive %s: %w", archive.ObjectKey, err)
+	}
+	return &conversation, nil
+}
+
+// archiveIndex returns the IDs of an archived conversation's messages and their distinct
+// non-empty metadata as a JSON array. An array contains [filter] in JSONB when one of its
+// elements contains filter, so the column answers the same @> test as the messages.
+func archiveIndex(conversation *models.ArchivedConversation) ([]uuid.UUID, []byte, error) {
+	messageIDs := make([]uuid.UUID, 0, len(conversation.Messages))
+	metadata := []json.RawMessage{}
+	seen := make(map[string]bool)
+	for _, message := range conversation.Messages {
+		messageIDs = append(messageIDs, message.ID)
+		if len(message.Metadata) == 0 {
+			continue
+		}
+		encoded, err := json.Marshal(message.Metadata)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode message metadata: %w", err)
+		}
+		if !seen[string(encoded)] {
+			seen[string(encoded)] = true
+			metadata = append(metadata, encoded)
+		}
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode archive metadata: %w", err)
+	}
+	return messageIDs, encoded, nil
+}
+
+// scanConversationArchive scans a conversation_archives row selected with
+// conversationArchiveColumns
+func scanConversationArchive(row pgx.Row) (*models.ConversationArchive, error) {
+	var archive models.ConversationArchive
+	err := row.Scan(
+		&archive.ID,
+		&archive.SessionID,
+		&archive.UserID,
+		&archive.ObjectKey,
+		&archive.MessageCount,
+		&archive.FirstMessageAt,
+		&archive.LastMessageAt,
+		&archive.ArchivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestArchiveIndex(t *testing.T) {
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		messages     []*models.WhatsAppMessage
+		wantIDs      []uuid.UUID
+		wantMetadata string
+	}{
+		{
+			name:         "empty conversation",
+			messages:     []*models.WhatsAppMessage{},
+			wantIDs:      []uuid.UUID{},
+			wantMetadata: `[]`,
+		},
+		{
+			name: "messages without metadata",
+			messages: []*models.WhatsAppMessage{
+				{ID: first},
+				{ID: second, Metadata: models.Metadata{}},
+			},
+			wantIDs:      []uuid.UUID{first, second},
+			wantMetadata: `[]`,
+		},
+		{
+			name: "repeated metadata is kept once",
+			messages: []*models.WhatsAppMessage{
+				{ID: first, Metadata: models.Metadata{"listing_id": "L1"}},
+				{ID: second, Metadata: models.Metadata{"listing_id": "L1"}},
+				{ID: third, Metadata: models.Metadata{"listing_id": "L2", "tags": []interface{}{"vip"}}},
+			},
+			wantIDs:      []uuid.UUID{first, second, third},
+			wantMetadata: `[{"listing_id":"L1"},{"listing_id":"L2","tags":["vip"]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, metadata, err := archiveIndex(&models.ArchivedConversation{Messages: tt.messages})
+			if err != nil {
+				t.Fatalf("archiveIndex() error = %v", err)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("archiveIndex() IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			if string(metadata) != tt.wantMetadata {
+				t.Errorf("archiveIndex() metadata = %s, want %s", metadata, tt.wantMetadata)
+			}
+		})
+	}
+}
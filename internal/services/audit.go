@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// dbExecer is satisfied by the pool and by transactions, so audit entries and outbox
+// entries can be written atomically with the change they describe
+type dbExecer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// AuditService records administrative actions in the audit log
+type AuditService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewAuditService creates a new audit log service
+func NewAuditService(db *pgxpool.Pool, logger *logrus.Logger) *AuditService {
+	return &AuditService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Record writes an audit entry
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditEntry) error {
+	return recordAudit(ctx, s.db, entry)
+}
+
+// List returns audit entries, newest first, optionally for a single target or actions
+// starting with actionPrefix
+func (s *AuditService) List(ctx context.Context, targetType, targetID, actionPrefix string, limit, offset int) ([]*models.AuditEntry, int64, error) {
+	query := `
+		SELECT id, actor, COALESCE(actor_role, ''), COALESCE(client_ip, ''), action, target_type, target_id, details, created_at
+		FROM audit_log
+		WHERE ($1 = '' OR target_type = $1) AND ($2 = '' OR target_id = $2)
+			AND ($3 = '' OR starts_with(action, $3))
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := s.db.Query(ctx, query, targetType, targetID, actionPrefix, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.Actor,
+			&entry.ActorRole,
+			&entry.ClientIP,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.Details,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading audit log: %w", err)
+	}
+
+	return entries, estimateTotal(ctx, s.db, s.logger, query, targetType, targetID, actionPrefix, limit, offset), nil
+}
+
+// recordAudit inserts an audit entry through the given pool or transaction
+func recordAudit(ctx context.Context, db dbExecer, entry *models.AuditEntry) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	_, err := db.Exec(ctx, `
+		INSERT INTO audit_log (id, actor, actor_role, client_ip, action, target_type, target_id, details, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, $8, $9)`,
+		entry.ID,
+		entry.Actor,
+		entry.ActorRole,
+		entry.ClientIP,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.Details,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,535 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// Automation errors
+var (
+	ErrAutomationNotFound = errors.New("automation not found")
+	ErrInvalidAutomation  = errors.New("invalid automation")
+)
+
+// automationColumns lists the automations columns in the order scanAutomation expects
+const automationColumns = `
+	id, name, tenant, channel, match_type, pattern, action, template_sid,
+	template_variables, tags, priority, is_active, created_at, updated_at`
+
+// dbQueryRower is satisfied by the pool and by transactions, so bundle imports can
+// write automations atomically
+type dbQueryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// compiledAutomation is an active automation ready to match message text
+type compiledAutomation struct {
+	*models.Automation
+	regex    *regexp.Regexp
+	keywords []string
+}
+
+// AutomationService runs keyword and regex automations on inbound messages so simple
+// flows (send a template, tag the conversation, hand off to a human) need no AI round
+// trip. Active rules are cached and reloaded when they change on any replica.
+type AutomationService struct {
+	db             *pgxpool.Pool
+	cacheBus       *CacheBus
+	sessionService *SessionService
+	autoReply      *AutoReplyService
+	eventService   *EventService
+	config         *config.Config
+	logger         *logrus.Logger
+
+	mu    sync.RWMutex
+	rules []*compiledAutomation
+}
+
+// NewAutomationService creates a new automation service
+func NewAutomationService(
+	db *pgxpool.Pool,
+	cacheBus *CacheBus,
+	sessionService *SessionService,
+	autoReply *AutoReplyService,
+	eventService *EventService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *AutomationService {
+	return &AutomationService{
+		db:             db,
+		cacheBus:       cacheBus,
+		sessionService: sessionService,
+		autoReply:      autoReply,
+		eventService:   eventService,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// Start loads the automations and reloads them on changes and periodically until ctx is done
+func (s *AutomationService) Start(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Error("Failed to load automations")
+	}
+
+	changes := make(chan struct{}, 1)
+	s.cacheBus.Subscribe(CacheTopicAutomations, func(string) {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+
+	ticker := time.NewTicker(s.config.AutomationsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+
+		if err := s.Reload(ctx); err != nil {
+			s.logger.WithError(err).Error("Failed to reload automations")
+		}
+	}
+}
+
+// Reload replaces the cached rules with the active stored automations
+func (s *AutomationService) Reload(ctx context.Context) error {
+	automations, err := s.list(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]*compiledAutomation, 0, len(automations))
+	for _, automation := range automations {
+		rule, err := compileAutomation(automation)
+		if err != nil {
+			s.logger.WithError(err).WithField("automation", automation.Name).Error("Skipping invalid automation")
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+
+	s.logger.WithField("automations", len(rules)).Debug("Automations loaded")
+	return nil
+}
+
+// AutomationMatch holds the automations an inbound message triggered, in priority order
+type AutomationMatch struct {
+	rules   []*compiledAutomation
+	Handled bool // a send_template or open_handoff rule answers the message
+}
+
+// Match finds the automations an inbound text message triggers without running them,
+// so the decision can be made before the message is stored and the actions run only
+// once it is known to be new. Tags accumulate; the first send_template or open_handoff
+// rule ends matching and marks the message as handled, so it is not forwarded to the
+// orchestrator.
+func (s *AutomationService) Match(message *models.WhatsAppMessage) *AutomationMatch {
+	match := &AutomationMatch{}
+	if message.Direction != models.MessageDirectionInbound || strings.TrimSpace(message.Content) == "" {
+		return match
+	}
+
+	s.mu.RLock()
+	rules := s.rules
+	s.mu.RUnlock()
+
+	tenant := normalizeRecipient(message.Channel, message.To)
+	for _, rule := range rules {
+		if !rule.applies(message.Channel, tenant) || !rule.matches(message.Content) {
+			continue
+		}
+
+		match.rules = append(match.rules, rule)
+		if rule.Action == models.AutomationActionSendTemplate || rule.Action == models.AutomationActionOpenHandoff {
+			match.Handled = true
+			break
+		}
+	}
+
+	return match
+}
+
+// Apply runs the actions of the automations a message matched
+func (s *AutomationService) Apply(ctx context.Context, match *AutomationMatch, message *models.WhatsAppMessage, session *models.ChatSession) {
+	for _, rule := range match.rules {
+		s.fired(ctx, rule, message)
+
+		switch rule.Action {
+		case models.AutomationActionTagConversation:
+			if session == nil {
+				continue
+			}
+			if err := s.sessionService.AddTags(ctx, session.ID, rule.Tags); err != nil {
+				s.logger.WithError(err).WithField("automation", rule.Name).Warn("Failed to tag conversation")
+			}
+
+		case models.AutomationActionSendTemplate:
+			go func(rule *compiledAutomation) {
+				if _, err := s.autoReply.ReplyTemplate(context.Background(), message, rule.TemplateSID, rule.TemplateVariables); err != nil {
+					s.logger.WithError(err).WithField("automation", rule.Name).Warn("Automation failed to send template")
+				}
+			}(rule)
+
+		case models.AutomationActionOpenHandoff:
+			if session != nil {
+				if err := s.sessionService.SetState(ctx, session.ID, models.ConversationStateHandoff); err != nil {
+					s.logger.WithError(err).WithField("automation", rule.Name).Warn("Failed to open handoff")
+				}
+			}
+		}
+	}
+}
+
+// List returns every stored automation
+func (s *AutomationService) List(ctx context.Context) ([]*models.Automation, error) {
+	return s.list(ctx, false)
+}
+
+// Create stores a new automation
+func (s *AutomationService) Create(ctx context.Context, request *models.AutomationRequest) (*models.Automation, error) {
+	automation := &models.Automation{
+		ID:                uuid.New(),
+		TemplateVariables: map[string]string{},
+		Tags:              []string{},
+		Priority:          100,
+		IsActive:          true,
+	}
+	applyAutomationRequest(automation, request)
+
+	return s.save(ctx, automation, true)
+}
+
+// Update changes a stored automation
+func (s *AutomationService) Update(ctx context.Context, id uuid.UUID, request *models.AutomationRequest) (*models.Automation, error) {
+	automation, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyAutomationRequest(automation, request)
+
+	return s.save(ctx, automation, false)
+}
+
+// Delete removes a stored automation
+func (s *AutomationService) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM automations WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete automation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAutomationNotFound
+	}
+
+	s.changed(ctx)
+	return nil
+}
+
+// Helper methods
+
+// fired logs and publishes a matched automation
+func (s *AutomationService) fired(ctx context.Context, rule *compiledAutomation, message *models.WhatsAppMessage) {
+	s.logger.WithFields(logrus.Fields{
+		"automation": rule.Name,
+		"action":     rule.Action,
+		"message_id": message.ID,
+	}).Info("Automation fired")
+
+	messageID := message.ID
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventAutomationFired,
+		MessageID: &messageID,
+		SessionID: message.SessionID,
+		Data: map[string]interface{}{
+			"automation_id": rule.ID,
+			"automation":    rule.Name,
+			"action":        rule.Action,
+			"tags":          rule.Tags,
+		},
+	})
+}
+
+// save validates and writes an automation, then reloads the rules
+func (s *AutomationService) save(ctx context.Context, automation *models.Automation, create bool) (*models.Automation, error) {
+	saved, err := writeAutomation(ctx, s.db, automation, create)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"automation": saved.Name,
+		"action":     saved.Action,
+	}).Info("Automation saved")
+
+	s.changed(ctx)
+	return saved, nil
+}
+
+// changed reloads local rules and tells other replicas to do the same
+func (s *AutomationService) changed(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Error("Failed to reload automations")
+	}
+	s.cacheBus.Publish(ctx, CacheTopicAutomations, "")
+}
+
+// get loads one automation
+func (s *AutomationService) get(ctx context.Context, id uuid.UUID) (*models.Automation, error) {
+	query := `SELECT` + automationColumns + ` FROM automations WHERE id = $1`
+
+	automation, err := scanAutomation(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAutomationNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve automation: %w", err)
+	}
+
+	return automation, nil
+}
+
+// list loads automations in evaluation order, optionally only the active ones
+func (s *AutomationService) list(ctx context.Context, activeOnly bool) ([]*models.Automation, error) {
+	query := `SELECT` + automationColumns + ` FROM automations
+		WHERE is_active OR NOT $1
+		ORDER BY priority, created_at`
+
+	rows, err := s.db.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query automations: %w", err)
+	}
+	defer rows.Close()
+
+	automations := []*models.Automation{}
+	for rows.Next() {
+		automation, err := scanAutomation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan automation: %w", err)
+		}
+		automations = append(automations, automation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading automations: %w", err)
+	}
+
+	return automations, nil
+}
+
+// writeAutomation validates and writes an automation through the pool or a transaction
+func writeAutomation(ctx context.Context, db dbQueryRower, automation *models.Automation, create bool) (*models.Automation, error) {
+	automation.Tenant = strings.TrimSpace(automation.Tenant)
+	if automation.Tenant != "" {
+		automation.Tenant = normalizeRecipient(automation.Channel, automation.Tenant)
+	}
+	if _, err := compileAutomation(automation); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAutomation, err)
+	}
+
+	var query string
+	if create {
+		query = `
+			INSERT INTO automations (id, name, tenant, channel, match_type, pattern, action, template_sid,
+				template_variables, tags, priority, is_active, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+			RETURNING` + automationColumns
+	} else {
+		query = `
+			UPDATE automations
+			SET name = $2, tenant = $3, channel = $4, match_type = $5, pattern = $6, action = $7,
+				template_sid = $8, template_variables = $9, tags = $10, priority = $11, is_active = $12,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING` + automationColumns
+	}
+
+	saved, err := scanAutomation(db.QueryRow(ctx, query,
+		automation.ID,
+		automation.Name,
+		automation.Tenant,
+		automation.Channel,
+		automation.MatchType,
+		automation.Pattern,
+		automation.Action,
+		automation.TemplateSID,
+		automation.TemplateVariables,
+		automation.Tags,
+		automation.Priority,
+		automation.IsActive,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAutomationNotFound
+		}
+		return nil, fmt.Errorf("failed to save automation: %w", err)
+	}
+
+	return saved, nil
+}
+
+// scanAutomation scans an automations row selected with automationColumns
+func scanAutomation(row pgx.Row) (*models.Automation, error) {
+	var automation models.Automation
+	err := row.Scan(
+		&automation.ID,
+		&automation.Name,
+		&automation.Tenant,
+		&automation.Channel,
+		&automation.MatchType,
+		&automation.Pattern,
+		&automation.Action,
+		&automation.TemplateSID,
+		&automation.TemplateVariables,
+		&automation.Tags,
+		&automation.Priority,
+		&automation.IsActive,
+		&automation.CreatedAt,
+		&automation.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &automation, nil
+}
+
+// applyAutomationRequest copies the provided request fields onto an automation
+func applyAutomationRequest(automation *models.Automation, request *models.AutomationRequest) {
+	if request.Name != nil {
+		automation.Name = *request.Name
+	}
+	if request.Tenant != nil {
+		automation.Tenant = *request.Tenant
+	}
+	if request.Channel != nil {
+		automation.Channel = *request.Channel
+	}
+	if request.MatchType != nil {
+		automation.MatchType = *request.MatchType
+	}
+	if request.Pattern != nil {
+		automation.Pattern = *request.Pattern
+	}
+	if request.Action != nil {
+		automation.Action = *request.Action
+	}
+	if request.TemplateSID != nil {
+		automation.TemplateSID = *request.TemplateSID
+	}
+	if request.TemplateVariables != nil {
+		automation.TemplateVariables = request.TemplateVariables
+	}
+	if request.Tags != nil {
+		automation.Tags = request.Tags
+	}
+	if request.Priority != nil {
+		automation.Priority = *request.Priority
+	}
+	if request.IsActive != nil {
+		automation.IsActive = *request.IsActive
+	}
+}
+
+// compileAutomation validates an automation and prepares its matcher
+func compileAutomation(automation *models.Automation) (*compiledAutomation, error) {
+	if strings.TrimSpace(automation.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(automation.Pattern) == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+
+	rule := &compiledAutomation{Automation: automation}
+
+	switch automation.MatchType {
+	case models.AutomationMatchKeyword:
+		rule.keywords = words(automation.Pattern)
+		if len(rule.keywords) == 0 {
+			return nil, fmt.Errorf("keyword pattern has no words")
+		}
+	case models.AutomationMatchRegex:
+		regex, err := regexp.Compile("(?i)" + automation.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		rule.regex = regex
+	default:
+		return nil, fmt.Errorf("match_type must be %q or %q", models.AutomationMatchKeyword, models.AutomationMatchRegex)
+	}
+
+	switch automation.Action {
+	case models.AutomationActionSendTemplate:
+		if automation.TemplateSID == "" {
+			return nil, fmt.Errorf("template_sid is required for %s", automation.Action)
+		}
+	case models.AutomationActionTagConversation:
+		if len(automation.Tags) == 0 {
+			return nil, fmt.Errorf("tags are required for %s", automation.Action)
+		}
+	case models.AutomationActionOpenHandoff:
+	default:
+		return nil, fmt.Errorf("unknown action %q", automation.Action)
+	}
+
+	return rule, nil
+}
+
+// applies reports whether a rule covers the channel and receiving number of a message
+func (r *compiledAutomation) applies(channel models.Channel, tenant string) bool {
+	if r.Channel != "" && r.Channel != channel {
+		return false
+	}
+	return r.Tenant == "" || r.Tenant == tenant
+}
+
+// matches reports whether message text triggers the rule
+func (r *compiledAutomation) matches(text string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(text)
+	}
+
+	// Keywords match as a sequence of whole words, so "preço" does not match "apreço"
+	textWords := words(text)
+	for i := 0; i+len(r.keywords) <= len(textWords); i++ {
+		found := true
+		for j, keyword := range r.keywords {
+			if textWords[i+j] != keyword {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// words splits text into lowercase words of letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
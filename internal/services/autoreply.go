@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// AutoReplyService sends adapter-generated replies (rejections, prompts, notices)
+// and records them in the conversation like any other outbound message
+type AutoReplyService struct {
+	channels        *ChannelProviders
+	messageService  *MessageService
+	identityService *IdentityService
+	catalog         *i18n.Catalog
+	logger          *logrus.Logger
+}
+
+// NewAutoReplyService creates a new auto-reply service instance
+func NewAutoReplyService(channels *ChannelProviders, messageService *MessageService, identityService *IdentityService, catalog *i18n.Catalog, logger *logrus.Logger) *AutoReplyService {
+	return &AutoReplyService{
+		channels:        channels,
+		messageService:  messageService,
+		identityService: identityService,
+		catalog:         catalog,
+		logger:          logger,
+	}
+}
+
+// ReplyMessage sends a catalog message to the sender of an inbound message in the
+// sender's locale
+func (a *AutoReplyService) ReplyMessage(ctx context.Context, inbound *models.WhatsAppMessage, message i18n.Message) (*models.WhatsAppMessage, error) {
+	return a.Reply(ctx, inbound, a.catalog.Text(a.locale(ctx, inbound), message))
+}
+
+// locale returns the locale set for the sender of an inbound message, or an empty
+// string for the catalog default
+func (a *AutoReplyService) locale(ctx context.Context, inbound *models.WhatsAppMessage) string {
+	if inbound.UserID == nil {
+		return ""
+	}
+	locale, err := a.identityService.Locale(ctx, *inbound.UserID)
+	if err != nil {
+		a.logger.WithError(err).WithField("message_id", inbound.ID).Warn("Failed to load user locale; using default")
+		return ""
+	}
+	return locale
+}
+
+// Reply sends a text reply to the sender of an inbound message over the same channel
+// and stores it in the same session
+func (a *AutoReplyService) Reply(ctx context.Context, inbound *models.WhatsAppMessage, content string) (*models.WhatsAppMessage, error) {
+	provider, err := a.channels.For(inbound.Channel)
+	if err != nil {
+		a.logger.WithError(err).WithField("message_id", inbound.ID).Error("No provider for automatic reply")
+		return nil, err
+	}
+
+	response, err := provider.SendTextMessage(ctx, inbound.From, content)
+	if err != nil {
+		a.logger.WithError(err).WithField("message_id", inbound.ID).Error("Failed to send automatic reply")
+		return nil, err
+	}
+
+	return a.store(ctx, inbound, provider, response, content), nil
+}
+
+// ReplyTemplate sends a content template to the sender of an inbound message over the
+// same channel and stores it in the same session
+func (a *AutoReplyService) ReplyTemplate(ctx context.Context, inbound *models.WhatsAppMessage, templateSID string, variables map[string]string) (*models.WhatsAppMessage, error) {
+	provider, err := a.channels.For(inbound.Channel)
+	if err != nil {
+		a.logger.WithError(err).WithField("message_id", inbound.ID).Error("No provider for automatic reply")
+		return nil, err
+	}
+
+	response, err := provider.SendTemplateMessage(ctx, inbound.From, templateSID, variables)
+	if err != nil {
+		a.logger.WithError(err).WithField("message_id", inbound.ID).Error("Failed to send automatic template reply")
+		return nil, err
+	}
+
+	return a.store(ctx, inbound, provider, response, ""), nil
+}
+
+// store records an automatic reply in the conversation of the inbound message
+func (a *AutoReplyService) store(ctx context.Context, inbound *models.WhatsAppMessage, provider MessagingProvider, response *models.SendMessageResponse, content string) *models.WhatsAppMessage {
+	reply := &models.WhatsAppMessage{
+		ID:        response.ID,
+		TwilioSID: response.TwilioSID,
+		From:      provider.GetFromNumber(),
+		To:        inbound.From,
+		Direction: models.MessageDirectionOutbound,
+		Type:      models.MessageTypeText,
+		Status:    response.Status,
+		Content:   content,
+		Timestamp: response.CreatedAt,
+		CreatedAt: response.CreatedAt,
+		UpdatedAt: response.CreatedAt,
+		UserID:    inbound.UserID,
+		SessionID: inbound.SessionID,
+		Channel:   inbound.Channel,
+	}
+
+	if _, err := a.messageService.StoreMessage(ctx, reply); err != nil {
+		a.logger.WithError(err).Error("Failed to store automatic reply")
+	}
+
+	return reply
+}
+
+// ReplyUnsupportedAsync explains in the background that the type of an inbound message
+// cannot be handled, using the unsupported.<type> catalog message or unsupported.default.
+// A type whose message is empty is tagged without a reply.
+func (a *AutoReplyService) ReplyUnsupportedAsync(inbound *models.WhatsAppMessage) {
+	key := i18n.KeyUnsupportedPrefix + inbound.UnsupportedType
+	if !a.catalog.Has(key) {
+		key = i18n.KeyUnsupportedDefault
+	}
+
+	go func() {
+		ctx := context.Background()
+		content := a.catalog.Text(a.locale(ctx, inbound), i18n.Message{Key: key})
+		if content == "" {
+			return
+		}
+		_, _ = a.Reply(ctx, inbound, content)
+	}()
+}
+
+// ReplyAsync sends a catalog message in the background, for use on latency-sensitive
+// webhook paths
+func (a *AutoReplyService) ReplyAsync(inbound *models.WhatsAppMessage, message i18n.Message) {
+	go func() {
+		_, _ = a.ReplyMessage(context.Background(), inbound, message)
+	}()
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrInvalidBundle is returned for bundles that cannot be imported
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// bundleTemplateKeys are the configuration keys of the message templates a bundle carries
+var bundleTemplateKeys = []string{
+	"MEDIA_RESEND_TEMPLATE_SID",
+	"PAYMENT_TEMPLATE_SID",
+	"LOCATION_REQUEST_TEMPLATE_SID",
+	"APPOINTMENT_TEMPLATE_SID",
+}
+
+// bundlePolicyKeys are the configuration keys of the policies a bundle carries
+var bundlePolicyKeys = []string{
+	"MEDIA_ALLOWED_TYPES",
+	"MEDIA_MAX_IMAGE_BYTES",
+	"MEDIA_MAX_VIDEO_BYTES",
+	"MEDIA_MAX_AUDIO_BYTES",
+	"MEDIA_MAX_DOCUMENT_BYTES",
+	"REVOKED_MESSAGE_POLICY",
+	"SMS_FALLBACK_ENABLED",
+	"SMS_FALLBACK_CATEGORIES",
+	"SMS_FALLBACK_ERROR_CODES",
+}
+
+// BundleService exports the templates, automations and policies of this environment
+// as a versioned bundle and imports bundles of other environments, e.g. to promote
+// staging to production. Imports apply automations; templates and policies come from
+// the configuration and are only reported where they differ.
+type BundleService struct {
+	db                *pgxpool.Pool
+	automationService *AutomationService
+	config            *config.Config
+	logger            *logrus.Logger
+}
+
+// NewBundleService creates a new bundle service
+func NewBundleService(db *pgxpool.Pool, automationService *AutomationService, cfg *config.Config, logger *logrus.Logger) *BundleService {
+	return &BundleService{
+		db:                db,
+		automationService: automationService,
+		config:            cfg,
+		logger:            logger,
+	}
+}
+
+// Export returns the bundle of this environment
+func (s *BundleService) Export(ctx context.Context) (*models.ConfigBundle, error) {
+	automations, err := s.automationService.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &models.ConfigBundle{
+		Version:     models.BundleVersion,
+		Environment: s.config.Environment,
+		ExportedAt:  time.Now().UTC(),
+		Templates:   s.settings(bundleTemplateKeys),
+		Automations: make([]*models.BundleAutomation, 0, len(automations)),
+		Policies:    s.settings(bundlePolicyKeys),
+	}
+	for _, automation := range automations {
+		bundle.Automations = append(bundle.Automations, &models.BundleAutomation{
+			Name:              automation.Name,
+			Tenant:            automation.Tenant,
+			Channel:           automation.Channel,
+			MatchType:         automation.MatchType,
+			Pattern:           automation.Pattern,
+			Action:            automation.Action,
+			TemplateSID:       automation.TemplateSID,
+			TemplateVariables: automation.TemplateVariables,
+			Tags:              automation.Tags,
+			Priority:          automation.Priority,
+			IsActive:          automation.IsActive,
+		})
+	}
+
+	return bundle, nil
+}
+
+// Import compares a bundle with this environment and applies its automations in one
+// transaction. With prune, automations missing from the bundle are deleted; with
+// dryRun nothing is written and the result only lists the differences.
+func (s *BundleService) Import(ctx context.Context, bundle *models.ConfigBundle, dryRun, prune bool, actor, role, clientIP string) (*models.BundleImportResult, error) {
+	if bundle.Version != models.BundleVersion {
+		return nil, fmt.Errorf("%w: version %d is not supported, expected %d", ErrInvalidBundle, bundle.Version, models.BundleVersion)
+	}
+
+	current, err := s.automationService.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*models.Automation, len(current))
+	ambiguous := make(map[string]bool)
+	for _, automation := range current {
+		if _, ok := byName[automation.Name]; ok {
+			ambiguous[automation.Name] = true
+		}
+		byName[automation.Name] = automation
+	}
+
+	result := &models.BundleImportResult{DryRun: dryRun, Changes: []models.BundleChange{}}
+	var writes []*models.Automation
+	var deletes []*models.Automation
+
+	inBundle := make(map[string]bool, len(bundle.Automations))
+	for _, item := range bundle.Automations {
+		if inBundle[item.Name] {
+			return nil, fmt.Errorf("%w: automation %q appears more than once", ErrInvalidBundle, item.Name)
+		}
+		inBundle[item.Name] = true
+		if ambiguous[item.Name] {
+			return nil, fmt.Errorf("%w: automation %q matches several automations here; rename them first", ErrInvalidBundle, item.Name)
+		}
+
+		existing := byName[item.Name]
+		automation := bundleAutomation(item)
+		if _, err := compileAutomation(automation); err != nil {
+			return nil, fmt.Errorf("%w: automation %q: %v", ErrInvalidBundle, item.Name, err)
+		}
+
+		if existing == nil {
+			automation.ID = uuid.New()
+			writes = append(writes, automation)
+			result.Changes = append(result.Changes, models.BundleChange{
+				Kind:   models.BundleKindAutomation,
+				Key:    item.Name,
+				Change: models.BundleChangeCreate,
+			})
+			continue
+		}
+
+		fields := automationChanges(existing, automation)
+		if len(fields) == 0 {
+			continue
+		}
+		automation.ID = existing.ID
+		writes = append(writes, automation)
+		result.Changes = append(result.Changes, models.BundleChange{
+			Kind:   models.BundleKindAutomation,
+			Key:    item.Name,
+			Change: models.BundleChangeUpdate,
+			Fields: fields,
+		})
+	}
+
+	if prune {
+		for _, automation := range current {
+			if inBundle[automation.Name] {
+				continue
+			}
+			deletes = append(deletes, automation)
+			result.Changes = append(result.Changes, models.BundleChange{
+				Kind:   models.BundleKindAutomation,
+				Key:    automation.Name,
+				Change: models.BundleChangeDelete,
+			})
+		}
+	}
+
+	result.Changes = append(result.Changes, settingChanges(models.BundleKindTemplate, bundle.Templates, s.settings(bundleTemplateKeys))...)
+	result.Changes = append(result.Changes, settingChanges(models.BundleKindPolicy, bundle.Policies, s.settings(bundlePolicyKeys))...)
+
+	if dryRun || (len(writes) == 0 && len(deletes) == 0) {
+		return result, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin bundle import: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, automation := range writes {
+		if _, err := writeAutomation(ctx, tx, automation, byName[automation.Name] == nil); err != nil {
+			return nil, fmt.Errorf("failed to import automation %q: %w", automation.Name, err)
+		}
+	}
+	for _, automation := range deletes {
+		if _, err := tx.Exec(ctx, `DELETE FROM automations WHERE id = $1`, automation.ID); err != nil {
+			return nil, fmt.Errorf("failed to delete automation %q: %w", automation.Name, err)
+		}
+	}
+
+	err = recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  role,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionBundleImported,
+		TargetType: "bundle",
+		TargetID:   bundle.Environment,
+		Details: map[string]interface{}{
+			"exported_at": bundle.ExportedAt,
+			"written":     len(writes),
+			"deleted":     len(deletes),
+			"prune":       prune,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit bundle import: %w", err)
+	}
+
+	for i := range result.Changes {
+		if result.Changes[i].Kind == models.BundleKindAutomation {
+			result.Changes[i].Applied = true
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"environment": bundle.Environment,
+		"written":     len(writes),
+		"deleted":     len(deletes),
+		"admin":       actor,
+	}).Info("Configuration bundle imported")
+
+	s.automationService.changed(ctx)
+	return result, nil
+}
+
+// settings returns the effective values of the given configuration keys
+func (s *BundleService) settings(keys []string) map[string]string {
+	effective := make(map[string]string, len(keys))
+	for _, setting := range s.config.Settings() {
+		effective[setting.Key] = setting.Value
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := effective[key]; ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// bundleAutomation converts a bundle automation to the automation it is stored as
+func bundleAutomation(item *models.BundleAutomation) *models.Automation {
+	automation := &models.Automation{
+		Name:              item.Name,
+		Tenant:            item.Tenant,
+		Channel:           item.Channel,
+		MatchType:         item.MatchType,
+		Pattern:           item.Pattern,
+		Action:            item.Action,
+		TemplateSID:       item.TemplateSID,
+		TemplateVariables: item.TemplateVariables,
+		Tags:              item.Tags,
+		Priority:          item.Priority,
+		IsActive:          item.IsActive,
+	}
+	if automation.TemplateVariables == nil {
+		automation.TemplateVariables = map[string]string{}
+	}
+	if automation.Tags == nil {
+		automation.Tags = []string{}
+	}
+	return automation
+}
+
+// automationChanges returns the names of the fields that differ between two automations
+func automationChanges(current, next *models.Automation) []string {
+	var fields []string
+	for _, field := range []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"tenant", current.Tenant, normalizedTenant(next)},
+		{"channel", current.Channel, next.Channel},
+		{"match_type", current.MatchType, next.MatchType},
+		{"pattern", current.Pattern, next.Pattern},
+		{"action", current.Action, next.Action},
+		{"template_sid", current.TemplateSID, next.TemplateSID},
+		{"priority", current.Priority, next.Priority},
+		{"is_active", current.IsActive, next.IsActive},
+	} {
+		if field.from != field.to {
+			fields = append(fields, field.name)
+		}
+	}
+
+	if (len(current.TemplateVariables) > 0 || len(next.TemplateVariables) > 0) && !reflect.DeepEqual(current.TemplateVariables, next.TemplateVariables) {
+		fields = append(fields, "template_variables")
+	}
+	if (len(current.Tags) > 0 || len(next.Tags) > 0) && !reflect.DeepEqual(current.Tags, next.Tags) {
+		fields = append(fields, "tags")
+	}
+	return fields
+}
+
+// normalizedTenant returns the tenant of an automation as writeAutomation stores it
+func normalizedTenant(automation *models.Automation) string {
+	if automation.Tenant == "" {
+		return ""
+	}
+	return normalizeRecipient(automation.Channel, automation.Tenant)
+}
+
+// settingChanges lists the configuration keys whose bundle value differs from the
+// current one, sorted by key
+func settingChanges(kind string, bundle, current map[string]string) []models.BundleChange {
+	keys := make([]string, 0, len(bundle))
+	for key := range bundle {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []models.BundleChange
+	for _, key := range keys {
+		value, ok := current[key]
+		if ok && value == bundle[key] {
+			continue
+		}
+		change := models.BundleChange{
+			Kind:    kind,
+			Key:     key,
+			Change:  models.BundleChangeUpdate,
+			Current: value,
+			Bundle:  bundle[key],
+		}
+		if !ok {
+			change.Change = models.BundleChangeCreate
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
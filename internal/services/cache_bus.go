@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Cache bus transports
+const (
+	CacheBusTransportRedis    = "redis"
+	CacheBusTransportPostgres = "postgres"
+)
+
+// Cache topics; the key identifies the changed entry, empty for "everything"
+const (
+	CacheTopicProviderConfigs = "provider_configs"
+	CacheTopicAutomations     = "automations"
+	CacheTopicAPIKeys         = "api_keys"
+)
+
+// cacheBusChannel is the Redis channel and Postgres NOTIFY channel the bus uses
+const cacheBusChannel = "cache_invalidation"
+
+// cacheBusReconnectDelay is how long the listener waits before reconnecting
+const cacheBusReconnectDelay = 5 * time.Second
+
+// cacheInvalidationsTotal counts invalidations by topic and direction
+var cacheInvalidationsTotal = metrics.NewCounter("cache_invalidations_total", "Cache invalidations sent to and received from other replicas", "topic", "direction")
+
+// cacheInvalidation is the message exchanged between replicas
+type cacheInvalidation struct {
+	Origin string `json:"origin"`
+	Topic  string `json:"topic"`
+	Key    string `json:"key,omitempty"`
+}
+
+// cacheBusTransport carries invalidations between replicas
+type cacheBusTransport interface {
+	publish(ctx context.Context, payload []byte) error
+	// listen delivers payloads until ctx is done or the connection fails
+	listen(ctx context.Context, deliver func(payload []byte)) error
+}
+
+// CacheBus tells other replicas that cached data changed so they can drop or reload
+// their in-process copies. Invalidations are delivered at most once and only to other
+// replicas: the publisher updates its own caches itself. Caches that cannot afford a
+// missed invalidation should still expire or refresh periodically.
+type CacheBus struct {
+	transport cacheBusTransport
+	origin    string
+	logger    *logrus.Logger
+
+	mu       sync.RWMutex
+	handlers map[string][]func(key string)
+}
+
+// NewCacheBus creates a cache bus on the configured transport
+func NewCacheBus(cfg *config.Config, db *pgxpool.Pool, redisClient *redis.Client, logger *logrus.Logger) (*CacheBus, error) {
+	var transport cacheBusTransport
+	switch cfg.CacheBusTransport {
+	case CacheBusTransportRedis, "":
+		transport = &redisCacheTransport{redis: redisClient, channel: redisKey(cfg, cacheBusChannel)}
+	case CacheBusTransportPostgres:
+		transport = &postgresCacheTransport{db: db}
+	default:
+		return nil, fmt.Errorf("unknown cache bus transport %q", cfg.CacheBusTransport)
+	}
+
+	return &CacheBus{
+		transport: transport,
+		origin:    uuid.New().String(),
+		logger:    logger,
+		handlers:  make(map[string][]func(key string)),
+	}, nil
+}
+
+// Subscribe registers a handler for invalidations of a topic from other replicas.
+// Handlers run on the listener goroutine and must not block.
+func (b *CacheBus) Subscribe(topic string, handler func(key string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[topic] = append(b.handlers[topic], handler)
+}
+
+// Publish tells other replicas that the entry key of a topic changed. Failures are
+// logged, never returned, so cache invalidation cannot fail the write it follows.
+func (b *CacheBus) Publish(ctx context.Context, topic, key string) {
+	payload, err := json.Marshal(cacheInvalidation{Origin: b.origin, Topic: topic, Key: key})
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to marshal cache invalidation")
+		return
+	}
+
+	if err := b.transport.publish(ctx, payload); err != nil {
+		b.logger.WithError(err).WithField("topic", topic).Warn("Failed to publish cache invalidation")
+		return
+	}
+
+	cacheInvalidationsTotal.Inc(topic, "sent")
+}
+
+// Start listens for invalidations from other replicas until ctx is done, reconnecting
+// after failures
+func (b *CacheBus) Start(ctx context.Context) {
+	for {
+		err := b.transport.listen(ctx, b.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+
+		b.logger.WithError(err).Warn("Cache bus listener disconnected, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cacheBusReconnectDelay):
+		}
+	}
+}
+
+// deliver dispatches a received invalidation to the topic's handlers
+func (b *CacheBus) deliver(payload []byte) {
+	var invalidation cacheInvalidation
+	if err := json.Unmarshal(payload, &invalidation); err != nil {
+		b.logger.WithError(err).Warn("Ignoring malformed cache invalidation")
+		return
+	}
+	if invalidation.Origin == b.origin {
+		return
+	}
+
+	cacheInvalidationsTotal.Inc(invalidation.Topic, "received")
+
+	b.mu.RLock()
+	handlers := b.handlers[invalidation.Topic]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(invalidation.Key)
+	}
+}
+
+// redisCacheTransport uses Redis pub/sub, on cacheBusChannel in the Redis namespace
+type redisCacheTransport struct {
+	redis   *redis.Client
+	channel string
+}
+
+func (t *redisCacheTransport) publish(ctx context.Context, payload []byte) error {
+	return t.redis.Publish(ctx, t.channel, payload).Err()
+}
+
+func (t *redisCacheTransport) listen(ctx context.Context, deliver func(payload []byte)) error {
+	pubsub := t.redis.Subscribe(ctx, t.channel)
+	defer pubsub.Close()
+
+	// Wait for the subscription so errors surface here instead of as a closed channel
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to cache bus: %w", err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return fmt.Errorf("cache bus subscription closed")
+			}
+			deliver([]byte(message.Payload))
+		}
+	}
+}
+
+// postgresCacheTransport uses LISTEN/NOTIFY. The listener holds one pool connection for
+// as long as it runs.
+type postgresCacheTransport struct {
+	db *pgxpool.Pool
+}
+
+func (t *postgresCacheTransport) publish(ctx context.Context, payload []byte) error {
+	_, err := t.db.Exec(ctx, `SELECT pg_notify($1, $2)`, cacheBusChannel, string(payload))
+	return err
+}
+
+func (t *postgresCacheTransport) listen(ctx context.Context, deliver func(payload []byte)) error {
+	conn, err := t.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire cache bus connection: %w", err)
+	}
+	// A connection left in LISTEN mode must not go back to the pool
+	defer func() {
+		conn.Conn().Close(context.Background())
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+cacheBusChannel); err != nil {
+		return fmt.Errorf("failed to listen on cache bus: %w", err)
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		deliver([]byte(notification.Payload))
+	}
+}
@@ -0,0 +1,1026 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var (
+	// ErrInvalidCampaign is returned for campaigns that cannot be created or scheduled
+	ErrInvalidCampaign = errors.New("invalid campaign")
+
+	// ErrCampaignNotFound is returned for unknown campaigns
+	ErrCampaignNotFound = errors.New("campaign not found")
+
+	// ErrCampaignTransition is returned when a campaign is not in a state the requested
+	// action applies to, e.g. pausing a completed campaign
+	ErrCampaignTransition = errors.New("campaign cannot make this transition")
+
+	// ErrCampaignRecipientSkipped is returned by a CampaignSender for recipients that must
+	// not get the message, e.g. suppressed ones, as opposed to sends that failed
+	ErrCampaignRecipientSkipped = errors.New("campaign recipient skipped")
+)
+
+// campaignMaxVariants bounds how many variants a campaign may test
+const campaignMaxVariants = 10
+
+// campaignConfidenceZ is the z-score of the 95% confidence intervals of variant reports
+const campaignConfidenceZ = 1.96
+
+// campaignSendingTimeout is how long a recipient may stay claimed before it is assumed
+// lost with the replica that claimed it. It is failed rather than retried, since the
+// message may have gone out.
+const campaignSendingTimeout = 10 * time.Minute
+
+var campaignSendsTotal = metrics.NewCounter("campaign_sends_total", "Campaign recipients by outcome", "outcome")
+
+// campaignRecipientColumns lists the campaign_recipients columns in the order
+// scanCampaignRecipient expects
+const campaignRecipientColumns = `
+	campaign_id, recipient, status, variant, timezone, send_after, message_id, error, sent_at`
+
+// campaignColumns lists the campaigns columns in the order scanCampaign expects
+const campaignColumns = `
+	id, name, tenant, status, channel, provider, message_type, content, media_url, media_type,
+	template, variables, category, variants, rate_per_minute, segment_id, audience_size, scheduled_at,
+	started_at, paused_at, completed_at, created_by, created_at, updated_at`
+
+// CampaignSender sends the message of a campaign to one recipient through the send
+// pipeline, returning ErrCampaignRecipientSkipped when the recipient must be left out
+type CampaignSender func(ctx context.Context, request *models.SendMessageRequest) (*models.SendMessageResponse, error)
+
+// CampaignService runs broadcasts of one message to an audience. A campaign is created
+// as a draft with its audience snapshotted into campaign_recipients, or with a segment
+// whose members are snapshotted when it launches, is scheduled, and runs from scheduled_at at most rate_per_minute (CAMPAIGN_RATE_PER_MINUTE) messages a
+// minute across all replicas until every recipient was sent, skipped or failed. Running
+// and scheduled campaigns can be paused and resumed.
+type CampaignService struct {
+	db             *pgxpool.Pool
+	eventService   *EventService
+	segmentService *SegmentService
+	frequencyCaps  *FrequencyCapService
+	quietHours     *quietHours // nil sends at any hour
+	sender         CampaignSender
+	config         *config.Config
+	logger         *logrus.Logger
+}
+
+// NewCampaignService creates a new campaign service instance
+func NewCampaignService(db *pgxpool.Pool, eventService *EventService, segmentService *SegmentService, frequencyCaps *FrequencyCapService, cfg *config.Config, logger *logrus.Logger) *CampaignService {
+	return &CampaignService{
+		db:             db,
+		eventService:   eventService,
+		segmentService: segmentService,
+		frequencyCaps:  frequencyCaps,
+		quietHours:     newQuietHours(cfg),
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// UseSender sends campaign messages through sender; campaigns do not run without one
+func (s *CampaignService) UseSender(sender CampaignSender) {
+	s.sender = sender
+}
+
+// Create stores a draft campaign and the snapshot of its audience. Recipients are
+// normalized and deduplicated, keeping the order they were given in. A campaign to a
+// segment gets its recipients when it launches; the segment must not already exceed
+// CAMPAIGN_MAX_RECIPIENTS. The tenant's frequency cap applies to the campaign's messages.
+func (s *CampaignService) Create(ctx context.Context, request *models.CreateCampaignRequest, tenant, createdBy string) (*models.Campaign, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidCampaign)
+	}
+	if request.RatePerMinute != nil && *request.RatePerMinute <= 0 {
+		return nil, fmt.Errorf("%w: rate_per_minute must be positive", ErrInvalidCampaign)
+	}
+
+	channel := request.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	recipients := make([]string, 0, len(request.Recipients))
+	seen := make(map[string]bool, len(request.Recipients))
+	for _, to := range request.Recipients {
+		to = normalizeRecipient(channel, to)
+		if to == "" || seen[to] {
+			continue
+		}
+		seen[to] = true
+		recipients = append(recipients, to)
+	}
+	switch {
+	case request.SegmentID != nil && len(recipients) > 0:
+		return nil, fmt.Errorf("%w: recipients and segment_id are mutually exclusive", ErrInvalidCampaign)
+	case request.SegmentID == nil && len(recipients) == 0:
+		return nil, fmt.Errorf("%w: at least one recipient or a segment_id is required", ErrInvalidCampaign)
+	case len(recipients) > s.config.CampaignMaxRecipients:
+		return nil, fmt.Errorf("%w: at most %d recipients are allowed", ErrInvalidCampaign, s.config.CampaignMaxRecipients)
+	}
+
+	if err := validateCampaignVariants(request.Variants); err != nil {
+		return nil, err
+	}
+
+	if request.SegmentID != nil {
+		segment, err := s.segmentService.Get(ctx, *request.SegmentID)
+		if errors.Is(err, ErrSegmentNotFound) {
+			return nil, fmt.Errorf("%w: segment not found", ErrInvalidCampaign)
+		}
+		if err != nil {
+			return nil, err
+		}
+		preview, err := s.segmentService.Preview(ctx, segment.Filters, channel)
+		if err != nil {
+			return nil, err
+		}
+		if preview.Count > int64(s.config.CampaignMaxRecipients) {
+			return nil, fmt.Errorf("%w: the segment has %d recipients, at most %d are allowed", ErrInvalidCampaign, preview.Count, s.config.CampaignMaxRecipients)
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin campaign: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	campaign, err := scanCampaign(tx.QueryRow(ctx, `
+		INSERT INTO campaigns (
+			id, name, tenant, status, channel, provider, message_type, content, media_url, media_type,
+			template, variables, category, variants, rate_per_minute, segment_id, audience_size,
+			created_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, NOW(), NOW())
+		RETURNING`+campaignColumns,
+		uuid.New(), name, tenant, models.CampaignStatusDraft, channel, request.Provider, request.Type,
+		request.Content, request.MediaURL, request.MediaType, request.Template, request.Variables,
+		request.Category, request.Variants, request.RatePerMinute, request.SegmentID, len(recipients), createdBy,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store campaign: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO campaign_recipients (campaign_id, position, recipient)
+		SELECT $1, position, recipient FROM unnest($2::text[]) WITH ORDINALITY AS audience(recipient, position)`,
+		campaign.ID, recipients); err != nil {
+		return nil, fmt.Errorf("failed to store campaign audience: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit campaign: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"audience":    campaign.AudienceSize,
+	}).Info("Campaign created")
+
+	return campaign, nil
+}
+
+// Get returns a campaign by ID
+func (s *CampaignService) Get(ctx context.Context, id uuid.UUID) (*models.Campaign, error) {
+	campaign, err := scanCampaign(s.db.QueryRow(ctx, `SELECT`+campaignColumns+` FROM campaigns WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to load campaign: %w", err)
+	}
+	return campaign, nil
+}
+
+// List returns campaigns, newest first, filtered by status
+func (s *CampaignService) List(ctx context.Context, status string, limit, offset int) ([]*models.Campaign, int64, error) {
+	query := `SELECT` + campaignColumns + ` FROM campaigns
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := []*models.Campaign{}
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading campaigns: %w", err)
+	}
+
+	return campaigns, estimateTotal(ctx, s.db, s.logger, query, status, limit, offset), nil
+}
+
+// Schedule schedules a draft campaign to start at scheduled_at, or at once
+func (s *CampaignService) Schedule(ctx context.Context, id uuid.UUID, request *models.ScheduleCampaignRequest) (*models.Campaign, error) {
+	if request.RatePerMinute != nil && *request.RatePerMinute <= 0 {
+		return nil, fmt.Errorf("%w: rate_per_minute must be positive", ErrInvalidCampaign)
+	}
+
+	return s.transition(ctx, id, models.CampaignStatusScheduled, `
+		UPDATE campaigns
+		SET status = 'scheduled', scheduled_at = COALESCE($2, NOW()),
+			rate_per_minute = COALESCE($3, rate_per_minute), updated_at = NOW()
+		WHERE id = $1 AND status = 'draft'
+		RETURNING`+campaignColumns, id, request.ScheduledAt, request.RatePerMinute)
+}
+
+// Pause stops a scheduled or running campaign from sending further messages. Messages
+// already being sent still go out.
+func (s *CampaignService) Pause(ctx context.Context, id uuid.UUID) (*models.Campaign, error) {
+	return s.transition(ctx, id, models.CampaignStatusPaused, `
+		UPDATE campaigns
+		SET status = 'paused', paused_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status IN ('scheduled', 'running')
+		RETURNING`+campaignColumns, id)
+}
+
+// Resume schedules a paused campaign again; it continues with the recipients it has not
+// sent to once its scheduled_at has passed
+func (s *CampaignService) Resume(ctx context.Context, id uuid.UUID) (*models.Campaign, error) {
+	return s.transition(ctx, id, models.CampaignStatusScheduled, `
+		UPDATE campaigns
+		SET status = 'scheduled', paused_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'paused'
+		RETURNING`+campaignColumns, id)
+}
+
+// Report computes the delivery and engagement funnel of a campaign
+func (s *CampaignService) Report(ctx context.Context, id uuid.UUID) (*models.CampaignReport, error) {
+	campaign, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.CampaignReport{CampaignID: campaign.ID, Status: campaign.Status}
+
+	// Replies are matched on the number the message went to, in either of the forms an
+	// inbound WhatsApp sender is stored in
+	err = s.db.QueryRow(ctx, `
+		SELECT
+			COUNT(*),
+			COUNT(*) FILTER (WHERE r.status IN ('pending', 'sending')),
+			COUNT(*) FILTER (WHERE r.status = 'pending' AND r.send_after > NOW()),
+			COUNT(*) FILTER (WHERE r.status = 'skipped'),
+			COUNT(*) FILTER (WHERE r.status = 'capped'),
+			COUNT(*) FILTER (WHERE r.status = 'failed' OR m.status = 'failed'),
+			COUNT(*) FILTER (WHERE r.status = 'sent'),
+			COUNT(*) FILTER (WHERE m.status IN ('delivered', 'read')),
+			COUNT(*) FILTER (WHERE m.status = 'read'),
+			COUNT(*) FILTER (WHERE r.status = 'sent' AND EXISTS (
+				SELECT 1 FROM whatsapp_messages reply
+				WHERE reply.direction = 'inbound' AND reply.channel = $2
+					AND reply.from_number IN (r.recipient, 'whatsapp:' || r.recipient)
+					AND reply.timestamp > r.sent_at AND reply.timestamp <= r.sent_at + $3 * INTERVAL '1 second'
+			)),
+			COUNT(*) FILTER (WHERE r.message_id IS NOT NULL AND EXISTS (
+				SELECT 1 FROM tracked_links l
+				JOIN link_clicks c ON c.link_id = l.id
+				WHERE l.message_id = r.message_id
+			))
+		FROM campaign_recipients r
+		LEFT JOIN whatsapp_messages m ON m.id = r.message_id
+		WHERE r.campaign_id = $1`,
+		campaign.ID, campaign.Channel, s.config.CampaignReplyWindow.Seconds(),
+	).Scan(
+		&report.Audience, &report.Pending, &report.Deferred, &report.Skipped, &report.Capped, &report.Failed, &report.Sent,
+		&report.Delivered, &report.Read, &report.Replied, &report.Clicked,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute campaign report: %w", err)
+	}
+
+	if report.Sent > 0 {
+		report.DeliveryRate = float64(report.Delivered) / float64(report.Sent)
+	}
+	if report.Delivered > 0 {
+		report.ReadRate = float64(report.Read) / float64(report.Delivered)
+		report.ReplyRate = float64(report.Replied) / float64(report.Delivered)
+		report.ClickRate = float64(report.Clicked) / float64(report.Delivered)
+	}
+
+	if len(campaign.Variants) > 0 {
+		if err := s.reportVariants(ctx, campaign, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// Start runs due campaigns every CAMPAIGN_POLL_INTERVAL until ctx is canceled
+func (s *CampaignService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CampaignPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunDue(ctx); err != nil {
+				s.logger.WithError(err).Error("Campaign run failed")
+			}
+		}
+	}
+}
+
+// RunDue starts campaigns whose scheduled_at has passed, sends the next recipients of
+// every running campaign within its throttle and completes the campaigns with nobody
+// left to send to
+func (s *CampaignService) RunDue(ctx context.Context) error {
+	if s.sender == nil {
+		return nil
+	}
+
+	if err := s.launchSegments(ctx); err != nil {
+		return err
+	}
+
+	started, err := s.updateStatuses(ctx, `
+		UPDATE campaigns
+		SET status = 'running', started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		WHERE status = 'scheduled' AND scheduled_at <= NOW()
+			AND (segment_id IS NULL OR started_at IS NOT NULL)
+		RETURNING`+campaignColumns)
+	if err != nil {
+		return err
+	}
+	for _, campaign := range started {
+		s.planQuietHours(ctx, campaign)
+		s.publish(ctx, campaign)
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		UPDATE campaign_recipients
+		SET status = 'failed', error = 'interrupted while sending', updated_at = NOW()
+		WHERE status = 'sending' AND updated_at < $1`,
+		time.Now().Add(-campaignSendingTimeout)); err != nil {
+		return fmt.Errorf("failed to release interrupted campaign sends: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `SELECT id FROM campaigns WHERE status = 'running' ORDER BY started_at`)
+	if err != nil {
+		return fmt.Errorf("failed to query running campaigns: %w", err)
+	}
+	var running []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan running campaign: %w", err)
+		}
+		running = append(running, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading running campaigns: %w", err)
+	}
+
+	for _, id := range running {
+		if err := s.dispatch(ctx, id); err != nil {
+			s.logger.WithError(err).WithField("campaign_id", id).Error("Failed to send campaign messages")
+		}
+	}
+
+	completed, err := s.updateStatuses(ctx, `
+		UPDATE campaigns c
+		SET status = 'completed', completed_at = NOW(), updated_at = NOW()
+		WHERE status = 'running' AND NOT EXISTS (
+			SELECT 1 FROM campaign_recipients r
+			WHERE r.campaign_id = c.id AND r.status IN ('pending', 'sending')
+		)
+		RETURNING`+campaignColumns)
+	if err != nil {
+		return err
+	}
+	for _, campaign := range completed {
+		s.logger.WithField("campaign_id", campaign.ID).Info("Campaign completed")
+		s.publish(ctx, campaign)
+	}
+
+	return nil
+}
+
+// Helper methods
+
+// transition applies a conditional status update and publishes the new state. When no
+// row matches, the campaign is either unknown or not in a state the update applies to.
+func (s *CampaignService) transition(ctx context.Context, id uuid.UUID, to models.CampaignStatus, query string, args ...interface{}) (*models.Campaign, error) {
+	campaign, err := scanCampaign(s.db.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		current, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: campaign is %s", ErrCampaignTransition, current.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to move campaign to %s: %w", to, err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"status":      campaign.Status,
+	}).Info("Campaign status changed")
+	s.publish(ctx, campaign)
+
+	return campaign, nil
+}
+
+// updateStatuses runs a status update over several campaigns and returns them
+func (s *CampaignService) updateStatuses(ctx context.Context, query string) ([]*models.Campaign, error) {
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var campaigns []*models.Campaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// Recipients returns the recipients of a campaign in audience order, filtered by status,
+// with the time each deferred one is scheduled for
+func (s *CampaignService) Recipients(ctx context.Context, id uuid.UUID, status string, limit, offset int) ([]*models.CampaignRecipient, int64, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT` + campaignRecipientColumns + ` FROM campaign_recipients
+		WHERE campaign_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY position
+		LIMIT $3 OFFSET $4`
+
+	rows, err := s.db.Query(ctx, query, id, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query campaign recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []*models.CampaignRecipient{}
+	for rows.Next() {
+		recipient, err := scanCampaignRecipient(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan campaign recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading campaign recipients: %w", err)
+	}
+
+	return recipients, estimateTotal(ctx, s.db, s.logger, query, id, status, limit, offset), nil
+}
+
+// reportVariants adds the funnel of each variant of a campaign to its report, and the
+// winner when one is ahead of the others with confidence
+func (s *CampaignService) reportVariants(ctx context.Context, campaign *models.Campaign, report *models.CampaignReport) error {
+	rows, err := s.db.Query(ctx, `
+		WITH funnel AS (
+			SELECT
+				r.variant,
+				r.status = 'sent' AS sent,
+				m.status IN ('delivered', 'read') AS delivered,
+				m.status = 'read' AS read,
+				r.status = 'sent' AND EXISTS (
+					SELECT 1 FROM whatsapp_messages reply
+					WHERE reply.direction = 'inbound' AND reply.channel = $2
+						AND reply.from_number IN (r.recipient, 'whatsapp:' || r.recipient)
+						AND reply.timestamp > r.sent_at AND reply.timestamp <= r.sent_at + $3 * INTERVAL '1 second'
+				) AS replied,
+				r.message_id IS NOT NULL AND EXISTS (
+					SELECT 1 FROM tracked_links l
+					JOIN link_clicks c ON c.link_id = l.id
+					WHERE l.message_id = r.message_id
+				) AS clicked
+			FROM campaign_recipients r
+			LEFT JOIN whatsapp_messages m ON m.id = r.message_id
+			WHERE r.campaign_id = $1 AND r.variant IS NOT NULL
+		)
+		SELECT
+			variant,
+			COUNT(*) FILTER (WHERE sent),
+			COUNT(*) FILTER (WHERE delivered),
+			COUNT(*) FILTER (WHERE read),
+			COUNT(*) FILTER (WHERE replied),
+			COUNT(*) FILTER (WHERE clicked),
+			COUNT(*) FILTER (WHERE delivered AND (replied OR clicked))
+		FROM funnel
+		GROUP BY variant`,
+		campaign.ID, campaign.Channel, s.config.CampaignReplyWindow.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to compute campaign variant report: %w", err)
+	}
+	defer rows.Close()
+
+	variants := make(map[string]*models.CampaignVariantReport, len(campaign.Variants))
+	for _, variant := range campaign.Variants {
+		variants[variant.Name] = &models.CampaignVariantReport{Name: variant.Name, Weight: variant.Weight}
+	}
+	for rows.Next() {
+		var name string
+		var counts models.CampaignVariantReport
+		if err := rows.Scan(&name, &counts.Sent, &counts.Delivered, &counts.Read, &counts.Replied, &counts.Clicked, &counts.Engaged); err != nil {
+			return fmt.Errorf("failed to scan campaign variant report: %w", err)
+		}
+		if variant, ok := variants[name]; ok {
+			counts.Name, counts.Weight = variant.Name, variant.Weight
+			*variant = counts
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading campaign variant report: %w", err)
+	}
+
+	for _, variant := range campaign.Variants {
+		result := variants[variant.Name]
+		if result.Delivered > 0 {
+			result.EngagementRate = float64(result.Engaged) / float64(result.Delivered)
+			result.ConfidenceLow, result.ConfidenceHigh = wilsonInterval(result.Engaged, result.Delivered)
+		}
+		report.Variants = append(report.Variants, result)
+	}
+	report.Winner = variantWinner(report.Variants)
+
+	return nil
+}
+
+// launchSegments starts the due campaigns to a segment that have not launched yet,
+// snapshotting the segment's members into their recipients first
+func (s *CampaignService) launchSegments(ctx context.Context) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT id FROM campaigns
+		WHERE status = 'scheduled' AND scheduled_at <= NOW()
+			AND segment_id IS NOT NULL AND started_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to query campaigns to launch: %w", err)
+	}
+	var due []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan campaign to launch: %w", err)
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading campaigns to launch: %w", err)
+	}
+
+	for _, id := range due {
+		if err := s.launch(ctx, id); err != nil {
+			s.logger.WithError(err).WithField("campaign_id", id).Error("Failed to launch campaign")
+		}
+	}
+	return nil
+}
+
+// launch snapshots the segment of a due campaign into its recipients and starts it.
+// Members beyond CAMPAIGN_MAX_RECIPIENTS are left out.
+func (s *CampaignService) launch(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin campaign launch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	campaign, err := scanCampaign(tx.QueryRow(ctx, `
+		SELECT`+campaignColumns+` FROM campaigns
+		WHERE id = $1 AND status = 'scheduled' AND segment_id IS NOT NULL AND started_at IS NULL
+		FOR UPDATE SKIP LOCKED`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Paused meanwhile, or another replica is launching it
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock campaign: %w", err)
+	}
+
+	audience, err := s.segmentService.Materialize(ctx, tx, campaign, *campaign.SegmentID, s.config.CampaignMaxRecipients)
+	if err != nil {
+		return err
+	}
+
+	campaign, err = scanCampaign(tx.QueryRow(ctx, `
+		UPDATE campaigns
+		SET status = 'running', audience_size = $2, started_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING`+campaignColumns, id, audience))
+	if err != nil {
+		return fmt.Errorf("failed to start campaign: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit campaign launch: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"campaign_id": campaign.ID,
+		"segment_id":  *campaign.SegmentID,
+		"audience":    audience,
+	}).Info("Campaign launched")
+	s.planQuietHours(ctx, campaign)
+	s.publish(ctx, campaign)
+
+	return nil
+}
+
+// planQuietHours resolves the time zone of each recipient a campaign has yet to send to
+// and defers those whose local time is in the quiet hours until they end. Recipients
+// the throttle only reaches later are checked again when they are sent.
+func (s *CampaignService) planQuietHours(ctx context.Context, campaign *models.Campaign) {
+	if s.quietHours == nil {
+		return
+	}
+
+	kind, _ := models.IdentityKindForChannel(campaign.Channel)
+	rows, err := s.db.Query(ctx, `
+		SELECT r.position, r.recipient, COALESCE(u.timezone, '')
+		FROM campaign_recipients r
+		LEFT JOIN LATERAL (
+			SELECT u.timezone FROM whatsapp_users u
+			WHERE u.timezone IS NOT NULL AND u.merged_into IS NULL AND (
+				u.phone_number = r.recipient
+				OR u.id IN (SELECT i.user_id FROM user_identities i WHERE i.kind = $2 AND i.value = r.recipient)
+			)
+			LIMIT 1
+		) u ON TRUE
+		WHERE r.campaign_id = $1 AND r.status = 'pending'`, campaign.ID, kind)
+	if err != nil {
+		s.logger.WithError(err).WithField("campaign_id", campaign.ID).Error("Failed to load campaign recipients for quiet hours")
+		return
+	}
+
+	now := time.Now()
+	var positions []int32
+	var zones []string
+	var sendAfter []*time.Time
+	for rows.Next() {
+		var position int32
+		var recipient, timezone string
+		if err := rows.Scan(&position, &recipient, &timezone); err != nil {
+			rows.Close()
+			s.logger.WithError(err).WithField("campaign_id", campaign.ID).Error("Failed to scan campaign recipient")
+			return
+		}
+
+		location := s.quietHours.location(timezone, recipient)
+		var after *time.Time
+		if resume, quiet := s.quietHours.resume(now, location); quiet {
+			after = &resume
+		}
+		positions = append(positions, position)
+		zones = append(zones, location.String())
+		sendAfter = append(sendAfter, after)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		s.logger.WithError(err).WithField("campaign_id", campaign.ID).Error("Failed to read campaign recipients")
+		return
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		UPDATE campaign_recipients r
+		SET timezone = plan.timezone, send_after = plan.send_after
+		FROM unnest($2::int[], $3::text[], $4::timestamptz[]) AS plan(position, timezone, send_after)
+		WHERE r.campaign_id = $1 AND r.position = plan.position`,
+		campaign.ID, positions, zones, sendAfter); err != nil {
+		s.logger.WithError(err).WithField("campaign_id", campaign.ID).Error("Failed to schedule campaign recipients around quiet hours")
+	}
+}
+
+// dispatch claims the recipients a running campaign may be sent to now and sends them.
+// The campaign row is locked while claiming, so one replica at a time spends the
+// throttle, which accrues from the last dispatch for at most a minute.
+func (s *CampaignService) dispatch(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin campaign dispatch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var dispatchedAt *time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT dispatched_at FROM campaigns
+		WHERE id = $1 AND status = 'running'
+		FOR UPDATE SKIP LOCKED`, id).Scan(&dispatchedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Paused meanwhile, or another replica is dispatching it
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock campaign: %w", err)
+	}
+	campaign, err := scanCampaign(tx.QueryRow(ctx, `SELECT`+campaignColumns+` FROM campaigns WHERE id = $1`, id))
+	if err != nil {
+		return fmt.Errorf("failed to load campaign: %w", err)
+	}
+
+	rate := s.config.CampaignRatePerMinute
+	if campaign.RatePerMinute != nil {
+		rate = *campaign.RatePerMinute
+	}
+	elapsed := s.config.CampaignPollInterval
+	if dispatchedAt != nil {
+		elapsed = time.Since(*dispatchedAt)
+	}
+	quota := int(float64(rate) * math.Min(elapsed.Minutes(), 1))
+	if quota < 1 {
+		// Let the allowance build up until a whole message fits
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE campaign_recipients
+		SET status = 'sending', updated_at = NOW()
+		WHERE campaign_id = $1 AND position IN (
+			SELECT position FROM campaign_recipients
+			WHERE campaign_id = $1 AND status = 'pending' AND (send_after IS NULL OR send_after <= NOW())
+			ORDER BY position
+			LIMIT $2
+		)
+		RETURNING recipient, COALESCE(timezone, '')`, campaign.ID, quota)
+	if err != nil {
+		return fmt.Errorf("failed to claim campaign recipients: %w", err)
+	}
+	var recipients, timezones []string
+	for rows.Next() {
+		var recipient, timezone string
+		if err := rows.Scan(&recipient, &timezone); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan campaign recipient: %w", err)
+		}
+		recipients = append(recipients, recipient)
+		timezones = append(timezones, timezone)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading campaign recipients: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE campaigns SET dispatched_at = NOW() WHERE id = $1`, campaign.ID); err != nil {
+		return fmt.Errorf("failed to record campaign dispatch: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit campaign dispatch: %w", err)
+	}
+
+	for i, recipient := range recipients {
+		if s.deferQuiet(ctx, campaign, recipient, timezones[i]) {
+			continue
+		}
+		s.send(ctx, campaign, recipient)
+	}
+	return nil
+}
+
+// deferQuiet puts a claimed recipient whose local time is in the quiet hours back as
+// pending until they end, reporting whether it did
+func (s *CampaignService) deferQuiet(ctx context.Context, campaign *models.Campaign, recipient, timezone string) bool {
+	if s.quietHours == nil {
+		return false
+	}
+
+	location := s.quietHours.location(timezone, recipient)
+	resume, quiet := s.quietHours.resume(time.Now(), location)
+	if !quiet {
+		return false
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		UPDATE campaign_recipients
+		SET status = 'pending', timezone = $3, send_after = $4, updated_at = NOW()
+		WHERE campaign_id = $1 AND recipient = $2`,
+		campaign.ID, recipient, location.String(), resume); err != nil {
+		// Left claimed, the recipient is failed as interrupted rather than sent in its night
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"campaign_id": campaign.ID,
+			"to":          recipient,
+		}).Error("Failed to defer campaign recipient past quiet hours")
+	}
+	return true
+}
+
+// send delivers the campaign message to one claimed recipient and records the outcome.
+// Recipients over their frequency cap are not sent to.
+func (s *CampaignService) send(ctx context.Context, campaign *models.Campaign, recipient string) {
+	status := models.CampaignRecipientSent
+	var messageID *uuid.UUID
+	var failure *string
+
+	variant := assignVariant(campaign, recipient)
+	var variantName *string
+	if variant != nil {
+		variantName = &variant.Name
+	}
+
+	claim, err := s.frequencyCaps.Claim(ctx, campaign, recipient)
+	if err == nil {
+		var response *models.SendMessageResponse
+		response, err = s.sender(ctx, campaign.SendRequest(recipient, variant))
+		if err != nil {
+			s.frequencyCaps.Release(context.Background(), claim)
+		} else {
+			s.frequencyCaps.Commit(ctx, claim)
+			messageID = &response.ID
+		}
+	}
+
+	var capped *FrequencyCapError
+	switch {
+	case errors.As(err, &capped):
+		status = models.CampaignRecipientCapped
+	case errors.Is(err, ErrCampaignRecipientSkipped):
+		status = models.CampaignRecipientSkipped
+	case err != nil:
+		status = models.CampaignRecipientFailed
+	}
+	if err != nil {
+		reason := err.Error()
+		failure = &reason
+	}
+	campaignSendsTotal.Inc(string(status))
+
+	if _, err := s.db.Exec(ctx, `
+		UPDATE campaign_recipients
+		SET status = $3, message_id = $4, error = $5, variant = $6,
+			sent_at = CASE WHEN $4::uuid IS NOT NULL THEN NOW() END,
+			updated_at = NOW()
+		WHERE campaign_id = $1 AND recipient = $2`,
+		campaign.ID, recipient, status, messageID, failure, variantName); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"campaign_id": campaign.ID,
+			"to":          recipient,
+		}).Error("Failed to record campaign send")
+	}
+}
+
+// publish announces the state a campaign entered
+func (s *CampaignService) publish(ctx context.Context, campaign *models.Campaign) {
+	s.eventService.Publish(ctx, &models.Event{
+		Type: models.EventCampaignUpdated,
+		Data: map[string]interface{}{
+			"campaign_id": campaign.ID,
+			"name":        campaign.Name,
+			"status":      campaign.Status,
+		},
+	})
+}
+
+// Helper functions
+
+// validateCampaignVariants checks that variants, when given, are at least two with
+// distinct names and positive weights
+func validateCampaignVariants(variants []models.CampaignVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > campaignMaxVariants {
+		return fmt.Errorf("%w: between 2 and %d variants are required to test them", ErrInvalidCampaign, campaignMaxVariants)
+	}
+
+	names := make(map[string]bool, len(variants))
+	for i := range variants {
+		variant := &variants[i]
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || len(variant.Name) > 100 {
+			return fmt.Errorf("%w: every variant needs a name of at most 100 characters", ErrInvalidCampaign)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("%w: variant %q is defined twice", ErrInvalidCampaign, variant.Name)
+		}
+		names[variant.Name] = true
+		if variant.Weight <= 0 {
+			return fmt.Errorf("%w: weight of variant %q must be positive", ErrInvalidCampaign, variant.Name)
+		}
+	}
+	return nil
+}
+
+// assignVariant picks the variant a recipient gets, nil when the campaign tests none.
+// The pick hashes the campaign and recipient, so a recipient always gets the same
+// variant and the split follows the weights.
+func assignVariant(campaign *models.Campaign, recipient string) *models.CampaignVariant {
+	if len(campaign.Variants) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, variant := range campaign.Variants {
+		total += variant.Weight
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(campaign.ID.String()))
+	hash.Write([]byte(recipient))
+	point := int(hash.Sum64() % uint64(total))
+
+	for i := range campaign.Variants {
+		point -= campaign.Variants[i].Weight
+		if point < 0 {
+			return &campaign.Variants[i]
+		}
+	}
+	return &campaign.Variants[len(campaign.Variants)-1]
+}
+
+// wilsonInterval returns the 95% Wilson score interval of successes out of trials
+func wilsonInterval(successes, trials int64) (float64, float64) {
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := campaignConfidenceZ * campaignConfidenceZ
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := campaignConfidenceZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// variantWinner names the variant with the highest engagement rate when its interval
+// lies above every other variant's, or returns "" while the test is inconclusive
+func variantWinner(variants []*models.CampaignVariantReport) string {
+	var best *models.CampaignVariantReport
+	for _, variant := range variants {
+		if variant.Delivered == 0 {
+			return ""
+		}
+		if best == nil || variant.EngagementRate > best.EngagementRate {
+			best = variant
+		}
+	}
+	if best == nil {
+		return ""
+	}
+
+	for _, variant := range variants {
+		if variant != best && best.ConfidenceLow <= variant.ConfidenceHigh {
+			return ""
+		}
+	}
+	return best.Name
+}
+
+// scanCampaignRecipient reads a recipient selected with campaignRecipientColumns
+func scanCampaignRecipient(row pgx.Row) (*models.CampaignRecipient, error) {
+	var recipient models.CampaignRecipient
+	err := row.Scan(
+		&recipient.CampaignID, &recipient.To, &recipient.Status, &recipient.Variant,
+		&recipient.Timezone, &recipient.ScheduledAt, &recipient.MessageID, &recipient.Error,
+		&recipient.SentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &recipient, nil
+}
+
+// scanCampaign reads a campaign selected with campaignColumns
+func scanCampaign(row pgx.Row) (*models.Campaign, error) {
+	var campaign models.Campaign
+	err := row.Scan(
+		&campaign.ID, &campaign.Name, &campaign.Tenant, &campaign.Status, &campaign.Channel,
+		&campaign.Provider, &campaign.Type, &campaign.Content, &campaign.MediaURL, &campaign.MediaType,
+		&campaign.Template, &campaign.Variables, &campaign.Category, &campaign.Variants,
+		&campaign.RatePerMinute, &campaign.SegmentID, &campaign.AudienceSize, &campaign.ScheduledAt,
+		&campaign.StartedAt, &campaign.PausedAt, &campaign.CompletedAt, &campaign.CreatedBy,
+		&campaign.CreatedAt, &campaign.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Canary metrics
+var (
+	canaryRunsTotal         = metrics.NewCounter("canary_runs_total", "Synthetic canary conversations by outcome", "outcome")
+	canaryStageSecondsTotal = metrics.NewCounter("canary_stage_seconds_total", "Total time from sending a canary message until each stage was reached", "stage")
+	canaryUp                = metrics.NewGauge("canary_up", "1 when the last canary conversation passed every stage, 0 otherwise")
+	canaryLastSuccess       = metrics.NewGauge("canary_last_success_timestamp_seconds", "Unix time of the last successful canary conversation")
+)
+
+// Canary outcomes
+const (
+	CanaryOutcomeOK             = "ok"
+	CanaryOutcomeSendFailed     = "send_failed"
+	CanaryOutcomeNotStored      = "not_stored"
+	CanaryOutcomeForwardFailed  = "forward_failed"
+	CanaryOutcomeForwardTimeout = "forward_timeout"
+	CanaryOutcomeError          = "error"
+)
+
+// Canary stages, measured from the send
+const (
+	canaryStageStored    = "stored"
+	canaryStageForwarded = "forwarded"
+)
+
+const (
+	// canaryLockKey makes one replica run each canary
+	canaryLockKey = "canary:lock"
+
+	// canaryPollInterval is how often a running canary checks for progress
+	canaryPollInterval = 2 * time.Second
+
+	// CanaryContentPrefix starts the text of canary messages, so the orchestrator and
+	// reports can recognize them
+	CanaryContentPrefix = "re9 canary "
+)
+
+// CanaryResult describes one canary conversation
+type CanaryResult struct {
+	Outcome   string
+	MessageID uuid.UUID
+	Stored    time.Duration // from send to storage; 0 when not reached
+	Forwarded time.Duration // from send to a successful orchestrator forward; 0 when not reached
+}
+
+// CanaryService checks the inbound pipeline end to end. On a schedule it sends a
+// WhatsApp message from a monitoring number to the production number (or the sandbox),
+// then waits until the webhook was received and stored and the orchestrator forward
+// succeeded, and records the outcome and stage latencies as metrics.
+type CanaryService struct {
+	db     *pgxpool.Pool
+	redis  *redis.Client
+	client *twilio.RestClient
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewCanaryService creates a new canary service
+func NewCanaryService(db *pgxpool.Pool, redisClient *redis.Client, twilioCalls *TwilioCallLog, cfg *config.Config, logger *logrus.Logger) *CanaryService {
+	return &CanaryService{
+		db:     db,
+		redis:  redisClient,
+		client: newTwilioClient(cfg.TwilioAccountSID, cfg.TwilioAuthToken, twilioCalls),
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Enabled reports whether canary conversations are scheduled
+func (s *CanaryService) Enabled() bool {
+	return s.config.CanaryInterval > 0 && s.config.CanaryFromNumber != ""
+}
+
+// Start runs a canary every canary interval until ctx is done. Replicas share the
+// schedule: each interval, the first one to claim it runs the canary.
+func (s *CanaryService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CanaryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		claimed, err := s.redis.SetNX(ctx, redisKey(s.config, canaryLockKey), time.Now().Unix(), s.config.CanaryInterval).Result()
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to claim canary run")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		s.Run(ctx)
+	}
+}
+
+// Run sends one canary message and follows it through the pipeline
+func (s *CanaryService) Run(ctx context.Context) *CanaryResult {
+	result, err := s.run(ctx)
+	if err != nil {
+		s.logger.WithError(err).Warn("Canary conversation failed")
+	}
+
+	canaryRunsTotal.Inc(result.Outcome)
+	if result.Stored > 0 {
+		canaryStageSecondsTotal.Add(result.Stored.Seconds(), canaryStageStored)
+	}
+	if result.Forwarded > 0 {
+		canaryStageSecondsTotal.Add(result.Forwarded.Seconds(), canaryStageForwarded)
+	}
+	if result.Outcome == CanaryOutcomeOK {
+		canaryUp.Set(1)
+		canaryLastSuccess.Set(float64(time.Now().Unix()))
+	} else {
+		canaryUp.Set(0)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"outcome":      result.Outcome,
+		"message_id":   result.MessageID,
+		"stored_ms":    result.Stored.Milliseconds(),
+		"forwarded_ms": result.Forwarded.Milliseconds(),
+	}).Info("Canary conversation finished")
+
+	return result
+}
+
+// run performs the stages of a canary conversation
+func (s *CanaryService) run(ctx context.Context) (*CanaryResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.CanaryTimeout)
+	defer cancel()
+
+	result := &CanaryResult{Outcome: CanaryOutcomeError}
+	from := canaryNumber(s.config.CanaryFromNumber)
+	to := s.config.TwilioWhatsAppFrom
+	if s.config.CanaryToNumber != "" {
+		to = canaryNumber(s.config.CanaryToNumber)
+	}
+	content := CanaryContentPrefix + uuid.New().String()
+
+	params := &twilioApi.CreateMessageParams{}
+	params.SetFrom(from)
+	params.SetTo(to)
+	params.SetBody(content)
+
+	sentAt := time.Now()
+	if _, err := s.client.Api.CreateMessage(params); err != nil {
+		result.Outcome = CanaryOutcomeSendFailed
+		return result, fmt.Errorf("failed to send canary message: %w", err)
+	}
+
+	// The webhook of the canary message lands on any replica; the database is where
+	// every replica's progress shows
+	var createdAt time.Time
+	err := s.poll(ctx, func() (bool, error) {
+		err := s.db.QueryRow(ctx, `
+			SELECT id, created_at FROM whatsapp_messages
+			WHERE direction = 'inbound' AND from_number = $1 AND content = $2 AND timestamp >= $3`,
+			from, content, sentAt.Add(-time.Minute),
+		).Scan(&result.MessageID, &createdAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Outcome = CanaryOutcomeNotStored
+			return result, errors.New("canary message was not received and stored in time")
+		}
+		return result, fmt.Errorf("failed to look up canary message: %w", err)
+	}
+	result.Stored = createdAt.Sub(sentAt)
+
+	var status string
+	var processedAt *time.Time
+	err = s.poll(ctx, func() (bool, error) {
+		err := s.db.QueryRow(ctx, `
+			SELECT status, processed_at FROM outbox
+			WHERE message_id = $1 AND kind = $2`,
+			result.MessageID, models.OutboxKindOrchestratorForward,
+		).Scan(&status, &processedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil && status != models.OutboxStatusPending, err
+	})
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Outcome = CanaryOutcomeForwardTimeout
+			return result, errors.New("canary message was not forwarded in time")
+		}
+		return result, fmt.Errorf("failed to look up canary forward: %w", err)
+	}
+	if status != models.OutboxStatusDone {
+		result.Outcome = CanaryOutcomeForwardFailed
+		return result, fmt.Errorf("canary forward ended as %s", status)
+	}
+	if processedAt != nil {
+		result.Forwarded = processedAt.Sub(sentAt)
+	}
+
+	result.Outcome = CanaryOutcomeOK
+	return result, nil
+}
+
+// poll calls check every poll interval until it reports done, fails or ctx is done
+func (s *CanaryService) poll(ctx context.Context, check func() (bool, error)) error {
+	ticker := time.NewTicker(canaryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// canaryNumber returns a number in the whatsapp: form Twilio and stored messages use
+func canaryNumber(number string) string {
+	if strings.HasPrefix(number, "whatsapp:") {
+		return number
+	}
+	return "whatsapp:" + normalizePhoneNumber(number)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+// roundTripFunc lets a function stand in for the Twilio API
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCanaryNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"+15550000001", "whatsapp:+15550000001"},
+		{"1 (555) 000-0001", "whatsapp:+15550000001"},
+		{"whatsapp:+15550000001", "whatsapp:+15550000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := canaryNumber(tt.in); got != tt.want {
+				t.Errorf("canaryNumber(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanaryEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want bool
+	}{
+		{"disabled by default", config.Config{}, false},
+		{"no from number", config.Config{CanaryInterval: time.Minute}, false},
+		{"no interval", config.Config{CanaryFromNumber: "+15550000001"}, false},
+		{"enabled", config.Config{CanaryInterval: time.Minute, CanaryFromNumber: "+15550000001"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &CanaryService{config: &tt.cfg}
+			if got := service.Enabled(); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanaryPoll(t *testing.T) {
+	service := &CanaryService{}
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name    string
+		check   func() (bool, error)
+		expired bool
+		want    error
+	}{
+		{"done", func() (bool, error) { return true, nil }, false, nil},
+		{"check fails", func() (bool, error) { return false, failure }, false, failure},
+		{"time runs out", func() (bool, error) { return false, nil }, true, context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if tt.expired {
+				ctx, cancel = context.WithTimeout(context.Background(), 0)
+			}
+			defer cancel()
+
+			if err := service.poll(ctx, tt.check); !errors.Is(err, tt.want) {
+				t.Errorf("poll() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanaryRunSendFailed(t *testing.T) {
+	var sent url.Values
+	previous := sharedTransport
+	sharedTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent, _ = url.ParseQuery(string(body))
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"code":21211,"message":"Invalid 'To' Phone Number","status":400}`)),
+			Request:    req,
+		}, nil
+	})
+	defer func() { sharedTransport = previous }()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{
+		TwilioAccountSID:   "AC00000000000000000000000000000000",
+		TwilioAuthToken:    "token",
+		TwilioWhatsAppFrom: "whatsapp:+15550000000",
+		CanaryFromNumber:   "+1 555 000 0001",
+		CanaryTimeout:      time.Minute,
+	}
+	service := NewCanaryService(nil, nil, nil, cfg, logger)
+
+	canaryUp.Set(1)
+	before := canaryRunsTotal.Value(CanaryOutcomeSendFailed)
+
+	result := service.Run(context.Background())
+
+	if result.Outcome != CanaryOutcomeSendFailed {
+		t.Errorf("Outcome = %q, want %q", result.Outcome, CanaryOutcomeSendFailed)
+	}
+	if sent.Get("From") != "whatsapp:+15550000001" || sent.Get("To") != "whatsapp:+15550000000" {
+		t.Errorf("sent From, To = %q, %q, want the canary number to the production number", sent.Get("From"), sent.Get("To"))
+	}
+	if !strings.HasPrefix(sent.Get("Body"), CanaryContentPrefix) {
+		t.Errorf("sent Body = %q, want the canary prefix", sent.Get("Body"))
+	}
+	if runs := canaryRunsTotal.Value(CanaryOutcomeSendFailed) - before; runs != 1 {
+		t.Errorf("counted %v send failures, want 1", runs)
+	}
+	if canaryUp.Value() != 0 {
+		t.Errorf("canary_up = %v, want 0 after a failed run", canaryUp.Value())
+	}
+}
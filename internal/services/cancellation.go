@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrMessageNotCancelable is returned for messages that were received, already sent, or
+// went through a provider that cannot recall them
+var ErrMessageNotCancelable = errors.New("message can no longer be canceled")
+
+// MessageCanceler is implemented by providers that can recall a message they have
+// accepted but not sent yet
+type MessageCanceler interface {
+	CancelMessage(ctx context.Context, messageSID string) error
+}
+
+// CancellationService cancels outbound messages that are still pending
+type CancellationService struct {
+	channels       *ChannelProviders
+	messageService *MessageService
+	eventService   *EventService
+	logger         *logrus.Logger
+}
+
+// NewCancellationService creates a new message cancellation service
+func NewCancellationService(channels *ChannelProviders, messageService *MessageService, eventService *EventService, logger *logrus.Logger) *CancellationService {
+	return &CancellationService{
+		channels:       channels,
+		messageService: messageService,
+		eventService:   eventService,
+		logger:         logger,
+	}
+}
+
+// Cancel recalls a pending outbound message from its provider, moves it to the canceled
+// status and publishes a message.canceled event
+func (s *CancellationService) Cancel(ctx context.Context, messageID string) (*models.WhatsAppMessage, error) {
+	message, err := s.messageService.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Direction != models.MessageDirectionOutbound || message.Status != models.MessageStatusPending {
+		return nil, ErrMessageNotCancelable
+	}
+
+	// Messages already handed to a provider must be recalled there first
+	if message.TwilioSID != "" {
+		provider, err := s.channels.For(message.Channel)
+		if err != nil {
+			return nil, ErrMessageNotCancelable
+		}
+		canceler, ok := provider.(MessageCanceler)
+		if !ok {
+			return nil, ErrMessageNotCancelable
+		}
+		if err := canceler.CancelMessage(ctx, message.TwilioSID); err != nil {
+			s.logger.WithError(err).WithField("message_id", message.ID).Warn("Provider refused to cancel message")
+			return nil, fmt.Errorf("%w: %v", ErrMessageNotCancelable, err)
+		}
+	}
+
+	canceled, err := s.messageService.MarkCanceled(ctx, message.ID)
+	if err != nil {
+		return nil, err
+	}
+	if canceled == nil {
+		// A status update moved the message on in the meantime
+		return nil, ErrMessageNotCancelable
+	}
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventMessageCanceled,
+		MessageID: &canceled.ID,
+		SessionID: canceled.SessionID,
+		Data: map[string]interface{}{
+			"channel":    canceled.Channel,
+			"twilio_sid": canceled.TwilioSID,
+			"status":     canceled.Status,
+		},
+	})
+
+	s.logger.WithField("message_id", canceled.ID).Info("Message canceled")
+
+	return canceled, nil
+}
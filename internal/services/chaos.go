@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// maxFaultLatency bounds injected latency so requests still finish within the server timeouts
+const maxFaultLatency = 20 * time.Second
+
+// Fault injection errors
+var (
+	ErrFaultInjectionUnavailable = errors.New("fault injection is not available in production")
+	ErrInvalidFaultConfig        = errors.New("invalid fault config")
+	ErrInjectedFault             = errors.New("injected fault")
+)
+
+// faultsInjectedTotal counts injected faults by kind
+var faultsInjectedTotal = metrics.NewCounter("chaos_faults_injected_total", "Faults injected for resilience testing", "kind")
+
+// FaultInjector injects latency, 5xx responses, dropped webhooks and provider errors so
+// failure handling can be exercised on purpose. It never injects in production and is
+// configured per instance through the admin API.
+type FaultInjector struct {
+	available bool
+	logger    *logrus.Logger
+
+	mu     sync.RWMutex
+	config models.FaultConfig
+}
+
+// NewFaultInjector creates a fault injector that starts disabled
+func NewFaultInjector(cfg *config.Config, logger *logrus.Logger) *FaultInjector {
+	return &FaultInjector{
+		available: cfg.Environment != "production",
+		logger:    logger,
+	}
+}
+
+// Config returns the current fault configuration
+func (f *FaultInjector) Config() models.FaultConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config
+}
+
+// Configure replaces the fault configuration
+func (f *FaultInjector) Configure(faults models.FaultConfig) error {
+	if !f.available {
+		return ErrFaultInjectionUnavailable
+	}
+
+	if faults.LatencyMs < 0 || time.Duration(faults.LatencyMs)*time.Millisecond > maxFaultLatency {
+		return fmt.Errorf("%w: latency_ms must be between 0 and %d", ErrInvalidFaultConfig, maxFaultLatency.Milliseconds())
+	}
+	for name, rate := range map[string]float64{
+		"latency_rate":        faults.LatencyRate,
+		"error_rate":          faults.ErrorRate,
+		"webhook_drop_rate":   faults.WebhookDropRate,
+		"provider_error_rate": faults.ProviderErrorRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%w: %s must be between 0 and 1", ErrInvalidFaultConfig, name)
+		}
+	}
+
+	f.mu.Lock()
+	f.config = faults
+	f.mu.Unlock()
+
+	f.logger.WithFields(logrus.Fields{
+		"enabled":             faults.Enabled,
+		"latency_ms":          faults.LatencyMs,
+		"latency_rate":        faults.LatencyRate,
+		"error_rate":          faults.ErrorRate,
+		"webhook_drop_rate":   faults.WebhookDropRate,
+		"provider_error_rate": faults.ProviderErrorRate,
+	}).Warn("Fault injection configured")
+
+	return nil
+}
+
+// InjectDelay returns the latency to add to a request, or zero
+func (f *FaultInjector) InjectDelay() time.Duration {
+	faults := f.Config()
+	if faults.LatencyMs == 0 || !f.roll(faults, faults.LatencyRate, "latency") {
+		return 0
+	}
+	return time.Duration(faults.LatencyMs) * time.Millisecond
+}
+
+// InjectError reports whether a request should fail with a 5xx response
+func (f *FaultInjector) InjectError() bool {
+	faults := f.Config()
+	return f.roll(faults, faults.ErrorRate, "error")
+}
+
+// InjectWebhookDrop reports whether a webhook should be acknowledged but not processed
+func (f *FaultInjector) InjectWebhookDrop() bool {
+	faults := f.Config()
+	return f.roll(faults, faults.WebhookDropRate, "webhook_drop")
+}
+
+// wrapProvider returns a provider whose sends fail when a provider error is injected
+// for this call, and the provider itself otherwise
+func (f *FaultInjector) wrapProvider(provider MessagingProvider) MessagingProvider {
+	faults := f.Config()
+	if !f.roll(faults, faults.ProviderErrorRate, "provider_error") {
+		return provider
+	}
+	return &faultyProvider{MessagingProvider: provider}
+}
+
+// roll decides whether to inject a fault of the given kind
+func (f *FaultInjector) roll(faults models.FaultConfig, rate float64, kind string) bool {
+	if !f.available || !faults.Enabled || rate <= 0 || rand.Float64() >= rate {
+		return false
+	}
+	faultsInjectedTotal.Inc(kind)
+	return true
+}
+
+// faultyProvider fails every provider call as if the provider API had returned an error
+type faultyProvider struct {
+	MessagingProvider
+}
+
+// SendTextMessage fails with an injected error
+func (p *faultyProvider) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("failed to send message: %w", ErrInjectedFault)
+}
+
+// SendMediaMessage fails with an injected error
+func (p *faultyProvider) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("failed to send media message: %w", ErrInjectedFault)
+}
+
+// SendTemplateMessage fails with an injected error
+func (p *faultyProvider) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("failed to send template message: %w", ErrInjectedFault)
+}
+
+// SendFlowMessage fails with an injected error
+func (p *faultyProvider) SendFlowMessage(ctx context.Context, to, flowSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("failed to send flow message: %w", ErrInjectedFault)
+}
+
+// SendLocationRequest fails with an injected error
+func (p *faultyProvider) SendLocationRequest(ctx context.Context, to, content, templateSID string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("failed to send location request: %w", ErrInjectedFault)
+}
+
+// TemplateVariables fails with an injected error
+func (p *faultyProvider) TemplateVariables(ctx context.Context, templateSID string) ([]string, error) {
+	return nil, fmt.Errorf("failed to fetch template: %w", ErrInjectedFault)
+}
+
+// CancelMessage fails with an injected error
+func (p *faultyProvider) CancelMessage(ctx context.Context, messageSID string) error {
+	return fmt.Errorf("failed to cancel message: %w", ErrInjectedFault)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// customerServiceWindow is how long after a user's last message free-form replies are allowed
+const customerServiceWindow = 24 * time.Hour
+
+// BuildChatContext assembles the orchestrator context for a message. Lookups that fail
+// are logged and left out, so a degraded context never blocks forwarding.
+func (s *SessionService) BuildChatContext(ctx context.Context, message *models.WhatsAppMessage) *models.ChatContext {
+	platform := string(message.Channel)
+	if platform == "" {
+		platform = string(models.ChannelWhatsApp)
+	}
+
+	chatContext := &models.ChatContext{
+		SchemaVersion:  models.ChatContextSchemaVersion,
+		Platform:       platform,
+		TwilioSID:      message.TwilioSID,
+		Direction:      message.Direction,
+		Locale:         s.config.DefaultLocale,
+		RecentMessages: []models.ChatContextMessage{},
+	}
+
+	if message.UserID != nil {
+		user, err := s.getUser(ctx, *message.UserID)
+		if err != nil {
+			s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to load user for chat context")
+		} else {
+			chatContext.User = &models.ChatContextUser{
+				ID:          user.ID,
+				PhoneNumber: user.PhoneNumber,
+				WhatsAppID:  user.WhatsAppID,
+				ProfileName: user.ProfileName,
+			}
+			if user.Locale != "" {
+				chatContext.Locale = user.Locale
+			}
+		}
+	}
+
+	var lastInbound time.Time
+	if message.Direction == models.MessageDirectionInbound {
+		lastInbound = message.Timestamp
+	}
+
+	if message.SessionID != nil {
+		session, err := s.storedSession(ctx, *message.SessionID)
+		if err != nil {
+			s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to load session for chat context")
+		} else {
+			chatContext.Session = &models.ChatContextSession{
+				ID:             session.ID,
+				Status:         session.Status,
+				State:          string(session.State),
+				Tags:           session.Tags,
+				StartedAt:      session.StartedAt,
+				LastActivityAt: session.LastActivityAt,
+			}
+		}
+
+		if s.config.ChatContextHistorySize > 0 {
+			recent, err := s.messageService.GetSessionHistory(ctx, *message.SessionID, message.ID, s.config.ChatContextHistorySize)
+			if err != nil {
+				s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to load recent messages for chat context")
+			}
+
+			for _, previous := range recent {
+				chatContext.RecentMessages = append(chatContext.RecentMessages, toChatContextMessage(previous))
+				if previous.Direction == models.MessageDirectionInbound && previous.Timestamp.After(lastInbound) {
+					lastInbound = previous.Timestamp
+				}
+			}
+		}
+	}
+
+	if !lastInbound.IsZero() {
+		expiresAt := lastInbound.Add(customerServiceWindow)
+		chatContext.Window = &models.ChatContextWindow{
+			Open:      time.Now().Before(expiresAt),
+			ExpiresAt: &expiresAt,
+		}
+	}
+
+	return chatContext
+}
+
+// CustomerServiceWindow reports whether free-form messages can be sent to a recipient,
+// based on their last inbound message on the channel
+func (s *SessionService) CustomerServiceWindow(ctx context.Context, channel models.Channel, recipient string) (*models.ChatContextWindow, error) {
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	// The store knows the window of users who wrote on the channel since their entry
+	// was created; for the others it falls back to the message history
+	kind, value := recipientAddress(channel, recipient)
+	if address, ok := s.store.address(ctx, kind, value); ok {
+		if entry, ok := s.store.user(ctx, address.UserID); ok {
+			if lastInbound, ok := entry.LastInbound[channel]; ok {
+				sessionStoreLookupsTotal.Inc("window", "hit")
+				expiresAt := lastInbound.Add(customerServiceWindow)
+				return &models.ChatContextWindow{
+					Open:      time.Now().Before(expiresAt),
+					ExpiresAt: &expiresAt,
+				}, nil
+			}
+		}
+	}
+	sessionStoreLookupsTotal.Inc("window", "miss")
+
+	userID, err := s.recipientUserID(ctx, channel, recipient)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return &models.ChatContextWindow{Open: false}, nil
+		}
+		return nil, err
+	}
+
+	var lastInbound *time.Time
+	err = s.db.QueryRow(ctx, `
+		SELECT MAX(timestamp) FROM whatsapp_messages
+		WHERE user_id = $1 AND channel = $2 AND direction = 'inbound'`,
+		userID, channel,
+	).Scan(&lastInbound)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find last inbound message: %w", err)
+	}
+
+	if lastInbound == nil {
+		return &models.ChatContextWindow{Open: false}, nil
+	}
+
+	expiresAt := lastInbound.Add(customerServiceWindow)
+	return &models.ChatContextWindow{
+		Open:      time.Now().Before(expiresAt),
+		ExpiresAt: &expiresAt,
+	}, nil
+}
+
+// toChatContextMessage converts a stored message, preferring the transcript for voice
+// notes. Translated replies are shown as written, so the whole history is in the
+// working language.
+func toChatContextMessage(message *models.WhatsAppMessage) models.ChatContextMessage {
+	content := message.Content
+	if message.Type == models.MessageTypeAudio && message.Transcript != nil && *message.Transcript != "" {
+		content = *message.Transcript
+	}
+	if message.Direction == models.MessageDirectionOutbound && message.OriginalContent != nil {
+		content = *message.OriginalContent
+	}
+
+	return models.ChatContextMessage{
+		ID:          message.ID,
+		Direction:   message.Direction,
+		MessageType: message.Type,
+		Content:     content,
+		MediaType:   message.MediaType,
+		Labels:      message.Labels,
+		Sentiment:   message.Sentiment,
+		Timestamp:   message.Timestamp,
+	}
+}
+
+// getUser retrieves a WhatsApp user by ID
+func (s *SessionService) getUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM whatsapp_users WHERE id = $1`
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestToChatContextMessage(t *testing.T) {
+	transcript := "I would like to visit on Friday"
+	emptyTranscript := ""
+	original := "Olá, como posso ajudar?"
+
+	tests := []struct {
+		name    string
+		message models.WhatsAppMessage
+		want    string
+	}{
+		{
+			name:    "text",
+			message: models.WhatsAppMessage{Direction: models.MessageDirectionInbound, Type: models.MessageTypeText, Content: "hi"},
+			want:    "hi",
+		},
+		{
+			name:    "voice note with transcript",
+			message: models.WhatsAppMessage{Direction: models.MessageDirectionInbound, Type: models.MessageTypeAudio, Transcript: &transcript},
+			want:    transcript,
+		},
+		{
+			name:    "voice note forwarded after its transcription timed out",
+			message: models.WhatsAppMessage{Direction: models.MessageDirectionInbound, Type: models.MessageTypeAudio, Content: "caption", Transcript: &emptyTranscript},
+			want:    "caption",
+		},
+		{
+			name:    "translated reply is shown as written",
+			message: models.WhatsAppMessage{Direction: models.MessageDirectionOutbound, Type: models.MessageTypeText, Content: "Hello, how can I help?", OriginalContent: &original},
+			want:    original,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toChatContextMessage(&tt.message).Content; got != tt.want {
+				t.Errorf("Content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
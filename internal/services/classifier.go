@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/signing"
+)
+
+// Classifier metrics for lead scoring analytics
+var (
+	messageLabelsTotal   = metrics.NewCounter("message_labels_total", "Classifier labels assigned to inbound messages", "label")
+	classificationsTotal = metrics.NewCounter("message_classifications_total", "Inbound message classifications by outcome", "outcome")
+)
+
+// ClassifyRequest is sent to the classifier for one inbound text message
+type ClassifyRequest struct {
+	MessageID string         `json:"message_id"`
+	Channel   models.Channel `json:"channel"`
+	Content   string         `json:"content"`
+	Locale    string         `json:"locale,omitempty"`
+}
+
+// ClassifyResponse lists the labels the classifier assigned; labels without a
+// confidence are always kept
+type ClassifyResponse struct {
+	Labels []struct {
+		Name       string   `json:"name"`
+		Confidence *float64 `json:"confidence,omitempty"`
+	} `json:"labels"`
+}
+
+// ClassifierService labels inbound text for lead scoring (e.g., "wants_valuation") by
+// calling an internal classifier. It runs beside the orchestrator flow and never
+// delays or blocks replies: classifications run in the background and are skipped
+// when too many are already in flight.
+type ClassifierService struct {
+	config         *config.Config
+	messageService *MessageService
+	eventService   *EventService
+	httpClient     *http.Client
+	signer         *signing.Signer
+	slots          chan struct{}
+	logger         *logrus.Logger
+}
+
+// NewClassifierService creates a new message classifier service
+func NewClassifierService(cfg *config.Config, messageService *MessageService, eventService *EventService, logger *logrus.Logger) *ClassifierService {
+	concurrency := cfg.ClassifierMaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	return &ClassifierService{
+		config:         cfg,
+		messageService: messageService,
+		eventService:   eventService,
+		httpClient:     newHTTPClient(cfg.ClassifierTimeout),
+		signer:         signing.NewSigner(cfg.SigningKeyID, cfg.ClassifierSigningSecret),
+		slots:          make(chan struct{}, concurrency),
+		logger:         logger,
+	}
+}
+
+// Enabled reports whether a classifier is configured
+func (s *ClassifierService) Enabled() bool {
+	return s.config.ClassifierURL != ""
+}
+
+// ClassifyAsync classifies an inbound text message in the background. The labels are
+// stored, not set on message, which the caller keeps using.
+func (s *ClassifierService) ClassifyAsync(message *models.WhatsAppMessage) {
+	if !s.Enabled() || message.Direction != models.MessageDirectionInbound || strings.TrimSpace(message.Content) == "" {
+		return
+	}
+	message = cloneForClassify(message)
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		classificationsTotal.Inc("skipped")
+		s.logger.WithField("message_id", message.ID).Warn("Classifier busy, message not classified")
+		return
+	}
+
+	go func() {
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.ClassifierTimeout)
+		defer cancel()
+
+		if _, err := s.Classify(ctx, message); err != nil {
+			classificationsTotal.Inc("failed")
+			s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to classify message")
+		}
+	}()
+}
+
+// Classify labels a message, stores the labels, sets them on message and publishes a
+// message.labeled event
+func (s *ClassifierService) Classify(ctx context.Context, message *models.WhatsAppMessage) ([]string, error) {
+	labels, err := s.requestLabels(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.messageService.SetLabels(ctx, message.ID, labels); err != nil {
+		return nil, err
+	}
+	message.Labels = labels
+
+	classificationsTotal.Inc("labeled")
+	for _, label := range labels {
+		messageLabelsTotal.Inc(label)
+	}
+
+	if len(labels) > 0 {
+		messageID := message.ID
+		s.eventService.Publish(ctx, &models.Event{
+			Type:      models.EventMessageLabeled,
+			MessageID: &messageID,
+			SessionID: message.SessionID,
+			Data: map[string]interface{}{
+				"labels":  labels,
+				"channel": message.Channel,
+				"user_id": message.UserID,
+			},
+		})
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"labels":     labels,
+	}).Info("Message classified")
+
+	return labels, nil
+}
+
+// cloneForClassify copies the fields Classify reads, so the background classification
+// shares nothing with the caller's message
+func cloneForClassify(message *models.WhatsAppMessage) *models.WhatsAppMessage {
+	clone := &models.WhatsAppMessage{
+		ID:      message.ID,
+		Channel: message.Channel,
+		Content: message.Content,
+	}
+	if message.SessionID != nil {
+		sessionID := *message.SessionID
+		clone.SessionID = &sessionID
+	}
+	if message.UserID != nil {
+		userID := *message.UserID
+		clone.UserID = &userID
+	}
+	return clone
+}
+
+// requestLabels calls the classifier and keeps labels above the confidence threshold
+func (s *ClassifierService) requestLabels(ctx context.Context, message *models.WhatsAppMessage) ([]string, error) {
+	body, err := json.Marshal(ClassifyRequest{
+		MessageID: message.ID.String(),
+		Channel:   message.Channel,
+		Content:   message.Content,
+		Locale:    s.config.DefaultLocale,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal classify request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.ClassifierURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create classify request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+
+	if err := s.signer.Sign(req, body); err != nil {
+		return nil, fmt.Errorf("failed to sign classify request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call classifier: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classifier returned status %d", resp.StatusCode)
+	}
+
+	var response ClassifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode classifier response: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	labels := []string{}
+	for _, label := range response.Labels {
+		name := strings.TrimSpace(label.Name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if label.Confidence != nil && *label.Confidence < s.config.ClassifierMinConfidence {
+			continue
+		}
+		seen[name] = true
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+
+	return labels, nil
+}
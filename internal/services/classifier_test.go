@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestCloneForClassify(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	message := &models.WhatsAppMessage{
+		ID:        uuid.New(),
+		Channel:   models.ChannelWhatsApp,
+		Content:   "I want to buy the blue one",
+		SessionID: &sessionID,
+		UserID:    &userID,
+		Labels:    []string{"existing"},
+	}
+
+	clone := cloneForClassify(message)
+
+	if clone.ID != message.ID || clone.Channel != message.Channel || clone.Content != message.Content {
+		t.Errorf("clone = %+v, want the ID, channel and content of the message", clone)
+	}
+	if clone.SessionID == message.SessionID || *clone.SessionID != sessionID {
+		t.Errorf("clone.SessionID = %v, want a copy of %v", clone.SessionID, sessionID)
+	}
+	if clone.UserID == message.UserID || *clone.UserID != userID {
+		t.Errorf("clone.UserID = %v, want a copy of %v", clone.UserID, userID)
+	}
+
+	clone.Labels = []string{"lead"}
+	if len(message.Labels) != 1 || message.Labels[0] != "existing" {
+		t.Errorf("labeling the clone changed the message: %v", message.Labels)
+	}
+
+	if withoutSession := cloneForClassify(&models.WhatsAppMessage{ID: uuid.New()}); withoutSession.SessionID != nil || withoutSession.UserID != nil {
+		t.Error("clone of a message without a session or user has one")
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// nextActionStates maps orchestrator next_action values to the conversation state they lead to
+var nextActionStates = map[string]models.ConversationState{
+	"request_document":     models.ConversationStateAwaitingDocument,
+	"request_confirmation": models.ConversationStateAwaitingConfirmation,
+	"handoff":              models.ConversationStateHandoff,
+	"complete":             models.ConversationStateIdle,
+	"reset":                models.ConversationStateIdle,
+}
+
+// cancelKeywords let a user leave a pending flow without the orchestrator's help
+var cancelKeywords = map[string]bool{
+	"cancelar": true,
+	"sair":     true,
+	"cancel":   true,
+}
+
+// SetState updates the conversation state of a session
+func (s *SessionService) SetState(ctx context.Context, sessionID uuid.UUID, state models.ConversationState) error {
+	query := `
+		UPDATE chat_sessions
+		SET state = $2, state_updated_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND state <> $2`
+
+	tag, err := s.db.Exec(ctx, query, sessionID, state)
+	if err != nil {
+		return fmt.Errorf("failed to update session state: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		s.store.Forget(ctx, sessionID)
+		s.logger.WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"state":      state,
+		}).Info("Conversation state changed")
+
+		if state == models.ConversationStateHandoff && s.agentService != nil {
+			if _, err := s.agentService.AutoAssign(ctx, sessionID); err != nil && !errors.Is(err, ErrNoAgentAvailable) {
+				s.logger.WithError(err).WithField("session_id", sessionID).Warn("Failed to assign handed-off conversation")
+			}
+		}
+	}
+
+	return nil
+}
+
+// UseAgents assigns conversations to an available agent when they are handed off
+func (s *SessionService) UseAgents(agentService *AgentService) {
+	s.agentService = agentService
+}
+
+// AddTags adds tags to a session, keeping existing ones
+func (s *SessionService) AddTags(ctx context.Context, sessionID uuid.UUID, tags []string) error {
+	query := `
+		UPDATE chat_sessions
+		SET tags = ARRAY(SELECT DISTINCT unnest(tags || $2::text[]) ORDER BY 1), updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, sessionID, tags); err != nil {
+		return fmt.Errorf("failed to tag session: %w", err)
+	}
+	s.store.Forget(ctx, sessionID)
+
+	return nil
+}
+
+// AddTagOnce tags a session unless it already has the tag, and reports whether it added it
+func (s *SessionService) AddTagOnce(ctx context.Context, sessionID uuid.UUID, tag string) (bool, error) {
+	query := `
+		UPDATE chat_sessions
+		SET tags = ARRAY(SELECT DISTINCT unnest(tags || ARRAY[$2::text]) ORDER BY 1), updated_at = NOW()
+		WHERE id = $1 AND NOT ($2 = ANY(tags))`
+
+	result, err := s.db.Exec(ctx, query, sessionID, tag)
+	if err != nil {
+		return false, fmt.Errorf("failed to tag session: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	s.store.Forget(ctx, sessionID)
+
+	return true, nil
+}
+
+// ApplyNextAction moves a session to the state implied by an orchestrator next_action.
+// Actions without a state mapping leave the state unchanged, and so does applying the
+// state a session is already in, so a replayed response can be applied again.
+func (s *SessionService) ApplyNextAction(ctx context.Context, sessionID uuid.UUID, nextAction string) error {
+	state, ok := nextActionStates[strings.ToLower(strings.TrimSpace(nextAction))]
+	if !ok {
+		return nil
+	}
+	return s.SetState(ctx, sessionID, state)
+}
+
+// EvaluateInbound decides whether an inbound message can be answered locally from the
+// session's conversation state. It returns the reply to send, if any, and true when the
+// message should not be forwarded to the orchestrator. A message completing the awaited
+// step, or a cancel keyword, moves the session back to idle.
+func (s *SessionService) EvaluateInbound(ctx context.Context, session *models.ChatSession, message *models.WhatsAppMessage) (*i18n.Message, bool) {
+	if !s.config.ConversationStateEnabled || session == nil {
+		return nil, false
+	}
+
+	reply, hold, next := inboundTransition(session.State, message)
+	if next != nil {
+		if err := s.SetState(ctx, session.ID, *next); err != nil {
+			s.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to update conversation state")
+		}
+	}
+	return reply, hold
+}
+
+// inboundTransition returns the local reply to an inbound message in a conversation
+// state, whether the message is held back from the orchestrator, and the state the
+// session moves to, or nil when it stays
+func inboundTransition(state models.ConversationState, message *models.WhatsAppMessage) (*i18n.Message, bool, *models.ConversationState) {
+	idle := models.ConversationStateIdle
+
+	switch state {
+	case models.ConversationStateAwaitingDocument:
+		switch message.Type {
+		case models.MessageTypeDocument, models.MessageTypeImage:
+			return nil, false, &idle
+		case models.MessageTypeText:
+			if cancelKeywords[strings.ToLower(strings.TrimSpace(message.Content))] {
+				return nil, false, &idle
+			}
+		}
+		return &i18n.Message{Key: i18n.KeyAwaitingDocument}, true, nil
+
+	case models.ConversationStateAwaitingConfirmation:
+		if message.Type == models.MessageTypeText {
+			return nil, false, &idle
+		}
+		return &i18n.Message{Key: i18n.KeyAwaitingConfirmation}, true, nil
+
+	case models.ConversationStateHandoff:
+		// A human agent answers; the orchestrator stays out of the conversation
+		return nil, true, nil
+	}
+
+	return nil, false, nil
+}
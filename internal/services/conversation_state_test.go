@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestInboundTransition(t *testing.T) {
+	idle := models.ConversationStateIdle
+
+	tests := []struct {
+		name      string
+		state     models.ConversationState
+		message   *models.WhatsAppMessage
+		wantReply string
+		wantHold  bool
+		wantNext  *models.ConversationState
+	}{
+		{"idle", models.ConversationStateIdle, &models.WhatsAppMessage{Type: models.MessageTypeText, Content: "oi"}, "", false, nil},
+		{"document received", models.ConversationStateAwaitingDocument, &models.WhatsAppMessage{Type: models.MessageTypeDocument}, "", false, &idle},
+		{"photo received", models.ConversationStateAwaitingDocument, &models.WhatsAppMessage{Type: models.MessageTypeImage}, "", false, &idle},
+		{"cancel keyword", models.ConversationStateAwaitingDocument, &models.WhatsAppMessage{Type: models.MessageTypeText, Content: " Cancelar "}, "", false, &idle},
+		{"text while awaiting a document", models.ConversationStateAwaitingDocument, &models.WhatsAppMessage{Type: models.MessageTypeText, Content: "what now?"}, i18n.KeyAwaitingDocument, true, nil},
+		{"confirmation answered", models.ConversationStateAwaitingConfirmation, &models.WhatsAppMessage{Type: models.MessageTypeText, Content: "sim"}, "", false, &idle},
+		{"media while awaiting a confirmation", models.ConversationStateAwaitingConfirmation, &models.WhatsAppMessage{Type: models.MessageTypeImage}, i18n.KeyAwaitingConfirmation, true, nil},
+		{"handoff", models.ConversationStateHandoff, &models.WhatsAppMessage{Type: models.MessageTypeText, Content: "hello?"}, "", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, hold, next := inboundTransition(tt.state, tt.message)
+
+			replyKey := ""
+			if reply != nil {
+				replyKey = reply.Key
+			}
+			if replyKey != tt.wantReply || hold != tt.wantHold {
+				t.Errorf("inboundTransition() = %q, %v, want %q, %v", replyKey, hold, tt.wantReply, tt.wantHold)
+			}
+			if (next == nil) != (tt.wantNext == nil) || (next != nil && *next != *tt.wantNext) {
+				t.Errorf("inboundTransition() next state = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
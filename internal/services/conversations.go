@@ -0,0 +1,278 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"github.com/twilio/twilio-go"
+	conversations "github.com/twilio/twilio-go/rest/conversations/v1"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// conversationsMediaURL is the Twilio Media Content Service endpoint for conversation media
+const conversationsMediaURL = "https://mcs.us1.twilio.com/v1/Services/%s/Media"
+
+// maxConversationsMediaBytes matches the Twilio Conversations attachment limit
+const maxConversationsMediaBytes = 16 * 1024 * 1024
+
+// ConversationsService sends WhatsApp messages through Twilio Conversations, keeping
+// one conversation per recipient for threading and per-participant delivery receipts
+type ConversationsService struct {
+	client     *twilio.RestClient
+	db         *pgxpool.Pool
+	httpClient *http.Client
+	config     *config.Config
+	logger     *logrus.Logger
+	serviceSID string
+	fromNumber string
+}
+
+// NewConversationsService creates a new Twilio Conversations provider
+func NewConversationsService(cfg *config.Config, db *pgxpool.Pool, twilioCalls *TwilioCallLog, logger *logrus.Logger) (*ConversationsService, error) {
+	if cfg.TwilioConversationsServiceSID == "" {
+		return nil, fmt.Errorf("TWILIO_CONVERSATIONS_SERVICE_SID is required for the conversations transport")
+	}
+
+	client := newTwilioClient(cfg.TwilioAccountSID, cfg.TwilioAuthToken, twilioCalls)
+
+	return &ConversationsService{
+		client:     client,
+		db:         db,
+		httpClient: newHTTPClient(60 * time.Second),
+		config:     cfg,
+		logger:     logger,
+		serviceSID: cfg.TwilioConversationsServiceSID,
+		fromNumber: cfg.TwilioWhatsAppFrom,
+	}, nil
+}
+
+// Name identifies the provider
+func (s *ConversationsService) Name() string {
+	return "twilio_conversations"
+}
+
+// GetFromNumber returns the configured WhatsApp from number
+func (s *ConversationsService) GetFromNumber() string {
+	return s.fromNumber
+}
+
+// SendTextMessage posts a text message to the recipient's conversation
+func (s *ConversationsService) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	params := &conversations.CreateServiceConversationMessageParams{}
+	params.SetBody(content)
+
+	return s.send(ctx, to, params)
+}
+
+// SendMediaMessage uploads media to the Media Content Service and posts it to the recipient's conversation
+func (s *ConversationsService) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	mediaSID, err := s.uploadMedia(ctx, mediaURL, mediaType)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to upload media to Twilio Conversations")
+		return nil, err
+	}
+
+	params := &conversations.CreateServiceConversationMessageParams{}
+	params.SetMediaSid(mediaSID)
+	if content != "" {
+		params.SetBody(content)
+	}
+
+	return s.send(ctx, to, params)
+}
+
+// SendTemplateMessage posts a content template message to the recipient's conversation
+func (s *ConversationsService) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	params := &conversations.CreateServiceConversationMessageParams{}
+	params.SetContentSid(templateSID)
+
+	if len(variables) > 0 {
+		contentVariables, err := json.Marshal(variables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode template variables: %w", err)
+		}
+		params.SetContentVariables(string(contentVariables))
+	}
+
+	return s.send(ctx, to, params)
+}
+
+// SendFlowMessage posts a WhatsApp Flow, which Twilio sends as a content template
+func (s *ConversationsService) SendFlowMessage(ctx context.Context, to, flowSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return s.SendTemplateMessage(ctx, to, flowSID, variables)
+}
+
+// SendLocationRequest posts a content template that asks the user to share their
+// location, LOCATION_REQUEST_TEMPLATE_SID unless templateSID is given
+func (s *ConversationsService) SendLocationRequest(ctx context.Context, to, content, templateSID string) (*models.SendMessageResponse, error) {
+	if templateSID == "" {
+		templateSID = s.config.LocationRequestTemplateSID
+	}
+	if templateSID == "" {
+		return nil, ErrLocationRequestTemplate
+	}
+	return s.SendTemplateMessage(ctx, to, templateSID, map[string]string{"1": content})
+}
+
+// TemplateVariables returns the variable names a content template expects
+func (s *ConversationsService) TemplateVariables(ctx context.Context, templateSID string) ([]string, error) {
+	return fetchTemplateVariables(s.client, templateSID)
+}
+
+// send posts a message to the recipient's conversation, creating it on first contact
+func (s *ConversationsService) send(ctx context.Context, to string, params *conversations.CreateServiceConversationMessageParams) (*models.SendMessageResponse, error) {
+	conversationSID, err := s.conversationFor(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.ConversationsV1.CreateServiceConversationMessage(s.serviceSID, conversationSID, params)
+	if err != nil {
+		s.logger.WithError(err).WithField("conversation_sid", conversationSID).Error("Failed to send conversation message")
+		return nil, fmt.Errorf("failed to send conversation message: %w", err)
+	}
+
+	response := &models.SendMessageResponse{
+		ID:        uuid.New(),
+		TwilioSID: *resp.Sid,
+		Status:    models.MessageStatusSent,
+		CreatedAt: time.Now(),
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"twilio_sid":       *resp.Sid,
+		"conversation_sid": conversationSID,
+	}).Info("WhatsApp message sent via Twilio Conversations")
+
+	return response, nil
+}
+
+// conversationFor returns the conversation of a recipient, creating it and adding the
+// recipient as a WhatsApp participant when none exists yet
+func (s *ConversationsService) conversationFor(ctx context.Context, to string) (string, error) {
+	phoneNumber := normalizePhoneNumber(to)
+
+	var conversationSID string
+	err := s.db.QueryRow(ctx,
+		`SELECT conversation_sid FROM twilio_conversations WHERE phone_number = $1`,
+		phoneNumber,
+	).Scan(&conversationSID)
+	if err == nil {
+		return conversationSID, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("failed to look up conversation: %w", err)
+	}
+
+	createParams := &conversations.CreateServiceConversationParams{}
+	createParams.SetFriendlyName("WhatsApp " + phoneNumber)
+
+	conversation, err := s.client.ConversationsV1.CreateServiceConversation(s.serviceSID, createParams)
+	if err != nil {
+		return "", fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	participantParams := &conversations.CreateServiceConversationParticipantParams{}
+	participantParams.SetMessagingBindingAddress("whatsapp:" + phoneNumber)
+	participantParams.SetMessagingBindingProxyAddress(s.fromNumber)
+
+	participant, err := s.client.ConversationsV1.CreateServiceConversationParticipant(s.serviceSID, *conversation.Sid, participantParams)
+	if err != nil {
+		return "", fmt.Errorf("failed to add conversation participant: %w", err)
+	}
+
+	// A concurrent send may have created a conversation first; keep whichever was stored
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO twilio_conversations (phone_number, conversation_sid, participant_sid, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (phone_number) DO UPDATE SET phone_number = EXCLUDED.phone_number
+		RETURNING conversation_sid`,
+		phoneNumber, *conversation.Sid, *participant.Sid,
+	).Scan(&conversationSID)
+	if err != nil {
+		return "", fmt.Errorf("failed to store conversation: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"phone_number":     phoneNumber,
+		"conversation_sid": conversationSID,
+	}).Info("Twilio conversation created")
+
+	return conversationSID, nil
+}
+
+// uploadMedia copies media from a URL into the Media Content Service and returns its SID
+func (s *ConversationsService) uploadMedia(ctx context.Context, mediaURL, mediaType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create media download request: %w", err)
+	}
+	if isTwilioURL(mediaURL) {
+		req.SetBasicAuth(s.config.TwilioAccountSID, s.config.TwilioAuthToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("media download returned status %d", resp.StatusCode)
+	}
+
+	media, err := io.ReadAll(io.LimitReader(resp.Body, maxConversationsMediaBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read media: %w", err)
+	}
+	if len(media) > maxConversationsMediaBytes {
+		return "", fmt.Errorf("media exceeds %d bytes", maxConversationsMediaBytes)
+	}
+
+	if mediaType == "" {
+		mediaType = resp.Header.Get("Content-Type")
+	}
+
+	upload, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(conversationsMediaURL, s.serviceSID), bytes.NewReader(media))
+	if err != nil {
+		return "", fmt.Errorf("failed to create media upload request: %w", err)
+	}
+	upload.SetBasicAuth(s.config.TwilioAccountSID, s.config.TwilioAuthToken)
+	upload.Header.Set("Content-Type", mediaType)
+
+	uploadResp, err := s.httpClient.Do(upload)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload media: %w", err)
+	}
+	defer uploadResp.Body.Close()
+
+	if uploadResp.StatusCode != http.StatusCreated && uploadResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("media upload returned status %d", uploadResp.StatusCode)
+	}
+
+	var uploaded struct {
+		Sid string `json:"sid"`
+	}
+	if err := json.NewDecoder(uploadResp.Body).Decode(&uploaded); err != nil {
+		return "", fmt.Errorf("failed to decode media upload response: %w", err)
+	}
+
+	return uploaded.Sid, nil
+}
+
+// conversationMediaContentURL returns the Media Content Service URL of a conversation attachment
+func conversationMediaContentURL(serviceSID, mediaSID string) string {
+	return fmt.Sprintf(conversationsMediaURL, serviceSID) + "/" + mediaSID + "/Content"
+}
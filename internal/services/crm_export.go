@@ -0,0 +1,499 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/signing"
+)
+
+// CRM export errors
+var (
+	ErrCRMExportNotFound     = errors.New("CRM export not found")
+	ErrCRMExportNotRetryable = errors.New("only failed CRM exports can be retried")
+)
+
+// crmExportsTotal counts CRM export delivery attempts by outcome
+var crmExportsTotal = metrics.NewCounter("crm_exports_total", "CRM conversation export attempts by outcome", "outcome")
+
+// crmExportBatchSize bounds how many due exports one retry pass claims
+const crmExportBatchSize = 50
+
+// crmExportMaxBackoff caps the delay between attempts
+const crmExportMaxBackoff = 6 * time.Hour
+
+// crmExportColumns lists the crm_exports columns in the order scanCRMExport expects
+const crmExportColumns = `
+	id, session_id, status, attempts, last_status_code, last_error, next_attempt_at,
+	delivered_at, payload, created_at, updated_at`
+
+// CRMExportService pushes a structured record of every closed conversation to the CRM.
+// Each export is stored with its payload before the first attempt, failed attempts are
+// retried with exponential backoff, and every attempt is kept as a delivery log.
+type CRMExportService struct {
+	db                 *pgxpool.Pool
+	messageService     *MessageService
+	suppressionService *SuppressionService
+	httpClient         *http.Client
+	signer             *signing.Signer
+	config             *config.Config
+	logger             *logrus.Logger
+}
+
+// NewCRMExportService creates a new CRM export service
+func NewCRMExportService(
+	db *pgxpool.Pool,
+	messageService *MessageService,
+	suppressionService *SuppressionService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *CRMExportService {
+	return &CRMExportService{
+		db:                 db,
+		messageService:     messageService,
+		suppressionService: suppressionService,
+		httpClient:         newHTTPClient(cfg.CRMExportTimeout),
+		signer:             signing.NewSigner(cfg.SigningKeyID, cfg.CRMExportSigningSecret),
+		config:             cfg,
+		logger:             logger,
+	}
+}
+
+// Enabled reports whether a CRM endpoint is configured
+func (s *CRMExportService) Enabled() bool {
+	return s.config.CRMExportURL != ""
+}
+
+// Export stores the conversation record of a closed session and delivers it. Sessions
+// are exported once; later calls for the same session are no-ops.
+func (s *CRMExportService) Export(ctx context.Context, session *models.ChatSession) error {
+	record, err := s.buildRecord(ctx, session)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal CRM record: %w", err)
+	}
+
+	// The first attempt runs right away; the lease keeps the retry worker off it meanwhile
+	query := `
+		INSERT INTO crm_exports (id, session_id, status, attempts, next_attempt_at, payload, created_at, updated_at)
+		VALUES ($1, $2, 'pending', 0, $3, $4, NOW(), NOW())
+		ON CONFLICT (session_id) DO NOTHING
+		RETURNING` + crmExportColumns
+
+	export, err := scanCRMExport(s.db.QueryRow(ctx, query, uuid.New(), session.ID, time.Now().Add(s.lease()), payload))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to store CRM export: %w", err)
+	}
+
+	s.deliver(ctx, export)
+	return nil
+}
+
+// Start retries due exports until ctx is done
+func (s *CRMExportService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CRMExportRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RetryDue(ctx); err != nil {
+				s.logger.WithError(err).Error("CRM export retry pass failed")
+			}
+		}
+	}
+}
+
+// RetryDue claims pending exports whose next attempt is due and delivers them
+func (s *CRMExportService) RetryDue(ctx context.Context) error {
+	query := `
+		UPDATE crm_exports
+		SET next_attempt_at = $1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM crm_exports
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING` + crmExportColumns
+
+	rows, err := s.db.Query(ctx, query, time.Now().Add(s.lease()), crmExportBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to claim due CRM exports: %w", err)
+	}
+
+	var exports []*models.CRMExport
+	for rows.Next() {
+		export, err := scanCRMExport(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan CRM export: %w", err)
+		}
+		exports = append(exports, export)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading due CRM exports: %w", err)
+	}
+
+	for _, export := range exports {
+		s.deliver(ctx, export)
+	}
+
+	return nil
+}
+
+// Retry gives a failed export a fresh set of attempts and delivers it right away
+func (s *CRMExportService) Retry(ctx context.Context, id uuid.UUID) (*models.CRMExport, error) {
+	query := `
+		UPDATE crm_exports
+		SET status = 'pending', attempts = 0, next_attempt_at = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'failed'
+		RETURNING` + crmExportColumns
+
+	export, err := scanCRMExport(s.db.QueryRow(ctx, query, id, time.Now().Add(s.lease())))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, err := s.Get(ctx, id); err != nil {
+				return nil, err
+			}
+			return nil, ErrCRMExportNotRetryable
+		}
+		return nil, fmt.Errorf("failed to reset CRM export: %w", err)
+	}
+
+	s.deliver(ctx, export)
+	return s.Get(ctx, id)
+}
+
+// Get returns an export with its delivery attempts
+func (s *CRMExportService) Get(ctx context.Context, id uuid.UUID) (*models.CRMExport, error) {
+	query := `SELECT` + crmExportColumns + ` FROM crm_exports WHERE id = $1`
+
+	export, err := scanCRMExport(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCRMExportNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve CRM export: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT id, export_id, attempt, status_code, error, duration_ms, created_at
+		FROM crm_export_attempts
+		WHERE export_id = $1
+		ORDER BY attempt`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CRM export attempts: %w", err)
+	}
+	defer rows.Close()
+
+	export.History = []*models.CRMExportAttempt{}
+	for rows.Next() {
+		var attempt models.CRMExportAttempt
+		if err := rows.Scan(
+			&attempt.ID,
+			&attempt.ExportID,
+			&attempt.Attempt,
+			&attempt.StatusCode,
+			&attempt.Error,
+			&attempt.DurationMS,
+			&attempt.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan CRM export attempt: %w", err)
+		}
+		export.History = append(export.History, &attempt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading CRM export attempts: %w", err)
+	}
+
+	return export, nil
+}
+
+// List returns exports, newest first, optionally of one status
+func (s *CRMExportService) List(ctx context.Context, status string, limit, offset int) ([]*models.CRMExport, int64, error) {
+	query := `SELECT` + crmExportColumns + ` FROM crm_exports
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query CRM exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := []*models.CRMExport{}
+	for rows.Next() {
+		export, err := scanCRMExport(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan CRM export: %w", err)
+		}
+		exports = append(exports, export)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading CRM exports: %w", err)
+	}
+
+	return exports, estimateTotal(ctx, s.db, s.logger, query, status, limit, offset), nil
+}
+
+// Helper methods
+
+// buildRecord assembles the CRM record of a session from its user, messages and the
+// suppression list
+func (s *CRMExportService) buildRecord(ctx context.Context, session *models.ChatSession) (*models.CRMConversationRecord, error) {
+	user, err := scanUser(s.db.QueryRow(ctx, `SELECT `+userColumns+` FROM whatsapp_users WHERE id = $1`, session.UserID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load session user: %w", err)
+	}
+
+	messages, err := s.messageService.GetMessagesBySession(ctx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.CRMConversationRecord{
+		SessionID:   session.ID,
+		UserID:      user.ID,
+		PhoneNumber: user.PhoneNumber,
+		ProfileName: user.ProfileName,
+		Channels:    []models.Channel{},
+		StartedAt:   session.StartedAt,
+		EndedAt:     session.EndedAt,
+		CloseReason: session.CloseReason,
+		Summary:     session.Summary,
+		State:       string(session.State),
+		Tags:        session.Tags,
+		Labels:      []string{},
+		Consent: models.CRMConsent{
+			Contactable:      true,
+			OptedOutChannels: []models.Channel{},
+		},
+	}
+	if record.Tags == nil {
+		record.Tags = []string{}
+	}
+
+	channels := make(map[models.Channel]bool)
+	senders := make(map[models.Channel]string)
+	labels := make(map[string]bool)
+	for _, message := range messages {
+		record.MessageCounts.Total++
+		if message.Direction == models.MessageDirectionInbound {
+			record.MessageCounts.Inbound++
+			senders[message.Channel] = message.From
+		} else {
+			record.MessageCounts.Outbound++
+		}
+		if message.MediaURL != nil && *message.MediaURL != "" {
+			record.MessageCounts.Media++
+		}
+		if !channels[message.Channel] {
+			channels[message.Channel] = true
+			record.Channels = append(record.Channels, message.Channel)
+		}
+		for _, label := range message.Labels {
+			if !labels[label] {
+				labels[label] = true
+				record.Labels = append(record.Labels, label)
+			}
+		}
+	}
+	sort.Strings(record.Labels)
+
+	for _, channel := range record.Channels {
+		sender, ok := senders[channel]
+		if !ok {
+			continue
+		}
+		suppression, err := s.suppressionService.Lookup(ctx, channel, sender)
+		if err != nil {
+			return nil, err
+		}
+		if suppression != nil {
+			record.Consent.Contactable = false
+			record.Consent.OptedOutChannels = append(record.Consent.OptedOutChannels, channel)
+		}
+	}
+
+	return record, nil
+}
+
+// deliver makes one delivery attempt, logs it and schedules the next one on failure
+func (s *CRMExportService) deliver(ctx context.Context, export *models.CRMExport) {
+	attempt := export.Attempts + 1
+	started := time.Now()
+	statusCode, err := s.post(ctx, export)
+	duration := time.Since(started)
+
+	var code *int
+	if statusCode > 0 {
+		code = &statusCode
+	}
+	var lastError *string
+	if err != nil {
+		lastError = optionalString(err.Error())
+	}
+
+	if _, logErr := s.db.Exec(ctx, `
+		INSERT INTO crm_export_attempts (id, export_id, attempt, status_code, error, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())`,
+		uuid.New(), export.ID, attempt, code, lastError, duration.Milliseconds(),
+	); logErr != nil {
+		s.logger.WithError(logErr).WithField("export_id", export.ID).Warn("Failed to log CRM export attempt")
+	}
+
+	entry := s.logger.WithFields(logrus.Fields{
+		"export_id":   export.ID,
+		"session_id":  export.SessionID,
+		"attempt":     attempt,
+		"status_code": statusCode,
+	})
+
+	var query string
+	var args []interface{}
+	switch {
+	case err == nil:
+		crmExportsTotal.Inc("delivered")
+		entry.Info("Conversation exported to CRM")
+		query = `
+			UPDATE crm_exports
+			SET status = 'delivered', attempts = $2, last_status_code = $3, last_error = NULL,
+				next_attempt_at = NULL, delivered_at = NOW(), updated_at = NOW()
+			WHERE id = $1`
+		args = []interface{}{export.ID, attempt, code}
+
+	case attempt >= s.config.CRMExportMaxAttempts || permanentCRMFailure(statusCode):
+		crmExportsTotal.Inc("failed")
+		entry.WithError(err).Error("CRM export failed permanently")
+		query = `
+			UPDATE crm_exports
+			SET status = 'failed', attempts = $2, last_status_code = $3, last_error = $4,
+				next_attempt_at = NULL, updated_at = NOW()
+			WHERE id = $1`
+		args = []interface{}{export.ID, attempt, code, lastError}
+
+	default:
+		crmExportsTotal.Inc("retry")
+		nextAttempt := time.Now().Add(s.backoff(attempt))
+		entry.WithError(err).WithField("next_attempt_at", nextAttempt).Warn("CRM export failed, will retry")
+		query = `
+			UPDATE crm_exports
+			SET attempts = $2, last_status_code = $3, last_error = $4, next_attempt_at = $5,
+				updated_at = NOW()
+			WHERE id = $1`
+		args = []interface{}{export.ID, attempt, code, lastError, nextAttempt}
+	}
+
+	if _, err := s.db.Exec(ctx, query, args...); err != nil {
+		s.logger.WithError(err).WithField("export_id", export.ID).Error("Failed to update CRM export")
+	}
+}
+
+// post sends an export payload to the CRM, returning the response status if one arrived
+func (s *CRMExportService) post(ctx context.Context, export *models.CRMExport) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.CRMExportURL, bytes.NewReader(export.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create CRM request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+	// The export ID stays the same across retries so the CRM can deduplicate
+	req.Header.Set("X-Export-ID", export.ID.String())
+	if s.config.CRMExportAuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.CRMExportAuthToken)
+	}
+
+	if err := s.signer.Sign(req, export.Payload); err != nil {
+		return 0, fmt.Errorf("failed to sign CRM request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call CRM: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("CRM returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt after the given one
+func (s *CRMExportService) backoff(attempt int) time.Duration {
+	delay := s.config.CRMExportRetryInterval
+	for i := 1; i < attempt && delay < crmExportMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > crmExportMaxBackoff {
+		delay = crmExportMaxBackoff
+	}
+	return delay
+}
+
+// lease is how long a claimed export is kept from other workers while it is delivered
+func (s *CRMExportService) lease() time.Duration {
+	return 2 * s.config.CRMExportTimeout
+}
+
+// permanentCRMFailure reports client errors that retrying cannot fix
+func permanentCRMFailure(statusCode int) bool {
+	return statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
+
+// scanCRMExport scans a crm_exports row selected with crmExportColumns
+func scanCRMExport(row pgx.Row) (*models.CRMExport, error) {
+	var export models.CRMExport
+	err := row.Scan(
+		&export.ID,
+		&export.SessionID,
+		&export.Status,
+		&export.Attempts,
+		&export.LastStatusCode,
+		&export.LastError,
+		&export.NextAttemptAt,
+		&export.DeliveredAt,
+		&export.Payload,
+		&export.CreatedAt,
+		&export.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
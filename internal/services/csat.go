@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// csatBatchSize bounds the surveys one poll sends
+const csatBatchSize = 50
+
+var csatSurveysTotal = metrics.NewCounter("csat_surveys_total", "Satisfaction surveys by the status they entered", "status")
+
+// CSATService asks users to rate a conversation once its session closes. Closing a
+// session schedules a survey CSAT_DELAY later; a poller sends the CSAT_TEMPLATE_SID
+// template then, unless the user has started another conversation or is suppressed.
+// The rating comes back as a "csat:<1-5>" button payload, or as a typed digit opening
+// the next conversation, within CSAT_RESPONSE_WINDOW of the survey.
+type CSATService struct {
+	db                 *pgxpool.Pool
+	autoReply          *AutoReplyService
+	suppressionService *SuppressionService
+	eventService       *EventService
+	config             *config.Config
+	logger             *logrus.Logger
+}
+
+// NewCSATService creates a new CSAT service instance
+func NewCSATService(
+	db *pgxpool.Pool,
+	autoReply *AutoReplyService,
+	suppressionService *SuppressionService,
+	eventService *EventService,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *CSATService {
+	return &CSATService{
+		db:                 db,
+		autoReply:          autoReply,
+		suppressionService: suppressionService,
+		eventService:       eventService,
+		config:             cfg,
+		logger:             logger,
+	}
+}
+
+// Schedule plans the survey of a closed session to the sender of its last inbound
+// message. Merged sessions, sessions nobody wrote in and sessions that only carried a
+// rating are not surveyed; a session is surveyed at most once.
+func (s *CSATService) Schedule(ctx context.Context, session *models.ChatSession) error {
+	if session.CloseReason != nil && *session.CloseReason == models.SessionCloseReasonMerged {
+		return nil
+	}
+
+	var channel models.Channel
+	var to string
+	err := s.db.QueryRow(ctx, `
+		SELECT m.channel, m.from_number FROM whatsapp_messages m
+		WHERE m.session_id = $1 AND m.direction = 'inbound'
+			AND NOT EXISTS (SELECT 1 FROM csat_responses c WHERE c.reply_message_id = m.id)
+		ORDER BY m.timestamp DESC
+		LIMIT 1`, session.ID).Scan(&channel, &to)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load last inbound message: %w", err)
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		INSERT INTO csat_responses (
+			id, session_id, user_id, to_number, channel, status, send_after, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (session_id) DO NOTHING`,
+		uuid.New(), session.ID, session.UserID, to, channel, models.CSATStatusScheduled,
+		time.Now().Add(s.config.CSATDelay),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to schedule satisfaction survey: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		csatSurveysTotal.Inc(string(models.CSATStatusScheduled))
+	}
+	return nil
+}
+
+// Start sends due surveys every CSAT_POLL_INTERVAL until ctx is canceled
+func (s *CSATService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CSATPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.SendDue(ctx)
+			if err != nil {
+				s.logger.WithError(err).Error("Satisfaction survey poll failed")
+				continue
+			}
+			if sent > 0 {
+				s.logger.WithField("surveys_sent", sent).Info("Satisfaction surveys sent")
+			}
+		}
+	}
+}
+
+// SendDue claims the surveys whose delay has passed and sends them. Claiming moves them
+// to sending, so replicas polling at the same time never send a survey twice.
+func (s *CSATService) SendDue(ctx context.Context) (int, error) {
+	rows, err := s.db.Query(ctx, `
+		UPDATE csat_responses
+		SET status = 'sending', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM csat_responses
+			WHERE status = 'scheduled' AND send_after <= NOW()
+			ORDER BY send_after
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING`+csatColumns, csatBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim satisfaction surveys: %w", err)
+	}
+
+	var due []*models.CSATResponse
+	for rows.Next() {
+		response, err := scanCSATResponse(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan satisfaction survey: %w", err)
+		}
+		due = append(due, response)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading satisfaction surveys: %w", err)
+	}
+
+	sent := 0
+	for _, response := range due {
+		status := s.send(ctx, response)
+		if err := s.setStatus(ctx, response, status); err != nil {
+			s.logger.WithError(err).WithField("survey_id", response.ID).Error("Failed to record satisfaction survey")
+			continue
+		}
+		if status == models.CSATStatusSent {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// HandleReply records the rating an inbound message gives to the user's latest
+// unanswered survey, and reports whether it was one. Typed digits only count as a rating
+// when they open a new conversation, so an answer to a question of the bot is not
+// mistaken for one.
+func (s *CSATService) HandleReply(ctx context.Context, session *models.ChatSession, message *models.WhatsAppMessage) bool {
+	rating, ok := parseCSATPayload(message.ButtonPayload)
+	if !ok && message.ButtonPayload == "" && (session == nil || session.New) {
+		rating, ok = parseCSATRating(message.Content)
+	}
+	if !ok {
+		return false
+	}
+
+	response, err := scanCSATResponse(s.db.QueryRow(ctx, `
+		UPDATE csat_responses
+		SET status = 'answered', rating = $4, reply_message_id = $5, responded_at = NOW(),
+			updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM csat_responses
+			WHERE channel = $1 AND to_number = $2 AND status = 'sent' AND sent_at > $3
+			ORDER BY sent_at DESC
+			LIMIT 1
+		)
+		RETURNING`+csatColumns,
+		message.Channel, message.From, time.Now().Add(-s.config.CSATResponseWindow), rating, message.ID,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to record satisfaction rating")
+		return false
+	}
+	csatSurveysTotal.Inc(string(models.CSATStatusAnswered))
+
+	s.logger.WithFields(logrus.Fields{
+		"survey_id":  response.ID,
+		"session_id": response.SessionID,
+		"rating":     rating,
+	}).Info("Satisfaction survey answered")
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventCSATAnswered,
+		MessageID: &message.ID,
+		SessionID: &response.SessionID,
+		Data: map[string]interface{}{
+			"survey_id": response.ID,
+			"rating":    rating,
+			"channel":   response.Channel,
+			"user_id":   response.UserID,
+		},
+	})
+
+	return true
+}
+
+// Stats aggregates the surveys sent between from and to, optionally on one channel
+func (s *CSATService) Stats(ctx context.Context, from, to time.Time, channel models.Channel) (*models.CSATStats, error) {
+	stats := &models.CSATStats{
+		From:         from,
+		To:           to,
+		Channel:      channel,
+		Distribution: map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0},
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT rating, COUNT(*) FROM csat_responses
+		WHERE sent_at >= $1 AND sent_at < $2 AND ($3 = '' OR channel = $3)
+		GROUP BY rating`, from, to, string(channel))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query satisfaction ratings: %w", err)
+	}
+	defer rows.Close()
+
+	var sum int64
+	var satisfied int64
+	for rows.Next() {
+		var rating *int
+		var count int64
+		if err := rows.Scan(&rating, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan satisfaction ratings: %w", err)
+		}
+		stats.Sent += count
+		if rating == nil {
+			continue
+		}
+		stats.Responses += count
+		stats.Distribution[*rating] = count
+		sum += int64(*rating) * count
+		if *rating >= 4 {
+			satisfied += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading satisfaction ratings: %w", err)
+	}
+
+	if stats.Sent > 0 {
+		stats.ResponseRate = float64(stats.Responses) / float64(stats.Sent)
+	}
+	if stats.Responses > 0 {
+		average := float64(sum) / float64(stats.Responses)
+		score := float64(satisfied) / float64(stats.Responses)
+		stats.Average = &average
+		stats.Score = &score
+	}
+
+	return stats, nil
+}
+
+// Helper methods
+
+// send delivers one claimed survey and returns the status it ends in
+func (s *CSATService) send(ctx context.Context, response *models.CSATResponse) models.CSATStatus {
+	logger := s.logger.WithFields(logrus.Fields{
+		"survey_id":  response.ID,
+		"session_id": response.SessionID,
+	})
+
+	// A user who is already talking to us again is not interrupted with a survey
+	var busy bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM chat_sessions
+			WHERE user_id = $1 AND status = 'active' AND id <> $2
+		)`, response.UserID, response.SessionID).Scan(&busy)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to check for a newer conversation")
+		return models.CSATStatusFailed
+	}
+	if busy {
+		return models.CSATStatusSkipped
+	}
+
+	suppression, err := s.suppressionService.Lookup(ctx, response.Channel, response.To)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to check suppression list")
+		return models.CSATStatusFailed
+	}
+	if suppression != nil {
+		return models.CSATStatusSkipped
+	}
+
+	// The survey is stored in the conversation it rates
+	userID := response.UserID
+	sessionID := response.SessionID
+	inbound := &models.WhatsAppMessage{
+		From:      response.To,
+		Channel:   response.Channel,
+		UserID:    &userID,
+		SessionID: &sessionID,
+	}
+	survey, err := s.autoReply.ReplyTemplate(ctx, inbound, s.config.CSATTemplateSID, map[string]string{
+		"survey_id": response.ID.String(),
+	})
+	if err != nil {
+		return models.CSATStatusFailed
+	}
+
+	response.SurveyMessageID = &survey.ID
+	return models.CSATStatusSent
+}
+
+// setStatus records the outcome of sending a survey
+func (s *CSATService) setStatus(ctx context.Context, response *models.CSATResponse, status models.CSATStatus) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE csat_responses
+		SET status = $2, survey_message_id = $3,
+			sent_at = CASE WHEN $2 = 'sent' THEN NOW() END,
+			updated_at = NOW()
+		WHERE id = $1`, response.ID, status, response.SurveyMessageID)
+	if err != nil {
+		return fmt.Errorf("failed to update satisfaction survey: %w", err)
+	}
+	response.Status = status
+	csatSurveysTotal.Inc(string(status))
+	return nil
+}
+
+// Helper functions
+
+// csatColumns lists the csat_responses columns in the order scanCSATResponse expects
+const csatColumns = `
+	id, session_id, user_id, to_number, channel, status, send_after, survey_message_id,
+	sent_at, rating, reply_message_id, responded_at, created_at, updated_at`
+
+// scanCSATResponse reads a survey selected with csatColumns
+func scanCSATResponse(row pgx.Row) (*models.CSATResponse, error) {
+	var response models.CSATResponse
+	err := row.Scan(
+		&response.ID, &response.SessionID, &response.UserID, &response.To, &response.Channel,
+		&response.Status, &response.SendAfter, &response.SurveyMessageID, &response.SentAt,
+		&response.Rating, &response.ReplyMessageID, &response.RespondedAt, &response.CreatedAt,
+		&response.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// parseCSATPayload reads the rating of a survey button payload, e.g. "csat:5" or
+// "csat_5"
+func parseCSATPayload(payload string) (int, bool) {
+	payload = strings.ToLower(strings.TrimSpace(payload))
+	if !strings.HasPrefix(payload, models.CSATPayloadPrefix) {
+		return 0, false
+	}
+	rest := strings.TrimPrefix(payload, models.CSATPayloadPrefix)
+	if rest == "" || (rest[0] != ':' && rest[0] != '_') {
+		return 0, false
+	}
+	return parseCSATRating(rest[1:])
+}
+
+// parseCSATRating reads a rating typed as a single digit from 1 to 5
+func parseCSATRating(text string) (int, bool) {
+	rating, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || rating < 1 || rating > 5 {
+		return 0, false
+	}
+	return rating, true
+}
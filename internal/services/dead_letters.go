@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrDeadLetterNotFound is returned when a dead letter does not exist
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+var deadLettersTotal = metrics.NewCounter("dead_letters_total", "Inbound payloads whose processing panicked, by source and stage", "source", "stage")
+
+// deadLetterColumns lists the dead_letters columns in the order scanDeadLetter expects
+const deadLetterColumns = `
+	id, source, stage, message_id, payload, error, stack, created_at`
+
+// DeadLetterService keeps inbound payloads whose processing panicked, so a poisoned
+// webhook is acknowledged once and can be analyzed instead of being retried forever
+type DeadLetterService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewDeadLetterService creates a new dead letter service
+func NewDeadLetterService(db *pgxpool.Pool, logger *logrus.Logger) *DeadLetterService {
+	return &DeadLetterService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Record stores a payload that panicked in a pipeline stage together with the
+// recovered value and stack trace. It uses its own context, since the request that
+// panicked may already be gone.
+func (s *DeadLetterService) Record(source, stage string, messageID *uuid.UUID, payload interface{}, recovered interface{}, stack []byte) {
+	deadLettersTotal.Inc(source, stage)
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprintf("%+v", payload))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO dead_letters (id, source, stage, message_id, payload, error, stack, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`,
+		uuid.New(), source, stage, messageID, raw, fmt.Sprint(recovered), string(stack),
+	)
+	if err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"source": source,
+			"stage":  stage,
+		}).Error("Failed to record dead letter")
+	}
+}
+
+// List returns dead letters, newest first, optionally from one source
+func (s *DeadLetterService) List(ctx context.Context, source string, limit, offset int) ([]*models.DeadLetter, int64, error) {
+	query := `SELECT` + deadLetterColumns + ` FROM dead_letters
+		WHERE $1 = '' OR source = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, source, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := []*models.DeadLetter{}
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading dead letters: %w", err)
+	}
+
+	return deadLetters, estimateTotal(ctx, s.db, s.logger, query, source, limit, offset), nil
+}
+
+// Get returns one dead letter
+func (s *DeadLetterService) Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	deadLetter, err := scanDeadLetter(s.db.QueryRow(ctx, `SELECT`+deadLetterColumns+` FROM dead_letters WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+	return deadLetter, nil
+}
+
+// scanDeadLetter scans a dead_letters row selected with deadLetterColumns
+func scanDeadLetter(row pgx.Row) (*models.DeadLetter, error) {
+	var deadLetter models.DeadLetter
+	err := row.Scan(
+		&deadLetter.ID,
+		&deadLetter.Source,
+		&deadLetter.Stage,
+		&deadLetter.MessageID,
+		&deadLetter.Payload,
+		&deadLetter.Error,
+		&deadLetter.Stack,
+		&deadLetter.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetter, nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// dedupPending marks a fingerprint whose send has not completed yet
+const dedupPending = "pending"
+
+// DuplicateSendError is returned when an identical message was sent within the dedup window
+type DuplicateSendError struct {
+	// PriorMessageID is empty while the earlier send is still in flight
+	PriorMessageID string
+}
+
+func (e *DuplicateSendError) Error() string {
+	if e.PriorMessageID == "" {
+		return "identical message is already being sent"
+	}
+	return fmt.Sprintf("identical message already sent as %s", e.PriorMessageID)
+}
+
+// OutboundDedupService suppresses accidental double-sends of identical outbound messages
+type OutboundDedupService struct {
+	redis  *redis.Client
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewOutboundDedupService creates a new outbound dedup service instance
+func NewOutboundDedupService(redisClient *redis.Client, cfg *config.Config, logger *logrus.Logger) *OutboundDedupService {
+	return &OutboundDedupService{
+		redis:  redisClient,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Claim reserves the fingerprint of an outbound request for the dedup window. It returns
+// the claimed key, to be passed to Confirm or Release, or a *DuplicateSendError. An empty
+// key means deduplication does not apply. Redis failures fail open.
+func (d *OutboundDedupService) Claim(ctx context.Context, request *models.SendMessageRequest) (string, error) {
+	if d.config.DuplicateSendWindow <= 0 || request.AllowDuplicate {
+		return "", nil
+	}
+
+	key := d.key(request)
+
+	claimed, err := d.redis.SetNX(ctx, key, dedupPending, d.config.DuplicateSendWindow).Result()
+	if err != nil {
+		d.logger.WithError(err).Warn("Failed to check outbound duplicate, sending anyway")
+		return "", nil
+	}
+	if claimed {
+		return key, nil
+	}
+
+	prior, err := d.redis.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		d.logger.WithError(err).Warn("Failed to read prior duplicate send")
+	}
+	if prior == dedupPending {
+		prior = ""
+	}
+
+	return "", &DuplicateSendError{PriorMessageID: prior}
+}
+
+// Check reports whether a request would be suppressed as a duplicate without claiming
+// its fingerprint. Redis failures fail open, as in Claim.
+func (d *OutboundDedupService) Check(ctx context.Context, request *models.SendMessageRequest) error {
+	if d.config.DuplicateSendWindow <= 0 || request.AllowDuplicate {
+		return nil
+	}
+
+	prior, err := d.redis.Get(ctx, d.key(request)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			d.logger.WithError(err).Warn("Failed to check outbound duplicate")
+		}
+		return nil
+	}
+	if prior == dedupPending {
+		prior = ""
+	}
+
+	return &DuplicateSendError{PriorMessageID: prior}
+}
+
+// Confirm records the message ID sent for a claimed key
+func (d *OutboundDedupService) Confirm(ctx context.Context, key string, messageID uuid.UUID) {
+	if key == "" {
+		return
+	}
+	if err := d.redis.Set(ctx, key, messageID.String(), d.config.DuplicateSendWindow).Err(); err != nil {
+		d.logger.WithError(err).Warn("Failed to record outbound dedup entry")
+	}
+}
+
+// Release frees a claimed key after a failed send so the request can be retried
+func (d *OutboundDedupService) Release(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := d.redis.Del(ctx, key).Err(); err != nil {
+		d.logger.WithError(err).Warn("Failed to release outbound dedup entry")
+	}
+}
+
+// key returns the Redis key a request's fingerprint is claimed under
+func (d *OutboundDedupService) key(request *models.SendMessageRequest) string {
+	return redisKey(d.config, "outbound:dedup:"+outboundFingerprint(request))
+}
+
+// outboundFingerprint hashes the channel, the recipient and everything that determines
+// what they receive. Only phone numbers are normalized as phone numbers; other channels'
+// recipient IDs are compared as their identities are.
+func outboundFingerprint(request *models.SendMessageRequest) string {
+	channel := request.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	parts := []string{
+		string(channel),
+		normalizeRecipient(channel, request.To),
+		string(request.Type),
+		request.Content,
+	}
+	if request.MediaURL != nil {
+		parts = append(parts, *request.MediaURL)
+	}
+	if request.Template != nil {
+		parts = append(parts, *request.Template)
+
+		keys := make([]string, 0, len(request.Variables))
+		for key := range request.Variables {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts = append(parts, key+"="+request.Variables[key])
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
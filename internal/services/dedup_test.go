@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestOutboundFingerprint(t *testing.T) {
+	text := func(channel models.Channel, to, content string) *models.SendMessageRequest {
+		return &models.SendMessageRequest{Channel: channel, To: to, Type: models.MessageTypeText, Content: content}
+	}
+
+	tests := []struct {
+		name string
+		a, b *models.SendMessageRequest
+		same bool
+	}{
+		{"same send", text(models.ChannelWhatsApp, "+5511999990000", "hi"), text(models.ChannelWhatsApp, "+5511999990000", "hi"), true},
+		{"default channel is whatsapp", text("", "+5511999990000", "hi"), text(models.ChannelWhatsApp, "+5511999990000", "hi"), true},
+		{"formatted phone number", text(models.ChannelSMS, "+55 (11) 99999-0000", "hi"), text(models.ChannelSMS, "+5511999990000", "hi"), true},
+		{"different channel", text(models.ChannelWhatsApp, "+5511999990000", "hi"), text(models.ChannelSMS, "+5511999990000", "hi"), false},
+		{"different content", text(models.ChannelWhatsApp, "+5511999990000", "hi"), text(models.ChannelWhatsApp, "+5511999990000", "hello"), false},
+		{"email case", text(models.ChannelEmail, "Ana@Example.com", "hi"), text(models.ChannelEmail, "ana@example.com", "hi"), true},
+		{"telegram ids not read as phones", text(models.ChannelTelegram, "-100123", "hi"), text(models.ChannelTelegram, "+100123", "hi"), false},
+		{"messenger ids not read as phones", text(models.ChannelMessenger, "12 34", "hi"), text(models.ChannelMessenger, "1234", "hi"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := outboundFingerprint(tt.a) == outboundFingerprint(tt.b); same != tt.same {
+				t.Errorf("fingerprints equal = %v, want %v", same, tt.same)
+			}
+		})
+	}
+}
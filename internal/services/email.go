@@ -0,0 +1,472 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// emailMaxBodyBytes caps how much of an inbound email body is kept
+const emailMaxBodyBytes = 64 * 1024
+
+var (
+	// ErrInvalidSNSSignature is returned for SNS posts that are not signed by Amazon SNS
+	ErrInvalidSNSSignature = errors.New("invalid SNS signature")
+
+	// ErrEmailUnauthenticated is returned for emails whose sender failed SPF or DKIM
+	ErrEmailUnauthenticated = errors.New("email sender is not authenticated")
+)
+
+// snsCertHost matches the hosts Amazon SNS serves its signing certificates from
+var snsCertHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// sendGridDKIMResult matches one "@domain : result" entry of SendGrid's dkim field
+var sendGridDKIMResult = regexp.MustCompile(`@([^\s:,{}]+)\s*:\s*([a-z]+)`)
+
+// quotedReplyMarkers match the line where a client starts quoting the previous message
+var quotedReplyMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`^>`),
+	regexp.MustCompile(`^(On|Em) .+(wrote|escreveu):$`),
+	regexp.MustCompile(`^-{2,}\s*(Original Message|Mensagem original)\s*-{2,}$`),
+	regexp.MustCompile(`^(From|De): .+`),
+}
+
+// EmailService bridges email into conversations: it parses inbound emails and sends
+// conversation replies over SMTP
+type EmailService struct {
+	config *config.Config
+	logger *logrus.Logger
+
+	certsMu sync.Mutex
+	certs   map[string]*x509.Certificate // SNS signing certificates by URL
+}
+
+// NewEmailService creates a new email bridge service instance
+func NewEmailService(cfg *config.Config, logger *logrus.Logger) *EmailService {
+	return &EmailService{
+		config: cfg,
+		logger: logger,
+		certs:  make(map[string]*x509.Certificate),
+	}
+}
+
+// SendTextMessage emails a conversation reply
+func (e *EmailService) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient address: %w", err)
+	}
+
+	messageID := fmt.Sprintf("<%s@%s>", uuid.New().String(), e.domain())
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", (&mail.Address{Name: e.config.EmailFromName, Address: e.config.EmailFromAddress}).String())
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", e.config.EmailSubject))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", messageID)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&msg)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return nil, fmt.Errorf("failed to encode email body: %w", err)
+	}
+	qp.Close()
+
+	var auth smtp.Auth
+	if e.config.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", e.config.SMTPUsername, e.config.SMTPPassword, e.config.SMTPHost)
+	}
+
+	addr := fmt.Sprintf("%s:%d", e.config.SMTPHost, e.config.SMTPPort)
+	if err := smtp.SendMail(addr, auth, e.config.EmailFromAddress, []string{recipient.Address}, msg.Bytes()); err != nil {
+		e.logger.WithError(err).Error("Failed to send email")
+		return nil, fmt.Errorf("failed to send email: %w", err)
+	}
+
+	e.logger.WithField("message_id", messageID).Info("Email sent successfully")
+
+	return &models.SendMessageResponse{
+		ID:        uuid.New(),
+		TwilioSID: messageID,
+		Status:    models.MessageStatusSent,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// SendMediaMessage emails the content with a link to the media
+func (e *EmailService) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	body := strings.TrimSpace(content + "\n\n" + mediaURL)
+	return e.SendTextMessage(ctx, to, body)
+}
+
+// SendTemplateMessage is not supported; WhatsApp content templates have no email rendering
+func (e *EmailService) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("template messages are not supported over email")
+}
+
+// Name identifies the provider
+func (e *EmailService) Name() string {
+	return "smtp_email"
+}
+
+// GetFromNumber returns the address replies are sent from
+func (e *EmailService) GetFromNumber() string {
+	return e.config.EmailFromAddress
+}
+
+// ParseRawEmail extracts the sender, subject and plain-text body of a raw RFC 5322 message
+func (e *EmailService) ParseRawEmail(raw []byte) (*models.InboundEmail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	email := &models.InboundEmail{
+		MessageID: strings.TrimSpace(msg.Header.Get("Message-ID")),
+		To:        msg.Header.Get("To"),
+		Subject:   decodeHeader(msg.Header.Get("Subject")),
+	}
+
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		email.From = from.Address
+		email.FromName = from.Name
+	}
+
+	text, err := extractPlainText(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+	email.Text = text
+
+	return email, nil
+}
+
+// ParseParsedFields builds an inbound email from the fields of a SendGrid Inbound Parse
+// post; the raw headers are only consulted for the Message-ID
+func (e *EmailService) ParseParsedFields(from, to, subject, text, headers string) *models.InboundEmail {
+	email := &models.InboundEmail{
+		From:    from,
+		To:      to,
+		Subject: subject,
+		Text:    strings.TrimSpace(text),
+	}
+
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		email.From = parsed.Address
+		email.FromName = parsed.Name
+	}
+
+	if msg, err := mail.ReadMessage(strings.NewReader(headers + "\r\n\r\n")); err == nil {
+		email.MessageID = strings.TrimSpace(msg.Header.Get("Message-ID"))
+	}
+
+	return email
+}
+
+// ConfirmSNSSubscription visits the SubscribeURL of an SNS subscription confirmation.
+// Only AWS-hosted URLs are followed.
+func (e *EmailService) ConfirmSNSSubscription(ctx context.Context, subscribeURL string) error {
+	parsed, err := url.Parse(subscribeURL)
+	if err != nil || parsed.Scheme != "https" || !strings.HasSuffix(parsed.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("refusing to confirm SNS subscription at %q", subscribeURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subscribeURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create SNS confirmation request: %w", err)
+	}
+
+	resp, err := newHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to confirm SNS subscription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SNS confirmation returned status %d", resp.StatusCode)
+	}
+
+	e.logger.Info("SNS subscription confirmed")
+	return nil
+}
+
+// VerifySNSMessage checks that an SNS post was signed by Amazon SNS, with a certificate
+// served from an SNS host, and comes from one of EMAIL_SNS_TOPIC_ARNS when set
+func (e *EmailService) VerifySNSMessage(ctx context.Context, notification *models.SNSNotification) error {
+	if topics := e.config.EmailSNSTopicARNs; len(topics) > 0 {
+		allowed := false
+		for _, topic := range topics {
+			allowed = allowed || topic == notification.TopicArn
+		}
+		if !allowed {
+			return fmt.Errorf("%w: topic %q is not accepted", ErrInvalidSNSSignature, notification.TopicArn)
+		}
+	}
+
+	var algorithm x509.SignatureAlgorithm
+	switch notification.SignatureVersion {
+	case "1":
+		algorithm = x509.SHA1WithRSA
+	case "2":
+		algorithm = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("%w: unsupported signature version %q", ErrInvalidSNSSignature, notification.SignatureVersion)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(notification.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: signature is not base64", ErrInvalidSNSSignature)
+	}
+
+	cert, err := e.snsCertificate(ctx, notification.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	if err := cert.CheckSignature(algorithm, snsStringToSign(notification), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSNSSignature, err)
+	}
+	return nil
+}
+
+// CheckSESVerdicts accepts emails that passed both the SPF and the DKIM checks of SES
+func (e *EmailService) CheckSESVerdicts(receipt *models.SESReceiptNotification) error {
+	spf, dkim := receipt.Receipt.SPFVerdict.Status, receipt.Receipt.DKIMVerdict.Status
+	if !strings.EqualFold(spf, "PASS") || !strings.EqualFold(dkim, "PASS") {
+		return fmt.Errorf("%w: SPF %s, DKIM %s", ErrEmailUnauthenticated, spf, dkim)
+	}
+	return nil
+}
+
+// CheckSendGridVerdicts accepts emails that passed SendGrid's SPF check and carry a
+// passing DKIM signature of the sender's domain
+func (e *EmailService) CheckSendGridVerdicts(spf, dkim string, email *models.InboundEmail) error {
+	if !strings.EqualFold(strings.TrimSpace(spf), "pass") {
+		return fmt.Errorf("%w: SPF %q", ErrEmailUnauthenticated, spf)
+	}
+
+	domain := ""
+	if idx := strings.LastIndex(email.From, "@"); idx >= 0 {
+		domain = strings.ToLower(email.From[idx+1:])
+	}
+	for _, match := range sendGridDKIMResult.FindAllStringSubmatch(strings.ToLower(dkim), -1) {
+		if match[1] == domain && match[2] == "pass" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no passing DKIM signature of %q", ErrEmailUnauthenticated, domain)
+}
+
+// ToMessage converts an inbound email into a conversation message. Quoted history is
+// stripped so only the new reply reaches the orchestrator.
+func (e *EmailService) ToMessage(email *models.InboundEmail) (*models.WhatsAppMessage, error) {
+	from, err := mail.ParseAddress(email.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	content := stripQuotedReply(email.Text)
+	if content == "" {
+		content = email.Subject
+	}
+
+	sid := email.MessageID
+	if sid == "" {
+		sid = fmt.Sprintf("<%s@%s>", uuid.New().String(), e.domain())
+	}
+
+	now := time.Now()
+	return &models.WhatsAppMessage{
+		ID:        uuid.New(),
+		TwilioSID: sid,
+		From:      NormalizeIdentityValue(models.IdentityKindEmail, from.Address),
+		To:        e.config.EmailFromAddress,
+		Direction: models.MessageDirectionInbound,
+		Type:      models.MessageTypeText,
+		Status:    models.MessageStatusDelivered,
+		Content:   content,
+		Timestamp: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Channel:   models.ChannelEmail,
+	}, nil
+}
+
+// Helper methods
+
+// domain returns the domain of the from address, used for generated Message-IDs
+func (e *EmailService) domain() string {
+	if idx := strings.LastIndex(e.config.EmailFromAddress, "@"); idx >= 0 {
+		return e.config.EmailFromAddress[idx+1:]
+	}
+	return "localhost"
+}
+
+// extractPlainText returns the first text/plain part of a (possibly multipart) body
+func extractPlainText(contentType, transferEncoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Messages without a Content-Type are plain text
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to read email part: %w", err)
+			}
+
+			text, err := extractPlainText(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			if text != "" {
+				return text, nil
+			}
+		}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, emailMaxBodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read email body: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// snsCertificate returns the SNS signing certificate at certURL, downloading it the
+// first time. Only HTTPS URLs on SNS hosts are fetched.
+func (e *EmailService) snsCertificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	parsed, err := url.Parse(certURL)
+	if err != nil || parsed.Scheme != "https" || !snsCertHost.MatchString(parsed.Hostname()) || !strings.HasSuffix(parsed.Path, ".pem") {
+		return nil, fmt.Errorf("%w: signing certificate URL %q is not an SNS URL", ErrInvalidSNSSignature, certURL)
+	}
+
+	e.certsMu.Lock()
+	cert, ok := e.certs[certURL]
+	e.certsMu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SNS certificate request: %w", err)
+	}
+	resp, err := newHTTPClient(10 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch SNS signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SNS signing certificate returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SNS signing certificate: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: signing certificate is not PEM", ErrInvalidSNSSignature)
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSNSSignature, err)
+	}
+
+	e.certsMu.Lock()
+	e.certs[certURL] = cert
+	e.certsMu.Unlock()
+	return cert, nil
+}
+
+// snsStringToSign builds the string SNS signs: the name and value of each signed field,
+// one per line, in the order SNS defines for the message type
+func snsStringToSign(notification *models.SNSNotification) []byte {
+	fields := [][2]string{{"Message", notification.Message}, {"MessageId", notification.MessageID}}
+	if notification.Type == "Notification" {
+		if notification.Subject != "" {
+			fields = append(fields, [2]string{"Subject", notification.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", notification.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", notification.Timestamp})
+	if notification.Type != "Notification" {
+		fields = append(fields, [2]string{"Token", notification.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", notification.TopicArn}, [2]string{"Type", notification.Type})
+
+	var payload bytes.Buffer
+	for _, field := range fields {
+		payload.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return payload.Bytes()
+}
+
+// stripQuotedReply drops the quoted previous message that email clients append to replies
+func stripQuotedReply(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		for _, marker := range quotedReplyMarkers {
+			if marker.MatchString(trimmed) {
+				return strings.TrimSpace(strings.Join(lines[:i], "\n"))
+			}
+		}
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// decodeHeader decodes RFC 2047 encoded words such as "=?utf-8?q?Ol=C3=A1?="
+func decodeHeader(value string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// EventService publishes adapter events on Redis pub/sub and, when configured, to a webhook
+type EventService struct {
+	redis      *redis.Client
+	httpClient *http.Client
+	config     *config.Config
+	logger     *logrus.Logger
+}
+
+// NewEventService creates a new event service instance
+func NewEventService(redisClient *redis.Client, cfg *config.Config, logger *logrus.Logger) *EventService {
+	return &EventService{
+		redis:      redisClient,
+		httpClient: newHTTPClient(10 * time.Second),
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// Publish emits an event. Delivery is best effort: failures are logged, never returned,
+// so that event consumers cannot break the request that produced the event.
+func (e *EventService) Publish(ctx context.Context, event *models.Event) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		e.logger.WithError(err).WithField("event_type", event.Type).Error("Failed to marshal event")
+		return
+	}
+
+	if err := e.redis.Publish(ctx, e.config.EventsChannel, payload).Err(); err != nil {
+		e.logger.WithError(err).WithField("event_type", event.Type).Warn("Failed to publish event to Redis")
+	}
+
+	if e.config.EventWebhookURL != "" {
+		go e.deliverWebhook(event, payload)
+	}
+
+	e.logger.WithFields(logrus.Fields{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+	}).Debug("Event published")
+}
+
+// deliverWebhook posts an event payload to the configured webhook URL
+func (e *EventService) deliverWebhook(event *models.Event, payload []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := e.postWebhook(ctx, event, payload); err != nil {
+		e.logger.WithError(err).WithFields(logrus.Fields{
+			"event_id":   event.ID,
+			"event_type": event.Type,
+		}).Warn("Failed to deliver event webhook")
+	}
+}
+
+// postWebhook sends a single webhook request
+func (e *EventService) postWebhook(ctx context.Context, event *models.Event, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.EventWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+	req.Header.Set("X-Event-Type", event.Type)
+	req.Header.Set("X-Event-ID", event.ID.String())
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
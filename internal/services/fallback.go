@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// fallbackSendTimeout bounds the SMS send triggered from a status webhook
+const fallbackSendTimeout = 30 * time.Second
+
+// whatsappFormatting matches paired WhatsApp markup (*bold*, _italic_, ~strike~, ```mono```)
+// that would render literally over SMS
+var whatsappFormatting = []*regexp.Regexp{
+	regexp.MustCompile("```([^`]+)```"),
+	regexp.MustCompile(`\*(\S[^*\n]*?)\*`),
+	regexp.MustCompile(`\b_(\S[^_\n]*?)_\b`),
+	regexp.MustCompile(`~(\S[^~\n]*?)~`),
+}
+
+// FallbackService re-sends permanently failed WhatsApp messages over SMS when the
+// message category and Twilio error code allow it
+type FallbackService struct {
+	sms            MessagingProvider
+	messageService *MessageService
+	config         *config.Config
+	logger         *logrus.Logger
+}
+
+// NewFallbackService creates a new SMS fallback service instance
+func NewFallbackService(sms MessagingProvider, messageService *MessageService, cfg *config.Config, logger *logrus.Logger) *FallbackService {
+	return &FallbackService{
+		sms:            sms,
+		messageService: messageService,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// HandleStatusUpdateAsync starts an SMS fallback in the background when a status
+// update reports a permanent WhatsApp failure
+func (f *FallbackService) HandleStatusUpdateAsync(update *models.MessageStatusUpdate) {
+	if !f.config.SMSFallbackEnabled || update.Status != models.MessageStatusFailed || update.ErrorCode == nil {
+		return
+	}
+	if !containsString(f.config.SMSFallbackErrorCodes, *update.ErrorCode) {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), fallbackSendTimeout)
+		defer cancel()
+
+		if err := f.fallback(ctx, update.MessageSid); err != nil {
+			f.logger.WithError(err).WithField("message_sid", update.MessageSid).Error("SMS fallback failed")
+		}
+	}()
+}
+
+// fallback claims the failed message and sends its content as SMS
+func (f *FallbackService) fallback(ctx context.Context, twilioSID string) error {
+	// Claiming first keeps repeated status callbacks from sending twice
+	original, err := f.messageService.ClaimFallback(ctx, twilioSID)
+	if err != nil {
+		return err
+	}
+	if original == nil {
+		return nil
+	}
+
+	logger := f.logger.WithFields(logrus.Fields{
+		"message_id": original.ID,
+		"to":         original.To,
+	})
+
+	if !f.categoryAllowed(original.Category) {
+		logger.Debug("Message category not eligible for SMS fallback")
+		return nil
+	}
+
+	content := smsBodyFromWhatsApp(original.Content)
+	if content == "" && original.MediaURL == nil {
+		// Templates without stored content have no SMS equivalent
+		logger.Warn("Failed message has no text content, skipping SMS fallback")
+		return nil
+	}
+
+	var response *models.SendMessageResponse
+	if original.MediaURL != nil {
+		mediaType := ""
+		if original.MediaType != nil {
+			mediaType = *original.MediaType
+		}
+		response, err = f.sms.SendMediaMessage(ctx, original.To, content, *original.MediaURL, mediaType)
+	} else {
+		response, err = f.sms.SendTextMessage(ctx, original.To, content)
+	}
+	if err != nil {
+		// Nothing was sent, so the claim must not keep a retried callback from sending
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if releaseErr := f.messageService.ReleaseFallback(releaseCtx, original.ID); releaseErr != nil {
+			logger.WithError(releaseErr).Warn("Failed to release SMS fallback claim")
+		}
+		return fmt.Errorf("failed to send fallback SMS: %w", err)
+	}
+
+	smsMessage := &models.WhatsAppMessage{
+		ID:         response.ID,
+		TwilioSID:  response.TwilioSID,
+		From:       f.sms.GetFromNumber(),
+		To:         normalizePhoneNumber(original.To),
+		Direction:  models.MessageDirectionOutbound,
+		Type:       original.Type,
+		Status:     response.Status,
+		Content:    content,
+		MediaURL:   original.MediaURL,
+		MediaType:  original.MediaType,
+		Timestamp:  response.CreatedAt,
+		CreatedAt:  response.CreatedAt,
+		UpdatedAt:  response.CreatedAt,
+		UserID:     original.UserID,
+		SessionID:  original.SessionID,
+		Category:   original.Category,
+		FallbackOf: &original.ID,
+		Channel:    models.ChannelSMS,
+	}
+
+	if _, err := f.messageService.StoreMessage(ctx, smsMessage); err != nil {
+		return err
+	}
+
+	if err := f.messageService.LinkFallback(ctx, original.ID, smsMessage.ID); err != nil {
+		return err
+	}
+
+	logger.WithField("fallback_message_id", smsMessage.ID).Info("WhatsApp message re-sent via SMS")
+
+	return nil
+}
+
+// categoryAllowed checks a message category against the configured fallback categories
+func (f *FallbackService) categoryAllowed(category *string) bool {
+	for _, allowed := range f.config.SMSFallbackCategories {
+		if allowed == "*" {
+			return true
+		}
+		if category != nil && strings.EqualFold(allowed, *category) {
+			return true
+		}
+	}
+	return false
+}
+
+// smsBodyFromWhatsApp strips WhatsApp formatting markup that SMS would show literally
+func smsBodyFromWhatsApp(content string) string {
+	for _, re := range whatsappFormatting {
+		content = re.ReplaceAllString(content, "$1")
+	}
+	return strings.TrimSpace(content)
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
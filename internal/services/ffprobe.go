@@ -0,0 +1,86 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeDuration runs ffprobe on in-memory media and returns its duration
+func probeDuration(ctx context.Context, ffprobePath string, media []byte) (time.Duration, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		"-i", "pipe:0",
+	)
+	cmd.Stdin = bytes.NewReader(media)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse ffprobe duration: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// probedMedia is the part of ffprobe's JSON output read by probeMedia
+type probedMedia struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"streams"`
+}
+
+// probeMedia runs ffprobe on in-memory media and returns its duration and, for video,
+// the dimensions of its first video stream. Zero values mean ffprobe did not report them.
+func probeMedia(ctx context.Context, ffprobePath string, media []byte) (time.Duration, int, int, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-show_entries", "format=duration:stream=width,height",
+		"-select_streams", "v:0",
+		"-of", "json",
+		"-i", "pipe:0",
+	)
+	cmd.Stdin = bytes.NewReader(media)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return 0, 0, 0, fmt.Errorf("ffprobe failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var probed probedMedia
+	if err := json.Unmarshal(stdout.Bytes(), &probed); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	var duration time.Duration
+	if seconds, err := strconv.ParseFloat(probed.Format.Duration, 64); err == nil {
+		duration = time.Duration(seconds * float64(time.Second))
+	}
+	var width, height int
+	if len(probed.Streams) > 0 {
+		width, height = probed.Streams[0].Width, probed.Streams[0].Height
+	}
+
+	return duration, width, height, nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+const (
+	// firstResponseSamples is how many recent reply latencies are kept per tenant
+	firstResponseSamples = 100
+	// firstResponseWait is how long an inbound message waits for a reply to be measured
+	firstResponseWait = 10 * time.Minute
+	// firstResponsePercentile is the share of recent replies that must arrive within the
+	// threshold for the acknowledgment to be suppressed
+	firstResponsePercentile = 0.9
+)
+
+var firstResponseAcksTotal = metrics.NewCounter("first_response_acks_total", "First-message acknowledgments, by outcome (sent, suppressed, failed)", "outcome")
+
+// FirstResponseService acknowledges the first inbound message of a new session while
+// the orchestrator prepares its reply. It learns how quickly replies follow forwarded
+// messages per tenant, and skips the acknowledgment when recent replies arrived within
+// the tenant's threshold. Latencies live in Redis; without enough samples it acknowledges.
+type FirstResponseService struct {
+	redis      *redis.Client
+	autoReply  *AutoReplyService
+	config     *config.Config
+	thresholds map[string]time.Duration // tenant -> threshold; 0 disables the tenant
+	logger     *logrus.Logger
+}
+
+// NewFirstResponseService creates a new first-response acknowledgment service
+func NewFirstResponseService(redisClient *redis.Client, autoReply *AutoReplyService, cfg *config.Config, logger *logrus.Logger) *FirstResponseService {
+	thresholds := make(map[string]time.Duration, len(cfg.FirstResponseAckTenants))
+	for tenant, value := range cfg.FirstResponseAckTenants {
+		if strings.EqualFold(value, "off") {
+			thresholds[tenant] = 0
+			continue
+		}
+		if threshold, err := time.ParseDuration(value); err == nil {
+			thresholds[tenant] = threshold
+		}
+	}
+
+	return &FirstResponseService{
+		redis:      redisClient,
+		autoReply:  autoReply,
+		config:     cfg,
+		thresholds: thresholds,
+		logger:     logger,
+	}
+}
+
+// ExpectReply starts measuring the reply latency of a forwarded inbound message. Only
+// the oldest unanswered message of a session is measured.
+func (s *FirstResponseService) ExpectReply(ctx context.Context, inbound *models.WhatsAppMessage) {
+	if inbound.SessionID == nil {
+		return
+	}
+	key := redisKey(s.config, fmt.Sprintf("ack:reply:%s", inbound.SessionID))
+	if err := s.redis.SetNX(ctx, key, inbound.CreatedAt.UnixMilli(), firstResponseWait).Err(); err != nil {
+		s.logger.WithError(err).WithField("message_id", inbound.ID).Warn("Failed to start reply latency measurement")
+	}
+}
+
+// ObserveReply records the latency of an outbound reply in a session that was waiting
+// for one
+func (s *FirstResponseService) ObserveReply(ctx context.Context, outbound *models.WhatsAppMessage) {
+	if outbound.SessionID == nil {
+		return
+	}
+	received, err := s.redis.GetDel(ctx, redisKey(s.config, fmt.Sprintf("ack:reply:%s", outbound.SessionID))).Int64()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			s.logger.WithError(err).WithField("message_id", outbound.ID).Warn("Failed to read reply latency measurement")
+		}
+		return
+	}
+
+	latency := outbound.CreatedAt.UnixMilli() - received
+	if latency < 0 {
+		return
+	}
+
+	key := redisKey(s.config, "ack:latency:"+messageTenant(outbound))
+	pipe := s.redis.TxPipeline()
+	pipe.LPush(ctx, key, latency)
+	pipe.LTrim(ctx, key, 0, firstResponseSamples-1)
+	pipe.Expire(ctx, key, 24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.WithError(err).Warn("Failed to record reply latency")
+	}
+}
+
+// AcknowledgeAsync sends the acknowledgment for the first message of a new session in
+// the background, unless the sender's tenant has it disabled or replies are fast enough
+func (s *FirstResponseService) AcknowledgeAsync(inbound *models.WhatsAppMessage) {
+	tenant := messageTenant(inbound)
+	threshold := s.threshold(tenant)
+	if threshold <= 0 {
+		return
+	}
+
+	go func() {
+		ctx := context.Background()
+		logger := s.logger.WithFields(logrus.Fields{"message_id": inbound.ID, "tenant": tenant})
+
+		if typical, ok := s.typicalLatency(ctx, tenant); ok && typical <= threshold {
+			firstResponseAcksTotal.Inc("suppressed")
+			logger.WithField("typical_latency", typical).Debug("Replies are fast; acknowledgment suppressed")
+			return
+		}
+
+		if _, err := s.autoReply.ReplyMessage(ctx, inbound, i18n.Message{Key: i18n.KeyAckReceived}); err != nil {
+			firstResponseAcksTotal.Inc("failed")
+			return
+		}
+		firstResponseAcksTotal.Inc("sent")
+	}()
+}
+
+// Helper methods
+
+// threshold returns the reply latency under which a tenant's acknowledgments are
+// suppressed; 0 when acknowledgments are disabled for the tenant
+func (s *FirstResponseService) threshold(tenant string) time.Duration {
+	if threshold, ok := s.thresholds[tenant]; ok {
+		return threshold
+	}
+	if !s.config.FirstResponseAckEnabled {
+		return 0
+	}
+	return s.config.FirstResponseAckThreshold
+}
+
+// typicalLatency returns the 90th percentile of a tenant's recent reply latencies, and
+// false when there are too few samples to tell
+func (s *FirstResponseService) typicalLatency(ctx context.Context, tenant string) (time.Duration, bool) {
+	values, err := s.redis.LRange(ctx, redisKey(s.config, "ack:latency:"+tenant), 0, -1).Result()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load reply latencies")
+		return 0, false
+	}
+	if len(values) == 0 || len(values) < s.config.FirstResponseAckMinSamples {
+		return 0, false
+	}
+
+	latencies := make([]int64, 0, len(values))
+	for _, value := range values {
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			latencies = append(latencies, ms)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0, false
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	index := int(float64(len(latencies)-1) * firstResponsePercentile)
+	return time.Duration(latencies[index]) * time.Millisecond, true
+}
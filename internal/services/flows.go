@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var flowResponsesTotal = metrics.NewCounter("flow_responses_total", "WhatsApp Flow submissions received, by outcome", "outcome")
+
+// FlowSender is implemented by providers that can send WhatsApp Flow messages
+type FlowSender interface {
+	SendFlowMessage(ctx context.Context, to, flowSID string, variables map[string]string) (*models.SendMessageResponse, error)
+}
+
+// interactiveReply is the interactive payload of an inbound reply; Flow submissions
+// arrive as type "nfm_reply"
+type interactiveReply struct {
+	Type     string `json:"type"`
+	NFMReply *struct {
+		Name         string          `json:"name"`
+		ResponseJSON json.RawMessage `json:"response_json"`
+	} `json:"nfm_reply"`
+}
+
+// ParseFlowResponse extracts the answers of a Flow submission from a webhook's
+// interactive data. It returns nil when the data is not a Flow submission.
+func ParseFlowResponse(raw string) (*models.FlowResponse, error) {
+	var reply interactiveReply
+	if err := json.Unmarshal([]byte(raw), &reply); err != nil {
+		flowResponsesTotal.Inc("invalid")
+		return nil, fmt.Errorf("failed to decode interactive data: %w", err)
+	}
+	if reply.Type != "nfm_reply" || reply.NFMReply == nil {
+		return nil, nil
+	}
+
+	// response_json is a JSON document encoded as a string, though some senders
+	// embed the object directly
+	document := []byte(reply.NFMReply.ResponseJSON)
+	var encoded string
+	if err := json.Unmarshal(document, &encoded); err == nil {
+		document = []byte(encoded)
+	}
+
+	answers := map[string]interface{}{}
+	if err := json.Unmarshal(document, &answers); err != nil {
+		flowResponsesTotal.Inc("invalid")
+		return nil, fmt.Errorf("failed to decode flow response: %w", err)
+	}
+
+	response := &models.FlowResponse{Name: reply.NFMReply.Name, Answers: answers}
+	if token, ok := answers["flow_token"].(string); ok {
+		response.FlowToken = token
+		delete(answers, "flow_token")
+	}
+
+	flowResponsesTotal.Inc("parsed")
+	return response, nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// frequencyCapScript counts a campaign message in the rolling window of its recipient,
+// a sorted set in KEYS[1] scored by send time. ARGV holds the time and window in
+// milliseconds, the cap and the member; it returns 0 when the message was counted, or 1
+// followed by the messages already in the window.
+var frequencyCapScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local used = redis.call('ZCARD', KEYS[1])
+if used >= tonumber(ARGV[3]) then
+	return {1, used}
+end
+redis.call('ZADD', KEYS[1], now, ARGV[4])
+redis.call('PEXPIRE', KEYS[1], window)
+return {0, used + 1}
+`)
+
+// FrequencyCapError is returned when a campaign message would exceed its recipient's
+// frequency cap
+type FrequencyCapError struct {
+	Cap    int           `json:"cap"`
+	Window time.Duration `json:"window"`
+}
+
+func (e *FrequencyCapError) Error() string {
+	return fmt.Sprintf("recipient reached the cap of %d campaign messages in %s", e.Cap, e.Window)
+}
+
+// FrequencyClaim is a campaign message counted in its recipient's window, to be
+// released if the message is not sent. A nil claim means the message was not capped.
+type FrequencyClaim struct {
+	key    string
+	member string
+	tenant string
+}
+
+// FrequencyCapService caps the campaign messages each recipient gets from a tenant across
+// all of the tenant's campaigns within a rolling CAMPAIGN_FREQUENCY_WINDOW. Messages whose
+// category is exempt from notification caps, e.g. transactional ones, are not counted.
+// Windows live in Redis, and Redis failures let messages through; daily rollups of the
+// counted and capped messages are kept in Postgres for reporting.
+type FrequencyCapService struct {
+	db     *pgxpool.Pool
+	redis  *redis.Client
+	config *config.Config
+	caps   map[string]int // tenant -> cap; 0 exempts the tenant
+	exempt map[string]bool
+	logger *logrus.Logger
+}
+
+// NewFrequencyCapService creates a new frequency cap service
+func NewFrequencyCapService(db *pgxpool.Pool, redisClient *redis.Client, cfg *config.Config, logger *logrus.Logger) *FrequencyCapService {
+	caps := make(map[string]int, len(cfg.CampaignFrequencyCapTenants))
+	for tenant, value := range cfg.CampaignFrequencyCapTenants {
+		if n, err := strconv.Atoi(value); err == nil {
+			caps[tenant] = n
+		}
+	}
+	exempt := make(map[string]bool, len(cfg.NotificationExemptCategories))
+	for _, category := range cfg.NotificationExemptCategories {
+		exempt[strings.ToLower(category)] = true
+	}
+
+	return &FrequencyCapService{
+		db:     db,
+		redis:  redisClient,
+		config: cfg,
+		caps:   caps,
+		exempt: exempt,
+		logger: logger,
+	}
+}
+
+// Claim counts the message of a campaign to a recipient against the recipient's cap. It
+// returns a *FrequencyCapError, after recording the capped message, when the cap is
+// reached.
+func (s *FrequencyCapService) Claim(ctx context.Context, campaign *models.Campaign, recipient string) (*FrequencyClaim, error) {
+	limit := s.capFor(campaign.Tenant)
+	if limit == 0 || s.exempt[categoryLabel(campaign.Category)] {
+		return nil, nil
+	}
+
+	claim := &FrequencyClaim{
+		key:    redisKey(s.config, fmt.Sprintf("campaign:freq:%s:%s:%s", campaign.Tenant, campaign.Channel, recipient)),
+		member: campaign.ID.String(),
+		tenant: campaign.Tenant,
+	}
+	window := s.config.CampaignFrequencyWindow
+
+	result, err := frequencyCapScript.Run(ctx, s.redis, []string{claim.key},
+		time.Now().UnixMilli(), window.Milliseconds(), limit, claim.member).Int64Slice()
+	if err != nil || len(result) != 2 {
+		s.logger.WithError(err).Warn("Failed to count campaign message against frequency cap, sending anyway")
+		return nil, nil
+	}
+	if result[0] == 1 {
+		s.rollup(ctx, campaign.Tenant, 0, 1)
+		return nil, &FrequencyCapError{Cap: limit, Window: window}
+	}
+	return claim, nil
+}
+
+// Commit records a claimed message as sent in the rollups
+func (s *FrequencyCapService) Commit(ctx context.Context, claim *FrequencyClaim) {
+	if claim == nil {
+		return
+	}
+	s.rollup(ctx, claim.tenant, 1, 0)
+}
+
+// Release takes a claimed message that was not sent out of its recipient's window
+func (s *FrequencyCapService) Release(ctx context.Context, claim *FrequencyClaim) {
+	if claim == nil {
+		return
+	}
+	if err := s.redis.ZRem(ctx, claim.key, claim.member).Err(); err != nil {
+		s.logger.WithError(err).WithField("key", claim.key).Warn("Failed to release frequency cap claim")
+	}
+}
+
+// Report sums the daily rollups between from and to, of one tenant or of all of them
+func (s *FrequencyCapService) Report(ctx context.Context, from, to time.Time, tenant *string) (*models.FrequencyCapReport, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT day, tenant, counted, capped FROM frequency_cap_rollups
+		WHERE day >= $1::date AND day <= $2::date AND ($3::text IS NULL OR tenant = $3)
+		ORDER BY day, tenant`, from.UTC().Format("2006-01-02"), to.UTC().Format("2006-01-02"), tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query frequency cap rollups: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.FrequencyCapReport{From: from, To: to, Days: []*models.FrequencyCapRollup{}}
+	if tenant != nil {
+		report.Tenant = *tenant
+	}
+	for rows.Next() {
+		var day models.FrequencyCapRollup
+		if err := rows.Scan(&day.Day, &day.Tenant, &day.Counted, &day.Capped); err != nil {
+			return nil, fmt.Errorf("failed to scan frequency cap rollup: %w", err)
+		}
+		report.Counted += day.Counted
+		report.Capped += day.Capped
+		report.Days = append(report.Days, &day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading frequency cap rollups: %w", err)
+	}
+
+	if total := report.Counted + report.Capped; total > 0 {
+		report.CappedRate = float64(report.Capped) / float64(total)
+	}
+	return report, nil
+}
+
+// Helper methods
+
+// capFor returns the cap of a tenant's campaigns
+func (s *FrequencyCapService) capFor(tenant string) int {
+	if limit, ok := s.caps[tenant]; ok {
+		return limit
+	}
+	return s.config.CampaignFrequencyCap
+}
+
+// rollup adds to today's counters of a tenant
+func (s *FrequencyCapService) rollup(ctx context.Context, tenant string, counted, capped int) {
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO frequency_cap_rollups (day, tenant, counted, capped)
+		VALUES ((NOW() AT TIME ZONE 'UTC')::date, $1, $2, $3)
+		ON CONFLICT (day, tenant) DO UPDATE
+		SET counted = frequency_cap_rollups.counted + EXCLUDED.counted,
+			capped = frequency_cap_rollups.capped + EXCLUDED.capped`,
+		tenant, counted, capped); err != nil {
+		s.logger.WithError(err).WithField("tenant", tenant).Warn("Failed to record frequency cap rollup")
+	}
+}
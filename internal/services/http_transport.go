@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Metrics of outbound HTTP connections
+var (
+	httpClientRequestsTotal  = metrics.NewCounter("http_client_requests_total", "Outbound HTTP requests by whether they reused a pooled connection and the protocol", "reused", "protocol")
+	httpClientDialsTotal     = metrics.NewCounter("http_client_dials_total", "New outbound TCP connections", "outcome")
+	dnsCacheLookupsTotal     = metrics.NewCounter("dns_cache_lookups_total", "Host lookups of outbound HTTP dials", "result")
+	egressProxyRequestsTotal = metrics.NewCounter("egress_proxy_requests_total", "Outbound HTTP requests sent through the egress proxy, by outcome", "outcome")
+)
+
+// sharedTransport is the transport of every downstream HTTP client; nil until
+// ConfigureHTTPTransport runs, in which case http.DefaultTransport is used
+var sharedTransport http.RoundTripper
+
+// egressTransport is the transport of the AI and media download clients, which send the
+// destinations of EGRESS_PROXY_HOSTS through EGRESS_PROXY_URL; nil when no egress proxy
+// is configured, in which case the shared transport is used
+var egressTransport http.RoundTripper
+
+// ConfigureHTTPTransport builds the transport shared by the downstream HTTP clients:
+// pooled keep-alive connections, HTTP/2 where the server supports it and cached DNS
+// lookups. It is called once at startup, before any service is created.
+func ConfigureHTTPTransport(cfg *config.Config) {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	resolver := &dnsCache{ttl: cfg.HTTPDNSCacheTTL, entries: make(map[string]dnsCacheEntry)}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           resolver.dialer(dialer),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.HTTPIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+
+	sharedTransport = &instrumentedTransport{base: transport}
+
+	egressTransport = nil
+	if proxyURL, err := url.Parse(cfg.EgressProxyURL); err == nil && cfg.EgressProxyURL != "" {
+		proxy := egressProxy(proxyURL, cfg.EgressProxyHosts)
+		egress := transport.Clone()
+		egress.Proxy = proxy
+		egressTransport = &instrumentedTransport{base: &egressProxyTransport{base: egress, proxy: proxy}}
+	}
+}
+
+// httpTransport returns the shared transport
+func httpTransport() http.RoundTripper {
+	if sharedTransport == nil {
+		return http.DefaultTransport
+	}
+	return sharedTransport
+}
+
+// newHTTPClient creates a client on the shared transport with its own timeout
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: httpTransport(),
+	}
+}
+
+// newEgressHTTPClient creates a client on the egress transport with its own timeout, for
+// partners that only accept requests from allowlisted IPs
+func newEgressHTTPClient(timeout time.Duration) *http.Client {
+	transport := egressTransport
+	if transport == nil {
+		transport = httpTransport()
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// CheckEgressProxy requests healthURL through the egress transport. It fails when the
+// proxy cannot be reached, or answers 407 or 502 as proxies do when they refuse a
+// request or cannot reach its destination; any other answer proves the path works.
+func CheckEgressProxy(ctx context.Context, healthURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, healthURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid egress proxy health URL: %w", err)
+	}
+
+	resp, err := newEgressHTTPClient(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("egress proxy health request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode == http.StatusBadGateway {
+		return fmt.Errorf("egress proxy answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// egressProxy returns the Proxy function of the egress transport: destinations matching
+// hosts go through proxyURL, every destination when hosts is empty. Other destinations
+// use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment like the shared transport.
+// A host rule is an exact host name, or a domain starting with "." or "*." that matches
+// its subdomains.
+func egressProxy(proxyURL *url.URL, hosts []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if len(hosts) == 0 {
+			return proxyURL, nil
+		}
+
+		host := strings.ToLower(req.URL.Hostname())
+		for _, rule := range hosts {
+			rule = strings.ToLower(strings.TrimPrefix(rule, "*"))
+			if host == rule || (strings.HasPrefix(rule, ".") && (strings.HasSuffix(host, rule) || host == rule[1:])) {
+				return proxyURL, nil
+			}
+		}
+		return http.ProxyFromEnvironment(req)
+	}
+}
+
+// egressProxyTransport counts the outcome of the requests it sends through the egress
+// proxy, telling failures to reach or authenticate with the proxy apart
+type egressProxyTransport struct {
+	base  http.RoundTripper
+	proxy func(*http.Request) (*url.URL, error)
+}
+
+// RoundTrip sends the request and records the proxy outcome of proxied requests
+func (t *egressProxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+
+	if proxyURL, proxyErr := t.proxy(req); proxyErr != nil || proxyURL == nil {
+		return resp, err
+	}
+
+	var opErr *net.OpError
+	switch {
+	case err != nil && errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		egressProxyRequestsTotal.Inc("connect_failed")
+	case err != nil && strings.Contains(err.Error(), http.StatusText(http.StatusProxyAuthRequired)):
+		egressProxyRequestsTotal.Inc("auth_failed")
+	case err != nil:
+		egressProxyRequestsTotal.Inc("error")
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		egressProxyRequestsTotal.Inc("auth_failed")
+	default:
+		egressProxyRequestsTotal.Inc("ok")
+	}
+	return resp, err
+}
+
+// instrumentedTransport counts whether requests reused a pooled connection
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sends the request and records how its connection was obtained
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return nil, err
+	}
+
+	// HTTP/2 requests share one connection, which GotConn reports as reused
+	httpClientRequestsTotal.Inc(strconv.FormatBool(reused), resp.Proto)
+	return resp, nil
+}
+
+// dnsCacheEntry is the cached addresses of a host
+type dnsCacheEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache keeps host lookups for ttl, so new connections to the same downstream do not
+// each wait for DNS. A ttl of 0 disables caching.
+type dnsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsCacheEntry
+}
+
+// dialer returns a DialContext that resolves hosts through the cache and tries each
+// address in turn; an entry whose addresses all fail is dropped
+func (d *dnsCache) dialer(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || d.ttl <= 0 || net.ParseIP(host) != nil {
+			return d.dial(ctx, dialer, network, address)
+		}
+
+		addrs, err := d.lookup(ctx, host)
+		if err != nil {
+			httpClientDialsTotal.Inc("error")
+			return nil, err
+		}
+
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := d.dial(ctx, dialer, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+
+		d.mu.Lock()
+		delete(d.entries, host)
+		d.mu.Unlock()
+		return nil, lastErr
+	}
+}
+
+// dial opens a connection and counts it
+func (d *dnsCache) dial(ctx context.Context, dialer *net.Dialer, network, address string) (net.Conn, error) {
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		httpClientDialsTotal.Inc("error")
+		return nil, err
+	}
+	httpClientDialsTotal.Inc("ok")
+	return conn, nil
+}
+
+// lookup returns the addresses of host, from the cache while they are fresh
+func (d *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[host]
+	d.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		dnsCacheLookupsTotal.Inc("hit")
+		return entry.addrs, nil
+	}
+
+	dnsCacheLookupsTotal.Inc("miss")
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	d.mu.Lock()
+	d.entries[host] = dnsCacheEntry{addrs: addrs, expires: time.Now().Add(d.ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
@@ -0,0 +1,650 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// Identity errors
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidMerge     = errors.New("invalid merge")
+	ErrIdentityConflict = errors.New("identity belongs to another user")
+	ErrInvalidLocale    = errors.New("invalid locale")
+	ErrInvalidTimezone  = errors.New("invalid time zone")
+)
+
+// IdentityService maps channel identifiers (phone variants, WaId, email, Meta and Telegram sender IDs) to canonical users
+type IdentityService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewIdentityService creates a new identity service instance
+func NewIdentityService(db *pgxpool.Pool, logger *logrus.Logger) *IdentityService {
+	return &IdentityService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Resolve returns the canonical user for an inbound sender, creating the user on first
+// contact and recording any identifiers not yet mapped
+func (s *IdentityService) Resolve(ctx context.Context, phoneNumber, profileName, waID string) (*models.User, error) {
+	phone := normalizePhoneNumber(phoneNumber)
+
+	userID, err := s.lookup(ctx, phoneVariants(phone), waID)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	if userID == uuid.Nil {
+		user, err = s.upsertUser(ctx, phone, profileName, waID)
+	} else {
+		user, err = s.refreshUser(ctx, userID, profileName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.attach(ctx, user.ID, models.IdentityKindPhone, phone)
+	if waID != "" {
+		s.attach(ctx, user.ID, models.IdentityKindWaID, waID)
+	}
+
+	return user, nil
+}
+
+// ResolveChannelUser returns the canonical user for a sender identified only by a
+// channel-scoped ID (Messenger PSID, Instagram ID, Telegram chat ID), creating a phone-less user on first contact
+func (s *IdentityService) ResolveChannelUser(ctx context.Context, kind, senderID, profileName string) (*models.User, error) {
+	userID, err := s.lookupIdentity(ctx, kind, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if userID != uuid.Nil {
+		return s.refreshUser(ctx, userID, profileName)
+	}
+
+	// The user and its identity are created together, so a request losing the race for the
+	// identity leaves no user without one behind
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin user creation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO whatsapp_users (id, profile_name, is_active, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), true, NOW(), NOW())
+		RETURNING ` + userColumns
+
+	user, err := scanUser(tx.QueryRow(ctx, query, uuid.New(), profileName))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create channel user")
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO user_identities (id, user_id, kind, value, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (kind, value) DO NOTHING`, uuid.New(), user.ID, kind, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record user identity: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		// A concurrent request created the user first; ours is rolled back
+		if err := tx.Rollback(ctx); err != nil {
+			return nil, fmt.Errorf("failed to discard user: %w", err)
+		}
+		userID, err := s.lookupIdentity(ctx, kind, senderID)
+		if err != nil {
+			return nil, err
+		}
+		if userID == uuid.Nil {
+			return nil, ErrUserNotFound
+		}
+		return s.refreshUser(ctx, userID, profileName)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit user creation: %w", err)
+	}
+
+	return user, nil
+}
+
+// LookupChannelUserID returns the canonical user ID for a channel-scoped sender ID without creating one
+func (s *IdentityService) LookupChannelUserID(ctx context.Context, kind, senderID string) (uuid.UUID, error) {
+	userID, err := s.lookupIdentity(ctx, kind, senderID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if userID == uuid.Nil {
+		return uuid.Nil, ErrUserNotFound
+	}
+	return userID, nil
+}
+
+// LookupUserID returns the canonical user ID for a phone number without creating one
+func (s *IdentityService) LookupUserID(ctx context.Context, phoneNumber string) (uuid.UUID, error) {
+	userID, err := s.lookup(ctx, phoneVariants(normalizePhoneNumber(phoneNumber)), "")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if userID == uuid.Nil {
+		return uuid.Nil, ErrUserNotFound
+	}
+	return userID, nil
+}
+
+// Locale returns the locale set for a user, or an empty string when none is set
+func (s *IdentityService) Locale(ctx context.Context, userID uuid.UUID) (string, error) {
+	var locale string
+	err := s.db.QueryRow(ctx, `SELECT COALESCE(locale, '') FROM whatsapp_users WHERE id = $1`, userID).Scan(&locale)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to load user locale: %w", err)
+	}
+	return locale, nil
+}
+
+// SetLocale sets the locale of a user's system-generated messages; an empty locale
+// reverts to the default
+func (s *IdentityService) SetLocale(ctx context.Context, userID uuid.UUID, locale string) (*models.User, error) {
+	var value *string
+	if locale != "" {
+		normalized, err := i18n.Normalize(locale)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLocale, err)
+		}
+		value = &normalized
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, `
+		UPDATE whatsapp_users SET locale = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns, userID, value))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update user locale: %w", err)
+	}
+	return user, nil
+}
+
+// SetTimezone sets the time zone campaign quiet hours are applied in for a user; an
+// empty time zone reverts to inferring it from the phone number
+func (s *IdentityService) SetTimezone(ctx context.Context, userID uuid.UUID, timezone string) (*models.User, error) {
+	var value *string
+	if timezone = strings.TrimSpace(timezone); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTimezone, err)
+		}
+		name := location.String()
+		value = &name
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, `
+		UPDATE whatsapp_users SET timezone = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns, userID, value))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update user time zone: %w", err)
+	}
+	return user, nil
+}
+
+// SetConsent replaces the notification categories a user opted in to. Categories are
+// lowercased and deduplicated; segments filtered on a consent category only include
+// users who gave it.
+func (s *IdentityService) SetConsent(ctx context.Context, userID uuid.UUID, categories []string) (*models.User, error) {
+	consented := make([]string, 0, len(categories))
+	seen := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category == "" || seen[category] {
+			continue
+		}
+		seen[category] = true
+		consented = append(consented, category)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, `
+		UPDATE whatsapp_users SET consent_categories = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns, userID, consented))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to update user consent: %w", err)
+	}
+	return user, nil
+}
+
+// LinkIdentity maps an identifier (e.g., an email address) to an existing user so
+// messages from it join that user's history. Linking an identifier already owned by
+// another user fails; merge the users instead.
+func (s *IdentityService) LinkIdentity(ctx context.Context, userID uuid.UUID, kind, value string) (*models.UserIdentity, error) {
+	value = NormalizeIdentityValue(kind, value)
+
+	var exists bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM whatsapp_users WHERE id = $1 AND merged_into IS NULL)`, userID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check user: %w", err)
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	owner, err := s.lookupIdentity(ctx, kind, value)
+	if err != nil {
+		return nil, err
+	}
+	if owner != uuid.Nil && owner != userID {
+		return nil, ErrIdentityConflict
+	}
+
+	identity := &models.UserIdentity{
+		ID:     uuid.New(),
+		UserID: userID,
+		Kind:   kind,
+		Value:  value,
+	}
+
+	query := `
+		INSERT INTO user_identities (id, user_id, kind, value, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (kind, value) DO UPDATE SET kind = EXCLUDED.kind
+		RETURNING id, user_id, created_at`
+
+	err = s.db.QueryRow(ctx, query, identity.ID, userID, kind, value).Scan(&identity.ID, &identity.UserID, &identity.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	if identity.UserID != userID {
+		// Claimed concurrently by someone else
+		return nil, ErrIdentityConflict
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"kind":    kind,
+	}).Info("Identity linked to user")
+
+	return identity, nil
+}
+
+// MergeUsers folds a duplicate user into a target user: identities, messages and sessions
+// move to the target, the source's active sessions are closed and the source is deactivated.
+// The merge is recorded in the audit log.
+func (s *IdentityService) MergeUsers(ctx context.Context, sourceID, targetID uuid.UUID, actor, role, clientIP string) (*models.MergeUsersResult, error) {
+	if sourceID == targetID {
+		return nil, fmt.Errorf("%w: source and target are the same user", ErrInvalidMerge)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin merge: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := mergeUsers(ctx, tx, sourceID, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  role,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionUsersMerged,
+		TargetType: "user",
+		TargetID:   targetID.String(),
+		Details:    mergeAuditDetails(result),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"source_user_id":   sourceID,
+		"target_user_id":   targetID,
+		"messages_moved":   result.MessagesMoved,
+		"identities_moved": result.IdentitiesMoved,
+	}).Info("Users merged")
+
+	return result, nil
+}
+
+// MergePhones repairs a conversation split across two numbers of the same person, e.g.
+// with and without the Brazilian ninth digit. The user owning the source number is
+// merged into the user owning the target number, so their messages and sessions move
+// over and later messages from either number reach the same conversation. The merge is
+// recorded in the audit log.
+func (s *IdentityService) MergePhones(ctx context.Context, request *models.MergePhonesRequest, actor, role, clientIP string) (*models.MergePhonesResult, error) {
+	sourcePhone := normalizePhoneNumber(request.SourcePhone)
+	targetPhone := normalizePhoneNumber(request.TargetPhone)
+	if sourcePhone == targetPhone {
+		return nil, fmt.Errorf("%w: source and target are the same number", ErrInvalidMerge)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin merge: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	owners := make([]uuid.UUID, 2)
+	for i, phone := range []string{sourcePhone, targetPhone} {
+		err := tx.QueryRow(ctx, `
+			SELECT COALESCE(u.merged_into, u.id)
+			FROM user_identities i
+			JOIN whatsapp_users u ON u.id = i.user_id
+			WHERE i.kind = $1 AND i.value = $2`, models.IdentityKindPhone, phone).Scan(&owners[i])
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("%w: no user has number %s", ErrUserNotFound, phone)
+			}
+			return nil, fmt.Errorf("failed to look up phone owner: %w", err)
+		}
+	}
+	if owners[0] == owners[1] {
+		return nil, fmt.Errorf("%w: both numbers already belong to user %s", ErrInvalidMerge, owners[0])
+	}
+
+	merged, err := mergeUsers(ctx, tx, owners[0], owners[1])
+	if err != nil {
+		return nil, err
+	}
+	result := &models.MergePhonesResult{
+		SourcePhone:      sourcePhone,
+		TargetPhone:      targetPhone,
+		MergeUsersResult: *merged,
+	}
+
+	details := mergeAuditDetails(merged)
+	details["source_phone"] = sourcePhone
+	details["target_phone"] = targetPhone
+	details["reason"] = request.Reason
+	err = recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  role,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionPhonesMerged,
+		TargetType: "user",
+		TargetID:   merged.TargetUserID.String(),
+		Details:    details,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit merge: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"source_user_id": merged.SourceUserID,
+		"target_user_id": merged.TargetUserID,
+		"messages_moved": merged.MessagesMoved,
+		"sessions_moved": merged.SessionsMoved,
+	}).Info("Phone numbers merged")
+
+	return result, nil
+}
+
+// mergeUsers moves everything of the source user to the target user within tx
+func mergeUsers(ctx context.Context, tx pgx.Tx, sourceID, targetID uuid.UUID) (*models.MergeUsersResult, error) {
+	// Lock both users so concurrent merges cannot interleave
+	var found int
+	err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM whatsapp_users
+			WHERE id IN ($1, $2) AND merged_into IS NULL
+			FOR UPDATE
+		) locked`, sourceID, targetID).Scan(&found)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+	if found != 2 {
+		return nil, ErrUserNotFound
+	}
+
+	result := &models.MergeUsersResult{
+		SourceUserID: sourceID,
+		TargetUserID: targetID,
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE chat_sessions
+		SET status = 'closed', ended_at = NOW(), close_reason = $2, updated_at = NOW()
+		WHERE user_id = $1 AND status = 'active'`, sourceID, models.SessionCloseReasonMerged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close source sessions: %w", err)
+	}
+	result.SessionsClosed = tag.RowsAffected()
+
+	steps := []struct {
+		query string
+		count *int64
+	}{
+		{`UPDATE user_identities SET user_id = $2 WHERE user_id = $1`, &result.IdentitiesMoved},
+		{`UPDATE whatsapp_messages SET user_id = $2, updated_at = NOW() WHERE user_id = $1`, &result.MessagesMoved},
+		{`UPDATE chat_sessions SET user_id = $2, updated_at = NOW() WHERE user_id = $1`, &result.SessionsMoved},
+		{`UPDATE conversation_archives SET user_id = $2 WHERE user_id = $1`, &result.ArchivesMoved},
+	}
+	for _, step := range steps {
+		tag, err := tx.Exec(ctx, step.query, sourceID, targetID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to move user records: %w", err)
+		}
+		*step.count = tag.RowsAffected()
+	}
+
+	// Users merged into the source earlier now point straight at the target
+	_, err = tx.Exec(ctx, `
+		UPDATE whatsapp_users
+		SET merged_into = $2, is_active = CASE WHEN id = $1 THEN false ELSE is_active END, updated_at = NOW()
+		WHERE id = $1 OR merged_into = $1`, sourceID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark user merged: %w", err)
+	}
+
+	return result, nil
+}
+
+// mergeAuditDetails describes a merge in an audit entry
+func mergeAuditDetails(result *models.MergeUsersResult) map[string]interface{} {
+	return map[string]interface{}{
+		"source_user_id":   result.SourceUserID,
+		"identities_moved": result.IdentitiesMoved,
+		"messages_moved":   result.MessagesMoved,
+		"sessions_moved":   result.SessionsMoved,
+		"sessions_closed":  result.SessionsClosed,
+		"archives_moved":   result.ArchivesMoved,
+	}
+}
+
+// Helper methods
+
+// lookup finds the canonical user owning any of the given identifiers
+func (s *IdentityService) lookup(ctx context.Context, phones []string, waID string) (uuid.UUID, error) {
+	query := `
+		SELECT COALESCE(u.merged_into, u.id)
+		FROM user_identities i
+		JOIN whatsapp_users u ON u.id = i.user_id
+		WHERE (i.kind = 'phone' AND i.value = ANY($1))
+			OR (i.kind = 'wa_id' AND i.value = NULLIF($2, ''))
+		ORDER BY i.created_at
+		LIMIT 1`
+
+	var userID uuid.UUID
+	err := s.db.QueryRow(ctx, query, phones, waID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	return userID, nil
+}
+
+// lookupIdentity finds the canonical user owning a single identifier
+func (s *IdentityService) lookupIdentity(ctx context.Context, kind, value string) (uuid.UUID, error) {
+	query := `
+		SELECT COALESCE(u.merged_into, u.id)
+		FROM user_identities i
+		JOIN whatsapp_users u ON u.id = i.user_id
+		WHERE i.kind = $1 AND i.value = $2`
+
+	var userID uuid.UUID
+	err := s.db.QueryRow(ctx, query, kind, value).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	return userID, nil
+}
+
+// attach maps an identifier to a user; identifiers already mapped are left alone
+func (s *IdentityService) attach(ctx context.Context, userID uuid.UUID, kind, value string) {
+	query := `
+		INSERT INTO user_identities (id, user_id, kind, value, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (kind, value) DO NOTHING`
+
+	if _, err := s.db.Exec(ctx, query, uuid.New(), userID, kind, value); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"user_id": userID,
+			"kind":    kind,
+		}).Warn("Failed to record user identity")
+	}
+}
+
+// upsertUser creates the user for a phone number or refreshes their profile
+func (s *IdentityService) upsertUser(ctx context.Context, phoneNumber, profileName, waID string) (*models.User, error) {
+	query := `
+		INSERT INTO whatsapp_users (id, phone_number, whatsapp_id, profile_name, is_active, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), true, NOW(), NOW())
+		ON CONFLICT (phone_number) DO UPDATE SET
+			whatsapp_id = COALESCE(EXCLUDED.whatsapp_id, whatsapp_users.whatsapp_id),
+			profile_name = COALESCE(EXCLUDED.profile_name, whatsapp_users.profile_name),
+			updated_at = NOW()
+		RETURNING ` + userColumns
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, uuid.New(), phoneNumber, waID, profileName))
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to upsert WhatsApp user")
+		return nil, fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	return user, nil
+}
+
+// refreshUser updates the profile name of a resolved user
+func (s *IdentityService) refreshUser(ctx context.Context, userID uuid.UUID, profileName string) (*models.User, error) {
+	query := `
+		UPDATE whatsapp_users
+		SET profile_name = COALESCE(NULLIF($2, ''), profile_name), updated_at = NOW()
+		WHERE id = $1
+		RETURNING ` + userColumns
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, userID, profileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh user: %w", err)
+	}
+
+	return user, nil
+}
+
+// userColumns lists the whatsapp_users columns in the order scanUser expects
+const userColumns = `id, COALESCE(phone_number, ''), COALESCE(whatsapp_id, ''), COALESCE(profile_name, ''),
+			is_active, created_at, updated_at, COALESCE(locale, ''), consent_categories, COALESCE(timezone, '')`
+
+// scanUser scans a whatsapp_users row selected with userColumns
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID,
+		&user.PhoneNumber,
+		&user.WhatsAppID,
+		&user.ProfileName,
+		&user.IsActive,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Locale,
+		&user.ConsentCategories,
+		&user.Timezone,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// NormalizeIdentityValue puts an identifier in the form it is stored under
+func NormalizeIdentityValue(kind, value string) string {
+	switch kind {
+	case models.IdentityKindPhone:
+		return normalizePhoneNumber(value)
+	case models.IdentityKindEmail:
+		return strings.ToLower(strings.TrimSpace(value))
+	default:
+		return strings.TrimSpace(value)
+	}
+}
+
+// phoneVariants returns a phone number together with its equivalent forms. Brazilian
+// mobile numbers are reachable with and without the ninth digit added in 2012-2016,
+// so "+55 11 9xxxx-xxxx" and "+55 11 xxxx-xxxx" refer to the same person.
+func phoneVariants(phone string) []string {
+	variants := []string{phone}
+
+	if !strings.HasPrefix(phone, "+55") {
+		return variants
+	}
+
+	national := strings.TrimPrefix(phone, "+55")
+	switch {
+	case len(national) == 11 && national[2] == '9':
+		// Area code + 9 + eight-digit subscriber number
+		variants = append(variants, "+55"+national[:2]+national[3:])
+	case len(national) == 10 && national[2] >= '6':
+		// Area code + legacy eight-digit mobile number
+		variants = append(variants, "+55"+national[:2]+"9"+national[2:])
+	}
+
+	return variants
+}
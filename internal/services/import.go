@@ -0,0 +1,542 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// messagesImportedTotal counts imported records by outcome
+var messagesImportedTotal = metrics.NewCounter("messages_imported_total", "Historical message records processed by the import API by outcome", "outcome")
+
+var (
+	// ErrInvalidImport is returned when an import payload cannot be read at all
+	ErrInvalidImport = errors.New("invalid import")
+
+	// ErrImportTooLarge is returned when an import holds more records than allowed
+	ErrImportTooLarge = errors.New("import too large")
+
+	// ErrImportSourceNotAllowed is returned for S3 objects outside the allowed buckets
+	ErrImportSourceNotAllowed = errors.New("import source not allowed")
+)
+
+const (
+	// importBatchSize is how many messages one transaction inserts
+	importBatchSize = 500
+
+	// maxImportErrors caps the record errors returned with a result
+	maxImportErrors = 100
+
+	// maxImportLineBytes bounds one JSONL line
+	maxImportLineBytes = 1024 * 1024
+
+	// importSIDPrefix marks the provider IDs of imported messages, so they cannot
+	// collide with the IDs of live messages
+	importSIDPrefix = "import:"
+)
+
+// importableTypes are the message types the whatsapp_messages type check accepts
+var importableTypes = map[models.MessageType]bool{
+	models.MessageTypeText:     true,
+	models.MessageTypeImage:    true,
+	models.MessageTypeDocument: true,
+	models.MessageTypeAudio:    true,
+	models.MessageTypeVideo:    true,
+	models.MessageTypeLocation: true,
+	models.MessageTypeContact:  true,
+}
+
+// importableStatuses are the statuses an imported message may carry
+var importableStatuses = map[models.MessageStatus]bool{
+	models.MessageStatusSent:      true,
+	models.MessageStatusDelivered: true,
+	models.MessageStatusRead:      true,
+	models.MessageStatusFailed:    true,
+}
+
+// ImportService imports historical conversations, such as the export of a previous
+// provider, into whatsapp_messages. Messages keep their original timestamps, are marked
+// with imported_at and are linked to the users of their customer numbers. Importing the
+// same records again skips the ones already stored.
+type ImportService struct {
+	db              *pgxpool.Pool
+	s3Client        *s3.Client
+	identityService *IdentityService
+	config          *appConfig.Config
+	logger          *logrus.Logger
+}
+
+// NewImportService creates a new import service
+func NewImportService(db *pgxpool.Pool, identityService *IdentityService, cfg *appConfig.Config, logger *logrus.Logger) (*ImportService, error) {
+	awsConfig, err := config.LoadDefaultConfig(context.TODO(),
+		config.WithRegion(cfg.AWSRegion),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &ImportService{
+		db:              db,
+		s3Client:        s3.NewFromConfig(awsConfig),
+		identityService: identityService,
+		config:          cfg,
+		logger:          logger,
+	}, nil
+}
+
+// Import reads JSONL or CSV records from r and stores the valid ones. With dryRun the
+// records are only validated and checked for duplicates.
+func (s *ImportService) Import(ctx context.Context, r io.Reader, format string, dryRun bool, actor, role, clientIP string) (*models.ImportResult, error) {
+	return s.importRecords(ctx, r, format, "upload", dryRun, actor, role, clientIP)
+}
+
+// ImportFromS3 imports the records of an S3 object in one of the allowed buckets
+func (s *ImportService) ImportFromS3(ctx context.Context, request *models.ImportS3Request, dryRun bool, actor, role, clientIP string) (*models.ImportResult, error) {
+	location, err := url.Parse(request.S3URI)
+	if err != nil || location.Scheme != "s3" || location.Host == "" || strings.Trim(location.Path, "/") == "" {
+		return nil, fmt.Errorf("%w: s3_uri must look like s3://bucket/key", ErrInvalidImport)
+	}
+	bucket, key := location.Host, strings.TrimPrefix(location.Path, "/")
+
+	allowed := false
+	for _, name := range s.config.ImportBuckets {
+		if name == bucket {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: bucket %s", ErrImportSourceNotAllowed, bucket)
+	}
+
+	format := request.Format
+	if format == "" {
+		format = strings.TrimPrefix(path.Ext(key), ".")
+	}
+
+	object, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch import object %s: %w", request.S3URI, err)
+	}
+	defer object.Body.Close()
+
+	return s.importRecords(ctx, object.Body, format, request.S3URI, dryRun, actor, role, clientIP)
+}
+
+// importRecord is a validated record and the line it came from
+type importRecord struct {
+	line    int
+	message *models.WhatsAppMessage
+}
+
+// importRecords validates every record of r, then stores the valid ones
+func (s *ImportService) importRecords(ctx context.Context, r io.Reader, format, source string, dryRun bool, actor, role, clientIP string) (*models.ImportResult, error) {
+	result := &models.ImportResult{DryRun: dryRun, Errors: []models.ImportError{}}
+	fail := func(line int, err error) {
+		result.Invalid++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, models.ImportError{Line: line, Error: err.Error()})
+		}
+	}
+
+	now := time.Now().UTC()
+	var cutoff time.Time
+	if s.config.MessageRetentionMonths > 0 {
+		// Partitions before the cutoff are dropped by maintenance, taking imports with them
+		cutoff = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -s.config.MessageRetentionMonths, 0)
+	}
+
+	var records []importRecord
+	err := readImportRecords(r, format, func(line int, record *models.ImportRecord, err error) error {
+		result.Records++
+		if result.Records > s.config.ImportMaxMessages {
+			return fmt.Errorf("%w: more than %d records", ErrImportTooLarge, s.config.ImportMaxMessages)
+		}
+		if err == nil {
+			var message *models.WhatsAppMessage
+			if message, err = importMessage(record, now, cutoff); err == nil {
+				records = append(records, importRecord{line: line, message: message})
+				return nil
+			}
+		}
+		fail(line, err)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Duplicates within the payload are dropped here, those already stored on insert
+	seen := make(map[string]bool, len(records))
+	unique := records[:0]
+	for _, record := range records {
+		if seen[record.message.TwilioSID] {
+			result.Duplicates++
+			continue
+		}
+		seen[record.message.TwilioSID] = true
+		unique = append(unique, record)
+	}
+	records = unique
+
+	if dryRun {
+		for _, record := range records {
+			var exists bool
+			if err := s.db.QueryRow(ctx, messageSIDExistsQuery, record.message.TwilioSID).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("failed to check imported message: %w", err)
+			}
+			if exists {
+				result.Duplicates++
+			}
+		}
+	} else if len(records) > 0 {
+		if err := s.store(ctx, records, result); err != nil {
+			return nil, err
+		}
+	}
+
+	messagesImportedTotal.Add(float64(result.Imported), "imported")
+	messagesImportedTotal.Add(float64(result.Duplicates), "duplicate")
+	messagesImportedTotal.Add(float64(result.Invalid), "invalid")
+
+	s.logger.WithFields(logrus.Fields{
+		"source":     source,
+		"format":     format,
+		"dry_run":    dryRun,
+		"records":    result.Records,
+		"imported":   result.Imported,
+		"duplicates": result.Duplicates,
+		"invalid":    result.Invalid,
+		"admin":      actor,
+	}).Info("Imported historical messages")
+
+	if dryRun || result.Imported == 0 {
+		return result, nil
+	}
+
+	err = recordAudit(ctx, s.db, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  role,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionMessagesImported,
+		TargetType: "import",
+		TargetID:   source,
+		Details: map[string]interface{}{
+			"format":     format,
+			"records":    result.Records,
+			"imported":   result.Imported,
+			"duplicates": result.Duplicates,
+			"invalid":    result.Invalid,
+			"users":      result.Users,
+		},
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to audit message import")
+	}
+
+	return result, nil
+}
+
+// store links the records to their users, creates the partitions they fall in and
+// inserts them in batches, skipping messages already stored
+func (s *ImportService) store(ctx context.Context, records []importRecord, result *models.ImportResult) error {
+	oldest, newest := records[0].message.Timestamp, records[0].message.Timestamp
+	for _, record := range records {
+		if record.message.Timestamp.Before(oldest) {
+			oldest = record.message.Timestamp
+		}
+		if record.message.Timestamp.After(newest) {
+			newest = record.message.Timestamp
+		}
+	}
+	// An unpartitioned table takes messages of any date
+	created, err := database.EnsureMessagePartitionRange(ctx, s.db, oldest, newest)
+	if err != nil && !errors.Is(err, database.ErrMessagesNotPartitioned) {
+		return err
+	}
+	if len(created) > 0 {
+		messagePartitionsChanged.Add(float64(len(created)), "created")
+		s.logger.WithField("partitions", created).Info("Created message partitions for imported history")
+	}
+
+	users := make(map[string]uuid.UUID)
+	for _, record := range records {
+		phone := record.message.From
+		if record.message.Direction == models.MessageDirectionOutbound {
+			phone = record.message.To
+		}
+		phone = normalizePhoneNumber(phone)
+
+		userID, ok := users[phone]
+		if !ok {
+			user, err := s.identityService.Resolve(ctx, phone, "", "")
+			if err != nil {
+				return fmt.Errorf("failed to resolve user of %s: %w", phone, err)
+			}
+			userID = user.ID
+			users[phone] = userID
+		}
+		record.message.UserID = &userID
+	}
+	result.Users = len(users)
+
+	for start := 0; start < len(records); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(records) {
+			end = len(records)
+		}
+		imported, err := s.storeBatch(ctx, records[start:end])
+		if err != nil {
+			return err
+		}
+		result.Imported += imported
+		result.Duplicates += end - start - imported
+	}
+
+	return nil
+}
+
+// storeBatch inserts one batch of messages in a transaction and returns how many were new
+func (s *ImportService) storeBatch(ctx context.Context, records []importRecord) (int, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin import batch: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	imported := 0
+	for _, record := range records {
+		// Serialized with StoreMessage on the same SID, see there
+		if _, err := tx.Exec(ctx, lockMessageSIDQuery, record.message.TwilioSID); err != nil {
+			return 0, fmt.Errorf("failed to lock message SID: %w", err)
+		}
+
+		var exists bool
+		if err := tx.QueryRow(ctx, messageSIDExistsQuery, record.message.TwilioSID).Scan(&exists); err != nil {
+			return 0, fmt.Errorf("failed to check imported message: %w", err)
+		}
+		if exists {
+			continue
+		}
+
+		if _, err := tx.Exec(ctx, insertMessageQuery, messageRows.Values(record.message, messageInsertColumns...)...); err != nil {
+			return 0, fmt.Errorf("failed to import message of line %d: %w", record.line, err)
+		}
+		imported++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit import batch: %w", err)
+	}
+	return imported, nil
+}
+
+// readImportRecords calls fn with every record of r, or the error that made it
+// unreadable; an error returned by fn stops reading
+func readImportRecords(r io.Reader, format string, fn func(line int, record *models.ImportRecord, err error) error) error {
+	switch format {
+	case models.ImportFormatJSONL, "ndjson":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxImportLineBytes)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var record models.ImportRecord
+			err := json.Unmarshal(scanner.Bytes(), &record)
+			if err != nil {
+				err = fmt.Errorf("invalid JSON: %v", err)
+			}
+			if err := fn(line, &record, err); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalidImport, line+1, err)
+		}
+		return nil
+
+	case models.ImportFormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("%w: failed to read CSV header: %v", ErrInvalidImport, err)
+		}
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, required := range []string{"direction", "from", "to", "timestamp"} {
+			if _, ok := columns[required]; !ok {
+				return fmt.Errorf("%w: CSV header is missing the %s column", ErrInvalidImport, required)
+			}
+		}
+
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if !errors.As(err, &parseErr) {
+					return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+				}
+				if err := fn(parseErr.Line, nil, err); err != nil {
+					return err
+				}
+				continue
+			}
+			line, _ := reader.FieldPos(0)
+
+			field := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(row) {
+					return strings.TrimSpace(row[i])
+				}
+				return ""
+			}
+			record := &models.ImportRecord{
+				ExternalID: field("external_id"),
+				Channel:    models.Channel(field("channel")),
+				Direction:  field("direction"),
+				From:       field("from"),
+				To:         field("to"),
+				Type:       field("type"),
+				Content:    field("content"),
+				MediaURL:   field("media_url"),
+				MediaType:  field("media_type"),
+				Status:     field("status"),
+			}
+			if value := field("timestamp"); value != "" {
+				if record.Timestamp, err = time.Parse(time.RFC3339, value); err != nil {
+					err = fmt.Errorf("timestamp must be RFC 3339, got %q", value)
+				}
+			}
+			if err := fn(line, record, err); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%w: unsupported format %q, use jsonl or csv", ErrInvalidImport, format)
+	}
+}
+
+// importMessage validates a record and builds the message it is stored as. Records
+// older than cutoff are refused, a zero cutoff accepts any past timestamp.
+func importMessage(record *models.ImportRecord, now, cutoff time.Time) (*models.WhatsAppMessage, error) {
+	channel := record.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+	if channel != models.ChannelWhatsApp && channel != models.ChannelSMS {
+		return nil, fmt.Errorf("channel must be whatsapp or sms, got %q", channel)
+	}
+
+	direction := models.MessageDirection(record.Direction)
+	if direction != models.MessageDirectionInbound && direction != models.MessageDirectionOutbound {
+		return nil, fmt.Errorf("direction must be inbound or outbound, got %q", record.Direction)
+	}
+
+	from, to := normalizePhoneNumber(record.From), normalizePhoneNumber(record.To)
+	if from == "" || to == "" {
+		return nil, errors.New("from and to are required")
+	}
+	if channel == models.ChannelWhatsApp {
+		from, to = "whatsapp:"+from, "whatsapp:"+to
+	}
+
+	messageType := models.MessageType(record.Type)
+	if messageType == "" {
+		messageType = models.MessageTypeText
+	}
+	if !importableTypes[messageType] {
+		return nil, fmt.Errorf("unsupported message type %q", record.Type)
+	}
+
+	status := models.MessageStatus(record.Status)
+	if status == "" {
+		status = models.MessageStatusDelivered
+	}
+	if !importableStatuses[status] {
+		return nil, fmt.Errorf("unsupported status %q", record.Status)
+	}
+
+	if record.Content == "" && record.MediaURL == "" {
+		return nil, errors.New("content or media_url is required")
+	}
+
+	switch {
+	case record.Timestamp.IsZero():
+		return nil, errors.New("timestamp is required")
+	case record.Timestamp.After(now):
+		return nil, errors.New("timestamp is in the future")
+	case !cutoff.IsZero() && record.Timestamp.Before(cutoff):
+		return nil, fmt.Errorf("timestamp is before the retention cutoff %s", cutoff.Format(time.RFC3339))
+	}
+	timestamp := record.Timestamp.UTC()
+
+	sid := record.ExternalID
+	if sid == "" {
+		// Without a provider ID the record is identified by its contents
+		hash := sha256.Sum256([]byte(strings.Join([]string{
+			string(channel), string(direction), from, to, timestamp.Format(time.RFC3339Nano), record.Content, record.MediaURL,
+		}, "\x00")))
+		sid = hex.EncodeToString(hash[:16])
+	}
+
+	message := &models.WhatsAppMessage{
+		ID:         uuid.New(),
+		TwilioSID:  importSIDPrefix + sid,
+		From:       from,
+		To:         to,
+		Direction:  direction,
+		Type:       messageType,
+		Status:     status,
+		Content:    record.Content,
+		Timestamp:  timestamp,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Channel:    channel,
+		Metadata:   models.Metadata{},
+		ImportedAt: &now,
+	}
+	if record.MediaURL != "" {
+		message.MediaURL = &record.MediaURL
+	}
+	if record.MediaType != "" {
+		message.MediaType = &record.MediaType
+	}
+
+	return message, nil
+}
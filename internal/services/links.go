@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrLinkNotFound is returned when a short link code does not exist
+var ErrLinkNotFound = errors.New("link not found")
+
+const (
+	linkCodeLength   = 8
+	linkCodeAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// urlPattern matches http(s) URLs embedded in free text
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// LinkService handles outbound link shortening and click tracking
+type LinkService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+	domain string
+}
+
+// NewLinkService creates a new link service instance
+func NewLinkService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *LinkService {
+	return &LinkService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+		domain: strings.TrimSuffix(cfg.LinkShortenerDomain, "/"),
+	}
+}
+
+// ShortenLinks replaces every URL in content with a tracked short link.
+// The returned links are not yet bound to a message; call AttachToMessage
+// once the outbound message has been stored.
+func (l *LinkService) ShortenLinks(ctx context.Context, content string) (string, []*models.TrackedLink, error) {
+	if !l.config.LinkTrackingEnabled || content == "" {
+		return content, nil, nil
+	}
+
+	var links []*models.TrackedLink
+	var shortenErr error
+	shortened := make(map[string]string)
+
+	content = urlPattern.ReplaceAllStringFunc(content, func(match string) string {
+		if shortenErr != nil {
+			return match
+		}
+
+		// Trailing punctuation is almost always sentence text, not part of the URL
+		rawURL := strings.TrimRight(match, ".,;:!?)")
+		suffix := match[len(rawURL):]
+
+		if strings.HasPrefix(rawURL, l.domain+"/") {
+			return match
+		}
+		if short, done := shortened[rawURL]; done {
+			return short + suffix
+		}
+
+		link, err := l.createLink(ctx, rawURL)
+		if err != nil {
+			shortenErr = err
+			return match
+		}
+
+		shortened[rawURL] = link.ShortURL
+		links = append(links, link)
+		return link.ShortURL + suffix
+	})
+
+	if shortenErr != nil {
+		return "", nil, shortenErr
+	}
+	if len(links) == 0 {
+		return content, nil, nil
+	}
+
+	l.logger.WithField("links_shortened", len(links)).Debug("Outbound links shortened")
+	return content, links, nil
+}
+
+// AttachToMessage binds previously created links to the message that carried them
+func (l *LinkService) AttachToMessage(ctx context.Context, links []*models.TrackedLink, messageID uuid.UUID) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ID)
+		link.MessageID = &messageID
+	}
+
+	query := `UPDATE tracked_links SET message_id = $1 WHERE id = ANY($2)`
+	if _, err := l.db.Exec(ctx, query, messageID, ids); err != nil {
+		l.logger.WithError(err).Error("Failed to attach tracked links to message")
+		return fmt.Errorf("failed to attach links: %w", err)
+	}
+
+	return nil
+}
+
+// RecordClick registers a click on a short link and returns the destination URL
+func (l *LinkService) RecordClick(ctx context.Context, code string, click *models.LinkClick) (string, error) {
+	var linkID uuid.UUID
+	var originalURL string
+
+	query := `SELECT id, original_url FROM tracked_links WHERE code = $1`
+	if err := l.db.QueryRow(ctx, query, code).Scan(&linkID, &originalURL); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrLinkNotFound
+		}
+		return "", fmt.Errorf("failed to look up link: %w", err)
+	}
+
+	click.ID = uuid.New()
+	click.LinkID = linkID
+	click.ClickedAt = time.Now()
+
+	insert := `
+		INSERT INTO link_clicks (id, link_id, clicked_at, ip_address, user_agent, referer)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	if _, err := l.db.Exec(ctx, insert,
+		click.ID,
+		click.LinkID,
+		click.ClickedAt,
+		click.IPAddress,
+		click.UserAgent,
+		click.Referer,
+	); err != nil {
+		// Never block the redirect because analytics failed
+		l.logger.WithError(err).WithField("code", code).Warn("Failed to record link click")
+	}
+
+	return originalURL, nil
+}
+
+// GetMessageClickStats returns click analytics for all links sent in a message
+func (l *LinkService) GetMessageClickStats(ctx context.Context, messageID uuid.UUID) (*models.MessageClickStats, error) {
+	query := `
+		SELECT l.code, l.original_url,
+			   COUNT(c.id), COUNT(DISTINCT c.ip_address),
+			   MIN(c.clicked_at), MAX(c.clicked_at)
+		FROM tracked_links l
+		LEFT JOIN link_clicks c ON c.link_id = l.id
+		WHERE l.message_id = $1
+		GROUP BY l.id, l.code, l.original_url
+		ORDER BY l.created_at`
+
+	rows, err := l.db.Query(ctx, query, messageID)
+	if err != nil {
+		l.logger.WithError(err).Error("Failed to query link click stats")
+		return nil, fmt.Errorf("failed to query click stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &models.MessageClickStats{
+		MessageID: messageID,
+		Links:     []models.LinkStats{},
+	}
+
+	for rows.Next() {
+		var linkStats models.LinkStats
+		if err := rows.Scan(
+			&linkStats.Code,
+			&linkStats.OriginalURL,
+			&linkStats.Clicks,
+			&linkStats.UniqueClicks,
+			&linkStats.FirstClickAt,
+			&linkStats.LastClickAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan click stats: %w", err)
+		}
+		linkStats.ShortURL = l.shortURL(linkStats.Code)
+		stats.TotalClicks += linkStats.Clicks
+		stats.Links = append(stats.Links, linkStats)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading click stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Helper methods
+
+// createLink persists a new tracked link with a unique short code
+func (l *LinkService) createLink(ctx context.Context, originalURL string) (*models.TrackedLink, error) {
+	const maxAttempts = 5
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		code, err := generateLinkCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate link code: %w", err)
+		}
+
+		link := &models.TrackedLink{
+			ID:          uuid.New(),
+			Code:        code,
+			OriginalURL: originalURL,
+			ShortURL:    l.shortURL(code),
+			CreatedAt:   time.Now(),
+		}
+
+		query := `
+			INSERT INTO tracked_links (id, code, original_url, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (code) DO NOTHING`
+
+		result, err := l.db.Exec(ctx, query, link.ID, link.Code, link.OriginalURL, link.CreatedAt)
+		if err != nil {
+			l.logger.WithError(err).Error("Failed to store tracked link")
+			return nil, fmt.Errorf("failed to store link: %w", err)
+		}
+
+		if result.RowsAffected() == 1 {
+			return link, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to allocate unique link code after %d attempts", maxAttempts)
+}
+
+// shortURL builds the public redirect URL for a code
+func (l *LinkService) shortURL(code string) string {
+	return fmt.Sprintf("%s/l/%s", l.domain, code)
+}
+
+// generateLinkCode returns a random, unambiguous short code
+func generateLinkCode() (string, error) {
+	max := big.NewInt(int64(len(linkCodeAlphabet)))
+	code := make([]byte, linkCodeLength)
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = linkCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrLocationRequestTemplate is returned when a WhatsApp location request has neither a
+// content template nor LOCATION_REQUEST_TEMPLATE_SID
+var ErrLocationRequestTemplate = errors.New("location request content template not configured")
+
+var liveLocationUpdatesTotal = metrics.NewCounter("live_location_updates_total", "Live location updates received, by outcome", "outcome")
+
+// LocationRequester is implemented by providers that can ask a user to share their
+// location with a tap. templateSID selects the content template where the provider
+// renders the request from one; other providers ignore it.
+type LocationRequester interface {
+	SendLocationRequest(ctx context.Context, to, content, templateSID string) (*models.SendMessageResponse, error)
+}
+
+// FormatCoordinates renders a position as the content of a location message
+func FormatCoordinates(latitude, longitude float64) string {
+	return strconv.FormatFloat(latitude, 'f', 6, 64) + "," + strconv.FormatFloat(longitude, 'f', 6, 64)
+}
+
+// LiveLocationService keeps the latest position of locations users share live. The live
+// location message is stored like any inbound message; updates move its coordinates.
+type LiveLocationService struct {
+	db             *pgxpool.Pool
+	messageService *MessageService
+	eventService   *EventService
+	logger         *logrus.Logger
+}
+
+// NewLiveLocationService creates a new live location service
+func NewLiveLocationService(db *pgxpool.Pool, messageService *MessageService, eventService *EventService, logger *logrus.Logger) *LiveLocationService {
+	return &LiveLocationService{
+		db:             db,
+		messageService: messageService,
+		eventService:   eventService,
+		logger:         logger,
+	}
+}
+
+// Update records a new position of a live location message and publishes a
+// location.updated event. Updates older than the recorded one, and updates of messages
+// not stored yet, are ignored; it reports whether the update was applied.
+func (s *LiveLocationService) Update(ctx context.Context, update *models.LiveLocationUpdate) (bool, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin live location update: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var message struct {
+		ID        uuid.UUID
+		SessionID *uuid.UUID
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT id, session_id FROM whatsapp_messages
+		WHERE twilio_sid = $1 AND channel = $2 AND direction = 'inbound' AND message_type = $3
+		LIMIT 1`, update.ProviderID, update.Channel, models.MessageTypeLiveLocation).Scan(&message.ID, &message.SessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			liveLocationUpdatesTotal.Inc("unknown")
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find live location message: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO live_locations (message_id, twilio_sid, latitude, longitude, live_period, sequence, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (message_id) DO UPDATE SET
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			live_period = EXCLUDED.live_period,
+			sequence = EXCLUDED.sequence,
+			updated_at = NOW()
+		WHERE live_locations.sequence < EXCLUDED.sequence`,
+		message.ID, update.ProviderID, update.Latitude, update.Longitude, update.LivePeriod, update.Sequence)
+	if err != nil {
+		return false, fmt.Errorf("failed to record live location: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		liveLocationUpdatesTotal.Inc("stale")
+		return false, nil
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE whatsapp_messages SET content = $2, updated_at = NOW()
+		WHERE id = $1`, message.ID, FormatCoordinates(update.Latitude, update.Longitude)); err != nil {
+		return false, fmt.Errorf("failed to update live location message: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit live location update: %w", err)
+	}
+	liveLocationUpdatesTotal.Inc("applied")
+	s.messageService.InvalidateCache(ctx, message.ID)
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventLocationUpdated,
+		MessageID: &message.ID,
+		SessionID: message.SessionID,
+		Data: map[string]interface{}{
+			"channel":     update.Channel,
+			"latitude":    update.Latitude,
+			"longitude":   update.Longitude,
+			"live_period": update.LivePeriod,
+			"sequence":    update.Sequence,
+		},
+	})
+
+	return true, nil
+}
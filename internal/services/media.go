@@ -5,6 +5,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 
 	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
@@ -21,14 +24,28 @@ import (
 
 // MediaService handles media file operations and storage
 type MediaService struct {
-	s3Client *s3.Client
-	config   *appConfig.Config
-	logger   *logrus.Logger
-	bucket   string
+	db         *pgxpool.Pool
+	s3Client   *s3.Client
+	httpClient *http.Client
+	ocr        OCRProvider
+	config     *appConfig.Config
+	logger     *logrus.Logger
+	bucket     string
+}
+
+// MediaProcessingResult carries the outputs of inbound media processing
+type MediaProcessingResult struct {
+	ExtractedText string
+	DocumentLike  bool // image carries enough text to be analyzed as a document
+	Duration      time.Duration
+	Violation     *MediaPolicyViolation // set when processing uncovered a policy breach
+	SHA256        string                // content hash, when media deduplication is enabled
+	Duplicate     bool                  // the same content was received before
+	Downloaded    bool                  // the media was fetched for processing
 }
 
 // NewMediaService creates a new media service instance
-func NewMediaService(cfg *appConfig.Config, logger *logrus.Logger) (*MediaService, error) {
+func NewMediaService(db *pgxpool.Pool, cfg *appConfig.Config, logger *logrus.Logger) (*MediaService, error) {
 	// Load AWS configuration
 	awsConfig, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(cfg.AWSRegion),
@@ -39,11 +56,19 @@ func NewMediaService(cfg *appConfig.Config, logger *logrus.Logger) (*MediaServic
 
 	s3Client := s3.NewFromConfig(awsConfig)
 
+	var ocr OCRProvider
+	if cfg.OCREnabled {
+		ocr = NewTesseractOCR(cfg.OCRTesseractPath, cfg.OCRLanguages)
+	}
+
 	return &MediaService{
-		s3Client: s3Client,
-		config:   cfg,
-		logger:   logger,
-		bucket:   cfg.S3BucketName,
+		db:         db,
+		s3Client:   s3Client,
+		httpClient: newEgressHTTPClient(60 * time.Second),
+		ocr:        ocr,
+		config:     cfg,
+		logger:     logger,
+		bucket:     cfg.S3BucketName,
 	}, nil
 }
 
@@ -70,13 +95,15 @@ func (m *MediaService) UploadMedia(ctx context.Context, file io.Reader, filename
 	}
 
 	// Upload to S3
-	_, err = m.s3Client.PutObject(ctx, &s3.PutObjectInput{
+	input := &s3.PutObjectInput{
 		Bucket:      aws.String(m.bucket),
 		Key:         aws.String(fileKey),
 		Body:        bytes.NewReader(buf.Bytes()),
 		ContentType: aws.String(contentType),
 		ACL:         "public-read", // Make file publicly accessible
-	})
+	}
+	applyObjectSettings(m.config, input, nil)
+	_, err = m.s3Client.PutObject(ctx, input)
 
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to upload file to S3")
@@ -95,13 +122,21 @@ func (m *MediaService) UploadMedia(ctx context.Context, file io.Reader, filename
 		"media_url": mediaURL,
 	}).Info("Media file uploaded successfully")
 
+	m.registerObject(ctx, &models.MediaObject{
+		Key:         fileKey,
+		URL:         mediaURL,
+		Source:      models.MediaSourceUpload,
+		ContentType: contentType,
+		SizeBytes:   int64(buf.Len()),
+	})
+
 	return mediaURL, nil
 }
 
 // ProcessMedia downloads and processes media files from incoming messages
-func (m *MediaService) ProcessMedia(ctx context.Context, message *models.WhatsAppMessage) error {
+func (m *MediaService) ProcessMedia(ctx context.Context, message *models.WhatsAppMessage) (*MediaProcessingResult, error) {
 	if message.MediaURL == nil {
-		return fmt.Errorf("no media URL provided")
+		return nil, fmt.Errorf("no media URL provided")
 	}
 
 	m.logger.WithFields(logrus.Fields{
@@ -119,32 +154,87 @@ func (m *MediaService) ProcessMedia(ctx context.Context, message *models.WhatsAp
 	// 5. Store in your own S3 bucket for long-term storage
 	// 6. Run AI analysis (image recognition, OCR, etc.)
 
+	result := &MediaProcessingResult{}
+	var data []byte
+	var err error
+
+	// Identical files are stored once and their earlier analysis reused
+	if m.dedupEnabled() {
+		data, err = m.downloadMedia(ctx, *message.MediaURL, m.maxBytesFor(*message.MediaType))
+		if err != nil {
+			return nil, err
+		}
+		result.Downloaded = true
+		var storeErr error
+		result.SHA256, result.Duplicate, storeErr = m.storeContent(ctx, message, data)
+		if storeErr != nil {
+			m.logger.WithError(storeErr).WithField("message_id", message.ID).Warn("Failed to store media by content hash")
+		}
+	}
+
 	switch {
 	case strings.HasPrefix(*message.MediaType, "image/"):
-		return m.processImage(ctx, message)
+		err = m.processImage(ctx, message, data, result)
 	case strings.HasPrefix(*message.MediaType, "video/"):
-		return m.processVideo(ctx, message)
+		err = m.processVideo(ctx, message)
 	case strings.HasPrefix(*message.MediaType, "audio/"):
-		return m.processAudio(ctx, message)
+		err = m.processAudio(ctx, message, data, result)
 	case strings.HasPrefix(*message.MediaType, "application/pdf"):
-		return m.processDocument(ctx, message)
+		err = m.processDocument(ctx, message)
 	default:
 		m.logger.WithField("media_type", *message.MediaType).Info("Unknown media type, skipping processing")
-		return nil
 	}
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// OCREnabled reports whether inbound images are run through OCR
+func (m *MediaService) OCREnabled() bool {
+	return m.ocr != nil
 }
 
-// processImage handles image file processing
-func (m *MediaService) processImage(ctx context.Context, message *models.WhatsAppMessage) error {
+// processImage handles image file processing; image is nil when not downloaded yet
+func (m *MediaService) processImage(ctx context.Context, message *models.WhatsAppMessage, image []byte, result *MediaProcessingResult) error {
 	m.logger.WithField("message_id", message.ID).Info("Processing image file")
 
 	// TODO: Implement image processing logic
-	// - Download image from Twilio URL
 	// - Generate thumbnails
 	// - Extract EXIF data
-	// - Run image recognition/OCR
 	// - Store processed results
 
+	if !m.OCREnabled() {
+		return nil
+	}
+
+	// Users often photograph contracts and IDs instead of sending PDFs
+	if image == nil {
+		var err error
+		image, err = m.downloadMedia(ctx, *message.MediaURL, int64(m.config.MediaMaxImageBytes))
+		if err != nil {
+			return err
+		}
+		result.Downloaded = true
+	}
+
+	text, err := m.ocr.ExtractText(ctx, image)
+	if err != nil {
+		m.logger.WithError(err).WithField("message_id", message.ID).Warn("OCR failed, treating image as a photo")
+		return nil
+	}
+
+	textChars := countTextChars(text)
+	result.ExtractedText = text
+	result.DocumentLike = textChars >= m.config.OCRDocumentMinChars
+
+	m.logger.WithFields(logrus.Fields{
+		"message_id":    message.ID,
+		"text_chars":    textChars,
+		"document_like": result.DocumentLike,
+	}).Info("OCR completed for image")
+
 	return nil
 }
 
@@ -162,17 +252,42 @@ func (m *MediaService) processVideo(ctx context.Context, message *models.WhatsAp
 	return nil
 }
 
-// processAudio handles audio file processing
-func (m *MediaService) processAudio(ctx context.Context, message *models.WhatsAppMessage) error {
+// processAudio handles audio file processing; audio is nil when not downloaded yet
+func (m *MediaService) processAudio(ctx context.Context, message *models.WhatsAppMessage, audio []byte, result *MediaProcessingResult) error {
 	m.logger.WithField("message_id", message.ID).Info("Processing audio file")
 
 	// TODO: Implement audio processing logic
-	// - Download audio from Twilio URL
 	// - Convert to standard format if needed
-	// - Extract audio metadata
-	// - Run speech-to-text conversion
 	// - Store processed results
 
+	if m.config.VoiceNoteMaxDuration <= 0 {
+		return nil
+	}
+
+	if audio == nil {
+		var err error
+		audio, err = m.downloadMedia(ctx, *message.MediaURL, int64(m.config.MediaMaxAudioBytes))
+		if err != nil {
+			return err
+		}
+		result.Downloaded = true
+	}
+
+	duration, err := probeDuration(ctx, m.config.FFprobePath, audio)
+	if err != nil {
+		// The transcription callback enforces the limit again with the reported duration
+		m.logger.WithError(err).WithField("message_id", message.ID).Warn("Could not determine voice note duration")
+		return nil
+	}
+
+	result.Duration = duration
+	result.Violation = m.CheckVoiceNoteDuration(*message.MediaType, duration)
+
+	m.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"duration":   duration.String(),
+	}).Info("Voice note duration determined")
+
 	return nil
 }
 
@@ -189,20 +304,35 @@ func (m *MediaService) processDocument(ctx context.Context, message *models.What
 	return nil
 }
 
-// GetMediaInfo retrieves metadata about a media file
-func (m *MediaService) GetMediaInfo(ctx context.Context, mediaURL string) (map[string]interface{}, error) {
-	m.logger.WithField("media_url", mediaURL).Info("Getting media info")
+// downloadMedia fetches a media file, refusing bodies larger than maxBytes
+func (m *MediaService) downloadMedia(ctx context.Context, mediaURL string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media download request: %w", err)
+	}
+	if isTwilioURL(mediaURL) {
+		req.SetBasicAuth(m.config.TwilioAccountSID, m.config.TwilioAuthToken)
+	}
 
-	// TODO: Implement media info extraction
-	// This would typically involve:
-	// - Downloading the file header
-	// - Extracting metadata without downloading the full file
-	// - Returning information like file size, dimensions, duration, etc.
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
 
-	return map[string]interface{}{
-		"url":       mediaURL,
-		"processed": false,
-	}, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, mediaStatusError("download", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media body: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("media exceeds %d bytes", maxBytes)
+	}
+
+	return data, nil
 }
 
 // DeleteMedia removes a media file from storage
@@ -230,5 +360,29 @@ func (m *MediaService) DeleteMedia(ctx context.Context, mediaURL string) error {
 	}
 
 	m.logger.WithField("key", key).Info("Media file deleted successfully")
+
+	if _, err := m.db.Exec(ctx, `DELETE FROM media_objects WHERE bucket = $1 AND object_key = $2`, m.bucket, key); err != nil {
+		m.logger.WithError(err).WithField("key", key).Warn("Failed to remove deleted media from the registry")
+	}
 	return nil
 }
+
+// IsStoredMedia reports whether a media URL points at an object in our media bucket
+func (m *MediaService) IsStoredMedia(mediaURL string) bool {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil {
+		return false
+	}
+	return parsed.Host == fmt.Sprintf("%s.s3.%s.amazonaws.com", m.bucket, m.config.AWSRegion)
+}
+
+// isTwilioURL reports whether a media URL is hosted by Twilio and needs account credentials;
+// media from other channels (e.g., Meta's CDN) must never receive them
+func isTwilioURL(mediaURL string) bool {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil {
+		return false
+	}
+	host := parsed.Hostname()
+	return host == "twilio.com" || strings.HasSuffix(host, ".twilio.com")
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var mediaDedupTotal = metrics.NewCounter("media_dedup_total", "Inbound media stored by content hash, by outcome", "outcome")
+
+// contentKey is the bucket key of a content-addressed media object
+func contentKey(hash string) string {
+	return fmt.Sprintf("whatsapp-media/sha256/%s", hash)
+}
+
+// dedupEnabled reports whether inbound media is stored once per content hash
+func (m *MediaService) dedupEnabled() bool {
+	return m.config.MediaDedupEnabled && m.bucket != ""
+}
+
+// storeContent stores downloaded inbound media under its SHA-256 unless an object with
+// the same content is already stored. It returns the hash and whether it was a duplicate.
+func (m *MediaService) storeContent(ctx context.Context, message *models.WhatsAppMessage, data []byte) (string, bool, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	existing, err := m.storedMediaByHash(ctx, hash)
+	if err != nil {
+		return hash, false, err
+	}
+	if existing != nil {
+		mediaDedupTotal.Inc("duplicate")
+		m.logger.WithField("message_id", message.ID).WithField("media_id", existing.ID).Info("Inbound media already stored")
+		return hash, true, nil
+	}
+
+	key := contentKey(hash)
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if message.MediaType != nil {
+		input.ContentType = aws.String(*message.MediaType)
+	}
+	applyObjectSettings(m.config, input, messageObjectTags(message))
+	if _, err := m.s3Client.PutObject(ctx, input); err != nil {
+		return hash, false, fmt.Errorf("failed to store media: %w", err)
+	}
+
+	object := &models.MediaObject{
+		Key:       key,
+		URL:       m.objectURL(key),
+		MessageID: &message.ID,
+		Source:    models.MediaSourceInbound,
+		SizeBytes: int64(len(data)),
+		SHA256:    &hash,
+	}
+	if message.MediaType != nil {
+		object.ContentType = *message.MediaType
+	}
+	m.registerObject(ctx, object)
+
+	mediaDedupTotal.Inc("stored")
+	return hash, false, nil
+}
+
+// storedMediaByHash returns the object stored for a content hash, or nil
+func (m *MediaService) storedMediaByHash(ctx context.Context, hash string) (*models.MediaObject, error) {
+	query := `SELECT` + mediaObjectColumns + mediaObjectsFrom + ` WHERE o.bucket = $1 AND o.sha256 = $2`
+
+	object, err := scanMediaObject(m.db.QueryRow(ctx, query, m.bucket, hash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find media by hash: %w", err)
+	}
+	return object, nil
+}
+
+// sharedWithOthers reports whether unredacted messages other than messageID carry the
+// content of a hashed object
+func (m *MediaService) sharedWithOthers(ctx context.Context, object *models.MediaObject, messageID uuid.UUID) (bool, error) {
+	if object.SHA256 == nil {
+		return false, nil
+	}
+
+	var shared bool
+	err := m.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM whatsapp_messages
+			WHERE media_sha256 = $1 AND id <> $2 AND redacted_at IS NULL
+		)`, *object.SHA256, messageID,
+	).Scan(&shared)
+	if err != nil {
+		return false, fmt.Errorf("failed to check shared media: %w", err)
+	}
+	return shared, nil
+}
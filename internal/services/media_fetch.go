@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var (
+	// ErrMediaUnavailable is returned when a media URL has expired or its media was deleted
+	ErrMediaUnavailable = errors.New("media is no longer available")
+	// ErrMediaAuthFailed is returned when the media host refuses our credentials
+	ErrMediaAuthFailed = errors.New("media host rejected our credentials")
+	// ErrMediaHostNotAllowed is returned when media is hosted neither by Twilio nor in our
+	// bucket, so the adapter will not fetch it
+	ErrMediaHostNotAllowed = errors.New("media host not allowed")
+)
+
+var mediaFetchFailuresTotal = metrics.NewCounter("media_fetch_failures_total", "Media fetches that failed, by reason", "reason")
+
+// mediaStatusError describes an unsuccessful media response. Twilio answers 404 once
+// media is deleted or has expired and 401 when the account credentials are wrong.
+func mediaStatusError(method string, status int) error {
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		mediaFetchFailuresTotal.Inc("unavailable")
+		return fmt.Errorf("%w: media %s returned status %d", ErrMediaUnavailable, method, status)
+	case http.StatusUnauthorized:
+		mediaFetchFailuresTotal.Inc("auth")
+		return fmt.Errorf("%w: media %s returned status %d", ErrMediaAuthFailed, method, status)
+	default:
+		mediaFetchFailuresTotal.Inc("status")
+		return fmt.Errorf("media %s returned status %d", method, status)
+	}
+}
+
+// checkFetchable refuses media URLs the API must not fetch on a caller's behalf. Outbound
+// messages carry caller-supplied URLs, so fetching any stored URL would let callers make
+// the adapter request arbitrary hosts, including internal ones. Only Twilio over HTTPS and
+// our own bucket are fetched.
+func (m *MediaService) checkFetchable(mediaURL string) error {
+	if m.IsStoredMedia(mediaURL) {
+		return nil
+	}
+	parsed, err := url.Parse(mediaURL)
+	if err == nil && parsed.Scheme == "https" && isTwilioURL(mediaURL) {
+		return nil
+	}
+	mediaFetchFailuresTotal.Inc("host")
+	return fmt.Errorf("%w: %s", ErrMediaHostNotAllowed, hostOf(mediaURL))
+}
+
+// hostOf returns the host of a URL for error messages, without its path or credentials
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	return parsed.Host
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+func TestCheckFetchable(t *testing.T) {
+	service := &MediaService{bucket: "re9-media", config: &config.Config{AWSRegion: "us-east-1"}}
+
+	tests := []struct {
+		name     string
+		mediaURL string
+		allowed  bool
+	}{
+		{"twilio media", "https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME1", true},
+		{"our bucket", "https://re9-media.s3.us-east-1.amazonaws.com/whatsapp-media/2026/10/01/a.jpg", true},
+		{"twilio over http", "http://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME1", false},
+		{"twilio lookalike", "https://api.twilio.com.attacker.example/media.jpg", false},
+		{"another bucket", "https://other.s3.us-east-1.amazonaws.com/a.jpg", false},
+		{"metadata service", "http://169.254.169.254/latest/meta-data/iam/security-credentials/", false},
+		{"internal host", "http://redis:6379/", false},
+		{"caller-supplied", "https://cdn.example.com/brochure.pdf", false},
+		{"invalid", "://", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.checkFetchable(tt.mediaURL)
+			if tt.allowed && err != nil {
+				t.Errorf("checkFetchable(%q) error = %v, want allowed", tt.mediaURL, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrMediaHostNotAllowed) {
+				t.Errorf("checkFetchable(%q) error = %v, want %v", tt.mediaURL, err, ErrMediaHostNotAllowed)
+			}
+		})
+	}
+
+	// Refused before any request is made
+	if _, _, err := service.OpenMedia(context.Background(), "http://169.254.169.254/"); !errors.Is(err, ErrMediaHostNotAllowed) {
+		t.Errorf("OpenMedia() error = %v, want %v", err, ErrMediaHostNotAllowed)
+	}
+}
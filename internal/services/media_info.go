@@ -0,0 +1,262 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF for image.DecodeConfig
+	_ "image/jpeg" // register JPEG for image.DecodeConfig
+	_ "image/png"  // register PNG for image.DecodeConfig
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// imageHeaderBytes is how much of an image is read to find its dimensions
+const imageHeaderBytes = 64 * 1024
+
+// GetMediaInfo describes a media file. The size, type and ETag come from a HEAD of the
+// object; image dimensions are decoded from its first bytes, and the duration (and
+// dimensions) of audio and video are probed with ffprobe from its first
+// MEDIA_INFO_PROBE_BYTES. Results for objects in our bucket are cached in the media
+// registry, so each object is inspected once.
+func (m *MediaService) GetMediaInfo(ctx context.Context, mediaURL string) (*models.MediaInfo, error) {
+	if err := m.checkFetchable(mediaURL); err != nil {
+		return nil, err
+	}
+	stored := m.IsStoredMedia(mediaURL)
+	if stored {
+		object, err := m.storedMediaByURL(ctx, mediaURL)
+		if err != nil && !errors.Is(err, ErrMediaNotFound) {
+			return nil, err
+		}
+		if object != nil && object.Info != nil {
+			return object.Info, nil
+		}
+	}
+
+	info, err := m.headMedia(ctx, mediaURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(info.ContentType, "image/"):
+		head, err := m.readMedia(ctx, mediaURL, imageHeaderBytes)
+		if err == nil {
+			var width, height int
+			width, height, err = imageDimensions(head)
+			if err == nil {
+				info.Width, info.Height = &width, &height
+			}
+		}
+		if err != nil {
+			m.logger.WithError(err).WithField("media_url", mediaURL).Warn("Failed to read image dimensions")
+		}
+
+	case strings.HasPrefix(info.ContentType, "video/"), strings.HasPrefix(info.ContentType, "audio/"):
+		head, err := m.readMedia(ctx, mediaURL, int64(m.config.MediaInfoProbeBytes))
+		if err == nil {
+			var duration time.Duration
+			var width, height int
+			duration, width, height, err = probeMedia(ctx, m.config.FFprobePath, head)
+			if err == nil {
+				if duration > 0 {
+					seconds := duration.Seconds()
+					info.DurationSeconds = &seconds
+				}
+				if width > 0 && height > 0 {
+					info.Width, info.Height = &width, &height
+				}
+			}
+		}
+		if err != nil {
+			// Files whose index is at the end (some MP4s) cannot be probed from their start
+			m.logger.WithError(err).WithField("media_url", mediaURL).Warn("Failed to probe media")
+		}
+	}
+
+	if stored {
+		m.cacheMediaInfo(ctx, info)
+	}
+
+	return info, nil
+}
+
+// Helper methods
+
+// headMedia reads the size, content type and ETag of a media file without downloading it
+func (m *MediaService) headMedia(ctx context.Context, mediaURL string) (*models.MediaInfo, error) {
+	info := &models.MediaInfo{URL: mediaURL, InspectedAt: time.Now()}
+
+	if m.IsStoredMedia(mediaURL) {
+		output, err := m.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(m.bucket),
+			Key:    aws.String(objectKey(mediaURL)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to head media object: %w", err)
+		}
+		info.ContentType = aws.ToString(output.ContentType)
+		info.SizeBytes = aws.ToInt64(output.ContentLength)
+		info.ETag = strings.Trim(aws.ToString(output.ETag), `"`)
+		return info, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create media HEAD request: %w", err)
+	}
+	if isTwilioURL(mediaURL) {
+		req.SetBasicAuth(m.config.TwilioAccountSID, m.config.TwilioAuthToken)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch media headers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, mediaStatusError("HEAD", resp.StatusCode)
+	}
+
+	info.ContentType = resp.Header.Get("Content-Type")
+	if resp.ContentLength > 0 {
+		info.SizeBytes = resp.ContentLength
+	}
+	info.ETag = strings.Trim(resp.Header.Get("ETag"), `"`)
+	return info, nil
+}
+
+// readMedia reads up to the first maxBytes of a media file with a range request
+func (m *MediaService) readMedia(ctx context.Context, mediaURL string, maxBytes int64) ([]byte, error) {
+	byteRange := fmt.Sprintf("bytes=0-%d", maxBytes-1)
+
+	var body io.ReadCloser
+	if m.IsStoredMedia(mediaURL) {
+		output, err := m.s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(m.bucket),
+			Key:    aws.String(objectKey(mediaURL)),
+			Range:  aws.String(byteRange),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read media object: %w", err)
+		}
+		body = output.Body
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create media download request: %w", err)
+		}
+		req.Header.Set("Range", byteRange)
+		if isTwilioURL(mediaURL) {
+			req.SetBasicAuth(m.config.TwilioAccountSID, m.config.TwilioAuthToken)
+		}
+
+		resp, err := m.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download media: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, mediaStatusError("download", resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close()
+
+	// Servers that ignore the range send everything; stop reading at the limit
+	data, err := io.ReadAll(io.LimitReader(body, maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media body: %w", err)
+	}
+	return data, nil
+}
+
+// storedMediaByURL returns the registry record of an object in our bucket
+func (m *MediaService) storedMediaByURL(ctx context.Context, mediaURL string) (*models.MediaObject, error) {
+	query := `SELECT` + mediaObjectColumns + mediaObjectsFrom + ` WHERE o.url = $1 LIMIT 1`
+
+	object, err := scanMediaObject(m.db.QueryRow(ctx, query, mediaURL))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+	return object, nil
+}
+
+// cacheMediaInfo stores inspection results in the registry record of an object
+func (m *MediaService) cacheMediaInfo(ctx context.Context, info *models.MediaInfo) {
+	_, err := m.db.Exec(ctx, `
+		UPDATE media_objects
+		SET content_type = COALESCE(NULLIF($2, ''), content_type),
+			size_bytes = $3, etag = NULLIF($4, ''),
+			width = $5, height = $6, duration_seconds = $7, inspected_at = $8
+		WHERE url = $1`,
+		info.URL, info.ContentType, info.SizeBytes, info.ETag,
+		info.Width, info.Height, info.DurationSeconds, info.InspectedAt,
+	)
+	if err != nil {
+		m.logger.WithError(err).WithField("media_url", info.URL).Warn("Failed to cache media info")
+	}
+}
+
+// objectKey is the key of an object in our bucket, given its URL
+func objectKey(mediaURL string) string {
+	parsed, err := url.Parse(mediaURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Path, "/")
+}
+
+// imageDimensions decodes the width and height from the start of an image. WebP, which
+// the standard library does not decode, is read from its RIFF header.
+func imageDimensions(head []byte) (int, int, error) {
+	if len(head) >= 30 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WEBP" {
+		return webpDimensions(head)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image header: %w", err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// webpDimensions reads the canvas size from the first chunk of a WebP file
+func webpDimensions(head []byte) (int, int, error) {
+	chunk := head[20:]
+	switch string(head[12:16]) {
+	case "VP8X":
+		// 24-bit canvas width and height minus one
+		width := int(chunk[4]) | int(chunk[5])<<8 | int(chunk[6])<<16
+		height := int(chunk[7]) | int(chunk[8])<<8 | int(chunk[9])<<16
+		return width + 1, height + 1, nil
+	case "VP8 ":
+		// Lossy: 14-bit width and height after the frame tag and start code
+		width := int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff)
+		return width, height, nil
+	case "VP8L":
+		// Lossless: 14-bit width and height minus one after the signature byte
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	default:
+		return 0, 0, fmt.Errorf("unsupported WebP chunk %q", head[12:16])
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// MediaJobService tracks the pipeline stages of inbound attachments so clients can tell
+// whether media is still being processed or failed
+type MediaJobService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewMediaJobService creates a new media job service instance
+func NewMediaJobService(db *pgxpool.Pool, logger *logrus.Logger) *MediaJobService {
+	return &MediaJobService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Start records every stage of a message's attachment as pending, resetting the stages
+// of an earlier run
+func (s *MediaJobService) Start(ctx context.Context, messageID uuid.UUID) {
+	for _, stage := range models.MediaStages {
+		_, err := s.db.Exec(ctx, `
+			INSERT INTO media_processing_jobs (id, message_id, stage, status)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (message_id, attachment_index, stage) DO UPDATE SET
+				status = EXCLUDED.status, error = NULL, started_at = NULL,
+				completed_at = NULL, updated_at = NOW()`,
+			uuid.New(), messageID, stage, models.MediaJobPending,
+		)
+		if err != nil {
+			s.logger.WithError(err).WithField("message_id", messageID).Warn("Failed to start media processing job")
+			return
+		}
+	}
+}
+
+// Record sets the status of a stage. Running stages get a start time, finished stages a
+// completion time; failed stages keep err. Failures are logged rather than returned:
+// tracking must never hold up processing.
+func (s *MediaJobService) Record(ctx context.Context, messageID uuid.UUID, stage, status string, err error) {
+	var message *string
+	if err != nil {
+		text := err.Error()
+		message = &text
+	}
+
+	_, dbErr := s.db.Exec(ctx, `
+		UPDATE media_processing_jobs SET
+			status = $3,
+			error = $4,
+			started_at = COALESCE(started_at, NOW()),
+			completed_at = CASE WHEN $3 IN ('pending', 'running') THEN NULL ELSE NOW() END,
+			updated_at = NOW()
+		WHERE message_id = $1 AND attachment_index = 0 AND stage = $2`,
+		messageID, stage, status, message,
+	)
+	if dbErr != nil {
+		s.logger.WithError(dbErr).WithFields(logrus.Fields{
+			"message_id": messageID,
+			"stage":      stage,
+		}).Warn("Failed to record media processing stage")
+	}
+}
+
+// Status returns the pipeline state of a message's attachments. Media received before
+// tracking existed is reported as unknown.
+func (s *MediaJobService) Status(ctx context.Context, message *models.WhatsAppMessage) (*models.MediaStatus, error) {
+	status := &models.MediaStatus{
+		MessageID:   message.ID,
+		Attachments: []*models.MediaAttachmentStatus{},
+	}
+	if message.MediaURL == nil {
+		return status, nil
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT attachment_index, stage, status, error, started_at, completed_at, updated_at
+		FROM media_processing_jobs
+		WHERE message_id = $1
+		ORDER BY attachment_index, created_at`, message.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media processing jobs: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := map[int]*models.MediaAttachmentStatus{}
+	for rows.Next() {
+		var index int
+		var job models.MediaProcessingJob
+		if err := rows.Scan(&index, &job.Stage, &job.Status, &job.Error, &job.StartedAt, &job.CompletedAt, &job.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan media processing job: %w", err)
+		}
+
+		attachment, ok := attachments[index]
+		if !ok {
+			attachment = &models.MediaAttachmentStatus{Index: index, Stages: []*models.MediaProcessingJob{}}
+			if message.MediaType != nil {
+				attachment.MediaType = *message.MediaType
+			}
+			attachments[index] = attachment
+			status.Attachments = append(status.Attachments, attachment)
+		}
+		attachment.Stages = append(attachment.Stages, &job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get media processing jobs: %w", err)
+	}
+
+	if len(status.Attachments) == 0 {
+		attachment := &models.MediaAttachmentStatus{Status: models.MediaJobUnknown, Stages: []*models.MediaProcessingJob{}}
+		if message.MediaType != nil {
+			attachment.MediaType = *message.MediaType
+		}
+		status.Attachments = append(status.Attachments, attachment)
+	}
+	for _, attachment := range status.Attachments {
+		if attachment.Status == "" {
+			attachment.Status = attachmentStatus(attachment.Stages)
+		}
+	}
+
+	return status, nil
+}
+
+// attachmentStatus summarizes the stages of an attachment: unavailable when the media
+// could not be fetched anymore, failed when any stage failed, processing while any is
+// pending or running, and completed otherwise
+func attachmentStatus(stages []*models.MediaProcessingJob) string {
+	status := models.MediaJobCompleted
+	for _, stage := range stages {
+		switch stage.Status {
+		case models.MediaJobUnavailable:
+			return models.MediaJobUnavailable
+		case models.MediaJobFailed:
+			status = models.MediaJobFailed
+		case models.MediaJobPending, models.MediaJobRunning:
+			if status != models.MediaJobFailed {
+				status = models.MediaJobProcessing
+			}
+		}
+	}
+	return status
+}
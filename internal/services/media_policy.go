@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// Media policy violation reasons
+const (
+	MediaPolicyTypeNotAllowed = "media_type_not_allowed"
+	MediaPolicyTooLarge       = "media_too_large"
+	MediaPolicyTooLong        = "voice_note_too_long"
+)
+
+// mediaSizeLookupTimeout bounds the HEAD request made while the webhook is waiting
+const mediaSizeLookupTimeout = 5 * time.Second
+
+// MediaPolicyViolation describes why an inbound attachment was rejected
+type MediaPolicyViolation struct {
+	Reason      string
+	MediaType   string
+	SizeBytes   int64
+	MaxBytes    int64
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// Error returns a description of the violation suitable for the message flag reason
+func (v *MediaPolicyViolation) Error() string {
+	switch v.Reason {
+	case MediaPolicyTooLarge:
+		return fmt.Sprintf("%s: %s attachment of %d bytes exceeds limit of %d bytes", v.Reason, v.MediaType, v.SizeBytes, v.MaxBytes)
+	case MediaPolicyTooLong:
+		return fmt.Sprintf("%s: %s of %s exceeds limit of %s", v.Reason, v.MediaType, v.Duration.Round(time.Second), v.MaxDuration)
+	default:
+		return fmt.Sprintf("%s: %s", v.Reason, v.MediaType)
+	}
+}
+
+// Reply returns the polite explanation sent back to the user
+func (v *MediaPolicyViolation) Reply() i18n.Message {
+	switch v.Reason {
+	case MediaPolicyTooLarge:
+		return i18n.Message{Key: i18n.KeyMediaTooLarge, Vars: map[string]string{"max_mb": strconv.FormatInt(v.MaxBytes/(1024*1024), 10)}}
+	case MediaPolicyTooLong:
+		return i18n.Message{Key: i18n.KeyMediaTooLong, Vars: map[string]string{"max_minutes": strconv.Itoa(int(v.MaxDuration.Minutes()))}}
+	default:
+		return i18n.Message{Key: i18n.KeyMediaTypeNotAllowed}
+	}
+}
+
+// CheckInboundPolicy validates an inbound attachment against the configured type
+// whitelist and size limits before anything is downloaded. It returns nil when the
+// attachment is acceptable or its size cannot be determined.
+func (m *MediaService) CheckInboundPolicy(ctx context.Context, message *models.WhatsAppMessage) *MediaPolicyViolation {
+	if message.MediaURL == nil || message.MediaType == nil {
+		return nil
+	}
+
+	mediaType := strings.ToLower(*message.MediaType)
+
+	if !m.isAllowedMediaType(mediaType) {
+		return &MediaPolicyViolation{
+			Reason:    MediaPolicyTypeNotAllowed,
+			MediaType: mediaType,
+		}
+	}
+
+	maxBytes := m.maxBytesFor(mediaType)
+	if maxBytes <= 0 {
+		return nil
+	}
+
+	size, err := m.fetchMediaSize(ctx, *message.MediaURL)
+	if err != nil {
+		m.logger.WithError(err).WithField("message_id", message.ID).Warn("Could not determine inbound media size, skipping size check")
+		return nil
+	}
+
+	if size > maxBytes {
+		return &MediaPolicyViolation{
+			Reason:    MediaPolicyTooLarge,
+			MediaType: mediaType,
+			SizeBytes: size,
+			MaxBytes:  maxBytes,
+		}
+	}
+
+	return nil
+}
+
+// CheckVoiceNoteDuration validates a voice note's duration against the configured maximum
+func (m *MediaService) CheckVoiceNoteDuration(mediaType string, duration time.Duration) *MediaPolicyViolation {
+	if m.config.VoiceNoteMaxDuration <= 0 || duration <= m.config.VoiceNoteMaxDuration {
+		return nil
+	}
+
+	return &MediaPolicyViolation{
+		Reason:      MediaPolicyTooLong,
+		MediaType:   mediaType,
+		Duration:    duration,
+		MaxDuration: m.config.VoiceNoteMaxDuration,
+	}
+}
+
+// isAllowedMediaType checks a MIME type against the whitelist, honoring "type/*" wildcards
+func (m *MediaService) isAllowedMediaType(mediaType string) bool {
+	// Strip parameters such as "audio/ogg; codecs=opus"
+	if idx := strings.Index(mediaType, ";"); idx >= 0 {
+		mediaType = strings.TrimSpace(mediaType[:idx])
+	}
+
+	for _, allowed := range m.config.MediaAllowedTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxBytesFor returns the configured size limit for a MIME type
+func (m *MediaService) maxBytesFor(mediaType string) int64 {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return int64(m.config.MediaMaxImageBytes)
+	case strings.HasPrefix(mediaType, "video/"):
+		return int64(m.config.MediaMaxVideoBytes)
+	case strings.HasPrefix(mediaType, "audio/"):
+		return int64(m.config.MediaMaxAudioBytes)
+	default:
+		return int64(m.config.MediaMaxDocumentBytes)
+	}
+}
+
+// fetchMediaSize issues a HEAD request for a media URL, authenticated for Twilio-hosted media
+func (m *MediaService) fetchMediaSize(ctx context.Context, mediaURL string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, mediaSizeLookupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mediaURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create media HEAD request: %w", err)
+	}
+	if isTwilioURL(mediaURL) {
+		req.SetBasicAuth(m.config.TwilioAccountSID, m.config.TwilioAuthToken)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch media headers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, mediaStatusError("HEAD", resp.StatusCode)
+	}
+
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("media size not reported")
+	}
+
+	m.logger.WithFields(logrus.Fields{
+		"media_url":  mediaURL,
+		"size_bytes": resp.ContentLength,
+	}).Debug("Inbound media size determined")
+
+	return resp.ContentLength, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+var (
+	// ErrMediaStorageDisabled is returned when no media bucket is configured
+	ErrMediaStorageDisabled = errors.New("media storage is not configured")
+	// ErrMediaUploadPending is returned when a direct upload has not arrived yet
+	ErrMediaUploadPending = errors.New("media upload not completed")
+	// ErrMediaUploadRejected is returned when a direct upload is refused
+	ErrMediaUploadRejected = errors.New("media upload rejected")
+)
+
+// PresignUpload registers a direct upload and returns a presigned PUT URL for it. The
+// declared type and size are checked against the media policy now and against the
+// uploaded object by VerifyUpload.
+func (m *MediaService) PresignUpload(ctx context.Context, request *models.MediaPresignRequest) (*models.MediaPresignResponse, error) {
+	if m.bucket == "" {
+		return nil, ErrMediaStorageDisabled
+	}
+	if !m.isAllowedMediaType(request.ContentType) {
+		return nil, fmt.Errorf("%w: media type %s is not allowed", ErrMediaUploadRejected, request.ContentType)
+	}
+	if maxBytes := m.maxBytesFor(request.ContentType); maxBytes > 0 && request.SizeBytes > maxBytes {
+		return nil, fmt.Errorf("%w: media exceeds %d bytes", ErrMediaUploadRejected, maxBytes)
+	}
+
+	key := fmt.Sprintf("whatsapp-media/%s/%s%s",
+		time.Now().Format("2006/01/02"),
+		uuid.New().String(),
+		filepath.Ext(request.Filename),
+	)
+
+	// Sent media must be readable by Twilio, like API uploads
+	expiresAt := time.Now().Add(m.config.MediaUploadURLTTL)
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(m.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(request.ContentType),
+		ACL:         types.ObjectCannedACLPublicRead,
+	}
+	applyObjectSettings(m.config, input, nil)
+	presigned, err := s3.NewPresignClient(m.s3Client).PresignPutObject(ctx, input, s3.WithPresignExpires(m.config.MediaUploadURLTTL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload URL: %w", err)
+	}
+
+	pending := models.MediaUploadPending
+	object := &models.MediaObject{
+		Key:          key,
+		URL:          m.objectURL(key),
+		Source:       models.MediaSourceDirect,
+		ContentType:  request.ContentType,
+		SizeBytes:    request.SizeBytes,
+		UploadStatus: &pending,
+	}
+	m.registerObject(ctx, object)
+
+	m.logger.WithField("key", key).WithField("size", request.SizeBytes).Info("Presigned direct media upload")
+
+	headers := encryptionHeaders(input)
+	headers["Content-Type"] = request.ContentType
+	headers["x-amz-acl"] = string(types.ObjectCannedACLPublicRead)
+
+	return &models.MediaPresignResponse{
+		MediaID:   object.ID,
+		MediaURL:  object.URL,
+		UploadURL: presigned.URL,
+		Method:    presigned.Method,
+		Headers:   headers,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifyUpload checks a direct upload when it is used in a send: the object must exist,
+// match the declared type and size, and not be flagged by a scan. Objects failing the
+// check are deleted. While the presigned URL is still valid the object can be replaced,
+// so a verified upload is checked again against the ETag recorded at verification. Media
+// that is not a direct upload passes.
+func (m *MediaService) VerifyUpload(ctx context.Context, mediaURL string) error {
+	if !m.IsStoredMedia(mediaURL) {
+		return nil
+	}
+	object, err := m.storedMediaByURL(ctx, mediaURL)
+	if err != nil {
+		if errors.Is(err, ErrMediaNotFound) {
+			return nil
+		}
+		return err
+	}
+	if object.Source != models.MediaSourceDirect || object.UploadStatus == nil {
+		return nil
+	}
+
+	verified := *object.UploadStatus == models.MediaUploadVerified
+	switch {
+	case verified && time.Since(object.CreatedAt) > m.config.MediaUploadURLTTL:
+		return nil
+	case *object.UploadStatus == models.MediaUploadRejected:
+		return ErrMediaUploadRejected
+	}
+
+	head, err := m.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(object.Bucket),
+		Key:    aws.String(object.Key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) && !verified {
+			return ErrMediaUploadPending
+		}
+		return fmt.Errorf("failed to check uploaded media: %w", err)
+	}
+
+	if reason := uploadRejection(object, head); reason != "" {
+		m.rejectUpload(ctx, object, reason)
+		return fmt.Errorf("%w: %s", ErrMediaUploadRejected, reason)
+	}
+	if verified {
+		return nil
+	}
+
+	if _, err := m.db.Exec(ctx, `
+		UPDATE media_objects SET upload_status = $2, etag = $3
+		WHERE id = $1`, object.ID, models.MediaUploadVerified, aws.ToString(head.ETag)); err != nil {
+		return fmt.Errorf("failed to mark upload verified: %w", err)
+	}
+	return nil
+}
+
+// uploadRejection returns why an uploaded object does not match its registration, or ""
+// when it does. A verified upload must still have the ETag it was verified with.
+func uploadRejection(object *models.MediaObject, head *s3.HeadObjectOutput) string {
+	size := aws.ToInt64(head.ContentLength)
+	switch {
+	case object.UploadStatus != nil && *object.UploadStatus == models.MediaUploadVerified &&
+		aws.ToString(object.ETag) != aws.ToString(head.ETag):
+		return "media was replaced after verification"
+	case size != object.SizeBytes:
+		return fmt.Sprintf("uploaded %d bytes, declared %d", size, object.SizeBytes)
+	case aws.ToString(head.ContentType) != object.ContentType:
+		return fmt.Sprintf("uploaded as %s, declared %s", aws.ToString(head.ContentType), object.ContentType)
+	case object.ScanStatus == models.MediaScanInfected:
+		return "media failed the malware scan"
+	}
+	return ""
+}
+
+// rejectUpload deletes a direct upload that failed verification and keeps its record,
+// so later sends referring to it are refused
+func (m *MediaService) rejectUpload(ctx context.Context, object *models.MediaObject, reason string) {
+	m.logger.WithField("media_id", object.ID).WithField("reason", reason).Warn("Direct media upload rejected")
+
+	if _, err := m.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(object.Bucket),
+		Key:    aws.String(object.Key),
+	}); err != nil {
+		m.logger.WithError(err).WithField("media_id", object.ID).Warn("Failed to delete rejected upload")
+	}
+
+	if _, err := m.db.Exec(ctx, `
+		UPDATE media_objects SET upload_status = $2 WHERE id = $1`,
+		object.ID, models.MediaUploadRejected); err != nil {
+		m.logger.WithError(err).WithField("media_id", object.ID).Warn("Failed to mark upload rejected")
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestUploadRejection(t *testing.T) {
+	pending, verified := models.MediaUploadPending, models.MediaUploadVerified
+	upload := func(status *string, etag *string) *models.MediaObject {
+		return &models.MediaObject{
+			ContentType:  "application/pdf",
+			SizeBytes:    1024,
+			ScanStatus:   models.MediaScanNotScanned,
+			UploadStatus: status,
+			ETag:         etag,
+		}
+	}
+	head := func(size int64, contentType, etag string) *s3.HeadObjectOutput {
+		return &s3.HeadObjectOutput{ContentLength: aws.Int64(size), ContentType: aws.String(contentType), ETag: aws.String(etag)}
+	}
+
+	tests := []struct {
+		name       string
+		object     *models.MediaObject
+		head       *s3.HeadObjectOutput
+		wantReject bool
+	}{
+		{"pending and matching", upload(&pending, nil), head(1024, "application/pdf", `"a"`), false},
+		{"pending with another size", upload(&pending, nil), head(2048, "application/pdf", `"a"`), true},
+		{"pending with another type", upload(&pending, nil), head(1024, "application/zip", `"a"`), true},
+		{"verified and unchanged", upload(&verified, aws.String(`"a"`)), head(1024, "application/pdf", `"a"`), false},
+		{"verified and replaced", upload(&verified, aws.String(`"a"`)), head(1024, "application/pdf", `"b"`), true},
+		{"verified without an ETag", upload(&verified, nil), head(1024, "application/pdf", `"a"`), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := uploadRejection(tt.object, tt.head); (reason != "") != tt.wantReject {
+				t.Errorf("uploadRejection() = %q, want rejected %v", reason, tt.wantReject)
+			}
+		})
+	}
+
+	infected := upload(&pending, nil)
+	infected.ScanStatus = models.MediaScanInfected
+	if reason := uploadRejection(infected, head(1024, "application/pdf", `"a"`)); reason == "" {
+		t.Error("uploadRejection() accepted media that failed the malware scan")
+	}
+}
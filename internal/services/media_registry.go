@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrMediaNotFound is returned when a stored media object does not exist
+var ErrMediaNotFound = errors.New("media not found")
+
+// mediaObjectColumns lists the media_objects columns in the order scanMediaObject expects
+const mediaObjectColumns = `
+	o.id, o.bucket, o.object_key, o.url, o.message_id, m.session_id, o.source,
+	o.content_type, o.size_bytes, o.scan_status, o.thumbnail_url, o.created_at,
+	o.etag, o.width, o.height, o.duration_seconds, o.inspected_at, o.sha256,
+	o.upload_status`
+
+// mediaObjectsFrom joins media objects to their message for its session
+const mediaObjectsFrom = `
+	FROM media_objects o
+	LEFT JOIN whatsapp_messages m ON m.id = o.message_id`
+
+// ListMedia returns stored media objects, newest first, optionally only those of a
+// message or of the messages in a chat session. Content-addressed objects belong to every
+// message carrying the same content.
+func (m *MediaService) ListMedia(ctx context.Context, filter models.MediaFilter, limit, offset int) ([]*models.MediaObject, int64, error) {
+	query := `SELECT` + mediaObjectColumns + mediaObjectsFrom + `
+		WHERE ($1::uuid IS NULL OR o.message_id = $1
+		       OR o.sha256 = (SELECT media_sha256 FROM whatsapp_messages WHERE id = $1))
+		  AND ($2::uuid IS NULL OR m.session_id = $2
+		       OR EXISTS (SELECT 1 FROM whatsapp_messages d WHERE d.session_id = $2 AND d.media_sha256 = o.sha256))
+		ORDER BY o.created_at DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := m.db.Query(ctx, query, filter.MessageID, filter.SessionID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list media: %w", err)
+	}
+	defer rows.Close()
+
+	objects := []*models.MediaObject{}
+	for rows.Next() {
+		object, err := scanMediaObject(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan media: %w", err)
+		}
+		objects = append(objects, object)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return objects, estimateTotal(ctx, m.db, m.logger, query, filter.MessageID, filter.SessionID, limit, offset), nil
+}
+
+// GetStoredMedia returns a stored media object
+func (m *MediaService) GetStoredMedia(ctx context.Context, id uuid.UUID) (*models.MediaObject, error) {
+	query := `SELECT` + mediaObjectColumns + mediaObjectsFrom + ` WHERE o.id = $1`
+
+	object, err := scanMediaObject(m.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMediaNotFound
+		}
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+
+	return object, nil
+}
+
+// DeleteStoredMedia deletes a media object from the bucket and the registry and clears
+// the media URL of every message that points at it. A content-addressed object is shared
+// by every message with the same content, so their hash is cleared too and nothing
+// reuses the deleted content or its analysis. It returns those messages, whose cached
+// copies are stale.
+func (m *MediaService) DeleteStoredMedia(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	object, err := m.GetStoredMedia(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The object goes first: a failed deletion leaves the registry row to retry with
+	if _, err := m.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(object.Bucket),
+		Key:    aws.String(object.Key),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete media object: %w", err)
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin media deletion: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM media_objects WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to delete media record: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE whatsapp_messages
+		SET media_url = CASE WHEN media_url = $1 THEN NULL ELSE media_url END,
+			media_sha256 = CASE WHEN media_sha256 = $2 THEN NULL ELSE media_sha256 END,
+			updated_at = NOW()
+		WHERE media_url = $1 OR media_sha256 = $2
+		RETURNING id`, object.URL, object.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear message media: %w", err)
+	}
+	var messageIDs []uuid.UUID
+	for rows.Next() {
+		var messageID uuid.UUID
+		if err := rows.Scan(&messageID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan message ID: %w", err)
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to clear message media: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit media deletion: %w", err)
+	}
+
+	m.logger.WithField("media_id", id).WithField("messages", len(messageIDs)).Info("Stored media deleted")
+	return messageIDs, nil
+}
+
+// DeleteMessageMedia deletes every object stored for a message, such as re-hosted copies
+// of its Twilio media. Content-addressed objects still carried by other messages are kept.
+// The re-hosted copy is deleted by its key as well, in case its registration failed.
+func (m *MediaService) DeleteMessageMedia(ctx context.Context, messageID uuid.UUID) error {
+	if m.bucket != "" {
+		if _, err := m.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(m.bucket),
+			Key:    aws.String(rehostedKey(messageID)),
+		}); err != nil {
+			return fmt.Errorf("failed to delete re-hosted media: %w", err)
+		}
+	}
+
+	objects, _, err := m.ListMedia(ctx, models.MediaFilter{MessageID: &messageID}, 100, 0)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		shared, err := m.sharedWithOthers(ctx, object, messageID)
+		if err != nil {
+			return err
+		}
+		if shared {
+			continue
+		}
+		if _, err := m.DeleteStoredMedia(ctx, object.ID); err != nil && !errors.Is(err, ErrMediaNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachToMessage records the message that carries a media file from our bucket; media
+// hosted elsewhere is not tracked
+func (m *MediaService) AttachToMessage(ctx context.Context, mediaURL *string, messageID uuid.UUID) error {
+	if mediaURL == nil || !m.IsStoredMedia(*mediaURL) {
+		return nil
+	}
+
+	if _, err := m.db.Exec(ctx, `
+		UPDATE media_objects SET message_id = $2
+		WHERE url = $1 AND message_id IS NULL`, *mediaURL, messageID); err != nil {
+		return fmt.Errorf("failed to attach media to message: %w", err)
+	}
+	return nil
+}
+
+// Helper methods
+
+// registerObject records an object written to our bucket. A failure is logged rather
+// than returned: the object exists either way.
+func (m *MediaService) registerObject(ctx context.Context, object *models.MediaObject) {
+	object.ID = uuid.New()
+	object.Bucket = m.bucket
+	if object.ScanStatus == "" {
+		object.ScanStatus = models.MediaScanNotScanned
+	}
+
+	_, err := m.db.Exec(ctx, `
+		INSERT INTO media_objects (id, bucket, object_key, url, message_id, source, content_type, size_bytes, scan_status, sha256, upload_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT DO NOTHING`,
+		object.ID, object.Bucket, object.Key, object.URL, object.MessageID, object.Source,
+		object.ContentType, object.SizeBytes, object.ScanStatus, object.SHA256, object.UploadStatus,
+	)
+	if err != nil {
+		m.logger.WithError(err).WithField("key", object.Key).Warn("Failed to register stored media")
+	}
+}
+
+// objectURL is the URL of an object in our bucket
+func (m *MediaService) objectURL(key string) string {
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", m.bucket, m.config.AWSRegion, key)
+}
+
+// scanMediaObject reads a row selected with mediaObjectColumns
+func scanMediaObject(row pgx.Row) (*models.MediaObject, error) {
+	var object models.MediaObject
+	var width, height *int
+	var duration *float64
+	var inspectedAt *time.Time
+	err := row.Scan(
+		&object.ID, &object.Bucket, &object.Key, &object.URL, &object.MessageID, &object.SessionID,
+		&object.Source, &object.ContentType, &object.SizeBytes, &object.ScanStatus,
+		&object.ThumbnailURL, &object.CreatedAt,
+		&object.ETag, &width, &height, &duration, &inspectedAt, &object.SHA256,
+		&object.UploadStatus,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if inspectedAt != nil {
+		object.Info = &models.MediaInfo{
+			URL:             object.URL,
+			ContentType:     object.ContentType,
+			SizeBytes:       object.SizeBytes,
+			Width:           width,
+			Height:          height,
+			DurationSeconds: duration,
+			InspectedAt:     *inspectedAt,
+		}
+		if object.ETag != nil {
+			object.Info.ETag = *object.ETag
+		}
+	}
+	return &object, nil
+}
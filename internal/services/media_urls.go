@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// How media hosted by Twilio is referred to in orchestrator payloads
+const (
+	OrchestratorMediaPresigned = "presigned" // re-hosted in our bucket, shared as a presigned URL
+	OrchestratorMediaID        = "media_id"  // only the message ID; media is fetched from the adapter API
+)
+
+// OrchestratorMedia returns the media URL and media ID to send to the orchestrator for a
+// message. Twilio media URLs only work with our account credentials, so they are never
+// passed on: depending on ORCHESTRATOR_MEDIA_URLS the media is re-hosted and shared as a
+// presigned URL, or only its ID is sent. Media hosted elsewhere is passed through. When
+// re-hosting fails, the ID is returned without a URL along with the error.
+func (m *MediaService) OrchestratorMedia(ctx context.Context, message *models.WhatsAppMessage) (*string, *string, error) {
+	if message.MediaURL == nil {
+		return nil, nil, nil
+	}
+	if !isTwilioURL(*message.MediaURL) {
+		return message.MediaURL, nil, nil
+	}
+
+	mediaID := message.ID.String()
+	if m.config.OrchestratorMediaURLs != OrchestratorMediaPresigned || m.bucket == "" {
+		return nil, &mediaID, nil
+	}
+
+	presigned, err := m.presignRehosted(ctx, message)
+	if err != nil {
+		return nil, &mediaID, err
+	}
+	return &presigned, &mediaID, nil
+}
+
+// OpenMedia streams a message's media, authenticating for media hosted by Twilio. Only
+// media hosted by Twilio or in our bucket is fetched.
+func (m *MediaService) OpenMedia(ctx context.Context, mediaURL string) (io.ReadCloser, string, error) {
+	if err := m.checkFetchable(mediaURL); err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create media download request: %w", err)
+	}
+	if isTwilioURL(mediaURL) {
+		req.SetBasicAuth(m.config.TwilioAccountSID, m.config.TwilioAuthToken)
+	}
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download media: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", mediaStatusError("download", resp.StatusCode)
+	}
+
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// Helper methods
+
+// rehostedKey is the bucket key of a message's re-hosted Twilio media
+func rehostedKey(messageID uuid.UUID) string {
+	return fmt.Sprintf("whatsapp-media/inbound/%s", messageID)
+}
+
+// presignRehosted copies a message's Twilio media into our bucket, unless an earlier
+// forward or content-addressed storage already did, and returns a presigned GET URL for
+// the copy
+func (m *MediaService) presignRehosted(ctx context.Context, message *models.WhatsAppMessage) (string, error) {
+	key := rehostedKey(message.ID)
+	var hash *string
+	if message.MediaSHA256 != nil && m.dedupEnabled() {
+		key = contentKey(*message.MediaSHA256)
+		hash = message.MediaSHA256
+	}
+
+	_, err := m.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if !errors.As(err, &notFound) {
+			return "", fmt.Errorf("failed to check re-hosted media: %w", err)
+		}
+
+		mediaType := ""
+		if message.MediaType != nil {
+			mediaType = *message.MediaType
+		}
+		data, err := m.downloadMedia(ctx, *message.MediaURL, m.maxBytesFor(mediaType))
+		if err != nil {
+			return "", err
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket: aws.String(m.bucket),
+			Key:    aws.String(key),
+			Body:   bytes.NewReader(data),
+		}
+		if mediaType != "" {
+			input.ContentType = aws.String(mediaType)
+		}
+		applyObjectSettings(m.config, input, messageObjectTags(message))
+		if _, err := m.s3Client.PutObject(ctx, input); err != nil {
+			return "", fmt.Errorf("failed to re-host media: %w", err)
+		}
+
+		m.registerObject(ctx, &models.MediaObject{
+			Key:         key,
+			URL:         m.objectURL(key),
+			MessageID:   &message.ID,
+			Source:      models.MediaSourceRehosted,
+			ContentType: mediaType,
+			SizeBytes:   int64(len(data)),
+			SHA256:      hash,
+		})
+	}
+
+	presigned, err := s3.NewPresignClient(m.s3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(m.config.OrchestratorMediaURLTTL))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign media URL: %w", err)
+	}
+
+	// The signed URL is a credential and is not logged
+	m.logger.WithField("key", key).Debug("Presigned re-hosted media for orchestrator")
+
+	return presigned.URL, nil
+}
@@ -2,36 +2,68 @@ package services
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrMessageNotFound is returned when a message does not exist
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrTranscriptStored is returned when a voice note already has a transcript, or was
+// forwarded without one after its transcription timed out
+var ErrTranscriptStored = errors.New("transcript already stored")
+
+// Message storage metrics
+var (
+	messagesStoredTotal    = metrics.NewCounter("messages_stored_total", "Messages written to the database", "direction", "channel", "tenant", "number", "type", "provider")
+	messagesDuplicateTotal = metrics.NewCounter("messages_duplicate_total", "Messages received again for an already stored SID", "direction", "channel", "tenant", "number", "type", "provider")
+	messageStatusTotal     = metrics.NewCounter("message_status_updates_total", "Status callbacks applied to stored messages", "status", "channel", "tenant", "number", "type", "provider")
 )
 
 // MessageService handles message storage and retrieval operations
 type MessageService struct {
-	db     *pgxpool.Pool
-	redis  *redis.Client
-	logger *logrus.Logger
+	db           *pgxpool.Pool
+	cache        *RedisCache
+	queryTimeout time.Duration
+	logger       *logrus.Logger
 }
 
 // NewMessageService creates a new message service instance
-func NewMessageService(db *pgxpool.Pool, redisClient *redis.Client, logger *logrus.Logger) *MessageService {
+func NewMessageService(db *pgxpool.Pool, cache *RedisCache, cfg *config.Config, logger *logrus.Logger) *MessageService {
 	return &MessageService{
-		db:     db,
-		redis:  redisClient,
-		logger: logger,
+		db:           db,
+		cache:        cache,
+		queryTimeout: cfg.DBQueryTimeout,
+		logger:       logger,
 	}
 }
 
-// StoreMessage stores a WhatsApp message in the database
-func (m *MessageService) StoreMessage(ctx context.Context, message *models.WhatsAppMessage) error {
+// query names the statements of a call for query metrics and slow query logs and
+// bounds the call by the query timeout
+func (m *MessageService) query(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	return database.WithQuery(ctx, name, m.queryTimeout)
+}
+
+// StoreMessage upserts a WhatsApp message by Twilio SID and reports whether the row is
+// new. A message whose SID is already stored only has its status overwritten (last write
+// wins), takes the stored message's ID, and its outbox entries are dropped, so callers
+// skip downstream processing when created is false. Outbox entries of a new message are
+// written in the same transaction; the caller dispatches them once StoreMessage returns.
+func (m *MessageService) StoreMessage(ctx context.Context, message *models.WhatsAppMessage, outbox ...*models.OutboxEntry) (bool, error) {
+	ctx, cancel := m.query(ctx, "store_message")
+	defer cancel()
+
 	m.logger.WithFields(logrus.Fields{
 		"message_id":   message.ID,
 		"twilio_sid":   message.TwilioSID,
@@ -39,52 +71,82 @@ func (m *MessageService) StoreMessage(ctx context.Context, message *models.Whats
 		"message_type": message.Type,
 	}).Info("Storing WhatsApp message")
 
-	query := `
-		INSERT INTO whatsapp_messages (
-			id, twilio_sid, from_number, to_number, direction, message_type, 
-			status, content, media_url, media_type, timestamp, created_at, updated_at,
-			user_id, session_id, error_code, error_message
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
-		)`
-
-	_, err := m.db.Exec(ctx, query,
-		message.ID,
-		message.TwilioSID,
-		message.From,
-		message.To,
-		message.Direction,
-		message.Type,
-		message.Status,
-		message.Content,
-		message.MediaURL,
-		message.MediaType,
-		message.Timestamp,
-		message.CreatedAt,
-		message.UpdatedAt,
-		message.UserID,
-		message.SessionID,
-		message.ErrorCode,
-		message.ErrorMsg,
-	)
+	if message.Channel == "" {
+		message.Channel = models.ChannelWhatsApp
+	}
+	if message.Metadata == nil {
+		message.Metadata = models.Metadata{}
+	}
 
+	tx, err := m.db.Begin(ctx)
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to store message in database")
-		return fmt.Errorf("failed to store message: %w", err)
+		return false, fmt.Errorf("failed to begin message write: %w", err)
 	}
+	defer tx.Rollback(ctx)
+
+	// The partitioned table cannot have a unique index on twilio_sid alone, so ON CONFLICT
+	// is not available; writers of the same SID are serialized and upsert here instead
+	if message.TwilioSID != "" {
+		if _, err := tx.Exec(ctx, lockMessageSIDQuery, message.TwilioSID); err != nil {
+			return false, fmt.Errorf("failed to lock message SID: %w", err)
+		}
+
+		var existingID uuid.UUID
+		err := tx.QueryRow(ctx, updateStoredMessageStatusQuery, message.TwilioSID, message.Status).Scan(&existingID)
+		switch {
+		case err == nil:
+			if err := tx.Commit(ctx); err != nil {
+				return false, fmt.Errorf("failed to commit message status: %w", err)
+			}
+			message.ID = existingID
+			messagesDuplicateTotal.Inc(append([]string{string(message.Direction), string(message.Channel)}, MessageMetricLabels(message)...)...)
+			m.InvalidateCache(ctx, existingID)
+
+			m.logger.WithFields(logrus.Fields{
+				"message_id": existingID,
+				"twilio_sid": message.TwilioSID,
+			}).Info("Message already stored, status updated")
+			return false, nil
+
+		case !errors.Is(err, pgx.ErrNoRows):
+			m.logger.WithError(err).Error("Failed to store message in database")
+			return false, fmt.Errorf("failed to update stored message: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, insertMessageQuery, messageRows.Values(message, messageInsertColumns...)...)
+
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to store message in database")
+		return false, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	if err := insertOutboxEntries(ctx, tx, outbox); err != nil {
+		m.logger.WithError(err).Error("Failed to store message in database")
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		m.logger.WithError(err).Error("Failed to store message in database")
+		return false, fmt.Errorf("failed to commit message: %w", err)
+	}
+	messagesStoredTotal.Inc(append([]string{string(message.Direction), string(message.Channel)}, MessageMetricLabels(message)...)...)
 
 	// Cache recent messages in Redis for quick access
-	cacheKey := fmt.Sprintf("message:%s", message.ID)
-	if err := m.redis.Set(ctx, cacheKey, message, 24*time.Hour).Err(); err != nil {
+	if err := m.cache.Set(ctx, CacheClassMessages, message.ID.String(), message); err != nil {
 		m.logger.WithError(err).Warn("Failed to cache message in Redis")
 	}
 
 	m.logger.WithField("message_id", message.ID).Info("Message stored successfully")
-	return nil
+	return true, nil
 }
 
 // GetMessage retrieves a message by ID
 func (m *MessageService) GetMessage(ctx context.Context, messageID string) (*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "get_message")
+	defer cancel()
+
 	m.logger.WithField("message_id", messageID).Info("Retrieving message")
 
 	// Parse UUID
@@ -94,53 +156,23 @@ func (m *MessageService) GetMessage(ctx context.Context, messageID string) (*mod
 	}
 
 	// Try cache first
-	cacheKey := fmt.Sprintf("message:%s", messageID)
 	var message models.WhatsAppMessage
-	if err := m.redis.Get(ctx, cacheKey).Scan(&message); err == nil {
+	if found, err := m.cache.Get(ctx, CacheClassMessages, id.String(), &message); err == nil && found {
 		m.logger.WithField("message_id", messageID).Debug("Message retrieved from cache")
 		return &message, nil
 	}
 
 	// Query database
-	query := `
-		SELECT id, twilio_sid, from_number, to_number, direction, message_type,
-			   status, content, media_url, media_type, timestamp, created_at, updated_at,
-			   user_id, session_id, error_code, error_message
-		FROM whatsapp_messages 
-		WHERE id = $1`
-
-	row := m.db.QueryRow(ctx, query, id)
-	
-	err = row.Scan(
-		&message.ID,
-		&message.TwilioSID,
-		&message.From,
-		&message.To,
-		&message.Direction,
-		&message.Type,
-		&message.Status,
-		&message.Content,
-		&message.MediaURL,
-		&message.MediaType,
-		&message.Timestamp,
-		&message.CreatedAt,
-		&message.UpdatedAt,
-		&message.UserID,
-		&message.SessionID,
-		&message.ErrorCode,
-		&message.ErrorMsg,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("message not found")
+	if err := scanMessageInto(m.db.QueryRow(ctx, getMessageQuery, id), &message); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
 		}
 		m.logger.WithError(err).Error("Failed to retrieve message from database")
 		return nil, fmt.Errorf("failed to retrieve message: %w", err)
 	}
 
 	// Cache the result
-	if err := m.redis.Set(ctx, cacheKey, &message, 24*time.Hour).Err(); err != nil {
+	if err := m.cache.Set(ctx, CacheClassMessages, id.String(), &message); err != nil {
 		m.logger.WithError(err).Warn("Failed to cache retrieved message")
 	}
 
@@ -148,41 +180,81 @@ func (m *MessageService) GetMessage(ctx context.Context, messageID string) (*mod
 	return &message, nil
 }
 
-// UpdateMessageStatus updates the status of a message
+// UpdateMessageStatus updates the status of a message and records the callback in its
+// status history. Callbacks for unknown SIDs are recorded before the not found error is
+// returned.
 func (m *MessageService) UpdateMessageStatus(ctx context.Context, statusUpdate *models.MessageStatusUpdate) error {
+	ctx, cancel := m.query(ctx, "update_message_status")
+	defer cancel()
+
 	m.logger.WithFields(logrus.Fields{
 		"message_sid": statusUpdate.MessageSid,
 		"status":      statusUpdate.Status,
 		"error_code":  statusUpdate.ErrorCode,
 	}).Info("Updating message status")
 
-	query := `
-		UPDATE whatsapp_messages 
-		SET status = $2, error_code = $3, error_message = $4, updated_at = $5
-		WHERE twilio_sid = $1`
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to update message status in database")
+		return fmt.Errorf("failed to begin status update: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	result, err := m.db.Exec(ctx, query,
+	rows, err := tx.Query(ctx, updateMessageStatusQuery,
 		statusUpdate.MessageSid,
 		statusUpdate.Status,
 		statusUpdate.ErrorCode,
 		statusUpdate.ErrorMessage,
 		statusUpdate.Timestamp,
 	)
-
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to update message status in database")
 		return fmt.Errorf("failed to update message status: %w", err)
 	}
 
-	rowsAffected := result.RowsAffected()
+	var updated []uuid.UUID
+	var labels [][]string
+	for rows.Next() {
+		var id uuid.UUID
+		var channel models.Channel
+		var direction models.MessageDirection
+		var messageType models.MessageType
+		var from, to string
+		if err := rows.Scan(&id, &channel, &direction, &messageType, &from, &to); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan updated message: %w", err)
+		}
+		updated = append(updated, id)
+		labels = append(labels, append([]string{string(statusUpdate.Status), string(channel)}, messageDimensionLabels(channel, direction, messageType, from, to)...))
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		m.logger.WithError(err).Error("Failed to update message status in database")
+		return fmt.Errorf("failed to update message status: %w", err)
+	}
+
+	if err := insertStatusEvent(ctx, tx, statusUpdate, updated); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		m.logger.WithError(err).Error("Failed to update message status in database")
+		return fmt.Errorf("failed to commit status update: %w", err)
+	}
+
+	rowsAffected := len(updated)
 	if rowsAffected == 0 {
 		m.logger.WithField("message_sid", statusUpdate.MessageSid).Warn("No message found to update")
 		return fmt.Errorf("message not found for status update")
 	}
 
 	// Invalidate cache
-	// We don't have the message ID here, so we'll need to query for it or use a different cache strategy
-	
+	for i, id := range updated {
+		messageStatusTotal.Inc(labels[i]...)
+		m.InvalidateCache(ctx, id)
+	}
+
 	m.logger.WithFields(logrus.Fields{
 		"message_sid":   statusUpdate.MessageSid,
 		"rows_affected": rowsAffected,
@@ -191,60 +263,221 @@ func (m *MessageService) UpdateMessageStatus(ctx context.Context, statusUpdate *
 	return nil
 }
 
+// MarkCanceled moves a pending outbound message to the canceled status and returns it.
+// It returns nil when the message is no longer pending.
+func (m *MessageService) MarkCanceled(ctx context.Context, messageID uuid.UUID) (*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "mark_canceled")
+	defer cancel()
+
+	var message models.WhatsAppMessage
+	if err := scanMessageInto(m.db.QueryRow(ctx, markCanceledQuery, messageID), &message); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to cancel message: %w", err)
+	}
+
+	m.InvalidateCache(ctx, messageID)
+
+	return &message, nil
+}
+
+// SetLabels stores the classifier labels of a message
+func (m *MessageService) SetLabels(ctx context.Context, messageID uuid.UUID, labels []string) error {
+	ctx, cancel := m.query(ctx, "set_labels")
+	defer cancel()
+
+	if labels == nil {
+		labels = []string{}
+	}
+
+	_, err := m.db.Exec(ctx, setLabelsQuery, messageID, labels)
+	if err != nil {
+		return fmt.Errorf("failed to store message labels: %w", err)
+	}
+
+	m.InvalidateCache(ctx, messageID)
+	return nil
+}
+
+// InvalidateCache drops the cached copy of a message after it changed. The cache is
+// shared in Redis, so other replicas see the change too.
+func (m *MessageService) InvalidateCache(ctx context.Context, messageID uuid.UUID) {
+	if err := m.cache.Delete(ctx, CacheClassMessages, messageID.String()); err != nil {
+		m.logger.WithError(err).Warn("Failed to invalidate cached message")
+	}
+}
+
+// ClaimFallback marks a failed outbound message as having started its SMS fallback and
+// returns it. It returns nil when the message is unknown or a fallback was already claimed.
+func (m *MessageService) ClaimFallback(ctx context.Context, twilioSID string) (*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "claim_fallback")
+	defer cancel()
+
+	var message models.WhatsAppMessage
+	if err := scanMessageInto(m.db.QueryRow(ctx, claimFallbackQuery, twilioSID), &message); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim fallback: %w", err)
+	}
+
+	return &message, nil
+}
+
+// ReleaseFallback gives up a fallback claim whose SMS could not be sent, so a later
+// failure callback for the message can try again
+func (m *MessageService) ReleaseFallback(ctx context.Context, messageID uuid.UUID) error {
+	ctx, cancel := m.query(ctx, "release_fallback")
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, releaseFallbackQuery, messageID); err != nil {
+		return fmt.Errorf("failed to release fallback claim: %w", err)
+	}
+
+	return nil
+}
+
+// LinkFallback records the SMS message sent as fallback for a WhatsApp message
+func (m *MessageService) LinkFallback(ctx context.Context, messageID, fallbackID uuid.UUID) error {
+	ctx, cancel := m.query(ctx, "link_fallback")
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, linkFallbackQuery, messageID, fallbackID); err != nil {
+		return fmt.Errorf("failed to link fallback message: %w", err)
+	}
+
+	if err := m.cache.Delete(ctx, CacheClassMessages, messageID.String()); err != nil {
+		m.logger.WithError(err).Warn("Failed to invalidate cached message")
+	}
+
+	return nil
+}
+
+// UpdateExtractedText stores text extracted from a message's media (e.g., via OCR)
+func (m *MessageService) UpdateExtractedText(ctx context.Context, messageID uuid.UUID, text string) error {
+	ctx, cancel := m.query(ctx, "update_extracted_text")
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, updateExtractedTextQuery, messageID, text); err != nil {
+		m.logger.WithError(err).Error("Failed to store extracted text")
+		return fmt.Errorf("failed to store extracted text: %w", err)
+	}
+
+	// Drop the cached copy so readers see the extracted text
+	if err := m.cache.Delete(ctx, CacheClassMessages, messageID.String()); err != nil {
+		m.logger.WithError(err).Warn("Failed to invalidate cached message")
+	}
+
+	return nil
+}
+
+// SetMediaHash records the SHA-256 of a message's downloaded media
+func (m *MessageService) SetMediaHash(ctx context.Context, messageID uuid.UUID, hash string) error {
+	ctx, cancel := m.query(ctx, "set_media_hash")
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, setMediaHashQuery, messageID, hash); err != nil {
+		return fmt.Errorf("failed to store media hash: %w", err)
+	}
+
+	m.InvalidateCache(ctx, messageID)
+	return nil
+}
+
+// TranscriptByMediaHash returns the most recent transcript of another, unredacted voice
+// note with the same media, or nil if there is none
+func (m *MessageService) TranscriptByMediaHash(ctx context.Context, hash string, excludeID uuid.UUID) (*string, error) {
+	ctx, cancel := m.query(ctx, "transcript_by_media_hash")
+	defer cancel()
+
+	var transcript string
+	err := m.db.QueryRow(ctx, transcriptByMediaHashQuery, hash, excludeID).Scan(&transcript)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find transcript by media hash: %w", err)
+	}
+	return &transcript, nil
+}
+
+// UpdateTranscript stores the speech-to-text transcript of an audio message. Only the
+// first transcript is kept; an empty one marks a voice note that was given up on.
+func (m *MessageService) UpdateTranscript(ctx context.Context, messageID uuid.UUID, transcript string) error {
+	ctx, cancel := m.query(ctx, "update_transcript")
+	defer cancel()
+
+	result, err := m.db.Exec(ctx, updateTranscriptQuery, messageID, transcript)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to store transcript")
+		return fmt.Errorf("failed to store transcript: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrTranscriptStored
+	}
+
+	if err := m.cache.Delete(ctx, CacheClassMessages, messageID.String()); err != nil {
+		m.logger.WithError(err).Warn("Failed to invalidate cached message")
+	}
+
+	return nil
+}
+
+// AwaitingTranscript returns up to limit voice notes that were sent for transcription
+// more than wait ago and still have no transcript
+func (m *MessageService) AwaitingTranscript(ctx context.Context, wait time.Duration, limit int) ([]*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "awaiting_transcript")
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, awaitingTranscriptQuery, time.Now().Add(-wait), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query voice notes awaiting a transcript: %w", err)
+	}
+
+	messages, err := collectMessages(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error reading voice notes awaiting a transcript: %w", err)
+	}
+	return messages, nil
+}
+
+// FlagMessage marks a stored message as flagged with the given reason
+func (m *MessageService) FlagMessage(ctx context.Context, messageID uuid.UUID, reason string) error {
+	ctx, cancel := m.query(ctx, "flag_message")
+	defer cancel()
+
+	if _, err := m.db.Exec(ctx, flagMessageQuery, messageID, reason); err != nil {
+		m.logger.WithError(err).Error("Failed to flag message")
+		return fmt.Errorf("failed to flag message: %w", err)
+	}
+
+	if err := m.cache.Delete(ctx, CacheClassMessages, messageID.String()); err != nil {
+		m.logger.WithError(err).Warn("Failed to invalidate cached message")
+	}
+
+	return nil
+}
+
 // GetMessagesByUser retrieves messages for a specific user/phone number
 func (m *MessageService) GetMessagesByUser(ctx context.Context, phoneNumber string, limit int, offset int) ([]*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "get_messages_by_user")
+	defer cancel()
+
 	m.logger.WithFields(logrus.Fields{
 		"phone_number": phoneNumber,
 		"limit":        limit,
 		"offset":       offset,
 	}).Info("Retrieving messages by user")
 
-	query := `
-		SELECT id, twilio_sid, from_number, to_number, direction, message_type,
-			   status, content, media_url, media_type, timestamp, created_at, updated_at,
-			   user_id, session_id, error_code, error_message
-		FROM whatsapp_messages 
-		WHERE from_number = $1 OR to_number = $1
-		ORDER BY timestamp DESC
-		LIMIT $2 OFFSET $3`
-
-	rows, err := m.db.Query(ctx, query, phoneNumber, limit, offset)
+	rows, err := m.db.Query(ctx, messagesByUserQuery, phoneNumber, limit, offset)
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to query messages by user")
 		return nil, fmt.Errorf("failed to query messages: %w", err)
 	}
-	defer rows.Close()
 
-	var messages []*models.WhatsAppMessage
-	for rows.Next() {
-		var message models.WhatsAppMessage
-		err := rows.Scan(
-			&message.ID,
-			&message.TwilioSID,
-			&message.From,
-			&message.To,
-			&message.Direction,
-			&message.Type,
-			&message.Status,
-			&message.Content,
-			&message.MediaURL,
-			&message.MediaType,
-			&message.Timestamp,
-			&message.CreatedAt,
-			&message.UpdatedAt,
-			&message.UserID,
-			&message.SessionID,
-			&message.ErrorCode,
-			&message.ErrorMsg,
-		)
-		if err != nil {
-			m.logger.WithError(err).Error("Failed to scan message row")
-			continue
-		}
-		messages = append(messages, &message)
-	}
-
-	if err := rows.Err(); err != nil {
+	messages, err := collectMessages(rows)
+	if err != nil {
 		m.logger.WithError(err).Error("Error iterating over message rows")
 		return nil, fmt.Errorf("error reading messages: %w", err)
 	}
@@ -257,59 +490,94 @@ func (m *MessageService) GetMessagesByUser(ctx context.Context, phoneNumber stri
 	return messages, nil
 }
 
+// GetMessagesByUserID retrieves a canonical user's messages across all of their
+// identifiers, limited to those whose metadata contains filter
+func (m *MessageService) GetMessagesByUserID(ctx context.Context, userID uuid.UUID, filter models.Metadata, limit int, offset int) ([]*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "get_messages_by_user_id")
+	defer cancel()
+
+	if filter == nil {
+		filter = models.Metadata{}
+	}
+
+	rows, err := m.db.Query(ctx, messagesByUserIDQuery, userID, limit, offset, filter)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to query messages by user ID")
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+
+	messages, err := collectMessages(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error reading messages: %w", err)
+	}
+	if messages == nil {
+		messages = []*models.WhatsAppMessage{}
+	}
+
+	return messages, nil
+}
+
 // GetRecentMessages retrieves recent messages across all users
 func (m *MessageService) GetRecentMessages(ctx context.Context, limit int) ([]*models.WhatsAppMessage, error) {
-	m.logger.WithField("limit", limit).Info("Retrieving recent messages")
+	ctx, cancel := m.query(ctx, "get_recent_messages")
+	defer cancel()
 
-	query := `
-		SELECT id, twilio_sid, from_number, to_number, direction, message_type,
-			   status, content, media_url, media_type, timestamp, created_at, updated_at,
-			   user_id, session_id, error_code, error_message
-		FROM whatsapp_messages 
-		ORDER BY timestamp DESC
-		LIMIT $1`
+	m.logger.WithField("limit", limit).Info("Retrieving recent messages")
 
-	rows, err := m.db.Query(ctx, query, limit)
+	rows, err := m.db.Query(ctx, recentMessagesQuery, limit)
 	if err != nil {
 		m.logger.WithError(err).Error("Failed to query recent messages")
 		return nil, fmt.Errorf("failed to query recent messages: %w", err)
 	}
-	defer rows.Close()
-
-	var messages []*models.WhatsAppMessage
-	for rows.Next() {
-		var message models.WhatsAppMessage
-		err := rows.Scan(
-			&message.ID,
-			&message.TwilioSID,
-			&message.From,
-			&message.To,
-			&message.Direction,
-			&message.Type,
-			&message.Status,
-			&message.Content,
-			&message.MediaURL,
-			&message.MediaType,
-			&message.Timestamp,
-			&message.CreatedAt,
-			&message.UpdatedAt,
-			&message.UserID,
-			&message.SessionID,
-			&message.ErrorCode,
-			&message.ErrorMsg,
-		)
-		if err != nil {
-			m.logger.WithError(err).Error("Failed to scan message row")
-			continue
-		}
-		messages = append(messages, &message)
-	}
 
-	if err := rows.Err(); err != nil {
+	messages, err := collectMessages(rows)
+	if err != nil {
 		m.logger.WithError(err).Error("Error iterating over recent message rows")
 		return nil, fmt.Errorf("error reading recent messages: %w", err)
 	}
 
 	m.logger.WithField("messages_found", len(messages)).Info("Recent messages retrieved successfully")
 	return messages, nil
-}
\ No newline at end of file
+}
+
+// GetMessagesBySession retrieves all messages of a chat session in chronological order.
+// The session start bounds the timestamp so only the partitions it spans are scanned.
+func (m *MessageService) GetMessagesBySession(ctx context.Context, sessionID uuid.UUID) ([]*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "get_messages_by_session")
+	defer cancel()
+
+	m.logger.WithField("session_id", sessionID).Info("Retrieving session transcript")
+
+	rows, err := m.db.Query(ctx, messagesBySessionQuery, sessionID)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to query session messages")
+		return nil, fmt.Errorf("failed to query session messages: %w", err)
+	}
+
+	messages, err := collectMessages(rows)
+	if err != nil {
+		m.logger.WithError(err).Error("Error iterating over session message rows")
+		return nil, fmt.Errorf("error reading session messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// GetSessionHistory retrieves the latest messages of a chat session in chronological
+// order, excluding the given message
+func (m *MessageService) GetSessionHistory(ctx context.Context, sessionID, excludeID uuid.UUID, limit int) ([]*models.WhatsAppMessage, error) {
+	ctx, cancel := m.query(ctx, "get_session_history")
+	defer cancel()
+
+	rows, err := m.db.Query(ctx, sessionHistoryQuery, sessionID, excludeID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session history: %w", err)
+	}
+
+	messages, err := collectMessages(rows)
+	if err != nil {
+		return nil, fmt.Errorf("error reading session history: %w", err)
+	}
+
+	return messages, nil
+}
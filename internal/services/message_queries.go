@@ -0,0 +1,171 @@
+package services
+
+import (
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+)
+
+// messageRows maps whatsapp_messages columns to WhatsAppMessage fields by their db tags
+var messageRows = database.NewRowMapper[models.WhatsAppMessage]()
+
+// messageColumns lists the whatsapp_messages columns in the order scanMessageInto expects
+var messageColumns = messageRows.Columns()
+
+// messageInsertColumns are the columns StoreMessage writes; the others are set later
+var messageInsertColumns = []string{
+	"id", "twilio_sid", "from_number", "to_number", "direction", "message_type",
+	"status", "content", "media_url", "media_type", "timestamp", "created_at", "updated_at",
+	"user_id", "session_id", "error_code", "error_message", "flagged", "flag_reason",
+	"category", "fallback_of", "channel", "metadata", "flow_response", "webhook_event_id",
+	"imported_at", "raw_content", "original_content", "language",
+	"sentiment",
+}
+
+// Queries of MessageService
+var (
+	insertMessageQuery = messageRows.Insert("whatsapp_messages", messageInsertColumns...)
+
+	lockMessageSIDQuery = `SELECT pg_advisory_xact_lock(hashtext($1))`
+
+	messageSIDExistsQuery = `SELECT EXISTS (SELECT 1 FROM whatsapp_messages WHERE twilio_sid = $1)`
+
+	updateStoredMessageStatusQuery = `
+		UPDATE whatsapp_messages
+		SET status = $2, updated_at = NOW()
+		WHERE twilio_sid = $1
+		RETURNING id`
+
+	getMessageQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		WHERE id = $1`
+
+	updateMessageStatusQuery = `
+		UPDATE whatsapp_messages
+		SET status = $2, error_code = $3, error_message = $4, updated_at = $5
+		WHERE twilio_sid = $1
+		RETURNING id, channel, direction, message_type, from_number, to_number`
+
+	markCanceledQuery = `
+		UPDATE whatsapp_messages
+		SET status = 'canceled', updated_at = NOW()
+		WHERE id = $1 AND direction = 'outbound' AND status = 'pending'
+		RETURNING` + messageColumns
+
+	setLabelsQuery = `
+		UPDATE whatsapp_messages SET labels = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	claimFallbackQuery = `
+		UPDATE whatsapp_messages
+		SET fallback_attempted_at = NOW(), updated_at = NOW()
+		WHERE twilio_sid = $1 AND direction = 'outbound'
+			AND fallback_of IS NULL AND fallback_attempted_at IS NULL
+		RETURNING` + messageColumns
+
+	releaseFallbackQuery = `
+		UPDATE whatsapp_messages
+		SET fallback_attempted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND fallback_message_id IS NULL`
+
+	linkFallbackQuery = `
+		UPDATE whatsapp_messages
+		SET fallback_message_id = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	updateExtractedTextQuery = `
+		UPDATE whatsapp_messages
+		SET extracted_text = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	setMediaHashQuery = `
+		UPDATE whatsapp_messages
+		SET media_sha256 = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	transcriptByMediaHashQuery = `
+		SELECT transcript FROM whatsapp_messages
+		WHERE media_sha256 = $1 AND id <> $2 AND transcript IS NOT NULL AND transcript <> '' AND redacted_at IS NULL
+		ORDER BY timestamp DESC
+		LIMIT 1`
+
+	updateTranscriptQuery = `
+		UPDATE whatsapp_messages
+		SET transcript = $2, updated_at = NOW()
+		WHERE id = $1 AND transcript IS NULL`
+
+	awaitingTranscriptQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		WHERE message_type = 'audio' AND transcript IS NULL AND id IN (
+			SELECT message_id FROM media_processing_jobs
+			WHERE stage = 'analyzed' AND status = 'running' AND started_at < $1
+		)
+		LIMIT $2`
+
+	flagMessageQuery = `
+		UPDATE whatsapp_messages
+		SET flagged = true, flag_reason = $2, updated_at = NOW()
+		WHERE id = $1`
+
+	messagesByUserQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		WHERE from_number = $1 OR to_number = $1
+		ORDER BY timestamp DESC
+		LIMIT $2 OFFSET $3`
+
+	messagesByUserIDQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		WHERE user_id = $1 AND metadata @> $4
+		ORDER BY timestamp DESC
+		LIMIT $2 OFFSET $3`
+
+	recentMessagesQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		ORDER BY timestamp DESC
+		LIMIT $1`
+
+	// The session start bounds the timestamp so only the partitions it spans are scanned
+	messagesBySessionQuery = `
+		SELECT` + messageColumns + `
+		FROM whatsapp_messages
+		WHERE session_id = $1
+			AND timestamp >= (SELECT COALESCE(MIN(started_at), '-infinity') - INTERVAL '1 day' FROM chat_sessions WHERE id = $1)
+		ORDER BY timestamp ASC`
+
+	sessionHistoryQuery = `
+		SELECT * FROM (
+			SELECT` + messageColumns + `
+			FROM whatsapp_messages
+			WHERE session_id = $1 AND id <> $2 AND revoked_at IS NULL
+				AND timestamp >= (SELECT COALESCE(MIN(started_at), '-infinity') - INTERVAL '1 day' FROM chat_sessions WHERE id = $1)
+			ORDER BY timestamp DESC
+			LIMIT $3
+		) recent
+		ORDER BY timestamp ASC`
+)
+
+// scanMessageInto scans a whatsapp_messages row selected with messageColumns
+func scanMessageInto(row pgx.Row, message *models.WhatsAppMessage) error {
+	return messageRows.Scan(row, message)
+}
+
+// collectMessages reads all rows of a query selecting messageColumns and closes them
+func collectMessages(rows pgx.Rows) ([]*models.WhatsAppMessage, error) {
+	defer rows.Close()
+
+	var messages []*models.WhatsAppMessage
+	for rows.Next() {
+		var message models.WhatsAppMessage
+		if err := scanMessageInto(rows, &message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, rows.Err()
+}
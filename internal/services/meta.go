@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// metaMediaTypeLookupTimeout bounds the HEAD request used to learn an attachment's MIME type
+const metaMediaTypeLookupTimeout = 5 * time.Second
+
+// metaDefaultMediaTypes is used when Meta's CDN does not report an attachment's MIME type
+var metaDefaultMediaTypes = map[string]string{
+	"image": "image/jpeg",
+	"video": "video/mp4",
+	"audio": "audio/mp4",
+	"file":  "application/octet-stream",
+}
+
+// metaMessageTypes maps Meta attachment types to message types
+var metaMessageTypes = map[string]models.MessageType{
+	"image": models.MessageTypeImage,
+	"video": models.MessageTypeVideo,
+	"audio": models.MessageTypeAudio,
+	"file":  models.MessageTypeDocument,
+}
+
+// MetaService sends and receives Messenger or Instagram Direct messages via the Graph API
+type MetaService struct {
+	channel     models.Channel
+	httpClient  *http.Client
+	graphURL    string
+	pageID      string
+	accessToken string
+	logger      *logrus.Logger
+}
+
+// NewMetaService creates a Meta messaging service for the Messenger or Instagram channel
+func NewMetaService(channel models.Channel, cfg *config.Config, logger *logrus.Logger) *MetaService {
+	return &MetaService{
+		channel:     channel,
+		httpClient:  newHTTPClient(30 * time.Second),
+		graphURL:    strings.TrimRight(cfg.MetaGraphAPIURL, "/"),
+		pageID:      cfg.MetaPageID,
+		accessToken: cfg.MetaPageAccessToken,
+		logger:      logger,
+	}
+}
+
+// Channel returns the channel this service terminates
+func (s *MetaService) Channel() models.Channel {
+	return s.channel
+}
+
+// SendTextMessage sends a text message to a page-scoped user ID
+func (s *MetaService) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	return s.send(ctx, to, map[string]interface{}{"text": content})
+}
+
+// SendMediaMessage sends an attachment; Meta does not allow captions, so any text is sent first
+func (s *MetaService) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	if content != "" {
+		if _, err := s.SendTextMessage(ctx, to, content); err != nil {
+			return nil, err
+		}
+	}
+
+	attachment := map[string]interface{}{
+		"type": metaAttachmentType(mediaType),
+		"payload": map[string]interface{}{
+			"url":         mediaURL,
+			"is_reusable": false,
+		},
+	}
+
+	return s.send(ctx, to, map[string]interface{}{"attachment": attachment})
+}
+
+// SendTemplateMessage is not supported; WhatsApp content templates have no Meta rendering
+func (s *MetaService) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("template messages are not supported on %s", s.channel)
+}
+
+// Name identifies the provider
+func (s *MetaService) Name() string {
+	return "meta_" + string(s.channel)
+}
+
+// GetFromNumber returns the page ID messages are sent from
+func (s *MetaService) GetFromNumber() string {
+	return s.pageID
+}
+
+// ParseWebhookEvent converts a messaging event into an inbound message or a delivery
+// status update. Echoes of our own messages and unsupported events yield neither.
+func (s *MetaService) ParseWebhookEvent(ctx context.Context, event *models.MetaMessagingEvent) (*models.WhatsAppMessage, []*models.MessageStatusUpdate) {
+	if event.Delivery != nil {
+		updates := make([]*models.MessageStatusUpdate, 0, len(event.Delivery.MIDs))
+		for _, mid := range event.Delivery.MIDs {
+			updates = append(updates, &models.MessageStatusUpdate{
+				MessageSid:     mid,
+				Status:         models.MessageStatusDelivered,
+				Timestamp:      time.Now(),
+				ProviderStatus: "delivery",
+			})
+		}
+		return nil, updates
+	}
+
+	if event.Message == nil || event.Message.IsEcho {
+		return nil, nil
+	}
+
+	timestamp := time.UnixMilli(event.Timestamp)
+	if event.Timestamp == 0 {
+		timestamp = time.Now()
+	}
+
+	message := &models.WhatsAppMessage{
+		ID:        uuid.New(),
+		TwilioSID: event.Message.MID,
+		From:      event.Sender.ID,
+		To:        event.Recipient.ID,
+		Direction: models.MessageDirectionInbound,
+		Type:      models.MessageTypeText,
+		Status:    models.MessageStatusDelivered,
+		Content:   event.Message.Text,
+		Timestamp: timestamp,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Channel:   s.channel,
+	}
+
+	// Only the first attachment is kept, matching the WhatsApp pipeline
+	for _, attachment := range event.Message.Attachments {
+		if attachment.Payload.URL == "" {
+			continue
+		}
+
+		messageType, ok := metaMessageTypes[attachment.Type]
+		if !ok {
+			continue
+		}
+
+		mediaURL := attachment.Payload.URL
+		mediaType := s.lookupMediaType(ctx, mediaURL, attachment.Type)
+		message.Type = messageType
+		message.MediaURL = &mediaURL
+		message.MediaType = &mediaType
+		break
+	}
+
+	// Attachments we cannot keep, such as shared locations, are not empty text messages
+	if message.MediaURL == nil && message.Content == "" && len(event.Message.Attachments) > 0 {
+		message.UnsupportedType = event.Message.Attachments[0].Type
+	}
+
+	return message, nil
+}
+
+// Helper methods
+
+// metaAttachmentType maps a MIME type to a Meta attachment type
+func metaAttachmentType(mediaType string) string {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "video/"):
+		return "video"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "audio"
+	default:
+		return "file"
+	}
+}
+
+// send posts a message to the Graph API Send API
+func (s *MetaService) send(ctx context.Context, to string, message map[string]interface{}) (*models.SendMessageResponse, error) {
+	payload := map[string]interface{}{
+		"recipient":      map[string]string{"id": to},
+		"messaging_type": "RESPONSE",
+		"message":        message,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s message: %w", s.channel, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.graphURL+"/me/messages", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s send request: %w", s.channel, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.WithError(err).WithField("channel", s.channel).Error("Failed to send Meta message")
+		return nil, fmt.Errorf("failed to send %s message: %w", s.channel, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		s.logger.WithFields(logrus.Fields{
+			"channel":     s.channel,
+			"status_code": resp.StatusCode,
+			"body":        string(body),
+		}).Error("Graph API rejected message")
+		return nil, fmt.Errorf("graph API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		RecipientID string `json:"recipient_id"`
+		MessageID   string `json:"message_id"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode graph API response: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"channel":    s.channel,
+		"message_id": result.MessageID,
+	}).Info("Meta message sent successfully")
+
+	return &models.SendMessageResponse{
+		ID:        uuid.New(),
+		TwilioSID: result.MessageID,
+		Status:    models.MessageStatusSent,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// lookupMediaType asks Meta's CDN for an attachment's MIME type, falling back to a
+// default for the attachment type so the media policy can still be applied
+func (s *MetaService) lookupMediaType(ctx context.Context, mediaURL, attachmentType string) string {
+	ctx, cancel := context.WithTimeout(ctx, metaMediaTypeLookupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mediaURL, nil)
+	if err == nil {
+		resp, err := s.httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			contentType := resp.Header.Get("Content-Type")
+			if resp.StatusCode == http.StatusOK && contentType != "" {
+				return contentType
+			}
+		}
+	}
+
+	return metaDefaultMediaTypes[attachmentType]
+}
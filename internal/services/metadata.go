@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrInvalidMetadata is returned for metadata patches that cannot be applied
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// maxMetadataBytes bounds the encoded size of one metadata patch
+const maxMetadataBytes = 16 << 10
+
+// UpdateMetadata applies a patch to a message's metadata and returns the result
+func (m *MessageService) UpdateMetadata(ctx context.Context, messageID uuid.UUID, patch models.MetadataPatch) (models.Metadata, error) {
+	ctx, cancel := m.query(ctx, "update_metadata")
+	defer cancel()
+
+	set, remove, err := splitMetadataPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata models.Metadata
+	err = m.db.QueryRow(ctx, `
+		UPDATE whatsapp_messages
+		SET metadata = (metadata || $2) - $3::text[], updated_at = NOW()
+		WHERE id = $1
+		RETURNING metadata`, messageID, set, remove).Scan(&metadata)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to update message metadata: %w", err)
+	}
+
+	m.InvalidateCache(ctx, messageID)
+	return metadata, nil
+}
+
+// UpdateMetadata applies a patch to a session's metadata and returns the result
+func (s *SessionService) UpdateMetadata(ctx context.Context, sessionID uuid.UUID, patch models.MetadataPatch) (models.Metadata, error) {
+	set, remove, err := splitMetadataPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata models.Metadata
+	err = s.db.QueryRow(ctx, `
+		UPDATE chat_sessions
+		SET metadata = (metadata || $2) - $3::text[], updated_at = NOW()
+		WHERE id = $1
+		RETURNING metadata`, sessionID, set, remove).Scan(&metadata)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to update session metadata: %w", err)
+	}
+	s.store.Forget(ctx, sessionID)
+
+	return metadata, nil
+}
+
+// Helper functions
+
+// splitMetadataPatch separates the keys a patch sets from the keys it removes
+func splitMetadataPatch(patch models.MetadataPatch) (models.Metadata, []string, error) {
+	if len(patch) == 0 {
+		return nil, nil, fmt.Errorf("%w: patch is empty", ErrInvalidMetadata)
+	}
+
+	encoded, err := json.Marshal(patch)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if len(encoded) > maxMetadataBytes {
+		return nil, nil, fmt.Errorf("%w: patch exceeds %d bytes", ErrInvalidMetadata, maxMetadataBytes)
+	}
+
+	set := models.Metadata{}
+	remove := []string{}
+	for key, value := range patch {
+		if strings.TrimSpace(key) == "" {
+			return nil, nil, fmt.Errorf("%w: empty key", ErrInvalidMetadata)
+		}
+		if value == nil {
+			remove = append(remove, key)
+			continue
+		}
+		set[key] = value
+	}
+	return set, remove, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// defaultMetricTenant labels numbers not assigned to a tenant
+const defaultMetricTenant = "default"
+
+// Guards for the unbounded message labels
+var (
+	tenantLabels = metrics.NewLabelGuard("tenant", 20)
+	numberLabels = metrics.NewLabelGuard("number", 50)
+)
+
+// metricTenants maps our normalized numbers to tenant names
+var metricTenants = map[string]string{}
+
+// ConfigureMetricLabels applies the tenant map and the label limits. It is called once
+// at startup, before any message is processed.
+func ConfigureMetricLabels(cfg *config.Config) {
+	tenantLabels.SetLimit(cfg.MetricsMaxTenants)
+	numberLabels.SetLimit(cfg.MetricsMaxNumbers)
+
+	metricTenants = make(map[string]string, len(cfg.MetricsTenants))
+	for number, tenant := range cfg.MetricsTenants {
+		metricTenants[normalizePhoneNumber(number)] = tenant
+	}
+}
+
+// MessageMetricLabels returns the tenant, number, type and provider label values of a
+// message. The number is ours: the sender of outbound and the recipient of inbound
+// messages.
+func MessageMetricLabels(message *models.WhatsAppMessage) []string {
+	return messageDimensionLabels(message.Channel, message.Direction, message.Type, message.From, message.To)
+}
+
+// messageDimensionLabels is MessageMetricLabels for a message's individual fields
+func messageDimensionLabels(channel models.Channel, direction models.MessageDirection, messageType models.MessageType, from, to string) []string {
+	number := to
+	if direction == models.MessageDirectionOutbound {
+		number = from
+	}
+	number = normalizeRecipient(channel, number)
+
+	tenant, ok := metricTenants[number]
+	if !ok {
+		tenant = defaultMetricTenant
+	}
+
+	return []string{
+		tenantLabels.Value(tenant),
+		numberLabels.Value(number),
+		string(messageType),
+		metricProvider(channel),
+	}
+}
+
+// messageTenant returns the tenant our number in a message is assigned to
+func messageTenant(message *models.WhatsAppMessage) string {
+	number := message.To
+	if message.Direction == models.MessageDirectionOutbound {
+		number = message.From
+	}
+	if tenant, ok := metricTenants[normalizeRecipient(message.Channel, number)]; ok {
+		return tenant
+	}
+	return defaultMetricTenant
+}
+
+// metricProvider names the provider a channel is served by
+func metricProvider(channel models.Channel) string {
+	switch channel {
+	case models.ChannelWhatsApp, models.ChannelSMS:
+		return "twilio"
+	case models.ChannelMessenger, models.ChannelInstagram:
+		return "meta"
+	case "":
+		return "twilio"
+	}
+	return string(channel)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var messagesNormalizedTotal = metrics.NewCounter("messages_normalized_total", "Inbound messages whose text the normalization stage changed", "channel")
+
+// normalizedTypes are the message types whose content is text the user typed; the
+// content of other types is structured (locations, contacts, flow answers)
+var normalizedTypes = map[models.MessageType]bool{
+	models.MessageTypeText:     true,
+	models.MessageTypeImage:    true,
+	models.MessageTypeVideo:    true,
+	models.MessageTypeAudio:    true,
+	models.MessageTypeDocument: true,
+}
+
+// InboundNormalizer rewrites the text of inbound messages into a canonical form before
+// it is stored, classified and forwarded, so stray whitespace, stretched words and
+// differently composed accents do not defeat keyword automations or the classifier.
+// The text as received is kept in the message's raw content.
+type InboundNormalizer struct {
+	form           norm.Form
+	normalizeForm  bool
+	maxRepeat      int
+	stripSkinTones bool
+}
+
+// NewInboundNormalizer creates an inbound normalizer from the configuration
+func NewInboundNormalizer(cfg *config.Config) *InboundNormalizer {
+	normalizer := &InboundNormalizer{
+		maxRepeat:      cfg.InboundMaxRepeat,
+		stripSkinTones: cfg.InboundStripSkinTones,
+	}
+	switch cfg.InboundUnicodeForm {
+	case "nfc":
+		normalizer.form, normalizer.normalizeForm = norm.NFC, true
+	case "nfkc":
+		normalizer.form, normalizer.normalizeForm = norm.NFKC, true
+	}
+	return normalizer
+}
+
+// Normalize rewrites the content of an inbound message and reports whether it changed
+func (n *InboundNormalizer) Normalize(message *models.WhatsAppMessage) bool {
+	if !normalizedTypes[message.Type] || message.Content == "" {
+		return false
+	}
+
+	normalized := n.Text(message.Content)
+	if normalized == message.Content {
+		return false
+	}
+
+	raw := message.Content
+	message.RawContent = &raw
+	message.Content = normalized
+	messagesNormalizedTotal.Inc(string(message.Channel))
+	return true
+}
+
+// Text normalizes the Unicode form, drops skin tone modifiers, collapses whitespace
+// and shortens runs of a repeated character
+func (n *InboundNormalizer) Text(text string) string {
+	if n.normalizeForm {
+		text = n.form.String(text)
+	}
+	if n.stripSkinTones {
+		text = strings.Map(func(r rune) rune {
+			if isSkinToneModifier(r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+	text = collapseWhitespace(text)
+	if n.maxRepeat > 0 {
+		text = collapseRepeats(text, n.maxRepeat)
+	}
+	return text
+}
+
+// isSkinToneModifier reports whether r is one of the Fitzpatrick emoji modifiers
+func isSkinToneModifier(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// collapseWhitespace trims the text and its lines, turns runs of spaces and tabs into
+// one space and keeps at most one blank line between paragraphs
+func collapseWhitespace(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	kept := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+			return unicode.IsSpace(r) && r != '\n'
+		}), " ")
+		if line == "" {
+			blank = len(kept) > 0
+			continue
+		}
+		if blank {
+			kept = append(kept, "")
+			blank = false
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// collapseRepeats shortens runs of the same character to max. Digits are left alone,
+// since "1000000" is an amount and not emphasis.
+func collapseRepeats(text string, max int) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	var previous rune
+	run := 0
+	for _, r := range text {
+		if r == previous {
+			run++
+		} else {
+			previous, run = r, 1
+		}
+		if run > max && !unicode.IsDigit(r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestInboundNormalizerText(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		in   string
+		want string
+	}{
+		{"collapses whitespace", config.Config{}, "  hello \t  there  ", "hello there"},
+		{"keeps one blank line", config.Config{}, "a\r\n\r\n\r\nb\n", "a\n\nb"},
+		{"shortens repeats", config.Config{InboundMaxRepeat: 2}, "sooooo goooood!!!!", "soo good!!"},
+		{"keeps digit runs", config.Config{InboundMaxRepeat: 2}, "R$ 1000000", "R$ 1000000"},
+		{"no repeat limit", config.Config{}, "sooooo", "sooooo"},
+		{"composes accents", config.Config{InboundUnicodeForm: "nfc"}, "cafe\u0301", "caf\u00e9"},
+		{"leaves form alone", config.Config{InboundUnicodeForm: "none"}, "cafe\u0301", "cafe\u0301"},
+		{"folds compatibility forms", config.Config{InboundUnicodeForm: "nfkc"}, "ＡＢ", "AB"},
+		{"strips skin tones", config.Config{InboundStripSkinTones: true}, "ok \U0001F44D\U0001F3FD", "ok \U0001F44D"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewInboundNormalizer(&tt.cfg).Text(tt.in); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInboundNormalizerNormalize(t *testing.T) {
+	normalizer := NewInboundNormalizer(&config.Config{InboundMaxRepeat: 3})
+
+	message := &models.WhatsAppMessage{Type: models.MessageTypeText, Content: " heyyyyy "}
+	if !normalizer.Normalize(message) {
+		t.Fatal("Normalize() = false, want true")
+	}
+	if message.Content != "heyyy" {
+		t.Errorf("Content = %q, want %q", message.Content, "heyyy")
+	}
+	if message.RawContent == nil || *message.RawContent != " heyyyyy " {
+		t.Errorf("RawContent = %v, want the text as received", message.RawContent)
+	}
+
+	location := &models.WhatsAppMessage{Type: models.MessageTypeLocation, Content: "1.0,  2.0"}
+	if normalizer.Normalize(location) || location.Content != "1.0,  2.0" {
+		t.Errorf("Normalize() changed a location: %q", location.Content)
+	}
+}
+
+func BenchmarkInboundNormalizerText(b *testing.B) {
+	normalizer := NewInboundNormalizer(&config.Config{
+		InboundUnicodeForm:    "nfc",
+		InboundMaxRepeat:      3,
+		InboundStripSkinTones: true,
+	})
+	text := strings.Repeat("Olá!!!!!  Gostaria de marcar uma visita   na sexta-feira \U0001F44D\U0001F3FD\n\n\n", 4)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		normalizer.Text(text)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Periods of the proactive message caps
+const (
+	NotificationPeriodDay  = "day"
+	NotificationPeriodWeek = "week"
+)
+
+var notificationsCappedTotal = metrics.NewCounter("notifications_capped_total", "Proactive sends refused by a per-recipient cap, by category and period", "category", "period")
+
+// notificationCapScript adds ARGV[1] to the counters in KEYS when none of them would
+// exceed its cap in ARGV[2..]; counter i expires after ARGV[len(KEYS)+1+i] seconds. It
+// returns 0 when the weight was counted, or the 1-based index of the first counter that
+// would exceed its cap, followed by that counter's value.
+var notificationCapScript = redis.NewScript(`
+local weight = tonumber(ARGV[1])
+local n = #KEYS
+for i = 1, n do
+	local used = tonumber(redis.call('GET', KEYS[i]) or '0')
+	if used + weight > tonumber(ARGV[1 + i]) then
+		return {i, used}
+	end
+end
+for i = 1, n do
+	redis.call('INCRBY', KEYS[i], weight)
+	redis.call('EXPIRE', KEYS[i], tonumber(ARGV[1 + n + i]))
+end
+return {0, 0}
+`)
+
+// NotificationCapError is returned when a proactive send would exceed a recipient's cap
+type NotificationCapError struct {
+	Period string `json:"period"`
+	Cap    int    `json:"cap"`
+	Used   int    `json:"used"`
+}
+
+func (e *NotificationCapError) Error() string {
+	return fmt.Sprintf("recipient reached the %s cap of %d proactive messages (%d used)", e.Period, e.Cap, e.Used)
+}
+
+// NotificationClaim is the weight a proactive send counted against its recipient's caps.
+// A nil claim means the send was not capped.
+type NotificationClaim struct {
+	keys   []string
+	weight int
+}
+
+// notificationCounter is one cap a send is counted against
+type notificationCounter struct {
+	period string
+	key    string
+	cap    int
+	ttl    time.Duration
+}
+
+// NotificationCapService limits how many proactive messages, sent while the recipient's
+// customer service window is closed, each recipient gets per calendar day and week (UTC).
+// Sends count the weight of their category; exempt categories are never capped.
+// Counters live in Redis, and Redis failures let sends through.
+type NotificationCapService struct {
+	redis          *redis.Client
+	sessionService *SessionService
+	config         *config.Config
+	weights        map[string]int
+	exempt         map[string]bool
+	logger         *logrus.Logger
+}
+
+// NewNotificationCapService creates a new notification cap service
+func NewNotificationCapService(redisClient *redis.Client, sessionService *SessionService, cfg *config.Config, logger *logrus.Logger) *NotificationCapService {
+	weights := make(map[string]int, len(cfg.NotificationCategoryWeights))
+	for category, weight := range cfg.NotificationCategoryWeights {
+		if n, err := strconv.Atoi(weight); err == nil {
+			weights[strings.ToLower(category)] = n
+		}
+	}
+	exempt := make(map[string]bool, len(cfg.NotificationExemptCategories))
+	for _, category := range cfg.NotificationExemptCategories {
+		exempt[strings.ToLower(category)] = true
+	}
+
+	return &NotificationCapService{
+		redis:          redisClient,
+		sessionService: sessionService,
+		config:         cfg,
+		weights:        weights,
+		exempt:         exempt,
+		logger:         logger,
+	}
+}
+
+// Enabled reports whether any cap is configured
+func (s *NotificationCapService) Enabled() bool {
+	return s.config.NotificationDailyCap > 0 || s.config.NotificationWeeklyCap > 0
+}
+
+// Claim counts a send against its recipient's caps. It returns the claim, to be passed
+// to Release if the send fails, or a *NotificationCapError when a cap would be exceeded.
+// Sends that are not proactive, exempt or weightless return a nil claim.
+func (s *NotificationCapService) Claim(ctx context.Context, request *models.SendMessageRequest) (*NotificationClaim, error) {
+	counters, weight, err := s.counters(ctx, request)
+	if err != nil || len(counters) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(counters))
+	args := make([]interface{}, 0, 1+2*len(counters))
+	args = append(args, weight)
+	for i, counter := range counters {
+		keys[i] = counter.key
+		args = append(args, counter.cap)
+	}
+	for _, counter := range counters {
+		args = append(args, int(counter.ttl.Seconds()))
+	}
+
+	result, err := notificationCapScript.Run(ctx, s.redis, keys, args...).Int64Slice()
+	if err != nil || len(result) != 2 {
+		s.logger.WithError(err).Warn("Failed to count proactive send against caps, sending anyway")
+		return nil, nil
+	}
+	if result[0] > 0 {
+		counter := counters[result[0]-1]
+		notificationsCappedTotal.Inc(s.metricCategory(request.Category), counter.period)
+		return nil, &NotificationCapError{Period: counter.period, Cap: counter.cap, Used: int(result[1])}
+	}
+
+	return &NotificationClaim{keys: keys, weight: weight}, nil
+}
+
+// Release gives back the weight of a claim after a failed send
+func (s *NotificationCapService) Release(ctx context.Context, claim *NotificationClaim) {
+	if claim == nil {
+		return
+	}
+	for _, key := range claim.keys {
+		if err := s.redis.DecrBy(ctx, key, int64(claim.weight)).Err(); err != nil {
+			s.logger.WithError(err).WithField("key", key).Warn("Failed to release proactive send claim")
+		}
+	}
+}
+
+// Check reports whether a send would exceed its recipient's caps, without counting it
+func (s *NotificationCapService) Check(ctx context.Context, request *models.SendMessageRequest) error {
+	counters, weight, err := s.counters(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	for _, counter := range counters {
+		used, err := s.redis.Get(ctx, counter.key).Int()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			s.logger.WithError(err).Warn("Failed to read proactive send counter")
+			return nil
+		}
+		if used+weight > counter.cap {
+			return &NotificationCapError{Period: counter.period, Cap: counter.cap, Used: used}
+		}
+	}
+	return nil
+}
+
+// Helper methods
+
+// counters returns the caps a send is counted against and its weight; none when the
+// send is not capped
+func (s *NotificationCapService) counters(ctx context.Context, request *models.SendMessageRequest) ([]notificationCounter, int, error) {
+	if !s.Enabled() {
+		return nil, 0, nil
+	}
+
+	category := categoryLabel(request.Category)
+	if s.exempt[category] {
+		return nil, 0, nil
+	}
+	weight, ok := s.weights[category]
+	if !ok {
+		weight = 1
+	}
+	if weight == 0 {
+		return nil, 0, nil
+	}
+
+	channel := request.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	// Replies within the customer service window are not proactive
+	window, err := s.sessionService.CustomerServiceWindow(ctx, channel, request.To)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check customer service window: %w", err)
+	}
+	if window.Open {
+		return nil, 0, nil
+	}
+
+	now := time.Now().UTC()
+	prefix := redisKey(s.config, fmt.Sprintf("notify:cap:%s:%s:", channel, normalizeRecipient(channel, request.To)))
+
+	var counters []notificationCounter
+	if s.config.NotificationDailyCap > 0 {
+		counters = append(counters, notificationCounter{
+			period: NotificationPeriodDay,
+			key:    prefix + now.Format("2006-01-02"),
+			cap:    s.config.NotificationDailyCap,
+			ttl:    48 * time.Hour,
+		})
+	}
+	if s.config.NotificationWeeklyCap > 0 {
+		year, week := now.ISOWeek()
+		counters = append(counters, notificationCounter{
+			period: NotificationPeriodWeek,
+			key:    fmt.Sprintf("%s%d-W%02d", prefix, year, week),
+			cap:    s.config.NotificationWeeklyCap,
+			ttl:    8 * 24 * time.Hour,
+		})
+	}
+	return counters, weight, nil
+}
+
+// metricCategory bounds the category label to categories with a configured weight
+func (s *NotificationCapService) metricCategory(category *string) string {
+	label := categoryLabel(category)
+	if _, ok := s.weights[label]; ok || label == "none" {
+		return label
+	}
+	return "other"
+}
+
+// categoryLabel returns the lowercased category of a send, or "none"
+func categoryLabel(category *string) string {
+	if category == nil || strings.TrimSpace(*category) == "" {
+		return "none"
+	}
+	return strings.ToLower(strings.TrimSpace(*category))
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// OCRProvider extracts text from an image
+type OCRProvider interface {
+	ExtractText(ctx context.Context, image []byte) (string, error)
+}
+
+// TesseractOCR runs the tesseract CLI to extract text from images
+type TesseractOCR struct {
+	binaryPath string
+	languages  string
+}
+
+// NewTesseractOCR creates a new tesseract OCR provider
+func NewTesseractOCR(binaryPath, languages string) *TesseractOCR {
+	return &TesseractOCR{
+		binaryPath: binaryPath,
+		languages:  languages,
+	}
+}
+
+// ExtractText pipes the image through tesseract and returns the recognized text
+func (t *TesseractOCR) ExtractText(ctx context.Context, image []byte) (string, error) {
+	args := []string{"stdin", "stdout"}
+	if t.languages != "" {
+		args = append(args, "-l", t.languages)
+	}
+
+	cmd := exec.CommandContext(ctx, t.binaryPath, args...)
+	cmd.Stdin = bytes.NewReader(image)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// countTextChars counts letters and digits, ignoring OCR noise like whitespace and symbols
+func countTextChars(text string) int {
+	count := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			count++
+		}
+	}
+	return count
+}
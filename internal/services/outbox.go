@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Outbox metrics
+var (
+	outboxProcessedTotal = metrics.NewCounter("outbox_entries_processed_total", "Outbox entry attempts by kind and outcome", "kind", "outcome")
+	outboxPending        = metrics.NewGauge("outbox_entries_pending", "Outbox entries waiting to be processed")
+)
+
+// outboxLease keeps an entry being processed away from other workers; an entry whose
+// worker died is picked up again once its lease expires. The lease is the entry's
+// next_attempt_at, renewed every outboxLeaseRenewal while its handler runs.
+const outboxLease = 2 * time.Minute
+
+// outboxLeaseRenewal is how often the lease of a running entry is extended
+const outboxLeaseRenewal = outboxLease / 4
+
+// outboxBatchSize bounds how many due entries one pass claims
+const outboxBatchSize = 100
+
+// outboxMaxBackoff caps the delay between attempts
+const outboxMaxBackoff = 10 * time.Minute
+
+// outboxColumns lists the outbox columns in the order scanOutboxEntry expects
+const outboxColumns = `
+	id, kind, message_id, payload, status, attempts, last_error, next_attempt_at,
+	processed_at, created_at, updated_at`
+
+// OutboxHandler processes one outbox entry; a returned error schedules a retry
+type OutboxHandler func(ctx context.Context, entry *models.OutboxEntry) error
+
+// OutboxService processes work recorded in the outbox table. Entries are written in the
+// same transaction as the data they concern, dispatched right after commit and swept up
+// by a background worker when that dispatch failed or the process died before it ran.
+type OutboxService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+
+	mu       sync.RWMutex
+	handlers map[string]OutboxHandler
+}
+
+// NewOutboxService creates a new outbox service
+func NewOutboxService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *OutboxService {
+	return &OutboxService{
+		db:       db,
+		config:   cfg,
+		logger:   logger,
+		handlers: make(map[string]OutboxHandler),
+	}
+}
+
+// NewOutboxEntry builds a pending entry whose payload is the JSON encoding of payload
+func NewOutboxEntry(kind string, messageID *uuid.UUID, payload interface{}) (*models.OutboxEntry, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	return &models.OutboxEntry{
+		ID:        uuid.New(),
+		Kind:      kind,
+		MessageID: messageID,
+		Payload:   data,
+		Status:    models.OutboxStatusPending,
+	}, nil
+}
+
+// Handle registers the handler of an entry kind
+func (s *OutboxService) Handle(kind string, handler OutboxHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[kind] = handler
+}
+
+// Enqueue stores entries outside of any other transaction and dispatches them
+func (s *OutboxService) Enqueue(ctx context.Context, entries ...*models.OutboxEntry) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin outbox write: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertOutboxEntries(ctx, tx, entries); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit outbox write: %w", err)
+	}
+
+	s.Dispatch(entries...)
+	return nil
+}
+
+// Dispatch processes committed entries in the background right away
+func (s *OutboxService) Dispatch(entries ...*models.OutboxEntry) {
+	for _, entry := range entries {
+		go func(entry *models.OutboxEntry) {
+			defer func() {
+				if r := recover(); r != nil {
+					s.logger.WithFields(logrus.Fields{
+						"outbox_id": entry.ID,
+						"kind":      entry.Kind,
+						"panic":     r,
+					}).Error("Outbox dispatch panicked; the entry is retried once its lease expires")
+				}
+			}()
+			s.process(context.Background(), entry)
+		}(entry)
+	}
+}
+
+// Start processes due entries every poll interval until ctx is done
+func (s *OutboxService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.OutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ProcessDue(ctx); err != nil {
+				s.logger.WithError(err).Error("Outbox pass failed")
+			}
+		}
+	}
+}
+
+// ProcessDue claims pending entries whose next attempt is due and processes them, then
+// removes processed entries older than the retention period
+func (s *OutboxService) ProcessDue(ctx context.Context) error {
+	query := `
+		UPDATE outbox
+		SET next_attempt_at = $1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING` + outboxColumns
+
+	rows, err := s.db.Query(ctx, query, newOutboxLease(), outboxBatchSize)
+	if err != nil {
+		return fmt.Errorf("failed to claim due outbox entries: %w", err)
+	}
+
+	var entries []*models.OutboxEntry
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading due outbox entries: %w", err)
+	}
+
+	for _, entry := range entries {
+		s.process(ctx, entry)
+	}
+
+	if _, err := s.db.Exec(ctx, `
+		DELETE FROM outbox WHERE status = 'done' AND processed_at < $1`,
+		time.Now().Add(-s.config.OutboxRetention),
+	); err != nil {
+		return fmt.Errorf("failed to prune outbox: %w", err)
+	}
+
+	var pending int
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM outbox WHERE status = 'pending'`).Scan(&pending); err != nil {
+		return fmt.Errorf("failed to count pending outbox entries: %w", err)
+	}
+	outboxPending.Set(float64(pending))
+
+	return nil
+}
+
+// Helper methods
+
+// process runs an entry's handler while renewing its lease, and records the outcome
+// unless the lease was lost to another worker in the meantime
+func (s *OutboxService) process(ctx context.Context, entry *models.OutboxEntry) {
+	s.mu.RLock()
+	handler, ok := s.handlers[entry.Kind]
+	s.mu.RUnlock()
+
+	var lease time.Time
+	if entry.NextAttemptAt != nil {
+		lease = *entry.NextAttemptAt
+	}
+	stop := make(chan struct{})
+	renewed := make(chan time.Time, 1)
+	go func() { renewed <- s.renewLease(entry, lease, stop) }()
+
+	var err error
+	if ok {
+		err = runOutboxHandler(ctx, handler, entry)
+	} else {
+		err = fmt.Errorf("no handler for outbox entry kind %q", entry.Kind)
+	}
+
+	close(stop)
+	lease = <-renewed
+
+	attempt := entry.Attempts + 1
+	entryLog := s.logger.WithFields(logrus.Fields{
+		"outbox_id":  entry.ID,
+		"kind":       entry.Kind,
+		"message_id": entry.MessageID,
+		"attempt":    attempt,
+	})
+
+	// Every outcome is only recorded while this worker still holds the lease ($2)
+	var query, outcome string
+	var args []interface{}
+	switch {
+	case err == nil:
+		outcome = "done"
+		query = `
+			UPDATE outbox
+			SET status = 'done', attempts = $3, last_error = NULL, next_attempt_at = NULL,
+				processed_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2`
+		args = []interface{}{entry.ID, lease, attempt}
+
+	case attempt >= s.config.OutboxMaxAttempts:
+		outcome = "failed"
+		entryLog.WithError(err).Error("Outbox entry failed permanently")
+		query = `
+			UPDATE outbox
+			SET status = 'failed', attempts = $3, last_error = $4, next_attempt_at = NULL,
+				updated_at = NOW()
+			WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2`
+		args = []interface{}{entry.ID, lease, attempt, err.Error()}
+
+	default:
+		outcome = "retry"
+		nextAttempt := time.Now().Add(s.backoff(attempt))
+		entryLog.WithError(err).WithField("next_attempt_at", nextAttempt).Warn("Outbox entry failed, will retry")
+		query = `
+			UPDATE outbox
+			SET attempts = $3, last_error = $4, next_attempt_at = $5, updated_at = NOW()
+			WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2`
+		args = []interface{}{entry.ID, lease, attempt, err.Error(), nextAttempt}
+	}
+
+	tag, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		entryLog.WithError(err).Error("Failed to record outbox entry outcome")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		outboxProcessedTotal.Inc(entry.Kind, "lost")
+		entryLog.Warn("Outbox entry lease was lost to another worker, outcome not recorded")
+		return
+	}
+	outboxProcessedTotal.Inc(entry.Kind, outcome)
+}
+
+// renewLease extends the lease of an entry every outboxLeaseRenewal until stop is
+// closed, and returns the lease then held. It stops renewing once the lease was taken
+// over, which the outcome update then detects.
+func (s *OutboxService) renewLease(entry *models.OutboxEntry, lease time.Time, stop <-chan struct{}) time.Time {
+	ticker := time.NewTicker(outboxLeaseRenewal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return lease
+		case <-ticker.C:
+			next := newOutboxLease()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			tag, err := s.db.Exec(ctx, `
+				UPDATE outbox SET next_attempt_at = $3, updated_at = NOW()
+				WHERE id = $1 AND status = 'pending' AND next_attempt_at = $2`,
+				entry.ID, lease, next,
+			)
+			cancel()
+			switch {
+			case err != nil:
+				s.logger.WithError(err).WithField("outbox_id", entry.ID).Warn("Failed to renew outbox lease")
+			case tag.RowsAffected() == 0:
+				s.logger.WithField("outbox_id", entry.ID).Warn("Outbox entry lease was taken over while its handler ran")
+				<-stop
+				return lease
+			default:
+				lease = next
+			}
+		}
+	}
+}
+
+// runOutboxHandler runs a handler, turning a panic into an error so the entry is
+// retried instead of the process crashing
+func runOutboxHandler(ctx context.Context, handler OutboxHandler, entry *models.OutboxEntry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("outbox handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, entry)
+}
+
+// newOutboxLease returns the end of a lease taken now. Postgres keeps microseconds, so
+// the lease is truncated to them to compare equal once stored.
+func newOutboxLease() time.Time {
+	return time.Now().Add(outboxLease).Truncate(time.Microsecond)
+}
+
+// backoff returns the delay before the attempt after the given one
+func (s *OutboxService) backoff(attempt int) time.Duration {
+	delay := s.config.OutboxPollInterval
+	for i := 1; i < attempt && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxBackoff {
+		delay = outboxMaxBackoff
+	}
+	return delay
+}
+
+// insertOutboxEntries writes entries through the given pool or transaction. They are
+// leased to the writer, which dispatches them after commit; the background worker only
+// picks them up if that never happens.
+func insertOutboxEntries(ctx context.Context, db dbExecer, entries []*models.OutboxEntry) error {
+	leasedUntil := newOutboxLease()
+	for _, entry := range entries {
+		entry.NextAttemptAt = &leasedUntil
+		_, err := db.Exec(ctx, `
+			INSERT INTO outbox (id, kind, message_id, payload, status, attempts, next_attempt_at, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, 'pending', 0, $5, NOW(), NOW())`,
+			entry.ID,
+			entry.Kind,
+			entry.MessageID,
+			entry.Payload,
+			leasedUntil,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write outbox entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanOutboxEntry scans an outbox row selected with outboxColumns
+func scanOutboxEntry(row pgx.Row) (*models.OutboxEntry, error) {
+	var entry models.OutboxEntry
+	err := row.Scan(
+		&entry.ID,
+		&entry.Kind,
+		&entry.MessageID,
+		&entry.Payload,
+		&entry.Status,
+		&entry.Attempts,
+		&entry.LastError,
+		&entry.NextAttemptAt,
+		&entry.ProcessedAt,
+		&entry.CreatedAt,
+		&entry.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestRunOutboxHandler(t *testing.T) {
+	errHandler := errors.New("orchestrator unavailable")
+
+	tests := []struct {
+		name    string
+		handler OutboxHandler
+		wantErr bool
+	}{
+		{"succeeds", func(ctx context.Context, entry *models.OutboxEntry) error { return nil }, false},
+		{"fails", func(ctx context.Context, entry *models.OutboxEntry) error { return errHandler }, true},
+		{"panics", func(ctx context.Context, entry *models.OutboxEntry) error {
+			var message *models.WhatsAppMessage
+			_ = message.Content
+			return nil
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runOutboxHandler(context.Background(), tt.handler, &models.OutboxEntry{Kind: "test"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runOutboxHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewOutboxLease(t *testing.T) {
+	lease := newOutboxLease()
+	if lease.Nanosecond()%1000 != 0 {
+		t.Errorf("lease %v has sub-microsecond precision Postgres would drop", lease)
+	}
+	if until := time.Until(lease); until <= outboxLease-time.Second || until > outboxLease {
+		t.Errorf("lease ends in %v, want about %v", until, outboxLease)
+	}
+}
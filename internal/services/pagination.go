@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+)
+
+// estimateTotal returns the planner's estimate of the rows a list query matches, or -1
+// when it cannot tell. A failed estimate never fails the list.
+func estimateTotal(ctx context.Context, db *pgxpool.Pool, logger *logrus.Logger, query string, args ...interface{}) int64 {
+	total, err := database.EstimateRows(ctx, db, query, args...)
+	if err != nil {
+		logger.WithError(err).Debug("Failed to estimate list total")
+		return -1
+	}
+	return total
+}
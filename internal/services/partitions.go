@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// messagePartitionsChanged counts partitions created and dropped by the maintenance job
+var messagePartitionsChanged = metrics.NewCounter("message_partitions_changed_total", "Message partitions created and dropped", "action")
+
+// PartitionService keeps the monthly whatsapp_messages partitions ahead of incoming
+// messages and drops the ones past retention
+type PartitionService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewPartitionService creates a new partition service
+func NewPartitionService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *PartitionService {
+	return &PartitionService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Start maintains partitions right away and then every maintenance interval until ctx
+// is done
+func (s *PartitionService) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PartitionMaintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Maintain(ctx, time.Now()); err != nil {
+			s.logger.WithError(err).Error("Message partition maintenance failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain creates the partitions up to the configured lookahead and drops those past
+// retention
+func (s *PartitionService) Maintain(ctx context.Context, now time.Time) error {
+	created, err := database.EnsureMessagePartitions(ctx, s.db, now, s.config.MessagePartitionsAhead)
+	if len(created) > 0 {
+		messagePartitionsChanged.Add(float64(len(created)), "created")
+		s.logger.WithField("partitions", created).Info("Created message partitions")
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.config.MessageRetentionMonths <= 0 {
+		return nil
+	}
+
+	current := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	cutoff := current.AddDate(0, -s.config.MessageRetentionMonths, 0)
+	dropped, err := database.DropMessagePartitions(ctx, s.db, cutoff)
+	if len(dropped) > 0 {
+		messagePartitionsChanged.Add(float64(len(dropped)), "dropped")
+		s.logger.WithFields(logrus.Fields{
+			"partitions": dropped,
+			"cutoff":     cutoff,
+		}).Info("Dropped message partitions past retention")
+	}
+	return err
+}
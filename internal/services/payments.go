@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var (
+	// ErrInvalidPayment is returned for payment requests that cannot be sent
+	ErrInvalidPayment = errors.New("invalid payment request")
+
+	// ErrPaymentTemplateMissing is returned when no order template is configured
+	ErrPaymentTemplateMissing = errors.New("no payment template configured")
+
+	// ErrPaymentExists is returned when a reference ID was already used
+	ErrPaymentExists = errors.New("payment reference already exists")
+
+	// ErrPaymentNotFound is returned for unknown payment references
+	ErrPaymentNotFound = errors.New("payment request not found")
+
+	// ErrPaymentMismatch is returned when a payment is reported paid with another amount
+	// or currency than was requested
+	ErrPaymentMismatch = errors.New("paid amount does not match the payment request")
+)
+
+var paymentRequestsTotal = metrics.NewCounter("payment_requests_total", "Payment requests by status they entered", "status")
+
+// paymentColumns lists the payment_requests columns in the order scanPayment expects
+const paymentColumns = `
+	id, reference_id, message_id, session_id, to_number, channel, currency, items,
+	total_amount, status, transaction_id, paid_at, created_at, updated_at`
+
+// PaymentService tracks payment requests sent as order templates and the status
+// updates the payment provider reports for them
+type PaymentService struct {
+	db               *pgxpool.Pool
+	referenceService *ReferenceService
+	eventService     *EventService
+	config           *appConfig.Config
+	logger           *logrus.Logger
+}
+
+// NewPaymentService creates a new payment service instance
+func NewPaymentService(db *pgxpool.Pool, referenceService *ReferenceService, eventService *EventService, cfg *appConfig.Config, logger *logrus.Logger) *PaymentService {
+	return &PaymentService{
+		db:               db,
+		referenceService: referenceService,
+		eventService:     eventService,
+		config:           cfg,
+		logger:           logger,
+	}
+}
+
+// Create validates a payment request, stores it as pending and returns the template
+// send that delivers it. The template receives the reference_id, currency, total and
+// items (JSON) variables unless the request sets them.
+func (s *PaymentService) Create(ctx context.Context, request *models.PaymentSendRequest) (*models.PaymentRequest, *models.SendMessageRequest, error) {
+	template := s.config.PaymentTemplateSID
+	if request.Template != nil && *request.Template != "" {
+		template = *request.Template
+	}
+	if template == "" {
+		return nil, nil, ErrPaymentTemplateMissing
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(request.Currency))
+	if currency == "" {
+		currency = s.config.PaymentCurrency
+	}
+	if len(currency) != 3 {
+		return nil, nil, fmt.Errorf("%w: currency must be an ISO 4217 code", ErrInvalidPayment)
+	}
+
+	referenceID := strings.TrimSpace(request.ReferenceID)
+	if referenceID == "" || len(referenceID) > maxRefIDLength {
+		return nil, nil, fmt.Errorf("%w: reference ID must be 1-%d characters", ErrInvalidPayment, maxRefIDLength)
+	}
+
+	if len(request.Items) == 0 {
+		return nil, nil, fmt.Errorf("%w: at least one item is required", ErrInvalidPayment)
+	}
+	var total int64
+	for i := range request.Items {
+		item := &request.Items[i]
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 || item.UnitAmount < 0 {
+			return nil, nil, fmt.Errorf("%w: item %q has a negative quantity or amount", ErrInvalidPayment, item.Name)
+		}
+		total += int64(item.Quantity) * item.UnitAmount
+	}
+	if total <= 0 {
+		return nil, nil, fmt.Errorf("%w: total must be positive", ErrInvalidPayment)
+	}
+
+	items, err := json.Marshal(request.Items)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode payment items: %w", err)
+	}
+
+	variables := map[string]string{}
+	for key, value := range request.Variables {
+		variables[key] = value
+	}
+	defaults := map[string]string{
+		"reference_id": referenceID,
+		"currency":     currency,
+		"total":        formatMinorAmount(total),
+		"items":        string(items),
+	}
+	for key, value := range defaults {
+		if _, ok := variables[key]; !ok {
+			variables[key] = value
+		}
+	}
+
+	content := request.Description
+	if content == "" {
+		content = fmt.Sprintf("Payment request %s: %s %s", referenceID, currency, formatMinorAmount(total))
+	}
+
+	payment := &models.PaymentRequest{
+		ID:          uuid.New(),
+		ReferenceID: referenceID,
+		To:          request.To,
+		Channel:     request.Channel,
+		Currency:    currency,
+		Items:       request.Items,
+		TotalAmount: total,
+		Status:      models.PaymentStatusPending,
+	}
+	if payment.Channel == "" {
+		payment.Channel = models.ChannelWhatsApp
+	}
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO payment_requests (
+			id, reference_id, to_number, channel, currency, items, total_amount, status,
+			created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+		ON CONFLICT (reference_id) DO NOTHING
+		RETURNING created_at, updated_at`,
+		payment.ID, payment.ReferenceID, payment.To, payment.Channel, payment.Currency,
+		items, payment.TotalAmount, payment.Status,
+	).Scan(&payment.CreatedAt, &payment.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, ErrPaymentExists
+		}
+		return nil, nil, fmt.Errorf("failed to store payment request: %w", err)
+	}
+	paymentRequestsTotal.Inc(string(models.PaymentStatusPending))
+
+	send := &models.SendMessageRequest{
+		To:        request.To,
+		Content:   content,
+		Type:      models.MessageTypePayment,
+		Template:  &template,
+		Variables: variables,
+		Channel:   request.Channel,
+		Provider:  request.Provider,
+	}
+	return payment, send, nil
+}
+
+// Sent links a payment request to the message that delivered it and, when the message
+// belongs to a session, stores the payment reference on that conversation
+func (s *PaymentService) Sent(ctx context.Context, payment *models.PaymentRequest, messageID uuid.UUID) error {
+	err := s.db.QueryRow(ctx, `
+		UPDATE payment_requests
+		SET message_id = $2,
+			session_id = (SELECT session_id FROM whatsapp_messages WHERE id = $2),
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING session_id`,
+		payment.ID, messageID,
+	).Scan(&payment.SessionID)
+	if err != nil {
+		return fmt.Errorf("failed to link payment request to message: %w", err)
+	}
+	payment.MessageID = &messageID
+
+	if payment.SessionID == nil {
+		return nil
+	}
+	return s.referenceService.Link(ctx, *payment.SessionID, &messageID, models.ReferenceKindPayment, models.ReferenceSourcePayment, payment.ReferenceID)
+}
+
+// Failed marks a payment request whose message could not be sent
+func (s *PaymentService) Failed(ctx context.Context, payment *models.PaymentRequest) error {
+	if _, err := s.db.Exec(ctx, `
+		UPDATE payment_requests SET status = $2, updated_at = NOW()
+		WHERE id = $1`, payment.ID, models.PaymentStatusFailed); err != nil {
+		return fmt.Errorf("failed to mark payment request failed: %w", err)
+	}
+	payment.Status = models.PaymentStatusFailed
+	paymentRequestsTotal.Inc(string(models.PaymentStatusFailed))
+	return nil
+}
+
+// UpdateStatus applies a status reported by the payment provider and publishes a
+// payment.updated event. Paid is only accepted with the requested amount and currency,
+// and is final: later updates of a paid request are ignored and the stored request is
+// returned unchanged.
+func (s *PaymentService) UpdateStatus(ctx context.Context, update *models.PaymentStatusUpdate) (*models.PaymentRequest, error) {
+	if !update.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPayment, update.Status)
+	}
+
+	if update.Status == models.PaymentStatusPaid {
+		if update.Amount == nil || update.Currency == "" {
+			return nil, fmt.Errorf("%w: amount and currency are required with status paid", ErrInvalidPayment)
+		}
+		current, err := s.Get(ctx, update.ReferenceID)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != models.PaymentStatusPaid &&
+			(*update.Amount != current.TotalAmount || !strings.EqualFold(update.Currency, current.Currency)) {
+			s.logger.WithFields(logrus.Fields{
+				"reference_id": current.ReferenceID,
+				"amount":       *update.Amount,
+				"currency":     update.Currency,
+			}).Warn("Rejected payment reported paid with another amount or currency")
+			return nil, ErrPaymentMismatch
+		}
+	}
+
+	var transactionID *string
+	if update.TransactionID != "" {
+		transactionID = &update.TransactionID
+	}
+
+	payment, err := scanPayment(s.db.QueryRow(ctx, `
+		UPDATE payment_requests
+		SET status = $2,
+			transaction_id = COALESCE($3, transaction_id),
+			paid_at = CASE WHEN $2 = 'paid' THEN NOW() ELSE paid_at END,
+			updated_at = NOW()
+		WHERE reference_id = $1 AND status <> 'paid'
+			AND ($2 <> 'paid' OR (total_amount = $4 AND currency = UPPER($5)))
+		RETURNING`+paymentColumns,
+		update.ReferenceID, update.Status, transactionID, update.Amount, update.Currency,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s.Get(ctx, update.ReferenceID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	}
+	paymentRequestsTotal.Inc(string(payment.Status))
+
+	s.logger.WithFields(logrus.Fields{
+		"reference_id": payment.ReferenceID,
+		"status":       payment.Status,
+	}).Info("Payment status updated")
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventPaymentUpdated,
+		MessageID: payment.MessageID,
+		SessionID: payment.SessionID,
+		Data: map[string]interface{}{
+			"reference_id":   payment.ReferenceID,
+			"status":         payment.Status,
+			"total_amount":   payment.TotalAmount,
+			"currency":       payment.Currency,
+			"transaction_id": payment.TransactionID,
+		},
+	})
+
+	return payment, nil
+}
+
+// Get returns a payment request by reference ID
+func (s *PaymentService) Get(ctx context.Context, referenceID string) (*models.PaymentRequest, error) {
+	payment, err := scanPayment(s.db.QueryRow(ctx, `SELECT`+paymentColumns+`
+		FROM payment_requests
+		WHERE reference_id = $1`, referenceID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("failed to load payment request: %w", err)
+	}
+	return payment, nil
+}
+
+// ListForSession returns the payment requests sent in a session, oldest first
+func (s *PaymentService) ListForSession(ctx context.Context, sessionID uuid.UUID) ([]*models.PaymentRequest, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+paymentColumns+`
+		FROM payment_requests
+		WHERE session_id = $1
+		ORDER BY created_at, id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment requests: %w", err)
+	}
+	defer rows.Close()
+
+	payments := []*models.PaymentRequest{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment request: %w", err)
+		}
+		payments = append(payments, payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading payment requests: %w", err)
+	}
+
+	return payments, nil
+}
+
+// Helper functions
+
+// formatMinorAmount formats an amount in minor units with two decimals
+func formatMinorAmount(amount int64) string {
+	return fmt.Sprintf("%d.%02d", amount/100, amount%100)
+}
+
+// scanPayment scans a payment_requests row selected with paymentColumns
+func scanPayment(row pgx.Row) (*models.PaymentRequest, error) {
+	var payment models.PaymentRequest
+	err := row.Scan(
+		&payment.ID,
+		&payment.ReferenceID,
+		&payment.MessageID,
+		&payment.SessionID,
+		&payment.To,
+		&payment.Channel,
+		&payment.Currency,
+		&payment.Items,
+		&payment.TotalAmount,
+		&payment.Status,
+		&payment.TransactionID,
+		&payment.PaidAt,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
@@ -0,0 +1,20 @@
+package services
+
+import "strings"
+
+// normalizePhoneNumber strips the channel prefix and formatting from a phone
+// number, returning it in E.164 form (e.g., "whatsapp:+55 11 9999-9999" -> "+551199999999")
+func normalizePhoneNumber(phoneNumber string) string {
+	cleaned := strings.TrimPrefix(strings.TrimSpace(phoneNumber), "whatsapp:")
+
+	cleaned = strings.ReplaceAll(cleaned, " ", "")
+	cleaned = strings.ReplaceAll(cleaned, "-", "")
+	cleaned = strings.ReplaceAll(cleaned, "(", "")
+	cleaned = strings.ReplaceAll(cleaned, ")", "")
+
+	if cleaned != "" && !strings.HasPrefix(cleaned, "+") {
+		cleaned = "+" + cleaned
+	}
+
+	return cleaned
+}
@@ -0,0 +1,369 @@
+package services
+
+// phoneTimezones maps phone number prefixes to the time zone their numbers are in; the
+// longest matching prefix wins. A country spanning several zones maps its code to the
+// zone most of its numbers are in and lists the area codes of the other zones. Where
+// mobile numbers are not tied to a region (Australia, Russia) the country code is left
+// out, so only landlines resolve and other numbers fall back to the configured zone.
+var phoneTimezones = map[string]string{
+	"+234": "Africa/Lagos",
+	"+27":  "Africa/Johannesburg",
+	"+33":  "Europe/Paris",
+	"+34":  "Europe/Madrid",
+	"+351": "Europe/Lisbon",
+	"+39":  "Europe/Rome",
+	"+44":  "Europe/London",
+	"+49":  "Europe/Berlin",
+	"+51":  "America/Lima",
+	"+54":  "America/Argentina/Buenos_Aires",
+	"+56":  "America/Santiago",
+	"+57":  "America/Bogota",
+	"+58":  "America/Caracas",
+	"+591": "America/La_Paz",
+	"+593": "America/Guayaquil",
+	"+595": "America/Asuncion",
+	"+598": "America/Montevideo",
+	"+81":  "Asia/Tokyo",
+	"+86":  "Asia/Shanghai",
+	"+91":  "Asia/Kolkata",
+	"+971": "Asia/Dubai",
+
+	// Brazil
+	"+55":   "America/Sao_Paulo",
+	"+5565": "America/Cuiaba",
+	"+5566": "America/Cuiaba",
+	"+5567": "America/Campo_Grande",
+	"+5568": "America/Rio_Branco",
+	"+5569": "America/Porto_Velho",
+	"+5592": "America/Manaus",
+	"+5595": "America/Boa_Vista",
+	"+5597": "America/Manaus",
+
+	// North American Numbering Plan: area codes outside the Eastern zone
+	"+1":    "America/New_York",
+	"+1204": "America/Winnipeg",
+	"+1205": "America/Chicago",
+	"+1206": "America/Los_Angeles",
+	"+1208": "America/Denver",
+	"+1209": "America/Los_Angeles",
+	"+1210": "America/Chicago",
+	"+1213": "America/Los_Angeles",
+	"+1214": "America/Chicago",
+	"+1217": "America/Chicago",
+	"+1218": "America/Chicago",
+	"+1219": "America/Chicago",
+	"+1224": "America/Chicago",
+	"+1225": "America/Chicago",
+	"+1228": "America/Chicago",
+	"+1236": "America/Vancouver",
+	"+1242": "America/Nassau",
+	"+1246": "America/Barbados",
+	"+1250": "America/Vancouver",
+	"+1251": "America/Chicago",
+	"+1253": "America/Los_Angeles",
+	"+1254": "America/Chicago",
+	"+1256": "America/Chicago",
+	"+1257": "America/Vancouver",
+	"+1262": "America/Chicago",
+	"+1264": "America/Anguilla",
+	"+1268": "America/Antigua",
+	"+1270": "America/Chicago",
+	"+1274": "America/Chicago",
+	"+1279": "America/Los_Angeles",
+	"+1281": "America/Chicago",
+	"+1284": "America/Tortola",
+	"+1303": "America/Denver",
+	"+1306": "America/Regina",
+	"+1307": "America/Denver",
+	"+1309": "America/Chicago",
+	"+1310": "America/Los_Angeles",
+	"+1312": "America/Chicago",
+	"+1314": "America/Chicago",
+	"+1316": "America/Chicago",
+	"+1318": "America/Chicago",
+	"+1319": "America/Chicago",
+	"+1320": "America/Chicago",
+	"+1323": "America/Los_Angeles",
+	"+1325": "America/Chicago",
+	"+1327": "America/Chicago",
+	"+1331": "America/Chicago",
+	"+1334": "America/Chicago",
+	"+1337": "America/Chicago",
+	"+1340": "America/St_Thomas",
+	"+1341": "America/Los_Angeles",
+	"+1345": "America/Cayman",
+	"+1346": "America/Chicago",
+	"+1350": "America/Los_Angeles",
+	"+1353": "America/Chicago",
+	"+1360": "America/Los_Angeles",
+	"+1361": "America/Chicago",
+	"+1364": "America/Chicago",
+	"+1368": "America/Edmonton",
+	"+1369": "America/Los_Angeles",
+	"+1385": "America/Denver",
+	"+1403": "America/Edmonton",
+	"+1405": "America/Chicago",
+	"+1406": "America/Denver",
+	"+1408": "America/Los_Angeles",
+	"+1409": "America/Chicago",
+	"+1414": "America/Chicago",
+	"+1415": "America/Los_Angeles",
+	"+1417": "America/Chicago",
+	"+1424": "America/Los_Angeles",
+	"+1425": "America/Los_Angeles",
+	"+1428": "America/Halifax",
+	"+1430": "America/Chicago",
+	"+1431": "America/Winnipeg",
+	"+1432": "America/Chicago",
+	"+1435": "America/Denver",
+	"+1441": "Atlantic/Bermuda",
+	"+1442": "America/Los_Angeles",
+	"+1447": "America/Chicago",
+	"+1457": "America/Chicago",
+	"+1458": "America/Los_Angeles",
+	"+1464": "America/Chicago",
+	"+1469": "America/Chicago",
+	"+1473": "America/Grenada",
+	"+1474": "America/Regina",
+	"+1479": "America/Chicago",
+	"+1480": "America/Phoenix",
+	"+1501": "America/Chicago",
+	"+1503": "America/Los_Angeles",
+	"+1504": "America/Chicago",
+	"+1505": "America/Denver",
+	"+1506": "America/Halifax",
+	"+1507": "America/Chicago",
+	"+1509": "America/Los_Angeles",
+	"+1510": "America/Los_Angeles",
+	"+1512": "America/Chicago",
+	"+1515": "America/Chicago",
+	"+1520": "America/Phoenix",
+	"+1530": "America/Los_Angeles",
+	"+1531": "America/Chicago",
+	"+1534": "America/Chicago",
+	"+1539": "America/Chicago",
+	"+1541": "America/Los_Angeles",
+	"+1557": "America/Chicago",
+	"+1559": "America/Los_Angeles",
+	"+1562": "America/Los_Angeles",
+	"+1563": "America/Chicago",
+	"+1564": "America/Los_Angeles",
+	"+1572": "America/Chicago",
+	"+1573": "America/Chicago",
+	"+1575": "America/Denver",
+	"+1580": "America/Chicago",
+	"+1584": "America/Winnipeg",
+	"+1587": "America/Edmonton",
+	"+1601": "America/Chicago",
+	"+1602": "America/Phoenix",
+	"+1604": "America/Vancouver",
+	"+1605": "America/Chicago",
+	"+1608": "America/Chicago",
+	"+1612": "America/Chicago",
+	"+1615": "America/Chicago",
+	"+1618": "America/Chicago",
+	"+1619": "America/Los_Angeles",
+	"+1620": "America/Chicago",
+	"+1623": "America/Phoenix",
+	"+1626": "America/Los_Angeles",
+	"+1628": "America/Los_Angeles",
+	"+1629": "America/Chicago",
+	"+1630": "America/Chicago",
+	"+1636": "America/Chicago",
+	"+1639": "America/Regina",
+	"+1641": "America/Chicago",
+	"+1649": "America/Grand_Turk",
+	"+1650": "America/Los_Angeles",
+	"+1651": "America/Chicago",
+	"+1657": "America/Los_Angeles",
+	"+1658": "America/Jamaica",
+	"+1659": "America/Chicago",
+	"+1660": "America/Chicago",
+	"+1661": "America/Los_Angeles",
+	"+1662": "America/Chicago",
+	"+1664": "America/Montserrat",
+	"+1669": "America/Los_Angeles",
+	"+1670": "Pacific/Saipan",
+	"+1671": "Pacific/Guam",
+	"+1672": "America/Vancouver",
+	"+1682": "America/Chicago",
+	"+1684": "Pacific/Pago_Pago",
+	"+1701": "America/Chicago",
+	"+1702": "America/Los_Angeles",
+	"+1707": "America/Los_Angeles",
+	"+1708": "America/Chicago",
+	"+1709": "America/St_Johns",
+	"+1712": "America/Chicago",
+	"+1713": "America/Chicago",
+	"+1714": "America/Los_Angeles",
+	"+1715": "America/Chicago",
+	"+1719": "America/Denver",
+	"+1720": "America/Denver",
+	"+1721": "America/Lower_Princes",
+	"+1725": "America/Los_Angeles",
+	"+1726": "America/Chicago",
+	"+1730": "America/Chicago",
+	"+1731": "America/Chicago",
+	"+1737": "America/Chicago",
+	"+1747": "America/Los_Angeles",
+	"+1758": "America/St_Lucia",
+	"+1760": "America/Los_Angeles",
+	"+1763": "America/Chicago",
+	"+1767": "America/Dominica",
+	"+1769": "America/Chicago",
+	"+1773": "America/Chicago",
+	"+1775": "America/Los_Angeles",
+	"+1778": "America/Vancouver",
+	"+1779": "America/Chicago",
+	"+1780": "America/Edmonton",
+	"+1782": "America/Halifax",
+	"+1784": "America/St_Vincent",
+	"+1785": "America/Chicago",
+	"+1787": "America/Puerto_Rico",
+	"+1801": "America/Denver",
+	"+1805": "America/Los_Angeles",
+	"+1806": "America/Chicago",
+	"+1808": "Pacific/Honolulu",
+	"+1809": "America/Santo_Domingo",
+	"+1815": "America/Chicago",
+	"+1816": "America/Chicago",
+	"+1817": "America/Chicago",
+	"+1818": "America/Los_Angeles",
+	"+1820": "America/Los_Angeles",
+	"+1825": "America/Edmonton",
+	"+1829": "America/Santo_Domingo",
+	"+1830": "America/Chicago",
+	"+1831": "America/Los_Angeles",
+	"+1832": "America/Chicago",
+	"+1837": "America/Los_Angeles",
+	"+1840": "America/Los_Angeles",
+	"+1847": "America/Chicago",
+	"+1849": "America/Santo_Domingo",
+	"+1858": "America/Los_Angeles",
+	"+1861": "America/Chicago",
+	"+1867": "America/Edmonton",
+	"+1868": "America/Port_of_Spain",
+	"+1869": "America/St_Kitts",
+	"+1870": "America/Chicago",
+	"+1872": "America/Chicago",
+	"+1876": "America/Jamaica",
+	"+1879": "America/St_Johns",
+	"+1901": "America/Chicago",
+	"+1902": "America/Halifax",
+	"+1903": "America/Chicago",
+	"+1907": "America/Anchorage",
+	"+1909": "America/Los_Angeles",
+	"+1913": "America/Chicago",
+	"+1915": "America/Denver",
+	"+1916": "America/Los_Angeles",
+	"+1918": "America/Chicago",
+	"+1920": "America/Chicago",
+	"+1924": "America/Chicago",
+	"+1925": "America/Los_Angeles",
+	"+1928": "America/Phoenix",
+	"+1931": "America/Chicago",
+	"+1936": "America/Chicago",
+	"+1938": "America/Chicago",
+	"+1939": "America/Puerto_Rico",
+	"+1940": "America/Chicago",
+	"+1945": "America/Chicago",
+	"+1949": "America/Los_Angeles",
+	"+1951": "America/Los_Angeles",
+	"+1952": "America/Chicago",
+	"+1956": "America/Chicago",
+	"+1970": "America/Denver",
+	"+1971": "America/Los_Angeles",
+	"+1972": "America/Chicago",
+	"+1975": "America/Chicago",
+	"+1979": "America/Chicago",
+	"+1983": "America/Denver",
+	"+1985": "America/Chicago",
+	"+1986": "America/Denver",
+
+	// Mexico: area codes outside the Central zone
+	"+52":    "America/Mexico_City",
+	"+52311": "America/Mazatlan",
+	"+52319": "America/Mazatlan",
+	"+52323": "America/Mazatlan",
+	"+52324": "America/Mazatlan",
+	"+52325": "America/Mazatlan",
+	"+52327": "America/Mazatlan",
+	"+52389": "America/Mazatlan",
+	"+52612": "America/Mazatlan",
+	"+52613": "America/Mazatlan",
+	"+52615": "America/Mazatlan",
+	"+52622": "America/Hermosillo",
+	"+52623": "America/Hermosillo",
+	"+52624": "America/Mazatlan",
+	"+52631": "America/Hermosillo",
+	"+52632": "America/Hermosillo",
+	"+52633": "America/Hermosillo",
+	"+52634": "America/Hermosillo",
+	"+52637": "America/Hermosillo",
+	"+52638": "America/Hermosillo",
+	"+52641": "America/Hermosillo",
+	"+52642": "America/Hermosillo",
+	"+52643": "America/Hermosillo",
+	"+52644": "America/Hermosillo",
+	"+52645": "America/Hermosillo",
+	"+52646": "America/Tijuana",
+	"+52647": "America/Hermosillo",
+	"+52653": "America/Tijuana",
+	"+52656": "America/Ciudad_Juarez",
+	"+52658": "America/Tijuana",
+	"+52661": "America/Tijuana",
+	"+52662": "America/Hermosillo",
+	"+52664": "America/Tijuana",
+	"+52665": "America/Tijuana",
+	"+52667": "America/Mazatlan",
+	"+52668": "America/Mazatlan",
+	"+52669": "America/Mazatlan",
+	"+52672": "America/Mazatlan",
+	"+52673": "America/Mazatlan",
+	"+52686": "America/Tijuana",
+	"+52687": "America/Mazatlan",
+	"+52694": "America/Mazatlan",
+	"+52695": "America/Mazatlan",
+	"+52696": "America/Mazatlan",
+	"+52697": "America/Mazatlan",
+	"+52698": "America/Mazatlan",
+	"+52983": "America/Cancun",
+	"+52984": "America/Cancun",
+	"+52987": "America/Cancun",
+	"+52998": "America/Cancun",
+
+	// Australia: landline area codes; mobile numbers (+614) can be anywhere
+	"+612":  "Australia/Sydney",
+	"+613":  "Australia/Melbourne",
+	"+617":  "Australia/Brisbane",
+	"+6188": "Australia/Adelaide",
+	"+6189": "Australia/Perth",
+
+	// Russia and Kazakhstan: landline codes; mobile numbers (+79) can be anywhere
+	"+7342":  "Asia/Yekaterinburg",
+	"+7343":  "Asia/Yekaterinburg",
+	"+7345":  "Asia/Yekaterinburg",
+	"+7347":  "Asia/Yekaterinburg",
+	"+7351":  "Asia/Yekaterinburg",
+	"+7352":  "Asia/Yekaterinburg",
+	"+7381":  "Asia/Omsk",
+	"+7383":  "Asia/Novosibirsk",
+	"+7391":  "Asia/Krasnoyarsk",
+	"+7395":  "Asia/Irkutsk",
+	"+74012": "Europe/Kaliningrad",
+	"+74212": "Asia/Vladivostok",
+	"+7423":  "Asia/Vladivostok",
+	"+7495":  "Europe/Moscow",
+	"+7496":  "Europe/Moscow",
+	"+7498":  "Europe/Moscow",
+	"+7499":  "Europe/Moscow",
+	"+76":    "Asia/Almaty",
+	"+77":    "Asia/Almaty",
+	"+7812":  "Europe/Moscow",
+	"+7831":  "Europe/Moscow",
+	"+7843":  "Europe/Moscow",
+	"+7846":  "Europe/Samara",
+	"+7848":  "Europe/Samara",
+	"+7861":  "Europe/Moscow",
+	"+7863":  "Europe/Moscow",
+}
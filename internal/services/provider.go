@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// Twilio transports for outbound messages
+const (
+	TransportMessaging     = "messaging"
+	TransportConversations = "conversations"
+)
+
+// ErrChannelNotConfigured is returned when no provider is registered for a channel
+var ErrChannelNotConfigured = errors.New("channel not configured")
+
+// MessagingProvider sends outbound messages over a specific channel and transport
+type MessagingProvider interface {
+	// Name identifies the provider in logs and stored metadata
+	Name() string
+	SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error)
+	SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error)
+	SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error)
+	GetFromNumber() string
+}
+
+// NewMessagingProvider returns the outbound provider selected by the configured Twilio transport
+func NewMessagingProvider(cfg *config.Config, db *pgxpool.Pool, whatsappService *WhatsAppService, twilioCalls *TwilioCallLog, logger *logrus.Logger) (MessagingProvider, error) {
+	switch cfg.TwilioTransport {
+	case TransportMessaging, "":
+		return whatsappService, nil
+	case TransportConversations:
+		return NewConversationsService(cfg, db, twilioCalls, logger)
+	default:
+		return nil, fmt.Errorf("unknown Twilio transport %q", cfg.TwilioTransport)
+	}
+}
+
+// ChannelProviders routes outbound messages to the provider registered for their channel.
+// Providers can be swapped at runtime when stored provider configurations change.
+type ChannelProviders struct {
+	mu        sync.RWMutex
+	base      map[models.Channel]MessagingProvider
+	providers map[models.Channel]MessagingProvider
+	named     map[models.Channel]map[string]MessagingProvider
+	faults    *FaultInjector
+}
+
+// NewChannelProviders creates a channel registry with the WhatsApp provider registered
+func NewChannelProviders(whatsapp MessagingProvider) *ChannelProviders {
+	return &ChannelProviders{
+		base: map[models.Channel]MessagingProvider{
+			models.ChannelWhatsApp: whatsapp,
+		},
+		providers: map[models.Channel]MessagingProvider{
+			models.ChannelWhatsApp: whatsapp,
+		},
+		named: map[models.Channel]map[string]MessagingProvider{},
+	}
+}
+
+// Register sets the environment-configured provider for a channel
+func (c *ChannelProviders) Register(channel models.Channel, provider MessagingProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.base[channel] = provider
+	c.providers[channel] = provider
+}
+
+// UseFaults lets the fault injector fail sends of the providers returned from now on
+func (c *ChannelProviders) UseFaults(faults *FaultInjector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.faults = faults
+}
+
+// Apply replaces the runtime-configured providers: defaults override the environment
+// providers of their channel and every provider is addressable by channel and name
+func (c *ChannelProviders) Apply(defaults map[models.Channel]MessagingProvider, named map[models.Channel]map[string]MessagingProvider) {
+	providers := make(map[models.Channel]MessagingProvider, len(c.base)+len(defaults))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for channel, provider := range c.base {
+		providers[channel] = provider
+	}
+	for channel, provider := range defaults {
+		providers[channel] = provider
+	}
+
+	c.providers = providers
+	c.named = named
+}
+
+// For returns the provider for a channel; an empty channel means WhatsApp
+func (c *ChannelProviders) For(channel models.Channel) (MessagingProvider, error) {
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	c.mu.RLock()
+	provider, ok := c.providers[channel]
+	faults := c.faults
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChannelNotConfigured, channel)
+	}
+
+	if faults != nil {
+		return faults.wrapProvider(provider), nil
+	}
+	return provider, nil
+}
+
+// Named returns the stored provider configuration with the given name on a channel;
+// an empty channel means WhatsApp
+func (c *ChannelProviders) Named(channel models.Channel, name string) (MessagingProvider, error) {
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	c.mu.RLock()
+	provider, ok := c.named[channel][name]
+	faults := c.faults
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider %s", ErrChannelNotConfigured, channel, name)
+	}
+
+	if faults != nil {
+		return faults.wrapProvider(provider), nil
+	}
+	return provider, nil
+}
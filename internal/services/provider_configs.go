@@ -0,0 +1,530 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/secretbox"
+)
+
+// Provider configuration errors
+var (
+	ErrProviderConfigNotFound  = errors.New("provider config not found")
+	ErrInvalidProviderConfig   = errors.New("invalid provider config")
+	ErrProviderConfigsDisabled = errors.New("provider configs are disabled")
+
+	// ErrProviderConfigUnreadable is returned for a stored configuration whose
+	// credentials cannot be decrypted or decoded
+	ErrProviderConfigUnreadable = errors.New("provider config credentials cannot be read")
+)
+
+// providerConfigColumns lists the provider_configs columns in the order scanProviderConfig expects
+const providerConfigColumns = `
+	id, name, channel, kind, from_address, settings, credentials,
+	is_default, is_active, created_at, updated_at`
+
+// ProviderConfigService stores outbound provider configurations encrypted in Postgres and
+// keeps the channel registry in sync with them, so numbers and providers can be added or
+// rotated without a redeploy
+type ProviderConfigService struct {
+	db           *pgxpool.Pool
+	cacheBus     *CacheBus
+	box          *secretbox.Box
+	channels     *ChannelProviders
+	mediaService *MediaService
+	twilioCalls  *TwilioCallLog
+	config       *config.Config
+	logger       *logrus.Logger
+}
+
+// NewProviderConfigService creates a new provider config service. Without an encryption
+// key the service is disabled and only environment-configured providers are used.
+func NewProviderConfigService(
+	db *pgxpool.Pool,
+	cacheBus *CacheBus,
+	channels *ChannelProviders,
+	mediaService *MediaService,
+	twilioCalls *TwilioCallLog,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) (*ProviderConfigService, error) {
+	service := &ProviderConfigService{
+		db:           db,
+		cacheBus:     cacheBus,
+		channels:     channels,
+		mediaService: mediaService,
+		twilioCalls:  twilioCalls,
+		config:       cfg,
+		logger:       logger,
+	}
+
+	if cfg.ProviderConfigKey != "" {
+		box, err := secretbox.NewFromBase64(cfg.ProviderConfigKeyID, cfg.ProviderConfigKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROVIDER_CONFIG_KEY: %w", err)
+		}
+		for keyID, encoded := range cfg.ProviderConfigPrevKeys {
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PROVIDER_CONFIG_PREVIOUS_KEYS key %q: %w", keyID, err)
+			}
+			if err := box.AddKey(keyID, key); err != nil {
+				return nil, fmt.Errorf("invalid PROVIDER_CONFIG_PREVIOUS_KEYS key %q: %w", keyID, err)
+			}
+		}
+		service.box = box
+	}
+
+	return service, nil
+}
+
+// Enabled reports whether stored provider configurations are in use
+func (s *ProviderConfigService) Enabled() bool {
+	return s.box != nil
+}
+
+// Start loads the stored providers, then reloads them whenever another replica reports a
+// change and on every refresh interval, until ctx is canceled
+func (s *ProviderConfigService) Start(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Error("Failed to load provider configs")
+	}
+
+	changes := make(chan struct{}, 1)
+	s.cacheBus.Subscribe(CacheTopicProviderConfigs, func(string) {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+
+	ticker := time.NewTicker(s.config.ProviderConfigRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+		case <-ticker.C:
+		}
+
+		if err := s.Reload(ctx); err != nil {
+			s.logger.WithError(err).Error("Failed to reload provider configs")
+		}
+	}
+}
+
+// Reload rebuilds the runtime providers from the active stored configurations
+func (s *ProviderConfigService) Reload(ctx context.Context) error {
+	configs, err := s.list(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	defaults := make(map[models.Channel]MessagingProvider)
+	named := make(map[models.Channel]map[string]MessagingProvider)
+	for _, providerConfig := range configs {
+		provider, err := s.buildProvider(providerConfig)
+		if err != nil {
+			s.logger.WithError(err).WithField("provider_config", providerConfig.Name).Error("Skipping invalid provider config")
+			continue
+		}
+
+		if named[providerConfig.Channel] == nil {
+			named[providerConfig.Channel] = make(map[string]MessagingProvider)
+		}
+		named[providerConfig.Channel][providerConfig.Name] = provider
+		if providerConfig.IsDefault {
+			defaults[providerConfig.Channel] = provider
+		}
+	}
+
+	s.channels.Apply(defaults, named)
+
+	s.logger.WithFields(logrus.Fields{
+		"providers": len(configs),
+		"defaults":  len(defaults),
+	}).Debug("Provider configs loaded")
+
+	return nil
+}
+
+// List returns every stored provider configuration
+func (s *ProviderConfigService) List(ctx context.Context) ([]*models.ProviderConfig, error) {
+	if !s.Enabled() {
+		return nil, ErrProviderConfigsDisabled
+	}
+	return s.list(ctx, false)
+}
+
+// Create stores a new provider configuration after checking that it builds a provider
+func (s *ProviderConfigService) Create(ctx context.Context, request *models.ProviderConfigRequest) (*models.ProviderConfig, error) {
+	if !s.Enabled() {
+		return nil, ErrProviderConfigsDisabled
+	}
+
+	providerConfig := &models.ProviderConfig{
+		ID:          uuid.New(),
+		Settings:    map[string]string{},
+		Credentials: map[string]string{},
+		IsActive:    true,
+	}
+	applyProviderConfigRequest(providerConfig, request)
+
+	if providerConfig.Name == "" || providerConfig.Channel == "" || providerConfig.Kind == "" {
+		return nil, fmt.Errorf("%w: name, channel and kind are required", ErrInvalidProviderConfig)
+	}
+
+	return s.save(ctx, providerConfig, true)
+}
+
+// Update changes a stored provider configuration
+func (s *ProviderConfigService) Update(ctx context.Context, id uuid.UUID, request *models.ProviderConfigRequest) (*models.ProviderConfig, error) {
+	if !s.Enabled() {
+		return nil, ErrProviderConfigsDisabled
+	}
+
+	providerConfig, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applyProviderConfigRequest(providerConfig, request)
+
+	return s.save(ctx, providerConfig, false)
+}
+
+// Delete removes a stored provider configuration
+func (s *ProviderConfigService) Delete(ctx context.Context, id uuid.UUID) error {
+	if !s.Enabled() {
+		return ErrProviderConfigsDisabled
+	}
+
+	tag, err := s.db.Exec(ctx, `DELETE FROM provider_configs WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete provider config: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrProviderConfigNotFound
+	}
+
+	s.changed(ctx)
+	return nil
+}
+
+// Helper methods
+
+// save validates, encrypts and writes a provider configuration, then reloads providers
+func (s *ProviderConfigService) save(ctx context.Context, providerConfig *models.ProviderConfig, create bool) (*models.ProviderConfig, error) {
+	if _, err := s.buildProvider(providerConfig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProviderConfig, err)
+	}
+
+	plaintext, err := json.Marshal(providerConfig.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	credentials, err := s.box.Seal(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin provider config update: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// A channel has at most one active default
+	if providerConfig.IsDefault && providerConfig.IsActive {
+		_, err := tx.Exec(ctx, `
+			UPDATE provider_configs SET is_default = false, updated_at = NOW()
+			WHERE channel = $1 AND id <> $2 AND is_default`, providerConfig.Channel, providerConfig.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to clear previous default: %w", err)
+		}
+	}
+
+	var query string
+	if create {
+		query = `
+			INSERT INTO provider_configs (id, name, channel, kind, from_address, settings, credentials,
+				is_default, is_active, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+			RETURNING` + providerConfigColumns
+	} else {
+		query = `
+			UPDATE provider_configs
+			SET name = $2, channel = $3, kind = $4, from_address = $5, settings = $6, credentials = $7,
+				is_default = $8, is_active = $9, updated_at = NOW()
+			WHERE id = $1
+			RETURNING` + providerConfigColumns
+	}
+
+	saved, err := s.scanProviderConfig(tx.QueryRow(ctx, query,
+		providerConfig.ID,
+		providerConfig.Name,
+		providerConfig.Channel,
+		providerConfig.Kind,
+		providerConfig.FromAddress,
+		providerConfig.Settings,
+		credentials,
+		providerConfig.IsDefault,
+		providerConfig.IsActive,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProviderConfigNotFound
+		}
+		return nil, fmt.Errorf("failed to save provider config: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit provider config: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"provider_config": saved.Name,
+		"channel":         saved.Channel,
+		"kind":            saved.Kind,
+	}).Info("Provider config saved")
+
+	s.changed(ctx)
+	return saved, nil
+}
+
+// changed reloads local providers and tells other replicas to do the same
+func (s *ProviderConfigService) changed(ctx context.Context) {
+	if err := s.Reload(ctx); err != nil {
+		s.logger.WithError(err).Error("Failed to reload provider configs")
+	}
+	s.cacheBus.Publish(ctx, CacheTopicProviderConfigs, "")
+}
+
+// get loads one provider configuration
+func (s *ProviderConfigService) get(ctx context.Context, id uuid.UUID) (*models.ProviderConfig, error) {
+	query := `SELECT` + providerConfigColumns + ` FROM provider_configs WHERE id = $1`
+
+	providerConfig, err := s.scanProviderConfig(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrProviderConfigNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve provider config: %w", err)
+	}
+
+	return providerConfig, nil
+}
+
+// list loads provider configurations, optionally only the active ones
+func (s *ProviderConfigService) list(ctx context.Context, activeOnly bool) ([]*models.ProviderConfig, error) {
+	query := `SELECT` + providerConfigColumns + ` FROM provider_configs
+		WHERE is_active OR NOT $1
+		ORDER BY channel, name`
+
+	rows, err := s.db.Query(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provider configs: %w", err)
+	}
+	defer rows.Close()
+
+	// A configuration sealed with a key that is gone must not take the others down
+	configs := []*models.ProviderConfig{}
+	for rows.Next() {
+		providerConfig, err := s.scanProviderConfig(rows)
+		if errors.Is(err, ErrProviderConfigUnreadable) {
+			s.logger.WithError(err).Error("Skipping provider config with unreadable credentials")
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan provider config: %w", err)
+		}
+		configs = append(configs, providerConfig)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading provider configs: %w", err)
+	}
+
+	return configs, nil
+}
+
+// scanProviderConfig scans a provider_configs row selected with providerConfigColumns
+// and decrypts its credentials, returning ErrProviderConfigUnreadable when they cannot be
+// decrypted or decoded
+func (s *ProviderConfigService) scanProviderConfig(row pgx.Row) (*models.ProviderConfig, error) {
+	var providerConfig models.ProviderConfig
+	var credentials string
+
+	err := row.Scan(
+		&providerConfig.ID,
+		&providerConfig.Name,
+		&providerConfig.Channel,
+		&providerConfig.Kind,
+		&providerConfig.FromAddress,
+		&providerConfig.Settings,
+		&credentials,
+		&providerConfig.IsDefault,
+		&providerConfig.IsActive,
+		&providerConfig.CreatedAt,
+		&providerConfig.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := s.box.Open(credentials)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt credentials of %s: %v", ErrProviderConfigUnreadable, providerConfig.Name, err)
+	}
+	if err := json.Unmarshal(plaintext, &providerConfig.Credentials); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode credentials of %s: %v", ErrProviderConfigUnreadable, providerConfig.Name, err)
+	}
+
+	providerConfig.CredentialKeys = make([]string, 0, len(providerConfig.Credentials))
+	for key := range providerConfig.Credentials {
+		providerConfig.CredentialKeys = append(providerConfig.CredentialKeys, key)
+	}
+	sort.Strings(providerConfig.CredentialKeys)
+
+	return &providerConfig, nil
+}
+
+// buildProvider instantiates the messaging provider described by a configuration, on
+// top of a copy of the environment configuration
+func (s *ProviderConfigService) buildProvider(providerConfig *models.ProviderConfig) (MessagingProvider, error) {
+	cfg := *s.config
+	credential := func(key, fallback string) string {
+		if value := providerConfig.Credentials[key]; value != "" {
+			return value
+		}
+		return fallback
+	}
+
+	requireChannel := func(channels ...models.Channel) error {
+		for _, channel := range channels {
+			if providerConfig.Channel == channel {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s providers cannot serve the %s channel", providerConfig.Kind, providerConfig.Channel)
+	}
+
+	if providerConfig.FromAddress == "" && providerConfig.Kind != models.ProviderKindTelegram {
+		return nil, fmt.Errorf("from_address is required")
+	}
+
+	switch providerConfig.Kind {
+	case models.ProviderKindTwilioMessaging, models.ProviderKindTwilioConversations:
+		if err := requireChannel(models.ChannelWhatsApp); err != nil {
+			return nil, err
+		}
+		// Numbers on the main Twilio account may omit credentials
+		cfg.TwilioAccountSID = credential("account_sid", cfg.TwilioAccountSID)
+		cfg.TwilioAuthToken = credential("auth_token", cfg.TwilioAuthToken)
+		cfg.TwilioWhatsAppFrom = providerConfig.FromAddress
+		if providerConfig.Kind == models.ProviderKindTwilioConversations {
+			cfg.TwilioConversationsServiceSID = providerConfig.Settings["service_sid"]
+			return NewConversationsService(&cfg, s.db, s.twilioCalls, s.logger)
+		}
+		return NewWhatsAppService(&cfg, s.twilioCalls, s.logger), nil
+
+	case models.ProviderKindTwilioSMS:
+		if err := requireChannel(models.ChannelSMS); err != nil {
+			return nil, err
+		}
+		cfg.TwilioAccountSID = credential("account_sid", cfg.TwilioAccountSID)
+		cfg.TwilioAuthToken = credential("auth_token", cfg.TwilioAuthToken)
+		cfg.SMSFromNumber = providerConfig.FromAddress
+		return NewSMSService(&cfg, s.twilioCalls, s.logger), nil
+
+	case models.ProviderKindMeta:
+		if err := requireChannel(models.ChannelMessenger, models.ChannelInstagram); err != nil {
+			return nil, err
+		}
+		cfg.MetaPageID = providerConfig.FromAddress
+		cfg.MetaPageAccessToken = credential("page_access_token", "")
+		if cfg.MetaPageAccessToken == "" {
+			return nil, fmt.Errorf("credential page_access_token is required")
+		}
+		return NewMetaService(providerConfig.Channel, &cfg, s.logger), nil
+
+	case models.ProviderKindTelegram:
+		if err := requireChannel(models.ChannelTelegram); err != nil {
+			return nil, err
+		}
+		cfg.TelegramBotToken = credential("bot_token", "")
+		if cfg.TelegramBotToken == "" {
+			return nil, fmt.Errorf("credential bot_token is required")
+		}
+		return NewTelegramService(&cfg, s.mediaService, s.logger), nil
+
+	case models.ProviderKindSMTPEmail:
+		if err := requireChannel(models.ChannelEmail); err != nil {
+			return nil, err
+		}
+		cfg.EmailFromAddress = providerConfig.FromAddress
+		cfg.SMTPHost = providerConfig.Settings["smtp_host"]
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("setting smtp_host is required")
+		}
+		if port := providerConfig.Settings["smtp_port"]; port != "" {
+			parsed, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid smtp_port %q", port)
+			}
+			cfg.SMTPPort = parsed
+		}
+		cfg.SMTPUsername = credential("smtp_username", "")
+		cfg.SMTPPassword = credential("smtp_password", "")
+		return NewEmailService(&cfg, s.logger), nil
+
+	default:
+		return nil, fmt.Errorf("unknown provider kind %q", providerConfig.Kind)
+	}
+}
+
+// applyProviderConfigRequest copies the fields set in a request onto a configuration
+func applyProviderConfigRequest(providerConfig *models.ProviderConfig, request *models.ProviderConfigRequest) {
+	if request.Name != nil {
+		providerConfig.Name = *request.Name
+	}
+	if request.Channel != nil {
+		providerConfig.Channel = *request.Channel
+	}
+	if request.Kind != nil {
+		providerConfig.Kind = *request.Kind
+	}
+	if request.FromAddress != nil {
+		providerConfig.FromAddress = *request.FromAddress
+	}
+	if request.Settings != nil {
+		providerConfig.Settings = request.Settings
+	}
+	if request.Credentials != nil {
+		providerConfig.Credentials = request.Credentials
+	}
+	if request.IsDefault != nil {
+		providerConfig.IsDefault = *request.IsDefault
+	}
+	if request.IsActive != nil {
+		providerConfig.IsActive = *request.IsActive
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/secretbox"
+)
+
+// valuesRow is a pgx.Row that scans fixed values, or fails with err
+type valuesRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r valuesRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, value := range r.values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+// providerConfigRow returns a provider_configs row whose credentials are sealed
+func providerConfigRow(credentials string) valuesRow {
+	now := time.Now()
+	return valuesRow{values: []interface{}{
+		uuid.New(), "primary", models.ChannelTelegram, models.ProviderKindTelegram, "",
+		map[string]string{}, credentials, true, true, now, now,
+	}}
+}
+
+func testKey(fill byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(fill), secretbox.KeySize)))
+}
+
+func seal(t *testing.T, keyID, key, plaintext string) string {
+	t.Helper()
+	box, err := secretbox.NewFromBase64(keyID, key)
+	if err != nil {
+		t.Fatalf("secretbox.NewFromBase64() error = %v", err)
+	}
+	sealed, err := box.Seal([]byte(plaintext))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	return sealed
+}
+
+func TestScanProviderConfig(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	service, err := NewProviderConfigService(nil, nil, nil, nil, nil, &config.Config{
+		ProviderConfigKey:      testKey('b'),
+		ProviderConfigKeyID:    "k2",
+		ProviderConfigPrevKeys: map[string]string{"k1": testKey('a')},
+	}, logger)
+	if err != nil {
+		t.Fatalf("NewProviderConfigService() error = %v", err)
+	}
+
+	credentials := `{"bot_token":"123:abc"}`
+	tests := []struct {
+		name           string
+		row            valuesRow
+		wantUnreadable bool
+	}{
+		{"current key", providerConfigRow(seal(t, "k2", testKey('b'), credentials)), false},
+		{"previous key", providerConfigRow(seal(t, "k1", testKey('a'), credentials)), false},
+		{"dropped key", providerConfigRow(seal(t, "k0", testKey('c'), credentials)), true},
+		{"not json", providerConfigRow(seal(t, "k2", testKey('b'), "token")), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerConfig, err := service.scanProviderConfig(tt.row)
+			if tt.wantUnreadable {
+				if !errors.Is(err, ErrProviderConfigUnreadable) {
+					t.Errorf("scanProviderConfig() error = %v, want %v", err, ErrProviderConfigUnreadable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scanProviderConfig() error = %v", err)
+			}
+			if providerConfig.Credentials["bot_token"] != "123:abc" || !reflect.DeepEqual(providerConfig.CredentialKeys, []string{"bot_token"}) {
+				t.Errorf("credentials = %v, keys %v", providerConfig.Credentials, providerConfig.CredentialKeys)
+			}
+		})
+	}
+
+	// A failed scan is a database error, which still aborts listing
+	if _, err := service.scanProviderConfig(valuesRow{err: errors.New("conn closed")}); err == nil || errors.Is(err, ErrProviderConfigUnreadable) {
+		t.Errorf("scanProviderConfig() error = %v, want the scan error", err)
+	}
+}
+
+func TestNewProviderConfigServicePreviousKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		prevKeys map[string]string
+		wantErr  bool
+	}{
+		{"none", nil, false},
+		{"valid", map[string]string{"k1": testKey('a')}, false},
+		{"not base64", map[string]string{"k1": "%%%"}, true},
+		{"wrong size", map[string]string{"k1": base64.StdEncoding.EncodeToString([]byte("short"))}, true},
+		{"invalid id", map[string]string{"k:1": testKey('a')}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProviderConfigService(nil, nil, nil, nil, nil, &config.Config{
+				ProviderConfigKey:      testKey('b'),
+				ProviderConfigKeyID:    "k2",
+				ProviderConfigPrevKeys: tt.prevKeys,
+			}, logrus.New())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewProviderConfigService() error = %v, want error = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+// quietHours is the daily period, in each recipient's local time, campaign messages are
+// held back in. A start after the end spans midnight, e.g. 21:00-08:00.
+type quietHours struct {
+	start    int // minutes after midnight
+	end      int
+	fallback *time.Location
+}
+
+// newQuietHours reads CAMPAIGN_QUIET_HOURS_START and _END, returning nil when campaigns
+// may send at any hour
+func newQuietHours(cfg *config.Config) *quietHours {
+	start, err := time.Parse("15:04", cfg.CampaignQuietHoursStart)
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse("15:04", cfg.CampaignQuietHoursEnd)
+	if err != nil {
+		return nil
+	}
+	fallback, err := time.LoadLocation(cfg.CampaignDefaultTimezone)
+	if err != nil {
+		fallback = time.UTC
+	}
+
+	return &quietHours{
+		start:    start.Hour()*60 + start.Minute(),
+		end:      end.Hour()*60 + end.Minute(),
+		fallback: fallback,
+	}
+}
+
+// location resolves the time zone of a recipient: the zone stored for the user, else
+// the zone of the phone number's country or area code, else CAMPAIGN_DEFAULT_TIMEZONE
+func (q *quietHours) location(timezone, recipient string) *time.Location {
+	if timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return location
+		}
+	}
+	if timezone = inferTimezone(recipient); timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return location
+		}
+	}
+	return q.fallback
+}
+
+// resume reports whether now falls in the quiet hours of location, and if so the time
+// they end
+func (q *quietHours) resume(now time.Time, location *time.Location) (time.Time, bool) {
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+
+	quiet := minute >= q.start && minute < q.end
+	if q.start > q.end {
+		quiet = minute >= q.start || minute < q.end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	end := time.Date(local.Year(), local.Month(), local.Day(), q.end/60, q.end%60, 0, 0, location)
+	if !end.After(local) {
+		end = time.Date(local.Year(), local.Month(), local.Day()+1, q.end/60, q.end%60, 0, 0, location)
+	}
+	return end, true
+}
+
+// inferTimezone returns the time zone of the longest phoneTimezones prefix of a phone
+// number, or "" for unknown numbers and recipients that are not phone numbers
+func inferTimezone(recipient string) string {
+	if !strings.HasPrefix(recipient, "+") {
+		return ""
+	}
+	for length := len(recipient); length > 1; length-- {
+		if timezone, ok := phoneTimezones[recipient[:length]]; ok {
+			return timezone
+		}
+	}
+	return ""
+}
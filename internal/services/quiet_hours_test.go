@@ -0,0 +1,112 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferTimezone(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient string
+		want      string
+	}{
+		{"new york", "+12125550100", "America/New_York"},
+		{"toronto", "+14165550100", "America/New_York"},
+		{"chicago", "+13125550100", "America/Chicago"},
+		{"denver", "+13035550100", "America/Denver"},
+		{"phoenix", "+16025550100", "America/Phoenix"},
+		{"san francisco", "+14155550100", "America/Los_Angeles"},
+		{"vancouver", "+16045550100", "America/Vancouver"},
+		{"anchorage", "+19075550100", "America/Anchorage"},
+		{"honolulu", "+18085550100", "Pacific/Honolulu"},
+		{"puerto rico", "+17875550100", "America/Puerto_Rico"},
+		{"mexico city", "+525555550100", "America/Mexico_City"},
+		{"tijuana", "+526645550100", "America/Tijuana"},
+		{"cancun", "+529985550100", "America/Cancun"},
+		{"sao paulo", "+5511955550100", "America/Sao_Paulo"},
+		{"manaus", "+5592955550100", "America/Manaus"},
+		{"sydney landline", "+61255550100", "Australia/Sydney"},
+		{"perth landline", "+61895550100", "Australia/Perth"},
+		{"australian mobile", "+61412345678", ""},
+		{"moscow landline", "+74955550100", "Europe/Moscow"},
+		{"vladivostok landline", "+74235550100", "Asia/Vladivostok"},
+		{"russian mobile", "+79165550100", ""},
+		{"kazakhstan", "+77015550100", "Asia/Almaty"},
+		{"unknown country", "+9995550100", ""},
+		{"not a phone number", "telegram:12345", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferTimezone(tt.recipient); got != tt.want {
+				t.Errorf("inferTimezone(%q) = %q, want %q", tt.recipient, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhoneTimezonesLoad(t *testing.T) {
+	for prefix, timezone := range phoneTimezones {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			t.Errorf("phoneTimezones[%q] = %q: %v", prefix, timezone, err)
+		}
+	}
+}
+
+func TestQuietHoursLocation(t *testing.T) {
+	fallback, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &quietHours{start: 21 * 60, end: 8 * 60, fallback: fallback}
+
+	tests := []struct {
+		name      string
+		timezone  string
+		recipient string
+		want      string
+	}{
+		{"stored zone wins", "America/Chicago", "+14155550100", "America/Chicago"},
+		{"invalid stored zone is inferred", "Mars/Olympus", "+14155550100", "America/Los_Angeles"},
+		{"inferred from area code", "", "+19075550100", "America/Anchorage"},
+		{"unresolvable falls back", "", "+61412345678", "America/Sao_Paulo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := q.location(tt.timezone, tt.recipient).String(); got != tt.want {
+				t.Errorf("location() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietHoursResume(t *testing.T) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := &quietHours{start: 21 * 60, end: 8 * 60, fallback: time.UTC}
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantQuiet  bool
+		wantResume time.Time
+	}{
+		{"early morning", time.Date(2024, 3, 4, 5, 0, 0, 0, pacific), true, time.Date(2024, 3, 4, 8, 0, 0, 0, pacific)},
+		{"late evening", time.Date(2024, 3, 4, 22, 30, 0, 0, pacific), true, time.Date(2024, 3, 5, 8, 0, 0, 0, pacific)},
+		{"at the end", time.Date(2024, 3, 4, 8, 0, 0, 0, pacific), false, time.Time{}},
+		{"daytime", time.Date(2024, 3, 4, 14, 0, 0, 0, pacific), false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resume, quiet := q.resume(tt.now.UTC(), pacific)
+			if quiet != tt.wantQuiet || !resume.Equal(tt.wantResume) {
+				t.Errorf("resume() = %v, %v, want %v, %v", resume, quiet, tt.wantResume, tt.wantQuiet)
+			}
+		})
+	}
+}
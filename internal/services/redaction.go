@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// RedactedContent replaces the content of redacted messages
+const RedactedContent = "[redacted]"
+
+// RedactionService removes sensitive content from individual messages while keeping
+// their metadata, e.g. when a user sent a document or card number by mistake
+type RedactionService struct {
+	db              *pgxpool.Pool
+	messageService  *MessageService
+	mediaService    *MediaService
+	whatsappService *WhatsAppService
+	archiveService  *ArchiveService
+	logger          *logrus.Logger
+}
+
+// NewRedactionService creates a new message redaction service
+func NewRedactionService(
+	db *pgxpool.Pool,
+	messageService *MessageService,
+	mediaService *MediaService,
+	whatsappService *WhatsAppService,
+	archiveService *ArchiveService,
+	logger *logrus.Logger,
+) *RedactionService {
+	return &RedactionService{
+		db:              db,
+		messageService:  messageService,
+		mediaService:    mediaService,
+		whatsappService: whatsappService,
+		archiveService:  archiveService,
+		logger:          logger,
+	}
+}
+
+// RedactMessage replaces a message's content, media URL, extracted text, transcript and
+// AI results with tombstones, deletes the media file and the copies kept of the raw
+// request, and records the redaction in the audit log. The media is deleted before the
+// change commits, so a failed deletion leaves the message untouched and the redaction can
+// be retried. Archived messages are redacted in their archive. Redacting twice is a no-op.
+func (s *RedactionService) RedactMessage(ctx context.Context, messageID uuid.UUID, actor, role, clientIP, reason string) (*models.WhatsAppMessage, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin redaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var message models.WhatsAppMessage
+	query := `SELECT` + messageColumns + ` FROM whatsapp_messages WHERE id = $1 FOR UPDATE`
+	if err := scanMessageInto(tx.QueryRow(ctx, query, messageID), &message); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s.redactArchived(ctx, tx, messageID, actor, role, clientIP, reason)
+		}
+		return nil, fmt.Errorf("failed to load message: %w", err)
+	}
+
+	if message.RedactedAt != nil {
+		return &message, nil
+	}
+
+	var redacted models.WhatsAppMessage
+	query = `
+		UPDATE whatsapp_messages
+		SET content = $2, raw_content = NULL, original_content = NULL, media_url = NULL, extracted_text = NULL, transcript = NULL,
+			media_sha256 = NULL, flow_response = NULL, redacted_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING` + messageColumns
+	if err := scanMessageInto(tx.QueryRow(ctx, query, messageID, RedactedContent), &redacted); err != nil {
+		return nil, fmt.Errorf("failed to redact message: %w", err)
+	}
+
+	if err := s.redactCopies(ctx, tx, &message); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordRedaction(ctx, tx, &message, false, actor, role, clientIP, reason); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit redaction: %w", err)
+	}
+
+	s.messageService.InvalidateCache(ctx, messageID)
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"actor":      actor,
+	}).Info("Message redacted")
+
+	return &redacted, nil
+}
+
+// redactArchived redacts a message that was moved to a conversation archive: the archive
+// is rewritten with the message's tombstone while its row is locked
+func (s *RedactionService) redactArchived(ctx context.Context, tx pgx.Tx, messageID uuid.UUID, actor, role, clientIP, reason string) (*models.WhatsAppMessage, error) {
+	archive, err := s.archiveService.lockArchiveOf(ctx, tx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	conversation, err := s.archiveService.load(ctx, archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived *models.WhatsAppMessage
+	for _, message := range conversation.Messages {
+		if message.ID == messageID {
+			archived = message
+			break
+		}
+	}
+	if archived == nil {
+		return nil, ErrMessageNotFound
+	}
+	if archived.RedactedAt != nil {
+		archived.Archived = true
+		return archived, nil
+	}
+
+	original := *archived
+	tombstone(archived, time.Now().UTC())
+
+	if err := s.redactCopies(ctx, tx, &original); err != nil {
+		return nil, err
+	}
+
+	if err := s.archiveService.upload(ctx, archive.ObjectKey, conversation); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordRedaction(ctx, tx, &original, true, actor, role, clientIP, reason); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit redaction: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"archive_id": archive.ID,
+		"actor":      actor,
+	}).Info("Archived message redacted")
+
+	archived.Archived = true
+	return archived, nil
+}
+
+// redactCopies removes what is kept about a message outside its row: AI results, the
+// media file and our copies of it, the recorded webhook request and outbox payloads
+func (s *RedactionService) redactCopies(ctx context.Context, tx pgx.Tx, message *models.WhatsAppMessage) error {
+	if _, err := tx.Exec(ctx, `
+		UPDATE ai_results SET result = NULL, updated_at = NOW()
+		WHERE message_id = $1`, message.ID); err != nil {
+		return fmt.Errorf("failed to redact AI results: %w", err)
+	}
+
+	if message.WebhookEventID != nil {
+		if _, err := tx.Exec(ctx, `DELETE FROM webhook_events WHERE id = $1`, *message.WebhookEventID); err != nil {
+			return fmt.Errorf("failed to delete recorded webhook: %w", err)
+		}
+	}
+
+	// Forwards and webhooks queued or kept after processing carry the content. Revocation
+	// notices only carry IDs and must still reach the orchestrator.
+	var eventID *string
+	if message.WebhookEventID != nil {
+		eventID = optionalString(message.WebhookEventID.String())
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM outbox
+		WHERE (message_id = $1 AND kind <> $2)
+			OR (kind = $3 AND (payload->>'webhook_event_id' = $4
+				OR position('MessageSid=' || $5 IN payload->>'body') > 0))`,
+		message.ID, models.OutboxKindOrchestratorRevoke, models.OutboxKindTwilioWebhook,
+		eventID, optionalString(message.TwilioSID)); err != nil {
+		return fmt.Errorf("failed to delete outbox payloads: %w", err)
+	}
+
+	if message.MediaURL != nil && *message.MediaURL != "" {
+		if err := s.deleteMedia(ctx, *message.MediaURL); err != nil {
+			return err
+		}
+	}
+
+	// Copies we made of the media, e.g. re-hosted for the orchestrator
+	return s.mediaService.DeleteMessageMedia(ctx, message.ID)
+}
+
+// recordRedaction writes the audit entry of a redaction
+func (s *RedactionService) recordRedaction(ctx context.Context, tx pgx.Tx, message *models.WhatsAppMessage, archived bool, actor, role, clientIP, reason string) error {
+	details := map[string]interface{}{
+		"reason":    reason,
+		"had_media": message.MediaURL != nil && *message.MediaURL != "",
+		"channel":   message.Channel,
+		"direction": message.Direction,
+	}
+	if archived {
+		details["archived"] = true
+	}
+
+	return recordAudit(ctx, tx, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  role,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionMessageRedacted,
+		TargetType: "message",
+		TargetID:   message.ID.String(),
+		Details:    details,
+	})
+}
+
+// tombstone clears in a message what the redaction query clears in its row
+func tombstone(message *models.WhatsAppMessage, redactedAt time.Time) {
+	message.Content = RedactedContent
+	message.RawContent = nil
+	message.OriginalContent = nil
+	message.MediaURL = nil
+	message.ExtractedText = nil
+	message.Transcript = nil
+	message.MediaSHA256 = nil
+	message.FlowResponse = nil
+	message.RedactedAt = &redactedAt
+	message.UpdatedAt = redactedAt
+}
+
+// deleteMedia removes a redacted message's media from our bucket or from Twilio; media
+// hosted elsewhere (e.g., Meta's CDN) expires on its own and cannot be deleted by us
+func (s *RedactionService) deleteMedia(ctx context.Context, mediaURL string) error {
+	switch {
+	case s.mediaService.IsStoredMedia(mediaURL):
+		return s.mediaService.DeleteMedia(ctx, mediaURL)
+	case isTwilioURL(mediaURL) && twilioMediaPathPattern.MatchString(mediaURL):
+		return s.whatsappService.DeleteMedia(ctx, mediaURL)
+	default:
+		s.logger.WithField("media_url", mediaURL).Warn("Redacted media is hosted externally and was not deleted")
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+func TestTombstone(t *testing.T) {
+	text := func(s string) *string { return &s }
+	sessionID := uuid.New()
+	message := &models.WhatsAppMessage{
+		ID:              uuid.New(),
+		TwilioSID:       "SM123",
+		Content:         "my card is 4111 1111 1111 1111",
+		MediaURL:        text("https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME1"),
+		MediaType:       text("image/jpeg"),
+		SessionID:       &sessionID,
+		ExtractedText:   text("4111 1111 1111 1111"),
+		Transcript:      text("my card is"),
+		MediaSHA256:     text("abc"),
+		FlowResponse:    &models.FlowResponse{},
+		RawContent:      text("my card is 4111-1111-1111-1111"),
+		OriginalContent: text("meu cartão"),
+		Channel:         models.ChannelWhatsApp,
+	}
+	redactedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tombstone(message, redactedAt)
+
+	tests := []struct {
+		name    string
+		cleared bool
+	}{
+		{"content", message.Content == RedactedContent},
+		{"media_url", message.MediaURL == nil},
+		{"extracted_text", message.ExtractedText == nil},
+		{"transcript", message.Transcript == nil},
+		{"media_sha256", message.MediaSHA256 == nil},
+		{"flow_response", message.FlowResponse == nil},
+		{"raw_content", message.RawContent == nil},
+		{"original_content", message.OriginalContent == nil},
+		{"redacted_at", message.RedactedAt != nil && message.RedactedAt.Equal(redactedAt)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.cleared {
+				t.Errorf("%s was not redacted: %+v", tt.name, message)
+			}
+		})
+	}
+
+	// Metadata stays, as in the database
+	if message.TwilioSID != "SM123" || message.SessionID == nil || *message.MediaType != "image/jpeg" || message.Channel != models.ChannelWhatsApp {
+		t.Errorf("tombstone() removed message metadata: %+v", message)
+	}
+}
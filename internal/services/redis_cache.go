@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/secretbox"
+)
+
+// Classes of values kept in Redis, each with its own TTL
+const (
+	CacheClassMessages = "messages" // stored messages, read through by ID
+	CacheClassSends    = "sends"    // states of async sends
+	CacheClassSessions = "sessions" // the session store: addresses, active sessions, windows
+)
+
+// cacheClassTTLs are the TTLs of the cache classes unless CACHE_TTLS overrides them
+var cacheClassTTLs = map[string]time.Duration{
+	CacheClassMessages: 24 * time.Hour,
+	CacheClassSends:    24 * time.Hour,
+	CacheClassSessions: time.Hour,
+}
+
+var cacheUnreadableTotal = metrics.NewCounter("cache_unreadable_total", "Cached values dropped because they could not be decrypted or decoded, by class", "class")
+
+// RedisCache stores JSON values in Redis under namespaced keys,
+// "<namespace>:<class>:<id>", so environments and tenants sharing a Redis cannot read or
+// overwrite each other's entries. With an encryption key, values are sealed with
+// AES-256-GCM; values that no longer open, e.g. after a key change without the previous
+// key listed, are treated as misses.
+type RedisCache struct {
+	redis     *redis.Client
+	namespace string
+	box       *secretbox.Box
+	ttls      map[string]time.Duration
+	logger    *logrus.Logger
+}
+
+// NewRedisCache creates a Redis cache from the cache configuration
+func NewRedisCache(redisClient *redis.Client, cfg *config.Config, logger *logrus.Logger) (*RedisCache, error) {
+	namespace := cfg.RedisNamespace()
+
+	ttls := make(map[string]time.Duration, len(cacheClassTTLs))
+	for class, ttl := range cacheClassTTLs {
+		ttls[class] = ttl
+	}
+	for class, value := range cfg.CacheTTLs {
+		if _, ok := cacheClassTTLs[class]; !ok {
+			return nil, fmt.Errorf("unknown cache class %q in CACHE_TTLS", class)
+		}
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL of cache class %q: %w", class, err)
+		}
+		ttls[class] = ttl
+	}
+
+	cache := &RedisCache{
+		redis:     redisClient,
+		namespace: namespace,
+		ttls:      ttls,
+		logger:    logger,
+	}
+
+	if cfg.CacheEncryptionKey != "" {
+		box, err := secretbox.NewFromBase64(cfg.CacheEncryptionKeyID, cfg.CacheEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CACHE_ENCRYPTION_KEY: %w", err)
+		}
+		for keyID, encoded := range cfg.CacheEncryptionPrevKeys {
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CACHE_ENCRYPTION_PREVIOUS_KEYS key %q: %w", keyID, err)
+			}
+			if err := box.AddKey(keyID, key); err != nil {
+				return nil, fmt.Errorf("invalid CACHE_ENCRYPTION_PREVIOUS_KEYS key %q: %w", keyID, err)
+			}
+		}
+		cache.box = box
+	}
+
+	return cache, nil
+}
+
+// redisKey returns key in the Redis namespace, "<namespace>:<key>", for the locks, claims
+// and counters kept outside RedisCache, so environments sharing a Redis keep them apart
+func redisKey(cfg *config.Config, key string) string {
+	return cfg.RedisNamespace() + ":" + key
+}
+
+// Key returns the namespaced Redis key of an entry
+func (c *RedisCache) Key(class, id string) string {
+	return c.namespace + ":" + class + ":" + id
+}
+
+// Set stores value as the entry id of class for the class TTL
+func (c *RedisCache) Set(ctx context.Context, class, id string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached %s: %w", class, err)
+	}
+
+	stored := string(data)
+	if c.box != nil {
+		if stored, err = c.box.Seal(data); err != nil {
+			return fmt.Errorf("failed to encrypt cached %s: %w", class, err)
+		}
+	}
+
+	return c.redis.Set(ctx, c.Key(class, id), stored, c.ttls[class]).Err()
+}
+
+// Get loads the entry id of class into dest and reports whether it was found
+func (c *RedisCache) Get(ctx context.Context, class, id string, dest interface{}) (bool, error) {
+	key := c.Key(class, id)
+	stored, err := c.redis.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	data := []byte(stored)
+	if c.box != nil {
+		if data, err = c.box.Open(stored); err != nil {
+			c.drop(ctx, class, key, err)
+			return false, nil
+		}
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		c.drop(ctx, class, key, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Delete removes entries of class
+func (c *RedisCache) Delete(ctx context.Context, class string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = c.Key(class, id)
+	}
+	return c.redis.Del(ctx, keys...).Err()
+}
+
+// drop deletes an entry that cannot be read, such as one written in the clear before
+// encryption was enabled
+func (c *RedisCache) drop(ctx context.Context, class, key string, cause error) {
+	cacheUnreadableTotal.Inc(class)
+	c.logger.WithError(cause).WithField("class", class).Debug("Dropping unreadable cache entry")
+	if err := c.redis.Del(ctx, key).Err(); err != nil {
+		c.logger.WithError(err).Warn("Failed to drop unreadable cache entry")
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+func TestRedisKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		namespace   string
+		environment string
+		want        string
+	}{
+		{"environment default", "", "production", "re9:production:security:lock:ip:10.0.0.1"},
+		{"configured namespace", "re9:prod:acme", "production", "re9:prod:acme:security:lock:ip:10.0.0.1"},
+		{"trailing colon", "re9:staging:", "staging", "re9:staging:security:lock:ip:10.0.0.1"},
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{CacheNamespace: tt.namespace, Environment: tt.environment}
+			if got := redisKey(cfg, "security:lock:ip:10.0.0.1"); got != tt.want {
+				t.Errorf("redisKey() = %q, want %q", got, tt.want)
+			}
+
+			// Cache entries live in the same namespace
+			cache, err := NewRedisCache(nil, cfg, logger)
+			if err != nil {
+				t.Fatalf("NewRedisCache() error = %v", err)
+			}
+			if got, want := cache.Key("security", "lock:ip:10.0.0.1"), tt.want; got != want {
+				t.Errorf("Key() = %q, want %q", got, want)
+			}
+		})
+	}
+}
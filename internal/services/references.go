@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrInvalidReference is returned for references that cannot be stored
+var ErrInvalidReference = errors.New("invalid reference")
+
+// listingNextActionPrefix marks orchestrator next actions that reference a listing
+const listingNextActionPrefix = "listing:"
+
+// maxRefIDLength bounds referenced object IDs
+const maxRefIDLength = 255
+
+// referenceColumns lists the conversation_references columns in the order scanReference
+// expects
+const referenceColumns = `
+	id, session_id, message_id, kind, ref_id, source, created_at`
+
+// ReferenceService links conversations to the real-estate listings they are about, so
+// every conversation about a listing can be found
+type ReferenceService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewReferenceService creates a new reference service
+func NewReferenceService(db *pgxpool.Pool, logger *logrus.Logger) *ReferenceService {
+	return &ReferenceService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// LinkFromResponse stores the listings an orchestrator response refers to: the
+// listing_id and listing_ids context keys and a "listing:<id>" next action. References
+// already stored are kept, so a replayed response adds nothing.
+func (s *ReferenceService) LinkFromResponse(ctx context.Context, sessionID uuid.UUID, messageID *uuid.UUID, responseContext map[string]interface{}, nextAction string) error {
+	var fromContext []string
+	if id := referenceID(responseContext["listing_id"]); id != "" {
+		fromContext = append(fromContext, id)
+	}
+	if ids, ok := responseContext["listing_ids"].([]interface{}); ok {
+		for _, value := range ids {
+			if id := referenceID(value); id != "" {
+				fromContext = append(fromContext, id)
+			}
+		}
+	}
+	if err := s.Link(ctx, sessionID, messageID, models.ReferenceKindListing, models.ReferenceSourceContext, fromContext...); err != nil {
+		return err
+	}
+
+	action := strings.TrimSpace(nextAction)
+	if strings.HasPrefix(strings.ToLower(action), listingNextActionPrefix) {
+		id := strings.TrimSpace(action[len(listingNextActionPrefix):])
+		return s.Link(ctx, sessionID, messageID, models.ReferenceKindListing, models.ReferenceSourceNextAction, id)
+	}
+	return nil
+}
+
+// Link stores references from a session, and optionally one of its messages, to objects
+// of a kind. References that already exist are kept as they are.
+func (s *ReferenceService) Link(ctx context.Context, sessionID uuid.UUID, messageID *uuid.UUID, kind, source string, refIDs ...string) error {
+	for _, refID := range refIDs {
+		refID = strings.TrimSpace(refID)
+		if refID == "" || len(refID) > maxRefIDLength {
+			return fmt.Errorf("%w: %s ID must be 1-%d characters", ErrInvalidReference, kind, maxRefIDLength)
+		}
+
+		_, err := s.db.Exec(ctx, `
+			INSERT INTO conversation_references (id, session_id, message_id, kind, ref_id, source, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT DO NOTHING`,
+			uuid.New(), sessionID, messageID, kind, refID, source,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store conversation reference: %w", err)
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"session_id": sessionID,
+			"kind":       kind,
+			"ref_id":     refID,
+			"source":     source,
+		}).Debug("Conversation reference stored")
+	}
+	return nil
+}
+
+// ListForSession returns the references of a session, oldest first
+func (s *ReferenceService) ListForSession(ctx context.Context, sessionID uuid.UUID) ([]*models.ConversationReference, error) {
+	rows, err := s.db.Query(ctx, `SELECT`+referenceColumns+`
+		FROM conversation_references
+		WHERE session_id = $1
+		ORDER BY created_at, id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversation references: %w", err)
+	}
+	defer rows.Close()
+
+	references := []*models.ConversationReference{}
+	for rows.Next() {
+		reference, err := scanReference(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation reference: %w", err)
+		}
+		references = append(references, reference)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading conversation references: %w", err)
+	}
+
+	return references, nil
+}
+
+// ListConversations returns the conversations that reference an object, most recently
+// referenced first
+func (s *ReferenceService) ListConversations(ctx context.Context, kind, refID string, limit, offset int) ([]*models.ReferencedConversation, int64, error) {
+	query := `
+		SELECT s.id, s.user_id, s.status, s.started_at, s.ended_at, s.last_activity_at,
+			MIN(r.created_at), MAX(r.created_at), COUNT(*)
+		FROM conversation_references r
+		JOIN chat_sessions s ON s.id = r.session_id
+		WHERE r.kind = $1 AND r.ref_id = $2
+		GROUP BY s.id
+		ORDER BY MAX(r.created_at) DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := s.db.Query(ctx, query, kind, refID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query referenced conversations: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := []*models.ReferencedConversation{}
+	for rows.Next() {
+		var conversation models.ReferencedConversation
+		if err := rows.Scan(
+			&conversation.SessionID,
+			&conversation.UserID,
+			&conversation.Status,
+			&conversation.StartedAt,
+			&conversation.EndedAt,
+			&conversation.LastActivityAt,
+			&conversation.FirstReferencedAt,
+			&conversation.LastReferencedAt,
+			&conversation.References,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan referenced conversation: %w", err)
+		}
+		conversations = append(conversations, &conversation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading referenced conversations: %w", err)
+	}
+
+	return conversations, estimateTotal(ctx, s.db, s.logger, query, kind, refID, limit, offset), nil
+}
+
+// Helper functions
+
+// referenceID converts a context value to an object ID; listing IDs may arrive as
+// strings or numbers
+func referenceID(value interface{}) string {
+	switch id := value.(type) {
+	case string:
+		return strings.TrimSpace(id)
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return ""
+}
+
+// scanReference scans a conversation_references row selected with referenceColumns
+func scanReference(row pgx.Row) (*models.ConversationReference, error) {
+	var reference models.ConversationReference
+	err := row.Scan(
+		&reference.ID,
+		&reference.SessionID,
+		&reference.MessageID,
+		&reference.Kind,
+		&reference.RefID,
+		&reference.Source,
+		&reference.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &reference, nil
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// What happens to the content of a message the user deleted for everyone
+const (
+	RevokedMessageKeep  = "keep"  // keep the content, only mark the message revoked
+	RevokedMessagePurge = "purge" // redact the content and delete its media
+)
+
+var messagesRevokedTotal = metrics.NewCounter("messages_revoked_total", "Messages deleted for everyone by their sender, by channel", "channel")
+
+// RevocationService handles messages users delete for everyone after sending them
+type RevocationService struct {
+	db               *pgxpool.Pool
+	messageService   *MessageService
+	redactionService *RedactionService
+	aiService        *AIService
+	eventService     *EventService
+	outbox           *OutboxService
+	config           *appConfig.Config
+	logger           *logrus.Logger
+}
+
+// NewRevocationService creates a new revocation service instance
+func NewRevocationService(
+	db *pgxpool.Pool,
+	messageService *MessageService,
+	redactionService *RedactionService,
+	aiService *AIService,
+	eventService *EventService,
+	outbox *OutboxService,
+	cfg *appConfig.Config,
+	logger *logrus.Logger,
+) *RevocationService {
+	return &RevocationService{
+		db:               db,
+		messageService:   messageService,
+		redactionService: redactionService,
+		aiService:        aiService,
+		eventService:     eventService,
+		outbox:           outbox,
+		config:           cfg,
+		logger:           logger,
+	}
+}
+
+// Revoke marks the inbound message with a provider SID as deleted by the user and
+// notifies the orchestrator through the outbox, in the same transaction. With
+// REVOKED_MESSAGE_POLICY=purge the content is redacted as well. Revoking twice is a
+// no-op; an unknown SID returns ErrMessageNotFound.
+func (s *RevocationService) Revoke(ctx context.Context, providerSID string) (*models.WhatsAppMessage, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin revocation: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var message models.WhatsAppMessage
+	query := `
+		UPDATE whatsapp_messages
+		SET revoked_at = NOW(), updated_at = NOW()
+		WHERE twilio_sid = $1 AND direction = 'inbound' AND revoked_at IS NULL
+		RETURNING` + messageColumns
+	if err := scanMessageInto(tx.QueryRow(ctx, query, providerSID), &message); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to revoke message: %w", err)
+		}
+		// Already revoked, or not a message we know
+		var existing models.WhatsAppMessage
+		query = `SELECT` + messageColumns + ` FROM whatsapp_messages WHERE twilio_sid = $1 AND direction = 'inbound'`
+		if err := scanMessageInto(s.db.QueryRow(ctx, query, providerSID), &existing); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrMessageNotFound
+			}
+			return nil, fmt.Errorf("failed to get revoked message: %w", err)
+		}
+		return &existing, nil
+	}
+
+	// The notice carries no content, which the purge policy is about to remove
+	entry, err := NewOutboxEntry(models.OutboxKindOrchestratorRevoke, &message.ID, &RevocationNotice{
+		MessageID: message.ID.String(),
+		UserPhone: message.From,
+		Channel:   message.Channel,
+		RevokedAt: *message.RevokedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := insertOutboxEntries(ctx, tx, []*models.OutboxEntry{entry}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit revocation: %w", err)
+	}
+	s.outbox.Dispatch(entry)
+	s.messageService.InvalidateCache(ctx, message.ID)
+
+	messagesRevokedTotal.Inc(string(message.Channel))
+	s.logger.WithField("message_id", message.ID).Info("Message revoked by user")
+
+	if s.config.RevokedMessagePolicy == RevokedMessagePurge {
+		redacted, err := s.redactionService.RedactMessage(ctx, message.ID, "user", "", "", "deleted for everyone")
+		if err != nil {
+			s.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to purge revoked message")
+		} else {
+			message = *redacted
+		}
+	}
+
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventMessageRevoked,
+		MessageID: &message.ID,
+		SessionID: message.SessionID,
+		Data: map[string]interface{}{
+			"channel":    message.Channel,
+			"twilio_sid": message.TwilioSID,
+			"purged":     message.RedactedAt != nil,
+		},
+	})
+
+	return &message, nil
+}
+
+// DeliverRevocation tells the orchestrator about a revoked message from the outbox
+func (s *RevocationService) DeliverRevocation(ctx context.Context, entry *models.OutboxEntry) error {
+	var notice RevocationNotice
+	if err := json.Unmarshal(entry.Payload, &notice); err != nil {
+		return fmt.Errorf("failed to decode revocation notice: %w", err)
+	}
+	return s.aiService.NotifyRevoked(ctx, &notice)
+}
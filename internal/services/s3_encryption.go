@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	appConfig "github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// S3 server-side encryption modes
+const (
+	S3SSEDisabled = ""
+	S3SSES3       = string(types.ServerSideEncryptionAes256)
+	S3SSEKMS      = string(types.ServerSideEncryptionAwsKms)
+)
+
+// applyObjectSettings sets the configured server-side encryption and, when enabled, the
+// given tags on an upload
+func applyObjectSettings(cfg *appConfig.Config, input *s3.PutObjectInput, tags url.Values) {
+	switch cfg.S3SSE {
+	case S3SSES3:
+		input.ServerSideEncryption = types.ServerSideEncryptionAes256
+	case S3SSEKMS:
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		if cfg.S3SSEKMSKeyID != "" {
+			input.SSEKMSKeyId = aws.String(cfg.S3SSEKMSKeyID)
+		}
+		input.BucketKeyEnabled = aws.Bool(cfg.S3BucketKeyEnabled)
+	}
+
+	if cfg.S3ObjectTagging && len(tags) > 0 {
+		input.Tagging = aws.String(tags.Encode())
+	}
+}
+
+// encryptionHeaders are the headers a client must send with a presigned PUT created
+// from an input prepared by applyObjectSettings
+func encryptionHeaders(input *s3.PutObjectInput) map[string]string {
+	headers := map[string]string{}
+	if input.ServerSideEncryption != "" {
+		headers["x-amz-server-side-encryption"] = string(input.ServerSideEncryption)
+	}
+	if input.SSEKMSKeyId != nil {
+		headers["x-amz-server-side-encryption-aws-kms-key-id"] = *input.SSEKMSKeyId
+	}
+	if input.BucketKeyEnabled != nil {
+		headers["x-amz-server-side-encryption-bucket-key-enabled"] = fmt.Sprint(*input.BucketKeyEnabled)
+	}
+	return headers
+}
+
+// messageObjectTags tags objects holding a message's media with its tenant and conversation
+func messageObjectTags(message *models.WhatsAppMessage) url.Values {
+	tags := url.Values{}
+	tags.Set("tenant", messageTenant(message))
+	if message.SessionID != nil {
+		tags.Set("conversation", message.SessionID.String())
+	}
+	return tags
+}
+
+// checkBucketEncryption verifies that a bucket encrypts new objects by default, with
+// KMS and the configured key when SSE-KMS is selected
+func checkBucketEncryption(ctx context.Context, client *s3.Client, cfg *appConfig.Config, bucket string) error {
+	output, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ServerSideEncryptionConfigurationNotFoundError" {
+			return fmt.Errorf("bucket %s has no default encryption", bucket)
+		}
+		return fmt.Errorf("failed to read encryption of bucket %s: %w", bucket, err)
+	}
+
+	if output.ServerSideEncryptionConfiguration != nil {
+		for _, rule := range output.ServerSideEncryptionConfiguration.Rules {
+			encryption := rule.ApplyServerSideEncryptionByDefault
+			if encryption == nil {
+				continue
+			}
+			if cfg.S3SSE != S3SSEKMS {
+				return nil
+			}
+			if encryption.SSEAlgorithm != types.ServerSideEncryptionAwsKms && encryption.SSEAlgorithm != types.ServerSideEncryptionAwsKmsDsse {
+				return fmt.Errorf("bucket %s encrypts with %s, not KMS", bucket, encryption.SSEAlgorithm)
+			}
+			if cfg.S3SSEKMSKeyID != "" && aws.ToString(encryption.KMSMasterKeyID) != cfg.S3SSEKMSKeyID {
+				return fmt.Errorf("bucket %s encrypts with a different KMS key", bucket)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("bucket %s has no default encryption", bucket)
+}
+
+// CheckEncryption verifies that the media bucket encrypts objects by default
+func (m *MediaService) CheckEncryption(ctx context.Context) error {
+	if m.bucket == "" {
+		return nil
+	}
+	return checkBucketEncryption(ctx, m.s3Client, m.config, m.bucket)
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrInvalidSecurityCaller is returned for an unlock of an unknown kind of caller
+var ErrInvalidSecurityCaller = errors.New("invalid security caller")
+
+// Kinds of abusive behavior the security guard detects
+const (
+	SecurityKindAuthFailures = "auth_failures" // repeated 401s: guessing keys or tokens
+	SecurityKindDenied       = "denied"        // repeated 403s: probing beyond the role
+	SecurityKindNotFound     = "not_found"     // repeated 404s: enumerating IDs
+	SecurityKindKeySpread    = "key_spread"    // one key used from many IPs: a leaked key
+)
+
+// securityEventsTotal counts detected anomalies by kind
+var securityEventsTotal = metrics.NewCounter("security_events_total", "Abusive API callers detected, by kind", "kind")
+
+// securityCountScript adds one to the counter in KEYS[1] and returns it. A counter
+// without a TTL, i.e. the first of its window, expires after ARGV[1] milliseconds;
+// doing both in one script means a counter can never be left without one.
+var securityCountScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// securitySpreadScript adds ARGV[2] to the set in KEYS[1] and returns its size, setting
+// the window of ARGV[1] milliseconds like securityCountScript
+var securitySpreadScript = redis.NewScript(`
+redis.call('SADD', KEYS[1], ARGV[2])
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return redis.call('SCARD', KEYS[1])
+`)
+
+// securityCaller identifies who is counted: an IP, or the API key presented
+type securityCaller struct {
+	kind string // "ip" or "api_key", the audit target type
+	id   string // the IP, or the key's displayable prefix
+}
+
+// SecurityService watches the responses the API gives each caller for brute force and
+// unusual patterns, and locks out callers that cross a threshold for SECURITY_LOCKOUT.
+// Counters and locks live in Redis so every replica enforces them; each detection is
+// written to the audit log once per window. Redis failures let requests through.
+// IPs are only counted and locked when TRUSTED_PROXIES is set: behind a load balancer
+// without it every caller has the balancer's IP, and one lockout would stop them all.
+type SecurityService struct {
+	redis  *redis.Client
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewSecurityService creates a new security service
+func NewSecurityService(redisClient *redis.Client, db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *SecurityService {
+	return &SecurityService{
+		redis:  redisClient,
+		db:     db,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Locked reports whether the caller's IP or API key is locked out, and for how long
+func (s *SecurityService) Locked(ctx context.Context, clientIP, apiKey string) (time.Duration, bool) {
+	var keys []string
+	if s.tracksIPs() {
+		keys = append(keys, s.lockKey(securityCaller{kind: "ip", id: clientIP}))
+	}
+	if prefix := apiKeyDisplayPrefix(apiKey); prefix != "" {
+		keys = append(keys, s.lockKey(securityCaller{kind: "api_key", id: prefix}))
+	}
+
+	var longest time.Duration
+	for _, key := range keys {
+		ttl, err := s.redis.PTTL(ctx, key).Result()
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to check security lock")
+			return 0, false
+		}
+		if ttl > longest {
+			longest = ttl
+		}
+	}
+	return longest, longest > 0
+}
+
+// Unlock lifts a caller's lockout and resets its counters, e.g. after a customer was
+// locked out by a misconfigured client. callerType is "ip" or "api_key".
+func (s *SecurityService) Unlock(ctx context.Context, callerType, callerID, actor, actorRole, clientIP string) error {
+	if callerType != "ip" && callerType != "api_key" {
+		return fmt.Errorf("%w: caller type must be ip or api_key", ErrInvalidSecurityCaller)
+	}
+	caller := securityCaller{kind: callerType, id: callerID}
+
+	keys := []string{s.lockKey(caller)}
+	for _, kind := range []string{SecurityKindAuthFailures, SecurityKindDenied, SecurityKindNotFound, SecurityKindKeySpread} {
+		keys = append(keys, s.countKey(caller, kind), s.reportedKey(caller, kind))
+	}
+	removed, err := s.redis.Del(ctx, keys...).Result()
+	if err != nil {
+		return fmt.Errorf("failed to unlock caller: %w", err)
+	}
+
+	return recordAudit(ctx, s.db, &models.AuditEntry{
+		Actor:      actor,
+		ActorRole:  actorRole,
+		ClientIP:   clientIP,
+		Action:     models.AuditActionSecurityUnlocked,
+		TargetType: caller.kind,
+		TargetID:   caller.id,
+		Details:    map[string]interface{}{"keys_removed": removed},
+	})
+}
+
+// ObserveCall counts a finished API call against its caller's thresholds. apiKey is
+// only set for keys that authenticated, so a forged key cannot get a real one locked.
+func (s *SecurityService) ObserveCall(clientIP, apiKey, route string, status int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ip := securityCaller{kind: "ip", id: clientIP}
+	caller := ip
+	if prefix := apiKeyDisplayPrefix(apiKey); prefix != "" && status != http.StatusUnauthorized {
+		caller = securityCaller{kind: "api_key", id: prefix}
+	}
+
+	if caller.kind == "ip" && !s.tracksIPs() {
+		return
+	}
+
+	switch status {
+	case http.StatusUnauthorized:
+		// Guesses come with a different key every time, so count them per IP
+		s.count(ctx, ip, SecurityKindAuthFailures, s.config.SecurityAuthFailureLimit, route)
+	case http.StatusForbidden:
+		s.count(ctx, caller, SecurityKindDenied, s.config.SecurityDeniedLimit, route)
+	case http.StatusNotFound:
+		s.count(ctx, caller, SecurityKindNotFound, s.config.SecurityNotFoundLimit, route)
+	default:
+		if caller.kind == "api_key" && status < http.StatusBadRequest {
+			s.spread(ctx, caller, clientIP, route)
+		}
+	}
+}
+
+// Helper methods
+
+// tracksIPs reports whether client IPs are trustworthy enough to count and lock
+func (s *SecurityService) tracksIPs() bool {
+	return len(s.config.TrustedProxies) > 0
+}
+
+// count adds one to a caller's counter of kind and reports the caller once it reaches limit
+func (s *SecurityService) count(ctx context.Context, caller securityCaller, kind string, limit int, route string) {
+	if limit <= 0 {
+		return
+	}
+
+	// The window starts with the first counted call
+	n, err := securityCountScript.Run(ctx, s.redis, []string{s.countKey(caller, kind)},
+		s.config.SecurityWindow.Milliseconds()).Int64()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to count API call")
+		return
+	}
+
+	if n >= int64(limit) {
+		s.detected(ctx, caller, kind, n, limit, route)
+	}
+}
+
+// spread tracks the distinct IPs using an API key and reports keys used from too many
+func (s *SecurityService) spread(ctx context.Context, caller securityCaller, clientIP, route string) {
+	limit := s.config.SecurityKeyIPLimit
+	if limit <= 0 {
+		return
+	}
+
+	n, err := securitySpreadScript.Run(ctx, s.redis, []string{s.countKey(caller, SecurityKindKeySpread)},
+		s.config.SecurityWindow.Milliseconds(), clientIP).Int64()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to track API key addresses")
+		return
+	}
+
+	if n > int64(limit) {
+		s.detected(ctx, caller, SecurityKindKeySpread, n, limit, route)
+	}
+}
+
+// detected locks a caller out and audits it, once per window and kind
+func (s *SecurityService) detected(ctx context.Context, caller securityCaller, kind string, count int64, limit int, route string) {
+	marker := s.reportedKey(caller, kind)
+	first, err := s.redis.SetNX(ctx, marker, 1, s.config.SecurityWindow).Result()
+	if err != nil || !first {
+		return
+	}
+
+	lockedUntil := time.Now().Add(s.config.SecurityLockout)
+	if err := s.redis.Set(ctx, s.lockKey(caller), lockedUntil.Unix(), s.config.SecurityLockout).Err(); err != nil {
+		s.logger.WithError(err).Warn("Failed to lock out API caller")
+	}
+
+	securityEventsTotal.Inc(kind)
+	s.logger.WithFields(logrus.Fields{
+		"caller_type": caller.kind,
+		"caller":      caller.id,
+		"kind":        kind,
+		"count":       count,
+		"route":       route,
+	}).Warn("Abusive API caller locked out")
+
+	err = recordAudit(ctx, s.db, &models.AuditEntry{
+		Actor:      "security",
+		Action:     models.AuditActionSecurityLockout,
+		TargetType: caller.kind,
+		TargetID:   caller.id,
+		Details: map[string]interface{}{
+			"kind":         kind,
+			"count":        count,
+			"limit":        limit,
+			"window":       s.config.SecurityWindow.String(),
+			"route":        route,
+			"locked_until": lockedUntil,
+		},
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to audit security event")
+	}
+}
+
+// lockKey is the Redis key holding a caller's lockout
+func (s *SecurityService) lockKey(caller securityCaller) string {
+	return redisKey(s.config, fmt.Sprintf("security:lock:%s:%s", caller.kind, caller.id))
+}
+
+// countKey is the Redis key counting a caller's calls of a kind in the current window
+func (s *SecurityService) countKey(caller securityCaller, kind string) string {
+	return redisKey(s.config, fmt.Sprintf("security:count:%s:%s:%s", kind, caller.kind, caller.id))
+}
+
+// reportedKey marks that a caller was reported for a kind in the current window
+func (s *SecurityService) reportedKey(caller securityCaller, kind string) string {
+	return redisKey(s.config, fmt.Sprintf("security:reported:%s:%s:%s", kind, caller.kind, caller.id))
+}
+
+// apiKeyDisplayPrefix returns the prefix shown for a key in the admin API, re9_<id>, or
+// "" when the value is not shaped like one of our keys
+func apiKeyDisplayPrefix(apiKey string) string {
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return ""
+	}
+	rest := apiKey[len(apiKeyPrefix):]
+	i := strings.IndexByte(rest, '_')
+	if i != 8 {
+		return ""
+	}
+	return apiKeyPrefix + rest[:i]
+}
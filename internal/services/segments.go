@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+var (
+	// ErrInvalidSegment is returned for segments whose name or filters are invalid
+	ErrInvalidSegment = errors.New("invalid segment")
+
+	// ErrSegmentNotFound is returned for unknown segments
+	ErrSegmentNotFound = errors.New("segment not found")
+
+	// ErrSegmentInUse is returned when deleting a segment a campaign has yet to launch with
+	ErrSegmentInUse = errors.New("segment is used by a campaign that has not launched")
+)
+
+// segmentPreviewSample is how many recipients a preview lists
+const segmentPreviewSample = 10
+
+// segmentColumns lists the segments columns in the order scanSegment expects
+const segmentColumns = ` id, name, filters, created_by, created_at, updated_at`
+
+// SegmentService stores audience definitions and resolves them to the recipients of a
+// channel: the phone number of each user on WhatsApp and SMS, the linked identity of
+// the channel otherwise.
+type SegmentService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewSegmentService creates a new segment service instance
+func NewSegmentService(db *pgxpool.Pool, logger *logrus.Logger) *SegmentService {
+	return &SegmentService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create validates and stores a segment
+func (s *SegmentService) Create(ctx context.Context, request *models.CreateSegmentRequest, createdBy string) (*models.Segment, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSegment)
+	}
+	filters, err := normalizeSegmentFilters(request.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	segment, err := scanSegment(s.db.QueryRow(ctx, `
+		INSERT INTO segments (id, name, filters, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		RETURNING`+segmentColumns, uuid.New(), name, filters, createdBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store segment: %w", err)
+	}
+	return segment, nil
+}
+
+// Get returns a segment by ID
+func (s *SegmentService) Get(ctx context.Context, id uuid.UUID) (*models.Segment, error) {
+	segment, err := scanSegment(s.db.QueryRow(ctx, `SELECT`+segmentColumns+` FROM segments WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSegmentNotFound
+		}
+		return nil, fmt.Errorf("failed to load segment: %w", err)
+	}
+	return segment, nil
+}
+
+// List returns segments, newest first
+func (s *SegmentService) List(ctx context.Context, limit, offset int) ([]*models.Segment, int64, error) {
+	query := `SELECT` + segmentColumns + ` FROM segments
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := s.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query segments: %w", err)
+	}
+	defer rows.Close()
+
+	segments := []*models.Segment{}
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan segment: %w", err)
+		}
+		segments = append(segments, segment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading segments: %w", err)
+	}
+
+	return segments, estimateTotal(ctx, s.db, s.logger, query, limit, offset), nil
+}
+
+// Delete removes a segment. Campaigns that launched with it keep their recipients;
+// a campaign that has yet to launch with it blocks the deletion.
+func (s *SegmentService) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM segments
+		WHERE id = $1 AND NOT EXISTS (
+			SELECT 1 FROM campaigns
+			WHERE segment_id = $1 AND started_at IS NULL
+		)`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete segment: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrSegmentInUse
+	}
+	return nil
+}
+
+// Preview counts the recipients filters resolve to on a channel right now and lists a
+// few of them
+func (s *SegmentService) Preview(ctx context.Context, filters models.SegmentFilters, channel models.Channel) (*models.SegmentPreview, error) {
+	filters, err := normalizeSegmentFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	audience, args := segmentAudienceQuery(filters, channel, nil)
+	preview := &models.SegmentPreview{Channel: channel, Sample: []string{}}
+
+	if err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM (`+audience+`) audience`, args...).Scan(&preview.Count); err != nil {
+		return nil, fmt.Errorf("failed to count segment: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx, `SELECT recipient FROM (`+audience+`) audience ORDER BY recipient LIMIT `+fmt.Sprint(segmentPreviewSample), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample segment: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipient string
+		if err := rows.Scan(&recipient); err != nil {
+			return nil, fmt.Errorf("failed to scan segment recipient: %w", err)
+		}
+		preview.Sample = append(preview.Sample, recipient)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading segment recipients: %w", err)
+	}
+
+	return preview, nil
+}
+
+// Materialize snapshots the members of a segment on the campaign's channel into its
+// recipients, at most limit of them, and returns how many were added. It runs in the
+// transaction that launches the campaign.
+func (s *SegmentService) Materialize(ctx context.Context, tx pgx.Tx, campaign *models.Campaign, segmentID uuid.UUID, limit int) (int, error) {
+	segment, err := scanSegment(tx.QueryRow(ctx, `SELECT`+segmentColumns+` FROM segments WHERE id = $1`, segmentID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrSegmentNotFound
+		}
+		return 0, fmt.Errorf("failed to load segment: %w", err)
+	}
+
+	args := []interface{}{campaign.ID, limit}
+	audience, args := segmentAudienceQuery(segment.Filters, campaign.Channel, args)
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO campaign_recipients (campaign_id, position, recipient)
+		SELECT $1, ROW_NUMBER() OVER (ORDER BY recipient), recipient
+		FROM (`+audience+` ORDER BY recipient LIMIT $2) audience`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store segment audience: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Helper functions
+
+// normalizeSegmentFilters trims and validates filters, lowercasing the consent
+// category the way consents are stored
+func normalizeSegmentFilters(filters models.SegmentFilters) (models.SegmentFilters, error) {
+	tags := make([]string, 0, len(filters.Tags))
+	for _, tag := range filters.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	filters.Tags = tags
+	if len(tags) == 0 {
+		filters.MatchAllTags = false
+	}
+
+	if filters.ActiveWithinHours != nil && *filters.ActiveWithinHours <= 0 {
+		return filters, fmt.Errorf("%w: active_within_hours must be positive", ErrInvalidSegment)
+	}
+	if filters.InactiveForHours != nil && *filters.InactiveForHours <= 0 {
+		return filters, fmt.Errorf("%w: inactive_for_hours must be positive", ErrInvalidSegment)
+	}
+
+	filters.ConsentCategory = strings.ToLower(strings.TrimSpace(filters.ConsentCategory))
+
+	regions := make([]string, 0, len(filters.Regions))
+	for _, region := range filters.Regions {
+		region = normalizePhoneNumber(region)
+		if region == "" {
+			continue
+		}
+		if len(region) < 2 || strings.Trim(region[1:], "0123456789") != "" {
+			return filters, fmt.Errorf("%w: region %q is not a phone number prefix", ErrInvalidSegment, region)
+		}
+		regions = append(regions, region)
+	}
+	filters.Regions = regions
+
+	return filters, nil
+}
+
+// segmentAudienceQuery builds the query of the distinct recipients filters select on
+// channel, as a `recipient` column. Its parameters are appended to args, so it can be
+// embedded in a statement with parameters of its own.
+func segmentAudienceQuery(filters models.SegmentFilters, channel models.Channel, args []interface{}) (string, []interface{}) {
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var query strings.Builder
+	recipient := "u.phone_number"
+	if kind, ok := models.IdentityKindForChannel(channel); ok {
+		recipient = "i.value"
+		query.WriteString(`SELECT DISTINCT i.value AS recipient FROM whatsapp_users u
+			JOIN user_identities i ON i.user_id = u.id AND i.kind = ` + param(kind))
+	} else {
+		query.WriteString(`SELECT DISTINCT u.phone_number AS recipient FROM whatsapp_users u`)
+	}
+
+	query.WriteString(`
+		WHERE u.merged_into IS NULL AND u.is_active AND ` + recipient + ` IS NOT NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM recipient_suppressions rs
+				WHERE rs.channel = ` + param(channel) + ` AND rs.recipient = ` + recipient + `
+			)`)
+
+	if len(filters.Tags) > 0 {
+		if filters.MatchAllTags {
+			query.WriteString(`
+			AND ` + param(filters.Tags) + `::text[] <@ ARRAY(
+				SELECT unnest(cs.tags) FROM chat_sessions cs WHERE cs.user_id = u.id
+			)`)
+		} else {
+			query.WriteString(`
+			AND EXISTS (
+				SELECT 1 FROM chat_sessions cs
+				WHERE cs.user_id = u.id AND cs.tags && ` + param(filters.Tags) + `::text[]
+			)`)
+		}
+	}
+
+	if filters.ActiveWithinHours != nil {
+		query.WriteString(`
+			AND EXISTS (
+				SELECT 1 FROM chat_sessions cs
+				WHERE cs.user_id = u.id AND cs.last_activity_at >= NOW() - ` + param(*filters.ActiveWithinHours) + ` * INTERVAL '1 hour'
+			)`)
+	}
+
+	// Users who never had a session count as inactive
+	if filters.InactiveForHours != nil {
+		query.WriteString(`
+			AND NOT EXISTS (
+				SELECT 1 FROM chat_sessions cs
+				WHERE cs.user_id = u.id AND cs.last_activity_at >= NOW() - ` + param(*filters.InactiveForHours) + ` * INTERVAL '1 hour'
+			)`)
+	}
+
+	if filters.ConsentCategory != "" {
+		query.WriteString(`
+			AND ` + param(filters.ConsentCategory) + ` = ANY(u.consent_categories)`)
+	}
+
+	if len(filters.Regions) > 0 {
+		patterns := make([]string, len(filters.Regions))
+		for i, region := range filters.Regions {
+			patterns[i] = region + "%"
+		}
+		query.WriteString(`
+			AND u.phone_number LIKE ANY(` + param(patterns) + `::text[])`)
+	}
+
+	return query.String(), args
+}
+
+// scanSegment reads a segment selected with segmentColumns
+func scanSegment(row pgx.Row) (*models.Segment, error) {
+	var segment models.Segment
+	err := row.Scan(
+		&segment.ID, &segment.Name, &segment.Filters, &segment.CreatedBy,
+		&segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &segment, nil
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Send queue errors
+var (
+	ErrSendQueueFull    = errors.New("send queue is full")
+	ErrSendQueueStopped = errors.New("send queue is stopped")
+	ErrSendNotFound     = errors.New("send not found")
+)
+
+// Send queue metrics
+var (
+	sendQueueDepth  = metrics.NewGauge("send_queue_depth", "Async sends waiting for a worker")
+	asyncSendsTotal = metrics.NewCounter("async_sends_total", "Async sends by outcome", "outcome")
+)
+
+// SendJob performs one send and stores the outbound message
+type SendJob func(ctx context.Context) (*models.SendMessageResponse, error)
+
+// errSendAbandoned is recorded on sends still queued when the drain timeout ran out
+var errSendAbandoned = errors.New("send queue stopped before the send was performed")
+
+// queuedSend is a send waiting for a worker
+type queuedSend struct {
+	send    *models.AsyncSend
+	job     SendJob
+	release func()
+}
+
+// SendQueue performs sends accepted by the API on a fixed number of workers, so API
+// latency does not depend on provider latency. The queue is in memory and bounded;
+// send states are kept in Redis, for the sends cache class TTL, for lookup from any replica.
+// On shutdown the queue is drained for a limited time, and sends left then are failed.
+type SendQueue struct {
+	cache   *RedisCache
+	config  *config.Config
+	logger  *logrus.Logger
+	jobs    chan queuedSend
+	mu      sync.Mutex // serializes submissions
+	stopped bool       // set under mu once the queue drains; submissions are rejected
+}
+
+// NewSendQueue creates a new send queue
+func NewSendQueue(cache *RedisCache, cfg *config.Config, logger *logrus.Logger) *SendQueue {
+	return &SendQueue{
+		cache:  cache,
+		config: cfg,
+		logger: logger,
+		jobs:   make(chan queuedSend, cfg.SendQueueSize),
+	}
+}
+
+// Start runs the workers until ctx is done. New sends are rejected from then on, and
+// the queued ones are still performed for up to the drain timeout; those left after it
+// are marked failed and released. Start returns once the queue is empty.
+func (q *SendQueue) Start(ctx context.Context) {
+	q.workers(func() { q.work(ctx) })
+
+	q.mu.Lock()
+	q.stopped = true
+	q.mu.Unlock()
+
+	q.drain()
+}
+
+// SendTask is a send to queue under its tracking ID. Release, when set, gives back the
+// claims the send holds if it is abandoned without its job running.
+type SendTask struct {
+	ID      uuid.UUID
+	Job     SendJob
+	Release func()
+}
+
+// Submit queues a send and returns its state. It returns ErrSendQueueFull instead of
+// waiting when every slot is taken, and ErrSendQueueStopped while shutting down.
+func (q *SendQueue) Submit(ctx context.Context, task SendTask) (*models.AsyncSend, error) {
+	sends, err := q.SubmitAll(ctx, task)
+	if err != nil {
+		return nil, err
+	}
+	return sends[0], nil
+}
+
+// SubmitAll queues either every task or, when they do not all fit, none of them
+func (q *SendQueue) SubmitAll(ctx context.Context, tasks ...SendTask) ([]*models.AsyncSend, error) {
+	now := time.Now()
+	queued := make([]queuedSend, len(tasks))
+	sends := make([]*models.AsyncSend, len(tasks))
+	for i, task := range tasks {
+		send := &models.AsyncSend{
+			ID:        task.ID,
+			Status:    models.SendStatusQueued,
+			QueuedAt:  now,
+			UpdatedAt: now,
+		}
+		copied := *send
+		queued[i] = queuedSend{send: send, job: task.Job, release: task.Release}
+		sends[i] = &copied
+	}
+
+	// Workers only take sends out, so the free slots checked under the lock stay free
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		asyncSendsTotal.Add(float64(len(tasks)), "rejected")
+		return nil, ErrSendQueueStopped
+	}
+	if cap(q.jobs)-len(q.jobs) < len(tasks) {
+		asyncSendsTotal.Add(float64(len(tasks)), "rejected")
+		return nil, ErrSendQueueFull
+	}
+
+	for _, entry := range queued {
+		if err := q.save(ctx, entry.send); err != nil {
+			q.forget(ctx, queued)
+			return nil, err
+		}
+	}
+
+	for _, entry := range queued {
+		q.jobs <- entry
+	}
+	asyncSendsTotal.Add(float64(len(tasks)), "queued")
+	sendQueueDepth.Set(float64(len(q.jobs)))
+
+	return sends, nil
+}
+
+// Get returns the state of an async send
+func (q *SendQueue) Get(ctx context.Context, id uuid.UUID) (*models.AsyncSend, error) {
+	var send models.AsyncSend
+	found, err := q.cache.Get(ctx, CacheClassSends, id.String(), &send)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get send state: %w", err)
+	}
+	if !found {
+		return nil, ErrSendNotFound
+	}
+	return &send, nil
+}
+
+// Helper methods
+
+// workers runs loop on every worker and waits for them to return
+func (q *SendQueue) workers(loop func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.config.SendWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop()
+		}()
+	}
+	wg.Wait()
+}
+
+// work performs queued sends until ctx is done
+func (q *SendQueue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case queued := <-q.jobs:
+			sendQueueDepth.Set(float64(len(q.jobs)))
+			q.run(queued)
+		}
+	}
+}
+
+// drain performs the sends still queued until the queue is empty or the drain timeout
+// runs out, then abandons the rest
+func (q *SendQueue) drain() {
+	deadline := time.Now().Add(q.config.SendQueueDrainTimeout)
+	q.workers(func() {
+		for time.Now().Before(deadline) {
+			select {
+			case queued := <-q.jobs:
+				sendQueueDepth.Set(float64(len(q.jobs)))
+				q.run(queued)
+			default:
+				return
+			}
+		}
+	})
+
+	// Nothing is submitted or taken anymore
+	abandoned := 0
+	for len(q.jobs) > 0 {
+		q.abandon(<-q.jobs)
+		abandoned++
+	}
+	sendQueueDepth.Set(0)
+
+	if abandoned > 0 {
+		q.logger.WithField("abandoned", abandoned).Warn("Send queue stopped with sends still queued; marked them failed")
+	}
+}
+
+// abandon fails a send whose job will not run and releases its claims, so the client
+// can send it again
+func (q *SendQueue) abandon(queued queuedSend) {
+	asyncSendsTotal.Inc("abandoned")
+
+	send := queued.send
+	send.Status = models.SendStatusFailed
+	send.Error = errSendAbandoned.Error()
+	send.UpdatedAt = time.Now()
+	if err := q.save(context.Background(), send); err != nil {
+		q.logger.WithError(err).WithField("send_id", send.ID).Warn("Failed to record send state")
+	}
+
+	if queued.release != nil {
+		queued.release()
+	}
+}
+
+// run performs one send and records its outcome
+func (q *SendQueue) run(queued queuedSend) {
+	ctx := context.Background()
+	send := queued.send
+
+	send.Status = models.SendStatusSending
+	send.UpdatedAt = time.Now()
+	if err := q.save(ctx, send); err != nil {
+		q.logger.WithError(err).WithField("send_id", send.ID).Warn("Failed to record send state")
+	}
+
+	response, err := runSendJob(ctx, queued.job)
+	var panicked *sendPanic
+	if errors.As(err, &panicked) {
+		// The job did not get to release its claims
+		q.logger.WithField("send_id", send.ID).WithField("stack", panicked.stack).Error("Async send panicked")
+		if queued.release != nil {
+			queued.release()
+		}
+	}
+	send.UpdatedAt = time.Now()
+	if err != nil {
+		asyncSendsTotal.Inc("failed")
+		q.logger.WithError(err).WithField("send_id", send.ID).Error("Async send failed")
+		send.Status = models.SendStatusFailed
+		send.Error = err.Error()
+	} else {
+		asyncSendsTotal.Inc("sent")
+		send.Status = models.SendStatusSent
+		send.MessageID = &response.ID
+		send.TwilioSID = response.TwilioSID
+	}
+
+	if err := q.save(ctx, send); err != nil {
+		q.logger.WithError(err).WithField("send_id", send.ID).Warn("Failed to record send state")
+	}
+}
+
+// sendPanic is the error of a job that panicked
+type sendPanic struct {
+	value interface{}
+	stack string
+}
+
+func (p *sendPanic) Error() string {
+	return fmt.Sprintf("send panicked: %v", p.value)
+}
+
+// runSendJob runs a job, turning a panic into a *sendPanic error so one bad send fails
+// on its own instead of taking the process and every queued send down
+func runSendJob(ctx context.Context, job SendJob) (response *models.SendMessageResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			response, err = nil, &sendPanic{value: r, stack: string(debug.Stack())}
+		}
+	}()
+	return job(ctx)
+}
+
+// save stores the state of a send
+func (q *SendQueue) save(ctx context.Context, send *models.AsyncSend) error {
+	if err := q.cache.Set(ctx, CacheClassSends, send.ID.String(), send); err != nil {
+		return fmt.Errorf("failed to store send state: %w", err)
+	}
+	return nil
+}
+
+// forget removes the states of sends that were not queued
+func (q *SendQueue) forget(ctx context.Context, queued []queuedSend) {
+	ids := make([]string, len(queued))
+	for i, entry := range queued {
+		ids[i] = entry.send.ID.String()
+	}
+	if err := q.cache.Delete(ctx, CacheClassSends, ids...); err != nil {
+		q.logger.WithError(err).Warn("Failed to remove send states")
+	}
+}
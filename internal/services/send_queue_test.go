@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// queueTestTask counts how often its job ran and its claims were released
+type queueTestTask struct {
+	id       uuid.UUID
+	ran      atomic.Int32
+	released atomic.Int32
+}
+
+func (task *queueTestTask) sendTask(wait <-chan struct{}) SendTask {
+	return SendTask{
+		ID: task.id,
+		Job: func(ctx context.Context) (*models.SendMessageResponse, error) {
+			task.ran.Add(1)
+			if wait != nil {
+				<-wait
+			}
+			return &models.SendMessageResponse{ID: uuid.New(), TwilioSID: "SM" + task.id.String()}, nil
+		},
+		Release: func() { task.released.Add(1) },
+	}
+}
+
+// stopWithQueuedSends starts a one-worker queue, keeps the worker busy while more sends
+// are queued, then shuts the queue down and waits for it
+func stopWithQueuedSends(t *testing.T, drainTimeout time.Duration, queued int) (*SendQueue, *queueTestTask, []*queueTestTask) {
+	t.Helper()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cache, _ := newTestRedisCache(t)
+	queue := NewSendQueue(cache, &config.Config{SendWorkers: 1, SendQueueSize: 10, SendQueueDrainTimeout: drainTimeout}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.Start(ctx)
+	}()
+
+	// The first send holds the only worker until the queue is stopped
+	unblock := make(chan struct{})
+	busy := &queueTestTask{id: uuid.New()}
+	if _, err := queue.Submit(ctx, busy.sendTask(unblock)); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	for busy.ran.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tasks := make([]*queueTestTask, queued)
+	for i := range tasks {
+		tasks[i] = &queueTestTask{id: uuid.New()}
+		if _, err := queue.Submit(ctx, tasks[i].sendTask(nil)); err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+
+	cancel()
+	close(unblock)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send queue did not stop")
+	}
+	return queue, busy, tasks
+}
+
+func TestSendQueueDrainsOnShutdown(t *testing.T) {
+	queue, busy, tasks := stopWithQueuedSends(t, 5*time.Second, 3)
+
+	for _, task := range append(tasks, busy) {
+		if task.ran.Load() != 1 || task.released.Load() != 0 {
+			t.Errorf("send %s ran %d times and was released %d times, want performed once", task.id, task.ran.Load(), task.released.Load())
+		}
+		send, err := queue.Get(context.Background(), task.id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if send.Status != models.SendStatusSent {
+			t.Errorf("send %s status = %q, want %q", task.id, send.Status, models.SendStatusSent)
+		}
+	}
+}
+
+func TestSendQueueAbandonsAfterDrainTimeout(t *testing.T) {
+	queue, busy, tasks := stopWithQueuedSends(t, 0, 3)
+
+	if busy.ran.Load() != 1 || busy.released.Load() != 0 {
+		t.Errorf("running send ran %d times and was released %d times, want finished", busy.ran.Load(), busy.released.Load())
+	}
+	for _, task := range tasks {
+		if task.ran.Load() != 0 || task.released.Load() != 1 {
+			t.Errorf("send %s ran %d times and was released %d times, want abandoned and released once", task.id, task.ran.Load(), task.released.Load())
+		}
+		send, err := queue.Get(context.Background(), task.id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if send.Status != models.SendStatusFailed || send.Error != errSendAbandoned.Error() {
+			t.Errorf("send %s = %q (%q), want failed as abandoned", task.id, send.Status, send.Error)
+		}
+	}
+	if len(queue.jobs) != 0 {
+		t.Errorf("%d sends left in the queue", len(queue.jobs))
+	}
+}
+
+func TestSendQueueRejectsAfterStop(t *testing.T) {
+	queue, _, _ := stopWithQueuedSends(t, time.Second, 0)
+
+	task := &queueTestTask{id: uuid.New()}
+	_, err := queue.Submit(context.Background(), task.sendTask(nil))
+	if !errors.Is(err, ErrSendQueueStopped) {
+		t.Fatalf("Submit() error = %v, want %v", err, ErrSendQueueStopped)
+	}
+	if _, err := queue.Get(context.Background(), task.id); !errors.Is(err, ErrSendNotFound) {
+		t.Errorf("Get() error = %v, want no state stored for a rejected send", err)
+	}
+}
+
+func TestSendQueueFull(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cache, _ := newTestRedisCache(t)
+	queue := NewSendQueue(cache, &config.Config{SendWorkers: 1, SendQueueSize: 2}, logger)
+
+	first, second, third := &queueTestTask{id: uuid.New()}, &queueTestTask{id: uuid.New()}, &queueTestTask{id: uuid.New()}
+	_, err := queue.SubmitAll(context.Background(), first.sendTask(nil), second.sendTask(nil), third.sendTask(nil))
+	if !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("SubmitAll() error = %v, want %v", err, ErrSendQueueFull)
+	}
+	if len(queue.jobs) != 0 {
+		t.Errorf("queued %d of a batch that does not fit", len(queue.jobs))
+	}
+
+	sends, err := queue.SubmitAll(context.Background(), first.sendTask(nil), second.sendTask(nil))
+	if err != nil {
+		t.Fatalf("SubmitAll() error = %v", err)
+	}
+	for _, send := range sends {
+		if send.Status != models.SendStatusQueued {
+			t.Errorf("send %s status = %q, want %q", send.ID, send.Status, models.SendStatusQueued)
+		}
+	}
+}
+
+func TestSendQueueSurvivesPanickingSend(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cache, _ := newTestRedisCache(t)
+	queue := NewSendQueue(cache, &config.Config{SendWorkers: 1, SendQueueSize: 10, SendQueueDrainTimeout: 5 * time.Second}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.Start(ctx)
+	}()
+
+	bad := &queueTestTask{id: uuid.New()}
+	good := &queueTestTask{id: uuid.New()}
+	_, err := queue.SubmitAll(ctx,
+		SendTask{
+			ID: bad.id,
+			Job: func(ctx context.Context) (*models.SendMessageResponse, error) {
+				bad.ran.Add(1)
+				var media *string
+				return &models.SendMessageResponse{TwilioSID: *media}, nil
+			},
+			Release: func() { bad.released.Add(1) },
+		},
+		good.sendTask(nil),
+	)
+	if err != nil {
+		t.Fatalf("SubmitAll() error = %v", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send queue did not stop")
+	}
+
+	tests := []struct {
+		name         string
+		task         *queueTestTask
+		wantStatus   string
+		wantReleased int32
+	}{
+		{"panicking send fails and is released", bad, models.SendStatusFailed, 1},
+		{"next send still performed", good, models.SendStatusSent, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send, err := queue.Get(context.Background(), tt.task.id)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if send.Status != tt.wantStatus {
+				t.Errorf("status = %q (%q), want %q", send.Status, send.Error, tt.wantStatus)
+			}
+			if got := tt.task.released.Load(); got != tt.wantReleased {
+				t.Errorf("released %d times, want %d", got, tt.wantReleased)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Sentiment metrics
+var (
+	sentimentScoresTotal      = metrics.NewCounter("sentiment_scores_total", "Inbound messages run through the sentiment stage, by outcome", "outcome")
+	sentimentEscalationsTotal = metrics.NewCounter("sentiment_escalations_total", "Conversations escalated after a streak of negative messages", "channel")
+)
+
+// SentimentProvider scores the sentiment of a text from -1 (negative) to 1 (positive)
+type SentimentProvider interface {
+	Score(ctx context.Context, text string) (float64, error)
+}
+
+// LexiconSentiment scores text locally against a word list in Portuguese and English.
+// Accents are ignored, and a negation flips the words that follow it.
+type LexiconSentiment struct{}
+
+// NewLexiconSentiment creates a lexicon sentiment provider
+func NewLexiconSentiment() *LexiconSentiment {
+	return &LexiconSentiment{}
+}
+
+// Score sums the weights of the words in text and squashes the sum into [-1, 1]
+func (l *LexiconSentiment) Score(ctx context.Context, text string) (float64, error) {
+	words := strings.FieldsFunc(foldAccents(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	sum := 0.0
+	negated := 0
+	for _, word := range words {
+		if sentimentNegations[word] {
+			negated = 3
+			continue
+		}
+		weight, ok := sentimentLexicon[word]
+		if negated > 0 {
+			negated--
+			weight = -weight
+		}
+		if ok {
+			sum += weight
+		}
+	}
+
+	// The normalization VADER uses: single words stay moderate, piles approach ±1
+	return sum / math.Sqrt(sum*sum+15), nil
+}
+
+// HTTPSentiment asks a sentiment API to score text
+type HTTPSentiment struct {
+	url        string
+	token      string
+	locale     string
+	httpClient *http.Client
+}
+
+// NewHTTPSentiment creates a sentiment provider calling SENTIMENT_URL
+func NewHTTPSentiment(cfg *config.Config) *HTTPSentiment {
+	return &HTTPSentiment{
+		url:        cfg.SentimentURL,
+		token:      cfg.SentimentAPIToken,
+		locale:     cfg.DefaultLocale,
+		httpClient: newHTTPClient(cfg.SentimentTimeout),
+	}
+}
+
+// Score posts {"text", "locale"} and expects {"score"} back
+func (h *HTTPSentiment) Score(ctx context.Context, text string) (float64, error) {
+	body, err := json.Marshal(map[string]string{"text": text, "locale": h.locale})
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal sentiment request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create sentiment request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call sentiment API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("sentiment API returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Errorf("failed to decode sentiment response: %w", err)
+	}
+	if response.Score == nil {
+		return 0, fmt.Errorf("sentiment response has no score")
+	}
+	return math.Max(-1, math.Min(1, *response.Score)), nil
+}
+
+// SentimentService scores inbound text and escalates conversations that keep going
+// badly: once SENTIMENT_ESCALATION_STREAK consecutive scored messages of a session are at
+// or below SENTIMENT_NEGATIVE_THRESHOLD, the session is tagged and a session.escalated
+// event is published, which reaches the event webhook. A tagged session is not escalated
+// again. Scores are stored on the message and sent to the orchestrator with it.
+type SentimentService struct {
+	provider       SentimentProvider
+	db             *pgxpool.Pool
+	sessionService *SessionService
+	eventService   *EventService
+	config         *config.Config
+	logger         *logrus.Logger
+}
+
+// NewSentimentService creates a new sentiment service on a provider
+func NewSentimentService(provider SentimentProvider, db *pgxpool.Pool, sessionService *SessionService, eventService *EventService, cfg *config.Config, logger *logrus.Logger) *SentimentService {
+	return &SentimentService{
+		provider:       provider,
+		db:             db,
+		sessionService: sessionService,
+		eventService:   eventService,
+		config:         cfg,
+		logger:         logger,
+	}
+}
+
+// Score sets the sentiment of an inbound text message or caption. Messages without
+// words, and messages the provider fails on, are left unscored.
+func (s *SentimentService) Score(ctx context.Context, message *models.WhatsAppMessage) {
+	if !normalizedTypes[message.Type] || countTextChars(message.Content) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.config.SentimentTimeout)
+	defer cancel()
+
+	score, err := s.provider.Score(ctx, message.Content)
+	if err != nil {
+		sentimentScoresTotal.Inc("failed")
+		s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to score message sentiment")
+		return
+	}
+
+	sentimentScoresTotal.Inc("scored")
+	message.Sentiment = &score
+}
+
+// Escalate checks whether a stored negative message completes a streak and escalates
+// its session
+func (s *SentimentService) Escalate(ctx context.Context, session *models.ChatSession, message *models.WhatsAppMessage) {
+	streak := s.config.SentimentEscalationStreak
+	if streak <= 0 || session == nil || message.Sentiment == nil || !s.negative(*message.Sentiment) {
+		return
+	}
+	tag := s.config.SentimentEscalationTag
+	for _, existing := range session.Tags {
+		if existing == tag {
+			return
+		}
+	}
+
+	scores, err := s.recentScores(ctx, session.ID, streak)
+	if err != nil {
+		s.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to load recent sentiment")
+		return
+	}
+	if len(scores) < streak {
+		return
+	}
+	for _, score := range scores {
+		if !s.negative(score) {
+			return
+		}
+	}
+
+	// Only the message that adds the tag escalates, should two complete the streak at once
+	added, err := s.sessionService.AddTagOnce(ctx, session.ID, tag)
+	if err != nil {
+		s.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to tag escalated session")
+		return
+	}
+	if !added {
+		return
+	}
+	session.Tags = append(session.Tags, tag)
+	sentimentEscalationsTotal.Inc(string(message.Channel))
+
+	messageID := message.ID
+	sessionID := session.ID
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      models.EventSessionEscalated,
+		MessageID: &messageID,
+		SessionID: &sessionID,
+		Data: map[string]interface{}{
+			"reason":  "negative_sentiment",
+			"tag":     tag,
+			"scores":  scores,
+			"channel": message.Channel,
+			"user_id": message.UserID,
+		},
+	})
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"scores":     scores,
+	}).Info("Conversation escalated after negative messages")
+}
+
+// Helper methods
+
+// negative reports whether a score is at or below the negative threshold
+func (s *SentimentService) negative(score float64) bool {
+	return score <= s.config.SentimentNegativeThreshold
+}
+
+// recentScores returns the sentiment of the latest scored inbound messages of a session,
+// newest first
+func (s *SentimentService) recentScores(ctx context.Context, sessionID uuid.UUID, limit int) ([]float64, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT sentiment FROM whatsapp_messages
+		WHERE session_id = $1 AND direction = 'inbound' AND sentiment IS NOT NULL
+		ORDER BY timestamp DESC
+		LIMIT $2`, sessionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sentiment: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []float64
+	for rows.Next() {
+		var score float64
+		if err := rows.Scan(&score); err != nil {
+			return nil, fmt.Errorf("failed to scan sentiment: %w", err)
+		}
+		scores = append(scores, score)
+	}
+	return scores, rows.Err()
+}
+
+// foldAccents drops diacritics, since users often type "pessimo" for "péssimo"
+func foldAccents(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// sentimentNegations flip the weight of the next few words
+var sentimentNegations = map[string]bool{
+	"nao": true, "nunca": true, "nem": true, "jamais": true,
+	"not": true, "no": true, "never": true, "dont": true, "isnt": true, "wasnt": true,
+}
+
+// sentimentLexicon weighs words without accents, in Portuguese and English
+var sentimentLexicon = map[string]float64{
+	// Portuguese
+	"obrigado": 2, "obrigada": 2, "otimo": 3, "otima": 3, "excelente": 3, "perfeito": 3,
+	"perfeita": 3, "bom": 2, "boa": 2, "gostei": 2, "adorei": 3, "amei": 3, "legal": 2,
+	"maravilhoso": 3, "maravilhosa": 3, "rapido": 1, "resolvido": 2, "top": 2, "show": 2,
+	"ruim": -2, "pessimo": -3, "pessima": -3, "horrivel": -3, "absurdo": -3, "absurda": -3,
+	"demora": -2, "demorando": -2, "atraso": -2, "atrasado": -2, "problema": -1,
+	"reclamacao": -2, "reclamar": -2, "cancelar": -2, "cancelamento": -2, "raiva": -3,
+	"irritado": -3, "irritada": -3, "decepcionado": -3, "decepcionada": -3, "lixo": -3,
+	"golpe": -3, "fraude": -3, "enganado": -3, "enganada": -3, "vergonha": -2,
+	"desrespeito": -3, "procon": -3, "processo": -1, "odeio": -3, "chateado": -2,
+	"chateada": -2, "insatisfeito": -3, "insatisfeita": -3, "descaso": -3,
+
+	// English
+	"thanks": 2, "thank": 2, "great": 3, "excellent": 3, "perfect": 3, "good": 2,
+	"love": 3, "nice": 2, "awesome": 3, "helpful": 2, "solved": 2,
+	"bad": -2, "terrible": -3, "awful": -3, "horrible": -3, "worst": -3, "slow": -2,
+	"late": -2, "delay": -2, "complaint": -2, "cancel": -2, "angry": -3, "annoyed": -2,
+	"disappointed": -3, "scam": -3, "fraud": -3, "useless": -3, "hate": -3, "ridiculous": -3,
+	"unacceptable": -3, "lawyer": -2, "refund": -1,
+}
@@ -0,0 +1,542 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrSessionNotFound is returned when a chat session does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionColumns lists the chat_sessions columns in the order scanSession expects
+const sessionColumns = `
+	id, user_id, status, COALESCE(context, '{}'::jsonb), started_at, ended_at,
+	last_activity_at, close_reason, summary, summarized_at, state, state_updated_at,
+	tags, metadata, created_at, updated_at, muted_until, mute_reason,
+	assigned_agent_id, assigned_at`
+
+// SessionService manages chat session lifecycle for WhatsApp users
+type SessionService struct {
+	db              *pgxpool.Pool
+	messageService  *MessageService
+	aiService       *AIService
+	identityService *IdentityService
+	crmExport       *CRMExportService
+	eventService    *EventService
+	agentService    *AgentService
+	csat            *CSATService
+	store           *SessionStore
+	config          *config.Config
+	logger          *logrus.Logger
+
+	closing sync.WaitGroup // post-close processing still running
+}
+
+// NewSessionService creates a new session service instance
+func NewSessionService(
+	db *pgxpool.Pool,
+	messageService *MessageService,
+	aiService *AIService,
+	identityService *IdentityService,
+	store *SessionStore,
+	cfg *config.Config,
+	logger *logrus.Logger,
+) *SessionService {
+	return &SessionService{
+		db:              db,
+		messageService:  messageService,
+		aiService:       aiService,
+		identityService: identityService,
+		store:           store,
+		config:          cfg,
+		logger:          logger,
+	}
+}
+
+// ResolveSession finds or creates the user and active session for an inbound sender on
+// a channel addressed by phone number
+func (s *SessionService) ResolveSession(ctx context.Context, channel models.Channel, phoneNumber, profileName, waID string) (*models.User, *models.ChatSession, error) {
+	phone := normalizePhoneNumber(phoneNumber)
+	if user, session, ok := s.resolveStored(ctx, channel, models.IdentityKindPhone, phone, profileName, waID); ok {
+		return user, session, nil
+	}
+
+	user, err := s.identityService.Resolve(ctx, phoneNumber, profileName, waID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.activeSession(ctx, user, channel, models.IdentityKindPhone, phone, waID)
+}
+
+// ResolveChannelSession finds or creates the user and active session for a sender on a
+// channel that identifies senders by a channel-scoped ID (Meta, Telegram)
+func (s *SessionService) ResolveChannelSession(ctx context.Context, channel models.Channel, senderID, profileName string) (*models.User, *models.ChatSession, error) {
+	kind, ok := models.IdentityKindForChannel(channel)
+	if !ok {
+		return nil, nil, fmt.Errorf("channel %q has no sender identity", channel)
+	}
+	if user, session, ok := s.resolveStored(ctx, channel, kind, senderID, profileName, ""); ok {
+		return user, session, nil
+	}
+
+	user, err := s.identityService.ResolveChannelUser(ctx, kind, senderID, profileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.activeSession(ctx, user, channel, kind, senderID, "")
+}
+
+// TouchActiveSession refreshes the activity timestamp of a recipient's active session,
+// returning nil when the user has no active session. Recipients are phone numbers on
+// WhatsApp and SMS and channel-scoped IDs elsewhere.
+func (s *SessionService) TouchActiveSession(ctx context.Context, channel models.Channel, recipient string) (*models.ChatSession, error) {
+	kind, value := recipientAddress(channel, recipient)
+	address, known := s.store.address(ctx, kind, value)
+	if known {
+		if session, ok := s.touchStored(ctx, address.UserID, ""); ok {
+			sessionStoreLookupsTotal.Inc("outbound", "hit")
+			return session, nil
+		}
+	}
+	sessionStoreLookupsTotal.Inc("outbound", "miss")
+
+	userID, err := s.recipientUserID(ctx, channel, recipient)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query := `
+		UPDATE chat_sessions
+		SET last_activity_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND status = 'active'
+		RETURNING` + sessionColumns
+
+	session, err := scanSession(s.db.QueryRow(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	if !known {
+		s.store.rememberAddress(ctx, kind, value, &sessionAddress{UserID: userID})
+	}
+	previous, _ := s.store.user(ctx, userID)
+	s.store.remember(ctx, previous, session, "")
+
+	return session, nil
+}
+
+// storedSession returns a session from the session store, or from Postgres when it is not stored
+func (s *SessionService) storedSession(ctx context.Context, sessionID uuid.UUID) (*models.ChatSession, error) {
+	if session, ok := s.store.session(ctx, sessionID); ok {
+		return session, nil
+	}
+	return s.GetSession(ctx, sessionID)
+}
+
+// GetSession retrieves a chat session by ID
+func (s *SessionService) GetSession(ctx context.Context, sessionID uuid.UUID) (*models.ChatSession, error) {
+	query := `SELECT` + sessionColumns + ` FROM chat_sessions WHERE id = $1`
+
+	session, err := scanSession(s.db.QueryRow(ctx, query, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve session: %w", err)
+	}
+
+	return session, nil
+}
+
+// ListSessions returns sessions whose metadata contains filter, newest first, optionally
+// of one status and only muted or unmuted ones
+func (s *SessionService) ListSessions(ctx context.Context, filter models.Metadata, status string, muted *bool, limit, offset int) ([]*models.ChatSession, int64, error) {
+	if filter == nil {
+		filter = models.Metadata{}
+	}
+
+	query := `SELECT` + sessionColumns + ` FROM chat_sessions
+		WHERE metadata @> $1 AND ($2 = '' OR status = $2)
+			AND ($5::boolean IS NULL OR (muted_until IS NOT NULL AND muted_until > NOW()) = $5)
+		ORDER BY started_at DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := s.db.Query(ctx, query, filter, status, limit, offset, muted)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*models.ChatSession{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading sessions: %w", err)
+	}
+
+	return sessions, estimateTotal(ctx, s.db, s.logger, query, filter, status, limit, offset, muted), nil
+}
+
+// CloseSession closes an active session and triggers post-close processing
+// in the background. Closing an already closed session is a no-op.
+func (s *SessionService) CloseSession(ctx context.Context, sessionID uuid.UUID, reason string) (*models.ChatSession, error) {
+	query := `
+		UPDATE chat_sessions
+		SET status = 'closed', ended_at = NOW(), close_reason = $2, updated_at = NOW()
+		WHERE id = $1 AND status = 'active'
+		RETURNING` + sessionColumns
+
+	session, err := scanSession(s.db.QueryRow(ctx, query, sessionID, reason))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Either unknown or already closed by another replica
+			return s.GetSession(ctx, sessionID)
+		}
+		s.logger.WithError(err).Error("Failed to close chat session")
+		return nil, fmt.Errorf("failed to close session: %w", err)
+	}
+
+	s.store.Forget(ctx, session.ID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"reason":     reason,
+	}).Info("Chat session closed")
+
+	s.closing.Add(1)
+	go func() {
+		defer s.closing.Done()
+		s.afterClose(session)
+	}()
+
+	return session, nil
+}
+
+// CloseIdleSessions closes every active session idle for longer than the configured timeout
+func (s *SessionService) CloseIdleSessions(ctx context.Context) (int, error) {
+	query := `
+		SELECT id FROM chat_sessions
+		WHERE status = 'active' AND last_activity_at < $1`
+
+	rows, err := s.db.Query(ctx, query, time.Now().Add(-s.config.SessionIdleTimeout))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query idle sessions: %w", err)
+	}
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan idle session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading idle sessions: %w", err)
+	}
+
+	closed := 0
+	for _, id := range ids {
+		if _, err := s.CloseSession(ctx, id, models.SessionCloseReasonTimeout); err != nil {
+			s.logger.WithError(err).WithField("session_id", id).Warn("Failed to close idle session")
+			continue
+		}
+		closed++
+	}
+
+	return closed, nil
+}
+
+// StartIdleSweeper periodically closes idle sessions until ctx is canceled
+func (s *SessionService) StartIdleSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.config.SessionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			closed, err := s.CloseIdleSessions(ctx)
+			if err != nil {
+				s.logger.WithError(err).Error("Idle session sweep failed")
+				continue
+			}
+			if closed > 0 {
+				s.logger.WithField("sessions_closed", closed).Info("Idle sessions closed")
+			}
+		}
+	}
+}
+
+// Drain waits for the post-close processing of closed sessions, so summaries, exports
+// and surveys are not lost on shutdown. It returns false if ctx ends first.
+func (s *SessionService) Drain(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		s.closing.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// UseCRMExport exports every session closed from now on to the CRM
+func (s *SessionService) UseCRMExport(crmExport *CRMExportService) {
+	s.crmExport = crmExport
+}
+
+// UseCSAT schedules a satisfaction survey for every session closed from now on
+func (s *SessionService) UseCSAT(csat *CSATService) {
+	s.csat = csat
+}
+
+// Helper methods
+
+// recipientUserID resolves the user behind an outbound recipient without creating one
+func (s *SessionService) recipientUserID(ctx context.Context, channel models.Channel, recipient string) (uuid.UUID, error) {
+	if kind, ok := models.IdentityKindForChannel(channel); ok {
+		return s.identityService.LookupChannelUserID(ctx, kind, recipient)
+	}
+	return s.identityService.LookupUserID(ctx, recipient)
+}
+
+// recipientAddress returns the identity kind and value a recipient is stored under
+func recipientAddress(channel models.Channel, recipient string) (string, string) {
+	if kind, ok := models.IdentityKindForChannel(channel); ok {
+		return kind, recipient
+	}
+	return models.IdentityKindPhone, normalizePhoneNumber(recipient)
+}
+
+// resolveStored resolves an inbound sender's user and active session from the session
+// store. Senders whose profile name or WhatsApp ID changed go through Postgres, which
+// records the change.
+func (s *SessionService) resolveStored(ctx context.Context, channel models.Channel, kind, value, profileName, waID string) (*models.User, *models.ChatSession, bool) {
+	address, ok := s.store.address(ctx, kind, value)
+	if !ok || address.User == nil ||
+		(profileName != "" && profileName != address.User.ProfileName) ||
+		(waID != "" && waID != address.WaID) {
+		sessionStoreLookupsTotal.Inc("inbound", "miss")
+		return nil, nil, false
+	}
+
+	session, ok := s.touchStored(ctx, address.UserID, channel)
+	if !ok {
+		sessionStoreLookupsTotal.Inc("inbound", "miss")
+		return nil, nil, false
+	}
+
+	sessionStoreLookupsTotal.Inc("inbound", "hit")
+	return address.User, session, true
+}
+
+// touchStored refreshes the activity of a user's stored active session. Sessions that
+// are no longer active in Postgres are forgotten and reported as misses.
+func (s *SessionService) touchStored(ctx context.Context, userID uuid.UUID, inbound models.Channel) (*models.ChatSession, bool) {
+	entry, session, ok := s.store.active(ctx, userID)
+	if !ok || session.Status != models.SessionStatusActive {
+		return nil, false
+	}
+
+	tag, err := s.db.Exec(ctx, touchSessionQuery, session.ID)
+	if err != nil {
+		s.logger.WithError(err).WithField("session_id", session.ID).Warn("Failed to touch stored session")
+		return nil, false
+	}
+	if tag.RowsAffected() == 0 {
+		s.store.Forget(ctx, session.ID)
+		return nil, false
+	}
+
+	s.store.touch(ctx, userID, entry, inbound)
+	session.LastActivityAt = entry.LastActivityAt
+	return session, true
+}
+
+// activeSession touches the user's active session, starting a new one when none is
+// active, and records both in the session store under the sender's address
+func (s *SessionService) activeSession(ctx context.Context, user *models.User, channel models.Channel, kind, value, waID string) (*models.User, *models.ChatSession, error) {
+	touch := `
+		UPDATE chat_sessions
+		SET last_activity_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND status = 'active'
+		RETURNING` + sessionColumns
+
+	session, err := scanSession(s.db.QueryRow(ctx, touch, user.ID))
+	if err == nil {
+		s.rememberResolved(ctx, user, session, channel, kind, value, waID)
+		return user, session, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		s.logger.WithError(err).Error("Failed to touch active session")
+		return nil, nil, fmt.Errorf("failed to load active session: %w", err)
+	}
+
+	// Concurrent webhooks of a new user race to start the session; the unique index on
+	// active sessions lets one insert win and the others touch its session
+	create := `
+		INSERT INTO chat_sessions (id, user_id, status, context, started_at, last_activity_at, created_at, updated_at)
+		VALUES ($1, $2, 'active', '{}'::jsonb, NOW(), NOW(), NOW(), NOW())
+		ON CONFLICT (user_id) WHERE status = 'active' DO NOTHING
+		RETURNING` + sessionColumns
+
+	session, err = scanSession(s.db.QueryRow(ctx, create, uuid.New(), user.ID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		session, err = scanSession(s.db.QueryRow(ctx, touch, user.ID))
+		if err == nil {
+			s.rememberResolved(ctx, user, session, channel, kind, value, waID)
+			return user, session, nil
+		}
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create chat session")
+		return nil, nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	session.New = true
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"user_id":    user.ID,
+	}).Info("Chat session started")
+
+	s.rememberResolved(ctx, user, session, channel, kind, value, waID)
+	return user, session, nil
+}
+
+// rememberResolved stores an inbound sender's address and active session after they
+// were resolved from Postgres
+func (s *SessionService) rememberResolved(ctx context.Context, user *models.User, session *models.ChatSession, channel models.Channel, kind, value, waID string) {
+	s.store.rememberAddress(ctx, kind, value, &sessionAddress{UserID: user.ID, User: user, WaID: waID})
+	previous, _ := s.store.user(ctx, user.ID)
+	s.store.remember(ctx, previous, session, channel)
+}
+
+// afterClose runs post-close processing for a session
+func (s *SessionService) afterClose(session *models.ChatSession) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if s.config.SessionSummaryEnabled {
+		if err := s.summarizeSession(ctx, session); err != nil {
+			s.logger.WithError(err).WithField("session_id", session.ID).Error("Failed to summarize session")
+		}
+	}
+
+	// The export runs after summarization so the record carries the summary
+	if s.crmExport != nil && s.crmExport.Enabled() {
+		exported, err := s.GetSession(ctx, session.ID)
+		if err != nil {
+			exported = session
+		}
+		if err := s.crmExport.Export(ctx, exported); err != nil {
+			s.logger.WithError(err).WithField("session_id", session.ID).Error("Failed to export session to CRM")
+		}
+	}
+
+	if s.csat != nil {
+		if err := s.csat.Schedule(ctx, session); err != nil {
+			s.logger.WithError(err).WithField("session_id", session.ID).Error("Failed to schedule satisfaction survey")
+		}
+	}
+}
+
+// summarizeSession sends the session transcript for summarization and stores the result
+func (s *SessionService) summarizeSession(ctx context.Context, session *models.ChatSession) error {
+	transcript, err := s.messageService.GetMessagesBySession(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(transcript) == 0 {
+		s.logger.WithField("session_id", session.ID).Debug("Session has no messages, skipping summary")
+		return nil
+	}
+
+	summary, err := s.aiService.SummarizeTranscript(ctx, session, transcript)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE chat_sessions
+		SET summary = $2, summarized_at = NOW(), updated_at = NOW()
+		WHERE id = $1`
+
+	if _, err := s.db.Exec(ctx, query, session.ID, summary); err != nil {
+		return fmt.Errorf("failed to store session summary: %w", err)
+	}
+	s.store.Forget(ctx, session.ID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id":  session.ID,
+		"summary_len": len(summary),
+	}).Info("Session summary stored")
+
+	return nil
+}
+
+// scanSession scans a chat_sessions row selected with sessionColumns
+func scanSession(row pgx.Row) (*models.ChatSession, error) {
+	var session models.ChatSession
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Status,
+		&session.Context,
+		&session.StartedAt,
+		&session.EndedAt,
+		&session.LastActivityAt,
+		&session.CloseReason,
+		&session.Summary,
+		&session.SummarizedAt,
+		&session.State,
+		&session.StateUpdatedAt,
+		&session.Tags,
+		&session.Metadata,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+		&session.MutedUntil,
+		&session.MuteReason,
+		&session.AssignedAgentID,
+		&session.AssignedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.Muted = session.MutedUntil != nil && session.MutedUntil.After(time.Now())
+	return &session, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrInvalidMute is returned for mute durations outside the allowed range
+var ErrInvalidMute = errors.New("invalid mute duration")
+
+var mutedMessagesTotal = metrics.NewCounter("muted_messages_total", "Inbound messages held back from the orchestrator in muted conversations", "channel")
+
+// UseEvents publishes session mute changes and messages received while muted
+func (s *SessionService) UseEvents(eventService *EventService) {
+	s.eventService = eventService
+}
+
+// Mute suppresses automated replies in a session for a duration, e.g. while an agent
+// handles the conversation offline. Muting again replaces the previous mute.
+func (s *SessionService) Mute(ctx context.Context, sessionID uuid.UUID, duration time.Duration, reason string) (*models.ChatSession, error) {
+	if duration <= 0 || duration > s.config.SessionMuteMaxDuration {
+		return nil, fmt.Errorf("%w: must be positive and at most %s", ErrInvalidMute, s.config.SessionMuteMaxDuration)
+	}
+
+	var muteReason *string
+	if reason != "" {
+		muteReason = &reason
+	}
+
+	query := `
+		UPDATE chat_sessions
+		SET muted_until = NOW() + $2 * INTERVAL '1 second', mute_reason = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING` + sessionColumns
+
+	session, err := scanSession(s.db.QueryRow(ctx, query, sessionID, duration.Seconds(), muteReason))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to mute session: %w", err)
+	}
+	s.store.Forget(ctx, session.ID)
+
+	s.logger.WithFields(logrus.Fields{
+		"session_id":  session.ID,
+		"muted_until": session.MutedUntil,
+	}).Info("Chat session muted")
+
+	s.publish(ctx, models.EventSessionMuted, session, nil, map[string]interface{}{
+		"muted_until": session.MutedUntil,
+		"reason":      reason,
+	})
+
+	return session, nil
+}
+
+// Unmute lifts a session's mute at once
+func (s *SessionService) Unmute(ctx context.Context, sessionID uuid.UUID) (*models.ChatSession, error) {
+	query := `
+		UPDATE chat_sessions
+		SET muted_until = NULL, mute_reason = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING` + sessionColumns
+
+	session, err := scanSession(s.db.QueryRow(ctx, query, sessionID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to unmute session: %w", err)
+	}
+	s.store.Forget(ctx, session.ID)
+
+	s.logger.WithField("session_id", session.ID).Info("Chat session unmuted")
+	s.publish(ctx, models.EventSessionUnmuted, session, nil, nil)
+
+	return session, nil
+}
+
+// IsMuted reports whether automated replies are suppressed for the session of a
+// message. Messages without a session are never muted.
+func (s *SessionService) IsMuted(ctx context.Context, message *models.WhatsAppMessage) (bool, error) {
+	if message.SessionID == nil {
+		return false, nil
+	}
+	if session, ok := s.store.session(ctx, *message.SessionID); ok {
+		return session.Muted, nil
+	}
+
+	var muted bool
+	err := s.db.QueryRow(ctx, `
+		SELECT COALESCE(muted_until > NOW(), false) FROM chat_sessions WHERE id = $1`,
+		*message.SessionID,
+	).Scan(&muted)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check session mute: %w", err)
+	}
+	return muted, nil
+}
+
+// HoldMuted records an inbound message that is not forwarded because its session is
+// muted and publishes a message.muted event so agents can follow the conversation
+func (s *SessionService) HoldMuted(ctx context.Context, session *models.ChatSession, message *models.WhatsAppMessage) {
+	mutedMessagesTotal.Inc(string(message.Channel))
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"session_id": session.ID,
+	}).Info("Inbound message held back in muted conversation")
+
+	s.publish(ctx, models.EventMessageMuted, session, &message.ID, map[string]interface{}{
+		"from":        message.From,
+		"channel":     message.Channel,
+		"type":        message.Type,
+		"content":     message.Content,
+		"muted_until": session.MutedUntil,
+	})
+}
+
+// publish sends a session event when events are enabled
+func (s *SessionService) publish(ctx context.Context, eventType string, session *models.ChatSession, messageID *uuid.UUID, data map[string]interface{}) {
+	if s.eventService == nil {
+		return
+	}
+	s.eventService.Publish(ctx, &models.Event{
+		Type:      eventType,
+		MessageID: messageID,
+		SessionID: &session.ID,
+		Data:      data,
+	})
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// sessionStoreLookupsTotal counts hot path session lookups answered from Redis or Postgres
+var sessionStoreLookupsTotal = metrics.NewCounter("session_store_lookups_total", "Session lookups on the message path, by path and outcome (hit, or miss when Postgres answered)", "path", "outcome")
+
+// touchSessionQuery refreshes the activity of a session the store resolved. It doubles
+// as the check that the session is still active, e.g. not closed by a merge.
+const touchSessionQuery = `
+	UPDATE chat_sessions
+	SET last_activity_at = NOW(), updated_at = NOW()
+	WHERE id = $1 AND status = 'active'`
+
+// sessionAddress maps a sender address to its canonical user. User and WaID are only
+// known for addresses seen inbound; outbound lookups record the user ID alone.
+type sessionAddress struct {
+	UserID uuid.UUID    `json:"user_id"`
+	User   *models.User `json:"user,omitempty"`
+	WaID   string       `json:"wa_id,omitempty"`
+}
+
+// userSession is a user's active session, when it was last active, and the user's last
+// inbound message on each channel, which opens the customer service window
+type userSession struct {
+	SessionID      uuid.UUID                    `json:"session_id"`
+	LastActivityAt time.Time                    `json:"last_activity_at"`
+	LastInbound    map[models.Channel]time.Time `json:"last_inbound,omitempty"`
+}
+
+// SessionStore keeps what the message path needs to know about a conversation in
+// Redis: the user behind an address, the user's active session and window, and a
+// snapshot of the session. Postgres remains the source of truth and every entry can be
+// rebuilt from it, so a miss or a Redis failure only costs the queries the lookup made
+// before. Entries expire with the sessions cache class; writers of sessions call Forget.
+type SessionStore struct {
+	cache  *RedisCache
+	logger *logrus.Logger
+}
+
+// NewSessionStore creates a session store on the Redis cache
+func NewSessionStore(cache *RedisCache, logger *logrus.Logger) *SessionStore {
+	return &SessionStore{
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// Forget drops the snapshot of a session after it changed in Postgres
+func (s *SessionStore) Forget(ctx context.Context, sessionID uuid.UUID) {
+	if err := s.cache.Delete(ctx, CacheClassSessions, "session:"+sessionID.String()); err != nil {
+		s.logger.WithError(err).WithField("session_id", sessionID).Warn("Failed to forget cached session")
+	}
+}
+
+// Helper methods
+
+// address returns the cached user of an address
+func (s *SessionStore) address(ctx context.Context, kind, value string) (*sessionAddress, bool) {
+	var address sessionAddress
+	if !s.get(ctx, "address:"+kind+":"+value, &address) {
+		return nil, false
+	}
+	return &address, true
+}
+
+// rememberAddress records the user of an address
+func (s *SessionStore) rememberAddress(ctx context.Context, kind, value string, address *sessionAddress) {
+	s.set(ctx, "address:"+kind+":"+value, address)
+}
+
+// user returns a user's active session entry
+func (s *SessionStore) user(ctx context.Context, userID uuid.UUID) (*userSession, bool) {
+	var entry userSession
+	if !s.get(ctx, "user:"+userID.String(), &entry) {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// active returns a user's active session entry and the session, whose last activity is
+// taken from the entry since hot path touches do not rewrite the snapshot
+func (s *SessionStore) active(ctx context.Context, userID uuid.UUID) (*userSession, *models.ChatSession, bool) {
+	entry, ok := s.user(ctx, userID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var session models.ChatSession
+	if !s.get(ctx, "session:"+entry.SessionID.String(), &session) || session.UserID != userID {
+		return entry, nil, false
+	}
+	session.LastActivityAt = entry.LastActivityAt
+	session.Muted = session.MutedUntil != nil && session.MutedUntil.After(time.Now())
+	return entry, &session, true
+}
+
+// session returns the snapshot of a session, with its last activity when the session
+// is still its user's active one
+func (s *SessionStore) session(ctx context.Context, sessionID uuid.UUID) (*models.ChatSession, bool) {
+	var session models.ChatSession
+	if !s.get(ctx, "session:"+sessionID.String(), &session) {
+		return nil, false
+	}
+
+	if entry, ok := s.user(ctx, session.UserID); ok && entry.SessionID == sessionID {
+		session.LastActivityAt = entry.LastActivityAt
+	}
+	session.Muted = session.MutedUntil != nil && session.MutedUntil.After(time.Now())
+	return &session, true
+}
+
+// remember records a session read from Postgres as its user's active session, keeping
+// the windows known from earlier entries. inbound is the channel of an inbound message
+// that touched the session, or "" for outbound touches.
+func (s *SessionStore) remember(ctx context.Context, previous *userSession, session *models.ChatSession, inbound models.Channel) {
+	entry := &userSession{SessionID: session.ID, LastActivityAt: session.LastActivityAt}
+	if previous != nil {
+		entry.LastInbound = previous.LastInbound
+	}
+
+	s.set(ctx, "session:"+session.ID.String(), session)
+	s.touch(ctx, session.UserID, entry, inbound)
+}
+
+// touch records activity on a user's active session
+func (s *SessionStore) touch(ctx context.Context, userID uuid.UUID, entry *userSession, inbound models.Channel) {
+	now := time.Now()
+	entry.LastActivityAt = now
+	if inbound != "" {
+		if entry.LastInbound == nil {
+			entry.LastInbound = make(map[models.Channel]time.Time)
+		}
+		entry.LastInbound[inbound] = now
+	}
+	s.set(ctx, "user:"+userID.String(), entry)
+}
+
+// get loads an entry, treating Redis failures as misses
+func (s *SessionStore) get(ctx context.Context, id string, dest interface{}) bool {
+	found, err := s.cache.Get(ctx, CacheClassSessions, id, dest)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read session store")
+		return false
+	}
+	return found
+}
+
+// set stores an entry; a failed write only costs a later miss
+func (s *SessionStore) set(ctx context.Context, id string, value interface{}) {
+	if err := s.cache.Set(ctx, CacheClassSessions, id, value); err != nil {
+		s.logger.WithError(err).Warn("Failed to write session store")
+	}
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionServiceDrain(t *testing.T) {
+	s := &SessionService{}
+	if !s.Drain(context.Background()) {
+		t.Fatal("Drain() with nothing running = false, want true")
+	}
+
+	s.closing.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if s.Drain(ctx) {
+		t.Fatal("Drain() while post-close processing runs = true, want false")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.closing.Done()
+	}()
+	if !s.Drain(context.Background()) {
+		t.Error("Drain() after post-close processing finished = false, want true")
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/signing"
+)
+
+// shadowRequestsTotal counts mirrored requests by target and outcome
+var shadowRequestsTotal = metrics.NewCounter("shadow_requests_total", "Requests mirrored to the shadow deployment by target and outcome", "target", "outcome")
+
+// Shadow sampling modes
+const (
+	ShadowSampleBySender  = "sender"
+	ShadowSampleByRequest = "request"
+)
+
+// Shadow targets
+const (
+	shadowTargetWebhook      = "webhook"
+	shadowTargetOrchestrator = "orchestrator"
+)
+
+// ShadowHeader marks mirrored requests, so the shadow deployment can tell them apart
+const ShadowHeader = "X-Shadow-Traffic"
+
+// shadowSkippedHeaders are not copied onto mirrored webhooks
+var shadowSkippedHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// ShadowService mirrors a sample of production traffic to a second deployment, such as
+// a new adapter version behind a blue/green switch or a new orchestrator, so it can be
+// tested on real messages. Mirroring is fire-and-forget: copies are sent in the
+// background, their responses are ignored and they are dropped when too many are in
+// flight, so the shadow never affects replies.
+type ShadowService struct {
+	config             *config.Config
+	httpClient         *http.Client
+	orchestratorSigner *signing.Signer
+	slots              chan struct{}
+	logger             *logrus.Logger
+}
+
+// NewShadowService creates a new shadow traffic service
+func NewShadowService(cfg *config.Config, logger *logrus.Logger) *ShadowService {
+	concurrency := cfg.ShadowMaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	return &ShadowService{
+		config:             cfg,
+		httpClient:         newHTTPClient(cfg.ShadowTimeout),
+		orchestratorSigner: signing.NewSigner(cfg.SigningKeyID, cfg.OrchestratorSigningSecret),
+		slots:              make(chan struct{}, concurrency),
+		logger:             logger,
+	}
+}
+
+// MirrorWebhook sends a copy of a webhook request production accepted to the shadow
+// adapter, under the same path and with the same headers
+func (s *ShadowService) MirrorWebhook(source string, r *http.Request, body []byte) {
+	if s.config.ShadowWebhookURL == "" || !s.sampled(webhookSender(source, r, body)) {
+		return
+	}
+
+	header := make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		if !shadowSkippedHeaders[name] {
+			header[name] = append([]string(nil), values...)
+		}
+	}
+	header.Set(ShadowHeader, "true")
+	if r.TLS != nil {
+		header.Set("X-Forwarded-Proto", "https")
+	}
+
+	// The original host keeps provider signatures, which cover the URL, valid
+	s.send(&shadowRequest{
+		target:   shadowTargetWebhook,
+		method:   r.Method,
+		endpoint: strings.TrimRight(s.config.ShadowWebhookURL, "/") + r.URL.RequestURI(),
+		host:     r.Host,
+		header:   header,
+		body:     body,
+	})
+}
+
+// MirrorOrchestrator sends a copy of an orchestrator forward to the shadow orchestrator
+func (s *ShadowService) MirrorOrchestrator(message *models.WhatsAppMessage, payload []byte) {
+	if s.config.ShadowOrchestratorURL == "" || !s.sampled(message.From) {
+		return
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+	header.Set("Idempotency-Key", message.ID.String())
+	header.Set(ShadowHeader, "true")
+
+	s.send(&shadowRequest{
+		target:   shadowTargetOrchestrator,
+		method:   http.MethodPost,
+		endpoint: strings.TrimRight(s.config.ShadowOrchestratorURL, "/") + "/api/v1/chat/process",
+		header:   header,
+		body:     payload,
+		signer:   s.orchestratorSigner,
+	})
+}
+
+// shadowRequest is one mirrored request
+type shadowRequest struct {
+	target   string
+	method   string
+	endpoint string
+	host     string // overrides the endpoint's host header when set
+	header   http.Header
+	body     []byte
+	signer   *signing.Signer
+}
+
+// send posts a copy in the background, unless every slot is taken
+func (s *ShadowService) send(request *shadowRequest) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		shadowRequestsTotal.Inc(request.target, "dropped")
+		return
+	}
+
+	go func() {
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.ShadowTimeout)
+		defer cancel()
+
+		if err := s.do(ctx, request); err != nil {
+			shadowRequestsTotal.Inc(request.target, "failed")
+			s.logger.WithError(err).WithField("target", request.target).Debug("Shadow request failed")
+			return
+		}
+		shadowRequestsTotal.Inc(request.target, "sent")
+	}()
+}
+
+// do sends one mirrored request and discards the response
+func (s *ShadowService) do(ctx context.Context, request *shadowRequest) error {
+	req, err := http.NewRequestWithContext(ctx, request.method, request.endpoint, bytes.NewReader(request.body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = request.header
+	if request.host != "" {
+		req.Host = request.host
+	}
+	if err := request.signer.Sign(req, request.body); err != nil {
+		return fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("shadow returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sampled decides whether traffic is mirrored. In sender mode the decision is a hash of
+// the sender, so a sampled conversation is mirrored completely; traffic without a known
+// sender is sampled per request.
+func (s *ShadowService) sampled(sender string) bool {
+	rate := s.config.ShadowSampleRate
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+
+	if s.config.ShadowSampleBy == ShadowSampleBySender && sender != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(normalizePhoneNumber(sender)))
+		return float64(hash.Sum32()%10000) < rate*10000
+	}
+	return rand.Float64() < rate
+}
+
+// webhookSender returns the user number of a Twilio webhook: the sender of inbound
+// messages, the recipient of status callbacks. It is "" for other sources.
+func webhookSender(source string, r *http.Request, body []byte) string {
+	if source != "twilio" || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return ""
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return ""
+	}
+	if values.Get("MessageStatus") != "" {
+		return values.Get("To")
+	}
+	return values.Get("From")
+}
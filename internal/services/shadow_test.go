@@ -0,0 +1,197 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+func newTestShadowService(cfg *config.Config) *ShadowService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	if cfg.ShadowTimeout == 0 {
+		cfg.ShadowTimeout = 5 * time.Second
+	}
+	return NewShadowService(cfg, logger)
+}
+
+func TestShadowSampled(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		sampleBy string
+		sender   string
+		want     bool
+	}{
+		{"off", 0, ShadowSampleBySender, "+15550000001", false},
+		{"everything", 1, ShadowSampleBySender, "+15550000001", true},
+		{"everything per request", 1, ShadowSampleByRequest, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestShadowService(&config.Config{ShadowSampleRate: tt.rate, ShadowSampleBy: tt.sampleBy})
+			if got := service.sampled(tt.sender); got != tt.want {
+				t.Errorf("sampled(%q) = %v, want %v", tt.sender, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShadowSampledBySender(t *testing.T) {
+	service := newTestShadowService(&config.Config{ShadowSampleRate: 0.3, ShadowSampleBy: ShadowSampleBySender})
+
+	// A sender is always decided the same way, however the number is written
+	for i := 0; i < 200; i++ {
+		number := fmt.Sprintf("+1555%07d", i)
+		want := service.sampled(number)
+		for _, variant := range []string{"whatsapp:" + number, strings.TrimPrefix(number, "+"), number} {
+			if got := service.sampled(variant); got != want {
+				t.Fatalf("sampled(%q) = %v, but sampled(%q) = %v", variant, got, number, want)
+			}
+		}
+	}
+
+	// And about the configured share of senders is sampled
+	sampled := 0
+	const senders = 10000
+	for i := 0; i < senders; i++ {
+		if service.sampled(fmt.Sprintf("+4479%08d", i)) {
+			sampled++
+		}
+	}
+	if share := float64(sampled) / senders; share < 0.27 || share > 0.33 {
+		t.Errorf("sampled %.3f of senders, want about 0.3", share)
+	}
+}
+
+func TestWebhookSender(t *testing.T) {
+	inbound := url.Values{"From": {"whatsapp:+15550000001"}, "To": {"whatsapp:+15550000000"}}
+	callback := url.Values{"From": {"whatsapp:+15550000000"}, "To": {"whatsapp:+15550000001"}, "MessageStatus": {"delivered"}}
+
+	tests := []struct {
+		name        string
+		source      string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"inbound message", "twilio", "application/x-www-form-urlencoded", inbound.Encode(), "whatsapp:+15550000001"},
+		{"status callback", "twilio", "application/x-www-form-urlencoded; charset=utf-8", callback.Encode(), "whatsapp:+15550000001"},
+		{"other source", "meta", "application/x-www-form-urlencoded", inbound.Encode(), ""},
+		{"json body", "twilio", "application/json", `{"From":"+15550000001"}`, ""},
+		{"malformed form", "twilio", "application/x-www-form-urlencoded", "%zz", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/webhook/whatsapp", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if got := webhookSender(tt.source, r, []byte(tt.body)); got != tt.want {
+				t.Errorf("webhookSender() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirrorWebhook(t *testing.T) {
+	type mirrored struct {
+		path   string
+		host   string
+		header http.Header
+		body   string
+	}
+	received := make(chan mirrored, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirrored{path: r.URL.RequestURI(), host: r.Host, header: r.Header, body: string(body)}
+	}))
+	defer shadow.Close()
+
+	service := newTestShadowService(&config.Config{
+		ShadowWebhookURL:     shadow.URL + "/",
+		ShadowSampleRate:     1,
+		ShadowSampleBy:       ShadowSampleBySender,
+		ShadowMaxConcurrency: 1,
+	})
+
+	body := url.Values{"From": {"whatsapp:+15550000001"}, "Body": {"hi"}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "https://adapter.example.com/webhook/whatsapp?attempt=1", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Twilio-Signature", "c2lnbmF0dXJl")
+	r.Header.Set("Connection", "keep-alive")
+
+	service.MirrorWebhook("twilio", r, []byte(body))
+
+	select {
+	case got := <-received:
+		if got.path != "/webhook/whatsapp?attempt=1" {
+			t.Errorf("path = %q, want the original path and query", got.path)
+		}
+		if got.host != "adapter.example.com" {
+			t.Errorf("host = %q, want the original host so signatures verify", got.host)
+		}
+		if got.header.Get("X-Twilio-Signature") != "c2lnbmF0dXJl" {
+			t.Errorf("signature header = %q, want it copied", got.header.Get("X-Twilio-Signature"))
+		}
+		if got.header.Get(ShadowHeader) != "true" {
+			t.Errorf("%s = %q, want true", ShadowHeader, got.header.Get(ShadowHeader))
+		}
+		if got.body != body {
+			t.Errorf("body = %q, want %q", got.body, body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not mirrored")
+	}
+}
+
+func TestMirrorWebhookDropsWhenBusy(t *testing.T) {
+	release := make(chan struct{})
+	requests := make(chan struct{}, 10)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- struct{}{}
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	service := newTestShadowService(&config.Config{
+		ShadowWebhookURL:     shadow.URL,
+		ShadowSampleRate:     1,
+		ShadowSampleBy:       ShadowSampleByRequest,
+		ShadowMaxConcurrency: 1,
+	})
+
+	before := shadowRequestsTotal.Value(shadowTargetWebhook, "dropped")
+	r := httptest.NewRequest(http.MethodPost, "/webhook/meta", strings.NewReader("{}"))
+	service.MirrorWebhook("meta", r, []byte("{}"))
+	<-requests
+	service.MirrorWebhook("meta", r, []byte("{}"))
+
+	if dropped := shadowRequestsTotal.Value(shadowTargetWebhook, "dropped") - before; dropped != 1 {
+		t.Errorf("dropped %v copies, want 1 while the only slot is taken", dropped)
+	}
+}
+
+func TestMirrorWebhookDisabled(t *testing.T) {
+	service := newTestShadowService(&config.Config{ShadowSampleRate: 1})
+
+	before := shadowRequestsTotal.Value(shadowTargetWebhook, "dropped")
+	r := httptest.NewRequest(http.MethodPost, "/webhook/meta", nil)
+	for i := 0; i < 5; i++ {
+		service.MirrorWebhook("meta", r, nil)
+	}
+
+	if len(service.slots) != 0 || shadowRequestsTotal.Value(shadowTargetWebhook, "dropped") != before {
+		t.Error("mirrored a webhook without a shadow URL")
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// SLO metrics
+var (
+	sloCompliance      = metrics.NewGauge("slo_compliance_ratio", "Share of messages meeting the SLO threshold by slo and window", "slo", "window")
+	sloBurnRate        = metrics.NewGauge("slo_error_budget_burn_rate", "Error budget burn rate by slo and window; 1 spends the budget exactly over the SLO window", "slo", "window")
+	sloBudgetRemaining = metrics.NewGauge("slo_error_budget_remaining_ratio", "Share of the error budget left over the SLO window", "slo")
+)
+
+// sloBurnWindows are the short windows burn rates are reported for, besides the SLO
+// window itself; a high burn over both signals a fast budget spend
+var sloBurnWindows = []time.Duration{time.Hour, 6 * time.Hour}
+
+// sloDeliveryChannels are the channels that report delivery receipts
+var sloDeliveryChannels = []string{
+	string(models.ChannelWhatsApp),
+	string(models.ChannelSMS),
+	string(models.ChannelMessenger),
+	string(models.ChannelInstagram),
+}
+
+// SLOService computes delivery SLO compliance and error budget burn. Inbound messages are
+// measured from their orchestrator forward in the outbox, outbound messages from the
+// status history. Messages younger than the threshold are not counted yet.
+type SLOService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewSLOService creates a new SLO service
+func NewSLOService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *SLOService {
+	return &SLOService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Start updates the SLO metrics every evaluation interval until ctx is done
+func (s *SLOService) Start(ctx context.Context) {
+	if s.config.SLOWindow > s.config.OutboxRetention {
+		s.logger.WithFields(logrus.Fields{
+			"slo_window":       s.config.SLOWindow,
+			"outbox_retention": s.config.OutboxRetention,
+		}).Warn("SLO window is longer than the outbox retention; inbound compliance only covers the retention")
+	}
+
+	ticker := time.NewTicker(s.config.SLOEvaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Report(ctx)
+		if err != nil {
+			s.logger.WithError(err).Error("SLO evaluation failed")
+		} else {
+			for _, slo := range report.SLOs {
+				for _, window := range slo.Windows {
+					sloCompliance.Set(window.Compliance, slo.Name, window.Window)
+					sloBurnRate.Set(window.BurnRate, slo.Name, window.Window)
+				}
+				sloBudgetRemaining.Set(slo.ErrorBudgetRemaining, slo.Name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report computes every SLO over the burn windows and the SLO window
+func (s *SLOService) Report(ctx context.Context) (*models.SLOReport, error) {
+	now := time.Now()
+	windows := append(append([]time.Duration{}, sloBurnWindows...), s.config.SLOWindow)
+
+	inbound := &models.SLOStatus{
+		Name:        models.SLOInboundForward,
+		Description: fmt.Sprintf("%g%% of inbound messages forwarded to the orchestrator within %s", s.config.SLOInboundForwardTarget*100, s.config.SLOInboundForwardThreshold),
+		Target:      s.config.SLOInboundForwardTarget,
+		Threshold:   s.config.SLOInboundForwardThreshold.String(),
+	}
+	outbound := &models.SLOStatus{
+		Name:        models.SLOOutboundDelivery,
+		Description: fmt.Sprintf("%g%% of outbound messages delivered within %s", s.config.SLOOutboundDeliveryTarget*100, s.config.SLOOutboundDeliveryThreshold),
+		Target:      s.config.SLOOutboundDeliveryTarget,
+		Threshold:   s.config.SLOOutboundDeliveryThreshold.String(),
+	}
+
+	for _, window := range windows {
+		total, good, err := s.countInboundForwards(ctx, now.Add(-window), now.Add(-s.config.SLOInboundForwardThreshold))
+		if err != nil {
+			return nil, err
+		}
+		inbound.Windows = append(inbound.Windows, sloWindow(window, total, good, inbound.Target))
+
+		total, good, err = s.countOutboundDeliveries(ctx, now.Add(-window), now.Add(-s.config.SLOOutboundDeliveryThreshold))
+		if err != nil {
+			return nil, err
+		}
+		outbound.Windows = append(outbound.Windows, sloWindow(window, total, good, outbound.Target))
+	}
+
+	// The last window is the SLO window
+	for _, slo := range []*models.SLOStatus{inbound, outbound} {
+		slo.ErrorBudgetRemaining = 1 - slo.Windows[len(slo.Windows)-1].BurnRate
+	}
+
+	return &models.SLOReport{
+		SLOs:        []*models.SLOStatus{inbound, outbound},
+		GeneratedAt: now,
+	}, nil
+}
+
+// Helper methods
+
+// countInboundForwards counts orchestrator forwards created in [from, to) and those
+// delivered within the threshold
+func (s *SLOService) countInboundForwards(ctx context.Context, from, to time.Time) (total, good int, err error) {
+	err = s.db.QueryRow(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status = 'done' AND processed_at <= created_at + $3 * INTERVAL '1 second')
+		FROM outbox
+		WHERE kind = $4 AND created_at >= $1 AND created_at < $2`,
+		from, to, s.config.SLOInboundForwardThreshold.Seconds(), models.OutboxKindOrchestratorForward,
+	).Scan(&total, &good)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count inbound forwards: %w", err)
+	}
+	return total, good, nil
+}
+
+// countOutboundDeliveries counts outbound messages sent in [from, to) on channels with
+// delivery receipts and those delivered within the threshold
+func (s *SLOService) countOutboundDeliveries(ctx context.Context, from, to time.Time) (total, good int, err error) {
+	err = s.db.QueryRow(ctx, `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE d.delivered_at <= m.created_at + $3 * INTERVAL '1 second')
+		FROM whatsapp_messages m
+		LEFT JOIN LATERAL (
+			SELECT MIN(e.received_at) AS delivered_at
+			FROM message_status_events e
+			WHERE e.twilio_sid = m.twilio_sid AND e.status IN ('delivered', 'read')
+		) d ON TRUE
+		WHERE m.direction = 'outbound' AND m.channel = ANY($4) AND m.status <> 'canceled'
+			AND m.timestamp >= $1 AND m.created_at >= $1 AND m.created_at < $2`,
+		from, to, s.config.SLOOutboundDeliveryThreshold.Seconds(), sloDeliveryChannels,
+	).Scan(&total, &good)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count outbound deliveries: %w", err)
+	}
+	return total, good, nil
+}
+
+// sloWindow computes compliance and burn rate; an empty window is compliant
+func sloWindow(window time.Duration, total, good int, target float64) *models.SLOWindow {
+	result := &models.SLOWindow{
+		Window:     window.String(),
+		Total:      total,
+		Good:       good,
+		Compliance: 1,
+	}
+	if total > 0 {
+		result.Compliance = float64(good) / float64(total)
+	}
+	if budget := 1 - target; budget > 0 {
+		result.BurnRate = (1 - result.Compliance) / budget
+	}
+	return result
+}
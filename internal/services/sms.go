@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// smsStatusCallbackPath is where Twilio reports the delivery status of our SMS sends
+const smsStatusCallbackPath = "/webhooks/whatsapp/status"
+
+// SMSService sends plain SMS messages via Twilio Programmable Messaging
+type SMSService struct {
+	client         *twilio.RestClient
+	logger         *logrus.Logger
+	fromNumber     string
+	statusCallback string // empty leaves it to the number's Messaging Service
+}
+
+// NewSMSService creates a new SMS service instance
+func NewSMSService(cfg *config.Config, twilioCalls *TwilioCallLog, logger *logrus.Logger) *SMSService {
+	client := newTwilioClient(cfg.TwilioAccountSID, cfg.TwilioAuthToken, twilioCalls)
+
+	return &SMSService{
+		client:         client,
+		logger:         logger,
+		fromNumber:     cfg.SMSFromNumber,
+		statusCallback: smsStatusCallback(cfg.WebhookBaseURL),
+	}
+}
+
+// SendTextMessage sends a text message via SMS
+func (s *SMSService) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(normalizePhoneNumber(to))
+	params.SetFrom(s.fromNumber)
+	params.SetBody(content)
+
+	return s.create(params)
+}
+
+// SendMediaMessage sends an MMS; carriers without MMS support receive the text only
+func (s *SMSService) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(normalizePhoneNumber(to))
+	params.SetFrom(s.fromNumber)
+	if content != "" {
+		params.SetBody(content)
+	}
+	params.SetMediaUrl([]string{mediaURL})
+
+	return s.create(params)
+}
+
+// SendTemplateMessage is not supported over SMS; WhatsApp content templates have no SMS rendering
+func (s *SMSService) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("template messages are not supported over SMS")
+}
+
+// CancelMessage asks Twilio to cancel a message it has not sent yet
+func (s *SMSService) CancelMessage(ctx context.Context, messageSID string) error {
+	return cancelTwilioMessage(s.client, messageSID)
+}
+
+// Name identifies the provider
+func (s *SMSService) Name() string {
+	return "twilio_sms"
+}
+
+// GetFromNumber returns the configured SMS from number
+func (s *SMSService) GetFromNumber() string {
+	return s.fromNumber
+}
+
+// create submits a message to Twilio and builds the send response
+func (s *SMSService) create(params *twilioApi.CreateMessageParams) (*models.SendMessageResponse, error) {
+	if s.statusCallback != "" {
+		params.SetStatusCallback(s.statusCallback)
+	}
+	resp, err := s.client.Api.CreateMessage(params)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to send SMS message")
+		return nil, fmt.Errorf("failed to send SMS: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"twilio_sid": *resp.Sid,
+		"status":     *resp.Status,
+	}).Info("SMS message sent successfully")
+
+	return &models.SendMessageResponse{
+		ID:        uuid.New(),
+		TwilioSID: *resp.Sid,
+		Status:    mapTwilioStatus(*resp.Status),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// smsStatusCallback is the status webhook under the public base URL, or "" without one
+func smsStatusCallback(baseURL string) string {
+	if baseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(baseURL, "/") + smsStatusCallbackPath
+}
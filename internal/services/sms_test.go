@@ -0,0 +1,24 @@
+package services
+
+import "testing"
+
+func TestSMSStatusCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+	}{
+		{"not configured", "", ""},
+		{"host", "https://adapter.example.com", "https://adapter.example.com/webhooks/whatsapp/status"},
+		{"trailing slash", "https://adapter.example.com/", "https://adapter.example.com/webhooks/whatsapp/status"},
+		{"path prefix", "https://example.com/adapter", "https://example.com/adapter/webhooks/whatsapp/status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := smsStatusCallback(tt.baseURL); got != tt.want {
+				t.Errorf("smsStatusCallback(%q) = %q, want %q", tt.baseURL, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// snapshotEllipsis marks content cut to fit the snapshot budget
+const snapshotEllipsis = "…"
+
+// ConversationSnapshot returns up to limit of the user's most recent messages across
+// channels, oldest first, keeping the newest messages whose content fits in maxChars
+// characters. If even the newest message does not fit, its content is cut at the start.
+// Zero values use SNAPSHOT_MAX_MESSAGES and SNAPSHOT_MAX_CHARS.
+func (s *SessionService) ConversationSnapshot(ctx context.Context, userID uuid.UUID, limit, maxChars int) (*models.ConversationSnapshot, error) {
+	if limit <= 0 {
+		limit = s.config.SnapshotMaxMessages
+	}
+	if maxChars <= 0 {
+		maxChars = s.config.SnapshotMaxChars
+	}
+
+	// One extra message tells whether older history was left out
+	recent, err := s.messageService.GetMessagesByUserID(ctx, userID, models.Metadata{}, limit+1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent messages: %w", err)
+	}
+
+	snapshot := &models.ConversationSnapshot{
+		UserID:   userID,
+		Messages: []models.SnapshotMessage{},
+		MaxChars: maxChars,
+	}
+	if len(recent) > limit {
+		recent = recent[:limit]
+		snapshot.Truncated = true
+	}
+
+	// Messages arrive newest first; fill the budget from the newest
+	for _, message := range recent {
+		// Messages the user deleted for everyone must not be acted on
+		if message.RevokedAt != nil {
+			continue
+		}
+		content := snapshotContent(message)
+		length := utf8.RuneCountInString(content)
+
+		if snapshot.Chars+length > maxChars {
+			snapshot.Truncated = true
+			if len(snapshot.Messages) == 0 {
+				content = cutToLast(content, maxChars)
+				length = utf8.RuneCountInString(content)
+			} else {
+				break
+			}
+		}
+
+		role := models.SnapshotRoleUser
+		if message.Direction == models.MessageDirectionOutbound {
+			role = models.SnapshotRoleAssistant
+		}
+		snapshot.Messages = append(snapshot.Messages, models.SnapshotMessage{
+			ID:        message.ID,
+			Role:      role,
+			Content:   content,
+			Type:      message.Type,
+			Channel:   message.Channel,
+			Timestamp: message.Timestamp,
+		})
+		snapshot.Chars += length
+	}
+
+	// Oldest first, as prompts are written
+	for i, j := 0, len(snapshot.Messages)-1; i < j; i, j = i+1, j-1 {
+		snapshot.Messages[i], snapshot.Messages[j] = snapshot.Messages[j], snapshot.Messages[i]
+	}
+
+	return snapshot, nil
+}
+
+// snapshotContent is the text a message contributes to a snapshot: the transcript of
+// voice notes, the extracted text of documents and images, or a placeholder for media
+// without text
+func snapshotContent(message *models.WhatsAppMessage) string {
+	content := strings.TrimSpace(toChatContextMessage(message).Content)
+	if content == "" && message.ExtractedText != nil {
+		content = strings.TrimSpace(*message.ExtractedText)
+	}
+	if content == "" && message.Type != models.MessageTypeText && message.Type != "" {
+		content = fmt.Sprintf("[%s]", message.Type)
+	}
+	return content
+}
+
+// cutToLast keeps the last max characters of content, marking the cut with an ellipsis
+func cutToLast(content string, max int) string {
+	runes := []rune(content)
+	if len(runes) <= max {
+		return content
+	}
+	if max <= utf8.RuneCountInString(snapshotEllipsis) {
+		return string(runes[len(runes)-max:])
+	}
+	return snapshotEllipsis + string(runes[len(runes)-max+utf8.RuneCountInString(snapshotEllipsis):])
+}
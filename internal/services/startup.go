@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// How failed startup checks are handled (STARTUP_CHECKS)
+const (
+	StartupChecksEnforce = "enforce" // refuse to start
+	StartupChecksDegrade = "degrade" // serve, and report degraded readiness
+	StartupChecksOff     = "off"
+)
+
+// twilioSandboxNumber is Twilio's shared WhatsApp sandbox sender, which no account owns
+const twilioSandboxNumber = "whatsapp:+14155238886"
+
+// twilioSendersURL lists the WhatsApp senders of the account
+const twilioSendersURL = "https://messaging.twilio.com/v2/Channels/Senders"
+
+var startupCheckFailed = metrics.NewGauge("startup_check_failed", "Whether a startup compatibility check failed (1) or not (0)", "check")
+
+// StartupChecker verifies at boot that the database schema, the Redis server and the
+// Twilio sender are what this binary expects
+type StartupChecker struct {
+	db              *pgxpool.Pool
+	redis           *redis.Client
+	whatsappService *WhatsAppService
+	config          *config.Config
+	logger          *logrus.Logger
+
+	results []*models.StartupCheck
+}
+
+// NewStartupChecker creates a new startup checker
+func NewStartupChecker(db *pgxpool.Pool, redisClient *redis.Client, whatsappService *WhatsAppService, cfg *config.Config, logger *logrus.Logger) *StartupChecker {
+	return &StartupChecker{
+		db:              db,
+		redis:           redisClient,
+		whatsappService: whatsappService,
+		config:          cfg,
+		logger:          logger,
+	}
+}
+
+// Run runs every check, logs failures and returns how many failed
+func (s *StartupChecker) Run(ctx context.Context) int {
+	if s.config.StartupChecks == StartupChecksOff {
+		return 0
+	}
+
+	checks := []struct {
+		name string
+		run  func(context.Context) (detail string, skipped bool, err error)
+	}{
+		{"schema", s.checkSchema},
+		{"redis", s.checkRedis},
+		{"twilio_sender", s.checkTwilioSender},
+	}
+
+	failed := 0
+	s.results = nil
+	for _, check := range checks {
+		result := &models.StartupCheck{Name: check.name, Status: models.StartupCheckOK}
+		detail, skipped, err := check.run(ctx)
+		result.Detail = detail
+		switch {
+		case err != nil:
+			result.Status = models.StartupCheckFailed
+			result.Detail = err.Error()
+			failed++
+			startupCheckFailed.Set(1, check.name)
+			s.logger.WithError(err).WithField("check", check.name).Error("Startup check failed")
+		case skipped:
+			result.Status = models.StartupCheckSkipped
+			startupCheckFailed.Set(0, check.name)
+		default:
+			startupCheckFailed.Set(0, check.name)
+		}
+		s.results = append(s.results, result)
+	}
+
+	return failed
+}
+
+// Results returns the outcome of the last run
+func (s *StartupChecker) Results() []*models.StartupCheck {
+	return s.results
+}
+
+// Degraded reports whether the service runs despite failed startup checks
+func (s *StartupChecker) Degraded() bool {
+	for _, result := range s.results {
+		if result.Status == models.StartupCheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSchema compares the schema version in schema_migrations with the binary's
+func (s *StartupChecker) checkSchema(ctx context.Context) (string, bool, error) {
+	version, err := database.CheckSchemaVersion(ctx, s.db)
+	if err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("version %d", version), false, nil
+}
+
+// checkRedis compares the Redis server version with REDIS_MIN_VERSION
+func (s *StartupChecker) checkRedis(ctx context.Context) (string, bool, error) {
+	info, err := s.redis.Info(ctx, "server").Result()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read Redis server info: %w", err)
+	}
+
+	var version string
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			version = value
+			break
+		}
+	}
+	if version == "" {
+		return "", false, fmt.Errorf("redis server did not report its version")
+	}
+
+	if compareVersions(version, s.config.RedisMinVersion) < 0 {
+		return "", false, fmt.Errorf("redis %s is older than the required %s", version, s.config.RedisMinVersion)
+	}
+	return "version " + version, false, nil
+}
+
+// checkTwilioSender verifies that the from number is an online WhatsApp sender of the account
+func (s *StartupChecker) checkTwilioSender(ctx context.Context) (string, bool, error) {
+	from := s.config.TwilioWhatsAppFrom
+	switch {
+	case s.config.TwilioAccountSID == "":
+		return "Twilio is not configured", true, nil
+	case from == twilioSandboxNumber:
+		return "the WhatsApp sandbox number", true, nil
+	}
+
+	status, err := s.whatsappService.SenderStatus(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if !strings.EqualFold(status, "online") {
+		return "", false, fmt.Errorf("WhatsApp sender %s is %s", from, strings.ToLower(status))
+	}
+	return from + " online", false, nil
+}
+
+// twilioSendersPage is a page of the Twilio senders list
+type twilioSendersPage struct {
+	Senders []struct {
+		SenderID string `json:"sender_id"`
+		Status   string `json:"status"`
+	} `json:"senders"`
+	Meta struct {
+		NextPageURL string `json:"next_page_url"`
+	} `json:"meta"`
+}
+
+// SenderStatus returns the status Twilio reports for the from number as a WhatsApp
+// sender of the account, e.g. ONLINE
+func (w *WhatsAppService) SenderStatus(ctx context.Context) (string, error) {
+	from := strings.ToLower(w.fromNumber)
+	if !strings.HasPrefix(from, "whatsapp:") {
+		from = "whatsapp:" + from
+	}
+
+	pageURL := twilioSendersURL
+	query := url.Values{"Channel": {"whatsapp"}, "PageSize": {"100"}}
+	for pageURL != "" {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		resp, err := w.client.Get(pageURL, query, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to list WhatsApp senders: %w", err)
+		}
+		var page twilioSendersPage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to decode WhatsApp senders: %w", err)
+		}
+
+		for _, sender := range page.Senders {
+			if strings.ToLower(sender.SenderID) == from {
+				return sender.Status, nil
+			}
+		}
+
+		// The next page URL carries its own query
+		pageURL, query = page.Meta.NextPageURL, nil
+	}
+
+	return "", fmt.Errorf("%s is not a WhatsApp sender of this Twilio account", w.fromNumber)
+}
+
+// compareVersions compares dotted numeric versions such as 7.2.4; missing parts count as 0
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// statusEventColumns lists the message_status_events columns in the order
+// scanStatusEvent expects
+const statusEventColumns = `
+	id, message_id, twilio_sid, status, provider_status, error_code, error_message,
+	payload_ref, received_at`
+
+// GetStatusHistory returns every status callback received for a message, oldest first,
+// including those that arrived before the message was stored
+func (m *MessageService) GetStatusHistory(ctx context.Context, messageID uuid.UUID) (*models.MessageStatusHistory, error) {
+	ctx, cancel := m.query(ctx, "get_status_history")
+	defer cancel()
+
+	message, err := m.GetMessage(ctx, messageID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	history := &models.MessageStatusHistory{
+		MessageID: message.ID,
+		TwilioSID: message.TwilioSID,
+		Status:    message.Status,
+		Events:    []*models.MessageStatusEvent{},
+	}
+	if message.TwilioSID == "" {
+		return history, nil
+	}
+
+	rows, err := m.db.Query(ctx, `SELECT`+statusEventColumns+`
+		FROM message_status_events
+		WHERE twilio_sid = $1
+		ORDER BY received_at, id`, message.TwilioSID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanStatusEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status event: %w", err)
+		}
+		history.Events = append(history.Events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// Helper functions
+
+// insertStatusEvent records a status callback for each message it updated, or once
+// without a message when its SID matched none
+func insertStatusEvent(ctx context.Context, db dbExecer, update *models.MessageStatusUpdate, messageIDs []uuid.UUID) error {
+	targets := make([]*uuid.UUID, 0, len(messageIDs))
+	for i := range messageIDs {
+		targets = append(targets, &messageIDs[i])
+	}
+	if len(targets) == 0 {
+		targets = append(targets, nil)
+	}
+
+	for _, messageID := range targets {
+		_, err := db.Exec(ctx, `
+			INSERT INTO message_status_events (
+				id, message_id, twilio_sid, status, provider_status, error_code, error_message,
+				payload_ref, received_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			uuid.New(),
+			messageID,
+			update.MessageSid,
+			update.Status,
+			update.ProviderStatus,
+			update.ErrorCode,
+			update.ErrorMessage,
+			update.PayloadRef,
+			update.Timestamp,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record status event: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanStatusEvent scans a message_status_events row selected with statusEventColumns
+func scanStatusEvent(row pgx.Row) (*models.MessageStatusEvent, error) {
+	var event models.MessageStatusEvent
+	err := row.Scan(
+		&event.ID,
+		&event.MessageID,
+		&event.TwilioSID,
+		&event.Status,
+		&event.ProviderStatus,
+		&event.ErrorCode,
+		&event.ErrorMessage,
+		&event.PayloadRef,
+		&event.ReceivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
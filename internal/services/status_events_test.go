@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// recordingExecer records the arguments of every Exec, failing with err when set
+type recordingExecer struct {
+	calls [][]interface{}
+	err   error
+}
+
+func (r *recordingExecer) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	r.calls = append(r.calls, arguments)
+	return pgconn.CommandTag{}, r.err
+}
+
+func TestInsertStatusEvent(t *testing.T) {
+	errorCode := "63016"
+	token := "idem-1"
+	receivedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	update := &models.MessageStatusUpdate{
+		MessageSid:     "SM0",
+		Status:         models.MessageStatusFailed,
+		ProviderStatus: "undelivered",
+		ErrorCode:      &errorCode,
+		PayloadRef:     &token,
+		Timestamp:      receivedAt,
+	}
+	first, second := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name       string
+		messageIDs []uuid.UUID
+		want       []*uuid.UUID
+	}{
+		{"one message", []uuid.UUID{first}, []*uuid.UUID{&first}},
+		{"several messages share the sid", []uuid.UUID{first, second}, []*uuid.UUID{&first, &second}},
+		{"unknown sid", nil, []*uuid.UUID{nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &recordingExecer{}
+			if err := insertStatusEvent(context.Background(), db, update, tt.messageIDs); err != nil {
+				t.Fatalf("insertStatusEvent() error = %v", err)
+			}
+			if len(db.calls) != len(tt.want) {
+				t.Fatalf("inserted %d events, want %d", len(db.calls), len(tt.want))
+			}
+
+			for i, args := range db.calls {
+				messageID := args[1].(*uuid.UUID)
+				if (messageID == nil) != (tt.want[i] == nil) || (messageID != nil && *messageID != *tt.want[i]) {
+					t.Errorf("event %d message_id = %v, want %v", i, messageID, tt.want[i])
+				}
+				if args[2] != "SM0" || args[3] != models.MessageStatusFailed || args[4] != "undelivered" {
+					t.Errorf("event %d sid, status, provider status = %v, %v, %v", i, args[2], args[3], args[4])
+				}
+				if args[5] != &errorCode || args[7] != &token || args[8] != receivedAt {
+					t.Errorf("event %d error code, payload ref, received at = %v, %v, %v", i, args[5], args[7], args[8])
+				}
+			}
+		})
+	}
+}
+
+func TestInsertStatusEventError(t *testing.T) {
+	failure := errors.New("connection reset")
+	db := &recordingExecer{err: failure}
+
+	err := insertStatusEvent(context.Background(), db, &models.MessageStatusUpdate{MessageSid: "SM0"}, []uuid.UUID{uuid.New(), uuid.New()})
+	if !errors.Is(err, failure) {
+		t.Fatalf("insertStatusEvent() error = %v, want %v", err, failure)
+	}
+	if len(db.calls) != 1 {
+		t.Errorf("kept inserting after a failure: %d calls", len(db.calls))
+	}
+}
+
+func TestProcessStatusUpdate(t *testing.T) {
+	service := newTestWhatsAppService()
+
+	tests := []struct {
+		name       string
+		smsStatus  string
+		errorCode  string
+		wantStatus models.MessageStatus
+	}{
+		{"queued", "queued", "", models.MessageStatusPending},
+		{"sent", "sent", "", models.MessageStatusSent},
+		{"delivered", "delivered", "", models.MessageStatusDelivered},
+		{"read", "read", "", models.MessageStatusRead},
+		{"undelivered", "undelivered", "", models.MessageStatusFailed},
+		{"canceled", "canceled", "", models.MessageStatusCanceled},
+		{"unknown status", "paused", "", models.MessageStatusPending},
+		{"error code fails a sent message", "sent", "30008", models.MessageStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update, err := service.ProcessStatusUpdate(&models.TwilioWebhookRequest{
+				MessageSid:   "SM0",
+				SmsStatus:    tt.smsStatus,
+				ErrorCode:    tt.errorCode,
+				ErrorMessage: "Unknown error",
+			})
+			if err != nil {
+				t.Fatalf("ProcessStatusUpdate() error = %v", err)
+			}
+			if update.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", update.Status, tt.wantStatus)
+			}
+			if update.ProviderStatus != tt.smsStatus {
+				t.Errorf("ProviderStatus = %q, want the status as reported, %q", update.ProviderStatus, tt.smsStatus)
+			}
+			if (update.ErrorCode != nil) != (tt.errorCode != "") {
+				t.Errorf("ErrorCode = %v, want set only for %q", update.ErrorCode, tt.errorCode)
+			}
+		})
+	}
+}
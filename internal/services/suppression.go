@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrSuppressionNotFound is returned when a suppression entry does not exist
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// suppressionColumns lists the recipient_suppressions columns in the order scanSuppression expects
+const suppressionColumns = ` id, channel, recipient, reason, created_at`
+
+// SuppressionService keeps the list of recipients that must not be messaged
+type SuppressionService struct {
+	db     *pgxpool.Pool
+	logger *logrus.Logger
+}
+
+// NewSuppressionService creates a new suppression list service
+func NewSuppressionService(db *pgxpool.Pool, logger *logrus.Logger) *SuppressionService {
+	return &SuppressionService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Lookup returns the suppression entry for a recipient on a channel, or nil if they
+// can be messaged. Phone numbers match in any of their equivalent forms.
+func (s *SuppressionService) Lookup(ctx context.Context, channel models.Channel, recipient string) (*models.RecipientSuppression, error) {
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	recipients := []string{normalizeRecipient(channel, recipient)}
+	if _, ok := models.IdentityKindForChannel(channel); !ok {
+		recipients = phoneVariants(recipients[0])
+	}
+
+	query := `SELECT` + suppressionColumns + ` FROM recipient_suppressions
+		WHERE channel = $1 AND recipient = ANY($2)
+		LIMIT 1`
+
+	suppression, err := scanSuppression(s.db.QueryRow(ctx, query, channel, recipients))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check suppression list: %w", err)
+	}
+
+	return suppression, nil
+}
+
+// Suppress adds a recipient to the suppression list; suppressing an already suppressed
+// recipient updates the reason
+func (s *SuppressionService) Suppress(ctx context.Context, request *models.SuppressRecipientRequest) (*models.RecipientSuppression, error) {
+	channel := request.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	query := `
+		INSERT INTO recipient_suppressions (id, channel, recipient, reason, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (channel, recipient) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING` + suppressionColumns
+
+	suppression, err := scanSuppression(s.db.QueryRow(ctx, query,
+		uuid.New(), channel, normalizeRecipient(channel, request.Recipient), request.Reason))
+	if err != nil {
+		return nil, fmt.Errorf("failed to suppress recipient: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"channel":   suppression.Channel,
+		"recipient": suppression.Recipient,
+	}).Info("Recipient suppressed")
+
+	return suppression, nil
+}
+
+// Unsuppress removes a suppression entry
+func (s *SuppressionService) Unsuppress(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, `DELETE FROM recipient_suppressions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to remove suppression: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+// List returns suppression entries, newest first
+func (s *SuppressionService) List(ctx context.Context, limit, offset int) ([]*models.RecipientSuppression, int64, error) {
+	query := `SELECT` + suppressionColumns + ` FROM recipient_suppressions
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
+	rows, err := s.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	suppressions := []*models.RecipientSuppression{}
+	for rows.Next() {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan suppression: %w", err)
+		}
+		suppressions = append(suppressions, suppression)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading suppressions: %w", err)
+	}
+
+	return suppressions, estimateTotal(ctx, s.db, s.logger, query, limit, offset), nil
+}
+
+// scanSuppression scans a recipient_suppressions row selected with suppressionColumns
+func scanSuppression(row pgx.Row) (*models.RecipientSuppression, error) {
+	var suppression models.RecipientSuppression
+	err := row.Scan(
+		&suppression.ID,
+		&suppression.Channel,
+		&suppression.Recipient,
+		&suppression.Reason,
+		&suppression.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+// normalizeRecipient puts an outbound address in the form it is stored under: channel
+// identifiers as identities, everything else as an E.164 phone number
+func normalizeRecipient(channel models.Channel, recipient string) string {
+	if kind, ok := models.IdentityKindForChannel(channel); ok {
+		return NormalizeIdentityValue(kind, recipient)
+	}
+	return normalizePhoneNumber(recipient)
+}
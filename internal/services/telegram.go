@@ -0,0 +1,382 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// Telegram update delivery modes
+const (
+	TelegramModeWebhook = "webhook"
+	TelegramModePolling = "polling"
+)
+
+// Telegram Bot API limits
+const (
+	telegramMaxDownloadBytes = 20 * 1024 * 1024
+	telegramPollTimeout      = 30 * time.Second
+)
+
+// TelegramService sends and receives messages through the Telegram Bot API
+type TelegramService struct {
+	httpClient     *http.Client
+	apiURL         string
+	token          string
+	locationButton string
+	mediaService   *MediaService
+	logger         *logrus.Logger
+}
+
+// NewTelegramService creates a new Telegram bot service instance
+func NewTelegramService(cfg *config.Config, mediaService *MediaService, logger *logrus.Logger) *TelegramService {
+	return &TelegramService{
+		// Long polls hold the request open for telegramPollTimeout
+		httpClient:     newHTTPClient(telegramPollTimeout + 30*time.Second),
+		apiURL:         strings.TrimRight(cfg.TelegramAPIURL, "/"),
+		token:          cfg.TelegramBotToken,
+		locationButton: cfg.TelegramLocationButton,
+		mediaService:   mediaService,
+		logger:         logger,
+	}
+}
+
+// SendTextMessage sends a text message to a Telegram chat ID
+func (t *TelegramService) SendTextMessage(ctx context.Context, to, content string) (*models.SendMessageResponse, error) {
+	return t.send(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": to,
+		"text":    content,
+	})
+}
+
+// SendMediaMessage sends a photo, video, audio or document by URL with the content as caption
+func (t *TelegramService) SendMediaMessage(ctx context.Context, to, content, mediaURL, mediaType string) (*models.SendMessageResponse, error) {
+	method, field := "sendDocument", "document"
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		method, field = "sendPhoto", "photo"
+	case strings.HasPrefix(mediaType, "video/"):
+		method, field = "sendVideo", "video"
+	case strings.HasPrefix(mediaType, "audio/"):
+		method, field = "sendAudio", "audio"
+	}
+
+	params := map[string]interface{}{
+		"chat_id": to,
+		field:     mediaURL,
+	}
+	if content != "" {
+		params["caption"] = content
+	}
+
+	return t.send(ctx, method, params)
+}
+
+// SendLocationRequest sends the content with a one-time keyboard button that shares the
+// user's location; templateSID is ignored
+func (t *TelegramService) SendLocationRequest(ctx context.Context, to, content, templateSID string) (*models.SendMessageResponse, error) {
+	return t.send(ctx, "sendMessage", map[string]interface{}{
+		"chat_id": to,
+		"text":    content,
+		"reply_markup": map[string]interface{}{
+			"keyboard":          [][]map[string]interface{}{{{"text": t.locationButton, "request_location": true}}},
+			"one_time_keyboard": true,
+			"resize_keyboard":   true,
+		},
+	})
+}
+
+// SendTemplateMessage is not supported; WhatsApp content templates have no Telegram rendering
+func (t *TelegramService) SendTemplateMessage(ctx context.Context, to, templateSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return nil, fmt.Errorf("template messages are not supported on telegram")
+}
+
+// Name identifies the provider
+func (t *TelegramService) Name() string {
+	return "telegram_bot"
+}
+
+// GetFromNumber returns the bot ID, the numeric prefix of the bot token
+func (t *TelegramService) GetFromNumber() string {
+	if idx := strings.Index(t.token, ":"); idx > 0 {
+		return t.token[:idx]
+	}
+	return "telegram"
+}
+
+// ProcessUpdate converts a Telegram update into an inbound message and the sender's
+// display name. Attachments are copied to our media storage so the bot token never
+// leaves the adapter inside a file URL. It returns nil for updates without a message.
+func (t *TelegramService) ProcessUpdate(ctx context.Context, update *models.TelegramUpdate) (*models.WhatsAppMessage, string) {
+	tgMessage := update.Message
+	if tgMessage == nil {
+		return nil, ""
+	}
+
+	chatID := strconv.FormatInt(tgMessage.Chat.ID, 10)
+	now := time.Now()
+
+	message := &models.WhatsAppMessage{
+		ID:        uuid.New(),
+		TwilioSID: chatID + ":" + strconv.FormatInt(tgMessage.MessageID, 10),
+		From:      chatID,
+		To:        t.GetFromNumber(),
+		Direction: models.MessageDirectionInbound,
+		Type:      models.MessageTypeText,
+		Status:    models.MessageStatusDelivered,
+		Content:   tgMessage.Text,
+		Timestamp: time.Unix(tgMessage.Date, 0),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Channel:   models.ChannelTelegram,
+	}
+	if tgMessage.Date == 0 {
+		message.Timestamp = now
+	}
+
+	var file *models.TelegramFile
+	defaultMediaType := ""
+	switch {
+	case len(tgMessage.Photo) > 0:
+		// Photo sizes are ordered smallest to largest
+		file = &tgMessage.Photo[len(tgMessage.Photo)-1]
+		message.Type, defaultMediaType = models.MessageTypeImage, "image/jpeg"
+	case tgMessage.Voice != nil:
+		file = tgMessage.Voice
+		message.Type, defaultMediaType = models.MessageTypeAudio, "audio/ogg"
+	case tgMessage.Audio != nil:
+		file = tgMessage.Audio
+		message.Type, defaultMediaType = models.MessageTypeAudio, "audio/mpeg"
+	case tgMessage.Video != nil:
+		file = tgMessage.Video
+		message.Type, defaultMediaType = models.MessageTypeVideo, "video/mp4"
+	case tgMessage.VideoNote != nil:
+		file = tgMessage.VideoNote
+		message.Type, defaultMediaType = models.MessageTypeVideo, "video/mp4"
+	case tgMessage.Document != nil:
+		file = tgMessage.Document
+		message.Type, defaultMediaType = models.MessageTypeDocument, "application/octet-stream"
+	case tgMessage.Poll != nil:
+		message.UnsupportedType = "poll"
+	case tgMessage.Location != nil:
+		message.Type = models.MessageTypeLocation
+		if tgMessage.Location.LivePeriod > 0 {
+			message.Type = models.MessageTypeLiveLocation
+		}
+		message.Content = FormatCoordinates(tgMessage.Location.Latitude, tgMessage.Location.Longitude)
+	case tgMessage.Contact != nil:
+		message.Type = models.MessageTypeContact
+		message.Content = strings.TrimSpace(fmt.Sprintf("%s %s %s",
+			tgMessage.Contact.FirstName, tgMessage.Contact.LastName, tgMessage.Contact.PhoneNumber))
+	}
+
+	if file != nil {
+		message.Content = tgMessage.Caption
+
+		mediaType := file.MimeType
+		if mediaType == "" {
+			mediaType = defaultMediaType
+		}
+		message.MediaType = &mediaType
+
+		mediaURL, err := t.rehostFile(ctx, file, mediaType)
+		if err != nil {
+			t.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to copy Telegram attachment")
+		} else {
+			message.MediaURL = &mediaURL
+		}
+	}
+
+	return message, telegramDisplayName(tgMessage.From)
+}
+
+// ProcessLocationEdit converts an edited live location message into a position update.
+// Telegram sends each move of a live location as an edit of the original message; edit
+// dates order the updates. It returns nil for other updates.
+func (t *TelegramService) ProcessLocationEdit(update *models.TelegramUpdate) *models.LiveLocationUpdate {
+	tgMessage := update.EditedMessage
+	if tgMessage == nil || tgMessage.Location == nil {
+		return nil
+	}
+
+	return &models.LiveLocationUpdate{
+		Channel:    models.ChannelTelegram,
+		ProviderID: strconv.FormatInt(tgMessage.Chat.ID, 10) + ":" + strconv.FormatInt(tgMessage.MessageID, 10),
+		Latitude:   tgMessage.Location.Latitude,
+		Longitude:  tgMessage.Location.Longitude,
+		LivePeriod: tgMessage.Location.LivePeriod,
+		Sequence:   tgMessage.EditDate,
+	}
+}
+
+// Poll long-polls getUpdates and passes each update to handle until ctx is canceled.
+// Used instead of the webhook when the adapter is not reachable from the internet.
+func (t *TelegramService) Poll(ctx context.Context, handle func(context.Context, *models.TelegramUpdate)) {
+	var offset int64
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		var updates []models.TelegramUpdate
+		err := t.call(ctx, "getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message", "edited_message"},
+		}, &updates)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			t.logger.WithError(err).Warn("Telegram getUpdates failed")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+
+		for i := range updates {
+			handle(ctx, &updates[i])
+			offset = updates[i].UpdateID + 1
+		}
+	}
+}
+
+// Helper methods
+
+// send calls a Bot API send method and builds the send response
+func (t *TelegramService) send(ctx context.Context, method string, params map[string]interface{}) (*models.SendMessageResponse, error) {
+	var result struct {
+		MessageID int64 `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	}
+
+	if err := t.call(ctx, method, params, &result); err != nil {
+		t.logger.WithError(err).WithField("method", method).Error("Failed to send Telegram message")
+		return nil, err
+	}
+
+	sid := strconv.FormatInt(result.Chat.ID, 10) + ":" + strconv.FormatInt(result.MessageID, 10)
+
+	t.logger.WithFields(logrus.Fields{
+		"method":     method,
+		"message_id": sid,
+	}).Info("Telegram message sent successfully")
+
+	return &models.SendMessageResponse{
+		ID:        uuid.New(),
+		TwilioSID: sid,
+		Status:    models.MessageStatusSent,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// call invokes a Bot API method and decodes its result
+func (t *TelegramService) call(ctx context.Context, method string, params map[string]interface{}, result interface{}) error {
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to marshal telegram %s request: %w", method, err)
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", t.apiURL, t.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create telegram %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		// The URL embeds the token, so never wrap the raw transport error
+		return fmt.Errorf("telegram %s request failed", method)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("failed to decode telegram %s response: %w", method, err)
+	}
+	if !envelope.OK {
+		return fmt.Errorf("telegram %s failed: %s", method, envelope.Description)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(envelope.Result, result); err != nil {
+			return fmt.Errorf("failed to decode telegram %s result: %w", method, err)
+		}
+	}
+
+	return nil
+}
+
+// rehostFile downloads a Telegram file and stores it in our media bucket
+func (t *TelegramService) rehostFile(ctx context.Context, file *models.TelegramFile, mediaType string) (string, error) {
+	if file.FileSize > telegramMaxDownloadBytes {
+		return "", fmt.Errorf("telegram file of %d bytes exceeds the bot API download limit", file.FileSize)
+	}
+
+	var info struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := t.call(ctx, "getFile", map[string]interface{}{"file_id": file.FileID}, &info); err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/file/bot%s/%s", t.apiURL, t.token, info.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create telegram file request: %w", err)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("telegram file download failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telegram file download returned status %d", resp.StatusCode)
+	}
+
+	filename := file.FileName
+	if filename == "" {
+		filename = path.Base(info.FilePath)
+	}
+
+	return t.mediaService.UploadMedia(ctx, io.LimitReader(resp.Body, telegramMaxDownloadBytes), filename, mediaType)
+}
+
+// telegramDisplayName builds a profile name from a Telegram user
+func telegramDisplayName(user *models.TelegramUser) string {
+	if user == nil {
+		return ""
+	}
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	return name
+}
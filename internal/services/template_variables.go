@@ -0,0 +1,172 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// ErrInvalidTemplateVariable is returned for template variables that are too long or
+// link to a host that is not allowed
+var ErrInvalidTemplateVariable = errors.New("invalid template variable")
+
+var templateVariablesRejectedTotal = metrics.NewCounter("template_variables_rejected_total", "Template variables rejected before sending, by reason", "reason")
+
+// templateLinkPattern finds links in variable values: anything with a scheme, and
+// www. hosts, which WhatsApp turns into links as well
+var templateLinkPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)[^\s<>"']+`)
+
+// TemplateSanitizer cleans the variables of outbound templates, which are often built
+// from user input, so they cannot break the template or smuggle in links. Values lose
+// control and invisible formatting characters (bidi overrides, zero-width spaces) and
+// have their whitespace collapsed, since WhatsApp rejects newlines, tabs and runs of
+// spaces in variables. Values over their length limit, and links to hosts outside
+// TEMPLATE_URL_ALLOWED_HOSTS, are rejected rather than cut.
+type TemplateSanitizer struct {
+	maxLength    int
+	maxLengths   map[string]int
+	allowedHosts []string
+}
+
+// NewTemplateSanitizer creates a template sanitizer from the template variable
+// configuration. The link shortener's host is allowed while link tracking is on.
+func NewTemplateSanitizer(cfg *config.Config) *TemplateSanitizer {
+	maxLengths := make(map[string]int, len(cfg.TemplateVariableMaxLengths))
+	for name, value := range cfg.TemplateVariableMaxLengths {
+		// Validate already rejected lengths that do not parse
+		if n, err := strconv.Atoi(value); err == nil {
+			maxLengths[name] = n
+		}
+	}
+
+	var hosts []string
+	for _, host := range cfg.TemplateURLAllowedHosts {
+		if host = allowedHost(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	if cfg.LinkTrackingEnabled {
+		if host := allowedHost(cfg.LinkShortenerDomain); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return &TemplateSanitizer{
+		maxLength:    cfg.TemplateVariableMaxLength,
+		maxLengths:   maxLengths,
+		allowedHosts: hosts,
+	}
+}
+
+// Sanitize returns the cleaned variables, or an ErrInvalidTemplateVariable listing
+// every variable that cannot be sent
+func (t *TemplateSanitizer) Sanitize(variables map[string]string) (map[string]string, error) {
+	if len(variables) == 0 {
+		return variables, nil
+	}
+
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cleaned := make(map[string]string, len(variables))
+	var problems []string
+	for _, name := range names {
+		value := cleanTemplateValue(variables[name])
+		cleaned[name] = value
+
+		limit := t.maxLength
+		if n, ok := t.maxLengths[name]; ok {
+			limit = n
+		}
+		if length := utf8.RuneCountInString(value); length > limit {
+			templateVariablesRejectedTotal.Inc("too_long")
+			problems = append(problems, fmt.Sprintf("%q is %d characters, at most %d are allowed", name, length, limit))
+		}
+
+		for _, link := range templateLinkPattern.FindAllString(value, -1) {
+			if !t.linkAllowed(link) {
+				templateVariablesRejectedTotal.Inc("link")
+				problems = append(problems, fmt.Sprintf("%q links to %s, which is not an allowed host", name, link))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTemplateVariable, strings.Join(problems, "; "))
+	}
+	return cleaned, nil
+}
+
+// Helper methods
+
+// linkAllowed reports whether a link found in a variable is an http(s) link to an
+// allowed host or one of its subdomains
+func (t *TemplateSanitizer) linkAllowed(link string) bool {
+	if strings.HasPrefix(strings.ToLower(link), "www.") {
+		link = "https://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.User != nil {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	for _, allowed := range t.allowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedHost normalizes an allowlist entry, which may be a bare host, a *.host
+// wildcard or a URL, to a lowercase host
+func allowedHost(entry string) string {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if strings.Contains(entry, "://") {
+		parsed, err := url.Parse(entry)
+		if err != nil {
+			return ""
+		}
+		entry = parsed.Hostname()
+	}
+	return strings.Trim(strings.TrimPrefix(entry, "*."), ".")
+}
+
+// cleanTemplateValue drops control and invisible formatting characters and collapses
+// whitespace, including newlines and tabs, to single spaces
+func cleanTemplateValue(value string) string {
+	var b strings.Builder
+	b.Grow(len(value))
+
+	space := false
+	for _, r := range value {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r == '\u200d':
+			// The zero-width joiner holds emoji sequences together
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
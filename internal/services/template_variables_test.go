@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+)
+
+func TestTemplateSanitizer(t *testing.T) {
+	sanitizer := NewTemplateSanitizer(&config.Config{
+		TemplateVariableMaxLength:  30,
+		TemplateVariableMaxLengths: map[string]string{"code": "6"},
+		TemplateURLAllowedHosts:    []string{"re9.ai", "*.example.com", "https://Shop.Test/path"},
+		LinkTrackingEnabled:        true,
+		LinkShortenerDomain:        "https://go.re9.link",
+	})
+
+	tests := []struct {
+		name     string
+		variable string
+		value    string
+		want     string
+		wantErr  bool
+	}{
+		{"plain text", "name", "Ana", "Ana", false},
+		{"collapses whitespace", "name", "  Ana\n\tMaria  ", "Ana Maria", false},
+		{"drops invisible characters", "name", "An\u200ba\u202e\x07", "Ana", false},
+		{"keeps emoji joiners", "name", "\U0001F469\u200d\U0001F4BB", "\U0001F469\u200d\U0001F4BB", false},
+		{"too long", "name", strings.Repeat("a", 31), "", true},
+		{"per-variable limit", "code", "1234567", "", true},
+		{"counts characters not bytes", "name", strings.Repeat("é", 30), strings.Repeat("é", 30), false},
+		{"allowed host", "link", "https://re9.ai/x", "https://re9.ai/x", false},
+		{"allowed subdomain", "link", "see www.app.re9.ai", "see www.app.re9.ai", false},
+		{"wildcard entry", "link", "http://a.example.com", "http://a.example.com", false},
+		{"url entry", "link", "https://shop.test/a", "https://shop.test/a", false},
+		{"shortener host", "link", "https://go.re9.link/abc", "https://go.re9.link/abc", false},
+		{"other host", "link", "https://evil.test", "", true},
+		{"lookalike suffix", "link", "https://notre9.ai", "", true},
+		{"userinfo", "link", "https://re9.ai@evil.test", "", true},
+		{"other scheme", "link", "ftp://re9.ai/x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizer.Sanitize(map[string]string{tt.variable: tt.value})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sanitize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTemplateVariable) {
+					t.Errorf("Sanitize() error = %v, want %v", err, ErrInvalidTemplateVariable)
+				}
+				return
+			}
+			if got[tt.variable] != tt.want {
+				t.Errorf("Sanitize() = %q, want %q", got[tt.variable], tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSanitizerReportsEveryVariable(t *testing.T) {
+	sanitizer := NewTemplateSanitizer(&config.Config{TemplateVariableMaxLength: 5})
+
+	_, err := sanitizer.Sanitize(map[string]string{"a": "too long", "b": "www.evil.test", "c": "ok"})
+	if err == nil {
+		t.Fatal("Sanitize() error = nil, want an error")
+	}
+	for _, want := range []string{`"a" is 8 characters`, `"b" links to www.evil.test`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Sanitize() error = %q, want it to mention %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), `"c"`) {
+		t.Errorf("Sanitize() error = %q mentions a valid variable", err)
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// replyQuery finds the first outbound message of a session sent after an inbound
+// message and before the user's next message. The inbound timestamp bounds the scan to
+// the partitions from there on.
+var replyQuery = `
+	SELECT` + messageColumns + `
+	FROM whatsapp_messages
+	WHERE session_id = $1 AND direction = 'outbound' AND timestamp >= $2 AND id <> $3
+		AND timestamp < COALESCE((
+			SELECT MIN(timestamp) FROM whatsapp_messages
+			WHERE session_id = $1 AND direction = 'inbound' AND timestamp > $2
+		), 'infinity')
+	ORDER BY timestamp
+	LIMIT 1`
+
+// TraceService assembles the processing timeline of a message from the tables each
+// pipeline step writes to, for debugging a single message end to end. Steps whose
+// records expired, such as recorded webhooks or processed outbox entries, are left out.
+type TraceService struct {
+	db             *pgxpool.Pool
+	messageService *MessageService
+	logger         *logrus.Logger
+}
+
+// NewTraceService creates a new trace service
+func NewTraceService(db *pgxpool.Pool, messageService *MessageService, logger *logrus.Logger) *TraceService {
+	return &TraceService{
+		db:             db,
+		messageService: messageService,
+		logger:         logger,
+	}
+}
+
+// Trace returns the timeline of a message: the webhook it was parsed from, its storage,
+// media pipeline stages, AI results, orchestrator calls, panics, status callbacks and,
+// for inbound messages, the reply sent to it with the reply's status callbacks
+func (s *TraceService) Trace(ctx context.Context, messageID uuid.UUID) (*models.MessageTrace, error) {
+	message, err := s.messageService.GetMessage(ctx, messageID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	trace := &models.MessageTrace{
+		MessageID: message.ID,
+		Direction: message.Direction,
+		Status:    message.Status,
+		Events:    []*models.TraceEvent{},
+	}
+
+	if message.WebhookEventID != nil {
+		if err := s.addWebhookEvent(ctx, trace, *message.WebhookEventID); err != nil {
+			return nil, err
+		}
+	}
+	trace.Events = append(trace.Events, &models.TraceEvent{
+		Stage:  models.TraceStageStored,
+		Status: string(message.Direction),
+		Detail: string(message.Type),
+		At:     message.CreatedAt,
+	})
+
+	steps := []func(context.Context, *models.MessageTrace, uuid.UUID) error{
+		s.addMediaJobs,
+		s.addAIResults,
+		s.addOutboxEntries,
+		s.addDeadLetters,
+	}
+	for _, step := range steps {
+		if err := step(ctx, trace, message.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.addStatusEvents(ctx, trace, message.ID, nil); err != nil {
+		return nil, err
+	}
+
+	if message.Direction == models.MessageDirectionInbound && message.SessionID != nil {
+		reply, err := s.findReply(ctx, message)
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil {
+			trace.ReplyMessageID = &reply.ID
+			trace.Events = append(trace.Events, &models.TraceEvent{
+				Stage:     models.TraceStageReplyStored,
+				Status:    string(reply.Status),
+				Detail:    string(reply.Type),
+				At:        reply.CreatedAt,
+				MessageID: &reply.ID,
+			})
+			if err := s.addStatusEvents(ctx, trace, reply.ID, &reply.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(trace.Events, func(i, j int) bool {
+		return trace.Events[i].At.Before(trace.Events[j].At)
+	})
+
+	return trace, nil
+}
+
+// addWebhookEvent adds the recorded webhook request the message was parsed from, if it
+// has not expired
+func (s *TraceService) addWebhookEvent(ctx context.Context, trace *models.MessageTrace, eventID uuid.UUID) error {
+	var source string
+	var statusCode int
+	var receivedAt time.Time
+	err := s.db.QueryRow(ctx, `
+		SELECT source, status_code, received_at FROM webhook_events WHERE id = $1`,
+		eventID).Scan(&source, &statusCode, &receivedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get trace webhook event: %w", err)
+	}
+
+	trace.Events = append(trace.Events, &models.TraceEvent{
+		Stage:  models.TraceStageWebhookReceived,
+		Status: fmt.Sprint(statusCode),
+		Detail: source,
+		At:     receivedAt,
+	})
+	return nil
+}
+
+// addMediaJobs adds the media pipeline stages that started, timed from start to
+// completion
+func (s *TraceService) addMediaJobs(ctx context.Context, trace *models.MessageTrace, messageID uuid.UUID) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT stage, status, error, started_at, completed_at, updated_at
+		FROM media_processing_jobs
+		WHERE message_id = $1 AND (started_at IS NOT NULL OR completed_at IS NOT NULL)
+		ORDER BY attachment_index, created_at`, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get trace media jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var job models.MediaProcessingJob
+		if err := rows.Scan(&job.Stage, &job.Status, &job.Error, &job.StartedAt, &job.CompletedAt, &job.UpdatedAt); err != nil {
+			return fmt.Errorf("failed to scan trace media job: %w", err)
+		}
+
+		event := &models.TraceEvent{
+			Stage:  models.TraceStageMedia,
+			Status: job.Status,
+			Detail: job.Stage,
+			Error:  job.Error,
+			At:     job.UpdatedAt,
+		}
+		if job.CompletedAt != nil {
+			event.At = *job.CompletedAt
+			if job.StartedAt != nil {
+				event.DurationMS = traceDuration(*job.StartedAt, *job.CompletedAt)
+			}
+		}
+		trace.Events = append(trace.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get trace media jobs: %w", err)
+	}
+	return nil
+}
+
+// addAIResults adds the analysis results posted back for the message
+func (s *TraceService) addAIResults(ctx context.Context, trace *models.MessageTrace, messageID uuid.UUID) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT analysis_type, status, error, received_at
+		FROM ai_results
+		WHERE message_id = $1
+		ORDER BY received_at`, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get trace AI results: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := &models.TraceEvent{Stage: models.TraceStageAIResult}
+		if err := rows.Scan(&event.Detail, &event.Status, &event.Error, &event.At); err != nil {
+			return fmt.Errorf("failed to scan trace AI result: %w", err)
+		}
+		trace.Events = append(trace.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get trace AI results: %w", err)
+	}
+	return nil
+}
+
+// addOutboxEntries adds the orchestrator work queued for the message. Processed entries
+// are timed from queueing; pending entries with attempts report the last error.
+func (s *TraceService) addOutboxEntries(ctx context.Context, trace *models.MessageTrace, messageID uuid.UUID) error {
+	rows, err := s.db.Query(ctx, `SELECT`+outboxColumns+`
+		FROM outbox
+		WHERE message_id = $1
+		ORDER BY created_at`, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get trace outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan trace outbox entry: %w", err)
+		}
+
+		event := &models.TraceEvent{
+			Stage:  models.TraceStageOrchestrator,
+			Status: entry.Status,
+			Detail: entry.Kind,
+			Error:  entry.LastError,
+			At:     entry.UpdatedAt,
+		}
+		if entry.ProcessedAt != nil {
+			event.At = *entry.ProcessedAt
+			event.DurationMS = traceDuration(entry.CreatedAt, *entry.ProcessedAt)
+		}
+		trace.Events = append(trace.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get trace outbox entries: %w", err)
+	}
+	return nil
+}
+
+// addDeadLetters adds the pipeline stages that panicked on the message
+func (s *TraceService) addDeadLetters(ctx context.Context, trace *models.MessageTrace, messageID uuid.UUID) error {
+	rows, err := s.db.Query(ctx, `
+		SELECT stage, error, created_at
+		FROM dead_letters
+		WHERE message_id = $1
+		ORDER BY created_at`, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get trace dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var errorText string
+		event := &models.TraceEvent{Stage: models.TraceStageDeadLetter}
+		if err := rows.Scan(&event.Detail, &errorText, &event.At); err != nil {
+			return fmt.Errorf("failed to scan trace dead letter: %w", err)
+		}
+		event.Error = &errorText
+		trace.Events = append(trace.Events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get trace dead letters: %w", err)
+	}
+	return nil
+}
+
+// addStatusEvents adds the status callbacks of a message; reply marks them as belonging
+// to the reply
+func (s *TraceService) addStatusEvents(ctx context.Context, trace *models.MessageTrace, messageID uuid.UUID, reply *uuid.UUID) error {
+	history, err := s.messageService.GetStatusHistory(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get trace status history: %w", err)
+	}
+
+	for _, status := range history.Events {
+		trace.Events = append(trace.Events, &models.TraceEvent{
+			Stage:     models.TraceStageStatus,
+			Status:    string(status.Status),
+			Detail:    status.ProviderStatus,
+			Error:     status.ErrorMessage,
+			At:        status.ReceivedAt,
+			MessageID: reply,
+		})
+	}
+	return nil
+}
+
+// findReply returns the reply sent to an inbound message, or nil if there is none yet
+func (s *TraceService) findReply(ctx context.Context, message *models.WhatsAppMessage) (*models.WhatsAppMessage, error) {
+	var reply models.WhatsAppMessage
+	if err := scanMessageInto(s.db.QueryRow(ctx, replyQuery, *message.SessionID, message.Timestamp, message.ID), &reply); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find reply: %w", err)
+	}
+	return &reply, nil
+}
+
+// traceDuration returns the milliseconds between start and end
+func traceDuration(start, end time.Time) *int64 {
+	ms := end.Sub(start).Milliseconds()
+	return &ms
+}
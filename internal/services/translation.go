@@ -0,0 +1,228 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+var translationsTotal = metrics.NewCounter("translations_total", "Messages run through the translation stage, by direction and outcome", "direction", "outcome")
+
+// Translation is a text in the target language and the language it was written in
+type Translation struct {
+	Text           string
+	SourceLanguage string
+}
+
+// TranslationProvider translates text between languages. An empty source asks the
+// provider to detect the language, which it reports in the translation.
+type TranslationProvider interface {
+	Translate(ctx context.Context, text, source, target string) (*Translation, error)
+}
+
+// LibreTranslate calls a LibreTranslate-compatible /translate endpoint
+type LibreTranslate struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewLibreTranslate creates a LibreTranslate provider from the translation configuration
+func NewLibreTranslate(cfg *config.Config) *LibreTranslate {
+	return &LibreTranslate{
+		baseURL:    strings.TrimSuffix(cfg.TranslationURL, "/"),
+		apiKey:     cfg.TranslationAPIKey,
+		httpClient: newHTTPClient(cfg.TranslationTimeout),
+	}
+}
+
+// Translate translates text to target, detecting its language when source is empty
+func (l *LibreTranslate) Translate(ctx context.Context, text, source, target string) (*Translation, error) {
+	if source == "" {
+		source = "auto"
+	}
+	body, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  source,
+		"target":  target,
+		"format":  "text",
+		"api_key": l.apiKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal translate request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.baseURL+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translate request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "re9ai-whatsapp-adapter/1.0")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call translation provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("translation provider returned status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		TranslatedText   string `json:"translatedText"`
+		DetectedLanguage *struct {
+			Language string `json:"language"`
+		} `json:"detectedLanguage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode translation response: %w", err)
+	}
+
+	translation := &Translation{Text: response.TranslatedText, SourceLanguage: source}
+	if response.DetectedLanguage != nil {
+		translation.SourceLanguage = response.DetectedLanguage.Language
+	}
+	return translation, nil
+}
+
+// TranslationService bridges conversations with users who write in another language
+// than the bot. Inbound text is translated to TRANSLATION_WORKING_LANGUAGE before
+// anything reads it, and the language it was written in is remembered on the user;
+// text replies to that user are translated back. Translated messages keep the text as
+// written in original_content. A failing provider never blocks a message: it travels
+// untranslated.
+type TranslationService struct {
+	provider        TranslationProvider
+	db              *pgxpool.Pool
+	identityService *IdentityService
+	workingLanguage string
+	logger          *logrus.Logger
+}
+
+// NewTranslationService creates a translation service on a provider
+func NewTranslationService(provider TranslationProvider, db *pgxpool.Pool, identityService *IdentityService, cfg *config.Config, logger *logrus.Logger) *TranslationService {
+	return &TranslationService{
+		provider:        provider,
+		db:              db,
+		identityService: identityService,
+		workingLanguage: baseLanguage(cfg.TranslationWorkingLanguage),
+		logger:          logger,
+	}
+}
+
+// TranslateInbound translates the text of an inbound message to the working language,
+// keeping the original, and records the language its sender writes in
+func (s *TranslationService) TranslateInbound(ctx context.Context, message *models.WhatsAppMessage) {
+	if !normalizedTypes[message.Type] || countTextChars(message.Content) == 0 {
+		return
+	}
+
+	translation, err := s.provider.Translate(ctx, message.Content, "", s.workingLanguage)
+	if err != nil {
+		translationsTotal.Inc("inbound", "failed")
+		s.logger.WithError(err).WithField("message_id", message.ID).Warn("Failed to translate inbound message")
+		return
+	}
+
+	language := baseLanguage(translation.SourceLanguage)
+	if message.UserID != nil && language != "" {
+		s.rememberLanguage(ctx, *message.UserID, language)
+	}
+
+	if language == "" || language == s.workingLanguage || translation.Text == "" {
+		translationsTotal.Inc("inbound", "unchanged")
+		return
+	}
+
+	original := message.Content
+	message.OriginalContent = &original
+	message.Language = &language
+	message.Content = translation.Text
+	translationsTotal.Inc("inbound", "translated")
+}
+
+// TranslateOutbound translates a reply to the language the recipient writes in. It
+// returns the text to send and, when it was translated, the recipient's language.
+func (s *TranslationService) TranslateOutbound(ctx context.Context, channel models.Channel, recipient, text string) (string, string) {
+	if countTextChars(text) == 0 {
+		return text, ""
+	}
+
+	language, err := s.recipientLanguage(ctx, channel, recipient)
+	if err != nil {
+		s.logger.WithError(err).WithField("to", recipient).Warn("Failed to look up recipient language")
+		return text, ""
+	}
+	if language == "" || language == s.workingLanguage {
+		return text, ""
+	}
+
+	translation, err := s.provider.Translate(ctx, text, s.workingLanguage, language)
+	if err != nil || translation.Text == "" {
+		translationsTotal.Inc("outbound", "failed")
+		s.logger.WithError(err).WithField("to", recipient).Warn("Failed to translate outbound message; sending it untranslated")
+		return text, ""
+	}
+
+	translationsTotal.Inc("outbound", "translated")
+	return translation.Text, language
+}
+
+// Helper methods
+
+// recipientLanguage returns the language recorded for the user behind a recipient, or
+// an empty string when none is known
+func (s *TranslationService) recipientLanguage(ctx context.Context, channel models.Channel, recipient string) (string, error) {
+	var userID uuid.UUID
+	var err error
+	if kind, ok := models.IdentityKindForChannel(channel); ok {
+		userID, err = s.identityService.LookupChannelUserID(ctx, kind, recipient)
+	} else {
+		userID, err = s.identityService.LookupUserID(ctx, recipient)
+	}
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var language string
+	err = s.db.QueryRow(ctx, `SELECT COALESCE(language, '') FROM whatsapp_users WHERE id = $1`, userID).Scan(&language)
+	if err != nil {
+		return "", fmt.Errorf("failed to load user language: %w", err)
+	}
+	return language, nil
+}
+
+// rememberLanguage records the language a user last wrote in
+func (s *TranslationService) rememberLanguage(ctx context.Context, userID uuid.UUID, language string) {
+	_, err := s.db.Exec(ctx, `
+		UPDATE whatsapp_users SET language = $2, updated_at = NOW()
+		WHERE id = $1 AND language IS DISTINCT FROM $2`, userID, language)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to record user language")
+	}
+}
+
+// baseLanguage reduces a language tag to its lowercase language, e.g. "pt-BR" to "pt"
+func baseLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/twilio/twilio-go"
+	twilioClient "github.com/twilio/twilio-go/client"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// maxTwilioErrorBody bounds the error response body kept per recorded call
+const maxTwilioErrorBody = 4096
+
+// Twilio API call metrics
+var (
+	twilioRequestsTotal   = metrics.NewCounter("twilio_api_requests_total", "Requests to the Twilio REST API", "method", "status")
+	twilioRequestDuration = metrics.NewCounter("twilio_api_request_seconds_total", "Total time spent in Twilio REST API requests", "method")
+)
+
+// TwilioCallLog keeps the most recent Twilio REST API calls in a ring buffer. Failed
+// calls are always recorded; successful ones are sampled.
+type TwilioCallLog struct {
+	sampleRate float64
+	logger     *logrus.Logger
+
+	mu     sync.Mutex
+	calls  []models.TwilioAPICall
+	next   int
+	lastID uint64
+}
+
+// NewTwilioCallLog creates a call log sized and sampled by configuration
+func NewTwilioCallLog(cfg *config.Config, logger *logrus.Logger) *TwilioCallLog {
+	size := cfg.TwilioCallLogSize
+	if size <= 0 {
+		size = 1
+	}
+
+	return &TwilioCallLog{
+		sampleRate: cfg.TwilioCallLogSampleRate,
+		logger:     logger,
+		calls:      make([]models.TwilioAPICall, 0, size),
+	}
+}
+
+// Recent returns recorded calls, newest first, optionally only failed ones
+func (l *TwilioCallLog) Recent(limit int, failedOnly bool) []models.TwilioAPICall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	calls := []models.TwilioAPICall{}
+	for i := 0; i < len(l.calls) && len(calls) < limit; i++ {
+		index := (l.next - 1 - i + len(l.calls)) % len(l.calls)
+		call := l.calls[index]
+		if failedOnly && call.Error == "" && call.StatusCode < 400 {
+			continue
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// record adds a call, overwriting the oldest once the buffer is full
+func (l *TwilioCallLog) record(call models.TwilioAPICall) {
+	l.mu.Lock()
+	l.lastID++
+	call.ID = l.lastID
+	if len(l.calls) < cap(l.calls) {
+		l.calls = append(l.calls, call)
+	} else {
+		l.calls[l.next] = call
+	}
+	l.next = (l.next + 1) % cap(l.calls)
+	l.mu.Unlock()
+
+	entry := l.logger.WithFields(logrus.Fields{
+		"method":            call.Method,
+		"url":               call.URL,
+		"status_code":       call.StatusCode,
+		"twilio_request_id": call.TwilioRequestID,
+		"duration_ms":       call.DurationMs,
+	})
+	if call.Error != "" || call.StatusCode >= 400 {
+		entry.WithFields(logrus.Fields{
+			"error":      call.Error,
+			"error_body": call.ErrorBody,
+		}).Warn("Twilio API call failed")
+	} else {
+		entry.Debug("Twilio API call")
+	}
+}
+
+// newTwilioClient creates a Twilio REST client whose requests go through the call log
+func newTwilioClient(accountSID, authToken string, calls *TwilioCallLog) *twilio.RestClient {
+	base := &twilioClient.Client{
+		Credentials: twilioClient.NewCredentials(accountSID, authToken),
+		HTTPClient: &http.Client{
+			// Like the SDK default client: return redirects instead of following them
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Timeout:   10 * time.Second,
+			Transport: &twilioTransport{base: httpTransport(), calls: calls},
+		},
+	}
+	base.SetAccountSid(accountSID)
+
+	return twilio.NewRestClientWithParams(twilio.ClientParams{Client: base})
+}
+
+// twilioTransport measures and records Twilio API requests
+type twilioTransport struct {
+	base  http.RoundTripper
+	calls *TwilioCallLog
+}
+
+// RoundTrip sends the request and records its metadata
+func (t *twilioTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(started)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	twilioRequestsTotal.Inc(req.Method, status)
+	twilioRequestDuration.Add(duration.Seconds(), req.Method)
+
+	if t.calls == nil {
+		return resp, err
+	}
+
+	failed := err != nil || resp.StatusCode >= 400
+	if !failed && rand.Float64() >= t.calls.sampleRate {
+		return resp, err
+	}
+
+	call := models.TwilioAPICall{
+		Method:     req.Method,
+		URL:        redactedURL(req.URL),
+		Params:     requestParamNames(req),
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		StartedAt:  started,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.StatusCode = resp.StatusCode
+		call.TwilioRequestID = resp.Header.Get("Twilio-Request-Id")
+		if failed {
+			call.ErrorBody = peekBody(resp)
+		}
+	}
+	t.calls.record(call)
+
+	return resp, err
+}
+
+// redactedURL drops user info and the query, whose values may identify recipients
+func redactedURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	return redacted.String()
+}
+
+// requestParamNames lists the query and form parameter names of a request without
+// consuming its body
+func requestParamNames(req *http.Request) []string {
+	params := req.URL.Query()
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			raw, err := io.ReadAll(io.LimitReader(body, 64*1024))
+			body.Close()
+			if err == nil {
+				if form, err := url.ParseQuery(string(raw)); err == nil {
+					for name, values := range form {
+						params[name] = values
+					}
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// peekBody reads the start of a response body and leaves the body readable for the SDK
+func peekBody(resp *http.Response) string {
+	head, err := io.ReadAll(io.LimitReader(resp.Body, maxTwilioErrorBody))
+	if err != nil {
+		return ""
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+
+	return string(head)
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// e164Pattern matches a normalized E.164 phone number
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// numericIDPattern matches Messenger, Instagram and Telegram recipient IDs
+var numericIDPattern = regexp.MustCompile(`^-?[0-9]+$`)
+
+// maxContentLength is the longest message body each channel accepts, in characters
+var maxContentLength = map[models.Channel]int{
+	models.ChannelWhatsApp:  1600,
+	models.ChannelSMS:       1600,
+	models.ChannelMessenger: 2000,
+	models.ChannelInstagram: 1000,
+	models.ChannelTelegram:  4096,
+}
+
+// windowedChannels only allow free-form messages within 24 hours of the user's last message
+var windowedChannels = map[models.Channel]bool{
+	models.ChannelWhatsApp:  true,
+	models.ChannelMessenger: true,
+	models.ChannelInstagram: true,
+}
+
+// TemplateInspector is implemented by providers that can describe a content template
+type TemplateInspector interface {
+	TemplateVariables(ctx context.Context, templateSID string) ([]string, error)
+}
+
+// SendValidationService runs every pre-send check on a message without sending it
+type SendValidationService struct {
+	channels           *ChannelProviders
+	sessionService     *SessionService
+	suppressionService *SuppressionService
+	dedupService       *OutboundDedupService
+	notificationCaps   *NotificationCapService
+	mediaService       *MediaService
+	templateSanitizer  *TemplateSanitizer
+	logger             *logrus.Logger
+}
+
+// NewSendValidationService creates a new send validation service
+func NewSendValidationService(
+	channels *ChannelProviders,
+	sessionService *SessionService,
+	suppressionService *SuppressionService,
+	dedupService *OutboundDedupService,
+	notificationCaps *NotificationCapService,
+	mediaService *MediaService,
+	templateSanitizer *TemplateSanitizer,
+	logger *logrus.Logger,
+) *SendValidationService {
+	return &SendValidationService{
+		channels:           channels,
+		sessionService:     sessionService,
+		suppressionService: suppressionService,
+		dedupService:       dedupService,
+		notificationCaps:   notificationCaps,
+		mediaService:       mediaService,
+		templateSanitizer:  templateSanitizer,
+		logger:             logger,
+	}
+}
+
+// Validate checks whether a send request would be accepted and delivered: channel and
+// provider, recipient format, suppression list, duplicate suppression, proactive message
+// caps, the customer service window, template variables, content policy and direct media uploads. Every check runs, so the
+// verdict lists all problems at once.
+func (v *SendValidationService) Validate(ctx context.Context, request *models.SendMessageRequest) *models.SendValidationResult {
+	channel := request.Channel
+	if channel == "" {
+		channel = models.ChannelWhatsApp
+	}
+
+	result := &models.SendValidationResult{
+		Valid:     true,
+		Channel:   channel,
+		Recipient: normalizeRecipient(channel, request.To),
+	}
+
+	add := func(name string, err error) {
+		check := models.ValidationCheck{Name: name, Passed: err == nil}
+		if err != nil {
+			check.Reason = err.Error()
+			result.Valid = false
+		}
+		result.Checks = append(result.Checks, check)
+	}
+	skip := func(name, reason string) {
+		result.Checks = append(result.Checks, models.ValidationCheck{Name: name, Skipped: true, Reason: reason})
+	}
+
+	// Channel and provider
+	var provider MessagingProvider
+	var err error
+	if request.Provider != "" {
+		provider, err = v.channels.Named(channel, request.Provider)
+	} else {
+		provider, err = v.channels.For(channel)
+	}
+	if provider != nil {
+		result.Provider = provider.Name()
+	}
+	add(models.ValidationCheckChannel, err)
+
+	// Recipient format
+	add(models.ValidationCheckRecipient, validateRecipient(channel, request.To))
+
+	// Suppression list
+	suppression, err := v.suppressionService.Lookup(ctx, channel, request.To)
+	if err == nil && suppression != nil {
+		err = fmt.Errorf("recipient is suppressed")
+		if suppression.Reason != nil && *suppression.Reason != "" {
+			err = fmt.Errorf("recipient is suppressed: %s", *suppression.Reason)
+		}
+	}
+	add(models.ValidationCheckSuppression, err)
+
+	// Duplicate suppression
+	add(models.ValidationCheckDuplicate, v.dedupService.Check(ctx, request))
+
+	// Proactive message caps
+	if v.notificationCaps.Enabled() {
+		add(models.ValidationCheckNotificationCap, v.notificationCaps.Check(ctx, request))
+	} else {
+		skip(models.ValidationCheckNotificationCap, "no notification caps configured")
+	}
+
+	// Templates are only sent for types other than text and media
+	template := request.Template
+	switch request.Type {
+	case models.MessageTypeText, "", models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
+		template = nil
+	}
+
+	// Customer service window; templates may be sent outside it
+	if windowedChannels[channel] {
+		window, err := v.sessionService.CustomerServiceWindow(ctx, channel, request.To)
+		switch {
+		case err != nil:
+			add(models.ValidationCheckWindow, err)
+		case template != nil:
+			result.Window = window
+			skip(models.ValidationCheckWindow, "templates can be sent outside the window")
+		case !window.Open:
+			result.Window = window
+			add(models.ValidationCheckWindow, fmt.Errorf("customer service window is closed; send a template"))
+		default:
+			result.Window = window
+			add(models.ValidationCheckWindow, nil)
+		}
+	} else {
+		skip(models.ValidationCheckWindow, fmt.Sprintf("%s has no messaging window", channel))
+	}
+
+	// Template variables
+	if template != nil {
+		add(models.ValidationCheckTemplate, v.validateTemplate(ctx, channel, provider, *template, request.Variables))
+	} else {
+		skip(models.ValidationCheckTemplate, "not a template message")
+	}
+
+	// Content policy
+	add(models.ValidationCheckPolicy, validateContent(channel, request))
+
+	// Direct uploads must have arrived and match what was declared
+	if request.MediaURL != nil && *request.MediaURL != "" {
+		add(models.ValidationCheckMedia, v.mediaService.VerifyUpload(ctx, *request.MediaURL))
+	} else {
+		skip(models.ValidationCheckMedia, "no media")
+	}
+
+	v.logger.WithFields(logrus.Fields{
+		"channel": channel,
+		"to":      result.Recipient,
+		"valid":   result.Valid,
+	}).Debug("Validated send request")
+
+	return result
+}
+
+// Helper methods
+
+// validateTemplate checks that templates are supported, every variable has a value and
+// the values pass the template sanitizer
+func (v *SendValidationService) validateTemplate(ctx context.Context, channel models.Channel, provider MessagingProvider, templateSID string, variables map[string]string) error {
+	if channel != models.ChannelWhatsApp {
+		return fmt.Errorf("template messages are not supported on %s", channel)
+	}
+	if templateSID == "" {
+		return fmt.Errorf("template is empty")
+	}
+
+	variables, err := v.templateSanitizer.Sanitize(variables)
+	if err != nil {
+		return err
+	}
+
+	inspector, ok := provider.(TemplateInspector)
+	if !ok {
+		// Without a provider the channel check already failed
+		return nil
+	}
+
+	expected, err := inspector.TemplateVariables(ctx, templateSID)
+	if err != nil {
+		v.logger.WithError(err).WithField("template", templateSID).Warn("Failed to inspect template")
+		return fmt.Errorf("template could not be loaded")
+	}
+
+	var missing []string
+	for _, name := range expected {
+		if strings.TrimSpace(variables[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// validateRecipient checks that an address has the format the channel expects
+func validateRecipient(channel models.Channel, to string) error {
+	if strings.TrimSpace(to) == "" {
+		return fmt.Errorf("recipient is required")
+	}
+
+	switch channel {
+	case models.ChannelEmail:
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid email address")
+		}
+	case models.ChannelMessenger, models.ChannelInstagram, models.ChannelTelegram:
+		if !numericIDPattern.MatchString(strings.TrimSpace(to)) {
+			return fmt.Errorf("invalid %s recipient ID", channel)
+		}
+	default:
+		if !e164Pattern.MatchString(normalizePhoneNumber(to)) {
+			return fmt.Errorf("invalid phone number; expected E.164 format")
+		}
+	}
+
+	return nil
+}
+
+// validateContent applies the message type and length rules of the channel
+func validateContent(channel models.Channel, request *models.SendMessageRequest) error {
+	switch request.Type {
+	case models.MessageTypeText, "":
+		if strings.TrimSpace(request.Content) == "" {
+			return fmt.Errorf("content is required")
+		}
+	case models.MessageTypeImage, models.MessageTypeVideo, models.MessageTypeAudio, models.MessageTypeDocument:
+		if request.MediaURL == nil || *request.MediaURL == "" {
+			return fmt.Errorf("media URL required for media messages")
+		}
+	case models.MessageTypeLocationRequest:
+		if strings.TrimSpace(request.Content) == "" {
+			return fmt.Errorf("content is required")
+		}
+	default:
+		if request.Template == nil {
+			return fmt.Errorf("unsupported message type")
+		}
+	}
+
+	if limit, ok := maxContentLength[channel]; ok && utf8.RuneCountInString(request.Content) > limit {
+		return fmt.Errorf("content exceeds %d characters", limit)
+	}
+
+	return nil
+}
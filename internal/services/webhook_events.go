@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// ErrWebhookEventNotFound is returned when a recorded webhook does not exist
+var ErrWebhookEventNotFound = errors.New("webhook event not found")
+
+// maxWebhookEventBody bounds the stored body; larger bodies (email attachments) are cut
+const maxWebhookEventBody = 1 << 20
+
+// webhookEventsPruneInterval is how often expired webhook events are deleted
+const webhookEventsPruneInterval = time.Hour
+
+// redactedWebhookHeaders carry credentials and are never stored
+var redactedWebhookHeaders = map[string]bool{
+	"Authorization":                   true,
+	"Cookie":                          true,
+	"X-Telegram-Bot-Api-Secret-Token": true,
+}
+
+// webhookEventColumns lists the webhook_events columns in the order scanWebhookEvent expects
+const webhookEventColumns = `
+	id, source, method, path, headers, body, truncated, status_code, received_at`
+
+// WebhookEventService records raw webhook requests in webhook_events, so what a provider
+// actually sent can be inspected after the fact
+type WebhookEventService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+}
+
+// NewWebhookEventService creates a new webhook event service
+func NewWebhookEventService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *WebhookEventService {
+	return &WebhookEventService{
+		db:     db,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Enabled reports whether webhook requests are recorded
+func (s *WebhookEventService) Enabled() bool {
+	return s.config.WebhookEventsEnabled
+}
+
+// RecordWebhook stores a webhook request and the status it was answered with in the
+// background; it implements middleware.WebhookRecorder
+func (s *WebhookEventService) RecordWebhook(id uuid.UUID, source string, r *http.Request, body []byte, status int) {
+	event := &models.WebhookEvent{
+		ID:         id,
+		Source:     source,
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		Headers:    make(map[string]string, len(r.Header)),
+		Body:       body,
+		StatusCode: status,
+		ReceivedAt: time.Now(),
+	}
+	for name, values := range r.Header {
+		if !redactedWebhookHeaders[name] && len(values) > 0 {
+			event.Headers[name] = values[0]
+		}
+	}
+	if len(event.Body) > maxWebhookEventBody {
+		event.Body = event.Body[:maxWebhookEventBody]
+		event.Truncated = true
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_, err := s.db.Exec(ctx, `
+			INSERT INTO webhook_events (id, source, method, path, headers, body, truncated, status_code, received_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			event.ID, event.Source, event.Method, event.Path, event.Headers, event.Body,
+			event.Truncated, event.StatusCode, event.ReceivedAt,
+		)
+		if err != nil {
+			s.logger.WithError(err).WithField("source", source).Error("Failed to record webhook event")
+		}
+	}()
+}
+
+// Start deletes events older than the retention period every hour until ctx is done
+func (s *WebhookEventService) Start(ctx context.Context) {
+	ticker := time.NewTicker(webhookEventsPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tag, err := s.db.Exec(ctx, `DELETE FROM webhook_events WHERE received_at < $1`,
+				time.Now().Add(-s.config.WebhookEventsRetention))
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to prune webhook events")
+				continue
+			}
+			if tag.RowsAffected() > 0 {
+				s.logger.WithField("deleted", tag.RowsAffected()).Info("Pruned webhook events")
+			}
+		}
+	}
+}
+
+// ListEvents returns recorded webhooks, newest first, optionally from one source
+func (s *WebhookEventService) ListEvents(ctx context.Context, source string, limit, offset int) ([]*models.WebhookEvent, int64, error) {
+	query := `SELECT` + webhookEventColumns + ` FROM webhook_events
+		WHERE $1 = '' OR source = $1
+		ORDER BY received_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(ctx, query, source, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*models.WebhookEvent{}
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading webhook events: %w", err)
+	}
+
+	return events, estimateTotal(ctx, s.db, s.logger, query, source, limit, offset), nil
+}
+
+// GetEvent returns one recorded webhook
+func (s *WebhookEventService) GetEvent(ctx context.Context, id uuid.UUID) (*models.WebhookEvent, error) {
+	event, err := scanWebhookEvent(s.db.QueryRow(ctx, `SELECT`+webhookEventColumns+` FROM webhook_events WHERE id = $1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookEventNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook event: %w", err)
+	}
+	return event, nil
+}
+
+// GetMessageEvent returns the recorded webhook a message was parsed from. It returns
+// ErrMessageNotFound for unknown messages and ErrWebhookEventNotFound when the message
+// has no recorded webhook or the record expired.
+func (s *WebhookEventService) GetMessageEvent(ctx context.Context, messageID uuid.UUID) (*models.WebhookEvent, error) {
+	var eventID *uuid.UUID
+	err := s.db.QueryRow(ctx, `SELECT webhook_event_id FROM whatsapp_messages WHERE id = $1`, messageID).Scan(&eventID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("failed to get message webhook event: %w", err)
+	}
+	if eventID == nil {
+		return nil, ErrWebhookEventNotFound
+	}
+	return s.GetEvent(ctx, *eventID)
+}
+
+// scanWebhookEvent scans a webhook_events row selected with webhookEventColumns
+func scanWebhookEvent(row pgx.Row) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	err := row.Scan(
+		&event.ID,
+		&event.Source,
+		&event.Method,
+		&event.Path,
+		&event.Headers,
+		&event.Body,
+		&event.Truncated,
+		&event.StatusCode,
+		&event.ReceivedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
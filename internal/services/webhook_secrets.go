@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// webhookSecretUsageInterval is how often last-seen is written for a secret
+const webhookSecretUsageInterval = time.Minute
+
+// WebhookSecretService tracks which configured secret verifies incoming webhooks, so the
+// secondary secret of a rotation can be retired once nothing is signed with it anymore.
+// Usage is kept per secret fingerprint, so rotating again starts from a clean slate.
+type WebhookSecretService struct {
+	db     *pgxpool.Pool
+	config *config.Config
+	logger *logrus.Logger
+
+	mu         sync.Mutex
+	recordedAt map[string]time.Time // source/slot -> last write
+}
+
+// NewWebhookSecretService creates a new webhook secret service
+func NewWebhookSecretService(db *pgxpool.Pool, cfg *config.Config, logger *logrus.Logger) *WebhookSecretService {
+	return &WebhookSecretService{
+		db:         db,
+		config:     cfg,
+		logger:     logger,
+		recordedAt: make(map[string]time.Time),
+	}
+}
+
+// ObserveWebhookSecret notes that a webhook from source was verified with the secret in
+// slot. Writes are throttled to one per secret per minute, and so are the logs of the
+// secondary secret, which are informational during a rotation.
+func (s *WebhookSecretService) ObserveWebhookSecret(source, slot string) {
+	fingerprint := webhookSecretFingerprint(s.secret(slot))
+	if fingerprint == "" {
+		return
+	}
+
+	now := time.Now()
+	key := source + "/" + slot
+	s.mu.Lock()
+	record := now.Sub(s.recordedAt[key]) >= webhookSecretUsageInterval
+	if record {
+		s.recordedAt[key] = now
+	}
+	s.mu.Unlock()
+
+	logger := s.logger.WithFields(logrus.Fields{"source": source, "secret": slot, "fingerprint": fingerprint})
+	if record && slot == models.WebhookSecretSecondary {
+		logger.Info("Webhook signature verified with the secondary secret")
+	} else {
+		logger.Debug("Webhook signature verified")
+	}
+
+	if record {
+		go s.recordUsage(source, fingerprint, now)
+	}
+}
+
+// Report returns when webhooks from source signed with each configured secret were
+// first and last seen
+func (s *WebhookSecretService) Report(ctx context.Context, source string) (*models.WebhookSecretReport, error) {
+	report := &models.WebhookSecretReport{Source: source, Secrets: []*models.WebhookSecretUsage{}}
+
+	for _, slot := range []string{models.WebhookSecretPrimary, models.WebhookSecretSecondary} {
+		fingerprint := webhookSecretFingerprint(s.secret(slot))
+		if fingerprint == "" {
+			continue
+		}
+
+		usage := &models.WebhookSecretUsage{Slot: slot, Fingerprint: fingerprint}
+		err := s.db.QueryRow(ctx, `
+			SELECT first_seen_at, last_seen_at FROM webhook_secret_usage
+			WHERE source = $1 AND fingerprint = $2`, source, fingerprint).Scan(&usage.FirstSeenAt, &usage.LastSeenAt)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to load webhook secret usage: %w", err)
+		}
+		report.Secrets = append(report.Secrets, usage)
+	}
+
+	if len(report.Secrets) == 2 {
+		primary, secondary := report.Secrets[0].LastSeenAt, report.Secrets[1].LastSeenAt
+		report.Retirable = primary != nil && (secondary == nil || secondary.Before(*primary))
+	}
+	return report, nil
+}
+
+// Helper methods
+
+// secret returns the configured secret of a slot
+func (s *WebhookSecretService) secret(slot string) string {
+	if slot == models.WebhookSecretSecondary {
+		return s.config.WhatsAppWebhookSecondarySecret
+	}
+	return s.config.WhatsAppWebhookSecret
+}
+
+// recordUsage stores when webhooks signed with a secret were seen
+func (s *WebhookSecretService) recordUsage(source, fingerprint string, seenAt time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO webhook_secret_usage (source, fingerprint, first_seen_at, last_seen_at)
+		VALUES ($1, $2, $3, $3)
+		ON CONFLICT (source, fingerprint) DO UPDATE
+		SET last_seen_at = GREATEST(webhook_secret_usage.last_seen_at, EXCLUDED.last_seen_at)`,
+		source, fingerprint, seenAt)
+	if err != nil {
+		s.logger.WithError(err).WithField("source", source).Warn("Failed to record webhook secret usage")
+	}
+}
+
+// webhookSecretFingerprint identifies a secret without revealing it: the first 12 hex
+// digits of its SHA-256, or "" when it is not set
+func webhookSecretFingerprint(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +19,9 @@ import (
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
 )
 
+// twilioMediaPathPattern extracts the account, message and media SIDs from a Twilio media URL
+var twilioMediaPathPattern = regexp.MustCompile(`/Accounts/(AC[0-9a-fA-F]+)/Messages/((?:MM|SM)[0-9a-fA-F]+)/Media/(ME[0-9a-fA-F]+)`)
+
 // WhatsAppService handles WhatsApp message operations via Twilio
 type WhatsAppService struct {
 	client     *twilio.RestClient
@@ -25,11 +31,8 @@ type WhatsAppService struct {
 }
 
 // NewWhatsAppService creates a new WhatsApp service instance
-func NewWhatsAppService(cfg *config.Config, logger *logrus.Logger) *WhatsAppService {
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: cfg.TwilioAccountSID,
-		Password: cfg.TwilioAuthToken,
-	})
+func NewWhatsAppService(cfg *config.Config, twilioCalls *TwilioCallLog, logger *logrus.Logger) *WhatsAppService {
+	client := newTwilioClient(cfg.TwilioAccountSID, cfg.TwilioAuthToken, twilioCalls)
 
 	return &WhatsAppService{
 		client:     client,
@@ -63,7 +66,7 @@ func (w *WhatsAppService) SendTextMessage(ctx context.Context, to, content strin
 	response := &models.SendMessageResponse{
 		ID:        uuid.New(),
 		TwilioSID: *resp.Sid,
-		Status:    models.MessageStatusSent,
+		Status:    mapTwilioStatus(*resp.Status),
 		CreatedAt: time.Now(),
 	}
 
@@ -107,7 +110,7 @@ func (w *WhatsAppService) SendMediaMessage(ctx context.Context, to, content, med
 	response := &models.SendMessageResponse{
 		ID:        uuid.New(),
 		TwilioSID: *resp.Sid,
-		Status:    models.MessageStatusSent,
+		Status:    mapTwilioStatus(*resp.Status),
 		CreatedAt: time.Now(),
 	}
 
@@ -136,11 +139,11 @@ func (w *WhatsAppService) SendTemplateMessage(ctx context.Context, to, templateS
 
 	// Convert variables to Twilio format
 	if len(variables) > 0 {
-		contentVariables := make(map[string]interface{})
-		for k, v := range variables {
-			contentVariables[k] = v
+		contentVariables, err := json.Marshal(variables)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode template variables: %w", err)
 		}
-		params.SetContentVariables(contentVariables)
+		params.SetContentVariables(string(contentVariables))
 	}
 
 	resp, err := w.client.Api.CreateMessage(params)
@@ -152,7 +155,7 @@ func (w *WhatsAppService) SendTemplateMessage(ctx context.Context, to, templateS
 	response := &models.SendMessageResponse{
 		ID:        uuid.New(),
 		TwilioSID: *resp.Sid,
-		Status:    models.MessageStatusSent,
+		Status:    mapTwilioStatus(*resp.Status),
 		CreatedAt: time.Now(),
 	}
 
@@ -164,6 +167,50 @@ func (w *WhatsAppService) SendTemplateMessage(ctx context.Context, to, templateS
 	return response, nil
 }
 
+// SendFlowMessage sends a WhatsApp Flow; Twilio sends Flows as content templates
+func (w *WhatsAppService) SendFlowMessage(ctx context.Context, to, flowSID string, variables map[string]string) (*models.SendMessageResponse, error) {
+	return w.SendTemplateMessage(ctx, to, flowSID, variables)
+}
+
+// SendLocationRequest sends a content template that asks the user to share their
+// location, LOCATION_REQUEST_TEMPLATE_SID unless templateSID is given. The content fills
+// variable "1".
+func (w *WhatsAppService) SendLocationRequest(ctx context.Context, to, content, templateSID string) (*models.SendMessageResponse, error) {
+	if templateSID == "" {
+		templateSID = w.config.LocationRequestTemplateSID
+	}
+	if templateSID == "" {
+		return nil, ErrLocationRequestTemplate
+	}
+	return w.SendTemplateMessage(ctx, to, templateSID, map[string]string{"1": content})
+}
+
+// TemplateVariables returns the variable names a content template expects
+func (w *WhatsAppService) TemplateVariables(ctx context.Context, templateSID string) ([]string, error) {
+	return fetchTemplateVariables(w.client, templateSID)
+}
+
+// CancelMessage asks Twilio to cancel a message it has not sent yet
+func (w *WhatsAppService) CancelMessage(ctx context.Context, messageSID string) error {
+	return cancelTwilioMessage(w.client, messageSID)
+}
+
+// DeleteMedia deletes a media file Twilio stores for a message, given its media URL
+func (w *WhatsAppService) DeleteMedia(ctx context.Context, mediaURL string) error {
+	match := twilioMediaPathPattern.FindStringSubmatch(mediaURL)
+	if match == nil {
+		return fmt.Errorf("not a Twilio media URL")
+	}
+
+	params := &twilioApi.DeleteMediaParams{}
+	params.SetPathAccountSid(match[1])
+
+	if err := w.client.Api.DeleteMedia(match[2], match[3], params); err != nil {
+		return fmt.Errorf("failed to delete Twilio media: %w", err)
+	}
+	return nil
+}
+
 // ProcessIncomingMessage processes an incoming WhatsApp message from Twilio webhook
 func (w *WhatsAppService) ProcessIncomingMessage(webhookData *models.TwilioWebhookRequest) (*models.WhatsAppMessage, error) {
 	w.logger.WithFields(logrus.Fields{
@@ -184,6 +231,29 @@ func (w *WhatsAppService) ProcessIncomingMessage(webhookData *models.TwilioWebho
 		}
 	}
 
+	// Shared locations carry coordinates, and a place name and address when the user
+	// picked a place, instead of a body
+	content := webhookData.Body
+	if latitude, longitude, ok := parseCoordinates(webhookData.Latitude, webhookData.Longitude); ok {
+		messageType = models.MessageTypeLocation
+		content = FormatCoordinates(latitude, longitude)
+		if place := strings.TrimSpace(strings.Join([]string{webhookData.Label, webhookData.Address}, " ")); place != "" {
+			content += "\n" + place
+		}
+	}
+
+	// Flow submissions carry their answers as structured interactive data
+	var flowResponse *models.FlowResponse
+	if webhookData.InteractiveData != "" {
+		parsed, err := ParseFlowResponse(webhookData.InteractiveData)
+		if err != nil {
+			w.logger.WithError(err).WithField("message_sid", webhookData.MessageSid).Warn("Failed to parse flow response; storing as text")
+		} else if parsed != nil {
+			flowResponse = parsed
+			messageType = models.MessageTypeFlow
+		}
+	}
+
 	// Parse timestamp
 	timestamp := time.Now()
 	if webhookData.Timestamp != "" {
@@ -200,12 +270,23 @@ func (w *WhatsAppService) ProcessIncomingMessage(webhookData *models.TwilioWebho
 		Direction: models.MessageDirectionInbound,
 		Type:      messageType,
 		Status:    models.MessageStatusDelivered,
-		Content:   webhookData.Body,
+		Content:   content,
 		MediaURL:  mediaURL,
 		MediaType: mediaType,
 		Timestamp: timestamp,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		Channel:   models.ChannelWhatsApp,
+		FlowResponse: flowResponse,
+	}
+
+	// Quick reply taps carry the button text as body and the button ID as payload
+	message.ButtonPayload = webhookData.ButtonPayload
+
+	// Polls, live locations and the like arrive with an empty body; they are explained
+	// to the user rather than processed as text
+	if w.isUnsupportedType(webhookData.MessageType) {
+		message.UnsupportedType = strings.ToLower(webhookData.MessageType)
 	}
 
 	w.logger.WithFields(logrus.Fields{
@@ -224,12 +305,13 @@ func (w *WhatsAppService) ProcessStatusUpdate(webhookData *models.TwilioWebhookR
 		"status":      webhookData.SmsStatus,
 	}).Info("Processing WhatsApp message status update")
 
-	status := w.mapTwilioStatus(webhookData.SmsStatus)
+	status := mapTwilioStatus(webhookData.SmsStatus)
 	
 	update := &models.MessageStatusUpdate{
-		MessageSid: webhookData.MessageSid,
-		Status:     status,
-		Timestamp:  time.Now(),
+		MessageSid:     webhookData.MessageSid,
+		Status:         status,
+		Timestamp:      time.Now(),
+		ProviderStatus: webhookData.SmsStatus,
 	}
 
 	// Handle error cases
@@ -258,7 +340,7 @@ func (w *WhatsAppService) GetMessageStatus(ctx context.Context, messageSID strin
 		return models.MessageStatusFailed, fmt.Errorf("failed to fetch message status: %w", err)
 	}
 
-	status := w.mapTwilioStatus(*resp.Status)
+	status := mapTwilioStatus(*resp.Status)
 	
 	w.logger.WithFields(logrus.Fields{
 		"twilio_status": *resp.Status,
@@ -268,6 +350,57 @@ func (w *WhatsAppService) GetMessageStatus(ctx context.Context, messageSID strin
 	return status, nil
 }
 
+// ConversationEventToWebhook converts a Twilio Conversations event into the Programmable
+// Messaging webhook shape so both transports share one ingestion path. It returns nil
+// for events that are not inbound WhatsApp messages or delivery receipts.
+func (w *WhatsAppService) ConversationEventToWebhook(event *models.ConversationsWebhookRequest) *models.TwilioWebhookRequest {
+	switch event.EventType {
+	case models.ConversationEventMessageAdded:
+		// Messages we send are authored by the adapter, not a WhatsApp address
+		if !strings.HasPrefix(event.Author, "whatsapp:") {
+			return nil
+		}
+
+		webhookData := &models.TwilioWebhookRequest{
+			MessageSid: event.MessageSid,
+			From:       event.Author,
+			To:         w.fromNumber,
+			Body:       event.Body,
+			NumMedia:   "0",
+			Timestamp:  event.DateCreated,
+		}
+
+		var media []struct {
+			Sid         string `json:"Sid"`
+			ContentType string `json:"ContentType"`
+		}
+		if event.Media != "" && json.Unmarshal([]byte(event.Media), &media) == nil && len(media) > 0 {
+			webhookData.NumMedia = strconv.Itoa(len(media))
+			webhookData.MediaUrl0 = conversationMediaContentURL(event.ChatServiceSid, media[0].Sid)
+			webhookData.MediaContentType0 = media[0].ContentType
+		}
+
+		return webhookData
+
+	case models.ConversationEventDeliveryUpdated:
+		webhookData := &models.TwilioWebhookRequest{
+			MessageSid: event.MessageSid,
+			SmsStatus:  event.Status,
+		}
+		if event.ErrorCode != "" && event.ErrorCode != "0" {
+			webhookData.ErrorCode = event.ErrorCode
+		}
+		return webhookData
+	}
+
+	return nil
+}
+
+// Name identifies the provider
+func (w *WhatsAppService) Name() string {
+	return "twilio_messaging"
+}
+
 // GetFromNumber returns the configured WhatsApp from number
 func (w *WhatsAppService) GetFromNumber() string {
 	return w.fromNumber
@@ -313,10 +446,40 @@ func (w *WhatsAppService) determineMessageType(contentType string) models.Messag
 	}
 }
 
-// mapTwilioStatus maps Twilio status to our internal status
-func (w *WhatsAppService) mapTwilioStatus(twilioStatus string) models.MessageStatus {
+// parseCoordinates parses the Latitude and Longitude of a location webhook
+func parseCoordinates(latitude, longitude string) (float64, float64, bool) {
+	if latitude == "" || longitude == "" {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	lng, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return lat, lng, true
+}
+
+// isUnsupportedType reports whether a Twilio MessageType is in UNSUPPORTED_INBOUND_TYPES
+func (w *WhatsAppService) isUnsupportedType(messageType string) bool {
+	if messageType == "" {
+		return false
+	}
+	for _, unsupported := range w.config.UnsupportedInboundTypes {
+		if strings.EqualFold(messageType, unsupported) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapTwilioStatus maps Twilio status to our internal status. Messages Twilio has queued,
+// accepted or scheduled are pending, so they can still be canceled.
+func mapTwilioStatus(twilioStatus string) models.MessageStatus {
 	switch strings.ToLower(twilioStatus) {
-	case "queued", "accepted":
+	case "queued", "accepted", "scheduled":
 		return models.MessageStatusPending
 	case "sent":
 		return models.MessageStatusSent
@@ -326,7 +489,41 @@ func (w *WhatsAppService) mapTwilioStatus(twilioStatus string) models.MessageSta
 		return models.MessageStatusRead
 	case "failed", "undelivered":
 		return models.MessageStatusFailed
+	case "canceled":
+		return models.MessageStatusCanceled
 	default:
 		return models.MessageStatusPending
 	}
-}
\ No newline at end of file
+}
+
+// fetchTemplateVariables reads a content template's variable names from the Twilio Content API
+func fetchTemplateVariables(client *twilio.RestClient, templateSID string) ([]string, error) {
+	content, err := client.ContentV1.FetchContent(templateSID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch template %s: %w", templateSID, err)
+	}
+
+	var names []string
+	if content.Variables != nil {
+		// Variables maps each placeholder to its sample value
+		if variables, ok := (*content.Variables).(map[string]interface{}); ok {
+			for name := range variables {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// cancelTwilioMessage cancels a message Twilio has accepted or scheduled but not sent
+func cancelTwilioMessage(client *twilio.RestClient, messageSID string) error {
+	params := &twilioApi.UpdateMessageParams{}
+	params.SetStatus("canceled")
+
+	if _, err := client.Api.UpdateMessage(messageSID, params); err != nil {
+		return fmt.Errorf("failed to cancel message %s: %w", messageSID, err)
+	}
+	return nil
+}
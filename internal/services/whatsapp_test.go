@@ -0,0 +1,131 @@
+package services
+
+import (
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
+)
+
+// newTestWhatsAppService creates a service whose logs are discarded; it never reaches Twilio
+func newTestWhatsAppService() *WhatsAppService {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := &config.Config{
+		TwilioAccountSID:        "AC00000000000000000000000000000000",
+		TwilioAuthToken:         "token",
+		TwilioWhatsAppFrom:      "whatsapp:+15550000000",
+		UnsupportedInboundTypes: []string{"poll"},
+	}
+	return NewWhatsAppService(cfg, nil, logger)
+}
+
+func TestProcessIncomingMessage(t *testing.T) {
+	service := newTestWhatsAppService()
+
+	tests := []struct {
+		name            string
+		webhook         models.TwilioWebhookRequest
+		wantType        models.MessageType
+		wantContent     string
+		wantMedia       bool
+		wantUnsupported string
+	}{
+		{
+			name:        "text",
+			webhook:     models.TwilioWebhookRequest{Body: "hello", NumMedia: "0"},
+			wantType:    models.MessageTypeText,
+			wantContent: "hello",
+		},
+		{
+			name:      "image",
+			webhook:   models.TwilioWebhookRequest{NumMedia: "1", MediaUrl0: "https://api.twilio.com/media/ME0", MediaContentType0: "image/jpeg"},
+			wantType:  models.MessageTypeImage,
+			wantMedia: true,
+		},
+		{
+			name:      "voice note",
+			webhook:   models.TwilioWebhookRequest{NumMedia: "1", MediaUrl0: "https://api.twilio.com/media/ME1", MediaContentType0: "audio/ogg"},
+			wantType:  models.MessageTypeAudio,
+			wantMedia: true,
+		},
+		{
+			name:        "media count without url",
+			webhook:     models.TwilioWebhookRequest{Body: "caption", NumMedia: "1"},
+			wantType:    models.MessageTypeText,
+			wantContent: "caption",
+		},
+		{
+			name:        "location",
+			webhook:     models.TwilioWebhookRequest{Latitude: "-23.5505", Longitude: "-46.6333", Label: "Office"},
+			wantType:    models.MessageTypeLocation,
+			wantContent: FormatCoordinates(-23.5505, -46.6333) + "\nOffice",
+		},
+		{
+			name:            "unsupported type",
+			webhook:         models.TwilioWebhookRequest{MessageType: "Poll"},
+			wantType:        models.MessageTypeText,
+			wantUnsupported: "poll",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.webhook.MessageSid = "SM0"
+			tt.webhook.From = "whatsapp:+15550000001"
+			tt.webhook.To = "whatsapp:+15550000000"
+
+			message, err := service.ProcessIncomingMessage(&tt.webhook)
+			if err != nil {
+				t.Fatalf("ProcessIncomingMessage() error = %v", err)
+			}
+			if message.Type != tt.wantType {
+				t.Errorf("Type = %q, want %q", message.Type, tt.wantType)
+			}
+			if message.Content != tt.wantContent {
+				t.Errorf("Content = %q, want %q", message.Content, tt.wantContent)
+			}
+			if (message.MediaURL != nil) != tt.wantMedia {
+				t.Errorf("MediaURL set = %v, want %v", message.MediaURL != nil, tt.wantMedia)
+			}
+			if message.UnsupportedType != tt.wantUnsupported {
+				t.Errorf("UnsupportedType = %q, want %q", message.UnsupportedType, tt.wantUnsupported)
+			}
+			if message.Direction != models.MessageDirectionInbound || message.Channel != models.ChannelWhatsApp {
+				t.Errorf("Direction, Channel = %q, %q, want inbound whatsapp", message.Direction, message.Channel)
+			}
+		})
+	}
+}
+
+func BenchmarkProcessIncomingMessage(b *testing.B) {
+	service := newTestWhatsAppService()
+
+	benchmarks := []struct {
+		name    string
+		webhook models.TwilioWebhookRequest
+	}{
+		{"text", models.TwilioWebhookRequest{Body: "I would like to book a visit on Friday", NumMedia: "0"}},
+		{"media", models.TwilioWebhookRequest{NumMedia: "1", MediaUrl0: "https://api.twilio.com/media/ME0", MediaContentType0: "image/jpeg"}},
+		{"location", models.TwilioWebhookRequest{Latitude: "-23.5505", Longitude: "-46.6333"}},
+	}
+
+	for _, bm := range benchmarks {
+		bm.webhook.MessageSid = "SM0"
+		bm.webhook.From = "whatsapp:+15550000001"
+		bm.webhook.To = "whatsapp:+15550000000"
+
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := service.ProcessIncomingMessage(&bm.webhook); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -13,10 +13,13 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/handlers"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/i18n"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/middleware"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/models"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/services"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/logger"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
 	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/redis"
 )
 
@@ -27,19 +30,44 @@ func main() {
 	}
 
 	// Initialize configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	// "config validate" and "config print" inspect the configuration without starting
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(cfg, os.Args[2:]))
+	}
+
+	// "bundle export" and "bundle import" move templates, automations and policies
+	// between environments
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		os.Exit(runBundleCommand(cfg, os.Args[2:]))
+	}
+
+	// "migrate partition-messages" runs one-off migrations too slow for startup
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(cfg, os.Args[2:]))
+	}
 
 	// Initialize logger
 	log := logger.New(cfg.LogLevel)
 	log.Info("Starting re9.ai WhatsApp Adapter")
 
 	// Initialize database connection
-	db, err := database.NewPostgresConnection(cfg.DatabaseURL)
+	db, err := database.NewPostgresConnection(cfg.DatabaseURL, database.NewQueryTracer(cfg.DBSlowQueryThreshold, log))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
+	// Ensure database schema is up to date
+	if err := database.CreateTables(context.Background(), db); err != nil {
+		log.Fatalf("Failed to create database tables: %v", err)
+	}
+
 	// Initialize Redis connection
 	redisClient, err := redis.NewRedisClient(cfg.RedisURL)
 	if err != nil {
@@ -47,21 +75,336 @@ func main() {
 	}
 	defer redisClient.Close()
 
+	// Tenant and number labels of message metrics
+	services.ConfigureMetricLabels(cfg)
+
+	// Pooled connections and DNS caching of downstream HTTP clients
+	services.ConfigureHTTPTransport(cfg)
+
+	// Cache invalidations between replicas
+	cacheBus, err := services.NewCacheBus(cfg, db, redisClient, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize cache bus: %v", err)
+	}
+
 	// Initialize services
-	whatsappService := services.NewWhatsAppService(cfg, log)
-	messageService := services.NewMessageService(db, redisClient, log)
-	mediaService := services.NewMediaService(cfg, log)
+	twilioCalls := services.NewTwilioCallLog(cfg, log)
+	whatsappService := services.NewWhatsAppService(cfg, twilioCalls, log)
+
+	// Refuse to serve with a schema, Redis or Twilio sender this binary does not expect
+	startupChecker := services.NewStartupChecker(db, redisClient, whatsappService, cfg, log)
+	if failed := startupChecker.Run(context.Background()); failed > 0 {
+		if cfg.StartupChecks == services.StartupChecksEnforce {
+			log.Fatalf("%d startup checks failed; set STARTUP_CHECKS=degrade to serve anyway", failed)
+		}
+		log.WithField("failed", failed).Warn("Startup checks failed, serving in degraded mode")
+	}
+	// Values cached in Redis are namespaced and, with a key configured, encrypted
+	redisCache, err := services.NewRedisCache(redisClient, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize Redis cache: %v", err)
+	}
+	messageService := services.NewMessageService(db, redisCache, cfg, log)
+	mediaService, err := services.NewMediaService(db, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize media service: %v", err)
+	}
 	aiService := services.NewAIService(cfg, log)
+	aiService.UseMediaService(mediaService)
+	shadowService := services.NewShadowService(cfg, log)
+	if cfg.ShadowOrchestratorURL != "" {
+		aiService.UseShadow(shadowService)
+	}
+	linkService := services.NewLinkService(db, cfg, log)
+	identityService := services.NewIdentityService(db, log)
+	sessionStore := services.NewSessionStore(redisCache, log)
+	sessionService := services.NewSessionService(db, messageService, aiService, identityService, sessionStore, cfg, log)
+	messagingProvider, err := services.NewMessagingProvider(cfg, db, whatsappService, twilioCalls, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize messaging provider: %v", err)
+	}
+	log.Infof("Outbound messages use the %s provider", messagingProvider.Name())
+
+	// Outbound providers per channel; WhatsApp is always available
+	channelProviders := services.NewChannelProviders(messagingProvider)
+	smsService := services.NewSMSService(cfg, twilioCalls, log)
+	if cfg.SMSFromNumber != "" {
+		channelProviders.Register(models.ChannelSMS, smsService)
+	}
+	var metaServices []*services.MetaService
+	if cfg.MetaMessengerEnabled {
+		metaServices = append(metaServices, services.NewMetaService(models.ChannelMessenger, cfg, log))
+	}
+	if cfg.MetaInstagramEnabled {
+		metaServices = append(metaServices, services.NewMetaService(models.ChannelInstagram, cfg, log))
+	}
+	for _, metaService := range metaServices {
+		channelProviders.Register(metaService.Channel(), metaService)
+		log.Infof("%s channel enabled", metaService.Channel())
+	}
+	var emailService *services.EmailService
+	if cfg.EmailEnabled {
+		emailService = services.NewEmailService(cfg, log)
+		channelProviders.Register(models.ChannelEmail, emailService)
+		log.Info("email channel enabled")
+	}
+	var telegramService *services.TelegramService
+	if cfg.TelegramEnabled {
+		telegramService = services.NewTelegramService(cfg, mediaService, log)
+		channelProviders.Register(models.ChannelTelegram, telegramService)
+		log.Infof("telegram channel enabled (%s mode)", cfg.TelegramMode)
+	}
+
+	// Fault injection for resilience testing; never active in production
+	faultInjector := services.NewFaultInjector(cfg, log)
+	channelProviders.UseFaults(faultInjector)
+
+	// Stored provider configurations override or extend the environment providers
+	providerConfigService, err := services.NewProviderConfigService(db, cacheBus, channelProviders, mediaService, twilioCalls, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize provider configs: %v", err)
+	}
+
+	// Tenant API keys for the /api/v1 endpoints, and lockout of callers that abuse them
+	apiKeyService := services.NewAPIKeyService(db, cacheBus, cfg, log)
+	securityService := services.NewSecurityService(redisClient, db, cfg, log)
+
+	// Which Twilio auth token signs webhooks, while it is being rotated
+	webhookSecretService := services.NewWebhookSecretService(db, cfg, log)
+
+	// Catalog of the adapter's own messages in each supported locale
+	catalog, err := i18n.Load(cfg.DefaultLocale, cfg.I18nOverridesDir)
+	if err != nil {
+		log.Fatalf("Failed to load message catalog: %v", err)
+	}
+
+	autoReplyService := services.NewAutoReplyService(channelProviders, messageService, identityService, catalog, log)
+	eventService := services.NewEventService(redisClient, cfg, log)
+	dedupService := services.NewOutboundDedupService(redisClient, cfg, log)
+	notificationCapService := services.NewNotificationCapService(redisClient, sessionService, cfg, log)
+	firstResponseService := services.NewFirstResponseService(redisClient, autoReplyService, cfg, log)
+	aiResultService := services.NewAIResultService(db, eventService, log)
+	mediaJobService := services.NewMediaJobService(db, log)
+	aiResultService.UseMediaJobs(mediaJobService)
+	traceService := services.NewTraceService(db, messageService, log)
+	liveLocationService := services.NewLiveLocationService(db, messageService, eventService, log)
+	fallbackService := services.NewFallbackService(smsService, messageService, cfg, log)
+	classifierService := services.NewClassifierService(cfg, messageService, eventService, log)
+	suppressionService := services.NewSuppressionService(db, log)
+	crmExportService := services.NewCRMExportService(db, messageService, suppressionService, cfg, log)
+	sessionService.UseCRMExport(crmExportService)
+	sessionService.UseEvents(eventService)
+	agentService := services.NewAgentService(db, eventService, sessionStore, log)
+	sessionService.UseAgents(agentService)
+	outboxService := services.NewOutboxService(db, cfg, log)
+	referenceService := services.NewReferenceService(db, log)
+	paymentService := services.NewPaymentService(db, referenceService, eventService, cfg, log)
+	appointmentService := services.NewAppointmentService(db, referenceService, eventService, outboxService, cfg, log)
+	sendQueue := services.NewSendQueue(redisCache, cfg, log)
+	webhookEventService := services.NewWebhookEventService(db, cfg, log)
+	deadLetterService := services.NewDeadLetterService(db, log)
+	sloService := services.NewSLOService(db, cfg, log)
+	anomalyService, err := services.NewAnomalyService(db, redisClient, eventService, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize anomaly service: %v", err)
+	}
+	partitionService := services.NewPartitionService(db, cfg, log)
+	archiveService, err := services.NewArchiveService(db, messageService, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize archive service: %v", err)
+	}
+	importService, err := services.NewImportService(db, identityService, cfg, log)
+	if err != nil {
+		log.Fatalf("Failed to initialize import service: %v", err)
+	}
+
+	// Media and archives must never land in a bucket that stores them unencrypted
+	if cfg.S3RequireEncryption {
+		if err := mediaService.CheckEncryption(context.Background()); err != nil {
+			log.Fatalf("Media bucket encryption check failed: %v", err)
+		}
+		if err := archiveService.CheckEncryption(context.Background()); err != nil {
+			log.Fatalf("Archive bucket encryption check failed: %v", err)
+		}
+	}
+	automationService := services.NewAutomationService(db, cacheBus, sessionService, autoReplyService, eventService, cfg, log)
+	bundleService := services.NewBundleService(db, automationService, cfg, log)
+	cancellationService := services.NewCancellationService(channelProviders, messageService, eventService, log)
+	auditService := services.NewAuditService(db, log)
+	alertService := services.NewAlertService(db, eventService, log)
+	redactionService := services.NewRedactionService(db, messageService, mediaService, whatsappService, archiveService, log)
+	revocationService := services.NewRevocationService(db, messageService, redactionService, aiService, eventService, outboxService, cfg, log)
+	templateSanitizer := services.NewTemplateSanitizer(cfg)
+	validationService := services.NewSendValidationService(channelProviders, sessionService, suppressionService, dedupService, notificationCapService, mediaService, templateSanitizer, log)
+	canaryService := services.NewCanaryService(db, redisClient, twilioCalls, cfg, log)
+
+	// Background workers stop when the server shuts down
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	go cacheBus.Start(backgroundCtx)
+	go sessionService.StartIdleSweeper(backgroundCtx)
+	go providerConfigService.Start(backgroundCtx)
+	go automationService.Start(backgroundCtx)
+	go partitionService.Start(backgroundCtx)
+	if archiveService.Enabled() {
+		go archiveService.Start(backgroundCtx)
+	}
+	if crmExportService.Enabled() {
+		go crmExportService.Start(backgroundCtx)
+	}
+	go sloService.Start(backgroundCtx)
+	// The send queue drains on shutdown; the process waits for it before exiting
+	sendQueueDone := make(chan struct{})
+	go func() {
+		defer close(sendQueueDone)
+		sendQueue.Start(backgroundCtx)
+	}()
+	if anomalyService.Enabled() {
+		go anomalyService.Start(backgroundCtx)
+	}
+	if canaryService.Enabled() {
+		go canaryService.Start(backgroundCtx)
+	}
+	// Raw webhook bodies are captured for every webhook and recorded when enabled
+	var webhookRecorder middleware.WebhookRecorder
+	if webhookEventService.Enabled() {
+		webhookRecorder = webhookEventService
+		go webhookEventService.Start(backgroundCtx)
+	}
+	// Accepted webhooks are mirrored to the shadow adapter when one is configured
+	var webhookMirror middleware.WebhookMirror
+	if cfg.ShadowWebhookURL != "" {
+		webhookMirror = shadowService
+	}
 
 	// Initialize handlers
 	whatsappHandler := handlers.NewWhatsAppHandler(
 		whatsappService,
+		channelProviders,
 		messageService,
 		mediaService,
 		aiService,
+		linkService,
+		sessionService,
+		autoReplyService,
+		dedupService,
+		notificationCapService,
+		suppressionService,
+		fallbackService,
+		classifierService,
+		automationService,
+		outboxService,
+		referenceService,
+		sendQueue,
+		aiResultService,
+		mediaJobService,
+		revocationService,
 		log,
 	)
+
+	whatsappHandler.UseMediaResendTemplate(cfg.MediaResendTemplateSID)
+	whatsappHandler.UseDeadLetters(deadLetterService)
+	whatsappHandler.UseAppointments(appointmentService)
+	whatsappHandler.UseFirstResponseAck(firstResponseService)
+	whatsappHandler.UseTemplateSanitizer(templateSanitizer)
+	if cfg.InboundNormalizationEnabled {
+		whatsappHandler.UseInboundNormalizer(services.NewInboundNormalizer(cfg))
+	}
+	if cfg.TranslationEnabled {
+		translator := services.NewLibreTranslate(cfg)
+		whatsappHandler.UseTranslation(services.NewTranslationService(translator, db, identityService, cfg, log))
+	}
+	if cfg.SentimentEnabled {
+		var scorer services.SentimentProvider = services.NewLexiconSentiment()
+		if cfg.SentimentProvider == "http" {
+			scorer = services.NewHTTPSentiment(cfg)
+		}
+		whatsappHandler.UseSentiment(services.NewSentimentService(scorer, db, sessionService, eventService, cfg, log))
+	}
+
+	// Surveys are only sent while enabled; ratings already stored stay queryable
+	csatService := services.NewCSATService(db, autoReplyService, suppressionService, eventService, cfg, log)
+	if cfg.CSATEnabled {
+		sessionService.UseCSAT(csatService)
+		whatsappHandler.UseCSAT(csatService)
+		go csatService.Start(backgroundCtx)
+	}
+
+	// Voice notes are forwarded without a transcript when the AI service does not answer
+	if cfg.TranscriptionTimeout > 0 {
+		go whatsappHandler.StartTranscriptionTimeouts(backgroundCtx, cfg.TranscriptionTimeout)
+	}
+
+	// Orchestrator forwards are written to the outbox together with their message
+	outboxService.Handle(models.OutboxKindOrchestratorForward, whatsappHandler.DeliverForward)
+	outboxService.Handle(models.OutboxKindTwilioWebhook, whatsappHandler.DeliverWebhook)
+	outboxService.Handle(models.OutboxKindOrchestratorRevoke, revocationService.DeliverRevocation)
+	outboxService.Handle(models.OutboxKindCalendarWebhook, appointmentService.DeliverCalendarWebhook)
+	go outboxService.Start(backgroundCtx)
+
+	// Campaign messages go through the same checks as API sends
+	segmentService := services.NewSegmentService(db, log)
+	frequencyCapService := services.NewFrequencyCapService(db, redisClient, cfg, log)
+	campaignService := services.NewCampaignService(db, eventService, segmentService, frequencyCapService, cfg, log)
+	campaignService.UseSender(whatsappHandler.SendCampaignMessage)
+	go campaignService.Start(backgroundCtx)
+
+	sendBatchHandler := handlers.NewSendBatchHandler(whatsappHandler, validationService, cfg.SendBatchMax, log)
+	paymentHandler := handlers.NewPaymentHandler(whatsappHandler, paymentService, log)
+	appointmentHandler := handlers.NewAppointmentHandler(appointmentService, log)
+	metaHandler := handlers.NewMetaHandler(whatsappHandler, metaServices, cfg.MetaVerifyToken, log)
+	var emailHandler *handlers.EmailHandler
+	if emailService != nil {
+		emailHandler = handlers.NewEmailHandler(whatsappHandler, emailService, log)
+	}
+	var telegramHandler *handlers.TelegramHandler
+	if telegramService != nil {
+		telegramHandler = handlers.NewTelegramHandler(whatsappHandler, telegramService, liveLocationService, log)
+		if cfg.TelegramMode == services.TelegramModePolling {
+			go telegramService.Poll(backgroundCtx, telegramHandler.HandleUpdate)
+		}
+	}
 	healthHandler := handlers.NewHealthHandler(db, redisClient, log)
+	healthHandler.UseStartupChecks(startupChecker)
+	if cfg.EgressProxyHealthURL != "" {
+		healthHandler.UseEgressProxyCheck(cfg.EgressProxyHealthURL)
+	}
+	linkHandler := handlers.NewLinkHandler(linkService, log)
+	sessionHandler := handlers.NewSessionHandler(sessionService, log)
+	agentHandler := handlers.NewAgentHandler(agentService, log)
+	aiResultHandler := handlers.NewAIResultHandler(aiResultService, messageService, log)
+	userHandler := handlers.NewUserHandler(identityService, archiveService, log)
+	snapshotHandler := handlers.NewSnapshotHandler(identityService, sessionService, log)
+	providerConfigHandler := handlers.NewProviderConfigHandler(providerConfigService, log)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, cfg, log)
+	securityHandler := handlers.NewSecurityHandler(securityService, auditService, log)
+	webhookSecretHandler := handlers.NewWebhookSecretHandler(webhookSecretService, log)
+	validationHandler := handlers.NewValidationHandler(validationService, log)
+	cancellationHandler := handlers.NewCancellationHandler(cancellationService, log)
+	redactionHandler := handlers.NewRedactionHandler(redactionService, log)
+	statusHistoryHandler := handlers.NewStatusHistoryHandler(messageService, log)
+	traceHandler := handlers.NewTraceHandler(traceService, log)
+	mediaHandler := handlers.NewMediaHandler(messageService, mediaService, mediaJobService, log)
+	metadataHandler := handlers.NewMetadataHandler(messageService, sessionService, log)
+	referenceHandler := handlers.NewReferenceHandler(referenceService, sessionService, log)
+	webhookEventHandler := handlers.NewWebhookEventHandler(webhookEventService, log)
+	importHandler := handlers.NewImportHandler(importService, log)
+	deadLetterHandler := handlers.NewDeadLetterHandler(deadLetterService, log)
+	sloHandler := handlers.NewSLOHandler(sloService, log)
+	csatHandler := handlers.NewCSATHandler(csatService, log)
+	campaignHandler := handlers.NewCampaignHandler(campaignService, frequencyCapService, whatsappHandler, log)
+	segmentHandler := handlers.NewSegmentHandler(segmentService, log)
+	sendHandler := handlers.NewSendHandler(sendQueue, log)
+	auditHandler := handlers.NewAuditHandler(auditService, log)
+	alertHandler := handlers.NewAlertHandler(alertService, log)
+	suppressionHandler := handlers.NewSuppressionHandler(suppressionService, log)
+	chaosHandler := handlers.NewChaosHandler(faultInjector, log)
+	diagnosticsHandler := handlers.NewDiagnosticsHandler(log)
+	twilioCallHandler := handlers.NewTwilioCallHandler(twilioCalls, log)
+	automationHandler := handlers.NewAutomationHandler(automationService, log)
+	bundleHandler := handlers.NewBundleHandler(bundleService, log)
+	crmExportHandler := handlers.NewCRMExportHandler(crmExportService, log)
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -70,40 +413,262 @@ func main() {
 
 	router := gin.New()
 
+	// Client IPs come from forwarding headers only when set by our own proxies
+	router.RemoteIPHeaders = cfg.RemoteIPHeaders
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	if cfg.RateLimitPerMinute > 0 && len(cfg.TrustedProxies) == 0 {
+		log.Warn("Rate limiting is on without TRUSTED_PROXIES: behind a load balancer every caller without an API key shares its IP")
+	}
+	if len(cfg.TrustedProxies) == 0 {
+		log.Info("TRUSTED_PROXIES is not set: abuse detection counts and locks API keys only, not IPs")
+	}
+	if cfg.SMSFallbackEnabled && cfg.WebhookBaseURL == "" {
+		log.Warn("SMS fallback is on without WEBHOOK_BASE_URL: fallback SMS get no status callback unless the sender's Messaging Service sets one")
+	}
+	if cfg.WhatsAppWebhookSecret != "" && cfg.WebhookBaseURL == "" {
+		log.Info("WEBHOOK_BASE_URL is not set: Twilio signatures are checked against the URL rebuilt from Host and X-Forwarded-Proto")
+	}
+
 	// Global middleware
 	router.Use(middleware.Logger(log))
 	router.Use(middleware.Recovery(log))
 	router.Use(middleware.CORS())
 	router.Use(middleware.Security())
-	router.Use(middleware.RateLimit(redisClient))
+	if cfg.CompressionLevel > 0 {
+		router.Use(middleware.Compress(cfg.CompressionLevel, cfg.CompressionMinSize, cfg.CompressionTypes, "/webhooks/"))
+	}
+	router.Use(middleware.RateLimit(redisClient, cfg.RedisNamespace(), cfg.RateLimitPerMinute, cfg.RateLimitBurst, "/webhooks/", "/health", "/ready", "/metrics"))
+	if cfg.Environment != "production" {
+		router.Use(middleware.FaultInjection(faultInjector))
+	}
 
 	// Health check endpoints
 	router.GET("/health", healthHandler.Health)
 	router.GET("/ready", healthHandler.Ready)
 
-	// WhatsApp webhook endpoints
-	whatsappGroup := router.Group("/webhooks/whatsapp")
+	// Short link redirects for click tracking
+	router.GET("/l/:code", linkHandler.Redirect)
+
+	// WhatsApp webhook endpoints. In early-ACK mode webhooks are answered as soon as they
+	// are stored and processed from the outbox.
+	handleMessage := whatsappHandler.HandleMessage
+	handleStatus := whatsappHandler.HandleStatus
+	handleConversationEvent := whatsappHandler.HandleConversationEvent
+	if cfg.WebhookEarlyAck {
+		handleMessage = whatsappHandler.AcknowledgeEarly(models.WebhookRouteMessage, handleMessage)
+		handleStatus = whatsappHandler.AcknowledgeEarly(models.WebhookRouteStatus, handleStatus)
+		handleConversationEvent = whatsappHandler.AcknowledgeEarly(models.WebhookRouteConversation, handleConversationEvent)
+	}
+	webhookBudget := middleware.LatencyBudget(cfg.WebhookLatencyBudget, log)
+
+	whatsappGroup := router.Group("/webhooks/whatsapp", webhookBudget, middleware.CaptureRawBody("twilio", cfg.WebhookMaxBodyBytes, webhookRecorder), middleware.MirrorWebhooks("twilio", webhookMirror))
 	{
 		whatsappGroup.GET("/verify", whatsappHandler.VerifyWebhook)
 		whatsappGroup.POST("/messages", 
-			middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret),
-			whatsappHandler.HandleMessage,
+			middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret, cfg.WhatsAppWebhookSecondarySecret, cfg.WebhookBaseURL, webhookSecretService),
+			handleMessage,
 		)
 		whatsappGroup.POST("/status", 
-			middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret),
-			whatsappHandler.HandleStatus,
+			middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret, cfg.WhatsAppWebhookSecondarySecret, cfg.WebhookBaseURL, webhookSecretService),
+			handleStatus,
+		)
+		whatsappGroup.POST("/conversations",
+			middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret, cfg.WhatsAppWebhookSecondarySecret, cfg.WebhookBaseURL, webhookSecretService),
+			handleConversationEvent,
 		)
 	}
 
+	// Twilio debugger alerts for the account
+	router.POST("/webhooks/twilio/alerts",
+		webhookBudget,
+		middleware.CaptureRawBody("twilio", cfg.WebhookMaxBodyBytes, webhookRecorder),
+		middleware.MirrorWebhooks("twilio", webhookMirror),
+		middleware.WhatsAppSignatureVerification(cfg.WhatsAppWebhookSecret, cfg.WhatsAppWebhookSecondarySecret, cfg.WebhookBaseURL, webhookSecretService),
+		alertHandler.HandleTwilioAlert,
+	)
+
+	// Messenger and Instagram Direct webhook endpoints
+	metaGroup := router.Group("/webhooks/meta", webhookBudget, middleware.CaptureRawBody("meta", cfg.WebhookMaxBodyBytes, webhookRecorder), middleware.MirrorWebhooks("meta", webhookMirror))
+	{
+		metaGroup.GET("", metaHandler.VerifyWebhook)
+		metaGroup.POST("",
+			middleware.MetaSignatureVerification(cfg.MetaAppSecret),
+			metaHandler.HandleWebhook,
+		)
+	}
+
+	// Inbound email webhook endpoints
+	if emailHandler != nil {
+		emailGroup := router.Group("/webhooks/email", webhookBudget, middleware.CaptureRawBody("email", cfg.EmailWebhookMaxBodyBytes, webhookRecorder), middleware.MirrorWebhooks("email", webhookMirror), middleware.BasicAuthToken(cfg.EmailWebhookToken))
+		{
+			emailGroup.POST("/sendgrid", emailHandler.HandleSendGrid)
+			emailGroup.POST("/ses", emailHandler.HandleSES)
+		}
+	}
+
+	// Payment provider status callbacks; without a token anyone could mark payments paid,
+	// so the route is only registered when one is set
+	if cfg.PaymentWebhookToken != "" {
+		router.POST("/webhooks/payments",
+			webhookBudget,
+			middleware.CaptureRawBody("payments", cfg.WebhookMaxBodyBytes, webhookRecorder),
+			middleware.MirrorWebhooks("payments", webhookMirror),
+			middleware.BasicAuthToken(cfg.PaymentWebhookToken),
+			paymentHandler.HandleStatus,
+		)
+	} else {
+		log.Warn("PAYMENT_WEBHOOK_TOKEN is not set, payment status webhooks are disabled")
+	}
+
+	// Telegram bot webhook endpoint
+	if telegramHandler != nil && cfg.TelegramMode == services.TelegramModeWebhook {
+		router.POST("/webhooks/telegram",
+			webhookBudget,
+			middleware.CaptureRawBody("telegram", cfg.WebhookMaxBodyBytes, webhookRecorder),
+			middleware.MirrorWebhooks("telegram", webhookMirror),
+			middleware.TelegramSecretToken(cfg.TelegramWebhookSecret),
+			telegramHandler.HandleWebhook,
+		)
+	}
+
+	// Each endpoint requires a scope of the caller's role: readonly callers read, senders
+	// also send, operators also manage sessions and read admin data, admins do the rest
+	read := middleware.RequireScope(middleware.ScopeRead)
+	send := middleware.RequireScope(middleware.ScopeSend)
+	operate := middleware.RequireScope(middleware.ScopeOperate)
+	admin := middleware.RequireScope(middleware.ScopeAdmin)
+	guard := middleware.Guard(securityService)
+
 	// API endpoints for internal communication
-	apiGroup := router.Group("/api/v1")
+	// AI callbacks authenticate with the service token instead of a tenant key
+	apiGroup := router.Group("/api/v1", guard, middleware.APIKeyAuth(apiKeyService, cfg.APIKeysRequired, cfg.APIKeylessRole, "/api/v1/ai/"), middleware.RateLimitAPIKeys(redisClient, cfg.RedisNamespace(), cfg.RateLimitPerMinute, cfg.RateLimitBurst))
+	{
+		apiGroup.POST("/messages/send", send, whatsappHandler.SendMessage)
+		apiGroup.POST("/messages/send-batch", send, sendBatchHandler.SendBatch)
+		apiGroup.POST("/messages/validate", send, validationHandler.ValidateMessage)
+		apiGroup.POST("/payments", send, paymentHandler.SendPayment)
+		apiGroup.GET("/payments/:referenceId", read, paymentHandler.GetPayment)
+		apiGroup.GET("/appointments/:appointmentId", read, appointmentHandler.GetAppointment)
+		apiGroup.GET("/sends/:sendId", read, sendHandler.GetSend)
+		apiGroup.GET("/messages/:messageId", read, whatsappHandler.GetMessage)
+		apiGroup.DELETE("/messages/:messageId", operate, cancellationHandler.CancelMessage)
+		apiGroup.GET("/messages/:messageId/clicks", read, linkHandler.GetMessageClicks)
+		apiGroup.GET("/messages/:messageId/ai-results", read, aiResultHandler.GetMessageResults)
+		apiGroup.GET("/messages/:messageId/status-history", read, statusHistoryHandler.GetStatusHistory)
+		apiGroup.GET("/messages/:messageId/trace", read, traceHandler.GetTrace)
+		apiGroup.PATCH("/messages/:messageId/metadata", operate, metadataHandler.UpdateMessageMetadata)
+		apiGroup.GET("/messages/:messageId/media", read, mediaHandler.GetMessageMedia)
+		apiGroup.GET("/messages/:messageId/media/info", read, mediaHandler.GetMessageMediaInfo)
+		apiGroup.GET("/messages/:messageId/media-status", read, mediaHandler.GetMediaStatus)
+		apiGroup.POST("/media/upload", send, whatsappHandler.UploadMedia)
+		apiGroup.POST("/media/presign", send, mediaHandler.PresignUpload)
+		apiGroup.GET("/media", read, mediaHandler.ListMedia)
+		apiGroup.GET("/media/:mediaId", read, mediaHandler.GetMedia)
+		apiGroup.GET("/media/:mediaId/info", read, mediaHandler.GetMediaInfo)
+		apiGroup.DELETE("/media/:mediaId", operate, mediaHandler.DeleteMedia)
+		apiGroup.GET("/sessions", read, sessionHandler.ListSessions)
+		apiGroup.GET("/sessions/:sessionId", read, sessionHandler.GetSession)
+		apiGroup.PATCH("/sessions/:sessionId/metadata", operate, metadataHandler.UpdateSessionMetadata)
+		apiGroup.POST("/sessions/:sessionId/close", operate, sessionHandler.CloseSession)
+		apiGroup.POST("/sessions/:sessionId/mute", operate, sessionHandler.MuteSession)
+		apiGroup.DELETE("/sessions/:sessionId/mute", operate, sessionHandler.UnmuteSession)
+		apiGroup.POST("/sessions/:sessionId/claim", operate, agentHandler.ClaimSession)
+		apiGroup.POST("/sessions/:sessionId/release", operate, agentHandler.ReleaseSession)
+		apiGroup.POST("/sessions/:sessionId/assign", operate, agentHandler.AssignSession)
+		apiGroup.GET("/agents", read, agentHandler.ListAgents)
+		apiGroup.PUT("/agents/:agentId/presence", operate, agentHandler.SetPresence)
+		apiGroup.GET("/agents/:agentId/sessions", read, agentHandler.ListAgentSessions)
+		apiGroup.GET("/sessions/:sessionId/references", read, referenceHandler.ListSessionReferences)
+		apiGroup.POST("/sessions/:sessionId/references", operate, referenceHandler.CreateSessionReference)
+		apiGroup.GET("/sessions/:sessionId/payments", read, paymentHandler.ListSessionPayments)
+		apiGroup.GET("/sessions/:sessionId/appointments", read, appointmentHandler.ListSessionAppointments)
+		apiGroup.GET("/listings/:listingId/conversations", read, referenceHandler.ListListingConversations)
+		apiGroup.GET("/users/:phone/messages", read, userHandler.GetUserMessages)
+		apiGroup.PUT("/users/:phone/locale", operate, userHandler.SetLocale)
+		apiGroup.PUT("/users/:phone/consent", operate, userHandler.SetConsent)
+		apiGroup.PUT("/users/:phone/timezone", operate, userHandler.SetTimezone)
+		apiGroup.GET("/conversations/:phone/snapshot", read, snapshotHandler.GetSnapshot)
+		apiGroup.GET("/slo", read, sloHandler.GetReport)
+		apiGroup.GET("/analytics/csat", read, csatHandler.GetStats)
+		apiGroup.GET("/analytics/frequency-caps", read, campaignHandler.GetFrequencyCapReport)
+		apiGroup.POST("/campaigns", send, campaignHandler.CreateCampaign)
+		apiGroup.GET("/campaigns", read, campaignHandler.ListCampaigns)
+		apiGroup.GET("/campaigns/:campaignId", read, campaignHandler.GetCampaign)
+		apiGroup.POST("/campaigns/:campaignId/schedule", send, campaignHandler.ScheduleCampaign)
+		apiGroup.POST("/campaigns/:campaignId/pause", send, campaignHandler.PauseCampaign)
+		apiGroup.POST("/campaigns/:campaignId/resume", send, campaignHandler.ResumeCampaign)
+		apiGroup.GET("/campaigns/:campaignId/report", read, campaignHandler.GetReport)
+		apiGroup.GET("/campaigns/:campaignId/recipients", read, campaignHandler.ListRecipients)
+		apiGroup.POST("/segments", send, segmentHandler.CreateSegment)
+		apiGroup.GET("/segments", read, segmentHandler.ListSegments)
+		apiGroup.POST("/segments/preview", read, segmentHandler.PreviewFilters)
+		apiGroup.GET("/segments/:segmentId", read, segmentHandler.GetSegment)
+		apiGroup.DELETE("/segments/:segmentId", send, segmentHandler.DeleteSegment)
+		apiGroup.GET("/segments/:segmentId/preview", read, segmentHandler.PreviewSegment)
+
+		// Callbacks from the AI processing service; without a token anyone could inject
+		// transcripts and analysis results, so they are only registered when one is set
+		if cfg.AICallbackToken != "" {
+			aiCallbackGroup := apiGroup.Group("/ai", middleware.ServiceToken(cfg.AICallbackToken))
+			{
+				aiCallbackGroup.POST("/transcriptions", whatsappHandler.HandleTranscription)
+				aiCallbackGroup.POST("/results", aiResultHandler.HandleResult)
+			}
+		} else {
+			log.Warn("AI_CALLBACK_TOKEN is not set, AI processing callbacks are disabled")
+		}
+	}
+
+	// Admin endpoints
+	adminGroup := router.Group("/api/v1/admin", guard, middleware.TokenAuth(cfg.JWTSecret))
 	{
-		apiGroup.POST("/messages/send", whatsappHandler.SendMessage)
-		apiGroup.GET("/messages/:messageId", whatsappHandler.GetMessage)
-		apiGroup.POST("/media/upload", whatsappHandler.UploadMedia)
+		adminGroup.POST("/users/merge", admin, userHandler.MergeUsers)
+		adminGroup.POST("/phones/merge", admin, userHandler.MergePhones)
+		adminGroup.POST("/users/:userId/identities", admin, userHandler.LinkIdentity)
+		adminGroup.GET("/providers", operate, providerConfigHandler.ListProviders)
+		adminGroup.POST("/providers", admin, providerConfigHandler.CreateProvider)
+		adminGroup.PUT("/providers/:id", admin, providerConfigHandler.UpdateProvider)
+		adminGroup.DELETE("/providers/:id", admin, providerConfigHandler.DeleteProvider)
+		adminGroup.GET("/api-keys", operate, apiKeyHandler.ListAPIKeys)
+		adminGroup.POST("/api-keys", admin, apiKeyHandler.CreateAPIKey)
+		adminGroup.POST("/api-keys/:id/rotate", admin, apiKeyHandler.RotateAPIKey)
+		adminGroup.DELETE("/api-keys/:id", admin, apiKeyHandler.RevokeAPIKey)
+		adminGroup.POST("/messages/:messageId/redact", admin, redactionHandler.RedactMessage)
+		adminGroup.GET("/messages/:messageId/raw", admin, webhookEventHandler.GetMessageRaw)
+		adminGroup.POST("/import", admin, importHandler.Import)
+		adminGroup.GET("/audit-log", operate, auditHandler.ListAuditLog)
+		adminGroup.GET("/security-events", operate, securityHandler.ListSecurityEvents)
+		adminGroup.DELETE("/security-locks/:type/:id", admin, securityHandler.UnlockCaller)
+		adminGroup.GET("/alerts", operate, alertHandler.ListAlerts)
+		adminGroup.POST("/agents", admin, agentHandler.CreateAgent)
+		adminGroup.GET("/webhook-events", operate, webhookEventHandler.ListEvents)
+		adminGroup.GET("/webhook-events/:id", operate, webhookEventHandler.GetEvent)
+		adminGroup.GET("/webhook-secrets", operate, webhookSecretHandler.GetWebhookSecrets)
+		adminGroup.GET("/dead-letters", operate, deadLetterHandler.ListDeadLetters)
+		adminGroup.GET("/dead-letters/:id", operate, deadLetterHandler.GetDeadLetter)
+		adminGroup.GET("/twilio/calls", operate, twilioCallHandler.ListCalls)
+		adminGroup.GET("/suppressions", operate, suppressionHandler.ListSuppressions)
+		adminGroup.POST("/suppressions", admin, suppressionHandler.CreateSuppression)
+		adminGroup.DELETE("/suppressions/:id", admin, suppressionHandler.DeleteSuppression)
+		adminGroup.GET("/automations", operate, automationHandler.ListAutomations)
+		adminGroup.POST("/automations", admin, automationHandler.CreateAutomation)
+		adminGroup.PUT("/automations/:id", admin, automationHandler.UpdateAutomation)
+		adminGroup.DELETE("/automations/:id", admin, automationHandler.DeleteAutomation)
+		adminGroup.GET("/bundle", operate, bundleHandler.ExportBundle)
+		adminGroup.POST("/bundle/import", admin, bundleHandler.ImportBundle)
+		adminGroup.GET("/crm-exports", operate, crmExportHandler.ListExports)
+		adminGroup.GET("/crm-exports/:id", operate, crmExportHandler.GetExport)
+		adminGroup.POST("/crm-exports/:id/retry", operate, crmExportHandler.RetryExport)
+		adminGroup.GET("/chaos", operate, chaosHandler.GetFaults)
+		adminGroup.PUT("/chaos", admin, chaosHandler.UpdateFaults)
+		adminGroup.GET("/diagnostics/runtime", operate, diagnosticsHandler.Runtime)
+		adminGroup.GET("/diagnostics/goroutines", operate, diagnosticsHandler.GoroutineDump)
 	}
 
 	// Metrics endpoint for Prometheus
+	metrics.RegisterRuntime()
 	router.GET("/metrics", handlers.PrometheusHandler())
 
 	// Create HTTP server
@@ -123,20 +688,65 @@ func main() {
 		}
 	}()
 
+	// Profiling lives on a separate port that should not be exposed publicly
+	var diagnosticsServer *http.Server
+	if cfg.DiagnosticsPort != "" {
+		if cfg.DiagnosticsToken == "" {
+			log.Warn("Diagnostics server disabled: DIAGNOSTICS_TOKEN is not set")
+		} else {
+			diagnosticsRouter := gin.New()
+			diagnosticsRouter.Use(middleware.Recovery(log))
+			diagnosticsRouter.Use(middleware.ServiceToken(cfg.DiagnosticsToken))
+			handlers.RegisterProfiling(diagnosticsRouter)
+			diagnosticsRouter.GET("/debug/runtime", diagnosticsHandler.Runtime)
+			diagnosticsRouter.GET("/debug/goroutines", diagnosticsHandler.GoroutineDump)
+
+			// No write timeout: CPU profiles and traces stream for their requested duration
+			diagnosticsServer = &http.Server{
+				Addr:        fmt.Sprintf(":%s", cfg.DiagnosticsPort),
+				Handler:     diagnosticsRouter,
+				ReadTimeout: 30 * time.Second,
+				IdleTimeout: 120 * time.Second,
+			}
+
+			go func() {
+				log.Infof("Diagnostics server starting on port %s", cfg.DiagnosticsPort)
+				if err := diagnosticsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.WithError(err).Error("Diagnostics server failed")
+				}
+			}()
+		}
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Info("Shutting down server...")
+	stopBackground()
 
 	// Give outstanding requests 30 seconds to complete
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if diagnosticsServer != nil {
+		diagnosticsServer.Shutdown(ctx)
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	select {
+	case <-sendQueueDone:
+	case <-ctx.Done():
+		log.Warn("Send queue did not drain before the shutdown deadline")
+	}
+
+	if !sessionService.Drain(ctx) {
+		log.Warn("Closed sessions were still being summarized or exported at the shutdown deadline")
+	}
+
 	log.Info("Server exited")
 }
\ No newline at end of file
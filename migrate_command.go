@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/internal/config"
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/database"
+)
+
+const migrateUsage = `usage: re9ai-whatsapp-adapter migrate <migration>
+
+migrations:
+  partition-messages  convert a whatsapp_messages table created before partitioning; the
+                      adapter can keep running, the table is only locked briefly at the end`
+
+// runMigrateCommand runs a one-off migration against the configured database and returns
+// the process exit code
+func runMigrateCommand(cfg *config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "partition-messages" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	db, err := database.NewPostgresConnection(cfg.DatabaseURL, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	converted, err := database.PartitionMessagesTable(ctx, db, func(step string) {
+		fmt.Fprintln(os.Stderr, step)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to partition whatsapp_messages: %v\n", err)
+		return 1
+	}
+	if !converted {
+		fmt.Println("whatsapp_messages is already partitioned")
+		return 0
+	}
+
+	// Create the monthly partitions the running replicas would otherwise wait for
+	if _, err := database.EnsureMessagePartitions(ctx, db, time.Now(), cfg.MessagePartitionsAhead); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create message partitions: %v\n", err)
+		return 1
+	}
+	fmt.Println("whatsapp_messages is partitioned")
+	return 0
+}
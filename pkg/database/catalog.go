@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Catalog lookups let CreateTables skip statements that would rewrite or rescan a table
+// under ACCESS EXCLUSIVE when the schema already has the intended shape.
+
+// columnType returns the type of a column as format_type prints it, e.g.
+// "character varying(255)", or "" when the column does not exist
+func columnType(ctx context.Context, db *pgxpool.Pool, table, column string) (string, error) {
+	var columnType string
+	err := db.QueryRow(ctx, `
+		SELECT format_type(a.atttypid, a.atttypmod)
+		FROM pg_attribute a
+		WHERE a.attrelid = to_regclass($1) AND a.attname = $2 AND NOT a.attisdropped`,
+		table, column).Scan(&columnType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read type of %s.%s: %w", table, column, err)
+	}
+	return columnType, nil
+}
+
+// tableExists reports whether a table exists
+func tableExists(ctx context.Context, db *pgxpool.Pool, table string) (bool, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, table).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+// checkLiterals matches the quoted values of a check constraint definition
+var checkLiterals = regexp.MustCompile(`'((?:[^']|'')*)'`)
+
+// checkValues returns the quoted values a check constraint on table lists, e.g. the
+// allowed statuses of "CHECK (status IN (...))", and false when there is no such constraint
+func checkValues(ctx context.Context, db *pgxpool.Pool, table, constraint string) ([]string, bool, error) {
+	var definition string
+	err := db.QueryRow(ctx, `
+		SELECT pg_get_constraintdef(oid) FROM pg_constraint
+		WHERE conrelid = to_regclass($1) AND conname = $2`,
+		table, constraint).Scan(&definition)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read constraint %s: %w", constraint, err)
+	}
+
+	var values []string
+	for _, match := range checkLiterals.FindAllStringSubmatch(definition, -1) {
+		values = append(values, strings.ReplaceAll(match[1], "''", "'"))
+	}
+	return values, true, nil
+}
+
+// ensureInCheck makes constraint on table allow exactly values in column. It does nothing
+// when the constraint already does; otherwise the new one is added NOT VALID, which only
+// locks the table for a moment, and validated without blocking writes.
+func ensureInCheck(ctx context.Context, db *pgxpool.Pool, table, constraint, column string, values []string) error {
+	current, exists, err := checkValues(ctx, db, table, constraint)
+	if err != nil {
+		return err
+	}
+	if exists && sameValues(current, values) {
+		return nil
+	}
+
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	replace := fmt.Sprintf(`
+	ALTER TABLE %[1]s DROP CONSTRAINT IF EXISTS %[2]s;
+	ALTER TABLE %[1]s ADD CONSTRAINT %[2]s CHECK (%[3]s IN (%[4]s)) NOT VALID;`,
+		pgx.Identifier{table}.Sanitize(), pgx.Identifier{constraint}.Sanitize(), pgx.Identifier{column}.Sanitize(), strings.Join(quoted, ", "))
+	if _, err := db.Exec(ctx, replace); err != nil {
+		return fmt.Errorf("failed to replace constraint %s: %w", constraint, err)
+	}
+
+	validate := fmt.Sprintf(`ALTER TABLE %s VALIDATE CONSTRAINT %s`, pgx.Identifier{table}.Sanitize(), pgx.Identifier{constraint}.Sanitize())
+	if _, err := db.Exec(ctx, validate); err != nil {
+		return fmt.Errorf("failed to validate constraint %s: %w", constraint, err)
+	}
+	return nil
+}
+
+// sameValues reports whether a and b hold the same values, in any order
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, value := range a {
+		counts[value]++
+	}
+	for _, value := range b {
+		counts[value]--
+		if counts[value] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// explainPlan is the part of EXPLAIN (FORMAT JSON) output EstimateRows reads
+type explainPlan struct {
+	NodeType string        `json:"Node Type"`
+	PlanRows float64       `json:"Plan Rows"`
+	Plans    []explainPlan `json:"Plans"`
+}
+
+// EstimateRows returns the planner's estimate of the rows a list query matches before its
+// LIMIT and OFFSET, without running it. The estimate comes from table statistics, so it
+// is cheap but approximate.
+func EstimateRows(ctx context.Context, db *pgxpool.Pool, query string, args ...interface{}) (int64, error) {
+	var raw []byte
+	if err := db.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&raw); err != nil {
+		return 0, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	var output []struct {
+		Plan explainPlan `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &output); err != nil {
+		return 0, fmt.Errorf("failed to decode query plan: %w", err)
+	}
+	if len(output) == 0 {
+		return 0, fmt.Errorf("query plan is empty")
+	}
+
+	// The rows feeding the LIMIT are those the query matches
+	plan := output[0].Plan
+	if plan.NodeType == "Limit" && len(plan.Plans) > 0 {
+		plan = plan.Plans[0]
+	}
+	return int64(plan.PlanRows), nil
+}
@@ -0,0 +1,344 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// whatsapp_messages is range partitioned by month on timestamp. Partitions are named
+// whatsapp_messages_YYYY_MM and cover UTC calendar months. A table created before
+// partitioning is kept as the whatsapp_messages_legacy partition.
+const (
+	messagesTable          = "whatsapp_messages"
+	legacyMessagesTable    = "whatsapp_messages_legacy"
+	messagePartitionPrefix = "whatsapp_messages_"
+	messagePartitionLayout = "2006_01"
+)
+
+// partitionLockID serializes partition changes across replicas (pg advisory lock key)
+const partitionLockID = 7_301_964_211
+
+// pgInvalidObjectDefinition is raised when a new partition would overlap an existing one
+const pgInvalidObjectDefinition = "42P17"
+
+// Built on an unpartitioned table by PartitionMessagesTable before it is attached: the
+// unique index that becomes its part of the primary key, and the check of its bound
+const (
+	legacyKeyIndex        = "whatsapp_messages_id_timestamp_key"
+	legacyBoundConstraint = "whatsapp_messages_legacy_bound"
+)
+
+// ErrMessagesNotPartitioned is returned by partition maintenance until a table created
+// before partitioning was converted with PartitionMessagesTable
+var ErrMessagesNotPartitioned = errors.New("whatsapp_messages is not partitioned; run the partition-messages migration")
+
+// PartitionMessagesTable converts an unpartitioned whatsapp_messages table, reporting each
+// step to progress, and reports whether it did. The old table is attached unchanged as
+// the partition for everything up to the end of the month after next, so rows are not
+// copied. The slow work runs while the table stays in use: the unique index the new
+// primary key needs is built concurrently, and a CHECK matching the partition bound is
+// added NOT VALID and then validated, so attaching neither scans the table nor builds an
+// index. Only the final rename and attach lock the table, briefly.
+func PartitionMessagesTable(ctx context.Context, db *pgxpool.Pool, progress func(step string)) (bool, error) {
+	conn, err := lockPartitions(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	defer unlockPartitions(conn)
+
+	if partitioned, err := messagesTablePartitioned(ctx, conn); err != nil || partitioned {
+		return false, err
+	}
+
+	var newest *time.Time
+	if err := conn.QueryRow(ctx, `SELECT MAX(timestamp) FROM whatsapp_messages`).Scan(&newest); err != nil {
+		return false, fmt.Errorf("failed to read message range: %w", err)
+	}
+	upper := time.Now()
+	if newest != nil && newest.After(upper) {
+		upper = *newest
+	}
+	// The month after next, so messages stored while this runs across a month end fit
+	upper = monthStart(upper).AddDate(0, 2, 0)
+
+	progress("building the (id, timestamp) unique index")
+	if err := ensureLegacyKeyIndex(ctx, conn); err != nil {
+		return false, err
+	}
+
+	progress(fmt.Sprintf("checking that every message is older than %s", upper.Format(time.RFC3339)))
+	addBound := fmt.Sprintf(`
+		SET LOCAL lock_timeout = '10s';
+		ALTER TABLE whatsapp_messages DROP CONSTRAINT IF EXISTS %[1]s;
+		ALTER TABLE whatsapp_messages ADD CONSTRAINT %[1]s
+			CHECK (timestamp IS NOT NULL AND timestamp < '%[2]s') NOT VALID;`,
+		legacyBoundConstraint, upper.Format(time.RFC3339))
+	if _, err := conn.Exec(ctx, addBound); err != nil {
+		return false, fmt.Errorf("failed to add legacy bound check: %w", err)
+	}
+	if _, err := conn.Exec(ctx, `ALTER TABLE whatsapp_messages VALIDATE CONSTRAINT `+legacyBoundConstraint); err != nil {
+		return false, fmt.Errorf("failed to validate legacy bound check: %w", err)
+	}
+
+	progress("attaching the table as whatsapp_messages_legacy")
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin partitioning: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Give up rather than queue every other query behind the exclusive lock
+	if _, err := tx.Exec(ctx, `SET LOCAL lock_timeout = '10s'`); err != nil {
+		return false, fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `LOCK TABLE whatsapp_messages IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return false, fmt.Errorf("failed to lock whatsapp_messages: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `ALTER TABLE whatsapp_messages RENAME TO whatsapp_messages_legacy`); err != nil {
+		return false, fmt.Errorf("failed to rename whatsapp_messages: %w", err)
+	}
+
+	// Free the index names for the partitioned table; equivalent indexes are attached
+	// to its indexes instead of being rebuilt
+	rows, err := tx.Query(ctx, `
+		SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = $1 AND indexname LIKE 'idx\_messages\_%'`,
+		legacyMessagesTable)
+	if err != nil {
+		return false, fmt.Errorf("failed to list legacy indexes: %w", err)
+	}
+	var indexes []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan legacy index: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error reading legacy indexes: %w", err)
+	}
+
+	for _, name := range indexes {
+		renamed := "idx_messages_legacy_" + strings.TrimPrefix(name, "idx_messages_")
+		query := fmt.Sprintf(`ALTER INDEX %s RENAME TO %s`, pgx.Identifier{name}.Sanitize(), pgx.Identifier{renamed}.Sanitize())
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return false, fmt.Errorf("failed to rename legacy index %s: %w", name, err)
+		}
+	}
+
+	// Attaching only reuses a unique index for the primary key when it backs a constraint
+	addKey := fmt.Sprintf(`ALTER TABLE whatsapp_messages_legacy ADD CONSTRAINT %[1]s UNIQUE USING INDEX %[1]s`, legacyKeyIndex)
+	if _, err := tx.Exec(ctx, addKey); err != nil {
+		return false, fmt.Errorf("failed to add legacy key constraint: %w", err)
+	}
+
+	createPartitioned := `
+	CREATE TABLE whatsapp_messages (
+		LIKE whatsapp_messages_legacy INCLUDING DEFAULTS INCLUDING CONSTRAINTS,
+		PRIMARY KEY (id, timestamp)
+	) PARTITION BY RANGE (timestamp);`
+
+	if _, err := tx.Exec(ctx, createPartitioned); err != nil {
+		return false, fmt.Errorf("failed to create partitioned whatsapp_messages: %w", err)
+	}
+	// The bound check only belongs on the legacy partition
+	if _, err := tx.Exec(ctx, `ALTER TABLE whatsapp_messages DROP CONSTRAINT `+legacyBoundConstraint); err != nil {
+		return false, fmt.Errorf("failed to drop bound check from whatsapp_messages: %w", err)
+	}
+
+	attach := fmt.Sprintf(`
+		ALTER TABLE whatsapp_messages ATTACH PARTITION whatsapp_messages_legacy
+		FOR VALUES FROM (MINVALUE) TO ('%s')`, upper.Format(time.RFC3339))
+	if _, err := tx.Exec(ctx, attach); err != nil {
+		return false, fmt.Errorf("failed to attach legacy messages: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit partitioning: %w", err)
+	}
+
+	return true, nil
+}
+
+// EnsureMessagePartitions creates the monthly partitions from the month before now up to
+// monthsAhead months after it. Months already covered by the legacy partition are skipped.
+func EnsureMessagePartitions(ctx context.Context, db *pgxpool.Pool, now time.Time, monthsAhead int) ([]string, error) {
+	current := monthStart(now)
+	return EnsureMessagePartitionRange(ctx, db, current.AddDate(0, -1, 0), current.AddDate(0, monthsAhead, 0))
+}
+
+// EnsureMessagePartitionRange creates the monthly partitions of every month from from's
+// up to to's, such as past months receiving imported history. Months already covered by
+// the legacy partition are skipped.
+func EnsureMessagePartitionRange(ctx context.Context, db *pgxpool.Pool, from, to time.Time) ([]string, error) {
+	conn, err := lockPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockPartitions(conn)
+
+	if partitioned, err := messagesTablePartitioned(ctx, conn); err != nil {
+		return nil, err
+	} else if !partitioned {
+		return nil, ErrMessagesNotPartitioned
+	}
+
+	var created []string
+	last := monthStart(to)
+	for month := monthStart(from); !month.After(last); month = month.AddDate(0, 1, 0) {
+		name := messagePartitionPrefix + month.Format(messagePartitionLayout)
+
+		var exists bool
+		if err := conn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			return created, fmt.Errorf("failed to check partition %s: %w", name, err)
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf(`CREATE TABLE %s PARTITION OF whatsapp_messages FOR VALUES FROM ('%s') TO ('%s')`,
+			pgx.Identifier{name}.Sanitize(), month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+		if _, err := conn.Exec(ctx, query); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == pgInvalidObjectDefinition {
+				continue
+			}
+			return created, fmt.Errorf("failed to create partition %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+
+	return created, nil
+}
+
+// DropMessagePartitions drops the monthly partitions that end on or before cutoff, and
+// the legacy partition once its newest message is older than cutoff
+func DropMessagePartitions(ctx context.Context, db *pgxpool.Pool, cutoff time.Time) ([]string, error) {
+	conn, err := lockPartitions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockPartitions(conn)
+
+	if partitioned, err := messagesTablePartitioned(ctx, conn); err != nil {
+		return nil, err
+	} else if !partitioned {
+		return nil, ErrMessagesNotPartitioned
+	}
+
+	rows, err := conn.Query(ctx, `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'whatsapp_messages'::regclass`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list message partitions: %w", err)
+	}
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan message partition: %w", err)
+		}
+		partitions = append(partitions, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading message partitions: %w", err)
+	}
+
+	var dropped []string
+	for _, name := range partitions {
+		expired := false
+		if name == legacyMessagesTable {
+			var newest *time.Time
+			if err := conn.QueryRow(ctx, `SELECT MAX(timestamp) FROM whatsapp_messages_legacy`).Scan(&newest); err != nil {
+				return dropped, fmt.Errorf("failed to read legacy message range: %w", err)
+			}
+			expired = newest != nil && newest.Before(cutoff)
+		} else if month, err := time.Parse(messagePartitionLayout, strings.TrimPrefix(name, messagePartitionPrefix)); err == nil {
+			expired = !month.AddDate(0, 1, 0).After(cutoff)
+		}
+		if !expired {
+			continue
+		}
+
+		if _, err := conn.Exec(ctx, `DROP TABLE `+pgx.Identifier{name}.Sanitize()); err != nil {
+			return dropped, fmt.Errorf("failed to drop partition %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+
+	return dropped, nil
+}
+
+// Helper functions
+
+// messagesTablePartitioned reports whether whatsapp_messages is already partitioned
+func messagesTablePartitioned(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}) (bool, error) {
+	var kind string
+	err := db.QueryRow(ctx, `SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)`, messagesTable).Scan(&kind)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect whatsapp_messages: %w", err)
+	}
+	return kind == "p", nil
+}
+
+// ensureLegacyKeyIndex builds the unique (id, timestamp) index on the unpartitioned
+// table without blocking writes. An index left invalid by an interrupted run is rebuilt.
+func ensureLegacyKeyIndex(ctx context.Context, conn *pgxpool.Conn) error {
+	var valid bool
+	err := conn.QueryRow(ctx, `SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1)`, legacyKeyIndex).Scan(&valid)
+	switch {
+	case err == nil && valid:
+		return nil
+	case err == nil:
+		if _, err := conn.Exec(ctx, `DROP INDEX CONCURRENTLY `+legacyKeyIndex); err != nil {
+			return fmt.Errorf("failed to drop invalid %s: %w", legacyKeyIndex, err)
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("failed to inspect %s: %w", legacyKeyIndex, err)
+	}
+
+	if _, err := conn.Exec(ctx, `CREATE UNIQUE INDEX CONCURRENTLY `+legacyKeyIndex+` ON whatsapp_messages (id, timestamp)`); err != nil {
+		return fmt.Errorf("failed to build %s: %w", legacyKeyIndex, err)
+	}
+	return nil
+}
+
+// lockPartitions takes the session-level partition lock on a dedicated connection
+func lockPartitions(ctx context.Context, db *pgxpool.Pool) (*pgxpool.Conn, error) {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, partitionLockID); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to lock partitions: %w", err)
+	}
+	return conn, nil
+}
+
+// unlockPartitions releases the partition lock and its connection
+func unlockPartitions(conn *pgxpool.Conn) {
+	conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, partitionLockID)
+	conn.Release()
+}
+
+// monthStart returns the first instant of t's month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -2,13 +2,17 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// NewPostgresConnection creates a new PostgreSQL connection pool
-func NewPostgresConnection(databaseURL string) (*pgxpool.Pool, error) {
+// NewPostgresConnection creates a new PostgreSQL connection pool; tracer, when not nil,
+// observes every query of the pool
+func NewPostgresConnection(databaseURL string, tracer pgx.QueryTracer) (*pgxpool.Pool, error) {
 	if databaseURL == "" {
 		return nil, fmt.Errorf("database URL is required")
 	}
@@ -22,6 +26,10 @@ func NewPostgresConnection(databaseURL string) (*pgxpool.Pool, error) {
 	config.MaxConns = 25
 	config.MinConns = 5
 
+	if tracer != nil {
+		config.ConnConfig.Tracer = tracer
+	}
+
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
@@ -38,16 +46,17 @@ func NewPostgresConnection(databaseURL string) (*pgxpool.Pool, error) {
 
 // CreateTables creates the necessary database tables for the WhatsApp adapter
 func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
-	// Create whatsapp_messages table
+	// Create whatsapp_messages table, partitioned by month (see partitions.go); a unique
+	// twilio_sid would have to include the partition key, so StoreMessage enforces it
 	createMessagesTable := `
 	CREATE TABLE IF NOT EXISTS whatsapp_messages (
-		id UUID PRIMARY KEY,
-		twilio_sid VARCHAR(255) UNIQUE NOT NULL,
+		id UUID NOT NULL,
+		twilio_sid VARCHAR(255) NOT NULL,
 		from_number VARCHAR(50) NOT NULL,
 		to_number VARCHAR(50) NOT NULL,
 		direction VARCHAR(20) NOT NULL CHECK (direction IN ('inbound', 'outbound')),
 		message_type VARCHAR(20) NOT NULL CHECK (message_type IN ('text', 'image', 'document', 'audio', 'video', 'location', 'contact')),
-		status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'sent', 'delivered', 'read', 'failed')),
+		status VARCHAR(20) NOT NULL CHECK (status IN ('pending', 'sent', 'delivered', 'read', 'failed', 'canceled')),
 		content TEXT,
 		media_url TEXT,
 		media_type VARCHAR(100),
@@ -57,13 +66,189 @@ func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
 		user_id UUID,
 		session_id UUID,
 		error_code VARCHAR(50),
-		error_message TEXT
-	);`
+		error_message TEXT,
+		PRIMARY KEY (id, timestamp)
+	) PARTITION BY RANGE (timestamp);`
 
 	if _, err := db.Exec(ctx, createMessagesTable); err != nil {
 		return fmt.Errorf("failed to create whatsapp_messages table: %w", err)
 	}
 
+	// Add policy enforcement columns
+	alterMessagesTable := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS flagged BOOLEAN NOT NULL DEFAULT false,
+		ADD COLUMN IF NOT EXISTS flag_reason TEXT;`
+
+	if _, err := db.Exec(ctx, alterMessagesTable); err != nil {
+		return fmt.Errorf("failed to alter whatsapp_messages table: %w", err)
+	}
+
+	// Add media processing result columns
+	alterMessagesMediaColumns := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS extracted_text TEXT,
+		ADD COLUMN IF NOT EXISTS transcript TEXT;`
+
+	if _, err := db.Exec(ctx, alterMessagesMediaColumns); err != nil {
+		return fmt.Errorf("failed to add media columns to whatsapp_messages: %w", err)
+	}
+
+	// Add SMS fallback columns
+	alterMessagesFallbackColumns := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS category VARCHAR(50),
+		ADD COLUMN IF NOT EXISTS fallback_message_id UUID,
+		ADD COLUMN IF NOT EXISTS fallback_of UUID,
+		ADD COLUMN IF NOT EXISTS fallback_attempted_at TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterMessagesFallbackColumns); err != nil {
+		return fmt.Errorf("failed to add fallback columns to whatsapp_messages: %w", err)
+	}
+
+	// Record the channel each message travelled over; addresses outgrow phone-number widths
+	alterMessagesChannelColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS channel VARCHAR(20) NOT NULL DEFAULT 'whatsapp';`
+
+	if _, err := db.Exec(ctx, alterMessagesChannelColumn); err != nil {
+		return fmt.Errorf("failed to add channel column to whatsapp_messages: %w", err)
+	}
+
+	// Widening a column locks the table, so it only runs on databases that predate it
+	for _, column := range []string{"from_number", "to_number"} {
+		current, err := columnType(ctx, db, "whatsapp_messages", column)
+		if err != nil {
+			return err
+		}
+		if current == "character varying(255)" {
+			continue
+		}
+		if _, err := db.Exec(ctx, `ALTER TABLE whatsapp_messages ALTER COLUMN `+column+` TYPE VARCHAR(255)`); err != nil {
+			return fmt.Errorf("failed to widen whatsapp_messages.%s: %w", column, err)
+		}
+	}
+
+	// Track redacted messages
+	alterMessagesRedactionColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS redacted_at TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterMessagesRedactionColumn); err != nil {
+		return fmt.Errorf("failed to add redaction column to whatsapp_messages: %w", err)
+	}
+
+	// Classifier labels for lead scoring
+	alterMessagesLabelsColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS labels TEXT[] NOT NULL DEFAULT '{}';`
+
+	if _, err := db.Exec(ctx, alterMessagesLabelsColumn); err != nil {
+		return fmt.Errorf("failed to add labels column to whatsapp_messages: %w", err)
+	}
+
+	// Structured data attached by the orchestrator
+	alterMessagesMetadataColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';`
+
+	if _, err := db.Exec(ctx, alterMessagesMetadataColumn); err != nil {
+		return fmt.Errorf("failed to add metadata column to whatsapp_messages: %w", err)
+	}
+
+	// Set when the user deleted a message for everyone
+	alterMessagesRevokedColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterMessagesRevokedColumn); err != nil {
+		return fmt.Errorf("failed to add revoked column to whatsapp_messages: %w", err)
+	}
+
+	// Structured answers of a WhatsApp Flow submission
+	alterMessagesFlowResponseColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS flow_response JSONB;`
+
+	if _, err := db.Exec(ctx, alterMessagesFlowResponseColumn); err != nil {
+		return fmt.Errorf("failed to add flow response column to whatsapp_messages: %w", err)
+	}
+
+	// Content hash of downloaded media, shared by messages carrying the same file
+	alterMessagesMediaHashColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS media_sha256 CHAR(64);`
+
+	if _, err := db.Exec(ctx, alterMessagesMediaHashColumn); err != nil {
+		return fmt.Errorf("failed to add media hash column to whatsapp_messages: %w", err)
+	}
+
+	// Raw webhook request the message was parsed from; webhook_events rows expire, so
+	// this is not a foreign key
+	alterMessagesWebhookEventColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS webhook_event_id UUID;`
+
+	if _, err := db.Exec(ctx, alterMessagesWebhookEventColumn); err != nil {
+		return fmt.Errorf("failed to add webhook event column to whatsapp_messages: %w", err)
+	}
+
+	// Mark messages imported from a previous provider
+	alterMessagesImportedColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS imported_at TIMESTAMPTZ;`
+
+	if _, err := db.Exec(ctx, alterMessagesImportedColumn); err != nil {
+		return fmt.Errorf("failed to add imported column to whatsapp_messages: %w", err)
+	}
+
+	// Original text of inbound messages rewritten by the normalization stage, for audit
+	alterMessagesRawContentColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS raw_content TEXT;`
+
+	if _, err := db.Exec(ctx, alterMessagesRawContentColumn); err != nil {
+		return fmt.Errorf("failed to add raw content column to whatsapp_messages: %w", err)
+	}
+
+	// Text as written of messages the translation stage translated, and the user's language
+	alterMessagesTranslationColumns := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS original_content TEXT,
+		ADD COLUMN IF NOT EXISTS language VARCHAR(20);`
+
+	if _, err := db.Exec(ctx, alterMessagesTranslationColumns); err != nil {
+		return fmt.Errorf("failed to add translation columns to whatsapp_messages: %w", err)
+	}
+
+	// Sentiment score of inbound text
+	alterMessagesSentimentColumn := `
+	ALTER TABLE whatsapp_messages
+		ADD COLUMN IF NOT EXISTS sentiment DOUBLE PRECISION;`
+
+	if _, err := db.Exec(ctx, alterMessagesSentimentColumn); err != nil {
+		return fmt.Errorf("failed to add sentiment column to whatsapp_messages: %w", err)
+	}
+
+	// Allow the canceled status on tables created before message cancellation
+	messageStatuses := []string{"pending", "sent", "delivered", "read", "failed", "canceled"}
+	if err := ensureInCheck(ctx, db, "whatsapp_messages", "whatsapp_messages_status_check", "status", messageStatuses); err != nil {
+		return fmt.Errorf("failed to update whatsapp_messages status check: %w", err)
+	}
+
+	// Allow the message types added since the table was created
+	messageTypes := []string{"text", "image", "document", "audio", "video", "location", "contact",
+		"flow", "payment", "live_location", "location_request", "appointment"}
+	if err := ensureInCheck(ctx, db, "whatsapp_messages", "whatsapp_messages_message_type_check", "message_type", messageTypes); err != nil {
+		return fmt.Errorf("failed to update whatsapp_messages message type check: %w", err)
+	}
+
+	// Make sure the current months exist. Tables created before partitioning keep working
+	// unpartitioned until they are converted with the partition-messages migration.
+	if _, err := EnsureMessagePartitions(ctx, db, time.Now(), 1); err != nil && !errors.Is(err, ErrMessagesNotPartitioned) {
+		return err
+	}
+
 	// Create users table
 	createUsersTable := `
 	CREATE TABLE IF NOT EXISTS whatsapp_users (
@@ -80,6 +265,86 @@ func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
 		return fmt.Errorf("failed to create whatsapp_users table: %w", err)
 	}
 
+	// Track users merged into a canonical user; Messenger and Instagram users have no phone number
+	alterUsersTable := `
+	ALTER TABLE whatsapp_users
+		ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES whatsapp_users(id),
+		ALTER COLUMN phone_number DROP NOT NULL;`
+
+	if _, err := db.Exec(ctx, alterUsersTable); err != nil {
+		return fmt.Errorf("failed to alter whatsapp_users table: %w", err)
+	}
+
+	// Locale of the adapter's own messages to the user
+	alterUsersLocaleColumn := `
+	ALTER TABLE whatsapp_users
+		ADD COLUMN IF NOT EXISTS locale VARCHAR(20);`
+
+	if _, err := db.Exec(ctx, alterUsersLocaleColumn); err != nil {
+		return fmt.Errorf("failed to add locale column to whatsapp_users: %w", err)
+	}
+
+	// Language the user writes in, as detected by the translation stage
+	alterUsersLanguageColumn := `
+	ALTER TABLE whatsapp_users
+		ADD COLUMN IF NOT EXISTS language VARCHAR(20);`
+
+	if _, err := db.Exec(ctx, alterUsersLanguageColumn); err != nil {
+		return fmt.Errorf("failed to add language column to whatsapp_users: %w", err)
+	}
+
+	// Notification categories the user opted in to, e.g. marketing
+	alterUsersConsentColumn := `
+	ALTER TABLE whatsapp_users
+		ADD COLUMN IF NOT EXISTS consent_categories TEXT[] NOT NULL DEFAULT '{}';`
+
+	if _, err := db.Exec(ctx, alterUsersConsentColumn); err != nil {
+		return fmt.Errorf("failed to add consent_categories column to whatsapp_users: %w", err)
+	}
+
+	// Time zone of the user, for campaign quiet hours
+	alterUsersTimezoneColumn := `
+	ALTER TABLE whatsapp_users
+		ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);`
+
+	if _, err := db.Exec(ctx, alterUsersTimezoneColumn); err != nil {
+		return fmt.Errorf("failed to add timezone column to whatsapp_users: %w", err)
+	}
+
+	// Create user_identities table
+	identitiesExisted, err := tableExists(ctx, db, "user_identities")
+	if err != nil {
+		return err
+	}
+	createIdentitiesTable := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id UUID PRIMARY KEY,
+		user_id UUID NOT NULL REFERENCES whatsapp_users(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL,
+		value VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE (kind, value)
+	);`
+
+	if _, err := db.Exec(ctx, createIdentitiesTable); err != nil {
+		return fmt.Errorf("failed to create user_identities table: %w", err)
+	}
+
+	// Map users created before identities existed, once: the table is created in the same
+	// run, and users created since record their identities themselves
+	if !identitiesExisted {
+		backfillIdentities := `
+		INSERT INTO user_identities (id, user_id, kind, value, created_at)
+		SELECT gen_random_uuid(), id, 'phone', phone_number, created_at FROM whatsapp_users
+		UNION ALL
+		SELECT gen_random_uuid(), id, 'wa_id', whatsapp_id, created_at FROM whatsapp_users WHERE whatsapp_id IS NOT NULL
+		ON CONFLICT (kind, value) DO NOTHING;`
+
+		if _, err := db.Exec(ctx, backfillIdentities); err != nil {
+			return fmt.Errorf("failed to backfill user identities: %w", err)
+		}
+	}
+
 	// Create chat_sessions table
 	createSessionsTable := `
 	CREATE TABLE IF NOT EXISTS chat_sessions (
@@ -97,14 +362,824 @@ func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
 		return fmt.Errorf("failed to create chat_sessions table: %w", err)
 	}
 
+	// Add session lifecycle columns
+	alterSessionsTable := `
+	ALTER TABLE chat_sessions
+		ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		ADD COLUMN IF NOT EXISTS close_reason VARCHAR(20),
+		ADD COLUMN IF NOT EXISTS summary TEXT,
+		ADD COLUMN IF NOT EXISTS summarized_at TIMESTAMP WITH TIME ZONE,
+		ADD COLUMN IF NOT EXISTS state VARCHAR(40) NOT NULL DEFAULT 'idle',
+		ADD COLUMN IF NOT EXISTS state_updated_at TIMESTAMP WITH TIME ZONE,
+		ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';`
+
+	if _, err := db.Exec(ctx, alterSessionsTable); err != nil {
+		return fmt.Errorf("failed to alter chat_sessions table: %w", err)
+	}
+
+	// Conversations muted while an agent handles them
+	alterSessionsMuteColumns := `
+	ALTER TABLE chat_sessions
+		ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP WITH TIME ZONE,
+		ADD COLUMN IF NOT EXISTS mute_reason TEXT;`
+
+	if _, err := db.Exec(ctx, alterSessionsMuteColumns); err != nil {
+		return fmt.Errorf("failed to add mute columns to chat_sessions: %w", err)
+	}
+
+	// Create agents table; humans who take over handed-off conversations
+	createAgentsTable := `
+	CREATE TABLE IF NOT EXISTS agents (
+		id UUID PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL UNIQUE,
+		presence VARCHAR(20) NOT NULL DEFAULT 'offline',
+		max_concurrent INTEGER NOT NULL DEFAULT 0,
+		is_active BOOLEAN NOT NULL DEFAULT true,
+		last_assigned_at TIMESTAMP WITH TIME ZONE,
+		last_seen_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createAgentsTable); err != nil {
+		return fmt.Errorf("failed to create agents table: %w", err)
+	}
+
+	// Agent handling a conversation after a handoff
+	alterSessionsAssignmentColumns := `
+	ALTER TABLE chat_sessions
+		ADD COLUMN IF NOT EXISTS assigned_agent_id UUID REFERENCES agents(id),
+		ADD COLUMN IF NOT EXISTS assigned_at TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterSessionsAssignmentColumns); err != nil {
+		return fmt.Errorf("failed to add assignment columns to chat_sessions: %w", err)
+	}
+
+	// Structured data attached by the orchestrator
+	alterSessionsMetadataColumn := `
+	ALTER TABLE chat_sessions
+		ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';`
+
+	if _, err := db.Exec(ctx, alterSessionsMetadataColumn); err != nil {
+		return fmt.Errorf("failed to add metadata column to chat_sessions: %w", err)
+	}
+
+	// A user has at most one active session (idx_sessions_user_active); concurrent
+	// webhooks used to start two. Close all but the most recent one before the index is
+	// created.
+	closeDuplicateSessions := `
+	UPDATE chat_sessions s
+	SET status = 'closed', ended_at = NOW(), close_reason = 'duplicate', updated_at = NOW()
+	WHERE s.status = 'active' AND EXISTS (
+		SELECT 1 FROM chat_sessions newer
+		WHERE newer.user_id = s.user_id AND newer.status = 'active'
+			AND (COALESCE(newer.last_activity_at, '-infinity'), newer.id) > (COALESCE(s.last_activity_at, '-infinity'), s.id)
+	);`
+
+	if _, err := db.Exec(ctx, closeDuplicateSessions); err != nil {
+		return fmt.Errorf("failed to close duplicate active sessions: %w", err)
+	}
+
+	// Create tracked_links table
+	createTrackedLinksTable := `
+	CREATE TABLE IF NOT EXISTS tracked_links (
+		id UUID PRIMARY KEY,
+		code VARCHAR(16) UNIQUE NOT NULL,
+		message_id UUID,
+		original_url TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createTrackedLinksTable); err != nil {
+		return fmt.Errorf("failed to create tracked_links table: %w", err)
+	}
+
+	// Create link_clicks table
+	createLinkClicksTable := `
+	CREATE TABLE IF NOT EXISTS link_clicks (
+		id UUID PRIMARY KEY,
+		link_id UUID NOT NULL REFERENCES tracked_links(id) ON DELETE CASCADE,
+		clicked_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		ip_address VARCHAR(64),
+		user_agent TEXT,
+		referer TEXT
+	);`
+
+	if _, err := db.Exec(ctx, createLinkClicksTable); err != nil {
+		return fmt.Errorf("failed to create link_clicks table: %w", err)
+	}
+
+	// Create ai_results table
+	createAIResultsTable := `
+	CREATE TABLE IF NOT EXISTS ai_results (
+		id UUID PRIMARY KEY,
+		message_id UUID NOT NULL,
+		analysis_type VARCHAR(20) NOT NULL CHECK (analysis_type IN ('document', 'image', 'audio')),
+		status VARCHAR(20) NOT NULL CHECK (status IN ('completed', 'failed')),
+		result JSONB,
+		error TEXT,
+		model VARCHAR(100),
+		received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE (message_id, analysis_type)
+	);`
+
+	if _, err := db.Exec(ctx, createAIResultsTable); err != nil {
+		return fmt.Errorf("failed to create ai_results table: %w", err)
+	}
+
+	// Create twilio_conversations table
+	createConversationsTable := `
+	CREATE TABLE IF NOT EXISTS twilio_conversations (
+		phone_number VARCHAR(50) PRIMARY KEY,
+		conversation_sid VARCHAR(64) NOT NULL,
+		participant_sid VARCHAR(64),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createConversationsTable); err != nil {
+		return fmt.Errorf("failed to create twilio_conversations table: %w", err)
+	}
+
+	// Create provider_configs table
+	createProviderConfigsTable := `
+	CREATE TABLE IF NOT EXISTS provider_configs (
+		id UUID PRIMARY KEY,
+		name VARCHAR(100) UNIQUE NOT NULL,
+		channel VARCHAR(20) NOT NULL,
+		kind VARCHAR(50) NOT NULL,
+		from_address VARCHAR(255) NOT NULL DEFAULT '',
+		settings JSONB NOT NULL DEFAULT '{}'::jsonb,
+		credentials TEXT NOT NULL,
+		is_default BOOLEAN NOT NULL DEFAULT false,
+		is_active BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createProviderConfigsTable); err != nil {
+		return fmt.Errorf("failed to create provider_configs table: %w", err)
+	}
+
+	// Create recipient_suppressions table
+	createSuppressionsTable := `
+	CREATE TABLE IF NOT EXISTS recipient_suppressions (
+		id UUID PRIMARY KEY,
+		channel VARCHAR(20) NOT NULL,
+		recipient VARCHAR(255) NOT NULL,
+		reason TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE (channel, recipient)
+	);`
+
+	if _, err := db.Exec(ctx, createSuppressionsTable); err != nil {
+		return fmt.Errorf("failed to create recipient_suppressions table: %w", err)
+	}
+
+	// Create audit_log table
+	createAuditLogTable := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id UUID PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(100) NOT NULL,
+		target_type VARCHAR(50) NOT NULL,
+		target_id VARCHAR(255) NOT NULL,
+		details JSONB,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createAuditLogTable); err != nil {
+		return fmt.Errorf("failed to create audit_log table: %w", err)
+	}
+
+	// Client IP the action was requested from, as resolved behind trusted proxies
+	alterAuditLogClientIPColumn := `
+	ALTER TABLE audit_log
+		ADD COLUMN IF NOT EXISTS client_ip VARCHAR(45);`
+
+	if _, err := db.Exec(ctx, alterAuditLogClientIPColumn); err != nil {
+		return fmt.Errorf("failed to add client_ip column to audit_log: %w", err)
+	}
+
+	// Role the actor held when the action was taken
+	alterAuditLogActorRoleColumn := `
+	ALTER TABLE audit_log
+		ADD COLUMN IF NOT EXISTS actor_role VARCHAR(20);`
+
+	if _, err := db.Exec(ctx, alterAuditLogActorRoleColumn); err != nil {
+		return fmt.Errorf("failed to add actor_role column to audit_log: %w", err)
+	}
+
+	// Create automations table
+	createAutomationsTable := `
+	CREATE TABLE IF NOT EXISTS automations (
+		id UUID PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		channel VARCHAR(20) NOT NULL DEFAULT '',
+		match_type VARCHAR(20) NOT NULL CHECK (match_type IN ('keyword', 'regex')),
+		pattern TEXT NOT NULL,
+		action VARCHAR(40) NOT NULL CHECK (action IN ('send_template', 'tag_conversation', 'open_handoff')),
+		template_sid VARCHAR(64) NOT NULL DEFAULT '',
+		template_variables JSONB NOT NULL DEFAULT '{}',
+		tags TEXT[] NOT NULL DEFAULT '{}',
+		priority INTEGER NOT NULL DEFAULT 100,
+		is_active BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createAutomationsTable); err != nil {
+		return fmt.Errorf("failed to create automations table: %w", err)
+	}
+
+	// Create outbox table
+	createOutboxTable := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id UUID PRIMARY KEY,
+		kind VARCHAR(40) NOT NULL,
+		message_id UUID,
+		payload JSONB NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT,
+		next_attempt_at TIMESTAMP WITH TIME ZONE,
+		processed_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createOutboxTable); err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+
+	// Create crm_exports table
+	createCRMExportsTable := `
+	CREATE TABLE IF NOT EXISTS crm_exports (
+		id UUID PRIMARY KEY,
+		session_id UUID UNIQUE NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_status_code INTEGER,
+		last_error TEXT,
+		next_attempt_at TIMESTAMP WITH TIME ZONE,
+		delivered_at TIMESTAMP WITH TIME ZONE,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createCRMExportsTable); err != nil {
+		return fmt.Errorf("failed to create crm_exports table: %w", err)
+	}
+
+	// Create crm_export_attempts table
+	createCRMExportAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS crm_export_attempts (
+		id UUID PRIMARY KEY,
+		export_id UUID NOT NULL REFERENCES crm_exports(id) ON DELETE CASCADE,
+		attempt INTEGER NOT NULL,
+		status_code INTEGER,
+		error TEXT,
+		duration_ms INTEGER NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createCRMExportAttemptsTable); err != nil {
+		return fmt.Errorf("failed to create crm_export_attempts table: %w", err)
+	}
+
+	// Create message_status_events table; one row per status callback
+	createMessageStatusEventsTable := `
+	CREATE TABLE IF NOT EXISTS message_status_events (
+		id UUID PRIMARY KEY,
+		message_id UUID,
+		twilio_sid VARCHAR(255) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		provider_status VARCHAR(40) NOT NULL DEFAULT '',
+		error_code VARCHAR(50),
+		error_message TEXT,
+		payload_ref VARCHAR(255),
+		received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createMessageStatusEventsTable); err != nil {
+		return fmt.Errorf("failed to create message_status_events table: %w", err)
+	}
+
+	// Create conversation_archives table; archived messages live in object storage
+	createConversationArchivesTable := `
+	CREATE TABLE IF NOT EXISTS conversation_archives (
+		id UUID PRIMARY KEY,
+		session_id UUID UNIQUE NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL,
+		object_key TEXT NOT NULL,
+		message_count INTEGER NOT NULL,
+		first_message_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		last_message_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		archived_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createConversationArchivesTable); err != nil {
+		return fmt.Errorf("failed to create conversation_archives table: %w", err)
+	}
+
+	// IDs of the messages in an archive, so one can be found without reading every
+	// object; NULL on archives written before they were recorded, until indexed
+	alterConversationArchivesMessageIDsColumn := `
+	ALTER TABLE conversation_archives
+		ADD COLUMN IF NOT EXISTS message_ids UUID[];`
+
+	if _, err := db.Exec(ctx, alterConversationArchivesMessageIDsColumn); err != nil {
+		return fmt.Errorf("failed to add message IDs column to conversation_archives: %w", err)
+	}
+
+	// Distinct metadata of the messages in an archive as a JSON array, so a metadata
+	// filter only reads archives holding a match; NULL until indexed
+	alterConversationArchivesMetadataColumn := `
+	ALTER TABLE conversation_archives
+		ADD COLUMN IF NOT EXISTS message_metadata JSONB;`
+
+	if _, err := db.Exec(ctx, alterConversationArchivesMetadataColumn); err != nil {
+		return fmt.Errorf("failed to add message metadata column to conversation_archives: %w", err)
+	}
+
+	// Create conversation_references table; links conversations to listings
+	createConversationReferencesTable := `
+	CREATE TABLE IF NOT EXISTS conversation_references (
+		id UUID PRIMARY KEY,
+		session_id UUID NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+		message_id UUID,
+		kind VARCHAR(40) NOT NULL,
+		ref_id VARCHAR(255) NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createConversationReferencesTable); err != nil {
+		return fmt.Errorf("failed to create conversation_references table: %w", err)
+	}
+
+	// Create webhook_events table; raw webhook requests kept for audit
+	createWebhookEventsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_events (
+		id UUID PRIMARY KEY,
+		source VARCHAR(40) NOT NULL,
+		method VARCHAR(10) NOT NULL,
+		path TEXT NOT NULL,
+		headers JSONB NOT NULL DEFAULT '{}',
+		body BYTEA,
+		truncated BOOLEAN NOT NULL DEFAULT FALSE,
+		status_code INTEGER NOT NULL,
+		received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createWebhookEventsTable); err != nil {
+		return fmt.Errorf("failed to create webhook_events table: %w", err)
+	}
+
+	// Create dead_letters table; inbound payloads whose processing panicked
+	createDeadLettersTable := `
+	CREATE TABLE IF NOT EXISTS dead_letters (
+		id UUID PRIMARY KEY,
+		source VARCHAR(40) NOT NULL,
+		stage VARCHAR(40) NOT NULL,
+		message_id UUID,
+		payload JSONB,
+		error TEXT NOT NULL,
+		stack TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createDeadLettersTable); err != nil {
+		return fmt.Errorf("failed to create dead_letters table: %w", err)
+	}
+
+	// Create media_objects table; registry of media files stored in our bucket
+	createMediaObjectsTable := `
+	CREATE TABLE IF NOT EXISTS media_objects (
+		id UUID PRIMARY KEY,
+		bucket VARCHAR(255) NOT NULL,
+		object_key TEXT NOT NULL,
+		url TEXT NOT NULL,
+		message_id UUID,
+		source VARCHAR(20) NOT NULL,
+		content_type VARCHAR(255) NOT NULL DEFAULT '',
+		size_bytes BIGINT NOT NULL DEFAULT 0,
+		scan_status VARCHAR(20) NOT NULL DEFAULT 'not_scanned',
+		thumbnail_url TEXT,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE (bucket, object_key)
+	);`
+
+	if _, err := db.Exec(ctx, createMediaObjectsTable); err != nil {
+		return fmt.Errorf("failed to create media_objects table: %w", err)
+	}
+
+	// Add media inspection columns
+	alterMediaObjectsInfoColumns := `
+	ALTER TABLE media_objects
+		ADD COLUMN IF NOT EXISTS etag VARCHAR(255),
+		ADD COLUMN IF NOT EXISTS width INTEGER,
+		ADD COLUMN IF NOT EXISTS height INTEGER,
+		ADD COLUMN IF NOT EXISTS duration_seconds DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS inspected_at TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterMediaObjectsInfoColumns); err != nil {
+		return fmt.Errorf("failed to add inspection columns to media_objects: %w", err)
+	}
+
+	// Content-addressed objects are stored once per hash
+	alterMediaObjectsHashColumn := `
+	ALTER TABLE media_objects
+		ADD COLUMN IF NOT EXISTS sha256 CHAR(64);`
+
+	if _, err := db.Exec(ctx, alterMediaObjectsHashColumn); err != nil {
+		return fmt.Errorf("failed to add hash column to media_objects: %w", err)
+	}
+
+	// Direct uploads are verified before they can be sent
+	alterMediaObjectsUploadColumn := `
+	ALTER TABLE media_objects
+		ADD COLUMN IF NOT EXISTS upload_status VARCHAR(20);`
+
+	if _, err := db.Exec(ctx, alterMediaObjectsUploadColumn); err != nil {
+		return fmt.Errorf("failed to add upload status column to media_objects: %w", err)
+	}
+
+	// Create media_processing_jobs table; pipeline stages of inbound message attachments
+	createMediaProcessingJobsTable := `
+	CREATE TABLE IF NOT EXISTS media_processing_jobs (
+		id UUID PRIMARY KEY,
+		message_id UUID NOT NULL,
+		attachment_index INTEGER NOT NULL DEFAULT 0,
+		stage VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		error TEXT,
+		started_at TIMESTAMP WITH TIME ZONE,
+		completed_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		UNIQUE (message_id, attachment_index, stage)
+	);`
+
+	if _, err := db.Exec(ctx, createMediaProcessingJobsTable); err != nil {
+		return fmt.Errorf("failed to create media_processing_jobs table: %w", err)
+	}
+
+	// Create payment_requests table; order/payment templates sent in conversations
+	createPaymentRequestsTable := `
+	CREATE TABLE IF NOT EXISTS payment_requests (
+		id UUID PRIMARY KEY,
+		reference_id VARCHAR(255) NOT NULL UNIQUE,
+		message_id UUID,
+		session_id UUID REFERENCES chat_sessions(id) ON DELETE SET NULL,
+		to_number VARCHAR(255) NOT NULL,
+		channel VARCHAR(20) NOT NULL DEFAULT 'whatsapp',
+		currency CHAR(3) NOT NULL,
+		items JSONB NOT NULL DEFAULT '[]',
+		total_amount BIGINT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		transaction_id VARCHAR(255),
+		paid_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createPaymentRequestsTable); err != nil {
+		return fmt.Errorf("failed to create payment_requests table: %w", err)
+	}
+
+	// Create twilio_alerts table
+	createTwilioAlertsTable := `
+	CREATE TABLE IF NOT EXISTS twilio_alerts (
+		id UUID PRIMARY KEY,
+		alert_sid VARCHAR(64) UNIQUE NOT NULL,
+		account_sid VARCHAR(64) NOT NULL,
+		level VARCHAR(20) NOT NULL,
+		error_code VARCHAR(20),
+		description TEXT,
+		resource_sid VARCHAR(64),
+		message_id UUID,
+		phone_number VARCHAR(255),
+		payload JSONB,
+		occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createTwilioAlertsTable); err != nil {
+		return fmt.Errorf("failed to create twilio_alerts table: %w", err)
+	}
+
+	// Create live_locations table; latest position of each live location message
+	createLiveLocationsTable := `
+	CREATE TABLE IF NOT EXISTS live_locations (
+		message_id UUID PRIMARY KEY,
+		twilio_sid VARCHAR(255) NOT NULL,
+		latitude DOUBLE PRECISION NOT NULL,
+		longitude DOUBLE PRECISION NOT NULL,
+		live_period INTEGER NOT NULL DEFAULT 0,
+		sequence BIGINT NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createLiveLocationsTable); err != nil {
+		return fmt.Errorf("failed to create live_locations table: %w", err)
+	}
+
+	// Create appointments table; appointments proposed to users and their answers
+	createAppointmentsTable := `
+	CREATE TABLE IF NOT EXISTS appointments (
+		id UUID PRIMARY KEY,
+		session_id UUID REFERENCES chat_sessions(id) ON DELETE SET NULL,
+		message_id UUID,
+		to_number VARCHAR(255) NOT NULL,
+		channel VARCHAR(20) NOT NULL DEFAULT 'whatsapp',
+		title TEXT NOT NULL DEFAULT '',
+		location TEXT NOT NULL DEFAULT '',
+		listing_id VARCHAR(255),
+		starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		ends_at TIMESTAMP WITH TIME ZONE,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		responded_at TIMESTAMP WITH TIME ZONE,
+		calendar_notified_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createAppointmentsTable); err != nil {
+		return fmt.Errorf("failed to create appointments table: %w", err)
+	}
+
+	// Inbound message whose orchestrator response scheduled the appointment, so a
+	// replayed response does not propose it twice
+	alterAppointmentsSourceColumn := `
+	ALTER TABLE appointments
+		ADD COLUMN IF NOT EXISTS source_message_id UUID;`
+
+	if _, err := db.Exec(ctx, alterAppointmentsSourceColumn); err != nil {
+		return fmt.Errorf("failed to add source message column to appointments: %w", err)
+	}
+
+	// Create api_keys table; tenant keys for the API, stored as SHA-256 hashes
+	createAPIKeysTable := `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id UUID PRIMARY KEY,
+		tenant VARCHAR(100) NOT NULL,
+		name VARCHAR(255) NOT NULL DEFAULT '',
+		prefix VARCHAR(20) NOT NULL,
+		key_hash CHAR(64) NOT NULL UNIQUE,
+		created_by VARCHAR(255) NOT NULL DEFAULT '',
+		rotated_from UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		expires_at TIMESTAMP WITH TIME ZONE,
+		revoked_at TIMESTAMP WITH TIME ZONE,
+		last_used_at TIMESTAMP WITH TIME ZONE,
+		last_used_ip VARCHAR(64),
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createAPIKeysTable); err != nil {
+		return fmt.Errorf("failed to create api_keys table: %w", err)
+	}
+
+	// Role granted to each key; keys issued before roles keep full API access
+	alterAPIKeysRoleColumn := `
+	ALTER TABLE api_keys
+		ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'operator';`
+
+	if _, err := db.Exec(ctx, alterAPIKeysRoleColumn); err != nil {
+		return fmt.Errorf("failed to add role column to api_keys: %w", err)
+	}
+
+	// Create csat_responses table; satisfaction surveys of closed sessions and their ratings
+	createCSATResponsesTable := `
+	CREATE TABLE IF NOT EXISTS csat_responses (
+		id UUID PRIMARY KEY,
+		session_id UUID NOT NULL UNIQUE REFERENCES chat_sessions(id) ON DELETE CASCADE,
+		user_id UUID NOT NULL,
+		to_number VARCHAR(255) NOT NULL,
+		channel VARCHAR(20) NOT NULL DEFAULT 'whatsapp',
+		status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+		send_after TIMESTAMP WITH TIME ZONE NOT NULL,
+		survey_message_id UUID,
+		sent_at TIMESTAMP WITH TIME ZONE,
+		rating SMALLINT CHECK (rating BETWEEN 1 AND 5),
+		reply_message_id UUID,
+		responded_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createCSATResponsesTable); err != nil {
+		return fmt.Errorf("failed to create csat_responses table: %w", err)
+	}
+
+	// Create campaigns table; broadcasts of one message to an audience
+	createCampaignsTable := `
+	CREATE TABLE IF NOT EXISTS campaigns (
+		id UUID PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'draft',
+		channel VARCHAR(20) NOT NULL DEFAULT 'whatsapp',
+		provider VARCHAR(100) NOT NULL DEFAULT '',
+		message_type VARCHAR(50) NOT NULL DEFAULT '',
+		content TEXT NOT NULL DEFAULT '',
+		media_url TEXT,
+		media_type VARCHAR(100),
+		template VARCHAR(255),
+		variables JSONB,
+		category VARCHAR(50),
+		rate_per_minute INTEGER CHECK (rate_per_minute > 0),
+		audience_size INTEGER NOT NULL DEFAULT 0,
+		scheduled_at TIMESTAMP WITH TIME ZONE,
+		started_at TIMESTAMP WITH TIME ZONE,
+		paused_at TIMESTAMP WITH TIME ZONE,
+		completed_at TIMESTAMP WITH TIME ZONE,
+		dispatched_at TIMESTAMP WITH TIME ZONE,
+		created_by VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createCampaignsTable); err != nil {
+		return fmt.Errorf("failed to create campaigns table: %w", err)
+	}
+
+	// Create campaign_recipients table; the audience snapshot of each campaign
+	createCampaignRecipientsTable := `
+	CREATE TABLE IF NOT EXISTS campaign_recipients (
+		campaign_id UUID NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		recipient VARCHAR(255) NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		message_id UUID,
+		error TEXT,
+		sent_at TIMESTAMP WITH TIME ZONE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		PRIMARY KEY (campaign_id, position),
+		UNIQUE (campaign_id, recipient)
+	);`
+
+	if _, err := db.Exec(ctx, createCampaignRecipientsTable); err != nil {
+		return fmt.Errorf("failed to create campaign_recipients table: %w", err)
+	}
+
+	// Create segments table; audience definitions resolved when a campaign launches
+	createSegmentsTable := `
+	CREATE TABLE IF NOT EXISTS segments (
+		id UUID PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		filters JSONB NOT NULL DEFAULT '{}',
+		created_by VARCHAR(255) NOT NULL DEFAULT '',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createSegmentsTable); err != nil {
+		return fmt.Errorf("failed to create segments table: %w", err)
+	}
+
+	// Campaigns to a segment instead of a list of recipients
+	alterCampaignsSegmentColumn := `
+	ALTER TABLE campaigns
+		ADD COLUMN IF NOT EXISTS segment_id UUID REFERENCES segments(id) ON DELETE SET NULL;`
+
+	if _, err := db.Exec(ctx, alterCampaignsSegmentColumn); err != nil {
+		return fmt.Errorf("failed to add segment_id column to campaigns: %w", err)
+	}
+
+	// Tenant whose frequency cap applies to a campaign
+	alterCampaignsTenantColumn := `
+	ALTER TABLE campaigns
+		ADD COLUMN IF NOT EXISTS tenant VARCHAR(255) NOT NULL DEFAULT '';`
+
+	if _, err := db.Exec(ctx, alterCampaignsTenantColumn); err != nil {
+		return fmt.Errorf("failed to add tenant column to campaigns: %w", err)
+	}
+
+	// A/B test variants of a campaign's message
+	alterCampaignsVariantsColumn := `
+	ALTER TABLE campaigns
+		ADD COLUMN IF NOT EXISTS variants JSONB;`
+
+	if _, err := db.Exec(ctx, alterCampaignsVariantsColumn); err != nil {
+		return fmt.Errorf("failed to add variants column to campaigns: %w", err)
+	}
+
+	// Variant each campaign recipient got
+	alterCampaignRecipientsVariantColumn := `
+	ALTER TABLE campaign_recipients
+		ADD COLUMN IF NOT EXISTS variant VARCHAR(100);`
+
+	if _, err := db.Exec(ctx, alterCampaignRecipientsVariantColumn); err != nil {
+		return fmt.Errorf("failed to add variant column to campaign_recipients: %w", err)
+	}
+
+	// Time zone of each campaign recipient, and when one deferred past its quiet hours is due
+	alterCampaignRecipientsScheduleColumns := `
+	ALTER TABLE campaign_recipients
+		ADD COLUMN IF NOT EXISTS timezone VARCHAR(64),
+		ADD COLUMN IF NOT EXISTS send_after TIMESTAMP WITH TIME ZONE;`
+
+	if _, err := db.Exec(ctx, alterCampaignRecipientsScheduleColumns); err != nil {
+		return fmt.Errorf("failed to add schedule columns to campaign_recipients: %w", err)
+	}
+
+	// Create frequency_cap_rollups table; daily counts of campaign messages per tenant
+	createFrequencyCapRollupsTable := `
+	CREATE TABLE IF NOT EXISTS frequency_cap_rollups (
+		day DATE NOT NULL,
+		tenant VARCHAR(255) NOT NULL,
+		counted BIGINT NOT NULL DEFAULT 0,
+		capped BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, tenant)
+	);`
+
+	if _, err := db.Exec(ctx, createFrequencyCapRollupsTable); err != nil {
+		return fmt.Errorf("failed to create frequency_cap_rollups table: %w", err)
+	}
+
+	// Create webhook_secret_usage table; when webhooks signed with each secret were seen
+	createWebhookSecretUsageTable := `
+	CREATE TABLE IF NOT EXISTS webhook_secret_usage (
+		source VARCHAR(50) NOT NULL,
+		fingerprint VARCHAR(64) NOT NULL,
+		first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		PRIMARY KEY (source, fingerprint)
+	);`
+
+	if _, err := db.Exec(ctx, createWebhookSecretUsageTable); err != nil {
+		return fmt.Errorf("failed to create webhook_secret_usage table: %w", err)
+	}
+
 	// Create indexes for better performance
 	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_messages_twilio_sid ON whatsapp_messages(twilio_sid);",
 		"CREATE INDEX IF NOT EXISTS idx_messages_from_number ON whatsapp_messages(from_number);",
 		"CREATE INDEX IF NOT EXISTS idx_messages_to_number ON whatsapp_messages(to_number);",
 		"CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON whatsapp_messages(timestamp);",
 		"CREATE INDEX IF NOT EXISTS idx_messages_status ON whatsapp_messages(status);",
 		"CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON chat_sessions(user_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_user_active ON chat_sessions(user_id) WHERE status = 'active';",
+		"CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_messages_user_id ON whatsapp_messages(user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_sessions_status ON chat_sessions(status);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_last_activity ON chat_sessions(last_activity_at) WHERE status = 'active';",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_closed_activity ON chat_sessions(last_activity_at) WHERE status = 'closed';",
+		"CREATE INDEX IF NOT EXISTS idx_messages_session_id ON whatsapp_messages(session_id);",
+		"CREATE INDEX IF NOT EXISTS idx_messages_user_inbound ON whatsapp_messages(user_id, channel, timestamp) WHERE direction = 'inbound';",
+		"CREATE INDEX IF NOT EXISTS idx_messages_labels ON whatsapp_messages USING GIN (labels);",
+		"CREATE INDEX IF NOT EXISTS idx_messages_metadata ON whatsapp_messages USING GIN (metadata jsonb_path_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_metadata ON chat_sessions USING GIN (metadata jsonb_path_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_tracked_links_message_id ON tracked_links(message_id);",
+		"CREATE INDEX IF NOT EXISTS idx_link_clicks_link_id ON link_clicks(link_id);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id);",
+		"CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_twilio_alerts_occurred_at ON twilio_alerts(occurred_at);",
+		"CREATE INDEX IF NOT EXISTS idx_twilio_alerts_message_id ON twilio_alerts(message_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_provider_configs_default ON provider_configs(channel) WHERE is_default AND is_active;",
+		"CREATE INDEX IF NOT EXISTS idx_automations_priority ON automations(priority) WHERE is_active;",
+		"CREATE INDEX IF NOT EXISTS idx_crm_exports_due ON crm_exports(next_attempt_at) WHERE status = 'pending';",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(next_attempt_at) WHERE status = 'pending';",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_processed_at ON outbox(processed_at) WHERE status = 'done';",
+		"CREATE INDEX IF NOT EXISTS idx_outbox_message_id ON outbox(message_id);",
+		"CREATE INDEX IF NOT EXISTS idx_crm_export_attempts_export_id ON crm_export_attempts(export_id);",
+		"CREATE INDEX IF NOT EXISTS idx_message_status_events_sid ON message_status_events(twilio_sid, received_at);",
+		"CREATE INDEX IF NOT EXISTS idx_message_status_events_received_at ON message_status_events(received_at);",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_archives_user ON conversation_archives(user_id, last_message_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_archives_message_ids ON conversation_archives USING GIN (message_ids);",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_archives_message_metadata ON conversation_archives USING GIN (message_metadata jsonb_path_ops);",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_archives_last_message_at ON conversation_archives(last_message_at);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_references_unique ON conversation_references(session_id, kind, ref_id, COALESCE(message_id, '00000000-0000-0000-0000-000000000000'));",
+		"CREATE INDEX IF NOT EXISTS idx_conversation_references_ref ON conversation_references(kind, ref_id, created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_events_received_at ON webhook_events(received_at);",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_events_source ON webhook_events(source, received_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_dead_letters_created_at ON dead_letters(created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_media_objects_message_id ON media_objects(message_id);",
+		"CREATE INDEX IF NOT EXISTS idx_media_objects_url ON media_objects(url);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_media_objects_sha256 ON media_objects(bucket, sha256) WHERE sha256 IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_messages_media_sha256 ON whatsapp_messages(media_sha256) WHERE media_sha256 IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_payment_requests_session_id ON payment_requests(session_id, created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_appointments_session_id ON appointments(session_id, created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_appointments_pending ON appointments(channel, to_number, created_at) WHERE status = 'pending';",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_appointments_source_message ON appointments(source_message_id) WHERE source_message_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_api_keys_tenant ON api_keys(tenant, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_assigned_agent ON chat_sessions(assigned_agent_id) WHERE status = 'active';",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_awaiting_agent ON chat_sessions(started_at) WHERE status = 'active' AND state = 'handoff' AND assigned_agent_id IS NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_csat_responses_due ON csat_responses(send_after) WHERE status = 'scheduled';",
+		"CREATE INDEX IF NOT EXISTS idx_csat_responses_pending ON csat_responses(channel, to_number, sent_at) WHERE status = 'sent';",
+		"CREATE INDEX IF NOT EXISTS idx_csat_responses_sent_at ON csat_responses(sent_at) WHERE sent_at IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_csat_responses_reply ON csat_responses(reply_message_id) WHERE reply_message_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_campaigns_status ON campaigns(status, created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_campaigns_due ON campaigns(scheduled_at) WHERE status = 'scheduled';",
+		"CREATE INDEX IF NOT EXISTS idx_campaign_recipients_pending ON campaign_recipients(campaign_id, position) WHERE status = 'pending';",
+		"CREATE INDEX IF NOT EXISTS idx_campaign_recipients_sending ON campaign_recipients(updated_at) WHERE status = 'sending';",
+		"CREATE INDEX IF NOT EXISTS idx_campaigns_segment_id ON campaigns(segment_id) WHERE segment_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_segments_created_at ON segments(created_at DESC);",
+		"CREATE INDEX IF NOT EXISTS idx_sessions_tags ON chat_sessions USING GIN (tags);",
+		"CREATE INDEX IF NOT EXISTS idx_media_processing_jobs_analyzing ON media_processing_jobs(started_at) WHERE stage = 'analyzed' AND status = 'running';",
 	}
 
 	for _, indexSQL := range indexes {
@@ -113,5 +1188,5 @@ func CreateTables(ctx context.Context, db *pgxpool.Pool) error {
 		}
 	}
 
-	return nil
+	return recordSchemaVersion(ctx, db)
 }
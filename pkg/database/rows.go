@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RowMapper maps the columns of a table to the fields of T by their db tags, so the
+// column lists of queries and the fields they are scanned into cannot drift apart.
+// Fields tagged db:"-" or without a tag are not stored.
+type RowMapper[T any] struct {
+	columns []string
+	fields  map[string]int // column -> field index
+}
+
+// NewRowMapper creates the mapper of struct type T; it panics if T is not a struct or
+// two fields share a column, as both are programming errors
+func NewRowMapper[T any]() *RowMapper[T] {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("database: row mapper of non-struct type %s", t))
+	}
+
+	m := &RowMapper[T]{fields: make(map[string]int)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		column, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		if column == "" || column == "-" || !field.IsExported() {
+			continue
+		}
+		if _, ok := m.fields[column]; ok {
+			panic(fmt.Sprintf("database: column %s mapped twice in %s", column, t))
+		}
+		m.fields[column] = i
+		m.columns = append(m.columns, column)
+	}
+	return m
+}
+
+// Columns returns the comma-separated columns in field order, with a leading space so
+// it can follow SELECT or RETURNING directly
+func (m *RowMapper[T]) Columns() string {
+	return " " + strings.Join(m.columns, ", ")
+}
+
+// Scan reads a row selected with Columns into dest
+func (m *RowMapper[T]) Scan(row pgx.Row, dest *T) error {
+	v := reflect.ValueOf(dest).Elem()
+	targets := make([]interface{}, len(m.columns))
+	for i, column := range m.columns {
+		targets[i] = v.Field(m.fields[column]).Addr().Interface()
+	}
+	return row.Scan(targets...)
+}
+
+// Insert returns an INSERT statement of the given columns into table, with one
+// placeholder per column in the same order as Values
+func (m *RowMapper[T]) Insert(table string, columns ...string) string {
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		if _, ok := m.fields[column]; !ok {
+			panic(fmt.Sprintf("database: column %s of %s is not mapped", column, table))
+		}
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+}
+
+// Values returns the values of the given columns of src, as arguments of Insert
+func (m *RowMapper[T]) Values(src *T, columns ...string) []interface{} {
+	v := reflect.ValueOf(src).Elem()
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = v.Field(m.fields[column]).Interface()
+	}
+	return values
+}
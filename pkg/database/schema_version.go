@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaVersion is the schema version CreateTables brings the database to. Bump it with
+// every change to CreateTables, so an older binary notices a schema it does not know.
+const SchemaVersion = 23
+
+// ErrSchemaMismatch is returned when the database schema is not the one this binary expects
+var ErrSchemaMismatch = errors.New("database schema version mismatch")
+
+// recordSchemaVersion creates schema_migrations and records SchemaVersion as applied
+func recordSchemaVersion(ctx context.Context, db *pgxpool.Pool) error {
+	createMigrationsTable := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	);`
+
+	if _, err := db.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	if _, err := db.Exec(ctx, `
+		INSERT INTO schema_migrations (version) VALUES ($1)
+		ON CONFLICT (version) DO NOTHING`, SchemaVersion); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	return nil
+}
+
+// CheckSchemaVersion returns the highest schema version applied to the database and
+// ErrSchemaMismatch unless it is SchemaVersion. A higher version means the database was
+// migrated by a newer binary, e.g. before a rollback.
+func CheckSchemaVersion(ctx context.Context, db *pgxpool.Pool) (int, error) {
+	var version int
+	err := db.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if version != SchemaVersion {
+		return version, fmt.Errorf("%w: database is at version %d, this binary expects %d", ErrSchemaMismatch, version, SchemaVersion)
+	}
+	return version, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+
+	"github.com/re9-ai/re9ai-whatsapp-adapter/pkg/metrics"
+)
+
+// Query metrics, by the statement name set with WithQuery
+var (
+	queriesTotal      = metrics.NewCounter("db_queries_total", "Database queries by statement and outcome", "statement", "outcome")
+	querySecondsTotal = metrics.NewCounter("db_query_seconds_total", "Total time spent in database queries by statement", "statement")
+	slowQueriesTotal  = metrics.NewCounter("db_slow_queries_total", "Database queries slower than the slow query threshold by statement", "statement")
+)
+
+const (
+	// unnamedStatement labels queries whose context carries no statement name
+	unnamedStatement = "other"
+
+	// maxLoggedSQLLength truncates the SQL of slow query logs
+	maxLoggedSQLLength = 500
+)
+
+type statementKey struct{}
+
+// WithQuery names the statements run with the returned context and bounds them by
+// timeout; a timeout of 0 only names them
+func WithQuery(ctx context.Context, name string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, statementKey{}, name)
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// statementName returns the statement name of ctx
+func statementName(ctx context.Context) string {
+	if name, ok := ctx.Value(statementKey{}).(string); ok && name != "" {
+		return name
+	}
+	return unnamedStatement
+}
+
+// QueryTracer records query latency and logs queries slower than a threshold. Logged
+// queries show the SQL and the number of parameters, never their values.
+type QueryTracer struct {
+	threshold time.Duration // 0 disables slow query logging
+	logger    *logrus.Logger
+}
+
+// NewQueryTracer creates a tracer that logs queries slower than threshold
+func NewQueryTracer(threshold time.Duration, logger *logrus.Logger) *QueryTracer {
+	return &QueryTracer{threshold: threshold, logger: logger}
+}
+
+type queryTraceKey struct{}
+
+// queryTrace is the state of a query between its start and end
+type queryTrace struct {
+	sql     string
+	params  int
+	started time.Time
+}
+
+// TraceQueryStart implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryTraceKey{}, &queryTrace{
+		sql:     data.SQL,
+		params:  len(data.Args),
+		started: time.Now(),
+	})
+}
+
+// TraceQueryEnd implements pgx.QueryTracer
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(queryTraceKey{}).(*queryTrace)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(trace.started)
+	statement := statementName(ctx)
+
+	outcome := "ok"
+	switch {
+	case errors.Is(data.Err, context.DeadlineExceeded):
+		outcome = "timeout"
+	case data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows):
+		outcome = "error"
+	}
+	queriesTotal.Inc(statement, outcome)
+	querySecondsTotal.Add(elapsed.Seconds(), statement)
+
+	if t.threshold <= 0 || elapsed < t.threshold {
+		return
+	}
+	slowQueriesTotal.Inc(statement)
+
+	entry := t.logger.WithFields(logrus.Fields{
+		"statement":   statement,
+		"duration_ms": elapsed.Milliseconds(),
+		"sql":         compactSQL(trace.sql),
+		"params":      trace.params,
+		"outcome":     outcome,
+	})
+	if data.Err != nil {
+		entry = entry.WithError(data.Err)
+	}
+	entry.Warn("Slow database query")
+}
+
+// compactSQL collapses whitespace and truncates the SQL of a logged query
+func compactSQL(sql string) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if len(sql) > maxLoggedSQLLength {
+		sql = sql[:maxLoggedSQLLength] + "..."
+	}
+	return sql
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import "sync"
+
+// OverflowValue replaces label values past a guard's limit
+const OverflowValue = "other"
+
+// labelOverflowTotal counts label values folded into OverflowValue
+var labelOverflowTotal = NewCounter("metrics_label_overflow_total", "Label values reported as \"other\" because the label reached its cardinality limit", "label")
+
+// LabelGuard bounds the number of distinct values of an unbounded label, such as a
+// phone number, so a metric cannot grow without limit. The first values seen keep
+// their own series; later ones are reported as OverflowValue.
+type LabelGuard struct {
+	label string
+
+	mu    sync.Mutex
+	limit int
+	seen  map[string]struct{}
+}
+
+// NewLabelGuard creates a guard allowing limit distinct values of label
+func NewLabelGuard(label string, limit int) *LabelGuard {
+	return &LabelGuard{
+		label: label,
+		limit: limit,
+		seen:  make(map[string]struct{}),
+	}
+}
+
+// SetLimit changes the number of distinct values allowed; values already seen are kept
+func (g *LabelGuard) SetLimit(limit int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.limit = limit
+}
+
+// Value returns value if it already has a series or the limit allows a new one, and
+// OverflowValue otherwise. Empty values are passed through.
+func (g *LabelGuard) Value(value string) string {
+	if value == "" {
+		return value
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[value]; ok {
+		return value
+	}
+	if len(g.seen) >= g.limit {
+		labelOverflowTotal.Inc(g.label)
+		return OverflowValue
+	}
+	g.seen[value] = struct{}{}
+	return value
+}
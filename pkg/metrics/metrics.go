@@ -0,0 +1,206 @@
+// Package metrics keeps process-wide counters and gauges and renders them in the
+// Prometheus text exposition format.
+//
+// Metrics are registered once, usually as package-level variables, and are safe
+// for concurrent use:
+//
+//	var alerts = metrics.NewCounter("twilio_alerts_total", "Twilio alerts received", "level")
+//	alerts.Inc("error")
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+// Registry holds registered metrics
+type Registry struct {
+	mu         sync.RWMutex
+	metrics    map[string]*Vec
+	collectors []func()
+}
+
+// Default is the registry the package-level constructors register with
+var Default = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*Vec)}
+}
+
+// Vec is a metric with zero or more labels; each label value combination is one series
+type Vec struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounter registers a counter on the default registry
+func NewCounter(name, help string, labelNames ...string) *Vec {
+	return Default.register(name, help, typeCounter, labelNames)
+}
+
+// NewGauge registers a gauge on the default registry
+func NewGauge(name, help string, labelNames ...string) *Vec {
+	return Default.register(name, help, typeGauge, labelNames)
+}
+
+// register adds a metric, returning the existing one if the name is already registered
+func (r *Registry) register(name, help, metricType string, labelNames []string) *Vec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.metrics[name]; ok {
+		return existing
+	}
+
+	vec := &Vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		series:     make(map[string]*series),
+	}
+	r.metrics[name] = vec
+	return vec
+}
+
+// Inc adds one to the series with the given label values
+func (v *Vec) Inc(labelValues ...string) {
+	v.Add(1, labelValues...)
+}
+
+// Add adds delta to the series with the given label values
+func (v *Vec) Add(delta float64, labelValues ...string) {
+	v.mu.Lock()
+	v.get(labelValues).value += delta
+	v.mu.Unlock()
+}
+
+// Set sets the series with the given label values; meant for gauges and sampled counters
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.mu.Lock()
+	v.get(labelValues).value = value
+	v.mu.Unlock()
+}
+
+// Value returns the current value of a series
+func (v *Vec) Value(labelValues ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.get(labelValues).value
+}
+
+// get returns the series for label values, creating it; missing values are empty
+func (v *Vec) get(labelValues []string) *series {
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: values}
+		v.series[key] = s
+	}
+	return s
+}
+
+// AddCollector registers a function that updates metrics right before they are rendered,
+// for values that are sampled rather than counted as they happen
+func (r *Registry) AddCollector(collect func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collect)
+}
+
+// WriteText renders every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	collectors := r.collectors
+	r.mu.RUnlock()
+	for _, collect := range collectors {
+		collect()
+	}
+
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.RLock()
+		vec := r.metrics[name]
+		r.mu.RUnlock()
+
+		if err := vec.writeText(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeText renders one metric and its series
+func (v *Vec) writeText(w io.Writer) error {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		s := v.series[key]
+		lines = append(lines, v.name+formatLabels(v.labelNames, s.labelValues)+" "+strconv.FormatFloat(s.value, 'g', -1, 64))
+	}
+	v.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.metricType); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels renders a label set such as {level="error",code="11200"}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestVec(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.register("test_total", "Test counter", typeCounter, []string{"channel", "status"})
+
+	counter.Inc("whatsapp", "ok")
+	counter.Add(2, "whatsapp", "ok")
+	counter.Inc("sms")
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   float64
+	}{
+		{"incremented series", []string{"whatsapp", "ok"}, 3},
+		{"missing label value", []string{"sms", ""}, 1},
+		{"unseen series", []string{"whatsapp", "failed"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := counter.Value(tt.labels...); got != tt.want {
+				t.Errorf("Value(%v) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	if again := registry.register("test_total", "Test counter", typeCounter, nil); again != counter {
+		t.Error("registering a name twice returned a new metric")
+	}
+}
+
+func BenchmarkCounterInc(b *testing.B) {
+	counter := NewRegistry().register("bench_total", "Benchmark counter", typeCounter, []string{"channel"})
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			counter.Inc("whatsapp")
+		}
+	})
+}
+
+func BenchmarkCounterIncManySeries(b *testing.B) {
+	counter := NewRegistry().register("bench_series_total", "Benchmark counter", typeCounter, []string{"series"})
+	labels := make([]string, 64)
+	for i := range labels {
+		labels[i] = strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		counter.Inc(labels[i%len(labels)])
+	}
+}
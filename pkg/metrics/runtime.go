@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+)
+
+var registerRuntimeOnce sync.Once
+
+// RegisterRuntime adds Go runtime metrics (goroutines, memory and GC statistics) to the
+// default registry; they are sampled on every scrape
+func RegisterRuntime() {
+	registerRuntimeOnce.Do(func() {
+		goroutines := NewGauge("go_goroutines", "Number of goroutines that currently exist")
+		threads := NewGauge("go_threads", "Number of OS threads created")
+		heapAlloc := NewGauge("go_memstats_heap_alloc_bytes", "Heap bytes allocated and still in use")
+		heapObjects := NewGauge("go_memstats_heap_objects", "Number of allocated heap objects")
+		sys := NewGauge("go_memstats_sys_bytes", "Bytes obtained from the OS")
+		gcCycles := NewCounter("go_gc_cycles_total", "Completed GC cycles")
+		gcPause := NewCounter("go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses")
+		nextGC := NewGauge("go_memstats_next_gc_bytes", "Heap size at which the next GC cycle starts")
+
+		Default.AddCollector(func() {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			threadCount, _ := runtime.ThreadCreateProfile(nil)
+
+			goroutines.Set(float64(runtime.NumGoroutine()))
+			threads.Set(float64(threadCount))
+			heapAlloc.Set(float64(stats.HeapAlloc))
+			heapObjects.Set(float64(stats.HeapObjects))
+			sys.Set(float64(stats.Sys))
+			gcCycles.Set(float64(stats.NumGC))
+			gcPause.Set(float64(stats.PauseTotalNs) / 1e9)
+			nextGC.Set(float64(stats.NextGC))
+		})
+	})
+}
@@ -0,0 +1,111 @@
+// Package secretbox encrypts small secrets (provider credentials) for storage at rest.
+//
+// Sealed values have the form "<key id>:<base64 nonce||ciphertext>" and are produced
+// with AES-256-GCM. The key ID lets older values be opened after the sealing key is
+// rotated, as long as the old key is still registered with AddKey.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the required key length in bytes
+const KeySize = 32
+
+// ErrUnknownKey is returned when a sealed value names a key that is not registered
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Box seals values with its current key and opens values sealed with any registered key
+type Box struct {
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// New creates a box that seals with the given key
+func New(keyID string, key []byte) (*Box, error) {
+	b := &Box{aeads: make(map[string]cipher.AEAD)}
+	if err := b.AddKey(keyID, key); err != nil {
+		return nil, err
+	}
+	b.currentID = keyID
+	return b, nil
+}
+
+// NewFromBase64 creates a box from a base64-encoded key
+func NewFromBase64(keyID, encodedKey string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 key: %w", err)
+	}
+	return New(keyID, key)
+}
+
+// AddKey registers an additional key used only to open existing values
+func (b *Box) AddKey(keyID string, key []byte) error {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return fmt.Errorf("invalid key id %q", keyID)
+	}
+	if len(key) != KeySize {
+		return fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	b.aeads[keyID] = aead
+	return nil
+}
+
+// Seal encrypts plaintext with the current key
+func (b *Box) Seal(plaintext []byte) (string, error) {
+	aead := b.aeads[b.currentID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(b.currentID))
+	return b.currentID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (b *Box) Open(value string) ([]byte, error) {
+	keyID, encoded, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, errors.New("malformed sealed value")
+	}
+
+	aead, ok := b.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed sealed value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed sealed value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return plaintext, nil
+}
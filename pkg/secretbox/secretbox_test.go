@@ -0,0 +1,105 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestSealOpen(t *testing.T) {
+	box, err := New("k1", testKey(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	sealed, err := box.Seal([]byte("provider-token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	rotated, err := New("k2", testKey(2))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := rotated.AddKey("k1", testKey(1)); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+	wrongKey, err := New("k1", testKey(3))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	keyID, encoded, _ := strings.Cut(sealed, ":")
+	raw, _ := base64.StdEncoding.DecodeString(encoded)
+	raw[len(raw)-1] ^= 0xff
+	tampered := keyID + ":" + base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name    string
+		box     *Box
+		value   string
+		want    string
+		wantErr bool
+		errIs   error
+	}{
+		{name: "round trip", box: box, value: sealed, want: "provider-token"},
+		{name: "opened after rotation", box: rotated, value: sealed, want: "provider-token"},
+		{name: "tampered ciphertext", box: box, value: tampered, wantErr: true},
+		{name: "relabelled key id", box: rotated, value: "k2:" + encoded, wantErr: true},
+		{name: "wrong key", box: wrongKey, value: sealed, wantErr: true},
+		{name: "unregistered key", box: box, value: "k9:" + encoded, wantErr: true, errIs: ErrUnknownKey},
+		{name: "malformed", box: box, value: "not sealed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.box.Open(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.errIs != nil && !errors.Is(err, tt.errIs) {
+				t.Errorf("Open() error = %v, want %v", err, tt.errIs)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Open() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSealUsesFreshNonces(t *testing.T) {
+	box, err := New("k1", testKey(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	a, _ := box.Seal([]byte("same"))
+	b, _ := box.Seal([]byte("same"))
+	if a == b {
+		t.Error("Seal() produced identical values for the same plaintext")
+	}
+}
+
+func TestNewRejectsBadKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		keyID string
+		key   []byte
+	}{
+		{"short key", "k1", testKey(1)[:16]},
+		{"empty key id", "", testKey(1)},
+		{"key id with separator", "k:1", testKey(1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.keyID, tt.key); err == nil {
+				t.Error("New() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package signing implements HMAC request signing for service-to-service HTTP calls.
+//
+// A signed request carries four headers:
+//
+//	X-Re9-Key-Id     identifies the shared secret used
+//	X-Re9-Timestamp  Unix seconds when the request was signed
+//	X-Re9-Nonce      random value that may only be used once
+//	X-Re9-Signature  "v1=" + hex HMAC-SHA256 of the canonical request
+//
+// The canonical request is the timestamp, nonce, method, request URI and hex
+// SHA-256 of the body, joined by newlines. Receivers verify with a Verifier,
+// which rejects stale timestamps and replayed nonces.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header names
+const (
+	HeaderKeyID     = "X-Re9-Key-Id"
+	HeaderTimestamp = "X-Re9-Timestamp"
+	HeaderNonce     = "X-Re9-Nonce"
+	HeaderSignature = "X-Re9-Signature"
+)
+
+const signatureVersion = "v1="
+
+// DefaultTolerance is the maximum clock skew accepted by a Verifier
+const DefaultTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingHeaders   = errors.New("signing: missing signature headers")
+	ErrUnknownKey       = errors.New("signing: unknown key id")
+	ErrStaleTimestamp   = errors.New("signing: timestamp outside tolerance")
+	ErrReplayedNonce    = errors.New("signing: nonce already used")
+	ErrInvalidSignature = errors.New("signing: invalid signature")
+)
+
+// Signer signs outgoing requests with a shared secret
+type Signer struct {
+	keyID  string
+	secret []byte
+}
+
+// NewSigner creates a signer. It returns nil when secret is empty, and a nil
+// Signer leaves requests unsigned, so callers can configure signing optionally.
+func NewSigner(keyID, secret string) *Signer {
+	if secret == "" {
+		return nil
+	}
+	return &Signer{
+		keyID:  keyID,
+		secret: []byte(secret),
+	}
+}
+
+// Sign adds signature headers to req. body must be the exact request body.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	if s == nil {
+		return nil
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderKeyID, s.keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, signatureVersion+computeSignature(s.secret, timestamp, nonce, req.Method, req.URL.RequestURI(), body))
+
+	return nil
+}
+
+// NonceStore remembers nonces for replay protection
+type NonceStore interface {
+	// Remember records nonce for ttl and reports whether it was not seen before
+	Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// Verifier checks signatures on incoming requests
+type Verifier struct {
+	secrets   map[string][]byte
+	nonces    NonceStore
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+// NewVerifier creates a verifier for the given key id to secret map. If nonces is
+// nil an in-memory store is used, which only protects a single receiving instance.
+func NewVerifier(secrets map[string]string, nonces NonceStore) *Verifier {
+	keys := make(map[string][]byte, len(secrets))
+	for keyID, secret := range secrets {
+		keys[keyID] = []byte(secret)
+	}
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	return &Verifier{
+		secrets:   keys,
+		nonces:    nonces,
+		tolerance: DefaultTolerance,
+		now:       time.Now,
+	}
+}
+
+// WithTolerance overrides the accepted clock skew
+func (v *Verifier) WithTolerance(tolerance time.Duration) *Verifier {
+	v.tolerance = tolerance
+	return v
+}
+
+// Verify checks the signature of req against body
+func (v *Verifier) Verify(ctx context.Context, req *http.Request, body []byte) error {
+	keyID := req.Header.Get(HeaderKeyID)
+	timestamp := req.Header.Get(HeaderTimestamp)
+	nonce := req.Header.Get(HeaderNonce)
+	signature := req.Header.Get(HeaderSignature)
+
+	if keyID == "" || timestamp == "" || nonce == "" || !strings.HasPrefix(signature, signatureVersion) {
+		return ErrMissingHeaders
+	}
+
+	secret, ok := v.secrets[keyID]
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	skew := v.now().Sub(time.Unix(unix, 0))
+	if skew > v.tolerance || skew < -v.tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := computeSignature(secret, timestamp, nonce, req.Method, req.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(strings.TrimPrefix(signature, signatureVersion)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	// Only remember nonces of authentic requests so forged ones cannot fill the store
+	fresh, err := v.nonces.Remember(ctx, keyID+":"+nonce, 2*v.tolerance)
+	if err != nil {
+		return fmt.Errorf("signing: failed to record nonce: %w", err)
+	}
+	if !fresh {
+		return ErrReplayedNonce
+	}
+
+	return nil
+}
+
+// VerifyRequest reads the body of req, verifies it and restores the body for later handlers
+func (v *Verifier) VerifyRequest(req *http.Request) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("signing: failed to read body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return v.Verify(req.Context(), req, body)
+}
+
+// MemoryNonceStore is an in-process NonceStore
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Remember records a nonce, pruning expired entries as it goes
+func (m *MemoryNonceStore) Remember(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for seen, expiresAt := range m.nonces {
+		if now.After(expiresAt) {
+			delete(m.nonces, seen)
+		}
+	}
+
+	if _, exists := m.nonces[nonce]; exists {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// computeSignature returns the hex HMAC-SHA256 of the canonical request
+func computeSignature(secret []byte, timestamp, nonce, method, requestURI string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		timestamp,
+		nonce,
+		strings.ToUpper(method),
+		requestURI,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newNonce returns a random 128-bit hex nonce
+func newNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("signing: failed to generate nonce: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,169 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testKeyID  = "adapter"
+	testSecret = "shared-secret"
+	testURL    = "https://ai.internal/api/v1/images/analyze?priority=high"
+)
+
+// signedRequest returns a request signed with Sign and its body
+func signedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, testURL, nil)
+	if err := NewSigner(testKeyID, testSecret).Sign(req, []byte(body)); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	body := `{"message_id":"1"}`
+
+	tests := []struct {
+		name    string
+		modify  func(req *http.Request) (*http.Request, string)
+		now     time.Time
+		wantErr error
+	}{
+		{
+			name:   "valid signature",
+			modify: func(req *http.Request) (*http.Request, string) { return req, body },
+		},
+		{
+			name:    "tampered body",
+			modify:  func(req *http.Request) (*http.Request, string) { return req, `{"message_id":"2"}` },
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "tampered path",
+			modify: func(req *http.Request) (*http.Request, string) {
+				other := httptest.NewRequest(http.MethodPost, "https://ai.internal/api/v1/documents/analyze?priority=high", nil)
+				other.Header = req.Header
+				return other, body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "different secret",
+			modify: func(req *http.Request) (*http.Request, string) {
+				if err := NewSigner(testKeyID, "other-secret").Sign(req, []byte(body)); err != nil {
+					t.Fatalf("Sign() error = %v", err)
+				}
+				return req, body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "expired timestamp",
+			modify:  func(req *http.Request) (*http.Request, string) { return req, body },
+			now:     time.Now().Add(DefaultTolerance + time.Minute),
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "clock ahead of the receiver",
+			modify:  func(req *http.Request) (*http.Request, string) { return req, body },
+			now:     time.Now().Add(-DefaultTolerance - time.Minute),
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name: "unparsable timestamp",
+			modify: func(req *http.Request) (*http.Request, string) {
+				req.Header.Set(HeaderTimestamp, "yesterday")
+				return req, body
+			},
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name: "unknown key",
+			modify: func(req *http.Request) (*http.Request, string) {
+				req.Header.Set(HeaderKeyID, "someone-else")
+				return req, body
+			},
+			wantErr: ErrUnknownKey,
+		},
+		{
+			name: "missing signature",
+			modify: func(req *http.Request) (*http.Request, string) {
+				req.Header.Del(HeaderSignature)
+				return req, body
+			},
+			wantErr: ErrMissingHeaders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewVerifier(map[string]string{testKeyID: testSecret}, nil)
+			if !tt.now.IsZero() {
+				verifier.now = func() time.Time { return tt.now }
+			}
+
+			req, received := tt.modify(signedRequest(t, body))
+			err := verifier.Verify(context.Background(), req, []byte(received))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyReplayedNonce(t *testing.T) {
+	verifier := NewVerifier(map[string]string{testKeyID: testSecret}, nil)
+	body := []byte(`{"message_id":"1"}`)
+	req := signedRequest(t, string(body))
+
+	if err := verifier.Verify(context.Background(), req, body); err != nil {
+		t.Fatalf("first Verify() error = %v", err)
+	}
+	if err := verifier.Verify(context.Background(), req, body); !errors.Is(err, ErrReplayedNonce) {
+		t.Errorf("replayed Verify() error = %v, want %v", err, ErrReplayedNonce)
+	}
+
+	// A forged request with the same nonce does not burn it for the genuine one
+	fresh := signedRequest(t, string(body))
+	forged := httptest.NewRequest(http.MethodPost, testURL, nil)
+	forged.Header = fresh.Header.Clone()
+	forged.Header.Set(HeaderSignature, signatureVersion+"00")
+	if err := verifier.Verify(context.Background(), forged, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("forged Verify() error = %v, want %v", err, ErrInvalidSignature)
+	}
+	if err := verifier.Verify(context.Background(), fresh, body); err != nil {
+		t.Errorf("genuine Verify() after forgery error = %v", err)
+	}
+}
+
+func TestNilSigner(t *testing.T) {
+	if signer := NewSigner(testKeyID, ""); signer != nil {
+		t.Fatalf("NewSigner() without a secret = %v, want nil", signer)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, testURL, nil)
+	var signer *Signer
+	if err := signer.Sign(req, nil); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if req.Header.Get(HeaderSignature) != "" {
+		t.Error("nil Signer signed the request")
+	}
+}
+
+func TestSignatureTimestamp(t *testing.T) {
+	req := signedRequest(t, "")
+	unix, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp %q: %v", req.Header.Get(HeaderTimestamp), err)
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew < 0 || skew > 5*time.Second {
+		t.Errorf("timestamp is %v old, want now", skew)
+	}
+}